@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/bench"
+	"github.com/pinpt/ripsrc/ripsrc/cmd/cmdutils"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Runs end-to-end benchmarks on reference repos",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		opts := bench.Opts{}
+		opts.CacheDir, _ = cmd.Flags().GetString("cache-dir")
+		opts.Iterations, _ = cmd.Flags().GetInt("iterations")
+		opts.AllBranches, _ = cmd.Flags().GetBool("all-branches")
+		repos, _ := cmd.Flags().GetString("repos")
+		if repos != "" {
+			for _, name := range strings.Split(repos, ",") {
+				found := false
+				for _, r := range bench.DefaultRepos {
+					if r.Name == name {
+						opts.Repos = append(opts.Repos, r)
+						found = true
+					}
+				}
+				if !found {
+					cmdutils.ExitWithErr(fmt.Errorf("unknown reference repo: %v", name))
+					os.Exit(1)
+				}
+			}
+		}
+
+		res, err := bench.Run(ctx, opts)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		if out != "" {
+			f, err := os.Create(out)
+			if err != nil {
+				cmdutils.ExitWithErr(err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			err = bench.WriteJSON(f, res)
+			if err != nil {
+				cmdutils.ExitWithErr(err)
+				os.Exit(1)
+			}
+		}
+
+		baseline, _ := cmd.Flags().GetString("baseline")
+		if baseline != "" {
+			f, err := os.Open(baseline)
+			if err != nil {
+				cmdutils.ExitWithErr(err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			base, err := bench.ReadJSON(f)
+			if err != nil {
+				cmdutils.ExitWithErr(err)
+				os.Exit(1)
+			}
+			threshold, _ := cmd.Flags().GetFloat64("threshold")
+			regressions := bench.Compare(base, res, threshold)
+			for _, r := range regressions {
+				fmt.Println("regression", r)
+			}
+			if len(regressions) != 0 {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func registerBench() {
+	cmd := benchCmd
+	cmd.Flags().String("cache-dir", "", "directory to clone reference repos into, reused between runs")
+	cmd.Flags().String("repos", "", "comma separated names of reference repos to run on, all if empty")
+	cmd.Flags().Int("iterations", 1, "number of runs for each repo")
+	cmd.Flags().Bool("all-branches", false, "process all branches")
+	cmd.Flags().String("out", "", "write results as json lines to this file")
+	cmd.Flags().String("baseline", "", "compare results with json lines file written by previous run, exit with error on regressions")
+	cmd.Flags().Float64("threshold", 0.1, "allowed increase compared to baseline, 0.1 is 10%")
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc"
+	"github.com/pinpt/ripsrc/ripsrc/cmd/cmdutils"
+	"github.com/spf13/cobra"
+)
+
+var recompressCheckpointsCmd = &cobra.Command{
+	Use:   "recompress-checkpoints <repodir>",
+	Short: "Rewrites existing checkpoints of a repo using different compression",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		opts := ripsrc.Opts{}
+		opts.RepoDir = args[0]
+		opts.CheckpointsDir, _ = cmd.Flags().GetString("checkpoints-dir")
+		opts.NamespaceCheckpoints, _ = cmd.Flags().GetBool("namespace-checkpoints")
+		opts.RepoID, _ = cmd.Flags().GetString("repo-id")
+		opts.CheckpointCompression, _ = cmd.Flags().GetString("compression")
+		err := ripsrc.New(opts).RecompressCheckpoints(ctx)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var migrateCheckpointsCmd = &cobra.Command{
+	Use:   "migrate-checkpoints <repodir>",
+	Short: "Upgrades existing checkpoints of a repo written by older versions of ripsrc",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		opts := ripsrc.Opts{}
+		opts.RepoDir = args[0]
+		opts.CheckpointsDir, _ = cmd.Flags().GetString("checkpoints-dir")
+		opts.NamespaceCheckpoints, _ = cmd.Flags().GetBool("namespace-checkpoints")
+		opts.RepoID, _ = cmd.Flags().GetString("repo-id")
+		err := ripsrc.New(opts).MigrateCheckpoints(ctx)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint <repodir>",
+	Short: "Prints information about saved incremental state of a repo",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		opts := ripsrc.Opts{}
+		opts.RepoDir = args[0]
+		opts.CheckpointsDir, _ = cmd.Flags().GetString("checkpoints-dir")
+		opts.NamespaceCheckpoints, _ = cmd.Flags().GetBool("namespace-checkpoints")
+		opts.RepoID, _ = cmd.Flags().GetString("repo-id")
+		info, err := ripsrc.New(opts).Checkpoint(ctx)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+		info.OutputStats(os.Stdout)
+	},
+}
+
+var listCheckpointsCmd = &cobra.Command{
+	Use:   "list-checkpoints <checkpointsdir>",
+	Short: "Lists repos with checkpoints in a directory used with --namespace-checkpoints",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		list, err := ripsrc.ListCheckpoints(args[0])
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+		for _, rc := range list {
+			fmt.Println(rc.RepoID, rc.Updated.Format(time.RFC3339), rc.SizeBytes, rc.RemoteURL, rc.RepoDir)
+		}
+	},
+}
+
+var deleteCheckpointsCmd = &cobra.Command{
+	Use:   "delete-checkpoints <checkpointsdir> <repoid>",
+	Short: "Deletes checkpoints of a repo from a directory used with --namespace-checkpoints",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := ripsrc.DeleteCheckpoints(args[0], args[1])
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func addCheckpointsDirFlags(cmd *cobra.Command) {
+	cmd.Flags().String("checkpoints-dir", "", "directory with checkpoints, repodir is used if empty")
+	cmd.Flags().Bool("namespace-checkpoints", false, "checkpoints-dir is shared by many repos, checkpoints are stored in per repo subdirectory")
+	cmd.Flags().String("repo-id", "", "repo id used with namespace-checkpoints, hash of origin url if empty")
+}
+
+func registerCheckpoints() {
+	cmd := recompressCheckpointsCmd
+	addCheckpointsDirFlags(cmd)
+	cmd.Flags().String("compression", "gzip", "compression to use, gzip or none")
+	rootCmd.AddCommand(cmd)
+
+	cmd = migrateCheckpointsCmd
+	addCheckpointsDirFlags(cmd)
+	rootCmd.AddCommand(cmd)
+
+	cmd = checkpointCmd
+	addCheckpointsDirFlags(cmd)
+	rootCmd.AddCommand(cmd)
+
+	rootCmd.AddCommand(listCheckpointsCmd)
+	rootCmd.AddCommand(deleteCheckpointsCmd)
+}
@@ -25,6 +25,8 @@ var codeCmd = &cobra.Command{
 		opts.Dir = args[0]
 		opts.CommitFromIncl, _ = cmd.Flags().GetString("sha")
 		opts.Profile, _ = cmd.Flags().GetString("profile")
+		opts.DebugAddr, _ = cmd.Flags().GetString("debug-addr")
+		opts.Plan, _ = cmd.Flags().GetBool("plan")
 		cmdcode.Run(ctx, os.Stdout, opts)
 	},
 }
@@ -39,6 +41,7 @@ var branchesCmd = &cobra.Command{
 		opts := cmdbranches.Opts{}
 		opts.Dir = args[0]
 		opts.Profile, _ = cmd.Flags().GetString("profile")
+		opts.DebugAddr, _ = cmd.Flags().GetString("debug-addr")
 		cmdbranches.Run(ctx, os.Stdout, opts)
 	},
 }
@@ -48,12 +51,17 @@ var branchesCmd = &cobra.Command{
 func Execute() {
 
 	RegisterIncBlame()
+	registerBench()
+	registerCheckpoints()
 
 	codeCmd.Flags().String("sha", "", "start streaming from sha")
 	codeCmd.Flags().String("profile", "", "one of mem, mutex, cpu, block, trace or empty to disable")
+	codeCmd.Flags().String("debug-addr", "", "serve pprof and expvar on this address while running, for example localhost:6060")
+	codeCmd.Flags().Bool("plan", false, "only print repos, branches and commit counts that would be processed, without running blame")
 	rootCmd.AddCommand(codeCmd)
 
 	branchesCmd.Flags().String("profile", "", "one of mem, mutex, cpu, block, trace or empty to disable")
+	branchesCmd.Flags().String("debug-addr", "", "serve pprof and expvar on this address while running, for example localhost:6060")
 	rootCmd.AddCommand(branchesCmd)
 
 	if err := rootCmd.Execute(); err != nil {
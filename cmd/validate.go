@@ -1,12 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"strings"
-
-	"github.com/pinpt/ripsrc/ripsrc/gitblame2"
+	"os"
 
 	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/history3/verify"
 	"github.com/spf13/cobra"
 )
 
@@ -37,6 +37,8 @@ var validateIncBlameCmd = &cobra.Command{
 	Use:  "validate_inc_blame <repodirs...>",
 	Args: cobra.RangeArgs(1, 999),
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		failed := false
 		for _, repoDir := range args {
 			fmt.Println("running on repo", repoDir)
 
@@ -44,74 +46,35 @@ var validateIncBlameCmd = &cobra.Command{
 			opts.RepoDir = repoDir
 			opts.CommitFromIncl, _ = cmd.Flags().GetString("commit-from-incl")
 
+			vopts := verify.Opts{}
+			vopts.RepoDir = repoDir
+			vopts.SampleRate, _ = cmd.Flags().GetFloat64("sample-rate")
+			vopts.Seed, _ = cmd.Flags().GetInt64("seed")
+			vopts.Strict, _ = cmd.Flags().GetBool("strict")
+			verifier := verify.New(vopts)
+
 			pr := process.New(opts)
 
 			res := make(chan process.Result)
 			done := make(chan bool)
+			divergences := 0
 			go func() {
 				for r := range res {
 					fmt.Println("Checking commit:", r.Commit)
-
-				LOOPFILES:
-					for p, bl1 := range r.Files {
-						fmt.Println("Checking file:", r.Commit, p)
+					for p := range r.Files {
 						if manuallyChecked[p] {
 							fmt.Println("Skipping checking file (manully checked to be ok to differ):", p)
-							continue
-						}
-						if len(bl1.Lines) == 0 {
-							// removed file, no blame
-							continue
-						}
-						bl2, err := gitblame2.Run(repoDir, r.Commit, p)
-						if err != nil {
-							panic(err)
-						}
-						rerr := func() {
-							fmt.Println("Failed checks.")
-							fmt.Println("Content of incremental blame")
-							fmt.Println(bl1)
-							fmt.Println("Content of git blame")
-							fmt.Println(bl2)
-							fmt.Println("ERROR!")
-						}
-						// validate
-						if len(bl1.Lines) != len(bl2.Lines) {
-							fmt.Println("Error: lines len mismatch")
-							rerr()
-							continue
+							delete(r.Files, p)
 						}
-						for i := range bl1.Lines {
-							l1 := bl1.Lines[i]
-							l2 := bl2.Lines[i]
-							// TODO: looks like currently we convert newlines to linux style, even if it was different in source
-							c1 := strings.TrimSpace(string(l1.Line))
-							c2 := strings.TrimSpace(l2.Content)
-							if l1.Commit != l2.CommitHash {
-								// repeating blocks are in sometime in different order when both ordering is correct
-								mostlySameContent := false
-								if c1 == c2 {
-									if len(c1) <= 10 {
-										mostlySameContent = true
-									}
-									if c1 == "return" {
-										mostlySameContent = true
-									}
-								}
-								// the new blame sometimes assigned ownership to lines in a different order, happens for repeating lines, such as empty lines, lines, containing braces, etc
-								// ignore diff for lines with 1 char only
-								if !mostlySameContent {
-									fmt.Println("invalid commit, line ", i)
-									continue LOOPFILES
-								}
-							}
-							if c1 != c2 {
-								fmt.Println("invalid content, line ", i)
-								continue LOOPFILES
-							}
-						}
-
 					}
+					div, err := verifier.CheckResult(ctx, r)
+					if err != nil {
+						panic(err)
+					}
+					for _, d := range div {
+						fmt.Println("ERROR!", d)
+					}
+					divergences += len(div)
 				}
 				done <- true
 			}()
@@ -120,13 +83,24 @@ var validateIncBlameCmd = &cobra.Command{
 				panic(err)
 			}
 			<-done
+			if divergences != 0 {
+				fmt.Println("FAILED on", repoDir, "divergences", divergences)
+				failed = true
+				continue
+			}
 			fmt.Println("SUCCESS on", repoDir)
 		}
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
 func RegisterIncBlame() {
 	cmd := validateIncBlameCmd
 	cmd.Flags().String("commit-from-incl", "", "start from specific commit (inclusive)")
+	cmd.Flags().Float64("sample-rate", 1, "fraction of files in each commit to check with git blame")
+	cmd.Flags().Int64("seed", 0, "seed for selecting sampled files")
+	cmd.Flags().Bool("strict", false, "also report short lines, such as braces, attributed to a different commit")
 	rootCmd.AddCommand(cmd)
 }
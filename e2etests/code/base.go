@@ -170,7 +170,7 @@ func assertCommitEqual(t *testing.T, c1, c2 ripsrc.Commit) bool {
 		t.Error("commit.CommitterEmail mismatch")
 		return false
 	}
-	if !reflect.DeepEqual(c1.Files, c2.Files) {
+	if !commitFilesEqual(c1.Files, c2.Files) {
 		t.Errorf("commit.Files mismatch")
 		t.Log("got")
 		for k, f := range c2.Files {
@@ -212,7 +212,33 @@ func equalDate(d1, d2 time.Time) bool {
 	return off1 == off2
 }
 
-func commitFileEqual(f1, f2 *ripsrc.CommitFile) bool {
+// commitFilesEqual compares commit files. Blob shas, previous blob shas and modes are only checked when set in want.
+func commitFilesEqual(want, got map[string]*ripsrc.CommitFile) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok || w == nil || g == nil {
+			if w != g {
+				return false
+			}
+			continue
+		}
+		g2 := *g
+		if w.BlobSHA == "" {
+			g2.BlobSHA = ""
+		}
+		if w.BlobSHAPrev == "" {
+			g2.BlobSHAPrev = ""
+		}
+		if w.Mode == "" {
+			g2.Mode = ""
+		}
+		if !reflect.DeepEqual(*w, g2) {
+			return false
+		}
+	}
 	return true
 }
 
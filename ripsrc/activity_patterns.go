@@ -0,0 +1,122 @@
+package ripsrc
+
+import (
+	"context"
+	"sort"
+)
+
+// AuthorActivity is the number of commits by one author in each local hour of day and day of week.
+// Local time uses the timezone offset recorded in the commit date, so it reflects the working hours of the author rather than UTC.
+type AuthorActivity struct {
+	AuthorEmail string
+	// AuthorName is the last name used with AuthorEmail.
+	AuthorName string
+	// Commits is the number of non-merge commits.
+	Commits int
+	// Hours is the number of commits by local hour of day.
+	Hours [24]int
+	// Weekdays is the number of commits by local day of week, indexed by time.Weekday, so Sunday is 0.
+	Weekdays [7]int
+	// WeekdayHours is the number of commits by local day of week and hour of day.
+	WeekdayHours [7][24]int
+	// TZOffsets is the number of commits by timezone offset in seconds east of UTC.
+	TZOffsets map[int]int
+}
+
+// ActivityPatterns aggregates commits into activity by local hour and day of week per author.
+// Add every commit with AddCommit, then call Result.
+type ActivityPatterns struct {
+	// CommitDate selects the commit date used. Both author and committer dates keep their own timezone offsets.
+	CommitDate CommitDate
+
+	authors map[string]*AuthorActivity
+}
+
+// NewActivityPatterns creates empty aggregation.
+func NewActivityPatterns() *ActivityPatterns {
+	s := &ActivityPatterns{}
+	s.authors = map[string]*AuthorActivity{}
+	return s
+}
+
+// AddCommit adds commit to the activity of its author. Merge commits are ignored.
+func (s *ActivityPatterns) AddCommit(c Commit) {
+	if len(c.Parents) > 1 {
+		return
+	}
+	a, ok := s.authors[c.AuthorEmail]
+	if !ok {
+		a = &AuthorActivity{}
+		a.AuthorEmail = c.AuthorEmail
+		a.TZOffsets = map[int]int{}
+		s.authors[c.AuthorEmail] = a
+	}
+	if c.AuthorName != "" {
+		a.AuthorName = c.AuthorName
+	}
+	date := s.CommitDate.Of(c)
+	_, offset := date.Zone()
+	hour := date.Hour()
+	day := date.Weekday()
+	a.Commits++
+	a.Hours[hour]++
+	a.Weekdays[day]++
+	a.WeekdayHours[day][hour]++
+	a.TZOffsets[offset]++
+}
+
+// Result returns activity sorted by author email.
+func (s *ActivityPatterns) Result() (res []AuthorActivity) {
+	for _, a := range s.authors {
+		res = append(res, *a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].AuthorEmail < res[j].AuthorEmail
+	})
+	return
+}
+
+// ActivityPatterns processes the repo using CodeByCommit and returns activity by local hour and day of week per author. Uses and updates checkpoints the same way as CodeByCommit.
+func (s *Ripsrc) ActivityPatterns(ctx context.Context, res chan AuthorActivity) error {
+	defer close(res)
+	agg := NewActivityPatterns()
+	agg.CommitDate = s.opts.CommitDate
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			agg.AddCommit(c.Commit)
+			for range c.Blames {
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+	for _, r := range agg.Result() {
+		select {
+		case res <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) ActivityPatternsSlice(ctx context.Context) (res []AuthorActivity, _ error) {
+	resChan := make(chan AuthorActivity)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.ActivityPatterns(ctx, resChan)
+	<-done
+	return res, err
+}
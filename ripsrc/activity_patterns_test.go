@@ -0,0 +1,64 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestActivityPatternsAggregate(t *testing.T) {
+	ist := time.FixedZone("", 5*3600+1800)
+	pst := time.FixedZone("", -8*3600)
+	agg := NewActivityPatterns()
+	// Monday 23:30 local, Tuesday in UTC
+	agg.AddCommit(Commit{AuthorEmail: "a", AuthorName: "A", Date: time.Date(2019, 1, 7, 23, 30, 0, 0, pst)})
+	agg.AddCommit(Commit{AuthorEmail: "a", AuthorName: "A", Date: time.Date(2019, 1, 8, 9, 0, 0, 0, ist)})
+	agg.AddCommit(Commit{AuthorEmail: "a", Date: time.Date(2019, 1, 8, 9, 0, 0, 0, ist), Parents: []string{"p1", "p2"}})
+	agg.AddCommit(Commit{AuthorEmail: "b", AuthorName: "B", Date: time.Date(2019, 1, 6, 9, 15, 0, 0, time.UTC)})
+
+	got := agg.Result()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 authors, got %v", len(got))
+	}
+	a := got[0]
+	if a.AuthorEmail != "a" || a.AuthorName != "A" || a.Commits != 2 {
+		t.Errorf("unexpected author %+v", a)
+	}
+	if a.Hours[23] != 1 || a.Hours[9] != 1 {
+		t.Errorf("unexpected hours %v", a.Hours)
+	}
+	if a.Weekdays[time.Monday] != 1 || a.Weekdays[time.Tuesday] != 1 {
+		t.Errorf("unexpected weekdays %v", a.Weekdays)
+	}
+	if a.WeekdayHours[time.Monday][23] != 1 || a.WeekdayHours[time.Tuesday][9] != 1 {
+		t.Errorf("unexpected weekday hours %v", a.WeekdayHours)
+	}
+	if a.TZOffsets[-8*3600] != 1 || a.TZOffsets[5*3600+1800] != 1 {
+		t.Errorf("unexpected offsets %v", a.TZOffsets)
+	}
+	b := got[1]
+	if b.Weekdays[time.Sunday] != 1 || b.Hours[9] != 1 || b.TZOffsets[0] != 1 {
+		t.Errorf("unexpected author %+v", b)
+	}
+}
+
+func TestActivityPatternsKeepsOffset(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Git("commit", "-q", "--amend", "--no-edit", "--date=2019-01-07T23:30:00-08:00")
+
+	res, err := New(Opts{RepoDir: r.Dir()}).ActivityPatternsSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 author, got %v", len(res))
+	}
+	a := res[0]
+	if a.Hours[23] != 1 || a.Weekdays[time.Monday] != 1 || a.TZOffsets[-8*3600] != 1 {
+		t.Errorf("expected local time of the author, got %+v", a)
+	}
+}
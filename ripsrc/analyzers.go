@@ -0,0 +1,124 @@
+package ripsrc
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+)
+
+// Analyzer is a custom analysis run for each file changed by a commit, for example to detect SQL migrations or lint proto files. Add it using RegisterAnalyzer and select it in Opts.Analyzers.
+// Analyze is called from a single goroutine, but the same Analyzer could be used by multiple Ripsrc instances running in parallel.
+type Analyzer interface {
+	// Name is used to select the analyzer in Opts.Analyzers and as the key in BlameResult.Analyses.
+	Name() string
+	// Languages are values of BlameResult.Language the analyzer runs for. Empty runs it for all languages.
+	Languages() []string
+	// Analyze returns the value set in BlameResult.Analyses, nil to not set any. Returned error stops processing.
+	Analyze(file AnalyzerFile) (interface{}, error)
+}
+
+// AnalyzerFile is the content and blame of a file after the commit, passed to Analyzer. Only files that are not skipped are analyzed.
+type AnalyzerFile struct {
+	Commit   Commit
+	Filename string
+	Language string
+	// Lines are lines of the file with the commit that added each of them.
+	Lines []AnalyzerLine
+	// Result is the result for the file before analyzers run. Shared with the caller, do not modify.
+	Result *BlameResult
+}
+
+// AnalyzerLine is a line of a file passed to Analyzer.
+type AnalyzerLine struct {
+	// Content is the line without the newline.
+	Content []byte
+	// SHA is the commit that added the line.
+	SHA string
+}
+
+var analyzersMu sync.RWMutex
+var analyzers = map[string]Analyzer{}
+
+// RegisterAnalyzer makes analyzer available for selection in Opts.Analyzers. Panics if an analyzer with the same name is already registered.
+func RegisterAnalyzer(a Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	if _, ok := analyzers[a.Name()]; ok {
+		panic(fmt.Errorf("analyzer %v is already registered", a.Name()))
+	}
+	analyzers[a.Name()] = a
+}
+
+// analyzersByName returns registered analyzers in the order of names.
+func analyzersByName(names []string) (res []Analyzer, _ error) {
+	analyzersMu.RLock()
+	defer analyzersMu.RUnlock()
+	for _, name := range names {
+		a, ok := analyzers[name]
+		if !ok {
+			var all []string
+			for k := range analyzers {
+				all = append(all, k)
+			}
+			sort.Strings(all)
+			return nil, fmt.Errorf("unknown analyzer %q, registered: %v", name, all)
+		}
+		res = append(res, a)
+	}
+	return
+}
+
+// runAnalyzers sets Analyses of r using analyzers selected in opts. Names are checked in Validate.
+func (s *Ripsrc) runAnalyzers(bl *incblame.Blame, r *BlameResult) error {
+	if len(s.opts.Analyzers) == 0 {
+		return nil
+	}
+	list, err := analyzersByName(s.opts.Analyzers)
+	if err != nil {
+		return err
+	}
+	var file *AnalyzerFile
+	for _, a := range list {
+		if !analyzerLanguage(a, r.Language) {
+			continue
+		}
+		if file == nil {
+			file = &AnalyzerFile{}
+			file.Commit = r.Commit
+			file.Filename = r.Filename
+			file.Language = r.Language
+			file.Result = r
+			file.Lines = make([]AnalyzerLine, len(bl.Lines))
+			for i, l := range bl.Lines {
+				file.Lines[i] = AnalyzerLine{Content: l.Line, SHA: l.Commit}
+			}
+		}
+		v, err := a.Analyze(*file)
+		if err != nil {
+			return fmt.Errorf("analyzer %v failed, commit: %v file: %v err: %v", a.Name(), r.Commit.SHA, r.Filename, err)
+		}
+		if v == nil {
+			continue
+		}
+		if r.Analyses == nil {
+			r.Analyses = map[string]interface{}{}
+		}
+		r.Analyses[a.Name()] = v
+	}
+	return nil
+}
+
+func analyzerLanguage(a Analyzer, language string) bool {
+	langs := a.Languages()
+	if len(langs) == 0 {
+		return true
+	}
+	for _, l := range langs {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
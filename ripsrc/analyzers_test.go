@@ -0,0 +1,92 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+type todoAnalyzer struct{}
+
+func (todoAnalyzer) Name() string {
+	return "test-todo"
+}
+
+func (todoAnalyzer) Languages() []string {
+	return []string{"Go"}
+}
+
+func (todoAnalyzer) Analyze(f AnalyzerFile) (interface{}, error) {
+	var res []string
+	for _, l := range f.Lines {
+		if bytes.Contains(l.Content, []byte("TODO")) {
+			res = append(res, l.SHA)
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+type failingAnalyzer struct{}
+
+func (failingAnalyzer) Name() string {
+	return "test-failing"
+}
+
+func (failingAnalyzer) Languages() []string {
+	return nil
+}
+
+func (failingAnalyzer) Analyze(f AnalyzerFile) (interface{}, error) {
+	return nil, errors.New("failed")
+}
+
+func init() {
+	RegisterAnalyzer(todoAnalyzer{})
+	RegisterAnalyzer(failingAnalyzer{})
+}
+
+func TestAnalyzers(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.go", "package a\n\n// TODO: a\n").Write("b.txt", "TODO\n").Commit("c1")
+	r.Write("a.go", "package a\n\n// TODO: a\nfunc A() {}\n").Commit("c2")
+
+	res, err := New(Opts{RepoDir: r.Dir(), Analyzers: []string{"test-todo"}}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, b := range res {
+		v := b.Analyses["test-todo"]
+		if b.Filename != "a.go" {
+			if v != nil {
+				t.Errorf("analyzer should only run for Go files, got %v for %v", v, b.Filename)
+			}
+			continue
+		}
+		shas, ok := v.([]string)
+		if !ok || len(shas) != 1 || shas[0] != c1 {
+			t.Errorf("unexpected analysis %v for %v in %v", v, b.Filename, b.Commit.Message)
+		}
+		found++
+	}
+	if found != 2 {
+		t.Fatalf("wanted a.go in 2 commits, got %v", found)
+	}
+
+	_, err = New(Opts{RepoDir: r.Dir(), Analyzers: []string{"test-failing"}}).CodeSlice(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "analyzer test-failing failed") {
+		t.Fatalf("expected analyzer error, got %v", err)
+	}
+	err = Opts{RepoDir: r.Dir(), Analyzers: []string{"missing"}}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown analyzer") {
+		t.Fatalf("expected unknown analyzer error, got %v", err)
+	}
+}
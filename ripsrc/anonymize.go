@@ -0,0 +1,197 @@
+package ripsrc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/blamediff"
+)
+
+// AnonymizeOpts configures pseudonymization of results, see Opts.Anonymize.
+type AnonymizeOpts struct {
+	// Key is the secret HMAC key used to hash names and emails. Empty disables anonymization.
+	// The same key gives the same pseudonyms across runs and repos, so that results could still be joined by author. Keep it secret, anyone with the key could check if a known email is in the results.
+	Key []byte
+	// DropMessages removes commit and tag messages and commit trailers from results.
+	DropMessages bool
+	// DropContent removes line content from results, such as BlameDiffLine.Content and BlameResult.Patch.
+	DropContent bool
+}
+
+// anonymizedDomain is used for hashed emails, so that they are still valid emails. The .invalid tld is reserved and never resolves.
+const anonymizedDomain = "@anonymized.invalid"
+
+// anonymizer replaces names, emails, messages and content in results according to AnonymizeOpts. Nil anonymizer returns values unchanged.
+type anonymizer struct {
+	opts AnonymizeOpts
+}
+
+func newAnonymizer(opts AnonymizeOpts) *anonymizer {
+	if len(opts.Key) == 0 {
+		return nil
+	}
+	s := &anonymizer{}
+	s.opts = opts
+	return s
+}
+
+// hash returns keyed hash of the value. Kind is included, so that name and email with the same text get different hashes.
+func (s *anonymizer) hash(kind, v string) string {
+	h := hmac.New(sha256.New, s.opts.Key)
+	h.Write([]byte(kind + ":" + v))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// name returns pseudonym of a person name or login.
+func (s *anonymizer) name(v string) string {
+	if s == nil || v == "" {
+		return v
+	}
+	return "anon-" + s.hash("name", strings.TrimSpace(v))
+}
+
+// email returns pseudonym of an email. Emails are compared case insensitively, so they are lowercased before hashing.
+func (s *anonymizer) email(v string) string {
+	if s == nil || v == "" {
+		return v
+	}
+	return s.hash("email", strings.ToLower(strings.TrimSpace(v))) + anonymizedDomain
+}
+
+// identity returns pseudonym of a value in Name <email> format, used by trailers and signatures. Values with only email or name are also supported.
+func (s *anonymizer) identity(v string) string {
+	if s == nil || v == "" {
+		return v
+	}
+	if i := strings.LastIndex(v, "<"); i != -1 && strings.HasSuffix(v, ">") {
+		email := s.email(v[i+1 : len(v)-1])
+		name := strings.TrimSpace(v[:i])
+		if name == "" {
+			return "<" + email + ">"
+		}
+		return s.name(name) + " <" + email + ">"
+	}
+	if strings.Contains(v, "@") && !strings.Contains(v, " ") {
+		return s.email(v)
+	}
+	return s.name(v)
+}
+
+// identityTrailers are trailer keys with Name <email> values, compared case insensitively.
+var identityTrailers = []string{"Signed-off-by", "Co-authored-by", "Reviewed-by", "Acked-by", "Tested-by", "Reported-by", "Suggested-by", "Helped-by", "Cc"}
+
+func isIdentityTrailer(key string) bool {
+	for _, k := range identityTrailers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// commit returns commit with anonymized authors, committers and signer. Messages and trailers are removed with DropMessages, otherwise trailers with people are anonymized.
+func (s *anonymizer) commit(c Commit) Commit {
+	if s == nil {
+		return c
+	}
+	c.AuthorName = s.name(c.AuthorName)
+	c.AuthorEmail = s.email(c.AuthorEmail)
+	c.CommitterName = s.name(c.CommitterName)
+	c.CommitterEmail = s.email(c.CommitterEmail)
+	c.Signature.Signer = s.identity(c.Signature.Signer)
+	if s.opts.DropMessages {
+		c.Message = ""
+		c.Trailers = nil
+		return c
+	}
+	if len(c.Trailers) != 0 {
+		trailers := make([]Trailer, len(c.Trailers))
+		for i, t := range c.Trailers {
+			if isIdentityTrailer(t.Key) || strings.Contains(t.Value, "@") {
+				t.Value = s.identity(t.Value)
+			}
+			trailers[i] = t
+		}
+		c.Trailers = trailers
+	}
+	return c
+}
+
+// tag returns tag with anonymized tagger. Message is removed with DropMessages.
+func (s *anonymizer) tag(t Tag) Tag {
+	if s == nil {
+		return t
+	}
+	t.TaggerName = s.name(t.TaggerName)
+	t.TaggerEmail = s.email(t.TaggerEmail)
+	if s.opts.DropMessages {
+		t.Message = ""
+	}
+	return t
+}
+
+// owners returns anonymized CODEOWNERS entries. Users and teams (@name) and emails are hashed.
+func (s *anonymizer) owners(owners []string) []string {
+	if s == nil || len(owners) == 0 {
+		return owners
+	}
+	res := make([]string, len(owners))
+	for i, o := range owners {
+		if strings.HasPrefix(o, "@") {
+			res[i] = "@" + s.name(o[1:])
+		} else {
+			res[i] = s.email(o)
+		}
+	}
+	return res
+}
+
+// hosting returns hosting metadata with anonymized approval logins.
+func (s *anonymizer) hosting(h *CommitHosting) *CommitHosting {
+	if s == nil || h == nil || len(h.Approvals) == 0 {
+		return h
+	}
+	res := *h
+	res.Approvals = make([]string, len(h.Approvals))
+	for i, a := range h.Approvals {
+		res.Approvals[i] = s.name(a)
+	}
+	return &res
+}
+
+// patch returns the patch, which is removed with DropContent.
+func (s *anonymizer) patch(p string) string {
+	if s == nil || !s.opts.DropContent {
+		return p
+	}
+	return ""
+}
+
+// blameDiffFile returns file with anonymized line owners. Line content is removed with DropContent.
+func (s *anonymizer) blameDiffFile(f BlameDiffFile) BlameDiffFile {
+	if s == nil {
+		return f
+	}
+	owner := func(o *blamediff.Owner) *blamediff.Owner {
+		if o == nil {
+			return nil
+		}
+		res := *o
+		res.Name = s.name(o.Name)
+		res.Email = s.email(o.Email)
+		return &res
+	}
+	lines := make([]BlameDiffLine, len(f.Lines))
+	for i, l := range f.Lines {
+		l.Before = owner(l.Before)
+		l.After = owner(l.After)
+		if s.opts.DropContent {
+			l.Content = ""
+		}
+		lines[i] = l
+	}
+	f.Lines = lines
+	return f
+}
@@ -0,0 +1,81 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestAnonymize(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Author("Jane Doe", "Jane@example.com").Write("a.go", "package a\n\nfunc secret() {}\n").Commit("add secret project\n\nCo-authored-by: John Roe <john@example.com>")
+	r.Tag("v1", "release by jane")
+	r.Author("John Roe", "john@example.com").Write("a.go", "package a\n\nfunc secret() {}\n\nfunc other() {}\n").Commit("second")
+
+	anon := newAnonymizer(AnonymizeOpts{Key: []byte("key")})
+	jane := anon.email("jane@example.com")
+	if jane != anon.email("JANE@example.com") || jane == newAnonymizer(AnonymizeOpts{Key: []byte("other")}).email("jane@example.com") || !strings.HasSuffix(jane, anonymizedDomain) {
+		t.Fatalf("unexpected pseudonym %v", jane)
+	}
+
+	assertNoPersonalData := func(label string, v interface{}, content ...string) {
+		t.Helper()
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range append([]string{"Jane", "jane", "John", "john", "example.com"}, content...) {
+			if strings.Contains(string(b), s) {
+				t.Errorf("%v: %q found in output\n%s", label, s, b)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	rip := New(Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir(), CommitTrailers: true, Anonymize: AnonymizeOpts{Key: []byte("key")}})
+	res, err := rip.CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Commit.AuthorEmail != jane || res[0].Commit.Message == "" || res[0].Lines[0].Email != jane || res[1].Lines[4].Email != anon.email("john@example.com") {
+		t.Fatalf("unexpected results %+v", res)
+	}
+	if tr := res[0].Commit.Trailer("Co-authored-by"); len(tr) != 1 || tr[0] != anon.name("John Roe")+" <"+anon.email("john@example.com")+">" {
+		t.Errorf("unexpected trailers %v", tr)
+	}
+	assertNoPersonalData("code", res)
+
+	rip = New(Opts{RepoDir: r.Dir(), Anonymize: AnonymizeOpts{Key: []byte("key"), DropMessages: true, DropContent: true}})
+	var commits []CommitInfo
+	err = rip.Commits(ctx, func(c CommitInfo) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].SHA != c1 || commits[0].AuthorEmail != jane {
+		t.Fatalf("unexpected commits %+v", commits)
+	}
+	assertNoPersonalData("commits", commits, "secret", "second")
+	tags, err := rip.TagsSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].TaggerEmail == "" {
+		t.Fatalf("unexpected tags %+v", tags)
+	}
+	assertNoPersonalData("tags", tags, "release")
+	diff, err := rip.BlameDiffSlice(ctx, c1, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 || len(diff[0].Lines) == 0 {
+		t.Fatalf("unexpected blame diff %+v", diff)
+	}
+	assertNoPersonalData("blame diff", diff, "other")
+}
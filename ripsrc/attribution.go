@@ -0,0 +1,89 @@
+package ripsrc
+
+import (
+	"context"
+
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+)
+
+// Attribution selects the commit blame lines are attributed to. See Opts.Attribution.
+type Attribution string
+
+const (
+	// AttributionCommit attributes lines to the commit that changed them. This is the default.
+	AttributionCommit = Attribution("")
+	// AttributionMerge attributes lines to the commit that landed them on the default branch, which is the merge commit for commits of merged branches, and the commit itself for commits made directly on the default branch.
+	// Commits that are not reachable from the default branch are attributed to themselves.
+	AttributionMerge = Attribution("merge")
+)
+
+func (s Attribution) valid() bool {
+	switch s {
+	case AttributionCommit, AttributionMerge:
+		return true
+	}
+	return false
+}
+
+// getLandedIn returns map[commit]landing commit for commits attributed to a different commit with Opts.Attribution. Opts.LandedIn takes precedence over merges found in the commit graph.
+func (s *Ripsrc) getLandedIn(ctx context.Context) (map[string]string, error) {
+	b, err := s.getDefaultBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := landedIn(s.commitGraph, b.Commit)
+	for c, l := range s.opts.LandedIn {
+		if _, ok := s.commitMeta[l]; !ok {
+			s.opts.Logger.Warn("LandedIn commit not found, using commit graph", "commit", c, "landed_in", l)
+			continue
+		}
+		res[c] = l
+	}
+	for c, l := range res {
+		if c == l {
+			delete(res, c)
+		}
+	}
+	return res, nil
+}
+
+// landedIn returns map[commit]merge for commits merged into the first parent chain of tip. Commits on the chain itself are not included.
+func landedIn(g *parentsgraph.Graph, tip string) map[string]string {
+	res := map[string]string{}
+	if tip == "" {
+		return res
+	}
+	var chain []string
+	onChain := map[string]bool{}
+	for c := tip; c != "" && !onChain[c]; {
+		chain = append(chain, c)
+		onChain[c] = true
+		parents := g.Parents[c]
+		if len(parents) == 0 {
+			break
+		}
+		c = parents[0]
+	}
+	// from the oldest merge, so that commits merged by older merges are already attributed when reached from newer ones
+	for i := len(chain) - 1; i >= 0; i-- {
+		merge := chain[i]
+		parents := g.Parents[merge]
+		if len(parents) < 2 {
+			continue
+		}
+		queue := append([]string{}, parents[1:]...)
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			if onChain[c] {
+				continue
+			}
+			if _, ok := res[c]; ok {
+				continue
+			}
+			res[c] = merge
+			queue = append(queue, g.Parents[c]...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestAttributionMerge(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("feature")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+	c3 := r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	r.Checkout("master")
+	r.Author("maintainer", "maintainer@example.com")
+	m := r.Merge("merge feature", "feature")
+	c4 := r.Write("a.txt", "a\nb\nc\nd\n").Commit("c4")
+
+	lastLines := func(opts Opts) []*BlameLine {
+		t.Helper()
+		opts.RepoDir = r.Dir()
+		it := New(opts).BlameIter(context.Background())
+		defer it.Close()
+		var res []*BlameLine
+		for it.Next() {
+			v := it.Value()
+			if v.Commit.SHA == c4 {
+				res = v.Lines
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	type want struct {
+		sha      string
+		original string
+	}
+	check := func(label string, got []*BlameLine, wants []want) {
+		t.Helper()
+		if len(got) != len(wants) {
+			t.Fatalf("%v: wanted %v lines, got %v", label, len(wants), len(got))
+		}
+		for i, w := range wants {
+			if got[i].SHA != w.sha || got[i].OriginalSHA != w.original {
+				t.Errorf("%v: line %v wanted %v from %q, got %v from %q", label, i, w.sha, w.original, got[i].SHA, got[i].OriginalSHA)
+			}
+		}
+	}
+
+	check("commit", lastLines(Opts{}), []want{{c1, ""}, {c2, ""}, {c3, ""}, {c4, ""}})
+
+	lines := lastLines(Opts{Attribution: AttributionMerge})
+	check("merge", lines, []want{{c1, ""}, {m, c2}, {m, c3}, {c4, ""}})
+	if lines[1].Name != "maintainer" {
+		t.Errorf("expected author of the merge, got %v", lines[1].Name)
+	}
+
+	// provided mapping takes precedence, unknown commits are ignored
+	lines = lastLines(Opts{Attribution: AttributionMerge, LandedIn: map[string]string{c2: c4, c3: "unknown"}})
+	check("mapping", lines, []want{{c1, ""}, {c4, c2}, {m, c3}, {c4, ""}})
+}
+
+func TestLandedIn(t *testing.T) {
+	// a-b-m1-m2 on the default branch, c merged in m1, d branched from c merged in m2
+	g := parentsgraph.NewFromMap(map[string][]string{
+		"a":  nil,
+		"b":  {"a"},
+		"c":  {"a"},
+		"m1": {"b", "c"},
+		"d":  {"c"},
+		"m2": {"m1", "d"},
+		"e":  {"m2"},
+	})
+	got := landedIn(g, "m2")
+	want := map[string]string{"c": "m1", "d": "m2"}
+	if len(got) != len(want) || got["c"] != "m1" || got["d"] != "m2" {
+		t.Fatalf("wanted %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,54 @@
+package ripsrc
+
+// authorFilter matches commit authors against Opts.ExcludeAuthors.
+type authorFilter struct {
+	exclude []namePattern
+}
+
+func newAuthorFilter(exclude []string) (*authorFilter, error) {
+	s := &authorFilter{}
+	var err error
+	s.exclude, err = parseNamePatterns("author", exclude)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// excluded returns true if author name or email matches any pattern.
+func (s *authorFilter) excluded(c Commit) bool {
+	for _, p := range s.exclude {
+		if p.match(c.AuthorName) || p.match(c.AuthorEmail) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorFilter returns filter for Opts.ExcludeAuthors. Patterns are checked in Validate.
+func (s *Ripsrc) authorFilter() *authorFilter {
+	res, err := newAuthorFilter(s.opts.ExcludeAuthors)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// excludeAuthors sets ExcludedAuthor of commits in commitMeta.
+func (s *Ripsrc) excludeAuthors() {
+	if len(s.opts.ExcludeAuthors) == 0 {
+		return
+	}
+	f := s.authorFilter()
+	for sha, c := range s.commitMeta {
+		if f.excluded(c) {
+			c.ExcludedAuthor = true
+			s.commitMeta[sha] = c
+		}
+	}
+}
+
+// skipCommit returns true for commits of excluded authors that are not returned, see Opts.FlagExcludedAuthors.
+func (s *Ripsrc) skipCommit(c Commit) bool {
+	return c.ExcludedAuthor && !s.opts.FlagExcludedAuthors
+}
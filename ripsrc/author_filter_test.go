@@ -0,0 +1,78 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestExcludeAuthors(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	r.Author("dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+	r.Author("renovate", "bot@renovateapp.com")
+	c3 := r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	r.Author("user", "user@example.com")
+	c4 := r.Write("a.txt", "a\nb\nc\nd\n").Commit("c4")
+
+	exclude := []string{"re:\\[bot\\]$", "*@renovateapp.com"}
+
+	type res struct {
+		sha      string
+		excluded bool
+	}
+	run := func(opts Opts) (got []res, lastLines []*BlameLine) {
+		t.Helper()
+		opts.RepoDir = r.Dir()
+		it := New(opts).CommitIter(context.Background())
+		defer it.Close()
+		for it.Next() {
+			c := it.Value()
+			got = append(got, res{c.SHA, c.ExcludedAuthor})
+			for b := range c.Blames {
+				lastLines = b.Lines
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	check := func(label string, got, want []res) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%v: wanted %v, got %v", label, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v: wanted %v, got %v", label, want, got)
+			}
+		}
+	}
+
+	got, lines := run(Opts{ExcludeAuthors: exclude})
+	check("skip", got, []res{{c1, false}, {c4, false}})
+	// lines of skipped commits are still attributed to them
+	if len(lines) != 4 || lines[1].SHA != c2 || lines[2].SHA != c3 {
+		t.Errorf("unexpected blame of last commit %+v", lines)
+	}
+
+	got, _ = run(Opts{ExcludeAuthors: exclude, FlagExcludedAuthors: true})
+	check("flag", got, []res{{c1, false}, {c2, true}, {c3, true}, {c4, false}})
+
+	got, _ = run(Opts{ExcludeAuthors: exclude, Stages: StageCommits})
+	check("commits only", got, []res{{c1, false}, {c4, false}})
+
+	var commits []res
+	err := New(Opts{RepoDir: r.Dir(), ExcludeAuthors: exclude}).Commits(context.Background(), func(c CommitInfo) error {
+		commits = append(commits, res{c.SHA, c.ExcludedAuthor})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Commits", commits, []res{{c1, false}, {c4, false}})
+}
@@ -0,0 +1,266 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TimeBucket is the period used to group contributions in AuthorTimeSeries.
+type TimeBucket string
+
+const (
+	// TimeBucketDay groups by UTC day.
+	TimeBucketDay = TimeBucket("day")
+	// TimeBucketWeek groups by UTC week starting on Monday.
+	TimeBucketWeek = TimeBucket("week")
+	// TimeBucketMonth groups by UTC month.
+	TimeBucketMonth = TimeBucket("month")
+)
+
+// Start returns the start of the bucket that includes t.
+func (s TimeBucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch s {
+	case TimeBucketWeek:
+		// time.Sunday is 0
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case TimeBucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func (s TimeBucket) valid() bool {
+	switch s {
+	case TimeBucketDay, TimeBucketWeek, TimeBucketMonth:
+		return true
+	}
+	return false
+}
+
+// AuthorPeriod is the contribution of one author in one time bucket.
+type AuthorPeriod struct {
+	AuthorEmail string
+	// AuthorName is the last name used with AuthorEmail.
+	AuthorName string
+	// Period is the start of the time bucket in UTC.
+	Period time.Time
+	// Commits is the number of non-merge commits authored in the period.
+	Commits int
+	// LinesAdded and LinesRemoved are summed from commit file stats. Merge commits are not included.
+	LinesAdded   int
+	LinesRemoved int
+	// SurvivingLines is the number of lines authored in the period that are still present in the last returned blame of each file.
+	// When processing HEAD only, this is the number of lines at HEAD.
+	SurvivingLines int
+	// FilesTouched is the number of distinct files changed in the period.
+	FilesTouched int
+	// ActiveDays is the number of distinct UTC days with at least one commit.
+	ActiveDays int
+}
+
+type authorPeriodKey struct {
+	email  string
+	period time.Time
+}
+
+type authorPeriodState struct {
+	AuthorPeriod
+	files map[string]bool
+	days  map[time.Time]bool
+}
+
+// AuthorTimeSeries aggregates commits and blames returned from CodeByCommit into contribution per author and time bucket.
+// Add every commit with AddCommit and its blames with AddBlame in the order returned, then call Result.
+type AuthorTimeSeries struct {
+	// CommitDate selects the commit date used for time buckets. Should match Opts.CommitDate used to get the blames, since blame line dates are bucketed as is.
+	CommitDate CommitDate
+
+	bucket  TimeBucket
+	periods map[authorPeriodKey]*authorPeriodState
+	names   map[string]string
+	// surviving is the number of lines by author period in the last blame of each file, map[file]map[key]lines
+	surviving map[string]map[authorPeriodKey]int
+}
+
+// NewAuthorTimeSeries creates aggregation using passed time bucket. Empty bucket defaults to TimeBucketWeek.
+func NewAuthorTimeSeries(bucket TimeBucket) *AuthorTimeSeries {
+	if bucket == "" {
+		bucket = TimeBucketWeek
+	}
+	s := &AuthorTimeSeries{}
+	s.bucket = bucket
+	s.periods = map[authorPeriodKey]*authorPeriodState{}
+	s.names = map[string]string{}
+	s.surviving = map[string]map[authorPeriodKey]int{}
+	return s
+}
+
+func (s *AuthorTimeSeries) period(email string, t time.Time) *authorPeriodState {
+	k := authorPeriodKey{email: email, period: s.bucket.Start(t)}
+	p, ok := s.periods[k]
+	if !ok {
+		p = &authorPeriodState{}
+		p.AuthorEmail = email
+		p.Period = k.period
+		p.files = map[string]bool{}
+		p.days = map[time.Time]bool{}
+		s.periods[k] = p
+	}
+	return p
+}
+
+// AddCommit adds commit stats. Merge commits only update files removed by renames, their changes are attributed to the original commits.
+func (s *AuthorTimeSeries) AddCommit(c Commit) {
+	for _, f := range c.Files {
+		if f.Renamed {
+			delete(s.surviving, f.RenamedFrom)
+		}
+	}
+	if len(c.Parents) > 1 {
+		return
+	}
+	if c.AuthorName != "" {
+		s.names[c.AuthorEmail] = c.AuthorName
+	}
+	date := s.CommitDate.Of(c)
+	p := s.period(c.AuthorEmail, date)
+	p.Commits++
+	p.days[TimeBucketDay.Start(date)] = true
+	for name, f := range c.Files {
+		p.LinesAdded += f.Additions
+		p.LinesRemoved += f.Deletions
+		p.files[name] = true
+	}
+}
+
+// AddBlame replaces surviving lines of the file with lines from the blame.
+func (s *AuthorTimeSeries) AddBlame(r BlameResult) {
+	if r.Status == GitFileCommitStatusRemoved {
+		delete(s.surviving, r.Filename)
+		return
+	}
+	counts := map[authorPeriodKey]int{}
+	for _, l := range r.Lines {
+		counts[authorPeriodKey{email: l.Email, period: s.bucket.Start(l.Date)}]++
+	}
+	s.surviving[r.Filename] = counts
+}
+
+// Result returns aggregated periods sorted by author email and period.
+func (s *AuthorTimeSeries) Result() (res []AuthorPeriod) {
+	surviving := map[authorPeriodKey]int{}
+	for _, counts := range s.surviving {
+		for k, n := range counts {
+			surviving[k] += n
+		}
+	}
+	for k, n := range surviving {
+		if _, ok := s.periods[k]; !ok {
+			// lines from commits not processed in this run, for example before CommitFromIncl
+			s.period(k.email, k.period)
+		}
+		s.periods[k].SurvivingLines = n
+	}
+	for _, p := range s.periods {
+		r := p.AuthorPeriod
+		r.AuthorName = s.names[r.AuthorEmail]
+		r.FilesTouched = len(p.files)
+		r.ActiveDays = len(p.days)
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a := res[i]
+		b := res[j]
+		if a.AuthorEmail != b.AuthorEmail {
+			return a.AuthorEmail < b.AuthorEmail
+		}
+		return a.Period.Before(b.Period)
+	})
+	return
+}
+
+// AuthorTimeSeries processes the repo using CodeByCommit and returns contribution per author and time bucket. Uses and updates checkpoints the same way as CodeByCommit.
+// Results are sent after all commits are processed, since surviving lines are only known at the end.
+func (s *Ripsrc) AuthorTimeSeries(ctx context.Context, bucket TimeBucket, res chan AuthorPeriod) error {
+	defer close(res)
+	if bucket != "" && !bucket.valid() {
+		return fmt.Errorf("invalid time bucket: %q", bucket)
+	}
+	agg := NewAuthorTimeSeries(bucket)
+	agg.CommitDate = s.opts.CommitDate
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			agg.AddCommit(c.Commit)
+			for b := range c.Blames {
+				agg.AddBlame(b)
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+	for _, r := range agg.Result() {
+		select {
+		case res <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) AuthorTimeSeriesSlice(ctx context.Context, bucket TimeBucket) (res []AuthorPeriod, _ error) {
+	resChan := make(chan AuthorPeriod)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.AuthorTimeSeries(ctx, bucket, resChan)
+	<-done
+	return res, err
+}
+
+// WriteAuthorTimeSeriesCSV writes periods as csv table with a header row.
+func WriteAuthorTimeSeriesCSV(wr io.Writer, periods []AuthorPeriod) error {
+	w := csv.NewWriter(wr)
+	err := w.Write([]string{"author_email", "author_name", "period", "commits", "lines_added", "lines_removed", "surviving_lines", "files_touched", "active_days"})
+	if err != nil {
+		return err
+	}
+	for _, p := range periods {
+		err := w.Write([]string{
+			p.AuthorEmail,
+			p.AuthorName,
+			p.Period.Format("2006-01-02"),
+			strconv.Itoa(p.Commits),
+			strconv.Itoa(p.LinesAdded),
+			strconv.Itoa(p.LinesRemoved),
+			strconv.Itoa(p.SurvivingLines),
+			strconv.Itoa(p.FilesTouched),
+			strconv.Itoa(p.ActiveDays),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
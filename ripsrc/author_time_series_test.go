@@ -0,0 +1,96 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestTimeBucketStart(t *testing.T) {
+	// Thursday
+	d := time.Date(2019, 1, 3, 15, 4, 5, 0, time.UTC)
+	cases := []struct {
+		bucket TimeBucket
+		want   time.Time
+	}{
+		{TimeBucketDay, time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{TimeBucketWeek, time.Date(2018, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{TimeBucketMonth, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		got := c.bucket.Start(d)
+		if !got.Equal(c.want) {
+			t.Errorf("%v: wanted %v got %v", c.bucket, c.want, got)
+		}
+	}
+}
+
+func TestAuthorTimeSeriesAggregate(t *testing.T) {
+	d1 := time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC)
+	d2 := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	d3 := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	agg := NewAuthorTimeSeries(TimeBucketMonth)
+	agg.AddCommit(Commit{SHA: "c1", AuthorName: "A", AuthorEmail: "a", Date: d1, Files: map[string]*CommitFile{
+		"a.txt": {Additions: 3},
+		"b.txt": {Additions: 1},
+	}})
+	agg.AddBlame(BlameResult{Filename: "a.txt", Lines: []*BlameLine{{Email: "a", Date: d1}, {Email: "a", Date: d1}, {Email: "a", Date: d1}}})
+	agg.AddBlame(BlameResult{Filename: "b.txt", Lines: []*BlameLine{{Email: "a", Date: d1}}})
+	agg.AddCommit(Commit{SHA: "c2", AuthorName: "A", AuthorEmail: "a", Date: d2, Files: map[string]*CommitFile{
+		"a.txt": {Additions: 1, Deletions: 1},
+	}})
+	agg.AddBlame(BlameResult{Filename: "a.txt", Lines: []*BlameLine{{Email: "a", Date: d1}, {Email: "a", Date: d2}, {Email: "a", Date: d1}}})
+	agg.AddCommit(Commit{SHA: "c3", AuthorName: "B", AuthorEmail: "b", Date: d3, Files: map[string]*CommitFile{
+		"b.txt": {Deletions: 1, Status: GitFileCommitStatusRemoved},
+		"a.txt": {Additions: 1, Deletions: 1},
+	}})
+	agg.AddBlame(BlameResult{Filename: "b.txt", Status: GitFileCommitStatusRemoved})
+	agg.AddBlame(BlameResult{Filename: "a.txt", Lines: []*BlameLine{{Email: "b", Date: d3}, {Email: "a", Date: d2}, {Email: "a", Date: d1}}})
+
+	jan := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+	want := []AuthorPeriod{
+		{AuthorEmail: "a", AuthorName: "A", Period: jan, Commits: 2, LinesAdded: 5, LinesRemoved: 1, SurvivingLines: 2, FilesTouched: 2, ActiveDays: 2},
+		{AuthorEmail: "b", AuthorName: "B", Period: feb, Commits: 1, LinesAdded: 1, LinesRemoved: 2, SurvivingLines: 1, FilesTouched: 2, ActiveDays: 1},
+	}
+	got := agg.Result()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteAuthorTimeSeriesCSV(buf, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[1] != "a,A,2019-01-01,2,5,1,2,2,2" {
+		t.Fatalf("unexpected csv\n%v", buf.String())
+	}
+}
+
+func TestAuthorTimeSeries(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Author("A", "a@example.com").Write("a.txt", "1\n2\n").Commit("c1")
+	r.Author("B", "b@example.com").Write("a.txt", "1\nb\n").Commit("c2")
+
+	got, err := New(Opts{RepoDir: r.Dir()}).AuthorTimeSeriesSlice(context.Background(), TimeBucketDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []AuthorPeriod{
+		{AuthorEmail: "a@example.com", AuthorName: "A", Period: day, Commits: 1, LinesAdded: 2, SurvivingLines: 1, FilesTouched: 1, ActiveDays: 1},
+		{AuthorEmail: "b@example.com", AuthorName: "B", Period: day, Commits: 1, LinesAdded: 1, LinesRemoved: 1, SurvivingLines: 1, FilesTouched: 1, ActiveDays: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
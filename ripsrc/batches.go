@@ -0,0 +1,68 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultBatchSize is the number of commits in batches of CodeByBatch when size is 0.
+const DefaultBatchSize = 100
+
+// CommitBatch is a group of consecutive commits returned from CodeByBatch with blames of files they changed.
+type CommitBatch struct {
+	// Index is the position of the batch in the run, starting from 0.
+	Index int
+	// Commits are in the same order as returned by CodeByCommit. Blames channel of commits is nil, use Blames of the batch.
+	Commits []CommitCode
+	// Blames are results of files changed by Commits, in commit order. Use BlameResult.Commit.SHA to find the commit.
+	Blames []BlameResult
+}
+
+// Last returns the sha of the last commit in the batch. Store it with data of the batch as the downstream checkpoint.
+func (s CommitBatch) Last() string {
+	return s.Commits[len(s.Commits)-1].SHA
+}
+
+// CodeByBatch calls cb with results of CodeByCommit grouped into batches of size commits, so that integrators could write each batch in a single transaction and checkpoint downstream systems at batch boundaries.
+// The last batch could be smaller. Default size is DefaultBatchSize. Memory is bounded by one batch, processing waits while cb is running.
+// Stops and returns the error if cb returns an error. The ripsrc checkpoint is written when the run finishes, or with CheckpointEvery for intermediate ones, so commits of batches after the last checkpoint are returned again by the next run. Skip batches that were already stored by comparing Last.
+// The last batch is passed to cb after the checkpoint is written, since the end of results is only known when CodeByCommit returns, same as OutputSink.OnFinish. If cb fails for it, its commits are not returned by the next run.
+func (s *Ripsrc) CodeByBatch(ctx context.Context, size int, cb func(CommitBatch) error) error {
+	if size < 0 {
+		return errors.New("batch size must not be negative")
+	}
+	if size == 0 {
+		size = DefaultBatchSize
+	}
+	it := s.CommitIter(ctx)
+	defer it.Close()
+
+	batch := CommitBatch{}
+	flush := func() error {
+		if len(batch.Commits) == 0 {
+			return nil
+		}
+		err := cb(batch)
+		batch = CommitBatch{Index: batch.Index + 1}
+		return err
+	}
+	for it.Next() {
+		c := it.Value()
+		for b := range c.Blames {
+			batch.Blames = append(batch.Blames, b)
+		}
+		c.Blames = nil
+		batch.Commits = append(batch.Commits, c)
+		if len(batch.Commits) < size {
+			continue
+		}
+		err := flush()
+		if err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
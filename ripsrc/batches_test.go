@@ -0,0 +1,70 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCodeByBatch(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	var shas []string
+	shas = append(shas, r.Write("a.txt", "a\n").Write("b.txt", "b\n").Commit("c1"))
+	shas = append(shas, r.Write("a.txt", "a2\n").Commit("c2"))
+	shas = append(shas, r.Write("b.txt", "b2\n").Commit("c3"))
+	shas = append(shas, r.Write("a.txt", "a3\n").Commit("c4"))
+	shas = append(shas, r.Write("c.txt", "c\n").Commit("c5"))
+
+	var batches []CommitBatch
+	err := New(Opts{RepoDir: r.Dir()}).CodeByBatch(context.Background(), 2, func(b CommitBatch) error {
+		batches = append(batches, b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 {
+		t.Fatalf("wanted 3 batches, got %v", len(batches))
+	}
+	wantBlames := []int{3, 2, 1}
+	i := 0
+	for bi, b := range batches {
+		if b.Index != bi {
+			t.Errorf("wanted index %v, got %v", bi, b.Index)
+		}
+		for _, c := range b.Commits {
+			if c.SHA != shas[i] || c.Blames != nil {
+				t.Errorf("batch %v: unexpected commit %v, wanted %v", bi, c.SHA, shas[i])
+			}
+			i++
+		}
+		if b.Last() != shas[i-1] {
+			t.Errorf("batch %v: wanted last %v, got %v", bi, shas[i-1], b.Last())
+		}
+		if len(b.Blames) != wantBlames[bi] {
+			t.Errorf("batch %v: wanted %v blames, got %v", bi, wantBlames[bi], len(b.Blames))
+		}
+		for _, bl := range b.Blames {
+			found := false
+			for _, c := range b.Commits {
+				found = found || c.SHA == bl.Commit.SHA
+			}
+			if !found {
+				t.Errorf("batch %v: blame of commit %v outside of the batch", bi, bl.Commit.SHA)
+			}
+		}
+	}
+
+	calls := 0
+	wantErr := errors.New("downstream failed")
+	err = New(Opts{RepoDir: r.Dir()}).CodeByBatch(context.Background(), 2, func(b CommitBatch) error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr || calls != 1 {
+		t.Errorf("expected processing to stop on error, got %v after %v calls", err, calls)
+	}
+}
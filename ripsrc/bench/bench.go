@@ -0,0 +1,305 @@
+// Package bench runs reproducible end-to-end benchmarks of ripsrc on a set of reference repos, so performance regressions can be tracked across releases.
+//
+// Reference repos are cloned once into CacheDir and reused by next runs. Pin Commit in RefRepo to get comparable numbers over time.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+// RefRepo is a reference repository used for benchmarks.
+type RefRepo struct {
+	// Name is used as directory name in cache and in results.
+	Name string
+	// URL is anything accepted by git clone, including local paths.
+	URL string
+	// Commit to benchmark on. If empty, uses the head of default branch at the time of clone.
+	Commit string
+}
+
+// DefaultRepos are reference repos of varying size. Small, medium and large.
+var DefaultRepos = []RefRepo{
+	{Name: "ripsrc", URL: "https://github.com/pinpt/ripsrc.git"},
+	{Name: "cobra", URL: "https://github.com/spf13/cobra.git"},
+	{Name: "vue", URL: "https://github.com/vuejs/vue.git"},
+}
+
+type Opts struct {
+	// Logger outputs logs.
+	Logger logger.Logger
+	// CacheDir is the directory where reference repos are cloned. Default is ripsrc-bench in os temp dir.
+	CacheDir string
+	// Repos to run benchmarks on. Default is DefaultRepos.
+	Repos []RefRepo
+	// Iterations is the number of runs for each repo. Default is 1.
+	Iterations int
+	// AllBranches is passed to ripsrc.Opts.
+	AllBranches bool
+}
+
+// Result contains measurements for one run on one repo.
+type Result struct {
+	Repo      string
+	Commit    string
+	Iteration int
+	GoVersion string
+
+	// Commits is the number of processed commits.
+	Commits int
+	// Files is the number of returned file records.
+	Files int
+
+	Duration time.Duration
+	// PeakRSSBytes is the maximum resident set size of the process. This is process wide, run one repo per process for isolated numbers.
+	PeakRSSBytes int64
+	// Allocs is the number of heap allocations during the run.
+	Allocs uint64
+	// AllocBytes is the total bytes allocated during the run.
+	AllocBytes      uint64
+	AllocsPerCommit float64
+}
+
+// Run clones or updates reference repos in cache and runs benchmarks on them.
+func Run(ctx context.Context, opts Opts) (res []Result, _ error) {
+	opts = setDefaults(opts)
+	for _, repo := range opts.Repos {
+		dir, commit, err := Fetch(ctx, opts, repo)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < opts.Iterations; i++ {
+			opts.Logger.Info("bench: running", "repo", repo.Name, "commit", commit, "iteration", i)
+			r, err := runOne(ctx, opts, dir)
+			if err != nil {
+				return nil, fmt.Errorf("bench: failed on repo %v err: %v", repo.Name, err)
+			}
+			r.Repo = repo.Name
+			r.Commit = commit
+			r.Iteration = i
+			opts.Logger.Info("bench: done", "repo", repo.Name, "d", r.Duration, "commits", r.Commits, "allocs_per_commit", r.AllocsPerCommit)
+			res = append(res, r)
+		}
+	}
+	return
+}
+
+func setDefaults(opts Opts) Opts {
+	if opts.Logger == nil {
+		opts.Logger = logger.NewDefaultLogger(os.Stdout)
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = filepath.Join(os.TempDir(), "ripsrc-bench")
+	}
+	if len(opts.Repos) == 0 {
+		opts.Repos = DefaultRepos
+	}
+	if opts.Iterations == 0 {
+		opts.Iterations = 1
+	}
+	return opts
+}
+
+// Fetch clones repo into cache dir if it does not exist yet and checks out pinned commit. Returns the repo location and the commit to benchmark on.
+func Fetch(ctx context.Context, opts Opts, repo RefRepo) (dir string, commit string, _ error) {
+	opts = setDefaults(opts)
+	dir = filepath.Join(opts.CacheDir, repo.Name)
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	if os.IsNotExist(err) {
+		opts.Logger.Info("bench: cloning repo", "url", repo.URL, "dir", dir)
+		err := os.MkdirAll(opts.CacheDir, 0777)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = git(ctx, opts.CacheDir, "clone", "-q", repo.URL, repo.Name)
+		if err != nil {
+			return "", "", err
+		}
+	} else if err != nil {
+		return "", "", err
+	}
+	if repo.Commit != "" {
+		_, err := git(ctx, dir, "checkout", "-q", repo.Commit)
+		if err != nil {
+			// not in the cached clone yet
+			_, err = git(ctx, dir, "fetch", "-q", "origin")
+			if err != nil {
+				return "", "", err
+			}
+			_, err = git(ctx, dir, "checkout", "-q", repo.Commit)
+			if err != nil {
+				return "", "", err
+			}
+		}
+	}
+	commit, err = git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	return dir, commit, nil
+}
+
+func runOne(ctx context.Context, opts Opts, dir string) (res Result, _ error) {
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-bench-checkpoints-")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	ropts := ripsrc.Opts{}
+	ropts.Logger = opts.Logger
+	ropts.RepoDir = dir
+	ropts.CheckpointsDir = checkpointsDir
+	ropts.AllBranches = opts.AllBranches
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	rs := ripsrc.New(ropts)
+	commits := make(chan ripsrc.CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			res.Commits++
+			for range c.Blames {
+				res.Files++
+			}
+		}
+		done <- true
+	}()
+	err = rs.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return res, err
+	}
+
+	res.Duration = time.Since(start)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	res.Allocs = after.Mallocs - before.Mallocs
+	res.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	if res.Commits != 0 {
+		res.AllocsPerCommit = float64(res.Allocs) / float64(res.Commits)
+	}
+	res.PeakRSSBytes = peakRSS()
+	res.GoVersion = runtime.Version()
+	return res, nil
+}
+
+// WriteJSON writes results in json format, one result per line.
+func WriteJSON(wr io.Writer, results []Result) error {
+	enc := json.NewEncoder(wr)
+	for _, r := range results {
+		err := enc.Encode(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads results written by WriteJSON.
+func ReadJSON(r io.Reader) (res []Result, _ error) {
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var r Result
+		err := dec.Decode(&r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return
+}
+
+// Regression describes a metric that got worse compared to baseline.
+type Regression struct {
+	Repo   string
+	Metric string
+	Base   float64
+	Head   float64
+}
+
+func (s Regression) String() string {
+	return fmt.Sprintf("%v %v %.0f -> %.0f (%+.1f%%)", s.Repo, s.Metric, s.Base, s.Head, (s.Head/s.Base-1)*100)
+}
+
+// Compare returns metrics that increased by more than threshold (0.1 = 10%) compared to baseline, ordered by repo name. Multiple iterations for the same repo are averaged.
+func Compare(base, head []Result, threshold float64) (res []Regression) {
+	b := averages(base)
+	h := averages(head)
+	var repos []string
+	for repo := range h {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		hm := h[repo]
+		bm, ok := b[repo]
+		if !ok {
+			continue
+		}
+		for _, metric := range metricNames {
+			if bm[metric] == 0 {
+				continue
+			}
+			if hm[metric] > bm[metric]*(1+threshold) {
+				res = append(res, Regression{Repo: repo, Metric: metric, Base: bm[metric], Head: hm[metric]})
+			}
+		}
+	}
+	return
+}
+
+var metricNames = []string{"duration_ms", "peak_rss_bytes", "allocs_per_commit"}
+
+func averages(results []Result) map[string]map[string]float64 {
+	sums := map[string]map[string]float64{}
+	counts := map[string]int{}
+	for _, r := range results {
+		if sums[r.Repo] == nil {
+			sums[r.Repo] = map[string]float64{}
+		}
+		m := sums[r.Repo]
+		m["duration_ms"] += float64(r.Duration / time.Millisecond)
+		m["peak_rss_bytes"] += float64(r.PeakRSSBytes)
+		m["allocs_per_commit"] += r.AllocsPerCommit
+		counts[r.Repo]++
+	}
+	for repo, m := range sums {
+		for k := range m {
+			m[k] /= float64(counts[repo])
+		}
+	}
+	return sums
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	c := exec.CommandContext(ctx, "git", args...)
+	c.Dir = dir
+	c.Stdout = out
+	c.Stderr = stderr
+	err := c.Run()
+	if err != nil {
+		return "", fmt.Errorf("git %v failed, err: %v stderr: %s", strings.Join(args, " "), err, stderr.Bytes())
+	}
+	return strings.TrimSpace(out.String()), nil
+}
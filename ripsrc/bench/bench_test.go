@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestRunOnLocalRepo(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	cacheDir, err := ioutil.TempDir("", "ripsrc-bench-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	opts := Opts{}
+	opts.Logger = logger.NewDefaultLogger(ioutil.Discard)
+	opts.CacheDir = cacheDir
+	opts.Repos = []RefRepo{{Name: "local", URL: r.Dir()}}
+	opts.Iterations = 2
+	res, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 results, got %v", len(res))
+	}
+	for _, r := range res {
+		if r.Repo != "local" || r.Commit != c2 || r.Commits != 2 || r.Files != 2 {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteJSON(buf, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res2, err := ReadJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, res2) {
+		t.Fatalf("json roundtrip failed, wanted\n%+v\ngot\n%+v", res, res2)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := []Result{
+		{Repo: "a", Duration: 100 * time.Millisecond, AllocsPerCommit: 10},
+		{Repo: "a", Duration: 200 * time.Millisecond, AllocsPerCommit: 10},
+	}
+	head := []Result{
+		{Repo: "a", Duration: 200 * time.Millisecond, AllocsPerCommit: 20},
+	}
+	got := Compare(base, head, 0.1)
+	want := []Regression{
+		{Repo: "a", Metric: "duration_ms", Base: 150, Head: 200},
+		{Repo: "a", Metric: "allocs_per_commit", Base: 10, Head: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func TestCompareMultipleRepos(t *testing.T) {
+	var base, head []Result
+	for _, repo := range []string{"d", "b", "a", "c"} {
+		base = append(base, Result{Repo: repo, Duration: 100 * time.Millisecond, PeakRSSBytes: 100})
+		head = append(head, Result{Repo: repo, Duration: 200 * time.Millisecond, PeakRSSBytes: 200})
+	}
+	want := []Regression{
+		{Repo: "a", Metric: "duration_ms", Base: 100, Head: 200},
+		{Repo: "a", Metric: "peak_rss_bytes", Base: 100, Head: 200},
+		{Repo: "b", Metric: "duration_ms", Base: 100, Head: 200},
+		{Repo: "b", Metric: "peak_rss_bytes", Base: 100, Head: 200},
+		{Repo: "c", Metric: "duration_ms", Base: 100, Head: 200},
+		{Repo: "c", Metric: "peak_rss_bytes", Base: 100, Head: 200},
+		{Repo: "d", Metric: "duration_ms", Base: 100, Head: 200},
+		{Repo: "d", Metric: "peak_rss_bytes", Base: 100, Head: 200},
+	}
+	// map iteration order is random, so run a few times
+	for i := 0; i < 10; i++ {
+		got := Compare(base, head, 0.1)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// +build windows
+
+package bench
+
+// peakRSS is not supported on windows.
+func peakRSS() int64 {
+	return 0
+}
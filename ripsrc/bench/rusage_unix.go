@@ -0,0 +1,23 @@
+// +build !windows
+
+package bench
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the maximum resident set size of the current process in bytes.
+func peakRSS() int64 {
+	ru := syscall.Rusage{}
+	err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru)
+	if err != nil {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		// already in bytes on darwin
+		return int64(ru.Maxrss)
+	}
+	// kilobytes on linux
+	return int64(ru.Maxrss) * 1024
+}
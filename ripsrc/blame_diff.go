@@ -0,0 +1,61 @@
+package ripsrc
+
+import (
+	"context"
+
+	"github.com/pinpt/ripsrc/ripsrc/blamediff"
+)
+
+// BlameDiffFile contains lines of a file which owning commit changed between two refs.
+type BlameDiffFile = blamediff.File
+
+// BlameDiffLine is a line which owning commit changed between two refs.
+type BlameDiffLine = blamediff.Line
+
+// BlameDiff computes blame at refA and refB and returns only attribution changes, lines added, removed or attributed to a different commit, grouped by file.
+// Files with the same owners at both refs are not returned. Much smaller than comparing full blame at both refs, useful for code review analytics.
+func (s *Ripsrc) BlameDiff(ctx context.Context, refA, refB string, res chan BlameDiffFile) error {
+	defer s.timings.track()()
+	ctx = s.gitContext(ctx)
+
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		close(res)
+		return err
+	}
+
+	opts := blamediff.Opts{}
+	opts.Logger = s.opts.Logger
+	opts.RepoDir = s.opts.RepoDir
+	opts.RefA = refA
+	opts.RefB = refB
+	if s.anonymizer == nil {
+		return blamediff.New(opts).Run(ctx, res)
+	}
+	files := make(chan BlameDiffFile)
+	done := make(chan bool)
+	go func() {
+		defer close(res)
+		for f := range files {
+			res <- s.anonymizer.blameDiffFile(f)
+		}
+		done <- true
+	}()
+	err = blamediff.New(opts).Run(ctx, files)
+	<-done
+	return err
+}
+
+func (s *Ripsrc) BlameDiffSlice(ctx context.Context, refA, refB string) (res []BlameDiffFile, _ error) {
+	resChan := make(chan BlameDiffFile)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.BlameDiff(ctx, refA, refB, resChan)
+	<-done
+	return res, err
+}
@@ -0,0 +1,260 @@
+// Package blamediff compares blame of files at two refs and returns lines which owning commit changed.
+package blamediff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitblame2"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+// File contains attribution changes for one file changed between refs.
+type File struct {
+	// Filename is the path of the file.
+	Filename string
+	// Status is added if file exists only at RefB, removed if only at RefA and modified otherwise.
+	Status Status
+	// Lines are lines which owner is different at RefA and RefB, sorted by line number at RefB, followed by removed lines.
+	Lines []Line
+}
+
+// Status of the file at RefB compared to RefA.
+type Status string
+
+const (
+	// StatusAdded is set for files that do not exist at RefA.
+	StatusAdded = Status("added")
+	// StatusModified is set for files that exist at both refs.
+	StatusModified = Status("modified")
+	// StatusRemoved is set for files that do not exist at RefB.
+	StatusRemoved = Status("removed")
+)
+
+// Line is a line which owner changed between refs.
+type Line struct {
+	// Line is 1-based line number in the file at RefB, or at RefA for removed lines.
+	Line int
+	// Content of the line.
+	Content string
+	// Before is the owner of the line at RefA. Nil for lines added after RefA.
+	Before *Owner
+	// After is the owner of the line at RefB. Nil for lines removed after RefA.
+	After *Owner
+}
+
+// AuthorChanged returns true if line was added, removed or attributed to a different author. False if only the commit changed, for example after rebase.
+func (s Line) AuthorChanged() bool {
+	if s.Before == nil || s.After == nil {
+		return true
+	}
+	return s.Before.Email != s.After.Email
+}
+
+// Owner is the commit that last changed the line.
+type Owner struct {
+	SHA   string
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+type Opts struct {
+	// Logger outputs logs.
+	Logger logger.Logger
+	// RepoDir is location of git repo.
+	RepoDir string
+	// RefA is the branch, tag or commit to compare from.
+	RefA string
+	// RefB is the branch, tag or commit to compare to.
+	RefB string
+}
+
+type Process struct {
+	opts Opts
+
+	commitA string
+	commitB string
+}
+
+func New(opts Opts) *Process {
+	s := &Process{}
+	s.opts = opts
+	return s
+}
+
+// lineAdded marks lines that are not present in the other ref when applying diff to blame.
+const lineAdded = ""
+
+// Run returns files changed between RefA and RefB. Only files with at least one changed line owner are returned.
+// Only files that differ between refs are checked, files with the same content at both refs are expected to have the same blame.
+func (s *Process) Run(ctx context.Context, res chan File) error {
+	defer close(res)
+	var err error
+	s.commitA, err = s.resolve(ctx, s.opts.RefA)
+	if err != nil {
+		return err
+	}
+	s.commitB, err = s.resolve(ctx, s.opts.RefB)
+	if err != nil {
+		return err
+	}
+	if s.commitA == s.commitB {
+		return nil
+	}
+	forward, err := s.diffs(ctx, s.commitA, s.commitB)
+	if err != nil {
+		return err
+	}
+	backward, err := s.diffs(ctx, s.commitB, s.commitA)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for p := range forward {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		f, err := s.file(ctx, p, forward[p], backward[p])
+		if err != nil {
+			return fmt.Errorf("could not diff blame for file %v err: %v", p, err)
+		}
+		if len(f.Lines) == 0 {
+			continue
+		}
+		select {
+		case res <- f:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Process) resolve(ctx context.Context, ref string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", s.opts.RepoDir, []string{"rev-parse", "--verify", ref + "^{commit}"})
+	if err != nil {
+		return "", fmt.Errorf("could not resolve ref %v: %w", ref, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// diffs returns parsed diffs from commit a to b by file path. Binary files are skipped.
+func (s *Process) diffs(ctx context.Context, a, b string) (map[string]incblame.Diff, error) {
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", s.opts.RepoDir, []string{"diff", "--no-renames", "--no-ext-diff", "--no-color", a, b})
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]incblame.Diff{}
+	for _, data := range incblame.SplitDiff(out.Bytes()) {
+		diff, err := incblame.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		if diff.IsBinary {
+			continue
+		}
+		res[diff.PathOrPrev()] = diff
+	}
+	return res, nil
+}
+
+// blame returns blame of the file at commit. Returns empty blame if file does not exist at commit.
+func (s *Process) blame(ctx context.Context, commit string, path string, exists bool) (res incblame.Blame, commits map[string]gitblame2.Commit, _ error) {
+	if !exists {
+		return res, nil, nil
+	}
+	bl, err := gitblame2.RunContext(ctx, s.opts.RepoDir, commit, path)
+	if err != nil {
+		return res, nil, err
+	}
+	res.Commit = commit
+	for _, l := range bl.Lines {
+		res.Lines = append(res.Lines, &incblame.Line{Line: []byte(l.Content), Commit: l.CommitHash})
+	}
+	return res, bl.Commits, nil
+}
+
+// project applies diff to blame, keeping owners for unchanged lines and marking added lines with lineAdded.
+// If the file does not exist at one of the refs, diff is not applied and all targetLen lines are marked as added.
+func (s *Process) project(bl incblame.Blame, diff incblame.Diff, path string, bothExist bool, targetLen int) (incblame.Blame, error) {
+	if bothExist {
+		return incblame.Apply(bl, diff, lineAdded, path)
+	}
+	var res incblame.Blame
+	for i := 0; i < targetLen; i++ {
+		res.Lines = append(res.Lines, &incblame.Line{Commit: lineAdded})
+	}
+	return res, nil
+}
+
+func (s *Process) file(ctx context.Context, path string, forward, backward incblame.Diff) (res File, _ error) {
+	res.Filename = path
+	existsA := forward.PathPrev != ""
+	existsB := forward.Path != ""
+	switch {
+	case !existsA:
+		res.Status = StatusAdded
+	case !existsB:
+		res.Status = StatusRemoved
+	default:
+		res.Status = StatusModified
+	}
+	blameA, commitsA, err := s.blame(ctx, s.commitA, path, existsA)
+	if err != nil {
+		return res, err
+	}
+	blameB, commitsB, err := s.blame(ctx, s.commitB, path, existsB)
+	if err != nil {
+		return res, err
+	}
+	owner := func(commits map[string]gitblame2.Commit, sha string) *Owner {
+		c := commits[sha]
+		return &Owner{SHA: sha, Name: c.AuthorName, Email: c.AuthorEmail, Date: c.AuthorTime}
+	}
+
+	// applying diff to blame at RefA keeps owners from RefA for unchanged lines and marks added lines
+	projectedA, err := s.project(blameA, forward, path, existsA && existsB, len(blameB.Lines))
+	if err != nil {
+		return res, err
+	}
+	if len(projectedA.Lines) != len(blameB.Lines) {
+		return res, fmt.Errorf("line count mismatch after applying diff, want %v got %v", len(blameB.Lines), len(projectedA.Lines))
+	}
+	for i, l := range blameB.Lines {
+		before := projectedA.Lines[i].Commit
+		if before == l.Commit {
+			continue
+		}
+		rl := Line{Line: i + 1, Content: string(l.Line), After: owner(commitsB, l.Commit)}
+		if before != lineAdded {
+			rl.Before = owner(commitsA, before)
+		}
+		res.Lines = append(res.Lines, rl)
+	}
+
+	// same in reverse to find lines removed after RefA
+	projectedB, err := s.project(blameB, backward, path, existsA && existsB, len(blameA.Lines))
+	if err != nil {
+		return res, err
+	}
+	if len(projectedB.Lines) != len(blameA.Lines) {
+		return res, fmt.Errorf("line count mismatch after applying reverse diff, want %v got %v", len(blameA.Lines), len(projectedB.Lines))
+	}
+	for i, l := range blameA.Lines {
+		if projectedB.Lines[i].Commit != lineAdded {
+			continue
+		}
+		res.Lines = append(res.Lines, Line{Line: i + 1, Content: string(l.Line), Before: owner(commitsA, l.Commit)})
+	}
+	return res, nil
+}
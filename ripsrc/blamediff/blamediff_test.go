@@ -0,0 +1,123 @@
+package blamediff
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func run(t *testing.T, repoDir, refA, refB string) (res []File) {
+	t.Helper()
+	resChan := make(chan File)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := New(Opts{RepoDir: repoDir, RefA: refA, RefB: refB}).Run(context.Background(), resChan)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+type wantLine struct {
+	Line    int
+	Content string
+	Before  string
+	After   string
+}
+
+func simplify(lines []Line) (res []wantLine) {
+	for _, l := range lines {
+		w := wantLine{Line: l.Line, Content: l.Content}
+		if l.Before != nil {
+			w.Before = l.Before.Email
+		}
+		if l.After != nil {
+			w.After = l.After.Email
+		}
+		res = append(res, w)
+	}
+	return
+}
+
+func TestBlameDiff(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Author("a", "a@example.com").
+		Write("a.txt", "1\n2\n3\n").
+		Write("same.txt", "x\n").
+		Write("removed.txt", "r\n").
+		Commit("c1")
+	c2 := r.Author("b", "b@example.com").
+		Write("a.txt", "1\nb2\n3\n4\n").
+		Write("added.txt", "n\n").
+		Delete("removed.txt").
+		Commit("c2")
+
+	got := run(t, r.Dir(), c1, c2)
+	if len(got) != 3 {
+		t.Fatalf("wanted 3 files, got %+v", got)
+	}
+	byName := map[string]File{}
+	for _, f := range got {
+		byName[f.Filename] = f
+	}
+
+	a := byName["a.txt"]
+	if a.Status != StatusModified {
+		t.Fatalf("unexpected status %v", a.Status)
+	}
+	wantA := []wantLine{
+		{Line: 2, Content: "b2", After: "b@example.com"},
+		{Line: 4, Content: "4", After: "b@example.com"},
+		{Line: 2, Content: "2", Before: "a@example.com"},
+	}
+	if !reflect.DeepEqual(simplify(a.Lines), wantA) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", wantA, simplify(a.Lines))
+	}
+	if a.Lines[0].After.SHA != c2 || a.Lines[2].Before.SHA != c1 || a.Lines[0].After.Name != "b" {
+		t.Fatalf("unexpected owners %+v %+v", a.Lines[0].After, a.Lines[2].Before)
+	}
+
+	if f := byName["added.txt"]; f.Status != StatusAdded || !reflect.DeepEqual(simplify(f.Lines), []wantLine{{Line: 1, Content: "n", After: "b@example.com"}}) {
+		t.Fatalf("unexpected added file %+v", f)
+	}
+	if f := byName["removed.txt"]; f.Status != StatusRemoved || !reflect.DeepEqual(simplify(f.Lines), []wantLine{{Line: 1, Content: "r", Before: "a@example.com"}}) {
+		t.Fatalf("unexpected removed file %+v", f)
+	}
+
+	if got := run(t, r.Dir(), c2, c2); len(got) != 0 {
+		t.Fatalf("wanted no changes for the same ref, got %+v", got)
+	}
+}
+
+func TestBlameDiffOwnerChangedWithoutContentChange(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Author("a", "a@example.com").Write("a.txt", "1\n2\n").Commit("c1")
+	r.Author("b", "b@example.com").Write("a.txt", "1\nx\n").Commit("c2")
+	// restores original content, but line is now owned by c3
+	c3 := r.Author("c", "c@example.com").Write("a.txt", "1\n2\n3\n").Commit("c3")
+
+	got := run(t, r.Dir(), c1, c3)
+	if len(got) != 1 {
+		t.Fatalf("wanted 1 file, got %+v", got)
+	}
+	want := []wantLine{
+		{Line: 2, Content: "2", Before: "a@example.com", After: "c@example.com"},
+		{Line: 3, Content: "3", After: "c@example.com"},
+	}
+	if !reflect.DeepEqual(simplify(got[0].Lines), want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, simplify(got[0].Lines))
+	}
+	if !got[0].Lines[0].AuthorChanged() {
+		t.Fatal("wanted author changed")
+	}
+}
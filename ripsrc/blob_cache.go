@@ -0,0 +1,148 @@
+package ripsrc
+
+import (
+	"container/list"
+
+	"github.com/boyter/scc/processor"
+	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
+)
+
+// DefaultBlobCacheSize is the default Opts.BlobCacheSize.
+const DefaultBlobCacheSize = 10000
+
+// blobCache keeps code info of recently processed blobs, so that files with identical content, for example after reverts, copies or merges, are not analyzed again.
+// Language and skip classification depends on the path, so it is keyed by blob and path. Line stats only depend on the content and language and are shared between paths.
+// Not safe for concurrent use.
+type blobCache struct {
+	max     int
+	entries map[blobCacheKey]*list.Element
+	// lru has the most recently used entries at the front
+	lru *list.List
+}
+
+type blobCacheKey struct {
+	sha string
+	// path is set for info entries
+	path string
+	// language is set for stats entries
+	language string
+	stats    bool
+}
+
+type blobCacheEntry struct {
+	key   blobCacheKey
+	info  blobInfo
+	stats blobStats
+}
+
+type blobInfo struct {
+	info       fileinfo.Info
+	skipReason string
+}
+
+// blobStats is the scc output for blob content.
+type blobStats struct {
+	loc                int64
+	sloc               int64
+	comments           int64
+	blanks             int64
+	complexity         int64
+	weightedComplexity float64
+	// lineTypes is the type of each line as returned by scc, lines not returned have lineTypeUnknown
+	lineTypes []lineType
+}
+
+type lineType uint8
+
+const (
+	lineTypeUnknown lineType = iota
+	lineTypeBlank
+	lineTypeCode
+	lineTypeComment
+)
+
+func newBlobCache(max int) *blobCache {
+	s := &blobCache{}
+	s.max = max
+	s.entries = map[blobCacheKey]*list.Element{}
+	s.lru = list.New()
+	return s
+}
+
+func (s *blobCache) get(key blobCacheKey) (*blobCacheEntry, bool) {
+	if s == nil || key.sha == "" {
+		return nil, false
+	}
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*blobCacheEntry), true
+}
+
+func (s *blobCache) add(e *blobCacheEntry) {
+	if s == nil || e.key.sha == "" {
+		return
+	}
+	if el, ok := s.entries[e.key]; ok {
+		el.Value = e
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.entries[e.key] = s.lru.PushFront(e)
+	for s.lru.Len() > s.max {
+		last := s.lru.Back()
+		s.lru.Remove(last)
+		delete(s.entries, last.Value.(*blobCacheEntry).key)
+	}
+}
+
+// info returns cached fileinfo result for blob at path.
+func (s *blobCache) info(sha string, path string) (blobInfo, bool) {
+	e, ok := s.get(blobCacheKey{sha: sha, path: path})
+	if !ok {
+		return blobInfo{}, false
+	}
+	return e.info, true
+}
+
+func (s *blobCache) addInfo(sha string, path string, info blobInfo) {
+	s.add(&blobCacheEntry{key: blobCacheKey{sha: sha, path: path}, info: info})
+}
+
+// eachInfo calls cb for fileinfo entries from least to most recently used.
+func (s *blobCache) eachInfo(cb func(sha string, path string, info blobInfo)) {
+	for el := s.lru.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*blobCacheEntry)
+		if e.key.stats {
+			continue
+		}
+		cb(e.key.sha, e.key.path, e.info)
+	}
+}
+
+// stats returns cached line stats for blob analyzed as language.
+func (s *blobCache) stats(sha string, language string) (blobStats, bool) {
+	e, ok := s.get(blobCacheKey{sha: sha, language: language, stats: true})
+	if !ok {
+		return blobStats{}, false
+	}
+	return e.stats, true
+}
+
+func (s *blobCache) addStats(sha string, language string, stats blobStats) {
+	s.add(&blobCacheEntry{key: blobCacheKey{sha: sha, language: language, stats: true}, stats: stats})
+}
+
+func toLineType(t processor.LineType) lineType {
+	switch t {
+	case processor.LINE_BLANK:
+		return lineTypeBlank
+	case processor.LINE_CODE:
+		return lineTypeCode
+	case processor.LINE_COMMENT:
+		return lineTypeComment
+	}
+	return lineTypeUnknown
+}
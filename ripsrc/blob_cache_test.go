@@ -0,0 +1,113 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBlobCacheEviction(t *testing.T) {
+	c := newBlobCache(2)
+	c.addInfo("a", "a.go", blobInfo{skipReason: "a"})
+	c.addStats("a", "Go", blobStats{loc: 1})
+	if _, ok := c.info("a", "a.go"); !ok {
+		t.Fatal("expected info for a")
+	}
+	// stats entry is now the least recently used
+	c.addInfo("b", "b.go", blobInfo{})
+	if _, ok := c.stats("a", "Go"); ok {
+		t.Fatal("expected stats for a to be evicted")
+	}
+	if _, ok := c.info("a", "a.go"); !ok {
+		t.Fatal("expected info for a")
+	}
+	if _, ok := c.info("a", "b.go"); ok {
+		t.Fatal("info is keyed by path")
+	}
+	if _, ok := c.info("", "a.go"); ok {
+		t.Fatal("empty sha is not cached")
+	}
+}
+
+func TestBlobCacheReusesIdenticalContent(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\n// x\nfunc x() {}\n").Commit("c1")
+	r.Write("a.go", "package a\n").Commit("c2")
+	// revert
+	r.Write("a.go", "package a\n\n// x\nfunc x() {}\n").Commit("c3")
+	// copy
+	r.Write("b.go", "package a\n\n// x\nfunc x() {}\n").Commit("c4")
+
+	run := func(size int) ([]BlameResult, string) {
+		t.Helper()
+		sink := metrics.NewPrometheus()
+		res, err := New(Opts{RepoDir: r.Dir(), BlobCacheSize: size, Metrics: sink}).CodeSlice(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		sink.WriteTo(buf)
+		return res, buf.String()
+	}
+	cached, out := run(0)
+	if !strings.Contains(out, metrics.BlobCacheHits+" 1\n") {
+		t.Errorf("expected 1 cache hit for the reverted file, got\n%v", out)
+	}
+	uncached, out := run(-1)
+	if strings.Contains(out, metrics.BlobCacheHits) {
+		t.Errorf("expected no cache hits with disabled cache, got\n%v", out)
+	}
+	if !reflect.DeepEqual(cached, uncached) {
+		t.Fatalf("results with cache differ\n%+v\n%+v", cached, uncached)
+	}
+	last := cached[len(cached)-1]
+	if last.Filename != "b.go" || last.Sloc != 2 || last.Comments != 1 || last.Blanks != 1 || !last.Lines[2].Comment {
+		t.Errorf("unexpected stats for copied file %+v", last)
+	}
+}
+
+func TestFileInfoCachePersisted(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Write("b.go", "package b\n\nvar x = 1\n").Commit("c1")
+	c2 := r.Delete("b.go").Commit("c2")
+	checkpoints := r.Dir() + "-checkpoints"
+	defer os.RemoveAll(checkpoints)
+
+	run := func(opts Opts) string {
+		t.Helper()
+		sink := metrics.NewPrometheus()
+		opts.RepoDir = r.Dir()
+		opts.CheckpointsDir = checkpoints
+		opts.Metrics = sink
+		if _, err := New(opts).CodeSlice(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		sink.WriteTo(buf)
+		return buf.String()
+	}
+	run(Opts{})
+
+	// restored file has the blob analyzed by the previous run
+	c3 := r.Write("b.go", "package b\n\nvar x = 1\n").Commit("c3")
+	out := run(Opts{CommitFromIncl: c2, CommitFromMakeNonIncl: true})
+	if !strings.Contains(out, metrics.BlobCacheHits+" 1\n") {
+		t.Errorf("expected cache hit for blob from the previous run, got\n%v", out)
+	}
+
+	// cache is not used when options affecting fileinfo change
+	r.Delete("b.go").Commit("c4")
+	r.Write("b.go", "package b\n\nvar x = 1\n").Commit("c5")
+	out = run(Opts{CommitFromIncl: c3, CommitFromMakeNonIncl: true, CopyrightHolders: []string{"pinpt"}})
+	if strings.Contains(out, metrics.BlobCacheHits) {
+		t.Errorf("expected no cache hits with different options, got\n%v", out)
+	}
+}
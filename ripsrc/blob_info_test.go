@@ -0,0 +1,43 @@
+package ripsrc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBlobInfo(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a").Write("run.sh", "#!/bin/sh\necho 1\n")
+	r.Git("update-index", "--chmod=+x", "run.sh")
+	r.Commit("c1")
+	// working tree file is not executable, force removal
+	r.Git("rm", "-q", "-f", "run.sh")
+	r.Commit("c2")
+
+	res, err := New(Opts{RepoDir: r.Dir()}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]BlameResult{}
+	for _, b := range res {
+		got[b.Commit.Message+":"+b.Filename] = b
+	}
+	a := got["c1:a.go"]
+	wantSHA := strings.TrimSpace(r.Git("rev-parse", "HEAD~1:a.go"))
+	// no newline at the end of file, size is of the actual content
+	if a.BlobSHA != wantSHA || a.BlobSize != 9 || a.Mode != "100644" || a.Executable() {
+		t.Errorf("unexpected blob info for a.go %+v", a)
+	}
+	run := got["c1:run.sh"]
+	if run.BlobSize != 17 || run.Mode != "100755" || !run.Executable() {
+		t.Errorf("unexpected blob info for run.sh %+v", run)
+	}
+	removed := got["c2:run.sh"]
+	if removed.Status != GitFileCommitStatusRemoved || removed.BlobSHA != "" || removed.BlobSize != 0 || removed.Mode != "" {
+		t.Errorf("unexpected blob info for removed file %+v", removed)
+	}
+}
@@ -0,0 +1,76 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchdiff"
+)
+
+// BranchDiff describes how a branch diverged from the default branch.
+type BranchDiff = branchdiff.BranchDiff
+
+// BranchDiff returns ahead/behind counts, merge bases, commit times and merge status for non-default branches compared to the default branch.
+// Pass branch name to only return data for one branch, or empty string for all branches.
+func (s *Ripsrc) BranchDiff(ctx context.Context, branch string, res chan BranchDiff) (rerr error) {
+	defer close(res)
+	defer s.timings.track()()
+	defer func() {
+		rerr = ctxErr(ctx, rerr)
+	}()
+	ctx = s.gitContext(ctx)
+	if !s.opts.AllBranches {
+		return errors.New("BranchDiff call is only allowed when AllBranches=true")
+	}
+
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.buildCommitGraph(ctx)
+	if err != nil {
+		return err
+	}
+
+	res2 := make(chan BranchDiff)
+	done := make(chan bool)
+	go func() {
+		for r := range res2 {
+			// keep reading until Run exits, it returns ctx error
+			select {
+			case res <- r:
+			case <-ctx.Done():
+			}
+		}
+		done <- true
+	}()
+	opts := branchdiff.Opts{}
+	opts.Logger = s.opts.Logger
+	opts.UseOrigin = s.opts.BranchesUseOrigin
+	opts.CommitGraph = s.commitGraph
+	opts.RepoDir = s.opts.RepoDir
+	opts.Branch = branch
+	pr := branchdiff.New(opts)
+	err = pr.Run(ctx, res2)
+	<-done
+	if err == nil {
+		// results dropped by the goroutine above on cancel
+		err = ctx.Err()
+	}
+	return err
+}
+
+func (s *Ripsrc) BranchDiffSlice(ctx context.Context, branch string) (res []BranchDiff, _ error) {
+	resChan := make(chan BranchDiff)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.BranchDiff(ctx, branch, resChan)
+	<-done
+	return res, err
+}
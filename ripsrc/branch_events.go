@@ -0,0 +1,277 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// BranchEventType is the kind of change to a branch since the previous BranchEvents call.
+type BranchEventType string
+
+const (
+	// BranchEventCreated is returned for branches that did not exist in the previous run, or for all branches in the first run.
+	BranchEventCreated = BranchEventType("created")
+	// BranchEventAdvanced is returned for branches that point to a different commit than in the previous run. Usually new commits, but also returned for force-pushed branches.
+	BranchEventAdvanced = BranchEventType("advanced")
+	// BranchEventMerged is returned when branch tip becomes reachable from the default branch. Also returned for deleted branches if they were merged before deletion.
+	BranchEventMerged = BranchEventType("merged")
+	// BranchEventDeleted is returned for branches that existed in the previous run, but not anymore.
+	BranchEventDeleted = BranchEventType("deleted")
+)
+
+// BranchEvent is a change to a branch since the previous BranchEvents call.
+type BranchEvent struct {
+	Type   BranchEventType
+	Branch string
+	// Commit is the current tip of the branch. Empty for deleted branches.
+	Commit string
+	// PrevCommit is the tip of the branch in the previous run. Empty for created branches.
+	PrevCommit string
+}
+
+// branchState records branch tips at the end of BranchEvents call.
+type branchState struct {
+	Branches map[string]branchStateEntry
+	Time     time.Time
+}
+
+type branchStateEntry struct {
+	Commit string
+	// Merged is true if the tip was reachable from the default branch. Merged event is returned when this changes from false to true.
+	Merged bool
+}
+
+const branchStateFile = "branch-state.json"
+
+// BranchEvents compares branches with the state stored by the previous call and returns branches that were created, advanced, merged into the default branch or deleted since.
+// State is stored in checkpoints dir and updated after all events are sent, so events are returned once. Lists local branches, or origin/ branches with BranchesUseOrigin.
+// Events are sorted by branch name.
+func (s *Ripsrc) BranchEvents(ctx context.Context, res chan BranchEvent) (rerr error) {
+	defer close(res)
+	defer s.timings.track()()
+	defer func() {
+		rerr = ctxErr(ctx, rerr)
+	}()
+	ctx = s.gitContext(ctx)
+
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return err
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	prev, err := s.readBranchState(ctx)
+	if err != nil {
+		return err
+	}
+	events, state, err := s.branchEvents(ctx, prev)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		select {
+		case res <- ev:
+		case <-ctx.Done():
+			// state is not written, so events are returned again
+			return ctx.Err()
+		}
+	}
+	return s.writeBranchState(ctx, state)
+}
+
+func (s *Ripsrc) BranchEventsSlice(ctx context.Context) (res []BranchEvent, _ error) {
+	resChan := make(chan BranchEvent)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.BranchEvents(ctx, resChan)
+	<-done
+	return res, err
+}
+
+func (s *Ripsrc) branchEvents(ctx context.Context, prev *branchState) (res []BranchEvent, state branchState, _ error) {
+	state.Branches = map[string]branchStateEntry{}
+	state.Time = time.Now()
+
+	defaultBranch, err := s.getDefaultBranch(ctx)
+	if err != nil {
+		return nil, state, err
+	}
+	branches, err := s.getBranches(ctx)
+	if err != nil {
+		return nil, state, err
+	}
+	defaultCommit := defaultBranch.Commit
+	filter := s.branchFilter()
+	var filtered []branchmeta.BranchWithCommitTime
+	for _, b := range branches {
+		if b.Name == defaultBranch.Name {
+			defaultCommit = b.Commit
+		}
+		if b.Name == defaultBranch.Name || filter.match(b.Name) {
+			filtered = append(filtered, b)
+		}
+	}
+	branches = filtered
+	merged, err := s.mergedBranches(ctx, defaultCommit)
+	if err != nil {
+		return nil, state, err
+	}
+
+	if prev == nil {
+		prev = &branchState{}
+	}
+	for _, b := range branches {
+		isMerged := b.Name != defaultBranch.Name && merged[b.Name]
+		state.Branches[b.Name] = branchStateEntry{Commit: b.Commit, Merged: isMerged}
+		old, existed := prev.Branches[b.Name]
+		switch {
+		case !existed:
+			res = append(res, BranchEvent{Type: BranchEventCreated, Branch: b.Name, Commit: b.Commit})
+			// branches created from the default branch are reachable from it, merged is only returned after branch had own commits
+			continue
+		case old.Commit != b.Commit:
+			res = append(res, BranchEvent{Type: BranchEventAdvanced, Branch: b.Name, Commit: b.Commit, PrevCommit: old.Commit})
+		}
+		if isMerged && !old.Merged {
+			res = append(res, BranchEvent{Type: BranchEventMerged, Branch: b.Name, Commit: b.Commit, PrevCommit: old.Commit})
+		}
+	}
+	var deleted []string
+	for name := range prev.Branches {
+		if _, ok := state.Branches[name]; !ok {
+			deleted = append(deleted, name)
+		}
+	}
+	if len(deleted) != 0 {
+		check, err := gitexec.NewCatFileCheck(ctx, gitCommand, s.opts.RepoDir)
+		if err != nil {
+			return nil, state, err
+		}
+		defer check.Close()
+		for _, name := range deleted {
+			old := prev.Branches[name]
+			if !old.Merged {
+				isMerged, err := s.commitReachable(ctx, check, old.Commit, defaultCommit)
+				if err != nil {
+					return nil, state, err
+				}
+				if isMerged {
+					res = append(res, BranchEvent{Type: BranchEventMerged, Branch: name, PrevCommit: old.Commit})
+				}
+			}
+			res = append(res, BranchEvent{Type: BranchEventDeleted, Branch: name, PrevCommit: old.Commit})
+		}
+	}
+	// stable sort keeps order of events for the same branch
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Branch < res[j].Branch
+	})
+	return res, state, nil
+}
+
+// mergedBranches returns branches which tips are reachable from commit.
+func (s *Ripsrc) mergedBranches(ctx context.Context, commit string) (map[string]bool, error) {
+	refs := "refs/heads"
+	if s.opts.BranchesUseOrigin {
+		refs = "refs/remotes/origin"
+	}
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"for-each-ref", "--merged", commit, "--format=%(refname:short)", refs})
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]bool{}
+	for _, name := range strings.Fields(out.String()) {
+		if s.opts.BranchesUseOrigin {
+			name = strings.TrimPrefix(name, "origin/")
+		}
+		res[name] = true
+	}
+	return res, nil
+}
+
+// commitReachable returns true if commit is reachable from target. Returns false if commit no longer exists in the repo, for example after gc of a deleted branch.
+func (s *Ripsrc) commitReachable(ctx context.Context, check *gitexec.CatFileCheck, commit string, target string) (bool, error) {
+	_, err := check.Info(commit)
+	if errors.Is(err, gitexec.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	out := bytes.NewBuffer(nil)
+	err = gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"rev-list", "--count", commit, "--not", target})
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out.String()) == "0", nil
+}
+
+func (s *Ripsrc) branchStatePath(ctx context.Context) (string, error) {
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(loc), branchStateFile), nil
+}
+
+// readBranchState returns nil if there is no stored state
+func (s *Ripsrc) readBranchState(ctx context.Context) (*branchState, error) {
+	loc, err := s.branchStatePath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(loc)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res branchState
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse branch state: %v", err)
+	}
+	return &res, nil
+}
+
+func (s *Ripsrc) writeBranchState(ctx context.Context, state branchState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	loc, err := s.branchStatePath(ctx)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(loc), 0777)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(loc+".tmp", b, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(loc+".tmp", loc)
+}
@@ -0,0 +1,70 @@
+package ripsrc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBranchEvents(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	f1 := r.Branch("f1").Write("b.txt", "b\n").Commit("c2")
+	f3 := r.Checkout("master").Branch("f3").Write("c.txt", "c\n").Commit("c3")
+	r.Checkout("master")
+
+	run := func() []BranchEvent {
+		t.Helper()
+		res, err := New(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}).BranchEventsSlice(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	assert := func(want, got []BranchEvent) {
+		t.Helper()
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+		}
+	}
+
+	assert([]BranchEvent{
+		{Type: BranchEventCreated, Branch: "f1", Commit: f1},
+		{Type: BranchEventCreated, Branch: "f3", Commit: f3},
+		{Type: BranchEventCreated, Branch: "master", Commit: c1},
+	}, run())
+
+	f1b := r.Checkout("f1").Write("b.txt", "b2\n").Commit("c4")
+	r.Checkout("master")
+	r.Merge("m1", "f1")
+	m2 := r.Merge("m2", "f3")
+	r.Git("branch", "-D", "f3")
+	r.Branch("f2").Checkout("master")
+
+	assert([]BranchEvent{
+		{Type: BranchEventAdvanced, Branch: "f1", Commit: f1b, PrevCommit: f1},
+		{Type: BranchEventMerged, Branch: "f1", Commit: f1b, PrevCommit: f1},
+		{Type: BranchEventCreated, Branch: "f2", Commit: m2},
+		{Type: BranchEventMerged, Branch: "f3", PrevCommit: f3},
+		{Type: BranchEventDeleted, Branch: "f3", PrevCommit: f3},
+		{Type: BranchEventAdvanced, Branch: "master", Commit: m2, PrevCommit: c1},
+	}, run())
+
+	assert(nil, run())
+
+	r.Git("branch", "-D", "f2")
+	assert([]BranchEvent{
+		{Type: BranchEventDeleted, Branch: "f2", PrevCommit: m2},
+	}, run())
+}
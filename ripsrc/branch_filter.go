@@ -0,0 +1,170 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+)
+
+// regexpPrefix marks branch and author patterns that are regular expressions instead of globs.
+const regexpPrefix = "re:"
+
+// branchFilter matches branch names against Opts.BranchesInclude and Opts.BranchesExclude.
+type branchFilter struct {
+	include []namePattern
+	exclude []namePattern
+}
+
+type namePattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func newBranchFilter(include, exclude []string) (*branchFilter, error) {
+	s := &branchFilter{}
+	var err error
+	s.include, err = parseNamePatterns("branch", include)
+	if err != nil {
+		return nil, err
+	}
+	s.exclude, err = parseNamePatterns("branch", exclude)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseNamePatterns parses globs and regular expressions prefixed with regexpPrefix. Kind is used in errors.
+func parseNamePatterns(kind string, patterns []string) (res []namePattern, _ error) {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, regexpPrefix) {
+			re, err := regexp.Compile(strings.TrimPrefix(p, regexpPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %v pattern %q err: %v", kind, p, err)
+			}
+			res = append(res, namePattern{re: re})
+			continue
+		}
+		// path.Match only returns error for malformed patterns
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid %v pattern %q err: %v", kind, p, err)
+		}
+		res = append(res, namePattern{glob: p})
+	}
+	return
+}
+
+func (s namePattern) match(name string) bool {
+	if s.re != nil {
+		return s.re.MatchString(name)
+	}
+	ok, _ := path.Match(s.glob, name)
+	return ok
+}
+
+// empty returns true if no patterns are set, in which case all branches match.
+func (s *branchFilter) empty() bool {
+	return len(s.include) == 0 && len(s.exclude) == 0
+}
+
+// match returns true if branch name matches any include pattern, or there are no include patterns, and does not match any exclude pattern.
+func (s *branchFilter) match(name string) bool {
+	ok := len(s.include) == 0
+	for _, p := range s.include {
+		if p.match(name) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return false
+	}
+	for _, p := range s.exclude {
+		if p.match(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// branchFilter returns filter for Opts.BranchesInclude and Opts.BranchesExclude. Patterns are checked in Validate.
+func (s *Ripsrc) branchFilter() *branchFilter {
+	res, err := newBranchFilter(s.opts.BranchesInclude, s.opts.BranchesExclude)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// selectBranchRefs returns refs of branches to process when AllBranches is limited by BranchesInclude, BranchesExclude, MaxBranches or, in incrementals, IncrementalIgnoreBranchesOlderThan.
+// Default branch is always included, since other branches are compared with it.
+func (s *Ripsrc) selectBranchRefs(ctx context.Context) (res []string, _ error) {
+	defaultBranch, err := s.getDefaultBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	branches, err := s.getBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var deadline time.Time
+	if s.opts.CommitFromIncl != "" {
+		deadline = s.opts.IncrementalIgnoreBranchesOlderThan
+		if deadline.IsZero() {
+			deadline = time.Now().Add(-3 * 30 * 24 * time.Hour)
+		}
+	}
+	filter := s.branchFilter()
+	var selected []branchmeta.BranchWithCommitTime
+	s.selectedBranches = map[string]bool{}
+	for _, b := range branches {
+		if b.Name == defaultBranch.Name {
+			s.selectedBranches[b.Name] = true
+			continue
+		}
+		if !filter.match(b.Name) || b.CommitCommitterTime.Before(deadline) {
+			continue
+		}
+		selected = append(selected, b)
+	}
+	if s.opts.MaxBranches > 0 && len(selected) > s.opts.MaxBranches {
+		sort.SliceStable(selected, func(i, j int) bool {
+			return selected[i].CommitCommitterTime.After(selected[j].CommitCommitterTime)
+		})
+		s.opts.Logger.Info("skipping branches over MaxBranches", "max", s.opts.MaxBranches, "skipped", len(selected)-s.opts.MaxBranches)
+		selected = selected[:s.opts.MaxBranches]
+	}
+	for _, b := range selected {
+		s.selectedBranches[b.Name] = true
+	}
+
+	prefix := "refs/heads/"
+	if s.opts.BranchesUseOrigin {
+		prefix = "refs/remotes/origin/"
+	}
+	for _, b := range branches {
+		if s.selectedBranches[b.Name] {
+			res = append(res, prefix+b.Name)
+		}
+	}
+	if !s.selectedBranches[defaultBranch.Name] {
+		// default branch is not in listed branches, for example only exists in origin, use the commit detected
+		s.selectedBranches[defaultBranch.Name] = true
+		res = append(res, defaultBranch.Commit)
+	}
+	return res, nil
+}
+
+// branchSelected returns true if branch is processed with current options.
+func (s *Ripsrc) branchSelected(name string) bool {
+	if s.selectedBranches != nil {
+		return s.selectedBranches[name]
+	}
+	return s.branchFilter().match(name)
+}
@@ -0,0 +1,217 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBranchFilterMatch(t *testing.T) {
+	f, err := newBranchFilter([]string{"main", "release/*", "re:^hotfix-[0-9]+$"}, []string{"release/old*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"main":          true,
+		"master":        false,
+		"release/1.0":   true,
+		"release/a/b":   false,
+		"release/old-1": false,
+		"hotfix-12":     true,
+		"hotfix-x":      false,
+	}
+	for name, want := range cases {
+		if got := f.match(name); got != want {
+			t.Errorf("%v: wanted %v got %v", name, want, got)
+		}
+	}
+	if _, err := newBranchFilter([]string{"re:("}, nil); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+	if _, err := newBranchFilter(nil, []string{"["}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+}
+
+func TestBranchFilterProcessing(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Branch("release/1").Write("b.txt", "b\n").Commit("c2")
+	r.Checkout("master").Branch("feature/x").Write("c.txt", "c\n").Commit("c3")
+	r.Checkout("master")
+
+	opts := Opts{RepoDir: r.Dir(), AllBranches: true, BranchesInclude: []string{"release/*"}}
+	got := codeByCommitSHAs(t, opts)
+	want := []string{c1, c2}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("wanted commits %v got %v", want, got)
+	}
+
+	branches, err := New(opts).BranchesSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "master" || names[1] != "release/1" {
+		t.Fatalf("unexpected branches %v", names)
+	}
+}
+
+// codeByCommit runs CodeByCommit and returns the commits and the number of their blames. Blames channels of returned commits are drained.
+func codeByCommit(opts Opts) (res []CommitCode, blames int, _ error) {
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			res = append(res, c)
+			for range c.Blames {
+				blames++
+			}
+		}
+		done <- true
+	}()
+	err := New(opts).CodeByCommit(context.Background(), commits)
+	<-done
+	return res, blames, err
+}
+
+// shas returns SHAs of commits.
+func shas(commits []CommitCode) (res []string) {
+	for _, c := range commits {
+		res = append(res, c.SHA)
+	}
+	return
+}
+
+func codeByCommitSHAs(t *testing.T, opts Opts) []string {
+	t.Helper()
+	commits, _, err := codeByCommit(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shas(commits)
+}
+
+func TestMaxBranches(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("b1").Write("b.txt", "b\n").Commit("c2")
+	c3 := r.Checkout("master").Branch("b2").Write("c.txt", "c\n").Commit("c3")
+	r.Checkout("master")
+
+	// most recently committed branch is selected
+	got := codeByCommitSHAs(t, Opts{RepoDir: r.Dir(), AllBranches: true, MaxBranches: 1})
+	want := []string{c1, c3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted commits %v got %v", want, got)
+	}
+}
+
+func TestIncrementalIgnoreBranchesOlderThan(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	// commits are one minute apart starting from 2019-01-01 00:00
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("stale").Write("b.txt", "b\n").Commit("c2")
+	c3 := r.Checkout("master").Write("a.txt", "a3\n").Commit("c3")
+	codeByCommitSHAs(t, Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, AllBranches: true})
+
+	r.Checkout("stale").Write("b.txt", "b4\n").Commit("c4")
+	c5 := r.Checkout("master").Branch("fresh").Write("c.txt", "c\n").Commit("c5")
+	c6 := r.Checkout("master").Write("a.txt", "a6\n").Commit("c6")
+
+	got := codeByCommitSHAs(t, Opts{
+		RepoDir:                            r.Dir(),
+		CheckpointsDir:                     checkpointsDir,
+		AllBranches:                        true,
+		CommitFromIncl:                     c3,
+		CommitFromMakeNonIncl:              true,
+		IncrementalIgnoreBranchesOlderThan: time.Date(2019, 1, 1, 0, 4, 30, 0, time.UTC),
+	})
+	want := []string{c5, c6}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted commits %v got %v", want, got)
+	}
+}
+
+func TestIncrementalSkipsProcessedBranches(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("feat").Write("b.txt", "b\n").Commit("c2")
+	// feat forked before the commits kept in checkpoint
+	var last string
+	for i := 0; i < 1010; i++ {
+		last = r.Checkout("master").Write("a.txt", fmt.Sprintf("a%v\n", i)).Commit("m")
+	}
+	codeByCommitSHAs(t, Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, AllBranches: true})
+
+	c3 := r.Write("a.txt", "a3\n").Commit("c3")
+	got := codeByCommitSHAs(t, Opts{
+		RepoDir:                            r.Dir(),
+		CheckpointsDir:                     checkpointsDir,
+		AllBranches:                        true,
+		CommitFromIncl:                     last,
+		CommitFromMakeNonIncl:              true,
+		IncrementalIgnoreBranchesOlderThan: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	want := []string{c3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted commits %v got %v", want, got)
+	}
+}
+
+func TestIncrementalRefsSkipsProcessedCommits(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("feat").Write("b.txt", "b\n").Commit("c2")
+	c3 := r.Checkout("master").Write("a.txt", "a3\n").Commit("c3")
+	refs := []string{"feat", "master"}
+	codeByCommitSHAs(t, Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, Refs: refs})
+
+	// c2 is not an ancestor of c3, but was processed already
+	c4 := r.Write("a.txt", "a4\n").Commit("c4")
+	got := codeByCommitSHAs(t, Opts{
+		RepoDir:               r.Dir(),
+		CheckpointsDir:        checkpointsDir,
+		Refs:                  refs,
+		CommitFromIncl:        c3,
+		CommitFromMakeNonIncl: true,
+	})
+	want := []string{c4}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted commits %v got %v", want, got)
+	}
+}
@@ -0,0 +1,313 @@
+package branchdiff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+// BranchDiff describes how a branch diverged from the default branch.
+type BranchDiff struct {
+	// Name of the branch
+	Name string
+
+	// HeadSHA is the sha of the head commit of the branch.
+	HeadSHA string
+
+	// DefaultHeadSHA is the sha of the head commit of the default branch used for comparison.
+	DefaultHeadSHA string
+
+	// AheadCount is the number of commits reachable from branch, but not from default branch.
+	// Same as git rev-list --count default..branch
+	AheadCount int
+
+	// BehindCount is the number of commits reachable from default branch, but not from branch.
+	// Same as git rev-list --count branch..default
+	BehindCount int
+
+	// MergeBases are the best common ancestors of branch and default branch. Same as git merge-base --all.
+	// Normally this is len(1). Empty for branches that do not share history with default branch.
+	MergeBases []string
+
+	// Orphan is true if branch does not share history with default branch, for example gh-pages or docs branches created with git checkout --orphan.
+	// AheadCount and BehindCount are all commits of the branch and default branch in this case.
+	Orphan bool
+
+	// FirstCommitTime is the committer time of the oldest commit ahead of default branch.
+	// Zero if AheadCount is 0.
+	FirstCommitTime time.Time
+
+	// LastCommitTime is the committer time of the head commit of the branch.
+	LastCommitTime time.Time
+
+	// IsMerged is true if branch head is reachable from default branch.
+	IsMerged bool
+
+	// MergeCommit is the commit on default branch that merged this branch. Set if IsMerged=true and branch was not fast-forwarded.
+	MergeCommit string
+
+	// MergeStatus classifies the branch based on ancestry and squash merge heuristics.
+	MergeStatus MergeStatus
+
+	// SquashMergeCommit is the commit on default branch that likely contains squashed changes of this branch.
+	// Set if MergeStatus=MergeStatusSquashMerged.
+	SquashMergeCommit string
+}
+
+// MergeStatus classifies whether branch changes landed on default branch.
+type MergeStatus string
+
+const (
+	// MergeStatusMerged is set when branch head is reachable from default branch.
+	MergeStatusMerged = MergeStatus("merged")
+	// MergeStatusSquashMerged is set when branch is not reachable from default branch, but default branch contains a commit with the same changes.
+	MergeStatusSquashMerged = MergeStatus("squash_merged")
+	// MergeStatusOpen is set for branches that are not merged and were updated recently.
+	MergeStatusOpen = MergeStatus("open")
+	// MergeStatusAbandoned is set for branches that are not merged and were not updated in Opts.AbandonedAfter.
+	MergeStatusAbandoned = MergeStatus("abandoned")
+	// MergeStatusOrphan is set for branches that do not share history with default branch, such as gh-pages. See BranchDiff.Orphan.
+	MergeStatusOrphan = MergeStatus("orphan")
+)
+
+type Opts struct {
+	// Logger outputs logs.
+	Logger logger.Logger
+	// RepoDir is location of git repo.
+	RepoDir string
+	// CommitGraph is the full graph of commits.
+	CommitGraph *parentsgraph.Graph
+	// UseOrigin set to true to use branches with origin/ prefix instead of default.
+	UseOrigin bool
+	// Branch limits the output to a single branch. If empty all non-default branches are returned.
+	Branch string
+	// AbandonedAfter is the duration since last commit after which not merged branch is considered abandoned.
+	// Default is 90 days.
+	AbandonedAfter time.Duration
+}
+
+type Process struct {
+	opts Opts
+
+	commits map[string]commitInfo
+
+	// diffTree is started on first use and reused for all branches
+	diffTree *gitexec.DiffTree
+}
+
+type commitInfo struct {
+	CommitterTime time.Time
+	AuthorEmail   string
+}
+
+func New(opts Opts) *Process {
+	if opts.AbandonedAfter == 0 {
+		opts.AbandonedAfter = 90 * 24 * time.Hour
+	}
+	s := &Process{}
+	s.opts = opts
+	return s
+}
+
+func (s *Process) Run(ctx context.Context, res chan BranchDiff) error {
+	defer close(res)
+	defer s.closeDiffTree()
+
+	defaultBranch, err := branchmeta.GetDefault(ctx, s.opts.RepoDir)
+	if err != nil {
+		return err
+	}
+
+	bopts := branchmeta.Opts{}
+	bopts.Logger = s.opts.Logger
+	bopts.RepoDir = s.opts.RepoDir
+	bopts.UseOrigin = s.opts.UseOrigin
+	branches, err := branchmeta.Get(ctx, bopts)
+	if err != nil {
+		return err
+	}
+
+	if s.opts.Branch != "" {
+		var found []branchmeta.BranchWithCommitTime
+		for _, b := range branches {
+			if b.Name == s.opts.Branch {
+				found = append(found, b)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("branch not found: %v", s.opts.Branch)
+		}
+		branches = found
+	}
+
+	s.commits, err = s.getCommits(ctx)
+	if err != nil {
+		return err
+	}
+
+	gr := s.opts.CommitGraph
+	defaultHead := defaultBranch.Commit
+	reachableFromDefault := reachable(gr, defaultHead)
+
+	for _, b := range branches {
+		if _, ok := gr.Parents[b.Commit]; !ok {
+			return fmt.Errorf("branch head not found in commit graph, branch: %v commit: %v", b.Name, b.Commit)
+		}
+		s.opts.Logger.Debug("branchdiff: processing branch", "name", b.Name, "commit", b.Commit)
+		r := s.diff(gr, b.Name, b.Commit, defaultHead, reachableFromDefault)
+		err := s.setMergeStatus(ctx, &r, gr, reachableFromDefault)
+		if err != nil {
+			return err
+		}
+		res <- r
+	}
+	return nil
+}
+
+func (s *Process) RunSlice(ctx context.Context) (res []BranchDiff, _ error) {
+	resChan := make(chan BranchDiff)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.Run(ctx, resChan)
+	<-done
+	return res, err
+}
+
+func (s *Process) diff(gr *parentsgraph.Graph, name string, head string, defaultHead string, reachableFromDefault map[string]bool) BranchDiff {
+	res := BranchDiff{}
+	res.Name = name
+	res.HeadSHA = head
+	res.DefaultHeadSHA = defaultHead
+
+	reachableFromBranch := reachable(gr, head)
+
+	var ahead []string
+	for h := range reachableFromBranch {
+		if !reachableFromDefault[h] {
+			ahead = append(ahead, h)
+		}
+	}
+	for h := range reachableFromDefault {
+		if !reachableFromBranch[h] {
+			res.BehindCount++
+		}
+	}
+	res.AheadCount = len(ahead)
+	res.MergeBases = mergeBases(gr, reachableFromBranch, reachableFromDefault)
+	res.Orphan = len(res.MergeBases) == 0
+
+	for _, h := range ahead {
+		t := s.commits[h].CommitterTime
+		if res.FirstCommitTime.IsZero() || t.Before(res.FirstCommitTime) {
+			res.FirstCommitTime = t
+		}
+	}
+	res.LastCommitTime = s.commits[head].CommitterTime
+
+	if reachableFromDefault[head] {
+		res.IsMerged = true
+		for _, ch := range gr.Children[head] {
+			if reachableFromDefault[ch] {
+				res.MergeCommit = ch
+				break
+			}
+		}
+	}
+	return res
+}
+
+// reachable returns all commits reachable from head, including head.
+func reachable(gr *parentsgraph.Graph, head string) map[string]bool {
+	res := map[string]bool{}
+	stack := []string{head}
+	for len(stack) != 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if res[h] {
+			continue
+		}
+		res[h] = true
+		stack = append(stack, gr.Parents[h]...)
+	}
+	return res
+}
+
+// mergeBases returns common ancestors that are not ancestors of other common ancestors.
+func mergeBases(gr *parentsgraph.Graph, a, b map[string]bool) (res []string) {
+	common := map[string]bool{}
+	for h := range a {
+		if b[h] {
+			common[h] = true
+		}
+	}
+	notBest := map[string]bool{}
+	var stack []string
+	for h := range common {
+		stack = append(stack, gr.Parents[h]...)
+	}
+	for len(stack) != 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if notBest[h] {
+			continue
+		}
+		notBest[h] = true
+		stack = append(stack, gr.Parents[h]...)
+	}
+	for h := range common {
+		if !notBest[h] {
+			res = append(res, h)
+		}
+	}
+	sort.Strings(res) // to have consistent order
+	return
+}
+
+func (s *Process) getCommits(ctx context.Context) (map[string]commitInfo, error) {
+	args := []string{
+		"log",
+		"--all",
+		"--no-abbrev-commit",
+		"--pretty=format:%H@%cI@%ae",
+	}
+	r, err := gitexec.Exec(ctx, "git", s.opts.RepoDir, args)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]commitInfo{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line := strings.TrimSpace(string(line))
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "@", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unexpected git log output line: %v", line)
+		}
+		t, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse commit time: %v err: %v", line, err)
+		}
+		res[parts[0]] = commitInfo{CommitterTime: t, AuthorEmail: parts[2]}
+	}
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package branchdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+)
+
+func assertEqual(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v got %v", want, got)
+	}
+}
+
+func TestDiffBasic(t *testing.T) {
+	gr := parentsgraph.NewFromMap(map[string][]string{
+		"m1": nil,
+		"m2": []string{"m1"},
+		"m3": []string{"m2"},
+		"b1": []string{"m1"},
+		"b2": []string{"b1"},
+	})
+	s := New(Opts{})
+	res := s.diff(gr, "b", "b2", "m3", reachable(gr, "m3"))
+	assertEqual(t, res.AheadCount, 2)
+	assertEqual(t, res.BehindCount, 2)
+	assertEqual(t, res.MergeBases, []string{"m1"})
+	assertEqual(t, res.IsMerged, false)
+}
+
+func TestDiffMerged(t *testing.T) {
+	gr := parentsgraph.NewFromMap(map[string][]string{
+		"m1": nil,
+		"b1": []string{"m1"},
+		"m2": []string{"m1", "b1"},
+	})
+	s := New(Opts{})
+	res := s.diff(gr, "b", "b1", "m2", reachable(gr, "m2"))
+	assertEqual(t, res.AheadCount, 0)
+	assertEqual(t, res.BehindCount, 1)
+	assertEqual(t, res.MergeBases, []string{"b1"})
+	assertEqual(t, res.IsMerged, true)
+	assertEqual(t, res.MergeCommit, "m2")
+}
+
+func TestMergeBasesCrissCross(t *testing.T) {
+	gr := parentsgraph.NewFromMap(map[string][]string{
+		"r":  nil,
+		"a1": []string{"r"},
+		"b1": []string{"r"},
+		"a2": []string{"a1", "b1"},
+		"b2": []string{"b1", "a1"},
+	})
+	got := mergeBases(gr, reachable(gr, "a2"), reachable(gr, "b2"))
+	assertEqual(t, got, []string{"a1", "b1"})
+}
+
+func TestMergeBasesUnrelated(t *testing.T) {
+	gr := parentsgraph.NewFromMap(map[string][]string{
+		"a": nil,
+		"b": nil,
+	})
+	got := mergeBases(gr, reachable(gr, "a"), reachable(gr, "b"))
+	assertEqual(t, len(got), 0)
+}
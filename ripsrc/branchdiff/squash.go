@@ -0,0 +1,263 @@
+package branchdiff
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+)
+
+func (s *Process) setMergeStatus(ctx context.Context, res *BranchDiff, gr *parentsgraph.Graph, reachableFromDefault map[string]bool) error {
+	if res.IsMerged {
+		res.MergeStatus = MergeStatusMerged
+		return nil
+	}
+	if res.Orphan {
+		res.MergeStatus = MergeStatusOrphan
+		return nil
+	}
+	commit, err := s.squashMergeCommit(ctx, res, gr, reachableFromDefault)
+	if err != nil {
+		return err
+	}
+	if commit != "" {
+		res.MergeStatus = MergeStatusSquashMerged
+		res.SquashMergeCommit = commit
+		return nil
+	}
+	if time.Since(res.LastCommitTime) > s.opts.AbandonedAfter {
+		res.MergeStatus = MergeStatusAbandoned
+		return nil
+	}
+	res.MergeStatus = MergeStatusOpen
+	return nil
+}
+
+// squashMergeCommit returns the commit on default branch that likely contains squashed changes of the branch.
+// Squashed branches are never reachable from default branch, so we compare the combined branch patch with commits made on default branch after the merge base.
+//
+// First we look for commit with the same git patch-id as the combined branch diff. If squash had conflicts patch-id would not match, so we fallback to
+// commit by one of the branch authors, made after the first branch commit, that changes the same set of files and adds all lines added in branch.
+func (s *Process) squashMergeCommit(ctx context.Context, res *BranchDiff, gr *parentsgraph.Graph, reachableFromDefault map[string]bool) (string, error) {
+	if res.AheadCount == 0 || len(res.MergeBases) != 1 {
+		return "", nil
+	}
+	mergeBase := res.MergeBases[0]
+
+	reachableFromMergeBase := reachable(gr, mergeBase)
+	candidates := map[string]bool{}
+	for h := range reachableFromDefault {
+		if reachableFromMergeBase[h] {
+			continue
+		}
+		if len(gr.Parents[h]) > 1 {
+			continue
+		}
+		if s.commits[h].CommitterTime.Before(res.FirstCommitTime) {
+			continue
+		}
+		candidates[h] = true
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	defaultRange := mergeBase + ".." + res.DefaultHeadSHA
+
+	branchDiff, err := s.branchPatch(ctx, mergeBase, res.HeadSHA)
+	if err != nil {
+		return "", err
+	}
+	branchPatchIDs, err := s.patchIDs(ctx, branchDiff)
+	if err != nil {
+		return "", err
+	}
+	defaultLog, err := s.gitOutput(ctx, []string{"log", "-p", "--no-color", "--no-ext-diff", "--no-merges", "--no-abbrev-commit", defaultRange})
+	if err != nil {
+		return "", err
+	}
+	defaultPatchIDs, err := s.patchIDs(ctx, defaultLog)
+	if err != nil {
+		return "", err
+	}
+	for patchID := range branchPatchIDs {
+		for _, c := range defaultPatchIDs[patchID] {
+			if candidates[c] {
+				return c, nil
+			}
+		}
+	}
+
+	// fallback to author and files heuristics
+	authors := map[string]bool{}
+	for h := range reachable(gr, res.HeadSHA) {
+		if !reachableFromDefault[h] {
+			authors[s.commits[h].AuthorEmail] = true
+		}
+	}
+	branchFilesData, err := s.gitOutput(ctx, []string{"diff", "--name-only", mergeBase, res.HeadSHA})
+	if err != nil {
+		return "", err
+	}
+	branchFiles := sortedLines(branchFilesData)
+	if len(branchFiles) == 0 {
+		return "", nil
+	}
+	defaultFiles, err := s.filesByCommit(ctx, defaultRange)
+	if err != nil {
+		return "", err
+	}
+	branchAdded := addedLines(branchDiff)[""]
+	defaultAdded := addedLines(defaultLog)
+	var matches []string
+	for c, files := range defaultFiles {
+		if !candidates[c] || !authors[s.commits[c].AuthorEmail] {
+			continue
+		}
+		if strings.Join(files, "\n") != strings.Join(branchFiles, "\n") {
+			continue
+		}
+		if !isSubset(branchAdded, defaultAdded[c]) {
+			continue
+		}
+		matches = append(matches, c)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	// prefer the oldest matching commit
+	sort.Slice(matches, func(i, j int) bool {
+		return s.commits[matches[i]].CommitterTime.Before(s.commits[matches[j]].CommitterTime)
+	})
+	return matches[0], nil
+}
+
+func (s *Process) gitOutput(ctx context.Context, args []string) ([]byte, error) {
+	r, err := gitexec.Exec(ctx, "git", s.opts.RepoDir, args)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// branchPatch returns the same patch as git diff from to. Uses a single git diff-tree process for all branches.
+func (s *Process) branchPatch(ctx context.Context, from, to string) ([]byte, error) {
+	if s.diffTree == nil {
+		dt, err := gitexec.NewDiffTree(ctx, "git", s.opts.RepoDir, "-p", "--no-ext-diff")
+		if err != nil {
+			return nil, err
+		}
+		s.diffTree = dt
+	}
+	return s.diffTree.Diff(from, to)
+}
+
+func (s *Process) closeDiffTree() {
+	if s.diffTree == nil {
+		return
+	}
+	err := s.diffTree.Close()
+	if err != nil {
+		s.opts.Logger.Error("branchdiff: could not stop git diff-tree", "err", err)
+	}
+	s.diffTree = nil
+}
+
+// patchIDs runs git patch-id on passed patch data and returns map[patchID][]commit
+func (s *Process) patchIDs(ctx context.Context, patch []byte) (map[string][]string, error) {
+	res := map[string][]string{}
+	if len(patch) == 0 {
+		return res, nil
+	}
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriterWithStdin(ctx, out, bytes.NewReader(patch), "git", s.opts.RepoDir, []string{"patch-id", "--stable"})
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range sortedLines(out.Bytes()) {
+		parts := strings.Split(line, " ")
+		if len(parts) != 2 {
+			continue
+		}
+		res[parts[0]] = append(res[parts[0]], parts[1])
+	}
+	return res, nil
+}
+
+const filesCommitPrefix = "@@@"
+
+// filesByCommit returns map[commit]sortedFiles for non-merge commits in range
+func (s *Process) filesByCommit(ctx context.Context, rangeSpec string) (map[string][]string, error) {
+	data, err := s.gitOutput(ctx, []string{"log", "--no-merges", "--no-abbrev-commit", "--name-only", "--pretty=format:" + filesCommitPrefix + "%H", rangeSpec})
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]string{}
+	commit := ""
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		l := strings.TrimSpace(string(line))
+		if l == "" {
+			continue
+		}
+		if strings.HasPrefix(l, filesCommitPrefix) {
+			commit = l[len(filesCommitPrefix):]
+			res[commit] = nil
+			continue
+		}
+		res[commit] = append(res[commit], l)
+	}
+	for _, files := range res {
+		sort.Strings(files)
+	}
+	return res, nil
+}
+
+const logCommitPrefix = "commit "
+
+// addedLines returns map[commit]set of lines added in patch. Patch could be git diff output, in which case commit is empty, or git log -p output.
+func addedLines(patch []byte) map[string]map[string]bool {
+	res := map[string]map[string]bool{}
+	commit := ""
+	for _, line := range bytes.Split(patch, []byte("\n")) {
+		l := string(line)
+		if strings.HasPrefix(l, logCommitPrefix) {
+			commit = strings.TrimSpace(l[len(logCommitPrefix):])
+			continue
+		}
+		if !strings.HasPrefix(l, "+") || strings.HasPrefix(l, "+++") {
+			continue
+		}
+		if res[commit] == nil {
+			res[commit] = map[string]bool{}
+		}
+		res[commit][l[1:]] = true
+	}
+	return res
+}
+
+func isSubset(a, b map[string]bool) bool {
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedLines(data []byte) (res []string) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		l := strings.TrimSpace(string(line))
+		if l == "" {
+			continue
+		}
+		res = append(res, l)
+	}
+	sort.Strings(res)
+	return
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchdiff"
+)
+
+func TestBehindMaster1(t *testing.T) {
+	test := NewTest(t, "behindmaster1", nil)
+	got := test.Run()
+
+	c1 := "33e223d1fd8393dc98596727d370e51e7b3b7fba"
+	c2 := "9b39087654af70197f68d0b3d196a4a20d987cd6"
+	c3 := "75ba0ade334c14cf010353a1473656511d12b02f"
+	c4 := "e938b9fbb1e6dbfb62d001e12e106d6746385851"
+
+	want := []branchdiff.BranchDiff{
+		{
+			Name:            "a",
+			HeadSHA:         c2,
+			DefaultHeadSHA:  c4,
+			AheadCount:      1,
+			BehindCount:     2,
+			MergeBases:      []string{c1},
+			FirstCommitTime: parseTime("2019-02-07T20:17:34+01:00"),
+			LastCommitTime:  parseTime("2019-02-07T20:17:34+01:00"),
+			// c3 on master has the same changes as c2
+			MergeStatus:       branchdiff.MergeStatusSquashMerged,
+			SquashMergeCommit: c3,
+		},
+	}
+	assertResult(t, want, got)
+}
+
+func TestMerged1(t *testing.T) {
+	test := NewTest(t, "merged1", nil)
+	got := test.Run()
+
+	c2 := "ac22dfb85417e3d256baeb62fc8b51e33b61ac27"
+	c3 := "5ac62691bf584ecee16eb832a4c444aab74d2d27"
+
+	want := []branchdiff.BranchDiff{
+		{
+			Name:           "a",
+			HeadSHA:        c2,
+			DefaultHeadSHA: c3,
+			AheadCount:     0,
+			BehindCount:    1,
+			MergeBases:     []string{c2},
+			LastCommitTime: parseTime("2019-02-08T18:36:42+01:00"),
+			IsMerged:       true,
+			MergeCommit:    c3,
+			MergeStatus:    branchdiff.MergeStatusMerged,
+		},
+	}
+	assertResult(t, want, got)
+}
+
+func TestSquashMerged1(t *testing.T) {
+	test := NewTest(t, "squash1", nil)
+	got := test.Run()
+
+	c1 := "130db0563739b8da357e33f61ba2bb164dd92560"
+	a2 := "3eb4eaa79aafd962e9ea9b383a418d2872b7a750"
+	b1 := "9779c916364f9e80780ff1223e4761cdd583696a"
+	squashed := "e1fc70b759027436c7e3d420eb02c9971f387b41"
+
+	want := []branchdiff.BranchDiff{
+		{
+			Name:              "a",
+			HeadSHA:           a2,
+			DefaultHeadSHA:    squashed,
+			AheadCount:        2,
+			BehindCount:       2,
+			MergeBases:        []string{c1},
+			FirstCommitTime:   parseTime("2019-01-01T01:02:00+01:00"),
+			LastCommitTime:    parseTime("2019-01-01T01:03:00+01:00"),
+			MergeStatus:       branchdiff.MergeStatusSquashMerged,
+			SquashMergeCommit: squashed,
+		},
+		{
+			Name:            "b",
+			HeadSHA:         b1,
+			DefaultHeadSHA:  squashed,
+			AheadCount:      1,
+			BehindCount:     2,
+			MergeBases:      []string{c1},
+			FirstCommitTime: parseTime("2019-01-01T01:04:00+01:00"),
+			LastCommitTime:  parseTime("2019-01-01T01:04:00+01:00"),
+			MergeStatus:     branchdiff.MergeStatusAbandoned,
+		},
+	}
+	assertResult(t, want, got)
+}
+
+func TestOpenBranch(t *testing.T) {
+	test := NewTest(t, "squash1", &branchdiff.Opts{
+		Branch:         "b",
+		AbandonedAfter: 100 * 365 * 24 * time.Hour,
+	})
+	got := test.Run()
+	if len(got) != 1 || got[0].MergeStatus != branchdiff.MergeStatusOpen {
+		t.Fatalf("expected open branch b, got %+v", got)
+	}
+}
+
+func TestSingleBranch(t *testing.T) {
+	test := NewTest(t, "behindmaster1", &branchdiff.Opts{Branch: "a"})
+	got := test.Run()
+	if len(got) != 1 || got[0].Name != "a" {
+		t.Fatalf("expected only branch a, got %+v", got)
+	}
+}
+
+func parseTime(s string) time.Time {
+	res, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
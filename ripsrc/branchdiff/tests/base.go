@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchdiff"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type Test struct {
+	t        *testing.T
+	repoName string
+	opts     *branchdiff.Opts
+}
+
+func NewTest(t *testing.T, repoName string, opts *branchdiff.Opts) *Test {
+	s := &Test{}
+	s.t = t
+	s.repoName = repoName
+	s.opts = opts
+	return s
+}
+
+func (s *Test) Run() []branchdiff.BranchDiff {
+	t := s.t
+	dirs := testutil.UnzipTestRepo(s.repoName)
+	defer dirs.Remove()
+
+	ctx := context.Background()
+	repoDir := dirs.RepoDir
+	log := logger.NewDefaultLogger(os.Stdout)
+	gitexec.Prepare(ctx, "git", repoDir)
+
+	commitGraph := parentsgraph.New(parentsgraph.Opts{
+		RepoDir:     repoDir,
+		AllBranches: true,
+		Logger:      log,
+	})
+	err := commitGraph.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := branchdiff.Opts{}
+	if s.opts != nil {
+		opts = *s.opts
+	}
+	opts.Logger = log
+	opts.RepoDir = repoDir
+	opts.CommitGraph = commitGraph
+	res, err := branchdiff.New(opts).RunSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func assertResult(t *testing.T, want, got []branchdiff.BranchDiff) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("invalid result count, wanted %v, got %v", len(want), len(got))
+	}
+	for i := range want {
+		w := want[i]
+		g := got[i]
+		if !w.FirstCommitTime.Equal(g.FirstCommitTime) || !w.LastCommitTime.Equal(g.LastCommitTime) {
+			t.Errorf("invalid commit times, wanted %v %v got %v %v", w.FirstCommitTime, w.LastCommitTime, g.FirstCommitTime, g.LastCommitTime)
+		}
+		w.FirstCommitTime, w.LastCommitTime = g.FirstCommitTime, g.LastCommitTime
+		assert.Equal(t, w, g)
+	}
+}
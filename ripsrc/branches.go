@@ -5,32 +5,74 @@ import (
 	"errors"
 
 	"github.com/pinpt/ripsrc/ripsrc/branches2"
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
 )
 
 // Branch contains information about the branch and commits on that branch.
 type Branch = branches2.Branch
 
-func (s *Ripsrc) Branches(ctx context.Context, res chan Branch) error {
+// PullRequest is a named pull request ref passed in Opts.PullRequests.
+type PullRequest = branches2.PullRequest
+
+func (s *Ripsrc) Branches(ctx context.Context, res chan Branch) (rerr error) {
 	defer close(res)
+	defer s.timings.track()()
+	defer func() {
+		rerr = ctxErr(ctx, rerr)
+	}()
+	ctx = s.gitContext(ctx)
 	if !s.opts.AllBranches {
 		return errors.New("Branches call is only allowed when AllBranches=true")
 	}
 
 	err := s.prepareGitExec(ctx)
+	if errors.Is(err, ErrEmptyRepo) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	var tips string
+	if s.branchCache != nil {
+		tips, err = branchmeta.Tips(ctx, s.opts.RepoDir)
+		if err != nil {
+			return err
+		}
+		if tips == s.branchesResult.tips {
+			for _, r := range s.branchesResult.res {
+				select {
+				case res <- r:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+		if s.branchesResult.tips != "" {
+			// refs moved since the graph was built
+			s.commitGraph = nil
+		}
+	}
+
 	err = s.buildCommitGraph(ctx)
 	if err != nil {
 		return err
 	}
 
+	var all []Branch
 	res2 := make(chan Branch)
 	done := make(chan bool)
 	go func() {
 		for r := range res2 {
-			res <- r
+			if s.branchCache != nil {
+				all = append(all, r)
+			}
+			// keep reading until Run exits, it returns ctx error
+			select {
+			case res <- r:
+			case <-ctx.Done():
+			}
 		}
 		done <- true
 	}()
@@ -41,10 +83,23 @@ func (s *Ripsrc) Branches(ctx context.Context, res chan Branch) error {
 	opts.RepoDir = s.opts.RepoDir
 	opts.IncludeDefaultBranch = true
 	opts.PullRequestSHAs = s.opts.PullRequestSHAs
+	opts.PullRequests = s.opts.PullRequests
+	opts.BranchFilter = s.branchSelected
 	pr := branches2.New(opts)
 	err = pr.Run(ctx, res2)
 	<-done
-	return err
+	if err == nil {
+		// results dropped by the goroutine above on cancel
+		err = ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if s.branchCache != nil {
+		s.branchesResult.tips = tips
+		s.branchesResult.res = all
+	}
+	return nil
 }
 
 func (s *Ripsrc) BranchesSlice(ctx context.Context) (res []Branch, _ error) {
@@ -60,3 +115,52 @@ func (s *Ripsrc) BranchesSlice(ctx context.Context) (res []Branch, _ error) {
 	<-done
 	return res, err
 }
+
+// DefaultBranch is the default branch of the repo with the method used to detect it.
+type DefaultBranch = branchmeta.Branch
+
+// DefaultBranchMethod is the way the default branch was detected.
+type DefaultBranchMethod = branchmeta.DefaultMethod
+
+const (
+	// DefaultBranchMethodHead means HEAD points to the branch.
+	DefaultBranchMethodHead = branchmeta.DefaultMethodHead
+	// DefaultBranchMethodOriginHead means HEAD is detached and the branch is the one origin/HEAD points to.
+	DefaultBranchMethodOriginHead = branchmeta.DefaultMethodOriginHead
+	// DefaultBranchMethodInitConfig means HEAD is detached and the branch is set in init.defaultBranch config.
+	DefaultBranchMethodInitConfig = branchmeta.DefaultMethodInitConfig
+	// DefaultBranchMethodHeuristic means HEAD is detached and main or master branch exists.
+	DefaultBranchMethodHeuristic = branchmeta.DefaultMethodHeuristic
+)
+
+// DefaultBranch returns the default branch of the repo used by processing. Works on detached HEAD checkouts, see DefaultBranch.Method for how it was detected.
+func (s *Ripsrc) DefaultBranch(ctx context.Context) (res DefaultBranch, _ error) {
+	ctx = s.gitContext(ctx)
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return res, err
+	}
+	return s.getDefaultBranch(ctx)
+}
+
+// getDefaultBranch returns the default branch, cached with Opts.CacheBranches.
+func (s *Ripsrc) getDefaultBranch(ctx context.Context) (branchmeta.Branch, error) {
+	if s.branchCache != nil {
+		return s.branchCache.GetDefault(ctx, s.opts.RepoDir)
+	}
+	return branchmeta.GetDefault(ctx, s.opts.RepoDir)
+}
+
+// getBranches returns all branches including the default one, cached with Opts.CacheBranches.
+func (s *Ripsrc) getBranches(ctx context.Context) ([]branchmeta.BranchWithCommitTime, error) {
+	opts := branchmeta.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		UseOrigin:      s.opts.BranchesUseOrigin,
+		IncludeDefault: true,
+	}
+	if s.branchCache != nil {
+		return s.branchCache.Get(ctx, opts)
+	}
+	return branchmeta.Get(ctx, opts)
+}
@@ -3,14 +3,18 @@ package branches2
 import (
 	"bytes"
 	"context"
-	"os/exec"
 
 	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 )
 
 type nameAndHash struct {
 	Name   string
 	Commit string
+
+	// PullRequestID and BaseSHA are only set for passed pull requests
+	PullRequestID string
+	BaseSHA       string
 }
 
 type namesAndHashes []nameAndHash
@@ -26,27 +30,27 @@ func (s namesAndHashes) Chan() chan nameAndHash {
 	return res
 }
 
-func (s *Process) getNamesAndHashes() (res namesAndHashes, _ error) {
+func (s *Process) getNamesAndHashes(ctx context.Context) (res namesAndHashes, _ error) {
 	opts := branchmeta.Opts{}
 	opts.Logger = s.opts.Logger
 	opts.RepoDir = s.opts.RepoDir
 	opts.UseOrigin = s.opts.UseOrigin
-	res0, err := branchmeta.Get(context.Background(), opts)
+	res0, err := branchmeta.Get(ctx, opts)
 	if err != nil {
 		return res, err
 	}
 	for _, item := range res0 {
+		if s.opts.BranchFilter != nil && !s.opts.BranchFilter(item.Name) {
+			continue
+		}
 		res = append(res, nameAndHash{Name: item.Name, Commit: item.Commit})
 	}
 	return res, nil
 }
 
-func execCommand(command string, dir string, args []string) ([]byte, error) {
+func execCommand(ctx context.Context, command string, dir string, args []string) ([]byte, error) {
 	out := bytes.NewBuffer(nil)
-	c := exec.Command(command, args...)
-	c.Dir = dir
-	c.Stdout = out
-	err := c.Run()
+	err := gitexec.ExecIntoWriter(ctx, out, command, dir, args)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +27,12 @@ type Branch struct {
 	// IsPullRequest is set to true if this is created based on passed pull request sha instead of repo branch.
 	IsPullRequest bool
 
+	// PullRequestID is the id of the passed pull request. Only set for pull requests passed in Opts.PullRequests.
+	PullRequestID string
+
+	// BaseSHA is the base commit that Commits and BehindDefaultCount were calculated against. Only set for pull requests with PullRequest.BaseSHA.
+	BaseSHA string
+
 	// HeadSHA is the sha of the head commit.
 	HeadSHA string
 
@@ -64,6 +70,22 @@ type Branch struct {
 
 	// FirstCommit is the first commit on this branch
 	FirstCommit string
+
+	// Orphan is true if the branch does not share history with default branch, for example gh-pages or docs branches created with git checkout --orphan.
+	// Commits are all commits of the branch and BranchedFromCommits is empty in this case. Orphan branches merged into default branch are returned with IsMerged instead.
+	Orphan bool
+}
+
+// PullRequest is a named virtual ref to process similar to branches returned from the repo.
+type PullRequest struct {
+	// ID of the pull request, returned in Branch.PullRequestID.
+	ID string
+	// HeadSHA is the head commit of the pull request.
+	HeadSHA string
+	// BaseSHA is optional commit the pull request is compared against, typically the head of target branch.
+	// If set, Commits and BehindDefaultCount are calculated against base instead of default branch.
+	// If not found in the commit graph, default branch is used.
+	BaseSHA string
 }
 
 type Opts struct {
@@ -81,8 +103,12 @@ type Opts struct {
 	UseOrigin bool
 	// PullRequestSHAs is a list of custom sha references to process similar to branches returned from the repo.
 	PullRequestSHAs []string
-	// PullRequestsOnly skips branch data output, only using passed PullRequestSHAs
+	// PullRequests is a list of named pull request refs to process similar to branches returned from the repo.
+	PullRequests []PullRequest
+	// PullRequestsOnly skips branch data output, only using passed PullRequestSHAs and PullRequests
 	PullRequestsOnly bool
+	// BranchFilter returns false for branches that should be skipped. Not applied to default branch and pull requests. Optional.
+	BranchFilter func(name string) bool
 }
 
 type Process struct {
@@ -91,6 +117,9 @@ type Process struct {
 	defaultBranch nameAndHash
 
 	reachableFromHead reachableFromHead
+
+	reachableFromBaseMu sync.Mutex
+	reachableFromBase   map[string]reachableFromHead
 }
 
 func New(opts Opts) *Process {
@@ -102,8 +131,8 @@ func New(opts Opts) *Process {
 	return s
 }
 
-func (s *Process) getFirstCommit() (string, error) {
-	buf, err := execCommand("git", s.opts.RepoDir, []string{"rev-list", "--max-parents=0", "HEAD"})
+func (s *Process) getFirstCommit(ctx context.Context) (string, error) {
+	buf, err := execCommand(ctx, "git", s.opts.RepoDir, []string{"rev-list", "--max-parents=0", "HEAD"})
 	if err != nil {
 		return "", err
 	}
@@ -120,17 +149,20 @@ func (s *Process) Run(ctx context.Context, res chan Branch) error {
 	s.defaultBranch = nameAndHash{Name: defaultBranch.Name, Commit: defaultBranch.Commit}
 
 	if !s.opts.PullRequestsOnly && s.opts.IncludeDefaultBranch {
-		firstCommit, err := s.getFirstCommit()
+		firstCommit, err := s.getFirstCommit(ctx)
 		if err != nil {
 			return err
 		}
-		res <- Branch{
+		err = send(ctx, res, Branch{
 			BranchID:    branchID(s.defaultBranch.Name, nil),
 			Name:        s.defaultBranch.Name,
 			HeadSHA:     s.defaultBranch.Commit,
 			IsDefault:   true,
 			Commits:     getAllCommits(s.opts.CommitGraph, s.defaultBranch.Commit),
 			FirstCommit: firstCommit,
+		})
+		if err != nil {
+			return err
 		}
 	}
 
@@ -139,7 +171,7 @@ func (s *Process) Run(ctx context.Context, res chan Branch) error {
 	var namesAndHashes namesAndHashes
 
 	if !s.opts.PullRequestsOnly {
-		namesAndHashes, err = s.getNamesAndHashes()
+		namesAndHashes, err = s.getNamesAndHashes(ctx)
 		if err != nil {
 			return err
 		}
@@ -148,6 +180,10 @@ func (s *Process) Run(ctx context.Context, res chan Branch) error {
 	for _, sha := range uniqueStrings(s.opts.PullRequestSHAs) {
 		namesAndHashes = append(namesAndHashes, nameAndHash{Commit: sha})
 	}
+	for _, pr := range uniquePullRequests(s.opts.PullRequests) {
+		namesAndHashes = append(namesAndHashes, nameAndHash{Commit: pr.HeadSHA, PullRequestID: pr.ID, BaseSHA: pr.BaseSHA})
+	}
+	s.reachableFromBase = map[string]reachableFromHead{}
 
 	workCh := namesAndHashes.Chan()
 	wg := sync.WaitGroup{}
@@ -162,7 +198,8 @@ func (s *Process) Run(ctx context.Context, res chan Branch) error {
 				err := lastErr
 				lastErrMu.Unlock()
 				if err != nil {
-					return
+					// keep reading, so that the goroutine sending work exits
+					continue
 				}
 				err = s.processBranch(ctx, nameAndHash, res)
 				if err != nil {
@@ -188,6 +225,18 @@ func uniqueStrings(arr1 []string) (res []string) {
 	return
 }
 
+func uniquePullRequests(arr1 []PullRequest) (res []PullRequest) {
+	m := map[PullRequest]bool{}
+	for _, pr := range arr1 {
+		if m[pr] {
+			continue
+		}
+		m[pr] = true
+		res = append(res, pr)
+	}
+	return
+}
+
 func getAllCommits(gr *parentsgraph.Graph, head string) (res []string) {
 	done := map[string]bool{}
 	var rec func(string)
@@ -228,7 +277,7 @@ func (s *Process) RunSlice(ctx context.Context) (res []Branch, _ error) {
 func (s *Process) processBranch(ctx context.Context, nameAndHash nameAndHash, resChan chan Branch) error {
 	name := nameAndHash.Name
 	if name == "" { // this is a passed pr
-		s.opts.Logger.Info("processing pr", "head", nameAndHash.Commit)
+		s.opts.Logger.Info("processing pr", "id", nameAndHash.PullRequestID, "head", nameAndHash.Commit, "base", nameAndHash.BaseSHA)
 	} else {
 		s.opts.Logger.Info("processing branch", "name", nameAndHash.Name, "commit", nameAndHash.Commit)
 	}
@@ -245,8 +294,22 @@ func (s *Process) processBranch(ctx context.Context, nameAndHash nameAndHash, re
 	}
 	res.HeadSHA = nameAndHash.Commit
 	res.Name = name
-	defaultHead := s.defaultBranch.Commit
-	res.Commits, res.BranchedFromCommits = branchCommits(gr, defaultHead, s.reachableFromHead, nameAndHash.Commit)
+	res.PullRequestID = nameAndHash.PullRequestID
+
+	// by default commits are calculated against default branch, pull requests with base use the base commit instead
+	compareHead := s.defaultBranch.Commit
+	compareReachable := s.reachableFromHead
+	if nameAndHash.BaseSHA != "" {
+		if _, ok := gr.Parents[nameAndHash.BaseSHA]; ok {
+			res.BaseSHA = nameAndHash.BaseSHA
+			compareHead = nameAndHash.BaseSHA
+			compareReachable = s.getReachableFromBase(nameAndHash.BaseSHA)
+		} else {
+			s.opts.Logger.Warn("pr base not found in the tree, using default branch", "id", nameAndHash.PullRequestID, "base", nameAndHash.BaseSHA)
+		}
+	}
+
+	res.Commits, res.BranchedFromCommits = branchCommits(gr, compareHead, compareReachable, nameAndHash.Commit)
 	if name != "" {
 		res.BranchID = branchID(res.Name, res.BranchedFromCommits)
 	}
@@ -255,13 +318,35 @@ func (s *Process) processBranch(ctx context.Context, nameAndHash nameAndHash, re
 		res.MergeCommit = getMergeCommit(gr, s.reachableFromHead, nameAndHash.Commit)
 	} else {
 		if len(res.BranchedFromCommits) >= 1 {
-			res.BehindDefaultCount = behindBranch(gr, s.reachableFromHead, nameAndHash.Commit, defaultHead)
+			res.BehindDefaultCount = behindBranch(gr, compareReachable, nameAndHash.Commit, compareHead)
 		}
 	}
+	res.Orphan = !res.IsMerged && len(res.BranchedFromCommits) == 0
 	res.AheadDefaultCount = len(res.Commits)
 	res.FirstCommit = res.Commits[0]
-	resChan <- res
-	return nil
+	return send(ctx, resChan, res)
+}
+
+// send sends branch to res, unless ctx is canceled first.
+func send(ctx context.Context, res chan Branch, b Branch) error {
+	select {
+	case res <- b:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getReachableFromBase returns commits reachable from pull request base. Cached, since multiple pull requests usually share the same base.
+func (s *Process) getReachableFromBase(base string) reachableFromHead {
+	s.reachableFromBaseMu.Lock()
+	defer s.reachableFromBaseMu.Unlock()
+	res, ok := s.reachableFromBase[base]
+	if !ok {
+		res = newReachableFromHead(s.opts.CommitGraph, base)
+		s.reachableFromBase[base] = res
+	}
+	return res
 }
 
 func branchID(name string, branchedFrom []string) string {
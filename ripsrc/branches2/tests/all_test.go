@@ -214,3 +214,65 @@ func TestPullRequestsNotExisting1(t *testing.T) {
 
 	assertResult(t, want, got)
 }
+
+func TestPullRequestsWithID1(t *testing.T) {
+
+	test := NewTest(t, "basic1", nil)
+	c1 := "33e223d1fd8393dc98596727d370e51e7b3b7fba"
+	c2 := "9b39087654af70197f68d0b3d196a4a20d987cd6"
+
+	test.opts = &branches2.Opts{}
+	test.opts.PullRequests = []branches2.PullRequest{
+		{ID: "pr1", HeadSHA: c2},
+	}
+	test.opts.PullRequestsOnly = true
+
+	got := test.Run()
+
+	want := []branches2.Branch{
+		{
+			IsPullRequest:       true,
+			PullRequestID:       "pr1",
+			HeadSHA:             c2,
+			Commits:             []string{c2},
+			BranchedFromCommits: []string{c1},
+			BehindDefaultCount:  0,
+			AheadDefaultCount:   1,
+			FirstCommit:         c2,
+		},
+	}
+
+	assertResult(t, want, got)
+}
+
+func TestPullRequestsWithBase1(t *testing.T) {
+
+	test := NewTest(t, "behindmaster1", nil)
+	c1 := "33e223d1fd8393dc98596727d370e51e7b3b7fba"
+	c2 := "9b39087654af70197f68d0b3d196a4a20d987cd6"
+	c3 := "75ba0ade334c14cf010353a1473656511d12b02f"
+
+	test.opts = &branches2.Opts{}
+	test.opts.PullRequests = []branches2.PullRequest{
+		{ID: "pr1", HeadSHA: c2, BaseSHA: c3},
+	}
+	test.opts.PullRequestsOnly = true
+
+	got := test.Run()
+
+	want := []branches2.Branch{
+		{
+			IsPullRequest:       true,
+			PullRequestID:       "pr1",
+			BaseSHA:             c3,
+			HeadSHA:             c2,
+			Commits:             []string{c2},
+			BranchedFromCommits: []string{c1},
+			BehindDefaultCount:  1,
+			AheadDefaultCount:   1,
+			FirstCommit:         c2,
+		},
+	}
+
+	assertResult(t, want, got)
+}
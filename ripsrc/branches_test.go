@@ -0,0 +1,59 @@
+package ripsrc
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBranchesCache(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("b1").Write("b.txt", "b\n").Commit("c2")
+	r.Checkout("master")
+
+	ctx := context.Background()
+	rip := New(Opts{RepoDir: r.Dir(), AllBranches: true, CacheBranches: true})
+	names := func() (res []string) {
+		t.Helper()
+		branches, err := rip.BranchesSlice(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range branches {
+			res = append(res, b.Name+":"+b.HeadSHA)
+		}
+		sort.Strings(res)
+		return
+	}
+	first := names()
+	tips, err := branchmeta.Tips(ctx, r.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rip.branchesResult.tips != tips {
+		t.Fatal("expected results to be cached for current ref tips")
+	}
+	if second := names(); len(second) != 2 || second[0] != first[0] || second[1] != first[1] {
+		t.Fatalf("cached result differs, first %v second %v", first, second)
+	}
+
+	// new branch changes ref tips
+	c3 := r.Branch("b2").Write("c.txt", "c\n").Commit("c3")
+	r.Checkout("master")
+	got := names()
+	if len(got) != 3 || got[1] != "b2:"+c3 {
+		t.Fatalf("expected new branch after refs changed, got %v", got)
+	}
+	def, err := rip.DefaultBranch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Name != "master" {
+		t.Fatalf("unexpected default branch %+v", def)
+	}
+}
@@ -3,12 +3,11 @@ package branchmeta
 import (
 	"bytes"
 	"context"
-	"errors"
-	"os/exec"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 	"github.com/pinpt/ripsrc/ripsrc/gittime"
 
 	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
@@ -28,7 +27,7 @@ type BranchWithCommitTime struct {
 }
 
 func Get(ctx context.Context, opts Opts) (res []BranchWithCommitTime, _ error) {
-	defaultBranch, err := getDefaultBranch(opts)
+	defaultBranch, err := getDefaultBranch(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +41,7 @@ func Get(ctx context.Context, opts Opts) (res []BranchWithCommitTime, _ error) {
 	} else {
 		args = append(args, "refs/heads")
 	}
-	data, err := execCommand("git", opts.RepoDir, args)
+	data, err := execCommand(ctx, "git", opts.RepoDir, args)
 	if err != nil {
 		return nil, err
 	}
@@ -92,29 +91,17 @@ func Get(ctx context.Context, opts Opts) (res []BranchWithCommitTime, _ error) {
 	return
 }
 
-func getDefaultBranch(opts Opts) (string, error) {
-	args := []string{
-		"symbolic-ref",
-		"--short",
-		"HEAD",
-	}
-	data, err := execCommand("git", opts.RepoDir, args)
+func getDefaultBranch(ctx context.Context, opts Opts) (string, error) {
+	b, err := GetDefault(ctx, opts.RepoDir)
 	if err != nil {
 		return "", err
 	}
-	res := strings.TrimSpace(string(data))
-	if len(res) == 0 {
-		return "", errors.New("could not get the default branch name")
-	}
-	return res, nil
+	return b.Name, nil
 }
 
-func execCommand(command string, dir string, args []string) ([]byte, error) {
+func execCommand(ctx context.Context, command string, dir string, args []string) ([]byte, error) {
 	out := bytes.NewBuffer(nil)
-	c := exec.Command(command, args...)
-	c.Dir = dir
-	c.Stdout = out
-	err := c.Run()
+	err := gitexec.ExecIntoWriter(ctx, out, command, dir, args)
 	if err != nil {
 		return nil, err
 	}
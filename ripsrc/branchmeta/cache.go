@@ -0,0 +1,108 @@
+package branchmeta
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+)
+
+// Tips returns a key identifying the current ref tips of the repo, including what HEAD points to. It changes whenever a ref is created, deleted or moved, or HEAD is switched to a different branch.
+// Only runs two cheap git commands, so it could be checked before every query.
+func Tips(ctx context.Context, repoDir string) (string, error) {
+	refs, err := execCommand(ctx, "git", repoDir, []string{"for-each-ref", "--format=%(refname) %(objectname) %(symref)"})
+	if err != nil {
+		return "", err
+	}
+	// prints HEAD commit and then the branch it points to, or HEAD if detached
+	head, err := execCommand(ctx, "git", repoDir, []string{"rev-parse", "HEAD", "--symbolic-full-name", "HEAD"})
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write(refs)
+	h.Write([]byte{0})
+	h.Write(head)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Cache keeps results of Get and GetDefault until ref tips change, see Tips. Changes of init.defaultBranch config are not detected.
+// Safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	tips     string
+	branches map[cacheKey][]BranchWithCommitTime
+	def      *Branch
+}
+
+type cacheKey struct {
+	useOrigin      bool
+	includeDefault bool
+}
+
+// NewCache creates an empty cache. Use a separate cache for each repo.
+func NewCache() *Cache {
+	s := &Cache{}
+	s.branches = map[cacheKey][]BranchWithCommitTime{}
+	return s
+}
+
+// check clears the cache if ref tips changed
+func (s *Cache) check(ctx context.Context, repoDir string) error {
+	tips, err := Tips(ctx, repoDir)
+	if err != nil {
+		return err
+	}
+	if tips != s.tips {
+		s.tips = tips
+		s.branches = map[cacheKey][]BranchWithCommitTime{}
+		s.def = nil
+	}
+	return nil
+}
+
+// Get is the same as Get, but returns cached result if ref tips did not change.
+func (s *Cache) Get(ctx context.Context, opts Opts) (res []BranchWithCommitTime, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.check(ctx, opts.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey{useOrigin: opts.UseOrigin, includeDefault: opts.IncludeDefault}
+	if cached, ok := s.branches[key]; ok {
+		return append(res, cached...), nil
+	}
+	res, err = Get(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.branches[key] = append([]BranchWithCommitTime{}, res...)
+	return res, nil
+}
+
+// GetDefault is the same as GetDefault, but returns cached result if ref tips did not change.
+func (s *Cache) GetDefault(ctx context.Context, repoDir string) (res Branch, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.check(ctx, repoDir)
+	if err != nil {
+		return res, err
+	}
+	if s.def != nil {
+		return *s.def, nil
+	}
+	res, err = GetDefault(ctx, repoDir)
+	if err != nil {
+		return res, err
+	}
+	s.def = &res
+	return res, nil
+}
+
+// Tips returns the ref tips key of the last query, empty if there was none.
+func (s *Cache) Tips() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tips
+}
@@ -1,45 +1,107 @@
 package branchmeta
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 )
 
+// DefaultMethod is the way the default branch was detected.
+type DefaultMethod string
+
+const (
+	// DefaultMethodHead means HEAD points to the branch. Used for normal checkouts.
+	DefaultMethodHead = DefaultMethod("head")
+	// DefaultMethodOriginHead means HEAD is detached and the branch is the one refs/remotes/origin/HEAD points to.
+	DefaultMethodOriginHead = DefaultMethod("origin-head")
+	// DefaultMethodInitConfig means HEAD is detached and the branch is the one set in init.defaultBranch config.
+	DefaultMethodInitConfig = DefaultMethod("init-config")
+	// DefaultMethodHeuristic means HEAD is detached and the branch is the first existing of defaultNames.
+	DefaultMethodHeuristic = DefaultMethod("heuristic")
+)
+
+// defaultNames are checked in order when no other method found the default branch.
+var defaultNames = []string{"main", "master"}
+
 type Branch struct {
 	Name   string
 	Commit string
+	// Method is how the default branch was detected.
+	Method DefaultMethod
 }
 
+// GetDefault returns the default branch of the repo. When HEAD points to a branch, returns it with HEAD commit.
+// On detached HEAD, common in CI checkouts, falls back to origin/HEAD, then init.defaultBranch config, then main or master, using the first branch that exists locally or in origin. Commit is the tip of the found branch in this case.
+// Returns error wrapping gitexec.ErrDetachedHead if none of the methods found a branch.
 func GetDefault(ctx context.Context, repoDir string) (res Branch, _ error) {
-
 	name, err := headBranch(ctx, "git", repoDir)
+	if err == nil {
+		commit, err := headCommit(ctx, "git", repoDir)
+		if err != nil {
+			return res, err
+		}
+		res.Name = name
+		res.Commit = commit
+		res.Method = DefaultMethodHead
+		return res, nil
+	}
+	if !errors.Is(err, gitexec.ErrDetachedHead) {
+		return res, err
+	}
+
+	try := func(name string, method DefaultMethod) (bool, error) {
+		if name == "" {
+			return false, nil
+		}
+		commit, err := branchCommit(ctx, "git", repoDir, name)
+		if err != nil || commit == "" {
+			return false, err
+		}
+		res.Name = name
+		res.Commit = commit
+		res.Method = method
+		return true, nil
+	}
+	originHead, err := originHeadBranch(ctx, "git", repoDir)
 	if err != nil {
 		return res, err
 	}
-	commit, err := headCommit(ctx, "git", repoDir)
+	if ok, err := try(originHead, DefaultMethodOriginHead); ok || err != nil {
+		return res, err
+	}
+	initDefault, err := configValue(ctx, "git", repoDir, "init.defaultBranch")
 	if err != nil {
 		return res, err
 	}
-	res.Name = name
-	res.Commit = commit
-	return res, nil
+	if ok, err := try(initDefault, DefaultMethodInitConfig); ok || err != nil {
+		return res, err
+	}
+	for _, name := range defaultNames {
+		if ok, err := try(name, DefaultMethodHeuristic); ok || err != nil {
+			return res, err
+		}
+	}
+	return res, fmt.Errorf("cound not retrieve the name of the default branch: %w", gitexec.ErrDetachedHead)
 }
 
 func headBranch(ctx context.Context, gitCommand string, repoDir string) (string, error) {
-	data, err := execCommand(gitCommand, repoDir, []string{"rev-parse", "--abbrev-ref", "HEAD"})
+	data, err := execCommand(ctx, gitCommand, repoDir, []string{"rev-parse", "--abbrev-ref", "HEAD"})
 	if err != nil {
 		return "", err
 	}
 	res := strings.TrimSpace(string(data))
 	if res == "HEAD" {
-		return "", errors.New("cound not retrieve the name of the default branch")
+		return "", fmt.Errorf("cound not retrieve the name of the default branch: %w", gitexec.ErrDetachedHead)
 	}
 	return res, nil
 }
 
 func headCommit(ctx context.Context, gitCommand string, repoDir string) (string, error) {
-	data, err := execCommand(gitCommand, repoDir, []string{"rev-parse", "HEAD"})
+	data, err := execCommand(ctx, gitCommand, repoDir, []string{"rev-parse", "HEAD"})
 	if err != nil {
 		return "", err
 	}
@@ -49,3 +111,45 @@ func headCommit(ctx context.Context, gitCommand string, repoDir string) (string,
 	}
 	return res, nil
 }
+
+// originHeadBranch returns the branch refs/remotes/origin/HEAD points to, without origin/ prefix. Returns empty string if it is not set.
+func originHeadBranch(ctx context.Context, gitCommand string, repoDir string) (string, error) {
+	data, err := execCommand(ctx, gitCommand, repoDir, []string{"for-each-ref", "--format=%(symref:short)", "refs/remotes/origin/HEAD"})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), "origin/"), nil
+}
+
+// configValue returns the value of config key or empty string if it is not set.
+func configValue(ctx context.Context, gitCommand string, repoDir string, key string) (string, error) {
+	// git config --get exits with 1 for missing keys, --default avoids treating it as error
+	data, err := execCommand(ctx, gitCommand, repoDir, []string{"config", "--default", "", "--get", key})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// branchCommit returns the tip of local branch, or origin/ branch if there is no local one. Returns empty string if neither exists.
+func branchCommit(ctx context.Context, gitCommand string, repoDir string, name string) (string, error) {
+	data, err := execCommand(ctx, gitCommand, repoDir, []string{"for-each-ref", "--format=%(refname) %(objectname)", "refs/heads/" + name, "refs/remotes/origin/" + name})
+	if err != nil {
+		return "", err
+	}
+	var res string
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		parts := strings.Fields(string(line))
+		if len(parts) != 2 {
+			continue
+		}
+		// patterns also match refs below name, for example refs/heads/main/x
+		switch parts[0] {
+		case "refs/heads/" + name:
+			return parts[1], nil
+		case "refs/remotes/origin/" + name:
+			res = parts[1]
+		}
+	}
+	return res, nil
+}
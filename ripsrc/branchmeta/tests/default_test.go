@@ -0,0 +1,56 @@
+package e2etests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestGetDefaultFallbacks(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Branch("dev").Write("a.txt", "b\n").Commit("c2")
+	r.Checkout("master")
+
+	get := func() branchmeta.Branch {
+		t.Helper()
+		res, err := branchmeta.GetDefault(context.Background(), r.Dir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	assert := func(want branchmeta.Branch) {
+		t.Helper()
+		got := get()
+		if got != want {
+			t.Fatalf("wanted %+v got %+v", want, got)
+		}
+	}
+
+	assert(branchmeta.Branch{Name: "master", Commit: c1, Method: branchmeta.DefaultMethodHead})
+
+	// detached checkout of another commit, as in CI
+	r.Checkout(c2)
+	assert(branchmeta.Branch{Name: "master", Commit: c1, Method: branchmeta.DefaultMethodHeuristic})
+
+	r.Git("config", "init.defaultBranch", "dev")
+	assert(branchmeta.Branch{Name: "dev", Commit: c2, Method: branchmeta.DefaultMethodInitConfig})
+
+	r.Git("update-ref", "refs/remotes/origin/trunk", c1)
+	r.Git("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/trunk")
+	assert(branchmeta.Branch{Name: "trunk", Commit: c1, Method: branchmeta.DefaultMethodOriginHead})
+
+	r.Git("symbolic-ref", "-d", "refs/remotes/origin/HEAD")
+	r.Git("config", "--unset", "init.defaultBranch")
+	r.Git("branch", "-m", "master", "other")
+	_, err := branchmeta.GetDefault(context.Background(), r.Dir())
+	if !errors.Is(err, gitexec.ErrDetachedHead) {
+		t.Fatalf("expected ErrDetachedHead, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCancelStopsPipeline(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	for i := 0; i < 20; i++ {
+		r.Write(fmt.Sprintf("f%v.txt", i), strings.Repeat("line\n", i+1)).Commit(fmt.Sprintf("c%v", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	res := make(chan CommitCode)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- New(Opts{RepoDir: r.Dir()}).CodeByCommit(ctx, res)
+	}()
+
+	// read one commit, then cancel and stop reading without draining
+	<-res
+	cancel()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("CodeByCommit did not return after cancel")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		children, ok := childProcesses()
+		if !ok || len(children) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("git processes still running after cancel: %v", children)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCancelBranches(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	for i := 0; i < 5; i++ {
+		r.Checkout("master").Branch(fmt.Sprintf("b%v", i)).Write("b.txt", fmt.Sprintf("b%v\n", i)).Commit("c")
+	}
+	r.Checkout("master")
+	opts := Opts{RepoDir: r.Dir(), AllBranches: true}
+
+	// git commands killed on cancel return ctx error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New(opts).BranchesSlice(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// read one branch, then cancel and stop reading without draining
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	res := make(chan Branch)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- New(opts).Branches(ctx, res)
+	}()
+	<-res
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Branches did not return after cancel")
+	}
+}
+
+// childProcesses returns commands of running child processes. Returns false if /proc is not available.
+func childProcesses() (res []string, ok bool) {
+	dirs, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil || len(dirs) == 0 {
+		return nil, false
+	}
+	pid := os.Getpid()
+	for _, loc := range dirs {
+		b, err := ioutil.ReadFile(loc)
+		if err != nil {
+			// process exited
+			continue
+		}
+		// pid (comm) state ppid ...
+		stat := string(b)
+		i := strings.LastIndex(stat, ")")
+		if i == -1 {
+			continue
+		}
+		fields := strings.Fields(stat[i+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		// zombies were already waited for or will be reaped, only running processes matter
+		if ppid == pid && fields[0] != "Z" {
+			res = append(res, stat[:i+1])
+		}
+	}
+	return res, true
+}
@@ -0,0 +1,163 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// CheckpointCompression is a codec for checkpoint files, see Opts.CheckpointCompression.
+type CheckpointCompression = repo.Compression
+
+// RegisterCheckpointCompression adds compression that could be selected using Opts.CheckpointCompression, for example zstd.
+// Registered compressions are also detected when reading checkpoints, so register it before reading checkpoints written with it.
+func RegisterCheckpointCompression(c CheckpointCompression) {
+	repo.RegisterCompression(c)
+}
+
+// checkpointCompression returns compression selected in opts. Name is checked in Validate.
+func (s *Ripsrc) checkpointCompression() repo.Compression {
+	c, err := repo.CompressionByName(s.opts.CheckpointCompression)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// RecompressCheckpoints rewrites existing checkpoints for the repo using Opts.CheckpointCompression. Use it to migrate checkpoints written with a different compression.
+func (s *Ripsrc) RecompressCheckpoints(ctx context.Context) error {
+	err := s.opts.validate(ctx)
+	if err != nil {
+		return err
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return err
+	}
+	p := process.New(process.Opts{
+		Logger:                s.opts.Logger,
+		RepoDir:               s.opts.RepoDir,
+		CheckpointsDir:        checkpointsDir,
+		CheckpointCompression: s.checkpointCompression(),
+	})
+	return p.RecompressCheckpoints()
+}
+
+// MigrateCheckpoints upgrades existing checkpoints for the repo written by older versions of ripsrc to the current format. Reading checkpoints in older format fails with an error asking to migrate.
+func (s *Ripsrc) MigrateCheckpoints(ctx context.Context) error {
+	err := s.opts.validate(ctx)
+	if err != nil {
+		return err
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return err
+	}
+	p := process.New(process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	})
+	return p.MigrateCheckpoints()
+}
+
+// CheckpointInfo describes incremental state saved for the repo. Returned from Checkpoint.
+type CheckpointInfo struct {
+	// Exists is false if there is no checkpoint for the repo, in which case other fields are empty.
+	Exists bool
+	// Dir is the location of checkpoint files.
+	Dir string
+	// Commit is the last processed commit included in checkpoint. Pass it as CommitFromIncl to continue processing.
+	Commit string
+	// Refs are tips of refs processed by the last successful run, map[ref]commit. Empty if the last run was made by an older version of ripsrc.
+	Refs map[string]string
+	// Time when checkpoint was written.
+	Time time.Time
+	// ProcessedCommits is the number of commits processed by the last successful run.
+	ProcessedCommits int
+	// StoredCommits is the number of commits which blame state is kept in checkpoint to continue processing.
+	StoredCommits int
+	// StoredFiles is the number of file blames kept in checkpoint.
+	StoredFiles int
+	// SizeBytes is the size of checkpoint files on disk.
+	SizeBytes int64
+	// FormatVersion is the checkpoint format version, see MigrateCheckpoints.
+	FormatVersion int
+}
+
+// Checkpoint returns information about saved incremental state for the repo, without loading blame data.
+func (s *Ripsrc) Checkpoint(ctx context.Context) (res CheckpointInfo, _ error) {
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return res, err
+	}
+	p := process.New(process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	})
+	res.Dir = p.Dir()
+	info, err := p.CheckpointInfo()
+	if err != nil {
+		return res, err
+	}
+	if info == nil {
+		return res, nil
+	}
+	res.Exists = true
+	res.Commit = info.Commit
+	res.Time = info.Time
+	res.StoredCommits = info.Commits
+	res.StoredFiles = info.Files
+	res.SizeBytes = info.SizeBytes
+	res.FormatVersion = info.FormatVersion
+
+	m, err := s.readRefsManifest(ctx)
+	if err != nil {
+		return res, err
+	}
+	if m != nil && m.CheckpointCommit == info.Commit {
+		res.Refs = m.Refs
+		res.ProcessedCommits = m.Commits
+	}
+	return res, nil
+}
+
+// OutputStats writes human-readable checkpoint info.
+func (s CheckpointInfo) OutputStats(wr io.Writer) {
+	if !s.Exists {
+		fmt.Fprintln(wr, "no checkpoint in", s.Dir)
+		return
+	}
+	fmt.Fprintln(wr, "dir:", s.Dir)
+	fmt.Fprintln(wr, "commit:", s.Commit)
+	fmt.Fprintln(wr, "time:", s.Time.Format(time.RFC3339))
+	var refs []string
+	for ref := range s.Refs {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		fmt.Fprintln(wr, "ref:", ref, s.Refs[ref])
+	}
+	fmt.Fprintln(wr, "processed commits:", s.ProcessedCommits)
+	fmt.Fprintln(wr, "stored commits:", s.StoredCommits)
+	fmt.Fprintln(wr, "stored files:", s.StoredFiles)
+	fmt.Fprintln(wr, "size bytes:", s.SizeBytes)
+	fmt.Fprintln(wr, "format version:", s.FormatVersion)
+}
@@ -0,0 +1,50 @@
+package ripsrc
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultChurnWindow is the default Opts.ChurnWindow.
+const DefaultChurnWindow = 21 * 24 * time.Hour
+
+// ChurnStats classifies lines deleted or rewritten by a commit by who added them and when. Returned in CommitCode.Churn with Opts.ClassifyChurn.
+type ChurnStats struct {
+	// SelfChurn is the number of lines the author rewrote of their own code added within ChurnWindow.
+	SelfChurn int
+	// Rework is the number of lines added by other authors within ChurnWindow.
+	Rework int
+	// LegacyRefactor is the number of lines older than ChurnWindow, regardless of author.
+	LegacyRefactor int
+	// UnknownOrigin is the number of lines added by commits that were not processed in this run, for example before CommitFromIncl.
+	UnknownOrigin int
+}
+
+// Total returns the number of deleted or rewritten lines.
+func (s ChurnStats) Total() int {
+	return s.SelfChurn + s.Rework + s.LegacyRefactor + s.UnknownOrigin
+}
+
+// classifyChurn sums deleted lines of all files in commit by origin.
+func (s *Ripsrc) classifyChurn(commit Commit, blames []BlameResult) (res ChurnStats) {
+	window := s.opts.ChurnWindow
+	if window == 0 {
+		window = DefaultChurnWindow
+	}
+	for _, b := range blames {
+		for origin, n := range b.DeletedLines {
+			oc, ok := s.commitMeta[origin]
+			switch {
+			case !ok:
+				res.UnknownOrigin += n
+			case s.opts.CommitDate.Of(commit).Sub(s.opts.CommitDate.Of(oc)) > window:
+				res.LegacyRefactor += n
+			case strings.EqualFold(oc.AuthorEmail, commit.AuthorEmail):
+				res.SelfChurn += n
+			default:
+				res.Rework += n
+			}
+		}
+	}
+	return
+}
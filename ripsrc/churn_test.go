@@ -0,0 +1,38 @@
+package ripsrc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestClassifyChurn(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	// commits are one minute apart
+	r.Author("A", "a@example.com").Write("a.txt", "1\n2\n3\n4\n").Commit("c1")
+	r.Author("B", "b@example.com").Write("a.txt", "b1\n2\n3\n4\n").Commit("c2")
+	r.Author("A", "a@example.com").Write("a.txt", "b1\na2\n3\n4\n").Commit("c3")
+	r.Author("B", "b@example.com").Write("a.txt", "b4\na2\nb3\n4\n").Commit("c4")
+
+	opts := Opts{RepoDir: r.Dir(), ClassifyChurn: true, ChurnWindow: 150 * time.Second}
+	commits, _, err := codeByCommit(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ChurnStats
+	for _, c := range commits {
+		got = append(got, *c.Churn)
+	}
+	want := []ChurnStats{
+		{},
+		{Rework: 1},
+		{SelfChurn: 1},
+		{SelfChurn: 1, LegacyRefactor: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
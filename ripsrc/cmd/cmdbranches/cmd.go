@@ -23,6 +23,9 @@ type Opts struct {
 
 	// Profile set to one of mem, mutex, cpu, block, trace to enable profiling.
 	Profile string
+
+	// DebugAddr set to host:port to serve pprof and expvar while running, for example localhost:6060.
+	DebugAddr string
 }
 
 type Stats struct {
@@ -47,6 +50,14 @@ func Run(ctx context.Context, out io.Writer, opts Opts) {
 		defer runEndHook()
 	}
 
+	if opts.DebugAddr != "" {
+		onEnd, err := cmdutils.StartDebugServer(opts.DebugAddr)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+		}
+		defer onEnd()
+	}
+
 	{
 		onEnd := cmdutils.StartMemLogs()
 		defer onEnd()
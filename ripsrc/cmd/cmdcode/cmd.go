@@ -25,6 +25,12 @@ type Opts struct {
 
 	// Profile set to one of mem, mutex, cpu, block, trace to enable profiling.
 	Profile string
+
+	// DebugAddr set to host:port to serve pprof and expvar while running, for example localhost:6060.
+	DebugAddr string
+
+	// Plan set to true to only output what would be processed for each repo, without running blame.
+	Plan bool
 }
 
 type Stats struct {
@@ -50,6 +56,14 @@ func Run(ctx context.Context, out io.Writer, opts Opts) {
 		defer runEndHook()
 	}
 
+	if opts.DebugAddr != "" {
+		onEnd, err := cmdutils.StartDebugServer(opts.DebugAddr)
+		if err != nil {
+			cmdutils.ExitWithErr(err)
+		}
+		defer onEnd()
+	}
+
 	{
 		onEnd := cmdutils.StartMemLogs()
 		defer onEnd()
@@ -100,6 +114,9 @@ func runOnDirs(ctx context.Context, wr io.Writer, opts Opts, dir string, start t
 }
 
 func runOnRepo(ctx context.Context, wr io.Writer, opts Opts, repoDir string, globalStart time.Time) (entries int, _ error) {
+	if opts.Plan {
+		return 0, planRepo(ctx, wr, opts, repoDir)
+	}
 
 	err := cmdutils.RunOnRepo(ctx, wr, repoDir, func() error {
 		res := make(chan ripsrc.CommitCode)
@@ -148,3 +165,20 @@ func runOnRepo(ctx context.Context, wr io.Writer, opts Opts, repoDir string, glo
 	})
 	return entries, err
 }
+
+func planRepo(ctx context.Context, wr io.Writer, opts Opts, repoDir string) error {
+	return cmdutils.RunOnRepo(ctx, wr, repoDir, func() error {
+		ripOpts := ripsrc.Opts{}
+		ripOpts.RepoDir = repoDir
+		ripOpts.CommitFromIncl = opts.CommitFromIncl
+		ripOpts.NoStrictResume = true
+
+		plan, err := ripsrc.New(ripOpts).Plan(ctx)
+		if err != nil {
+			return err
+		}
+		plan.OutputStats(wr)
+		fmt.Fprintln(wr)
+		return nil
+	})
+}
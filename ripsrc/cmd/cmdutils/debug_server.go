@@ -0,0 +1,41 @@
+package cmdutils
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/fatih/color"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// StartDebugServer serves pprof at /debug/pprof/ and expvar at /debug/vars on addr, so that a long run could be profiled while it is running, for example using go tool pprof http://localhost:6060/debug/pprof/profile.
+// Handlers are registered on a separate mux, not http.DefaultServeMux.
+func StartDebugServer(addr string) (onEnd func(), _ error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not start debug server, err: %v", err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	fmt.Fprintf(color.Output, "debug server listening on %v\n", color.GreenString("http://%v/debug/pprof/", l.Addr()))
+	return func() {
+		srv.Close()
+	}, nil
+}
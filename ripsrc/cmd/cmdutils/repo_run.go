@@ -37,7 +37,7 @@ func RunOnRepo(ctx context.Context, wr io.Writer, repoDir string, run func() err
 
 func hasHeadCommit(ctx context.Context, repoDir string) bool {
 	out := bytes.NewBuffer(nil)
-	c := exec.Command("git", "rev-parse", "HEAD")
+	c := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
 	c.Dir = repoDir
 	c.Stdout = out
 	c.Run()
@@ -2,62 +2,67 @@ package ripsrc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
-	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
+	"github.com/pinpt/ripsrc/ripsrc/projects"
+	"github.com/pinpt/ripsrc/ripsrc/types"
 )
 
 // Commit is a specific detail around a commit
-type Commit = commitmeta.Commit
+type Commit = types.Commit
 
 // CommitFile is a specific detail around a file in a commit
-type CommitFile = commitmeta.CommitFile
+type CommitFile = types.CommitFile
+
+// Signature holds commit signature verification result, see Opts.CommitSignatures
+type Signature = types.Signature
+
+// Trailer is a key value line at the end of the commit message, see Opts.CommitTrailers
+type Trailer = types.Trailer
+
+// SignatureStatus is a commit signature verification status
+type SignatureStatus = types.SignatureStatus
 
 // BlameResult holds details about the blame result
-type BlameResult struct {
-	Commit             Commit
-	Language           string
-	Filename           string
-	Lines              []*BlameLine
-	Size               int64
-	Loc                int64
-	Sloc               int64
-	Comments           int64
-	Blanks             int64
-	Complexity         int64
-	WeightedComplexity float64
-	Skipped            string
-	License            *License
-	Status             CommitStatus
-}
+type BlameResult = types.BlameResult
+
+// SkippedFiles controls how files skipped by code analysis, for example vendored, generated or too large files, are returned. See Opts.SkippedFiles.
+type SkippedFiles string
+
+const (
+	// SkippedFilesReason returns skipped files with Skipped reason and no stats. This is the default.
+	SkippedFilesReason = SkippedFiles("")
+	// SkippedFilesFlag returns skipped files with Skipped reason, Size and Loc, without blame lines, so that consumers could count the volume of skipped code.
+	SkippedFilesFlag = SkippedFiles("flag")
+	// SkippedFilesDrop does not return skipped files. Removed files are still returned.
+	SkippedFilesDrop = SkippedFiles("drop")
+)
 
 // BlameLine is a single line entry in blame
-type BlameLine struct {
-	Name    string
-	Email   string
-	Date    time.Time
-	Comment bool
-	Code    bool
-	Blank   bool
-	SHA     string
-}
+type BlameLine = types.BlameLine
+
+// Hunk is a changed range of a file in a commit, see Opts.TrackHunks.
+type Hunk = types.Hunk
 
 // License holds details about detected license
-type License = fileinfo.License
+type License = types.License
 
 // CommitStatus is a commit status type
-type CommitStatus = commitmeta.CommitStatus
+type CommitStatus = types.CommitStatus
 
 const (
 	// GitFileCommitStatusAdded is the added status
-	GitFileCommitStatusAdded = commitmeta.GitFileCommitStatusAdded
+	GitFileCommitStatusAdded = types.GitFileCommitStatusAdded
 	// GitFileCommitStatusModified is the modified status
-	GitFileCommitStatusModified = commitmeta.GitFileCommitStatusModified
+	GitFileCommitStatusModified = types.GitFileCommitStatusModified
 	// GitFileCommitStatusRemoved is the removed status
-	GitFileCommitStatusRemoved = commitmeta.GitFileCommitStatusRemoved
+	GitFileCommitStatusRemoved = types.GitFileCommitStatusRemoved
 )
 
 // Code returns code information using one record per file and commit
@@ -70,7 +75,11 @@ func (s *Ripsrc) Code(ctx context.Context, res chan BlameResult) error {
 	go func() {
 		for r := range res2 {
 			for f := range r.Blames {
-				res <- f
+				select {
+				case res <- f:
+				case <-ctx.Done():
+					// CodeByCommit returns ctx error, keep reading until it exits
+				}
 			}
 		}
 		done <- true
@@ -88,109 +97,308 @@ func (s *Ripsrc) Code(ctx context.Context, res chan BlameResult) error {
 type CommitCode struct {
 	Commit
 	Blames chan BlameResult
+
+	// ReleasedInTag is the name of the first tag (by tag date) that includes this commit.
+	// Only set when Opts.CommitsReleasedInTag is true. Empty if commit was not released yet.
+	ReleasedInTag string
+
+	// Tests splits lines changed by the commit between test and production files.
+	Tests TestStats
+
+	// Churn classifies lines deleted or rewritten by the commit. Only set when Opts.ClassifyChurn is true.
+	Churn *ChurnStats
+
+	// DeadlineExceeded is true if the commit took longer than Opts.CommitDeadline and some of its files were skipped. Skipped files have BlameResult.BlameError set.
+	DeadlineExceeded bool
+
+	// Hosting is metadata from Opts.Enricher, nil if not set or the enricher had none.
+	Hosting *CommitHosting
 }
 
 // CodeByCommit returns code information using one record per commit that includes records by file
-func (s *Ripsrc) CodeByCommit(ctx context.Context, res chan CommitCode) error {
+//
+// Results are streamed without buffering. Each stage of the pipeline, git log parsing, blame, code info and sending to res, hands over one commit at a time using unbuffered channels,
+// so git log and blame pause until the caller receives the commit and all of its Blames. A slow caller never causes results to accumulate in memory.
+// At most 3 commits are in flight: one being blamed, one in code info and one being returned. With SegmentConcurrency N > 1, up to 4*N commits are blamed ahead of the oldest unfinished one, so at most 4*N+2 commits are in flight.
+// Memory of an in-flight commit is proportional to the blame of the files it changed. Blame state of the repo kept for incremental processing is not in-flight memory, see MaxMemoryBytes to bound it.
+func (s *Ripsrc) CodeByCommit(ctx context.Context, res chan CommitCode) (rerr error) {
 	defer close(res)
+	started := time.Now()
+	if len(s.opts.Sinks) != 0 {
+		defer func() {
+			rerr = s.finishSinks(ctx, rerr)
+		}()
+	}
+
+	defer s.timings.track()()
+	ctx = s.gitContext(ctx)
 
-	err := s.prepareGitExec(ctx)
+	ctx, span := s.opts.Tracer.Start(ctx, tracing.SpanCode, "repo", s.opts.RepoDir)
+	defer span.End()
+
+	err := s.checkHistoryRewrite(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = s.buildCommitGraph(ctx)
+	err = s.prepareGitExec(ctx)
+	if errors.Is(err, ErrEmptyRepo) {
+		s.opts.Logger.Info("repo has no commits, nothing to process", "repo", s.opts.RepoDir)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	var wantedBranchRefs []string
-	var wantedBranchNames []string
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 
-	if s.opts.CommitFromIncl != "" && s.opts.AllBranches {
-		/*
-			allBranches, err := branchmeta.Get(ctx, branchmeta.Opts{
-				Logger:    s.opts.Logger,
-				RepoDir:   s.opts.RepoDir,
-				UseOrigin: s.opts.BranchesUseOrigin,
-			})
+	profiles, stopProfiles, err := s.startProfiles(ctx, started)
+	if err != nil {
+		return err
+	}
+	defer stopProfiles()
+	s.runProfiles = profiles
 
-			if err != nil {
-				return err
-			}
+	// recorded before reading commits, so that commits added during the run are detected by NeedsProcessing
+	refs, err := s.currentRefs(ctx)
+	if err != nil {
+		return err
+	}
 
-			deadline := s.opts.IncrementalIgnoreBranchesOlderThan
-			if deadline.IsZero() {
-				deadline = time.Now().Add(-3 * 30 * 24 * time.Hour)
-			}
-			for _, b := range allBranches {
-				if b.CommitCommitterTime.After(deadline) {
-					wantedBranchRefs = append(wantedBranchRefs, b.Commit)
-					wantedBranchNames = append(wantedBranchNames, b.Name)
-				}
-			}
-		*/
+	err = s.buildCommitGraph(ctx)
+	if err != nil {
+		return err
 	}
-	if len(wantedBranchRefs) != 0 {
-		s.opts.Logger.Debug("processing additional branches", "branches", wantedBranchNames)
+
+	err = s.getCommitInfo(ctx)
+	if err != nil {
+		return err
 	}
 
-	err = s.getCommitInfo(ctx, wantedBranchRefs)
+	s.blobs, err = gitexec.NewCatFileCheck(ctx, gitCommand, s.opts.RepoDir)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		s.blobs.Close()
+		s.blobs = nil
+	}()
+
+	if s.opts.CodeOwners {
+		s.codeOwners, _, err = s.loadCodeOwners(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			s.codeOwners = nil
+		}()
+	}
+
+	if s.opts.Projects {
+		files, err := s.listTree(ctx)
+		if err != nil {
+			return err
+		}
+		s.projects = projects.Detect(files)
+		defer func() {
+			s.projects = nil
+		}()
+	}
+
+	if s.opts.Attribution == AttributionMerge && s.opts.Stages.Has(StageLines) {
+		s.landedIn, err = s.getLandedIn(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			s.landedIn = nil
+		}()
+	}
+
+	s.loadFileInfoCache(ctx)
+
+	var releasedInTag map[string]string
+	if s.opts.CommitsReleasedInTag {
+		releasedInTag, err = s.getReleasedInTag(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.runCounts = RunCounts{}
+	if !s.opts.Stages.Has(StageBlame) {
+		err = s.sendCommitsOnly(ctx, res, releasedInTag)
+		if err != nil {
+			return err
+		}
+		return s.finishRunManifest(ctx, started, refs, "", false)
+	}
 
 	gitRes := make(chan process.Result)
 	done := make(chan bool)
+	// infoErr is the first error getting code info, safe to read after done. Git processing is canceled, so that checkpoint is not written for commits that were not returned, and remaining results are drained.
+	var infoErr error
+	processCtx, cancelProcess := context.WithCancel(ctx)
+	defer cancelProcess()
 	go func() {
+		batch := newCodeInfoTraceBatch(ctx, s.opts.Tracer, s.opts.RepoDir)
+		defer batch.End()
 		for r1 := range gitRes {
+			if infoErr != nil {
+				continue
+			}
 			sha := r1.Commit
+			batch.Commit(sha)
 
 			rc := CommitCode{}
 			rc.Blames = make(chan BlameResult)
 			commit, ok := s.commitMeta[sha]
 			if !ok {
-				panic(fmt.Errorf("commit not found in commit meta: %v", r1.Commit))
+				infoErr = fmt.Errorf("commit not found in commit meta: %v", r1.Commit)
+				cancelProcess()
+				continue
 			}
 			rc.Commit = commit
+			rc.ReleasedInTag = releasedInTag[sha]
+			rc.DeadlineExceeded = r1.DeadlineExceeded
+			if r1.DeadlineExceeded && s.skipReport != nil {
+				s.skipReport.addCommit(sha, countDeadlineSkipped(r1))
+			}
+
+			if s.onProcessResult != nil {
+				s.onProcessResult(r1, commit)
+			}
+			s.verifyResult(ctx, r1)
+			if s.skipCommit(commit) {
+				continue
+			}
 
 			rs, err := s.codeInfoFiles(r1)
 			if err != nil {
-				panic(err)
+				infoErr = err
+				cancelProcess()
+				continue
+			}
+			rc.Tests = testStats(commit, rs)
+			if s.opts.ClassifyChurn {
+				churn := s.classifyChurn(commit, rs)
+				rc.Churn = &churn
 			}
-			res <- rc
-			for _, r := range rs {
-				rc.Blames <- r
+			s.opts.Metrics.Counter(metrics.CommitsProcessed, 1)
+			s.opts.Metrics.Counter(metrics.FilesProcessed, float64(len(rs)))
+			s.enrich(ctx, &rc)
+			s.runCounts.add(rc, rs)
+			err = s.toSinks(ctx, rc, rs)
+			if err != nil {
+				infoErr = err
+				cancelProcess()
+				continue
+			}
+			err = sendCommitCode(ctx, res, rc, rs)
+			if err != nil {
+				infoErr = err
+				cancelProcess()
 			}
-			close(rc.Blames)
 		}
 		done <- true
 	}()
 
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return err
+	}
+
 	processOpts := process.Opts{
 		Logger:                s.opts.Logger,
 		RepoDir:               s.opts.RepoDir,
-		CheckpointsDir:        s.opts.CheckpointsDir,
+		CheckpointsDir:        checkpointsDir,
 		NoStrictResume:        s.opts.NoStrictResume,
 		CommitFromIncl:        s.opts.CommitFromIncl,
 		CommitFromMakeNonIncl: s.opts.CommitFromMakeNonIncl,
 		AllBranches:           s.opts.AllBranches,
 		ParentsGraph:          s.commitGraph,
-		WantedBranchRefs:      wantedBranchRefs,
+		Metrics:               s.opts.Metrics,
+		Tracer:                s.opts.Tracer,
+		Refs:                  s.opts.Refs,
+		CheckpointEvery:       s.opts.CheckpointEvery,
+		CheckpointInterval:    s.opts.CheckpointInterval,
+		ResumeInterrupted:     s.opts.ResumeInterrupted,
+		TrackDeletions:        s.opts.TrackDeletions || s.opts.ClassifyChurn,
+		TrackLineMapping:      s.opts.TrackLineMapping,
+		TrackHunks:            s.opts.TrackHunks,
+		TrackPatches:          s.opts.IncludePatches,
+		SegmentConcurrency:    s.opts.SegmentConcurrency,
+		CheckpointCompression: s.checkpointCompression(),
+		SharedCheckpointsDir:  s.opts.SharedCheckpointsDir,
+		ForceFullReprocess:    s.opts.ForceFullReprocess,
+		MaxLine:               s.opts.MaxLine,
+		AuthorDateOrder:       s.opts.CommitDate == CommitDateAuthor,
+		MaxDuration:           s.maxDurationLeft(started),
+		MaxMemoryBytes:        s.opts.MaxMemoryBytes,
+		CommitDeadline:        s.opts.CommitDeadline,
 	}
 	gitProcessor := process.New(processOpts)
-	err = gitProcessor.Run(gitRes)
+	err = gitProcessor.RunContext(processCtx, gitRes)
 	<-done
 
+	if infoErr != nil {
+		err = infoErr
+	}
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 
 	s.GitProcessTimings = gitProcessor.Timing()
+	commits := s.GitProcessTimings.RegularCommitsCount + s.GitProcessTimings.MergesCount
+	s.opts.Logger.Info("finished streaming all commits", "commits", commits)
+
+	checkpointCommit, err := gitProcessor.CheckpointCommit()
+	if err != nil {
+		return err
+	}
+	err = s.writeRefsManifest(ctx, refs, checkpointCommit, commits)
+	if err != nil {
+		return err
+	}
+	s.saveFileInfoCache(ctx)
+	err = s.finishRunManifest(ctx, started, refs, checkpointCommit, true)
+	if err != nil {
+		return err
+	}
+	return s.writeNamespaceMeta(ctx)
+}
 
+// sendCommitCode sends commit and its blames to the caller, unless ctx is canceled first. Blames channel is closed in both cases.
+func sendCommitCode(ctx context.Context, res chan CommitCode, rc CommitCode, blames []BlameResult) error {
+	defer close(rc.Blames)
+	select {
+	case res <- rc:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, r := range blames {
+		select {
+		case rc.Blames <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	return nil
 }
 
+// ctxErr returns ctx error if ctx is done, instead of err of git commands killed on cancel, so that callers could check for context.Canceled.
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
 func (s *Ripsrc) CodeSlice(ctx context.Context) (res []BlameResult, _ error) {
 	resChan := make(chan BlameResult)
 	done := make(chan bool)
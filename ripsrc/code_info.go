@@ -1,9 +1,9 @@
 package ripsrc
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"regexp"
 	"runtime/debug"
 	"time"
 
@@ -12,9 +12,17 @@ import (
 	"github.com/boyter/scc/processor"
 	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
 	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
 )
 
+// VendorRule marks files with matching paths as vendored, see Opts.VendorRules.
+type VendorRule = fileinfo.VendorRule
+
 func (s *Ripsrc) codeInfoFiles(blame process.Result) (res []BlameResult, _ error) {
+	start := time.Now()
+	defer func() {
+		s.opts.Metrics.Duration(metrics.StageDuration, time.Since(start), "stage", metrics.StageCodeInfo)
+	}()
 	commit := s.commitMeta[blame.Commit]
 
 	// check that files are included in both
@@ -45,8 +53,21 @@ func (s *Ripsrc) codeInfoFiles(blame process.Result) (res []BlameResult, _ error
 
 		r := BlameResult{}
 		r.Filename = filePath
+		r.DeletedLines = blame.Deleted[filePath]
+		r.Hunks = hunks(blame.Hunks[filePath])
+		if p, ok := blame.Patches[filePath]; ok {
+			r.Patch, r.PatchTruncated = s.patch(p)
+		}
+		if s.codeOwners != nil {
+			r.Owners = s.anonymizer.owners(s.codeOwners.Owners(filePath))
+		}
+		if s.projects != nil {
+			if pr, ok := s.projects.Find(filePath); ok {
+				r.ProjectID = pr.ID
+			}
+		}
 
-		r.Commit = commit
+		r.Commit = s.inlineCommit(commit)
 
 		f, ok := commit.Files[filePath]
 		if !ok {
@@ -56,30 +77,91 @@ func (s *Ripsrc) codeInfoFiles(blame process.Result) (res []BlameResult, _ error
 		}
 
 		r.Status = f.Status
+		r.BlobSHA = f.BlobSHA
+		r.Mode = f.Mode
+		if f.BlobSHA != "" && s.blobs != nil {
+			obj, err := s.blobs.Info(f.BlobSHA)
+			if err != nil {
+				return nil, fmt.Errorf("could not get blob size, commit: %v file: %v err: %v", commit.SHA, filePath, err)
+			}
+			r.BlobSize = obj.Size
+		}
 
 		if r.Status == GitFileCommitStatusRemoved {
 			r.Skipped = removedFile
+			r.IsTest = fileinfo.IsTest(filePath, nil)
 			// no need to run code info
 			res = append(res, r)
 			continue
 		}
 
+		if blf.IsUnknown {
+			r.Skipped = unknownBlame
+			if err := blame.Quarantined[filePath]; err != nil {
+				r.BlameError = err.Error()
+			}
+			if s.opts.SkippedFiles != SkippedFilesDrop {
+				res = append(res, r)
+			}
+			continue
+		}
+
 		fileBytes := blameToFileContent(blf)
 		fileLines := blameToByteLines(blf)
-		info, skipReason := s.fileInfo.GetInfo(fileinfo.InfoArgs{FilePath: filePath, Content: fileBytes, Lines: fileLines})
+		var cached blobInfo
+		if s.opts.Stages.Has(StageFileInfo) {
+			cached, ok = s.blobCache.info(r.BlobSHA, filePath)
+			if ok {
+				s.opts.Metrics.Counter(metrics.BlobCacheHits, 1)
+			} else {
+				cached.info, cached.skipReason = s.fileInfo.GetInfo(fileinfo.InfoArgs{FilePath: filePath, Content: fileBytes, Lines: fileLines})
+				s.blobCache.addInfo(r.BlobSHA, filePath, cached)
+			}
+		}
+		info, skipReason := cached.info, cached.skipReason
 		r.License = info.License
 		r.Language = info.Language
+		r.IsTest = info.IsTest
+		r.Generated = info.Generated
 
 		if skipReason != "" {
 			r.Skipped = skipReason
+			if s.skipReport != nil {
+				s.skipReport.add(filePath, skipReason, info.SkipRule, commit.SHA)
+			}
+			switch s.opts.SkippedFiles {
+			case SkippedFilesDrop:
+				continue
+			case SkippedFilesFlag:
+				r.Size = int64(len(fileBytes))
+				r.Loc = int64(len(fileLines))
+			}
 			res = append(res, r)
 			continue
 		}
 
-		r, err := s.codeInfoFile(filePath, blf, fileBytes, r)
+		r, err := s.codeInfoFile(filePath, blf, blame.PrevLines[filePath], fileBytes, r)
 		if err != nil {
 			return nil, err
 		}
+		if info.Generated {
+			// it was a generated file ... in this case, we treat it like a
+			// deleted file in case it wasn't skipped in a previous commit
+			r.Language = ""
+			r.Skipped = generatedFile
+			if s.skipReport != nil {
+				s.skipReport.add(filePath, generatedFile, "generated file name or header marker", commit.SHA)
+			}
+			if s.opts.SkippedFiles == SkippedFilesDrop {
+				continue
+			}
+		}
+		if r.Skipped == "" {
+			err := s.runAnalyzers(blf, &r)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		res = append(res, r)
 	}
@@ -90,6 +172,8 @@ const (
 	generatedFile = "file was a generated file"
 	//whitelisted      = "File was not on the inclusion list"
 	removedFile = "File was removed"
+	// unknownBlame is used for files which diff could not be parsed or applied in this or one of the previous commits
+	unknownBlame = "File blame is unknown because a diff could not be applied"
 	//pathInvalid      = "File path was invalid"
 	//languageUnknown  = "Language was unknown"
 	//fileNotSupported = "File type was not supported as source code"
@@ -107,7 +191,8 @@ func (s *CodeInfoTimings) OutputStats(wr io.Writer) {
 	fmt.Fprintln(wr, "total time", s.Time)
 }
 
-func (s *Ripsrc) codeInfoFile(filePath string, bl *incblame.Blame, fileBytes []byte, res BlameResult) (BlameResult, error) {
+// codeInfoFile returns code info of the file. prevLines are line numbers before the commit from process.Result.PrevLines, nil if not tracked.
+func (s *Ripsrc) codeInfoFile(filePath string, bl *incblame.Blame, prevLines []int, fileBytes []byte, res BlameResult) (BlameResult, error) {
 	start := time.Now()
 	defer func() {
 		dur := time.Since(start)
@@ -118,16 +203,37 @@ func (s *Ripsrc) codeInfoFile(filePath string, bl *incblame.Blame, fileBytes []b
 	var lines []*statsLine
 
 	// assign lines to result
-	for _, line := range bl.Lines {
-		meta := s.commitMeta[line.Commit]
-		line2 := &statsLine{}
-		line2.BlameLine = &BlameLine{}
-		line2.Name = meta.AuthorName
-		line2.Email = meta.AuthorEmail
-		line2.Date = meta.Date
-		line2.line = line.Line
-		line2.SHA = line.Commit
-		lines = append(lines, line2)
+	if s.opts.Stages.Has(StageLines) {
+		for i, line := range bl.Lines {
+			sha := line.Commit
+			if l, ok := s.landedIn[sha]; ok {
+				sha = l
+			}
+			meta := s.commitMeta[sha]
+			line2 := &statsLine{}
+			line2.BlameLine = &BlameLine{}
+			line2.Line = i + 1
+			if i < len(prevLines) {
+				line2.PrevLine = prevLines[i]
+			}
+			line2.Name = meta.AuthorName
+			line2.Email = meta.AuthorEmail
+			line2.Date = s.opts.CommitDate.Of(meta)
+			line2.SHA = sha
+			if sha != line.Commit {
+				line2.OriginalSHA = line.Commit
+			}
+			lines = append(lines, line2)
+		}
+	}
+
+	if !s.opts.Stages.Has(StageStats) {
+		res.Size = int64(len(fileBytes))
+		res.Loc = int64(len(bl.Lines))
+		for _, l := range lines {
+			res.Lines = append(res.Lines, l.BlameLine)
+		}
+		return res, nil
 	}
 
 	res, err := s.codeStats(filePath, bl, fileBytes, lines, res)
@@ -138,6 +244,35 @@ func (s *Ripsrc) codeInfoFile(filePath string, bl *incblame.Blame, fileBytes []b
 	return res, nil
 }
 
+// hunks converts hunks from process.Result.Hunks.
+func hunks(stats []incblame.HunkStats) (res []Hunk) {
+	for _, h := range stats {
+		res = append(res, Hunk{OldStart: h.OldStart, OldLines: h.OldLines, NewStart: h.NewStart, NewLines: h.NewLines, Added: h.Added, Deleted: h.Deleted})
+	}
+	return
+}
+
+// DefaultMaxPatchSize is the default Opts.MaxPatchSize.
+const DefaultMaxPatchSize = 1024 * 1024
+
+// patch returns the patch from process.Result.Patches cut to Opts.MaxPatchSize, true if it was cut.
+func (s *Ripsrc) patch(p []byte) (string, bool) {
+	max := s.opts.MaxPatchSize
+	if max == 0 {
+		max = DefaultMaxPatchSize
+	}
+	truncated := false
+	if max > 0 && len(p) > max {
+		truncated = true
+		p = p[:max]
+		// do not return partial lines, unless the first line is already too long
+		if i := bytes.LastIndexByte(p, '\n'); i != -1 {
+			p = p[:i+1]
+		}
+	}
+	return s.anonymizer.patch(string(p)), truncated
+}
+
 func blameToFileContent(bl *incblame.Blame) (res []byte) {
 	for _, l := range bl.Lines {
 		res = append(res, l.Line...)
@@ -154,75 +289,71 @@ func blameToByteLines(bl *incblame.Blame) (res [][]byte) {
 }
 
 func (s *Ripsrc) codeStats(filePath string, bl *incblame.Blame, fileBytes []byte, lines []*statsLine, res BlameResult) (BlameResult, error) {
-	statcallback := &statsProcessor{lines: lines}
+	stats, ok := s.blobCache.stats(res.BlobSHA, res.Language)
+	if !ok {
+		stats = countStats(filePath, res.Language, fileBytes, len(bl.Lines))
+		s.blobCache.addStats(res.BlobSHA, res.Language, stats)
+	}
+
+	res.Size = int64(len(fileBytes))
+	res.Loc = stats.loc
+	res.Sloc = stats.sloc
+	res.Comments = stats.comments
+	res.Blanks = stats.blanks
+	res.Complexity = stats.complexity
+	res.WeightedComplexity = stats.weightedComplexity
+
+	for i, l := range lines {
+		if i < len(stats.lineTypes) {
+			switch stats.lineTypes[i] {
+			case lineTypeBlank:
+				l.Blank = true
+			case lineTypeCode:
+				l.Code = true
+			case lineTypeComment:
+				l.Comment = true
+			}
+		}
+		res.Lines = append(res.Lines, l.BlameLine)
+	}
+
+	return res, nil
+}
+
+// countStats runs scc on file content.
+func countStats(filePath string, language string, fileBytes []byte, lines int) (res blobStats) {
+	statcallback := &statsProcessor{lineTypes: make([]lineType, lines)}
 	filejob := &processor.FileJob{
 		Filename: filePath,
-		Language: res.Language,
+		Language: language,
 		Content:  fileBytes,
 		Callback: statcallback,
 	}
 	processor.CountStats(filejob)
 	filejob.Content = nil
 
-	res.Size = int64(len(fileBytes))
-	res.Loc = filejob.Lines
-	res.Sloc = filejob.Code
-	res.Comments = filejob.Comment
-	res.Blanks = filejob.Blank
-	res.Complexity = filejob.Complexity
-	res.WeightedComplexity = filejob.WeightedComplexity
-
-	if statcallback.generated {
-		// it was a generated file ... in this case, we treat it like a
-		// deleted file in case it wasn't skipped in a previous commit
-		res.Language = ""
-		res.Skipped = generatedFile
-	}
-
-	for _, l := range lines {
-		res.Lines = append(res.Lines, l.BlameLine)
-	}
-
-	return res, nil
+	res.loc = filejob.Lines
+	res.sloc = filejob.Code
+	res.comments = filejob.Comment
+	res.blanks = filejob.Blank
+	res.complexity = filejob.Complexity
+	res.weightedComplexity = filejob.WeightedComplexity
+	res.lineTypes = statcallback.lineTypes
+	return
 }
 
 type statsLine struct {
 	*BlameLine
-	line []byte
 }
 
 type statsProcessor struct {
-	lines     []*statsLine
-	generated bool
+	lineTypes []lineType
 }
 
-// regular expression to attempt to detect if the file was generated and if so, we exclude it from processing since
-// it wasn't written by a human so we don't want to count it in our stats
-var generatedRegexp = regexp.MustCompile("(GENERATED|DO NOT EDIT|DO NOT MODIFY|machine generated)")
-
 func (p *statsProcessor) ProcessLine(job *processor.FileJob, currentLine int64, lineType processor.LineType) bool {
 	index := int(currentLine) - 1
-	if index >= 0 && index < len(p.lines) {
-		l := p.lines[index]
-		switch lineType {
-		case processor.LINE_BLANK:
-			l.Blank = true
-		case processor.LINE_CODE:
-			l.Code = true
-		case processor.LINE_COMMENT:
-			l.Comment = true
-		}
-		// if this is a comment and within N lines near the top, we check to see if it
-		// has a header that looks like a generated source file
-		if l.line != nil && l.Comment {
-			src := string(l.line)
-			if generatedRegexp.MatchString(src) {
-				l.line = nil
-				p.generated = true
-				return false
-			}
-			l.line = nil
-		}
+	if index >= 0 && index < len(p.lineTypes) {
+		p.lineTypes[index] = toLineType(lineType)
 		return true
 	}
 	return false
@@ -0,0 +1,85 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/pinpt/ripsrc/ripsrc/codeowners"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// CodeOwnersCoverage shows how much of the repo is covered by CODEOWNERS rules.
+type CodeOwnersCoverage struct {
+	// Location is the path of CODEOWNERS file used, empty if repo does not have one.
+	Location string
+	// Files is the number of files in the tree.
+	Files int
+	// Unowned are files without owner, sorted. All files are unowned if there is no CODEOWNERS file.
+	Unowned []string
+	// FilesByOwner is the number of files by owner. Files with multiple owners are counted for each.
+	FilesByOwner map[string]int
+	// InvalidLines are line numbers of CODEOWNERS that could not be parsed and were ignored.
+	InvalidLines []int
+}
+
+// loadCodeOwners reads CODEOWNERS from the first of codeowners.Locations that exists at treeRef. Returns nil file if there is none.
+func (s *Ripsrc) loadCodeOwners(ctx context.Context) (_ *codeowners.File, location string, _ error) {
+	cat, err := gitexec.NewCatFile(ctx, gitCommand, s.opts.RepoDir)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cat.Close()
+	for _, loc := range codeowners.Locations {
+		obj, err := cat.Get(s.treeRef() + ":" + loc)
+		if errors.Is(err, gitexec.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if obj.Type != "blob" {
+			continue
+		}
+		return codeowners.Parse(obj.Data), loc, nil
+	}
+	return nil, "", nil
+}
+
+// CodeOwnersCoverage lists files at the first of Opts.Refs or HEAD and reports which of them have owners in CODEOWNERS.
+func (s *Ripsrc) CodeOwnersCoverage(ctx context.Context) (res CodeOwnersCoverage, _ error) {
+	ctx = s.gitContext(ctx)
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return res, err
+	}
+	owners, loc, err := s.loadCodeOwners(ctx)
+	if err != nil {
+		return res, err
+	}
+	files, err := s.listTree(ctx)
+	if err != nil {
+		return res, err
+	}
+	res.Location = loc
+	res.FilesByOwner = map[string]int{}
+	if owners != nil {
+		res.InvalidLines = owners.InvalidLines
+	}
+	for _, fn := range files {
+		res.Files++
+		var fileOwners []string
+		if owners != nil {
+			fileOwners = owners.Owners(fn)
+		}
+		if len(fileOwners) == 0 {
+			res.Unowned = append(res.Unowned, fn)
+			continue
+		}
+		for _, o := range s.anonymizer.owners(fileOwners) {
+			res.FilesByOwner[o]++
+		}
+	}
+	sort.Strings(res.Unowned)
+	return res, nil
+}
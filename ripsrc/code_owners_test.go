@@ -0,0 +1,62 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCodeOwners(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("api/a.go", "package api\n").Write("README.md", "readme\n").Commit("c1")
+	r.Write(".github/CODEOWNERS", "api/ @org/api\n*.md @docs\n").Commit("c2")
+
+	opts := Opts{RepoDir: r.Dir(), CodeOwners: true}
+	res, err := New(opts).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, b := range res {
+		got[b.Filename] = b.Owners
+	}
+	want := map[string][]string{
+		"api/a.go":           {"@org/api"},
+		"README.md":          {"@docs"},
+		".github/CODEOWNERS": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got owners %v, want %v", got, want)
+	}
+
+	cov, err := New(opts).CodeOwnersCoverage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cov.Location != ".github/CODEOWNERS" || cov.Files != 3 {
+		t.Errorf("unexpected coverage %+v", cov)
+	}
+	if !reflect.DeepEqual(cov.Unowned, []string{".github/CODEOWNERS"}) {
+		t.Errorf("unexpected unowned files %v", cov.Unowned)
+	}
+	if cov.FilesByOwner["@org/api"] != 1 || cov.FilesByOwner["@docs"] != 1 {
+		t.Errorf("unexpected files by owner %v", cov.FilesByOwner)
+	}
+}
+
+func TestCodeOwnersCoverageNoFile(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+
+	cov, err := New(Opts{RepoDir: r.Dir()}).CodeOwnersCoverage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cov.Location != "" || cov.Files != 1 || !reflect.DeepEqual(cov.Unowned, []string{"a.txt"}) {
+		t.Errorf("unexpected coverage %+v", cov)
+	}
+}
@@ -0,0 +1,275 @@
+package ripsrc
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+)
+
+// SurvivalDimension is the grouping used in CodeSurvival results.
+type SurvivalDimension string
+
+const (
+	// SurvivalByAuthor groups lines by author email of the commit that added them.
+	SurvivalByAuthor = SurvivalDimension("author")
+	// SurvivalByDirectory groups lines by directory of the file, "." for files in repo root.
+	SurvivalByDirectory = SurvivalDimension("directory")
+	// SurvivalByLanguage groups lines by detected language of the file.
+	SurvivalByLanguage = SurvivalDimension("language")
+)
+
+// SurvivalGroup describes how long lines added in the group survive before being deleted or rewritten.
+type SurvivalGroup struct {
+	Dimension SurvivalDimension
+	// Key is author email, directory or language depending on Dimension.
+	Key string
+	// LinesAdded is the number of lines added by processed commits.
+	LinesAdded int
+	// LinesDeleted is the number of those lines that were later deleted or rewritten.
+	LinesDeleted int
+	// HalfLife is the estimated time after which half of added lines are deleted or rewritten. Lines still present are accounted for using Kaplan-Meier estimator.
+	// Zero if more than half of the lines survive for the observed time.
+	HalfLife time.Duration
+	// MeanDeletedAge is the average age of deleted lines at the time of deletion.
+	MeanDeletedAge time.Duration
+}
+
+type survivalKey struct {
+	dim SurvivalDimension
+	key string
+}
+
+// survivalEvent is lines deleted at age, or still alive at the end if censored.
+type survivalEvent struct {
+	age      time.Duration
+	lines    int
+	censored bool
+}
+
+type survivalGroupState struct {
+	SurvivalGroup
+	// alive lines by origin commit
+	alive  map[string]int
+	events []survivalEvent
+}
+
+// CodeSurvival aggregates blames returned from CodeByCommit with Opts.TrackDeletions into line survival statistics.
+// Add every commit with AddCommit and its blames with AddBlame in the order returned, then call Result.
+// Only lines added by commits passed to AddCommit are included, lines in files skipped by ripsrc are not included.
+type CodeSurvival struct {
+	// CommitDate selects the commit date used for line ages.
+	CommitDate CommitDate
+
+	groups map[survivalKey]*survivalGroupState
+	// commits is the author and date of added commits
+	commits map[string]Commit
+	// languages is the last detected language by file
+	languages map[string]string
+	current   Commit
+	end       time.Time
+}
+
+func NewCodeSurvival() *CodeSurvival {
+	s := &CodeSurvival{}
+	s.groups = map[survivalKey]*survivalGroupState{}
+	s.commits = map[string]Commit{}
+	s.languages = map[string]string{}
+	return s
+}
+
+func (s *CodeSurvival) group(dim SurvivalDimension, key string) *survivalGroupState {
+	k := survivalKey{dim: dim, key: key}
+	g, ok := s.groups[k]
+	if !ok {
+		g = &survivalGroupState{}
+		g.Dimension = dim
+		g.Key = key
+		g.alive = map[string]int{}
+		s.groups[k] = g
+	}
+	return g
+}
+
+func (s *CodeSurvival) fileGroups(filename string, author string) []*survivalGroupState {
+	res := []*survivalGroupState{
+		s.group(SurvivalByAuthor, author),
+		s.group(SurvivalByDirectory, path.Dir(filename)),
+	}
+	if lang := s.languages[filename]; lang != "" {
+		res = append(res, s.group(SurvivalByLanguage, lang))
+	}
+	return res
+}
+
+// AddCommit must be called before AddBlame for blames of the commit.
+func (s *CodeSurvival) AddCommit(c Commit) {
+	c.Date = s.CommitDate.Of(c)
+	s.commits[c.SHA] = Commit{SHA: c.SHA, AuthorEmail: c.AuthorEmail, Date: c.Date}
+	s.current = c
+	if c.Date.After(s.end) {
+		s.end = c.Date
+	}
+}
+
+// AddBlame records lines added and deleted by the current commit in the file.
+func (s *CodeSurvival) AddBlame(r BlameResult) {
+	if r.Language != "" {
+		s.languages[r.Filename] = r.Language
+	}
+	for origin, n := range r.DeletedLines {
+		oc, ok := s.commits[origin]
+		if !ok {
+			// added before the first processed commit
+			continue
+		}
+		age := s.current.Date.Sub(oc.Date)
+		for _, g := range s.fileGroups(r.Filename, oc.AuthorEmail) {
+			// file could be renamed to a different directory since lines were added
+			d := n
+			if d > g.alive[origin] {
+				d = g.alive[origin]
+			}
+			if d == 0 {
+				continue
+			}
+			g.alive[origin] -= d
+			g.LinesDeleted += d
+			g.events = append(g.events, survivalEvent{age: age, lines: d})
+		}
+	}
+	if r.Status == GitFileCommitStatusRemoved {
+		delete(s.languages, r.Filename)
+	}
+	added := 0
+	for _, l := range r.Lines {
+		if l.SHA == s.current.SHA {
+			added++
+		}
+	}
+	if added == 0 {
+		return
+	}
+	for _, g := range s.fileGroups(r.Filename, s.current.AuthorEmail) {
+		g.alive[s.current.SHA] += added
+		g.LinesAdded += added
+	}
+}
+
+// Result returns survival by author, directory and language, sorted by dimension and key.
+func (s *CodeSurvival) Result() (res []SurvivalGroup) {
+	for _, g := range s.groups {
+		r := g.SurvivalGroup
+		if r.LinesAdded == 0 {
+			continue
+		}
+		events := append([]survivalEvent(nil), g.events...)
+		var deletedAge time.Duration
+		for _, e := range events {
+			deletedAge += e.age * time.Duration(e.lines)
+		}
+		if r.LinesDeleted != 0 {
+			r.MeanDeletedAge = deletedAge / time.Duration(r.LinesDeleted)
+		}
+		for origin, n := range g.alive {
+			if n == 0 {
+				continue
+			}
+			events = append(events, survivalEvent{age: s.end.Sub(s.commits[origin].Date), lines: n, censored: true})
+		}
+		r.HalfLife = halfLife(events, r.LinesAdded)
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a := res[i]
+		b := res[j]
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		return a.Key < b.Key
+	})
+	return
+}
+
+// halfLife returns the age at which Kaplan-Meier survival estimate drops to 0.5 or below, or 0 if it does not.
+func halfLife(events []survivalEvent, total int) time.Duration {
+	sort.Slice(events, func(i, j int) bool {
+		a := events[i]
+		b := events[j]
+		if a.age != b.age {
+			return a.age < b.age
+		}
+		// deletions before censoring at the same age
+		return !a.censored && b.censored
+	})
+	atRisk := total
+	survival := 1.0
+	for i := 0; i < len(events); {
+		age := events[i].age
+		deleted := 0
+		censored := 0
+		for ; i < len(events) && events[i].age == age; i++ {
+			if events[i].censored {
+				censored += events[i].lines
+			} else {
+				deleted += events[i].lines
+			}
+		}
+		if deleted != 0 && atRisk != 0 {
+			survival *= 1 - float64(deleted)/float64(atRisk)
+			if survival <= 0.5 {
+				return age
+			}
+		}
+		atRisk -= deleted + censored
+	}
+	return 0
+}
+
+// CodeSurvival processes the repo using CodeByCommit with TrackDeletions and returns how long added lines survive by author, directory and language.
+// Uses and updates checkpoints the same way as CodeByCommit. Results are sent after all commits are processed.
+func (s *Ripsrc) CodeSurvival(ctx context.Context, res chan SurvivalGroup) error {
+	defer close(res)
+	s.opts.TrackDeletions = true
+	agg := NewCodeSurvival()
+	agg.CommitDate = s.opts.CommitDate
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			agg.AddCommit(c.Commit)
+			for b := range c.Blames {
+				agg.AddBlame(b)
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+	for _, r := range agg.Result() {
+		select {
+		case res <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) CodeSurvivalSlice(ctx context.Context) (res []SurvivalGroup, _ error) {
+	resChan := make(chan SurvivalGroup)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.CodeSurvival(ctx, resChan)
+	<-done
+	return res, err
+}
@@ -0,0 +1,53 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestHalfLife(t *testing.T) {
+	h := time.Hour
+	cases := []struct {
+		name   string
+		events []survivalEvent
+		total  int
+		want   time.Duration
+	}{
+		{"no deletions", []survivalEvent{{age: h, lines: 4, censored: true}}, 4, 0},
+		{"half deleted", []survivalEvent{{age: h, lines: 1}, {age: 2 * h, lines: 1}, {age: 3 * h, lines: 2, censored: true}}, 4, 2 * h},
+		// censored lines leave the risk set, so the second deletion drops survival to 1/2 * 3/4 * 2/3
+		{"censored", []survivalEvent{{age: h, lines: 1}, {age: 2 * h, lines: 1, censored: true}, {age: 3 * h, lines: 1}, {age: 4 * h, lines: 1, censored: true}}, 4, 3 * h},
+	}
+	for _, c := range cases {
+		got := halfLife(c.events, c.total)
+		if got != c.want {
+			t.Errorf("%v: wanted %v got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestCodeSurvival(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Author("A", "a@example.com").Write("src/a.go", "package a\n\nvar x = 1\nvar y = 2\n").Commit("c1")
+	r.Author("B", "b@example.com").Write("src/a.go", "package a\n\nvar x = 3\nvar y = 2\n").Commit("c2")
+	r.Author("B", "b@example.com").Write("src/a.go", "package a\n").Commit("c3")
+
+	got, err := New(Opts{RepoDir: r.Dir()}).CodeSurvivalSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SurvivalGroup{
+		{Dimension: SurvivalByAuthor, Key: "a@example.com", LinesAdded: 4, LinesDeleted: 3, HalfLife: 2 * time.Minute, MeanDeletedAge: 100 * time.Second},
+		{Dimension: SurvivalByAuthor, Key: "b@example.com", LinesAdded: 1, LinesDeleted: 1, HalfLife: time.Minute, MeanDeletedAge: time.Minute},
+		{Dimension: SurvivalByDirectory, Key: "src", LinesAdded: 5, LinesDeleted: 4, HalfLife: 2 * time.Minute, MeanDeletedAge: 90 * time.Second},
+		{Dimension: SurvivalByLanguage, Key: "Go", LinesAdded: 5, LinesDeleted: 4, HalfLife: 2 * time.Minute, MeanDeletedAge: 90 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
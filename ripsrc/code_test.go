@@ -0,0 +1,61 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCodeByCommitBackpressure(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	for i := 0; i < 20; i++ {
+		r.Write(fmt.Sprintf("f%v.txt", i), "a\n").Commit(fmt.Sprintf("c%v", i))
+	}
+	for _, concurrency := range []int{0, 4} {
+		t.Run(fmt.Sprint("concurrency", concurrency), func(t *testing.T) {
+			rip := New(Opts{RepoDir: r.Dir(), SegmentConcurrency: concurrency})
+			var processed int32
+			rip.onProcessResult = func(process.Result, commitmeta.Commit) {
+				atomic.AddInt32(&processed, 1)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			res := make(chan CommitCode)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- rip.CodeByCommit(ctx, res)
+			}()
+
+			// receive one commit with its blames and stop reading
+			c := <-res
+			for range c.Blames {
+			}
+			time.Sleep(200 * time.Millisecond)
+			// the returned commit and the one waiting to be sent
+			if n := atomic.LoadInt32(&processed); n > 2 {
+				t.Fatalf("pipeline did not pause while caller was not reading, commits past blame: %v", n)
+			}
+
+			// remaining commits are returned once caller reads again
+			got := 1
+			for c := range res {
+				for range c.Blames {
+				}
+				got++
+			}
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+			if got != 20 {
+				t.Fatalf("wanted 20 commits, got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package ripsrc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
+)
+
+// codeInfoTraceBatch groups commits processed by code info into spans of tracing.BatchSize commits.
+type codeInfoTraceBatch struct {
+	ctx     context.Context
+	tracer  tracing.Tracer
+	repoDir string
+
+	span       tracing.Span
+	index      int
+	commits    int
+	lastCommit string
+}
+
+func newCodeInfoTraceBatch(ctx context.Context, tracer tracing.Tracer, repoDir string) *codeInfoTraceBatch {
+	s := &codeInfoTraceBatch{}
+	s.ctx = ctx
+	s.tracer = tracer
+	s.repoDir = repoDir
+	return s
+}
+
+func (s *codeInfoTraceBatch) Commit(commit string) {
+	if s.span != nil && s.commits >= tracing.BatchSize {
+		s.End()
+	}
+	if s.span == nil {
+		_, s.span = s.tracer.Start(s.ctx, tracing.SpanCodeInfoBatch, "repo", s.repoDir, "batch", strconv.Itoa(s.index), "first_commit", commit)
+		s.index++
+	}
+	s.commits++
+	s.lastCommit = commit
+}
+
+func (s *codeInfoTraceBatch) End() {
+	if s.span == nil {
+		return
+	}
+	s.span.SetAttributes("last_commit", s.lastCommit, "commits", strconv.Itoa(s.commits))
+	s.span.End()
+	s.span = nil
+	s.commits = 0
+}
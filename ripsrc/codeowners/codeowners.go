@@ -0,0 +1,191 @@
+// Package codeowners parses CODEOWNERS files in GitHub and GitLab syntax and finds owners of files.
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Locations are paths where CODEOWNERS file is looked for, in order of priority. GitHub and GitLab use the first one found.
+var Locations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	sections []*section
+	// InvalidLines are line numbers, starting from 1, of lines that could not be parsed and were ignored.
+	InvalidLines []int
+}
+
+// section is a GitLab section. GitHub files have a single unnamed section.
+type section struct {
+	defaultOwners []string
+	rules         []rule
+}
+
+type rule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+// Parse parses CODEOWNERS file content. Lines that could not be parsed are ignored and recorded in InvalidLines, the same as GitHub and GitLab do.
+func Parse(data []byte) *File {
+	res := &File{}
+	cur := &section{}
+	res.sections = append(res.sections, cur)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			s, ok := parseSection(line)
+			if !ok {
+				res.InvalidLines = append(res.InvalidLines, lineNo)
+				continue
+			}
+			cur = s
+			res.sections = append(res.sections, cur)
+			continue
+		}
+		fields := splitFields(line)
+		re, err := compilePattern(fields[0])
+		if err != nil {
+			res.InvalidLines = append(res.InvalidLines, lineNo)
+			continue
+		}
+		r := rule{re: re, owners: fields[1:]}
+		if len(r.owners) == 0 {
+			r.owners = cur.defaultOwners
+		}
+		cur.rules = append(cur.rules, r)
+	}
+	return res
+}
+
+// parseSection parses GitLab section header, such as [Docs], ^[Docs][2] @docs-team.
+func parseSection(line string) (res *section, ok bool) {
+	line = strings.TrimPrefix(line, "^")
+	end := strings.Index(line, "]")
+	if end == -1 {
+		return nil, false
+	}
+	res = &section{}
+	rest := line[end+1:]
+	// optional number of required approvals
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, false
+		}
+		rest = rest[end+1:]
+	}
+	res.defaultOwners = strings.Fields(rest)
+	return res, true
+}
+
+// splitFields splits line by whitespace, keeping escaped spaces in the pattern and removing trailing comment.
+func splitFields(line string) (res []string) {
+	var cur strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '#':
+			if cur.Len() != 0 {
+				res = append(res, cur.String())
+			}
+			return
+		case c == ' ' || c == '\t':
+			if cur.Len() != 0 {
+				res = append(res, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if cur.Len() != 0 {
+		res = append(res, cur.String())
+	}
+	return
+}
+
+// compilePattern converts gitignore style pattern to regexp matching file paths relative to repo root.
+// Patterns without a slash, other than a trailing one, match at any depth. Patterns matching a directory also match all files in it, unless the pattern ends with a wildcard.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	p := strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	switch {
+	case dirOnly:
+		re.WriteString("/.*$")
+	case strings.HasSuffix(p, "*"):
+		// docs/* matches files in docs, but not in its subdirectories
+		re.WriteString("$")
+	default:
+		re.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(re.String())
+}
+
+// Owners returns owners of the file, such as @user, @org/team or email. Returns nil if file has no owner.
+// The last matching rule in each section wins. With GitLab sections, owners from all sections are combined.
+func (s *File) Owners(path string) (res []string) {
+	seen := map[string]bool{}
+	for _, sec := range s.sections {
+		for i := len(sec.rules) - 1; i >= 0; i-- {
+			r := sec.rules[i]
+			if !r.re.MatchString(path) {
+				continue
+			}
+			for _, o := range r.owners {
+				if seen[o] {
+					continue
+				}
+				seen[o] = true
+				res = append(res, o)
+			}
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package codeowners
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnersGitHub(t *testing.T) {
+	f := Parse([]byte(`# comment
+*       @global-owner1 @global-owner2
+*.js    @js-owner #inline comment
+**/logs @logs-team
+/build/logs/ @doctocat
+docs/*  docs@example.com
+apps/   @octocat
+/scripts/ @doctocat @octocat
+/apps/github
+My\ File.txt @spaces
+`))
+	cases := []struct {
+		path string
+		want []string
+	}{
+		{"README.md", []string{"@global-owner1", "@global-owner2"}},
+		{"src/a.js", []string{"@js-owner"}},
+		{"build/logs/x.txt", []string{"@doctocat"}},
+		{"docs/getting-started.md", []string{"docs@example.com"}},
+		{"docs/build-app/troubleshooting.md", []string{"@global-owner1", "@global-owner2"}},
+		{"x/apps/a.go", []string{"@octocat"}},
+		{"apps/github/a.go", nil},
+		{"scripts/a.sh", []string{"@doctocat", "@octocat"}},
+		{"deep/logs/a.txt", []string{"@logs-team"}},
+		{"My File.txt", []string{"@spaces"}},
+	}
+	for _, c := range cases {
+		got := f.Owners(c.path)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("path %v: got %v, want %v", c.path, got, c.want)
+		}
+	}
+	if len(f.InvalidLines) != 0 {
+		t.Errorf("unexpected invalid lines %v", f.InvalidLines)
+	}
+}
+
+func TestOwnersGitLabSections(t *testing.T) {
+	f := Parse([]byte(`* @default
+
+[Docs] @docs-team
+docs/
+README.md @writer
+
+^[Backend][2] @backend
+*.go
+internal/ @core
+
+[Broken
+`))
+	cases := []struct {
+		path string
+		want []string
+	}{
+		{"docs/a.md", []string{"@default", "@docs-team"}},
+		{"README.md", []string{"@default", "@writer"}},
+		{"cmd/main.go", []string{"@default", "@backend"}},
+		{"internal/x.go", []string{"@default", "@core"}},
+		{"Makefile", []string{"@default"}},
+	}
+	for _, c := range cases {
+		got := f.Owners(c.path)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("path %v: got %v, want %v", c.path, got, c.want)
+		}
+	}
+	if !reflect.DeepEqual(f.InvalidLines, []int{11}) {
+		t.Errorf("expected invalid section header on line 11, got %v", f.InvalidLines)
+	}
+}
@@ -0,0 +1,32 @@
+package ripsrc
+
+import "time"
+
+// CommitDate selects which commit date is used for time bucketing, ages and ordering of commits. See Opts.CommitDate.
+// Author and committer dates differ for rebased, amended or cherry-picked commits.
+type CommitDate string
+
+const (
+	// CommitDateDefault uses author date for time bucketing and ages, commits are ordered by committer date. This is the default.
+	CommitDateDefault = CommitDate("")
+	// CommitDateAuthor uses author date for time bucketing, ages and ordering.
+	CommitDateAuthor = CommitDate("author")
+	// CommitDateCommitter uses committer date for time bucketing, ages and ordering.
+	CommitDateCommitter = CommitDate("committer")
+)
+
+// Of returns the selected date of the commit.
+func (s CommitDate) Of(c Commit) time.Time {
+	if s == CommitDateCommitter {
+		return c.CommitterDate
+	}
+	return c.Date
+}
+
+func (s CommitDate) valid() bool {
+	switch s {
+	case CommitDateDefault, CommitDateAuthor, CommitDateCommitter:
+		return true
+	}
+	return false
+}
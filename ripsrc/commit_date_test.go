@@ -0,0 +1,88 @@
+package ripsrc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCommitDate(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("b")
+	picked := r.Write("b.txt", "b\n").Commit("c2")
+	r.Checkout("master")
+	r.Write("a.txt", "a2\n").Commit("c3")
+	// keeps author date of c2, uses current date as committer date
+	r.Git("cherry-pick", picked)
+	head := r.Head()
+
+	dates := strings.Fields(r.Git("log", "-1", "--format=%aI %cI"))
+	authorDate, err := time.Parse(time.RFC3339, dates[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	committerDate, err := time.Parse(time.RFC3339, dates[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorDate.Equal(committerDate) {
+		t.Fatal("expected author and committer dates to differ")
+	}
+
+	run := func(commitDate CommitDate) (res Commit, lineDate time.Time) {
+		t.Helper()
+		commits := make(chan CommitCode)
+		done := make(chan bool)
+		go func() {
+			for c := range commits {
+				for b := range c.Blames {
+					if c.SHA == head && b.Filename == "b.txt" {
+						lineDate = b.Lines[0].Date
+					}
+				}
+				if c.SHA == head {
+					res = c.Commit
+				}
+			}
+			done <- true
+		}()
+		err := New(Opts{RepoDir: r.Dir(), CommitDate: commitDate}).CodeByCommit(context.Background(), commits)
+		<-done
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	c, lineDate := run(CommitDateDefault)
+	if !c.Date.Equal(authorDate) || !c.CommitterDate.Equal(committerDate) {
+		t.Errorf("unexpected commit dates, author %v committer %v", c.Date, c.CommitterDate)
+	}
+	if !lineDate.Equal(authorDate) {
+		t.Errorf("expected line date to be author date %v, got %v", authorDate, lineDate)
+	}
+	_, lineDate = run(CommitDateCommitter)
+	if !lineDate.Equal(committerDate) {
+		t.Errorf("expected line date to be committer date %v, got %v", committerDate, lineDate)
+	}
+}
+
+func TestCommitDateOf(t *testing.T) {
+	d1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	d2 := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	c := Commit{Date: d1, CommitterDate: d2}
+	if !CommitDateDefault.Of(c).Equal(d1) || !CommitDateAuthor.Of(c).Equal(d1) {
+		t.Errorf("expected author date")
+	}
+	if !CommitDateCommitter.Of(c).Equal(d2) {
+		t.Errorf("expected committer date")
+	}
+	if err := (Opts{CommitDate: "x"}).validateFlags(); err == nil {
+		t.Errorf("expected error for invalid CommitDate")
+	}
+}
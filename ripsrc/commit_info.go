@@ -2,21 +2,73 @@ package ripsrc
 
 import (
 	"context"
+	"time"
 
 	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
 )
 
-func (s *Ripsrc) getCommitInfo(ctx context.Context, wantedBranchRefs []string) error {
+func (s *Ripsrc) commitMetaOpts() commitmeta.Opts {
 	copts := commitmeta.Opts{}
 	copts.CommitFromIncl = s.opts.CommitFromIncl
 	copts.CommitFromMakeNonIncl = s.opts.CommitFromMakeNonIncl
 	copts.AllBranches = s.opts.AllBranches
-	copts.WantedBranchRefs = wantedBranchRefs
-	cm := commitmeta.New(s.opts.RepoDir, copts)
-	res, err := cm.RunMap()
+	copts.Refs = s.opts.Refs
+	copts.BlobSizes = s.opts.CommitFileSizes
+	copts.Signatures = s.opts.CommitSignatures
+	copts.Trailers = s.opts.CommitTrailers || s.opts.InlineCommitMeta == InlineCommitMetaFull
+	return copts
+}
+
+func (s *Ripsrc) getCommitInfo(ctx context.Context) error {
+	start := time.Now()
+	_, span := s.opts.Tracer.Start(ctx, tracing.SpanCommitMeta, "repo", s.opts.RepoDir)
+	defer func() {
+		span.End()
+		s.opts.Metrics.Duration(metrics.StageDuration, time.Since(start), "stage", metrics.StageCommitMeta)
+	}()
+	cm := commitmeta.New(s.opts.RepoDir, s.commitMetaOpts())
+	res, err := cm.RunSliceContext(ctx)
 	if err != nil {
 		return err
 	}
-	s.commitMeta = res
+	s.commitMeta = map[string]commitmeta.Commit{}
+	s.commitOrder = nil
+	for _, c := range res {
+		s.commitMeta[c.SHA] = c
+		s.commitOrder = append(s.commitOrder, c.SHA)
+	}
+	s.excludeAuthors()
+	if s.anonymizer != nil || s.redactor != nil {
+		for sha, c := range s.commitMeta {
+			s.commitMeta[sha] = s.scrubCommit(c)
+		}
+	}
+	return nil
+}
+
+// sendCommitsOnly returns commits in git log order without processing the history, used when StageBlame is disabled.
+func (s *Ripsrc) sendCommitsOnly(ctx context.Context, res chan CommitCode, releasedInTag map[string]string) error {
+	for _, sha := range s.commitOrder {
+		rc := CommitCode{}
+		rc.Commit = s.commitMeta[sha]
+		if s.skipCommit(rc.Commit) {
+			continue
+		}
+		rc.ReleasedInTag = releasedInTag[sha]
+		rc.Blames = make(chan BlameResult)
+		s.opts.Metrics.Counter(metrics.CommitsProcessed, 1)
+		s.enrich(ctx, &rc)
+		s.runCounts.add(rc, nil)
+		err := s.toSinks(ctx, rc, nil)
+		if err != nil {
+			return err
+		}
+		err = sendCommitCode(ctx, res, rc, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/types"
 )
 
 type Opts struct {
@@ -29,6 +30,19 @@ type Opts struct {
 
 	// AllBranches set to true to process all branches. If false, processes commits reachable from HEAD only.
 	AllBranches bool
+
+	// Refs is a list of branches, tags or shas. If set, processes commits reachable from these refs only. Takes precedence over AllBranches.
+	Refs []string
+
+	// BlobSizes set to true to fill Size and SizePrev of commit files. Sizes are retrieved using git cat-file --batch-check.
+	BlobSizes bool
+
+	// Signatures set to true to fill Commit.Signature. Verification runs gpg or ssh-keygen for each signed commit, which is slow for large repos.
+	// Keys and trust are taken from repo config and environment, for example gpg.ssh.allowedSignersFile or GNUPGHOME.
+	Signatures bool
+
+	// Trailers set to true to fill Commit.Trailers.
+	Trailers bool
 }
 
 type Processor struct {
@@ -47,63 +61,32 @@ func New(repoDir string, opts Opts) *Processor {
 }
 
 // Commit is a specific detail around a commit
-type Commit struct {
-	//Dir            string
-	SHA            string
-	AuthorName     string
-	AuthorEmail    string
-	CommitterName  string
-	CommitterEmail string
-
-	Date    time.Time
-	Ordinal int64
-	Message string
-
-	Parents []string
-	//Previous *Commit
-
-	Files map[string]*CommitFile
-}
-
-// Author returns either the author name (preference) or the email if not found
-func (c Commit) Author() string {
-	if c.AuthorName != "" {
-		return c.AuthorName
-	}
-	return c.AuthorEmail
-}
+type Commit = types.Commit
 
 // CommitFile is a specific detail around a file in a commit
-type CommitFile struct {
-	Filename    string
-	Status      CommitStatus
-	Renamed     bool
-	Copied      bool
-	RenamedFrom string
-	RenamedTo   string
-	CopiedFrom  string
-	Additions   int
-	Deletions   int
-	Binary      bool
-}
+type CommitFile = types.CommitFile
 
 // CommitStatus is a commit status type
-type CommitStatus string
+type CommitStatus = types.CommitStatus
+
+// Trailer is a key value line at the end of the commit message. Only set with Opts.Trailers.
+type Trailer = types.Trailer
 
 const (
 	// GitFileCommitStatusAdded is the added status
-	GitFileCommitStatusAdded = CommitStatus("added")
+	GitFileCommitStatusAdded = types.GitFileCommitStatusAdded
 	// GitFileCommitStatusModified is the modified status
-	GitFileCommitStatusModified = CommitStatus("modified")
+	GitFileCommitStatusModified = types.GitFileCommitStatusModified
 	// GitFileCommitStatusRemoved is the removed status
-	GitFileCommitStatusRemoved = CommitStatus("removed")
+	GitFileCommitStatusRemoved = types.GitFileCommitStatusRemoved
 )
 
-func (s CommitStatus) String() string {
-	return string(s)
+func (s *Processor) RunSlice() (res []Commit, _ error) {
+	return s.RunSliceContext(context.Background())
 }
 
-func (s *Processor) RunSlice() (res []Commit, _ error) {
+// RunSliceContext is the same as RunSlice, ctx is passed to git commands.
+func (s *Processor) RunSliceContext(ctx context.Context) (res []Commit, _ error) {
 	resChan := make(chan Commit)
 	done := make(chan bool)
 	go func() {
@@ -112,12 +95,17 @@ func (s *Processor) RunSlice() (res []Commit, _ error) {
 		}
 		done <- true
 	}()
-	err := s.Run(resChan)
+	err := s.RunContext(ctx, resChan)
 	<-done
 	return res, err
 }
 
 func (s *Processor) RunMap() (map[string]Commit, error) {
+	return s.RunMapContext(context.Background())
+}
+
+// RunMapContext is the same as RunMap, ctx is passed to git commands.
+func (s *Processor) RunMapContext(ctx context.Context) (map[string]Commit, error) {
 	res := map[string]Commit{}
 	resChan := make(chan Commit)
 	done := make(chan bool)
@@ -127,26 +115,41 @@ func (s *Processor) RunMap() (map[string]Commit, error) {
 		}
 		done <- true
 	}()
-	err := s.Run(resChan)
+	err := s.RunContext(ctx, resChan)
 	<-done
 	return res, err
 }
 
 func (s *Processor) Run(res chan Commit) error {
+	return s.RunContext(context.Background(), res)
+}
+
+// RunContext is the same as Run, ctx is passed to git commands.
+func (s *Processor) RunContext(ctx context.Context, res chan Commit) error {
 	defer close(res)
-	r, err := s.gitLog()
+	r, err := s.gitLog(ctx)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
 	var parser parser
+	parser.ctx = ctx
 	parser.dir = s.repoDir
 	//parser.limit = limit
 	parser.commits = res
 
+	if s.opts.BlobSizes {
+		parser.blobs, err = gitexec.NewCatFileCheck(ctx, s.gitCommand, s.repoDir)
+		if err != nil {
+			return err
+		}
+		defer parser.blobs.Close()
+	}
+
 	// we don't need this in new code. TODO: check and remove
 	fjChan := make(chan *CommitFile, 100)
+	defer close(fjChan)
 	go func() {
 		for range fjChan {
 
@@ -164,14 +167,20 @@ func (s *Processor) Run(res chan Commit) error {
 			break
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading git log from %v. %v", s.repoDir, err)
+	}
 	if parser.commit != nil && parser.commit.SHA != "" { // because we send when we detect the next commit
-		res <- *parser.commit
+		err := parser.send()
+		if err != nil {
+			return fmt.Errorf("error processing commit from %v. %v", s.repoDir, err)
+		}
 	}
 
 	return nil
 }
 
-func (s *Processor) gitLog() (io.ReadCloser, error) {
+func (s *Processor) gitLog(ctx context.Context) (io.ReadCloser, error) {
 	// empty file at tem location to set an empty attributesFile
 	f, err := ioutil.TempFile("", "ripsrc")
 	if err != nil {
@@ -188,12 +197,23 @@ func (s *Processor) gitLog() (io.ReadCloser, error) {
 		"log",
 		"-c",
 		"--raw",
+		"--no-abbrev",
 		"--reverse",
 		"--numstat",
-		"--pretty=format:!SHA: %H%n!Parents: %P%n!Committer: %ce%n!CName: %cn%n!Author: %ae%n!AName: %an%n!Date: %aI%n!Message: %s%n",
+		"--pretty=format:!SHA: %H%n!Parents: %P%n!Committer: %ce%n!CName: %cn%n!Author: %ae%n!AName: %an%n!Date: %aI%n!CDate: %cI%n" + s.signatureFormat() + s.trailersFormat() + "!Message: %s%n",
 	}
 
-	if s.opts.CommitFromIncl != "" {
+	if len(s.opts.Refs) != 0 {
+		args = append(args, s.opts.Refs...)
+		if s.opts.CommitFromIncl != "" {
+			if s.opts.CommitFromMakeNonIncl {
+				args = append(args, "^"+s.opts.CommitFromIncl)
+			} else {
+				args = append(args, "^"+s.opts.CommitFromIncl+"^")
+			}
+		}
+		args = append(args, "--")
+	} else if s.opts.CommitFromIncl != "" {
 		if s.opts.AllBranches {
 			for _, c := range s.opts.WantedBranchRefs {
 				args = append(args, c)
@@ -212,27 +232,62 @@ func (s *Processor) gitLog() (io.ReadCloser, error) {
 		}
 	}
 
-	return gitexec.ExecPiped(context.Background(), s.gitCommand, s.repoDir, args)
+	return gitexec.ExecPiped(ctx, s.gitCommand, s.repoDir, args)
+}
+
+// signatureFormat returns git log format for signature lines, empty unless Opts.Signatures is set.
+func (s *Processor) signatureFormat() string {
+	if !s.opts.Signatures {
+		return ""
+	}
+	return "!Sig: %G?%n!SigKey: %GK%n!Signer: %GS%n!SigFingerprint: %GF%n"
+}
+
+// trailersFormat returns git log format for trailers line, empty unless Opts.Trailers is set. Trailers are unfolded to a single line each and separated by trailerSeparator.
+func (s *Processor) trailersFormat() string {
+	if !s.opts.Trailers {
+		return ""
+	}
+	return "!Trailers: %(trailers:only,unfold,separator=%x1f)%n"
+}
+
+const trailerSeparator = "\x1f"
+
+func parseTrailers(line string) (res []Trailer) {
+	for _, t := range strings.Split(line, trailerSeparator) {
+		i := strings.Index(t, ":")
+		if i == -1 {
+			continue
+		}
+		res = append(res, Trailer{Key: strings.TrimSpace(t[:i]), Value: strings.TrimSpace(t[i+1:])})
+	}
+	return
 }
 
 var (
-	commitPrefix        = []byte("!SHA: ")
-	authorPrefix        = []byte("!Author: ")
-	authorNamePrefix    = []byte("!AName: ")
-	committerPrefix     = []byte("!Committer: ")
-	committerNamePrefix = []byte("!CName: ")
-	messagePrefix       = []byte("!Message: ")
-	parentsPrefix       = []byte("!Parents: ")
-	emailRegex          = regexp.MustCompile("<(.*)>")
-	emailBracketsRegex  = regexp.MustCompile("^\\[(.*)\\]$")
-	datePrefix          = []byte("!Date: ")
-	space               = []byte(" ")
-	tab                 = []byte("\t")
-	removePrefix        = []byte("R")
-	copyPrefix          = []byte("C")
-	filenameMask        = regexp.MustCompile("^(100644|100755)$")
-	deletedMask         = []byte("000000")
-	renameRe            = regexp.MustCompile("(.*)\\{(.*) => (.*)\\}(.*)")
+	commitPrefix         = []byte("!SHA: ")
+	authorPrefix         = []byte("!Author: ")
+	authorNamePrefix     = []byte("!AName: ")
+	committerPrefix      = []byte("!Committer: ")
+	committerNamePrefix  = []byte("!CName: ")
+	messagePrefix        = []byte("!Message: ")
+	parentsPrefix        = []byte("!Parents: ")
+	emailRegex           = regexp.MustCompile("<(.*)>")
+	emailBracketsRegex   = regexp.MustCompile("^\\[(.*)\\]$")
+	datePrefix           = []byte("!Date: ")
+	committerDatePrefix  = []byte("!CDate: ")
+	sigPrefix            = []byte("!Sig: ")
+	sigKeyPrefix         = []byte("!SigKey: ")
+	signerPrefix         = []byte("!Signer: ")
+	sigFingerprintPrefix = []byte("!SigFingerprint: ")
+	trailersPrefix       = []byte("!Trailers: ")
+	space                = []byte(" ")
+	tab                  = []byte("\t")
+	removePrefix         = []byte("R")
+	copyPrefix           = []byte("C")
+	filenameMask         = regexp.MustCompile("^(100644|100755)$")
+	deletedMask          = []byte("000000")
+	renameRe             = regexp.MustCompile("(.*)\\{(.*) => (.*)\\}(.*)")
 )
 
 func toCommitStatus(name []byte) CommitStatus {
@@ -252,7 +307,9 @@ func parseDate(d string) (time.Time, error) {
 	if err != nil {
 		return time.Now(), fmt.Errorf("error parsing commit date `%v`. %v", d, err)
 	}
-	return t, nil
+	// time.Parse uses local location when offset matches it, keep the original offset as is
+	_, offset := t.Zone()
+	return t.In(time.FixedZone("", offset)), nil
 }
 
 func parseEmail(email string) string {
@@ -319,6 +376,8 @@ const (
 )
 
 type parser struct {
+	// ctx stops sending commits when canceled
+	ctx      context.Context
 	commits  chan<- Commit
 	filejobs chan<- *CommitFile
 	commit   *Commit
@@ -327,6 +386,42 @@ type parser struct {
 	total    int
 	ordinal  int64
 	state    parserState
+	// blobs is used to get file sizes, nil unless Opts.BlobSizes is set
+	blobs *gitexec.CatFileCheck
+}
+
+// send fills file sizes if needed and sends the current commit.
+func (p *parser) send() error {
+	if p.blobs != nil {
+		for _, f := range p.commit.Files {
+			var err error
+			f.Size, err = p.blobSize(f.BlobSHA)
+			if err != nil {
+				return err
+			}
+			f.SizePrev, err = p.blobSize(f.BlobSHAPrev)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	select {
+	case p.commits <- *p.commit:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+	return nil
+}
+
+func (p *parser) blobSize(sha string) (int64, error) {
+	if sha == "" {
+		return 0, nil
+	}
+	obj, err := p.blobs.Info(sha)
+	if err != nil {
+		return 0, fmt.Errorf("could not get blob size for commit %v err: %v", p.commit.SHA, err)
+	}
+	return obj.Size, nil
 }
 
 func (p *parser) parse(line string) (bool, error) {
@@ -352,7 +447,10 @@ func (p *parser) parse(line string) (bool, error) {
 				// send the old commit and create a new one
 				if p.commit != nil && p.commit.SHA != "" { // because we send when we detect the next commit
 					//parentCommit = p.commit
-					p.commits <- *p.commit
+					err := p.send()
+					if err != nil {
+						return false, err
+					}
 					p.commit = nil
 				}
 				if p.limit > 0 && p.total == p.limit {
@@ -386,6 +484,15 @@ func (p *parser) parse(line string) (bool, error) {
 				p.commit.Date = t
 				return true, nil
 			}
+			if bytes.HasPrefix(buf, committerDatePrefix) {
+				d := bytes.TrimSpace(buf[len(committerDatePrefix):])
+				t, err := parseDate(string(d))
+				if err != nil {
+					return false, fmt.Errorf("error parsing commit %s in %s. %v", p.commit.SHA, p.dir, err)
+				}
+				p.commit.CommitterDate = t
+				return true, nil
+			}
 			if bytes.HasPrefix(buf, authorPrefix) {
 				p.commit.AuthorEmail = string(buf[len(authorPrefix):])
 				return true, nil
@@ -402,6 +509,26 @@ func (p *parser) parse(line string) (bool, error) {
 				p.commit.CommitterName = string(buf[len(committerNamePrefix):])
 				return true, nil
 			}
+			if bytes.HasPrefix(buf, sigPrefix) {
+				p.commit.Signature.Status = SignatureStatus(buf[len(sigPrefix):])
+				return true, nil
+			}
+			if bytes.HasPrefix(buf, sigKeyPrefix) {
+				p.commit.Signature.KeyID = string(buf[len(sigKeyPrefix):])
+				return true, nil
+			}
+			if bytes.HasPrefix(buf, signerPrefix) {
+				p.commit.Signature.Signer = string(buf[len(signerPrefix):])
+				return true, nil
+			}
+			if bytes.HasPrefix(buf, sigFingerprintPrefix) {
+				p.commit.Signature.Fingerprint = string(buf[len(sigFingerprintPrefix):])
+				return true, nil
+			}
+			if bytes.HasPrefix(buf, trailersPrefix) {
+				p.commit.Trailers = parseTrailers(string(buf[len(trailersPrefix):]))
+				return true, nil
+			}
 			if bytes.HasPrefix(buf, messagePrefix) {
 				p.commit.Message = string(buf[len(messagePrefix):])
 				p.state = parserStateFiles
@@ -417,14 +544,19 @@ func (p *parser) parse(line string) (bool, error) {
 				if !filenameMask.Match(mask) && !bytes.Equal(mask, deletedMask) {
 					return true, nil
 				}
+				mode, blob := rawBlob(tok1)
+				blobPrev := rawBlobPrev(tok1)
 				tok2 := bytes.Split(bytes.Join(tok1[4:], space), tab)
 				action := tok2[0]
 				paths := tok2[1:]
 				if len(action) == 1 {
 					fn := string(bytes.TrimLeft(paths[0], " "))
 					cf := &CommitFile{
-						Filename: fn,
-						Status:   toCommitStatus(action),
+						Filename:    fn,
+						Status:      toCommitStatus(action),
+						BlobSHA:     blob,
+						BlobSHAPrev: blobPrev,
+						Mode:        mode,
 					}
 					p.commit.Files[fn] = cf
 					p.filejobs <- cf
@@ -439,6 +571,7 @@ func (p *parser) parse(line string) (bool, error) {
 						Renamed:     true,
 						RenamedFrom: fromFn,
 						RenamedTo:   toFn,
+						BlobSHAPrev: blobPrev,
 					}
 					cf := &CommitFile{
 						Status:      GitFileCommitStatusAdded,
@@ -446,6 +579,9 @@ func (p *parser) parse(line string) (bool, error) {
 						Renamed:     true,
 						RenamedFrom: fromFn,
 						RenamedTo:   toFn,
+						BlobSHA:     blob,
+						BlobSHAPrev: blobPrev,
+						Mode:        mode,
 					}
 					p.commit.Files[toFn] = cf
 					p.filejobs <- cf
@@ -454,18 +590,24 @@ func (p *parser) parse(line string) (bool, error) {
 					fromFn := string(bytes.TrimLeft(paths[0], " "))
 					toFn := string(bytes.TrimLeft(paths[1], " "))
 					cf := &CommitFile{
-						Status:     GitFileCommitStatusAdded,
-						Filename:   toFn,
-						Copied:     true,
-						CopiedFrom: fromFn,
+						Status:      GitFileCommitStatusAdded,
+						Filename:    toFn,
+						Copied:      true,
+						CopiedFrom:  fromFn,
+						BlobSHA:     blob,
+						BlobSHAPrev: blobPrev,
+						Mode:        mode,
 					}
 					p.commit.Files[toFn] = cf
 					p.filejobs <- cf
 				} else {
 					fn := string(bytes.TrimLeft(paths[0], " "))
 					cf := &CommitFile{
-						Status:   toCommitStatus(action),
-						Filename: fn,
+						Status:      toCommitStatus(action),
+						Filename:    fn,
+						BlobSHA:     blob,
+						BlobSHAPrev: blobPrev,
+						Mode:        mode,
 					}
 					p.commit.Files[fn] = cf
 					p.filejobs <- cf
@@ -512,8 +654,60 @@ func (p *parser) parse(line string) (bool, error) {
 				p.state = parserStateHeader
 				continue
 			}
+			if buf[0] == ':' {
+				// merges with -c print combined raw lines after numstat
+				// ::100644 100644 100644 1dbddb0... 4cd4b38... 904d55b... MM	main.go
+				i := bytes.IndexByte(buf, '\t')
+				if i == -1 {
+					return true, nil
+				}
+				file := p.commit.Files[string(buf[i+1:])]
+				if file == nil {
+					return true, nil
+				}
+				tok := bytes.Split(buf[:i], space)
+				file.Mode, file.BlobSHA = rawBlob(tok)
+				file.BlobSHAPrev = rawBlobPrev(tok)
+			}
 		}
 		break
 	}
 	return true, nil
 }
+
+// rawBlobPrev returns the blob sha before the commit, in the first parent for merges, from git log --raw line split by spaces. Returns empty value for added files.
+func rawBlobPrev(tok [][]byte) string {
+	if len(tok) == 0 {
+		return ""
+	}
+	parents := len(tok[0]) - len(bytes.TrimLeft(tok[0], ":"))
+	if len(tok) < 2*parents+2 {
+		return ""
+	}
+	blob := string(tok[parents+1])
+	if strings.Trim(blob, "0") == "" {
+		return ""
+	}
+	return blob
+}
+
+// rawBlob returns the mode and blob sha after the commit from git log --raw line split by spaces.
+// Lines of merges with -c start with one colon per parent and list modes and shas for each parent first.
+// :100644 100644 d1a02ae0... a452aaac... M
+// ::100644 100644 100644 1dbddb0... 4cd4b38... 904d55b... MM
+// Returns empty values for removed files.
+func rawBlob(tok [][]byte) (mode string, blob string) {
+	if len(tok) == 0 {
+		return
+	}
+	parents := len(tok[0]) - len(bytes.TrimLeft(tok[0], ":"))
+	if len(tok) < 2*parents+2 {
+		return
+	}
+	mode = string(tok[parents])
+	blob = string(tok[2*parents+1])
+	if bytes.Equal(tok[parents], deletedMask) || strings.Trim(blob, "0") == "" {
+		return "", ""
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package commitmeta
+
+import "github.com/pinpt/ripsrc/ripsrc/types"
+
+// SignatureStatus is the result of commit signature verification, same as %G? placeholder of git log.
+type SignatureStatus = types.SignatureStatus
+
+const (
+	// SignatureNone is returned for commits without signature.
+	SignatureNone = types.SignatureNone
+	// SignatureGood is returned for good and valid signature.
+	SignatureGood = types.SignatureGood
+	// SignatureBad is returned for bad signature.
+	SignatureBad = types.SignatureBad
+	// SignatureUnknownValidity is returned for good signature with unknown validity, for example from key that is not trusted.
+	SignatureUnknownValidity = types.SignatureUnknownValidity
+	// SignatureExpired is returned for good signature that has expired.
+	SignatureExpired = types.SignatureExpired
+	// SignatureExpiredKey is returned for good signature made by an expired key.
+	SignatureExpiredKey = types.SignatureExpiredKey
+	// SignatureRevokedKey is returned for good signature made by a revoked key.
+	SignatureRevokedKey = types.SignatureRevokedKey
+	// SignatureCannotCheck is returned when signature could not be checked, for example because of missing key.
+	SignatureCannotCheck = types.SignatureCannotCheck
+)
+
+// Signature holds GPG or SSH signature details of a commit. Only set with Opts.Signatures.
+type Signature = types.Signature
@@ -22,13 +22,18 @@ func TestBasic(t *testing.T) {
 		Filename:  "main.go",
 		Status:    commitmeta.GitFileCommitStatusAdded,
 		Additions: 8,
+		BlobSHA:   "43f941970b66c1040a17add12d9296142f89caca",
+		Mode:      "100644",
 	}
 
 	f2 := commitmeta.CommitFile{
-		Filename:  "main.go",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
-		Deletions: 3,
+		Filename:    "main.go",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		Deletions:   3,
+		BlobSHA:     "1671209982398cdf5ef7a16c529e203be8f5aabb",
+		BlobSHAPrev: "43f941970b66c1040a17add12d9296142f89caca",
+		Mode:        "100644",
 	}
 
 	commit1 := commitmeta.Commit{
@@ -40,10 +45,11 @@ func TestBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f1,
 		},
-		Message: "c1",
-		Date:    c1d,
-		Parents: nil,
-		Ordinal: 1,
+		Message:       "c1",
+		Date:          c1d,
+		CommitterDate: c1d,
+		Parents:       nil,
+		Ordinal:       1,
 	}
 
 	commit2 := commitmeta.Commit{
@@ -55,10 +61,11 @@ func TestBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f2,
 		},
-		Message: "c2",
-		Date:    c2d,
-		Parents: []string{"b4dadc54e312e976694161c2ac59ab76feb0c40d"},
-		Ordinal: 2,
+		Message:       "c2",
+		Date:          c2d,
+		CommitterDate: c2d,
+		Parents:       []string{"b4dadc54e312e976694161c2ac59ab76feb0c40d"},
+		Ordinal:       2,
 	}
 
 	want := []commitmeta.Commit{commit1, commit2}
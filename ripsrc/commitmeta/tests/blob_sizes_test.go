@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBlobSizes(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Write("b.bin", "\x00\x01").Commit("c1")
+	r.Write("a.txt", "a\nbb\n").Write("b.bin", "\x00").Commit("c2")
+	r.Delete("a.txt").Commit("c3")
+
+	got, err := commitmeta.New(r.Dir(), commitmeta.Opts{BlobSizes: true}).RunSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 commits, got %v", len(got))
+	}
+
+	type sizes struct {
+		Size, SizePrev, SizeChange int64
+		Changes                    int
+	}
+	get := func(c commitmeta.Commit, path string) sizes {
+		f := c.Files[path]
+		if f == nil {
+			t.Fatalf("file %v not found in commit %v", path, c.Message)
+		}
+		return sizes{f.Size, f.SizePrev, f.SizeChange(), f.Changes()}
+	}
+	assert := func(label string, want, got sizes) {
+		t.Helper()
+		if want != got {
+			t.Errorf("%v: wanted %+v, got %+v", label, want, got)
+		}
+	}
+	assert("added", sizes{2, 0, 2, 1}, get(got[0], "a.txt"))
+	assert("modified", sizes{5, 2, 3, 1}, get(got[1], "a.txt"))
+	assert("binary", sizes{1, 2, -1, 0}, get(got[1], "b.bin"))
+	assert("removed", sizes{0, 5, -5, 2}, get(got[2], "a.txt"))
+	if !got[1].Files["b.bin"].Binary {
+		t.Error("expected b.bin to be binary")
+	}
+
+	// sizes are not retrieved by default
+	got, err = commitmeta.New(r.Dir(), commitmeta.Opts{}).RunSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert("default", sizes{0, 0, 0, 1}, get(got[1], "a.txt"))
+}
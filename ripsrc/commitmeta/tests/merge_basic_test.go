@@ -19,6 +19,8 @@ func TestMergeBasic(t *testing.T) {
 		Filename:  "main.go",
 		Status:    commitmeta.GitFileCommitStatusAdded,
 		Additions: 4,
+		BlobSHA:   "1661cbb28614011d8612c4512affca2bd06db135",
+		Mode:      "100644",
 	}
 
 	commit1 := commitmeta.Commit{
@@ -30,17 +32,21 @@ func TestMergeBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f1,
 		},
-		Message: "base",
-		Date:    c1d,
-		Ordinal: 1,
+		Message:       "base",
+		Date:          c1d,
+		CommitterDate: c1d,
+		Ordinal:       1,
 	}
 
 	c2d := parseGitDate("Tue Dec 4 17:42:10 2018 +0100")
 
 	f2 := commitmeta.CommitFile{
-		Filename:  "main.go",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
+		Filename:    "main.go",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		BlobSHA:     "4cd4b38d3d8e5a4cd4a5989ba876321ec95077c3",
+		BlobSHAPrev: "1661cbb28614011d8612c4512affca2bd06db135",
+		Mode:        "100644",
 	}
 
 	commit2 := commitmeta.Commit{
@@ -52,18 +58,22 @@ func TestMergeBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f2,
 		},
-		Message: "a",
-		Date:    c2d,
-		Parents: []string{"cb78f81991af4120b649c5e2ae18cceba598220a"},
-		Ordinal: 2,
+		Message:       "a",
+		Date:          c2d,
+		CommitterDate: c2d,
+		Parents:       []string{"cb78f81991af4120b649c5e2ae18cceba598220a"},
+		Ordinal:       2,
 	}
 
 	c3d := parseGitDate("Tue Dec 4 17:42:29 2018 +0100")
 
 	f3 := commitmeta.CommitFile{
-		Filename:  "main.go",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
+		Filename:    "main.go",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		BlobSHA:     "1dbddb0365576318efec19371db7ab4745da12e5",
+		BlobSHAPrev: "1661cbb28614011d8612c4512affca2bd06db135",
+		Mode:        "100644",
 	}
 
 	commit3 := commitmeta.Commit{
@@ -75,18 +85,22 @@ func TestMergeBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f3,
 		},
-		Message: "m",
-		Date:    c3d,
-		Parents: []string{"cb78f81991af4120b649c5e2ae18cceba598220a"},
-		Ordinal: 3,
+		Message:       "m",
+		Date:          c3d,
+		CommitterDate: c3d,
+		Parents:       []string{"cb78f81991af4120b649c5e2ae18cceba598220a"},
+		Ordinal:       3,
 	}
 
 	c4d := parseGitDate("Tue Dec 4 17:42:55 2018 +0100")
 
 	f4 := commitmeta.CommitFile{
-		Filename:  "main.go",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
+		Filename:    "main.go",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		BlobSHA:     "904d55bde47e2f083b2d27b4befbacbf65c03e53",
+		BlobSHAPrev: "1dbddb0365576318efec19371db7ab4745da12e5",
+		Mode:        "100644",
 	}
 
 	commit4 := commitmeta.Commit{
@@ -98,10 +112,11 @@ func TestMergeBasic(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"main.go": &f4,
 		},
-		Message: "merge",
-		Date:    c4d,
-		Parents: []string{"3219b85f18fad2aa802344a2275bd8288916f4ee", "a08d204ee5913986294000e1280e7ad3484098e3"},
-		Ordinal: 4,
+		Message:       "merge",
+		Date:          c4d,
+		CommitterDate: c4d,
+		Parents:       []string{"3219b85f18fad2aa802344a2275bd8288916f4ee", "a08d204ee5913986294000e1280e7ad3484098e3"},
+		Ordinal:       4,
 	}
 
 	want := []commitmeta.Commit{commit1, commit2, commit3, commit4}
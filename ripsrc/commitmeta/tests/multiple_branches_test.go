@@ -17,6 +17,8 @@ func TestMultipleBranches1(t *testing.T) {
 		Filename:  "a.txt",
 		Status:    commitmeta.GitFileCommitStatusAdded,
 		Additions: 1,
+		BlobSHA:   "78981922613b2afb6025042ff6bd878ac1994e85",
+		Mode:      "100644",
 	}
 
 	commit1 := commitmeta.Commit{
@@ -28,15 +30,19 @@ func TestMultipleBranches1(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"a.txt": &f1,
 		},
-		Message: "c1",
-		Date:    parseGitDate("Mon Feb 4 12:58:55 2019 +0100"),
-		Ordinal: 1,
+		Message:       "c1",
+		Date:          parseGitDate("Mon Feb 4 12:58:55 2019 +0100"),
+		CommitterDate: parseGitDate("Mon Feb 4 12:58:55 2019 +0100"),
+		Ordinal:       1,
 	}
 
 	f2 := commitmeta.CommitFile{
-		Filename:  "a.txt",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
+		Filename:    "a.txt",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		BlobSHA:     "422c2b7ab3b3c668038da977e4e93a5fc623169c",
+		BlobSHAPrev: "78981922613b2afb6025042ff6bd878ac1994e85",
+		Mode:        "100644",
 	}
 
 	commit2 := commitmeta.Commit{
@@ -49,16 +55,20 @@ func TestMultipleBranches1(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"a.txt": &f2,
 		},
-		Message: "c2",
-		Date:    parseGitDate("Mon Feb 4 12:59:28 2019 +0100"),
-		Ordinal: 2,
+		Message:       "c2",
+		Date:          parseGitDate("Mon Feb 4 12:59:28 2019 +0100"),
+		CommitterDate: parseGitDate("Mon Feb 4 12:59:28 2019 +0100"),
+		Ordinal:       2,
 	}
 
 	f3 := commitmeta.CommitFile{
-		Filename:  "a.txt",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
-		Deletions: 1,
+		Filename:    "a.txt",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		Deletions:   1,
+		BlobSHA:     "e61ef7b965e17c62ca23b6ff5f0aaf09586e10e9",
+		BlobSHAPrev: "78981922613b2afb6025042ff6bd878ac1994e85",
+		Mode:        "100644",
 	}
 
 	commit3 := commitmeta.Commit{
@@ -71,15 +81,19 @@ func TestMultipleBranches1(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"a.txt": &f3,
 		},
-		Message: "c3",
-		Date:    parseGitDate("Mon Feb 4 12:59:42 2019 +0100"),
-		Ordinal: 3,
+		Message:       "c3",
+		Date:          parseGitDate("Mon Feb 4 12:59:42 2019 +0100"),
+		CommitterDate: parseGitDate("Mon Feb 4 12:59:42 2019 +0100"),
+		Ordinal:       3,
 	}
 
 	f4 := commitmeta.CommitFile{
-		Filename:  "a.txt",
-		Status:    commitmeta.GitFileCommitStatusModified,
-		Additions: 1,
+		Filename:    "a.txt",
+		Status:      commitmeta.GitFileCommitStatusModified,
+		Additions:   1,
+		BlobSHA:     "0f7bc766052a5a0ee28a393d51d2370f96d8ceb8",
+		BlobSHAPrev: "78981922613b2afb6025042ff6bd878ac1994e85",
+		Mode:        "100644",
 	}
 
 	commit4 := commitmeta.Commit{
@@ -92,9 +106,10 @@ func TestMultipleBranches1(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"a.txt": &f4,
 		},
-		Message: "c4",
-		Date:    parseGitDate("Mon Feb 4 13:00:29 2019 +0100"),
-		Ordinal: 4,
+		Message:       "c4",
+		Date:          parseGitDate("Mon Feb 4 13:00:29 2019 +0100"),
+		CommitterDate: parseGitDate("Mon Feb 4 13:00:29 2019 +0100"),
+		Ordinal:       4,
 	}
 
 	want := []commitmeta.Commit{commit1, commit2, commit3, commit4}
@@ -113,6 +128,8 @@ func TestMultipleBranchesDisabled(t *testing.T) {
 		Filename:  "a.txt",
 		Status:    commitmeta.GitFileCommitStatusAdded,
 		Additions: 1,
+		BlobSHA:   "78981922613b2afb6025042ff6bd878ac1994e85",
+		Mode:      "100644",
 	}
 
 	commit1 := commitmeta.Commit{
@@ -124,9 +141,10 @@ func TestMultipleBranchesDisabled(t *testing.T) {
 		Files: map[string]*commitmeta.CommitFile{
 			"a.txt": &f1,
 		},
-		Message: "c1",
-		Date:    parseGitDate("Mon Feb 4 13:06:01 2019 +0100"),
-		Ordinal: 1,
+		Message:       "c1",
+		Date:          parseGitDate("Mon Feb 4 13:06:01 2019 +0100"),
+		CommitterDate: parseGitDate("Mon Feb 4 13:06:01 2019 +0100"),
+		Ordinal:       1,
 	}
 
 	want := []commitmeta.Commit{commit1}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSignatures(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+	keys := t.TempDir()
+	for _, k := range []string{"trusted", "unknown"} {
+		out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", filepath.Join(keys, k)).CombinedOutput()
+		if err != nil {
+			t.Fatalf("could not generate key: %v %s", err, out)
+		}
+	}
+	pub, err := ioutil.ReadFile(filepath.Join(keys, "trusted.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := filepath.Join(keys, "allowed_signers")
+	err = ioutil.WriteFile(allowed, []byte("signer@example.com "+string(pub)), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Git("config", "gpg.format", "ssh")
+	r.Git("config", "gpg.ssh.allowedSignersFile", allowed)
+	r.Write("a.txt", "a\n").Commit("unsigned")
+	r.Git("config", "commit.gpgsign", "true")
+	r.Git("config", "user.signingkey", filepath.Join(keys, "trusted"))
+	r.Write("a.txt", "b\n").Commit("trusted")
+	r.Git("config", "user.signingkey", filepath.Join(keys, "unknown"))
+	r.Write("a.txt", "c\n").Commit("unknown")
+
+	got, err := commitmeta.New(r.Dir(), commitmeta.Opts{Signatures: true}).RunSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 commits, got %v", len(got))
+	}
+
+	unsigned, trusted, unknown := got[0].Signature, got[1].Signature, got[2].Signature
+	if unsigned.Status != commitmeta.SignatureNone || unsigned.Signed() {
+		t.Errorf("unexpected signature for unsigned commit %+v", unsigned)
+	}
+	if trusted.Status != commitmeta.SignatureGood || !trusted.Verified() || trusted.Signer != "signer@example.com" || !strings.HasPrefix(trusted.KeyID, "SHA256:") {
+		t.Errorf("unexpected signature for commit signed with trusted key %+v", trusted)
+	}
+	if unknown.Status != commitmeta.SignatureUnknownValidity || !unknown.Signed() || unknown.Verified() || unknown.Fingerprint == "" {
+		t.Errorf("unexpected signature for commit signed with unknown key %+v", unknown)
+	}
+	if got[1].Files["a.txt"] == nil || got[1].Message != "trusted" {
+		t.Errorf("files or message not parsed for signed commit %+v", got[1])
+	}
+
+	// not verified by default
+	got, err = commitmeta.New(r.Dir(), commitmeta.Opts{}).RunSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[1].Signature != (commitmeta.Signature{}) {
+		t.Errorf("signature should not be set by default, got %+v", got[1].Signature)
+	}
+}
@@ -0,0 +1,159 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
+)
+
+// CommitKind classifies a commit by its parents and message.
+type CommitKind string
+
+const (
+	// CommitKindMerge is a commit with more than one parent.
+	CommitKindMerge = CommitKind("merge")
+	// CommitKindRevert is a commit created by git revert, or with revert conventional commit type.
+	CommitKindRevert = CommitKind("revert")
+	// CommitKindFeature is feat conventional commit type.
+	CommitKindFeature = CommitKind("feature")
+	// CommitKindFix is fix conventional commit type, or a message that mentions fixing a bug.
+	CommitKindFix = CommitKind("fix")
+	// CommitKindDocs is docs conventional commit type.
+	CommitKindDocs = CommitKind("docs")
+	// CommitKindRefactor is refactor or perf conventional commit type.
+	CommitKindRefactor = CommitKind("refactor")
+	// CommitKindTest is test conventional commit type.
+	CommitKindTest = CommitKind("test")
+	// CommitKindChore is chore, build, ci or style conventional commit type.
+	CommitKindChore = CommitKind("chore")
+	// CommitKindOther is any other commit.
+	CommitKindOther = CommitKind("other")
+)
+
+var conventionalKinds = map[string]CommitKind{
+	"feat":     CommitKindFeature,
+	"feature":  CommitKindFeature,
+	"fix":      CommitKindFix,
+	"docs":     CommitKindDocs,
+	"refactor": CommitKindRefactor,
+	"perf":     CommitKindRefactor,
+	"test":     CommitKindTest,
+	"tests":    CommitKindTest,
+	"chore":    CommitKindChore,
+	"build":    CommitKindChore,
+	"ci":       CommitKindChore,
+	"style":    CommitKindChore,
+	"revert":   CommitKindRevert,
+}
+
+var (
+	// conventionalRe matches conventional commit subject, such as feat(api)!: add endpoint
+	conventionalRe = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?: `)
+	fixRe          = regexp.MustCompile(`(?i)\b(fix|fixes|fixed|bug|bugfix|hotfix)\b`)
+)
+
+// CommitInfo is a commit returned from Commits.
+type CommitInfo struct {
+	Commit
+	// Kind classifies the commit by parents and message, using conventional commit type if present.
+	Kind CommitKind
+	// Scope is the scope of conventional commit, such as api in feat(api): add endpoint.
+	Scope string
+	// Breaking is true for conventional commits marked with ! or BREAKING CHANGE trailer.
+	Breaking bool
+	// Tests splits lines changed by the commit between test and production files. Files are classified by path only, since content is not read.
+	Tests TestStats
+}
+
+// classifyCommit sets Kind, Scope and Breaking from commit parents, message and trailers.
+func classifyCommit(c Commit) (res CommitInfo) {
+	res.Commit = c
+	res.Breaking = len(c.Trailer("BREAKING CHANGE")) != 0 || len(c.Trailer("BREAKING-CHANGE")) != 0
+	if len(c.Parents) > 1 {
+		res.Kind = CommitKindMerge
+		return
+	}
+	if m := conventionalRe.FindStringSubmatch(c.Message); m != nil {
+		if kind, ok := conventionalKinds[strings.ToLower(m[1])]; ok {
+			res.Kind = kind
+			res.Scope = m[2]
+			res.Breaking = res.Breaking || m[3] != ""
+			return
+		}
+	}
+	switch {
+	case strings.HasPrefix(c.Message, "Revert \""):
+		res.Kind = CommitKindRevert
+	case fixRe.MatchString(c.Message):
+		res.Kind = CommitKindFix
+	default:
+		res.Kind = CommitKindOther
+	}
+	return
+}
+
+// pathTestStats splits commit changes between test and production files by path.
+func pathTestStats(c Commit) (res TestStats) {
+	for fn, f := range c.Files {
+		if fileinfo.IsTest(fn, nil) {
+			res.TestAdditions += f.Additions
+			res.TestDeletions += f.Deletions
+		} else {
+			res.ProductionAdditions += f.Additions
+			res.ProductionDeletions += f.Deletions
+		}
+	}
+	return
+}
+
+// Commits calls cb for each commit, oldest first, with commit metadata, file stats, trailers and classification. Uses a single git log invocation, without blame, checkpoints or the commit graph.
+// Commits are selected the same way as for CodeByCommit, using Refs, AllBranches, CommitFromIncl and ExcludeAuthors. Stops and returns the error if cb returns an error.
+func (s *Ripsrc) Commits(ctx context.Context, cb func(CommitInfo) error) error {
+	ctx = s.gitContext(ctx)
+	err := s.prepareGitExec(ctx)
+	if errors.Is(err, ErrEmptyRepo) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	copts := s.commitMetaOpts()
+	copts.Trailers = true
+	authors := s.authorFilter()
+	commits := make(chan Commit)
+	done := make(chan bool)
+	// cbErr is safe to read after done
+	var cbErr error
+	go func() {
+		for c := range commits {
+			if cbErr != nil {
+				continue
+			}
+			c.ExcludedAuthor = authors.excluded(c)
+			if s.skipCommit(c) {
+				continue
+			}
+			info := classifyCommit(c)
+			info.Commit = s.scrubCommit(c)
+			info.Tests = pathTestStats(c)
+			cbErr = cb(info)
+			if cbErr != nil {
+				cancel()
+			}
+		}
+		done <- true
+	}()
+	err = commitmeta.New(s.opts.RepoDir, copts).RunContext(ctx, commits)
+	<-done
+	if cbErr != nil {
+		return cbErr
+	}
+	return err
+}
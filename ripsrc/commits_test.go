@@ -0,0 +1,68 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCommits(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Commit("initial")
+	r.Write("a_test.go", "package a\n\nfunc TestA() {}\n").Write("a.go", "package a\n\nvar A = 1\n").
+		Commit("feat(api)!: add A\n\nBody.\n\nSigned-off-by: User 1 <user1@example.com>\nCo-authored-by: User 2 <user2@example.com>\n")
+	r.Write("a.go", "package a\n\nvar A = 2\n").Commit("Fix the bug in A")
+
+	var got []CommitInfo
+	err := New(Opts{RepoDir: r.Dir()}).Commits(context.Background(), func(c CommitInfo) error {
+		got = append(got, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 commits, got %v", len(got))
+	}
+	if got[0].Kind != CommitKindOther || got[0].Trailers != nil {
+		t.Errorf("unexpected first commit %+v", got[0])
+	}
+	c := got[1]
+	if c.Kind != CommitKindFeature || c.Scope != "api" || !c.Breaking {
+		t.Errorf("unexpected classification %v %v %v", c.Kind, c.Scope, c.Breaking)
+	}
+	wantTrailers := []Trailer{
+		{Key: "Signed-off-by", Value: "User 1 <user1@example.com>"},
+		{Key: "Co-authored-by", Value: "User 2 <user2@example.com>"},
+	}
+	if !reflect.DeepEqual(c.Trailers, wantTrailers) {
+		t.Errorf("got trailers %v, want %v", c.Trailers, wantTrailers)
+	}
+	if c.Tests.TestAdditions != 3 || c.Tests.ProductionAdditions != 2 || c.Tests.ProductionDeletions != 0 {
+		t.Errorf("unexpected test stats %+v", c.Tests)
+	}
+	if got[2].Kind != CommitKindFix {
+		t.Errorf("expected fix, got %v", got[2].Kind)
+	}
+}
+
+func TestCommitsStopOnError(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("a.txt", "b\n").Commit("c2")
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := New(Opts{RepoDir: r.Dir()}).Commits(context.Background(), func(c CommitInfo) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Fatalf("expected callback error after 1 call, got %v after %v calls", err, calls)
+	}
+}
@@ -0,0 +1,56 @@
+package ripsrc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pinpt/ripsrc/ripsrc/contributors"
+)
+
+// AuthorStats are contributions of one author email in the repo, used to build contributor profiles across repos with contributors.Org.
+type AuthorStats = contributors.AuthorStats
+
+// AuthorStatsFromPeriods sums AuthorTimeSeries periods into stats per author email, sorted by email. FirstCommit and LastCommit are the starts of the first and last period with commits.
+func AuthorStatsFromPeriods(periods []AuthorPeriod) (res []AuthorStats) {
+	byEmail := map[string]*AuthorStats{}
+	for _, p := range periods {
+		a, ok := byEmail[p.AuthorEmail]
+		if !ok {
+			a = &AuthorStats{Email: p.AuthorEmail}
+			byEmail[p.AuthorEmail] = a
+		}
+		if p.AuthorName != "" {
+			a.Name = p.AuthorName
+		}
+		a.Commits += p.Commits
+		a.LinesAdded += p.LinesAdded
+		a.LinesRemoved += p.LinesRemoved
+		a.SurvivingLines += p.SurvivingLines
+		if p.Commits == 0 {
+			continue
+		}
+		if a.FirstCommit.IsZero() || p.Period.Before(a.FirstCommit) {
+			a.FirstCommit = p.Period
+		}
+		if p.Period.After(a.LastCommit) {
+			a.LastCommit = p.Period
+		}
+	}
+	for _, a := range byEmail {
+		res = append(res, *a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Email < res[j].Email
+	})
+	return
+}
+
+// AuthorStats processes the repo using CodeByCommit and returns contributions per author email, with first and last commit dates by UTC day. Uses and updates checkpoints the same way as CodeByCommit.
+// Add results of each repo to contributors.Org to get profiles across repos.
+func (s *Ripsrc) AuthorStats(ctx context.Context) ([]AuthorStats, error) {
+	periods, err := s.AuthorTimeSeriesSlice(ctx, TimeBucketDay)
+	if err != nil {
+		return nil, err
+	}
+	return AuthorStatsFromPeriods(periods), nil
+}
@@ -0,0 +1,213 @@
+// Package contributors merges author stats of many repos into contributor profiles keyed by resolved identity.
+//
+// Stats of each repo are added with Org.AddRepo. Orgs built separately, for example by workers processing different repos, are combined with Org.Merge or Org.AddProfiles,
+// so that aggregation could run distributed and be combined later. Adding the same repo again replaces its previous stats, so merging is idempotent.
+package contributors
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuthorStats are contributions of one author email in one repo.
+type AuthorStats struct {
+	Email string
+	// Name is the last name used with Email.
+	Name string
+	// Commits is the number of non-merge commits.
+	Commits int
+	// LinesAdded and LinesRemoved are summed from commit file stats.
+	LinesAdded   int
+	LinesRemoved int
+	// SurvivingLines is the number of lines by the author at the last processed commit.
+	SurvivingLines int
+	// FirstCommit and LastCommit are the dates of the first and last commit. Zero if the author has no commits, only surviving lines.
+	FirstCommit time.Time
+	LastCommit  time.Time
+}
+
+// RepoStats are contributions of a profile in one repo, summed for all emails of the profile.
+type RepoStats struct {
+	Commits        int
+	LinesAdded     int
+	LinesRemoved   int
+	SurvivingLines int
+	FirstCommit    time.Time
+	LastCommit     time.Time
+}
+
+func (s *RepoStats) add(o RepoStats) {
+	s.Commits += o.Commits
+	s.LinesAdded += o.LinesAdded
+	s.LinesRemoved += o.LinesRemoved
+	s.SurvivingLines += o.SurvivingLines
+	if !o.FirstCommit.IsZero() && (s.FirstCommit.IsZero() || o.FirstCommit.Before(s.FirstCommit)) {
+		s.FirstCommit = o.FirstCommit
+	}
+	if o.LastCommit.After(s.LastCommit) {
+		s.LastCommit = o.LastCommit
+	}
+}
+
+// Profile is a contributor across repos.
+type Profile struct {
+	// ID is the resolved identity.
+	ID string
+	// Names are all names used, sorted.
+	Names []string
+	// Emails are all emails used, sorted.
+	Emails []string
+	// Repos are contributions by repo id.
+	Repos map[string]RepoStats
+}
+
+// Totals returns contributions summed for all repos.
+func (p Profile) Totals() (res RepoStats) {
+	for _, r := range p.Repos {
+		res.add(r)
+	}
+	return
+}
+
+// Resolver returns the identity of an author. Authors with the same identity are merged into one profile.
+type Resolver func(name, email string) string
+
+// EmailResolver uses lowercase email as identity.
+func EmailResolver(name, email string) string {
+	return strings.ToLower(email)
+}
+
+// Aliases returns a resolver that maps emails to identities, for example to merge work and personal emails of the same person. Emails are compared case insensitively, EmailResolver is used for emails not in aliases.
+func Aliases(aliases map[string]string) Resolver {
+	m := map[string]string{}
+	for email, id := range aliases {
+		m[strings.ToLower(email)] = id
+	}
+	return func(name, email string) string {
+		if id, ok := m[strings.ToLower(email)]; ok {
+			return id
+		}
+		return EmailResolver(name, email)
+	}
+}
+
+// Org aggregates profiles of contributors across repos.
+type Org struct {
+	resolve  Resolver
+	profiles map[string]*Profile
+}
+
+// New creates empty aggregation. If resolve is nil, EmailResolver is used.
+func New(resolve Resolver) *Org {
+	if resolve == nil {
+		resolve = EmailResolver
+	}
+	s := &Org{}
+	s.resolve = resolve
+	s.profiles = map[string]*Profile{}
+	return s
+}
+
+func (s *Org) profile(id string) *Profile {
+	p, ok := s.profiles[id]
+	if !ok {
+		p = &Profile{ID: id}
+		p.Repos = map[string]RepoStats{}
+		s.profiles[id] = p
+	}
+	return p
+}
+
+// AddRepo adds stats of all authors of the repo, replacing stats previously added for the same repo id.
+func (s *Org) AddRepo(repo string, authors []AuthorStats) {
+	for _, p := range s.profiles {
+		delete(p.Repos, repo)
+	}
+	byID := map[string]*RepoStats{}
+	for _, a := range authors {
+		id := s.resolve(a.Name, a.Email)
+		p := s.profile(id)
+		p.Names = addSorted(p.Names, a.Name)
+		p.Emails = addSorted(p.Emails, a.Email)
+		r, ok := byID[id]
+		if !ok {
+			r = &RepoStats{}
+			byID[id] = r
+		}
+		r.add(RepoStats{
+			Commits:        a.Commits,
+			LinesAdded:     a.LinesAdded,
+			LinesRemoved:   a.LinesRemoved,
+			SurvivingLines: a.SurvivingLines,
+			FirstCommit:    a.FirstCommit,
+			LastCommit:     a.LastCommit,
+		})
+	}
+	for id, r := range byID {
+		s.profiles[id].Repos[repo] = *r
+	}
+}
+
+// AddProfiles merges profiles, for example returned from Profiles of another Org and stored as json. Profiles with the same ID are combined, stats of repos present in both are replaced with the passed ones.
+func (s *Org) AddProfiles(profiles []Profile) {
+	for _, o := range profiles {
+		for repo := range o.Repos {
+			for _, p := range s.profiles {
+				delete(p.Repos, repo)
+			}
+		}
+	}
+	for _, o := range profiles {
+		p := s.profile(o.ID)
+		for _, n := range o.Names {
+			p.Names = addSorted(p.Names, n)
+		}
+		for _, e := range o.Emails {
+			p.Emails = addSorted(p.Emails, e)
+		}
+		for repo, r := range o.Repos {
+			p.Repos[repo] = r
+		}
+	}
+}
+
+// Merge adds profiles of other Org, same as AddProfiles(other.Profiles()).
+func (s *Org) Merge(other *Org) {
+	s.AddProfiles(other.Profiles())
+}
+
+// Profiles returns copies of all profiles sorted by ID.
+func (s *Org) Profiles() (res []Profile) {
+	for _, p := range s.profiles {
+		if len(p.Repos) == 0 {
+			continue
+		}
+		c := *p
+		c.Names = append([]string(nil), p.Names...)
+		c.Emails = append([]string(nil), p.Emails...)
+		c.Repos = map[string]RepoStats{}
+		for k, v := range p.Repos {
+			c.Repos[k] = v
+		}
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return
+}
+
+func addSorted(arr []string, s string) []string {
+	if s == "" {
+		return arr
+	}
+	i := sort.SearchStrings(arr, s)
+	if i < len(arr) && arr[i] == s {
+		return arr
+	}
+	arr = append(arr, "")
+	copy(arr[i+1:], arr[i:])
+	arr[i] = s
+	return arr
+}
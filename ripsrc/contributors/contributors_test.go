@@ -0,0 +1,72 @@
+package contributors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(day int) time.Time {
+	return time.Date(2020, 1, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestOrg(t *testing.T) {
+	resolve := Aliases(map[string]string{"alice@home.com": "alice@work.com"})
+
+	w1 := New(resolve)
+	w1.AddRepo("api", []AuthorStats{
+		{Email: "Alice@work.com", Name: "Alice", Commits: 2, LinesAdded: 10, SurvivingLines: 8, FirstCommit: date(1), LastCommit: date(3)},
+		{Email: "bob@work.com", Name: "Bob", Commits: 1, LinesAdded: 5, FirstCommit: date(2), LastCommit: date(2)},
+	})
+	w2 := New(resolve)
+	w2.AddRepo("web", []AuthorStats{
+		{Email: "alice@home.com", Name: "Alice A", Commits: 3, LinesAdded: 7, LinesRemoved: 1, SurvivingLines: 6, FirstCommit: date(5), LastCommit: date(9)},
+		{Email: "alice@work.com", Name: "Alice", Commits: 1, LinesAdded: 1, FirstCommit: date(4), LastCommit: date(4)},
+	})
+
+	// profiles from workers are passed as json
+	data, err := json.Marshal(w2.Profiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profiles []Profile
+	err = json.Unmarshal(data, &profiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := New(resolve)
+	org.Merge(w1)
+	org.AddProfiles(profiles)
+	// merging the same repo again does not double count
+	org.Merge(w1)
+
+	res := org.Profiles()
+	if len(res) != 2 {
+		t.Fatalf("expected 2 profiles, got %+v", res)
+	}
+	alice := res[0]
+	if alice.ID != "alice@work.com" || !reflect.DeepEqual(alice.Names, []string{"Alice", "Alice A"}) || !reflect.DeepEqual(alice.Emails, []string{"Alice@work.com", "alice@home.com", "alice@work.com"}) {
+		t.Errorf("unexpected profile %+v", alice)
+	}
+	want := RepoStats{Commits: 6, LinesAdded: 18, LinesRemoved: 1, SurvivingLines: 14, FirstCommit: date(1), LastCommit: date(9)}
+	if got := alice.Totals(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got totals %+v, want %+v", got, want)
+	}
+	if web := alice.Repos["web"]; web.Commits != 4 || !web.FirstCommit.Equal(date(4)) {
+		t.Errorf("unexpected web stats %+v", web)
+	}
+	if res[1].ID != "bob@work.com" || res[1].Totals().Commits != 1 {
+		t.Errorf("unexpected profile %+v", res[1])
+	}
+}
+
+func TestAddRepoReplaces(t *testing.T) {
+	org := New(nil)
+	org.AddRepo("api", []AuthorStats{{Email: "a@x.com", Commits: 1}})
+	org.AddRepo("api", []AuthorStats{{Email: "b@x.com", Commits: 2}})
+	res := org.Profiles()
+	if len(res) != 1 || res[0].ID != "b@x.com" {
+		t.Fatalf("expected only stats of the last AddRepo, got %+v", res)
+	}
+}
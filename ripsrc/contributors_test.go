@@ -0,0 +1,40 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/contributors"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestAuthorStatsAcrossRepos(t *testing.T) {
+	r1 := testkit.New(t)
+	defer r1.Remove()
+	r1.Write("a.go", "package a\n\nvar A = 1\n").Commit("c1")
+	r1.Author("User 2", "user2@example.com").Write("b.go", "package a\n").Commit("c2")
+
+	r2 := testkit.New(t)
+	defer r2.Remove()
+	r2.Author("User 2", "User2@Example.com").Write("c.go", "package c\n\nvar C = 1\n").Commit("c1")
+
+	org := contributors.New(nil)
+	for name, r := range map[string]*testkit.Repo{"r1": r1, "r2": r2} {
+		stats, err := New(Opts{RepoDir: r.Dir()}).AuthorStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		org.AddRepo(name, stats)
+	}
+	res := org.Profiles()
+	if len(res) != 2 {
+		t.Fatalf("expected 2 profiles, got %+v", res)
+	}
+	u1, u2 := res[0], res[1]
+	if u1.ID != "user1@example.com" || u1.Totals().Commits != 1 || u1.Totals().SurvivingLines != 3 || u1.Totals().FirstCommit.IsZero() {
+		t.Errorf("unexpected profile %+v", u1)
+	}
+	if u2.ID != "user2@example.com" || len(u2.Repos) != 2 || u2.Totals().Commits != 2 || u2.Totals().LinesAdded != 4 || len(u2.Emails) != 2 {
+		t.Errorf("unexpected profile %+v", u2)
+	}
+}
@@ -0,0 +1,10 @@
+// +build windows
+
+package ripsrc
+
+import "time"
+
+// processCPUTime is not supported on windows.
+func processCPUTime() time.Duration {
+	return 0
+}
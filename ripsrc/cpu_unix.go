@@ -0,0 +1,18 @@
+// +build !windows
+
+package ripsrc
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns user and system CPU time used by the current process.
+func processCPUTime() time.Duration {
+	ru := syscall.Rusage{}
+	err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,203 @@
+package ripsrc
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+)
+
+// RollupTotals are totals of files in a directory or project.
+type RollupTotals struct {
+	// Files is the number of files, including skipped files.
+	Files int
+	// Loc is the total number of lines.
+	Loc int64
+	// LocByLanguage is the number of lines by detected language. Files without detected language are not included.
+	LocByLanguage map[string]int64
+	// LinesByAuthor is the number of lines by author email of the commit that last changed them. Skipped files are not included, since they do not have blame.
+	LinesByAuthor map[string]int64
+}
+
+func newRollupTotals() RollupTotals {
+	s := RollupTotals{}
+	s.LocByLanguage = map[string]int64{}
+	s.LinesByAuthor = map[string]int64{}
+	return s
+}
+
+func (s *RollupTotals) add(f dirFile) {
+	s.Files++
+	s.Loc += f.loc
+	if f.language != "" {
+		s.LocByLanguage[f.language] += f.loc
+	}
+	for email, n := range f.authors {
+		s.LinesByAuthor[email] += n
+	}
+}
+
+// DirectoryRollup is the aggregate of all files in a directory and its subdirectories at the last processed commit.
+type DirectoryRollup struct {
+	// Dir is the directory path, "." for repo root.
+	Dir string
+	RollupTotals
+	// LastCommit is the sha of the last processed commit that added, changed or removed a file in the directory.
+	LastCommit string
+	// LastModified is the date of LastCommit.
+	LastModified time.Time
+}
+
+// dirFile is the part of the file blame needed for rollups, so that full results are not kept in memory.
+type dirFile struct {
+	language string
+	loc      int64
+	authors  map[string]int64
+}
+
+func newDirFile(r BlameResult) dirFile {
+	f := dirFile{}
+	f.language = r.Language
+	f.loc = r.Loc
+	f.authors = map[string]int64{}
+	for _, l := range r.Lines {
+		f.authors[l.Email]++
+	}
+	return f
+}
+
+// DirectoryRollups aggregates blames returned from CodeByCommit into per directory totals.
+// Add every commit with AddCommit and its blames with AddBlame in the order returned, then call Result.
+// Only a small summary of each file is kept. With checkpoints only files changed in new commits are included, process without checkpoints to get rollups of the whole tree.
+type DirectoryRollups struct {
+	// CommitDate selects the commit date used for LastModified.
+	CommitDate CommitDate
+
+	files   map[string]dirFile
+	last    map[string]Commit
+	current Commit
+}
+
+// NewDirectoryRollups creates empty aggregation.
+func NewDirectoryRollups() *DirectoryRollups {
+	s := &DirectoryRollups{}
+	s.files = map[string]dirFile{}
+	s.last = map[string]Commit{}
+	return s
+}
+
+// AddCommit must be called before AddBlame for blames of the commit. Removes files that were removed or renamed by the commit, since blames are not returned for them.
+func (s *DirectoryRollups) AddCommit(c Commit) {
+	s.current = Commit{SHA: c.SHA, Date: s.CommitDate.Of(c)}
+	for fn, f := range c.Files {
+		if f.Status == GitFileCommitStatusRemoved {
+			s.remove(fn)
+		}
+	}
+}
+
+func (s *DirectoryRollups) remove(filename string) {
+	s.touch(filename)
+	delete(s.files, filename)
+}
+
+// touch sets the current commit as the last commit of all directories containing the file.
+func (s *DirectoryRollups) touch(filename string) {
+	for _, dir := range parentDirs(filename) {
+		s.last[dir] = s.current
+	}
+}
+
+// AddBlame replaces the summary of the file with the state after the current commit.
+func (s *DirectoryRollups) AddBlame(r BlameResult) {
+	if r.Status == GitFileCommitStatusRemoved {
+		s.remove(r.Filename)
+		return
+	}
+	s.touch(r.Filename)
+	s.files[r.Filename] = newDirFile(r)
+}
+
+// Result returns rollups of directories that contain files, sorted by Dir.
+func (s *DirectoryRollups) Result() (res []DirectoryRollup) {
+	dirs := map[string]*DirectoryRollup{}
+	for fn, f := range s.files {
+		for _, dir := range parentDirs(fn) {
+			d, ok := dirs[dir]
+			if !ok {
+				d = &DirectoryRollup{}
+				d.Dir = dir
+				d.RollupTotals = newRollupTotals()
+				d.LastCommit = s.last[dir].SHA
+				d.LastModified = s.last[dir].Date
+				dirs[dir] = d
+			}
+			d.add(f)
+		}
+	}
+	for _, d := range dirs {
+		res = append(res, *d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Dir < res[j].Dir
+	})
+	return
+}
+
+// parentDirs returns all directories containing the file, from the closest one to ".".
+func parentDirs(filename string) (res []string) {
+	dir := path.Dir(filename)
+	for {
+		res = append(res, dir)
+		if dir == "." {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// DirectoryRollups processes the repo using CodeByCommit and returns per directory totals at the last processed commit. Uses and updates checkpoints the same way as CodeByCommit.
+func (s *Ripsrc) DirectoryRollups(ctx context.Context, res chan DirectoryRollup) error {
+	defer close(res)
+	agg := NewDirectoryRollups()
+	agg.CommitDate = s.opts.CommitDate
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			agg.AddCommit(c.Commit)
+			for b := range c.Blames {
+				agg.AddBlame(b)
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+	for _, r := range agg.Result() {
+		select {
+		case res <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) DirectoryRollupsSlice(ctx context.Context) (res []DirectoryRollup, _ error) {
+	resChan := make(chan DirectoryRollup)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.DirectoryRollups(ctx, resChan)
+	<-done
+	return res, err
+}
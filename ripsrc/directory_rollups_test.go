@@ -0,0 +1,43 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestDirectoryRollups(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("main.go", "package main\n").Commit("c1")
+	r.Write("pkg/a/a.go", "package a\n\nvar A = 1\n").Write("pkg/b/b.go", "package b\n").Commit("c2")
+	c3 := r.Write("pkg/b/b.go", "package b\n\nvar B = 1\n").Commit("c3")
+	c4 := r.Rename("pkg/a/a.go", "pkg/c/a.go").Commit("c4")
+
+	res, err := New(Opts{RepoDir: r.Dir()}).DirectoryRollupsSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]DirectoryRollup{}
+	var dirs []string
+	for _, d := range res {
+		got[d.Dir] = d
+		dirs = append(dirs, d.Dir)
+	}
+	if !reflect.DeepEqual(dirs, []string{".", "pkg", "pkg/b", "pkg/c"}) {
+		t.Fatalf("unexpected dirs %v", dirs)
+	}
+	root := got["."]
+	if root.Files != 3 || root.Loc != 7 || root.LocByLanguage["Go"] != 7 || root.LastCommit != c4 {
+		t.Errorf("unexpected root rollup %+v", root)
+	}
+	pkg := got["pkg"]
+	if pkg.Files != 2 || pkg.Loc != 6 || pkg.LinesByAuthor["user1@example.com"] != 6 {
+		t.Errorf("unexpected pkg rollup %+v", pkg)
+	}
+	if got["pkg/b"].LastCommit != c3 || got["pkg/b"].LastModified.IsZero() {
+		t.Errorf("unexpected pkg/b rollup %+v", got["pkg/b"])
+	}
+}
@@ -0,0 +1,95 @@
+package ripsrc
+
+import (
+	"context"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/duplicates"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// DuplicatesOpts configures duplicate detection, see Opts.Duplicates.
+type DuplicatesOpts = duplicates.Opts
+
+// DuplicateBlock is code duplicated in multiple files or places of the same file.
+type DuplicateBlock = duplicates.Block
+
+// DuplicateLocation is a place where DuplicateBlock is found, with commits that added its lines.
+type DuplicateLocation = duplicates.Location
+
+// Duplicates processes the repo using CodeByCommit and reports blocks of code duplicated across files at the last processed commit, the longest first.
+// Files skipped by code analysis, such as vendored or generated files, are not included. Uses and updates checkpoints the same way as CodeByCommit, process without checkpoints to check the whole tree.
+func (s *Ripsrc) Duplicates(ctx context.Context, res chan DuplicateBlock) error {
+	defer close(res)
+	// files have the blame of each file at the last commit that changed it, set from process results before the blames of the commit are returned
+	files := map[string]*incblame.Blame{}
+	s.onProcessResult = func(r process.Result, commit commitmeta.Commit) {
+		// process results do not include source of renamed files
+		for fn, f := range commit.Files {
+			if f.Status == GitFileCommitStatusRemoved {
+				delete(files, fn)
+			}
+		}
+		for fn, bl := range r.Files {
+			if f := commit.Files[fn]; f != nil && f.Status == GitFileCommitStatusRemoved || bl.IsBinary || bl.IsUnknown {
+				delete(files, fn)
+				continue
+			}
+			files[fn] = bl
+		}
+	}
+	defer func() {
+		s.onProcessResult = nil
+	}()
+	skipped := map[string]bool{}
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			for b := range c.Blames {
+				skipped[b.Filename] = b.IsSkipped()
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+
+	d := duplicates.New(s.opts.Duplicates)
+	for fn, bl := range files {
+		if skipped[fn] {
+			continue
+		}
+		lines := make([]duplicates.Line, len(bl.Lines))
+		for i, l := range bl.Lines {
+			lines[i] = duplicates.Line{Text: l.Line, Commit: l.Commit}
+		}
+		d.Add(fn, lines)
+	}
+	for _, b := range d.Result() {
+		select {
+		case res <- b:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) DuplicatesSlice(ctx context.Context) (res []DuplicateBlock, _ error) {
+	resChan := make(chan DuplicateBlock)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.Duplicates(ctx, resChan)
+	<-done
+	return res, err
+}
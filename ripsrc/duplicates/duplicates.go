@@ -0,0 +1,282 @@
+// Package duplicates finds blocks of code duplicated across files using winnowing of line hashes.
+//
+// Lines are compared ignoring leading and trailing whitespace, blank lines are ignored. Hashes of k consecutive lines are winnowed, keeping the minimum hash of each window of hashes as a fingerprint,
+// so any duplicated block of at least MinLines+Window-1 lines shares a fingerprint. Matching fingerprints are then verified and extended to the full duplicated block.
+package duplicates
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Opts are options for Detector.
+type Opts struct {
+	// MinLines is the number of consecutive non-blank lines hashed together. Shorter duplicates are not reported. Default is 6.
+	MinLines int
+	// Window is the number of consecutive line hashes from which a fingerprint is selected. Duplicates of MinLines+Window-1 lines are always found, shorter ones only if they share a fingerprint. Default is 4.
+	Window int
+	// MaxOccurrences skips fingerprints found in more than this number of places, since these are usually boilerplate, such as license headers. Default is 50.
+	MaxOccurrences int
+}
+
+// Line is a line of a file with the commit that added it.
+type Line struct {
+	Text   []byte
+	Commit string
+}
+
+// Location is a duplicated block in a file.
+type Location struct {
+	File string
+	// Start is the first line of the block, 1-based.
+	Start int
+	// End is the last line of the block, inclusive.
+	End int
+	// Commits are the commits that added lines of the block, in line order without repeats.
+	Commits []string
+}
+
+// Block is code duplicated in multiple locations.
+type Block struct {
+	// Lines is the number of non-blank duplicated lines.
+	Lines int
+	// Locations are places where the block is found, at least 2, sorted by file and line.
+	Locations []Location
+}
+
+type file struct {
+	name  string
+	lines []Line
+	// norm are trimmed non-blank lines
+	norm [][]byte
+	// pos maps norm index to index in lines
+	pos []int
+}
+
+type position struct {
+	file   int
+	offset int
+}
+
+// Detector collects files and finds duplicates between them.
+type Detector struct {
+	opts  Opts
+	files []*file
+	// fingerprints are positions of selected k-gram hashes
+	fingerprints map[uint64][]position
+}
+
+// New creates a detector.
+func New(opts Opts) *Detector {
+	if opts.MinLines <= 0 {
+		opts.MinLines = 6
+	}
+	if opts.Window <= 0 {
+		opts.Window = 4
+	}
+	if opts.MaxOccurrences <= 0 {
+		opts.MaxOccurrences = 50
+	}
+	s := &Detector{}
+	s.opts = opts
+	s.fingerprints = map[uint64][]position{}
+	return s
+}
+
+// Add adds file content. Each file must be added once.
+func (s *Detector) Add(name string, lines []Line) {
+	f := &file{name: name, lines: lines}
+	for i, l := range lines {
+		t := bytes.TrimSpace(l.Text)
+		if len(t) == 0 {
+			continue
+		}
+		f.norm = append(f.norm, t)
+		f.pos = append(f.pos, i)
+	}
+	fi := len(s.files)
+	s.files = append(s.files, f)
+
+	k := s.opts.MinLines
+	if len(f.norm) < k {
+		return
+	}
+	lineHashes := make([]uint64, len(f.norm))
+	for i, l := range f.norm {
+		lineHashes[i] = hash(l)
+	}
+	kgrams := make([]uint64, len(f.norm)-k+1)
+	for i := range kgrams {
+		h := fnv.New64a()
+		for _, lh := range lineHashes[i : i+k] {
+			var b [8]byte
+			for j := range b {
+				b[j] = byte(lh >> (8 * j))
+			}
+			h.Write(b[:])
+		}
+		kgrams[i] = h.Sum64()
+	}
+	// winnowing, select the rightmost minimum hash in each window, record each selected position once
+	last := -1
+	for i := 0; i < len(kgrams); i++ {
+		end := i + s.opts.Window
+		if end > len(kgrams) {
+			if i != 0 {
+				break
+			}
+			end = len(kgrams)
+		}
+		min := i
+		for j := i; j < end; j++ {
+			if kgrams[j] <= kgrams[min] {
+				min = j
+			}
+		}
+		if min != last {
+			s.fingerprints[kgrams[min]] = append(s.fingerprints[kgrams[min]], position{file: fi, offset: min})
+			last = min
+		}
+	}
+}
+
+func hash(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// match is a verified duplicate between two positions, with offsets into norm
+type match struct {
+	a, b   position
+	length int
+}
+
+// Result returns duplicated blocks, the longest first.
+func (s *Detector) Result() (res []Block) {
+	seen := map[string]bool{}
+	var matches []match
+	for _, positions := range s.fingerprints {
+		if len(positions) < 2 || len(positions) > s.opts.MaxOccurrences {
+			continue
+		}
+		for i := 0; i < len(positions); i++ {
+			for j := i + 1; j < len(positions); j++ {
+				m, ok := s.extend(positions[i], positions[j])
+				if !ok {
+					continue
+				}
+				key := m.key()
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	// group matches with the same content into blocks
+	blocks := map[string]*Block{}
+	locs := map[string]bool{}
+	var keys []string
+	for _, m := range matches {
+		content := s.content(m.a, m.length)
+		b, ok := blocks[content]
+		if !ok {
+			b = &Block{Lines: m.length}
+			blocks[content] = b
+			keys = append(keys, content)
+		}
+		for _, p := range []position{m.a, m.b} {
+			lk := content + "\x00" + strconv.Itoa(p.file) + ":" + strconv.Itoa(p.offset)
+			if locs[lk] {
+				continue
+			}
+			locs[lk] = true
+			b.Locations = append(b.Locations, s.location(p, m.length))
+		}
+	}
+	for _, k := range keys {
+		b := blocks[k]
+		sort.Slice(b.Locations, func(i, j int) bool {
+			a, c := b.Locations[i], b.Locations[j]
+			if a.File != c.File {
+				return a.File < c.File
+			}
+			return a.Start < c.Start
+		})
+		res = append(res, *b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		if a.Locations[0].File != b.Locations[0].File {
+			return a.Locations[0].File < b.Locations[0].File
+		}
+		return a.Locations[0].Start < b.Locations[0].Start
+	})
+	return
+}
+
+// extend verifies that k-grams at a and b are equal and extends the match in both directions to the longest duplicated block. Overlapping blocks in the same file are not duplicates.
+func (s *Detector) extend(a, b position) (res match, ok bool) {
+	if a.file > b.file || a.file == b.file && a.offset > b.offset {
+		a, b = b, a
+	}
+	fa, fb := s.files[a.file], s.files[b.file]
+	eq := func(i, j int) bool {
+		return i >= 0 && j >= 0 && i < len(fa.norm) && j < len(fb.norm) && bytes.Equal(fa.norm[i], fb.norm[j])
+	}
+	for i := 0; i < s.opts.MinLines; i++ {
+		if !eq(a.offset+i, b.offset+i) {
+			return res, false
+		}
+	}
+	for eq(a.offset-1, b.offset-1) {
+		a.offset--
+		b.offset--
+	}
+	length := 0
+	for eq(a.offset+length, b.offset+length) {
+		if a.file == b.file && a.offset+length >= b.offset {
+			break
+		}
+		length++
+	}
+	if length < s.opts.MinLines {
+		return res, false
+	}
+	return match{a: a, b: b, length: length}, true
+}
+
+func (m match) key() string {
+	return strings.Join([]string{strconv.Itoa(m.a.file), strconv.Itoa(m.a.offset), strconv.Itoa(m.b.file), strconv.Itoa(m.b.offset), strconv.Itoa(m.length)}, ":")
+}
+
+func (s *Detector) content(p position, length int) string {
+	return string(bytes.Join(s.files[p.file].norm[p.offset:p.offset+length], []byte("\n")))
+}
+
+func (s *Detector) location(p position, length int) (res Location) {
+	f := s.files[p.file]
+	res.File = f.name
+	start := f.pos[p.offset]
+	end := f.pos[p.offset+length-1]
+	res.Start = start + 1
+	res.End = end + 1
+	seen := map[string]bool{}
+	for _, l := range f.lines[start : end+1] {
+		if len(bytes.TrimSpace(l.Text)) == 0 || seen[l.Commit] {
+			continue
+		}
+		seen[l.Commit] = true
+		res.Commits = append(res.Commits, l.Commit)
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package duplicates
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lines(commit string, content string) (res []Line) {
+	for _, l := range strings.Split(content, "\n") {
+		res = append(res, Line{Text: []byte(l), Commit: commit})
+	}
+	return
+}
+
+const block = `func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}`
+
+func TestDetect(t *testing.T) {
+	d := New(Opts{MinLines: 3, Window: 2})
+	d.Add("a.go", lines("c1", "package a\n\n"+block+"\n"))
+	d.Add("b.go", lines("c2", "package b\n\nimport \"fmt\"\n\n"+strings.Replace(block, "\t", "    ", -1)+"\n\nfunc other() {}\n"))
+	d.Add("c.go", lines("c3", "package c\n\nfunc c() {\n\tprintln()\n}\n"))
+	res := d.Result()
+	want := []Block{
+		{Lines: 6, Locations: []Location{
+			{File: "a.go", Start: 3, End: 8, Commits: []string{"c1"}},
+			{File: "b.go", Start: 5, End: 10, Commits: []string{"c2"}},
+		}},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("got\n%+v\nwant\n%+v", res, want)
+	}
+}
+
+func TestDetectSameFile(t *testing.T) {
+	d := New(Opts{MinLines: 3, Window: 1})
+	d.Add("a.go", lines("c1", block+"\n\n"+block))
+	res := d.Result()
+	if len(res) != 1 || res[0].Lines != 6 || len(res[0].Locations) != 2 || res[0].Locations[1].Start != 8 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestDetectShort(t *testing.T) {
+	d := New(Opts{})
+	d.Add("a.go", lines("c1", "a\nb\nc"))
+	d.Add("b.go", lines("c1", "a\nb\nc"))
+	if res := d.Result(); len(res) != 0 {
+		t.Fatalf("expected no duplicates shorter than MinLines, got %+v", res)
+	}
+}
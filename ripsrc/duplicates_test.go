@@ -0,0 +1,38 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestDuplicates(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	code := "func max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n"
+	c1 := r.Write("a.go", "package a\n\n"+code).Commit("c1")
+	r.Write("b.go", "package a\n").Commit("c2")
+	c3 := r.Write("b.go", "package a\n\nimport \"fmt\"\n\n"+code).Commit("c3")
+	// renamed file should only be reported under the new name
+	r.Rename("a.go", "pkg/a.go").Commit("c4")
+
+	res, err := New(Opts{RepoDir: r.Dir(), Duplicates: DuplicatesOpts{MinLines: 4, Window: 2}}).DuplicatesSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 duplicate, got %+v", res)
+	}
+	b := res[0]
+	if b.Lines != 6 || len(b.Locations) != 2 {
+		t.Fatalf("unexpected block %+v", b)
+	}
+	l0, l1 := b.Locations[0], b.Locations[1]
+	if l0.File != "b.go" || l0.Start != 5 || len(l0.Commits) != 1 || l0.Commits[0] != c3 {
+		t.Errorf("unexpected location %+v", l0)
+	}
+	if l1.File != "pkg/a.go" || l1.Start != 3 || len(l1.Commits) != 1 || l1.Commits[0] != c1 {
+		t.Errorf("unexpected location %+v", l1)
+	}
+}
@@ -0,0 +1,65 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestEmptyRepo(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	ctx := context.Background()
+	rip := New(Opts{RepoDir: r.Dir(), AllBranches: true})
+
+	blames, err := rip.CodeSlice(ctx)
+	if err != nil || len(blames) != 0 {
+		t.Errorf("expected no results for empty repo, got %v %v", blames, err)
+	}
+	commits := 0
+	err = rip.Commits(ctx, func(CommitInfo) error {
+		commits++
+		return nil
+	})
+	if err != nil || commits != 0 {
+		t.Errorf("expected no commits for empty repo, got %v %v", commits, err)
+	}
+	branches, err := rip.BranchesSlice(ctx)
+	if err != nil || len(branches) != 0 {
+		t.Errorf("expected no branches for empty repo, got %v %v", branches, err)
+	}
+	tags, err := rip.TagsSlice(ctx)
+	if err != nil || len(tags) != 0 {
+		t.Errorf("expected no tags for empty repo, got %v %v", tags, err)
+	}
+	status, err := rip.NeedsProcessing(ctx)
+	if err != nil || !status.EmptyRepo || status.Needed {
+		t.Errorf("unexpected status for empty repo %+v %v", status, err)
+	}
+	_, err = rip.DefaultBranch(ctx)
+	if !errors.Is(err, ErrEmptyRepo) {
+		t.Errorf("expected ErrEmptyRepo, got %v", err)
+	}
+
+	// processed normally after the first commit
+	r.Write("a.txt", "a\n").Commit("c1")
+	blames, err = rip.CodeSlice(ctx)
+	if err != nil || len(blames) != 1 {
+		t.Errorf("expected result after first commit, got %v %v", blames, err)
+	}
+}
+
+func TestUnbornHead(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Git("checkout", "-q", "--orphan", "new")
+
+	_, err := New(Opts{RepoDir: r.Dir()}).CodeSlice(context.Background())
+	if !errors.Is(err, gitexec.ErrUnbornHead) || errors.Is(err, ErrEmptyRepo) {
+		t.Errorf("expected ErrUnbornHead, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package ripsrc
+
+import (
+	"context"
+)
+
+// CommitEnricher attaches hosting metadata, such as pull request and CI status, to commits before they are returned, so that results could be joined with GitHub or GitLab data in one stream. Set it in Opts.Enricher.
+// Enrich is called from a single goroutine for each returned commit. Implementations calling hosting APIs should cache or batch requests, for example by listing pull requests of the repo once.
+type CommitEnricher interface {
+	// Enrich returns metadata for the commit, nil if there is none. Errors are logged and the commit is returned without metadata, so that hosting API outages do not fail processing.
+	Enrich(ctx context.Context, commit Commit) (*CommitHosting, error)
+}
+
+// CommitHosting is metadata from the git hosting service attached to CommitCode.Hosting by CommitEnricher.
+type CommitHosting struct {
+	// PullRequest is the number of the pull or merge request that introduced the commit, 0 if none.
+	PullRequest int `json:"pull_request,omitempty"`
+	// PullRequestURL is the web url of the pull request.
+	PullRequestURL string `json:"pull_request_url,omitempty"`
+	// Approvals are logins of users that approved the pull request.
+	Approvals []string `json:"approvals,omitempty"`
+	// CIStatus is the combined status of checks for the commit, for example success, failure or pending.
+	CIStatus string `json:"ci_status,omitempty"`
+	// Extra are other provider specific values.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// enrich sets rc.Hosting using Opts.Enricher
+func (s *Ripsrc) enrich(ctx context.Context, rc *CommitCode) {
+	if s.opts.Enricher == nil {
+		return
+	}
+	h, err := s.opts.Enricher.Enrich(ctx, rc.Commit)
+	if err != nil {
+		s.opts.Logger.Warn("could not enrich commit", "commit", rc.SHA, "err", err)
+		return
+	}
+	rc.Hosting = s.anonymizer.hosting(h)
+}
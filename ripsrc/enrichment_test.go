@@ -0,0 +1,52 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+type mapEnricher map[string]*CommitHosting
+
+func (s mapEnricher) Enrich(ctx context.Context, commit Commit) (*CommitHosting, error) {
+	if commit.Message == "fail" {
+		return nil, errors.New("rate limited")
+	}
+	return s[commit.SHA], nil
+}
+
+func TestEnricher(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a2\n").Commit("fail")
+	c3 := r.Write("a.txt", "a3\n").Commit("c3")
+
+	enricher := mapEnricher{
+		c1: {PullRequest: 1, Approvals: []string{"user2"}, CIStatus: "success"},
+		c2: {PullRequest: 2},
+	}
+	for _, stages := range []Stages{0, StageCommits} {
+		var got []*CommitHosting
+		it := New(Opts{RepoDir: r.Dir(), Enricher: enricher, Stages: stages}).CommitIter(context.Background())
+		for it.Next() {
+			got = append(got, it.Value().Hosting)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		it.Close()
+		if len(got) != 3 {
+			t.Fatalf("wanted 3 commits, got %v", len(got))
+		}
+		if got[0] == nil || got[0].PullRequest != 1 || got[0].CIStatus != "success" {
+			t.Errorf("expected metadata for %v, got %+v", c1, got[0])
+		}
+		// errors are logged and metadata is skipped
+		if got[1] != nil || got[2] != nil {
+			t.Errorf("expected no metadata for %v and %v, got %+v %+v", c2, c3, got[1], got[2])
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package ripsrc
+
+import (
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// ErrEmptyRepo is returned when the repo has no commits, for example freshly initialized repo with unborn HEAD. Use errors.Is to check for it.
+// Code, CodeByCommit, CodeByBatch, Commits, Branches and Tags return no results without error for empty repos, NeedsProcessing sets ProcessingStatus.EmptyRepo. Other calls return this error.
+var ErrEmptyRepo = gitexec.ErrEmptyRepo
+
+// ApplyError is returned from Code and CodeByCommit when commits could not be processed. Lists all files and commits that failed before processing stopped, use errors.As to get it.
+type ApplyError = process.ApplyError
+
+// FailedFile is a file of a commit that could not be processed, see ApplyError.
+type FailedFile = process.FailedFile
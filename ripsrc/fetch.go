@@ -0,0 +1,29 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// Fetch updates refs of the repo from remote, origin if empty, including tags and removing branches deleted on the remote. Uses GitEnv, GitUserConfig and GitCredentialHelper, so the same credentials as for processing apply.
+// For repos kept up to date by Fetch use a mirror clone (git clone --mirror), where fetch updates branches directly. In a regular clone only remote tracking branches are updated, see BranchesUseOrigin.
+// Not allowed with ReadOnly.
+func (s *Ripsrc) Fetch(ctx context.Context, remote string) error {
+	if s.opts.ReadOnly {
+		return errors.New("Fetch is not allowed with ReadOnly")
+	}
+	ctx = s.gitContext(ctx)
+	err := s.prepareGitExec(ctx)
+	// initial fetch into an empty mirror is allowed
+	if err != nil && !errors.Is(err, ErrEmptyRepo) {
+		return err
+	}
+	if remote == "" {
+		remote = "origin"
+	}
+	out := bytes.NewBuffer(nil)
+	return gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"fetch", "--quiet", "--prune", "--tags", remote})
+}
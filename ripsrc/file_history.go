@@ -0,0 +1,157 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// FileHistoryEntry is a commit that changed the file, returned from FileHistory.
+type FileHistoryEntry struct {
+	// Commit is the commit metadata. Only SHA is set for commits not processed in this call, see Blame.
+	Commit Commit
+	// Path is the name of the file in this commit.
+	Path string
+	// PreviousPath is the name of the file before this commit if the commit renamed it, empty otherwise.
+	PreviousPath string
+	// Additions is the number of lines added to the file by this commit.
+	Additions int
+	// Deletions is the number of lines removed from the file by this commit.
+	Deletions int
+	// Binary is true if the file is binary, Additions and Deletions are 0 in this case.
+	Binary bool
+	// Blame is the blame of the file after this commit. Nil for commits before CommitFromIncl, since they were already processed and stored in the checkpoint.
+	Blame *BlameResult
+}
+
+// fileLogEntry is a commit from git log --follow.
+type fileLogEntry struct {
+	sha          string
+	path         string
+	previousPath string
+	additions    int
+	deletions    int
+	binary       bool
+}
+
+// fileLog returns commits that changed the file, following renames, newest first. Merge commits are not returned, since git log does not show their diffs.
+func (s *Ripsrc) fileLog(ctx context.Context, path string) (res []fileLogEntry, _ error) {
+	out := bytes.NewBuffer(nil)
+	args := []string{"log", "--follow", "-M", "--numstat", "-z", "--format=%x01%H", s.treeRef(), "--", path}
+	err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, args)
+	if err != nil {
+		return nil, err
+	}
+	return parseFileLog(out.String())
+}
+
+// parseFileLog parses output of git log --numstat -z with %x01%H format. Renames use 2 separate tokens for previous and new path.
+func parseFileLog(data string) (res []fileLogEntry, _ error) {
+	tokens := strings.Split(data, "\x00")
+	var sha string
+	for i := 0; i < len(tokens); i++ {
+		tok := strings.TrimPrefix(tokens[i], "\n")
+		if tok == "" {
+			continue
+		}
+		if strings.HasPrefix(tok, "\x01") {
+			sha = tok[1:]
+			continue
+		}
+		if sha == "" {
+			return nil, fmt.Errorf("unexpected git log output, stat without commit: %q", tok)
+		}
+		parts := strings.SplitN(tok, "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unexpected git log numstat line: %q", tok)
+		}
+		e := fileLogEntry{sha: sha, path: parts[2]}
+		if e.path == "" {
+			if i+2 >= len(tokens) {
+				return nil, fmt.Errorf("unexpected git log output, incomplete rename: %q", tok)
+			}
+			e.previousPath = tokens[i+1]
+			e.path = tokens[i+2]
+			i += 2
+		}
+		if parts[0] == "-" {
+			e.binary = true
+		} else {
+			var err error
+			e.additions, err = strconv.Atoi(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log numstat line: %q err: %v", tok, err)
+			}
+			e.deletions, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log numstat line: %q err: %v", tok, err)
+			}
+		}
+		res = append(res, e)
+	}
+	return
+}
+
+// FileHistory returns commits that changed the file at path, oldest first, following renames the same way as git log --follow from the first of Opts.Refs or HEAD.
+// Each entry has line changes of the commit and the blame of the file after it. Blames come from CodeByCommit, so history is processed starting from the existing checkpoint and the checkpoint is updated.
+// Run without CommitFromIncl to get blames of all commits. Merge commits are not included, the same as in git log --follow.
+func (s *Ripsrc) FileHistory(ctx context.Context, path string) (res []FileHistoryEntry, _ error) {
+	if path == "" {
+		return nil, errors.New("FileHistory: path is required")
+	}
+	gctx := s.gitContext(ctx)
+	err := s.prepareGitExec(gctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.fileLog(gctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	// wanted maps commit sha to the index in res
+	wanted := map[string]int{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		wanted[e.sha] = len(res)
+		res = append(res, FileHistoryEntry{
+			Commit:       Commit{SHA: e.sha},
+			Path:         e.path,
+			PreviousPath: e.previousPath,
+			Additions:    e.additions,
+			Deletions:    e.deletions,
+			Binary:       e.binary,
+		})
+	}
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			i, ok := wanted[c.SHA]
+			if ok {
+				res[i].Commit = c.Commit
+			}
+			for b := range c.Blames {
+				if ok && b.Filename == res[i].Path {
+					b := b
+					res[i].Blame = &b
+				}
+			}
+		}
+		done <- true
+	}()
+	err = s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,70 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestFileHistory(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.go", "package a\n\nvar A = 1\n").Commit("c1")
+	r.Write("other.go", "package a\n").Commit("c2")
+	c3 := r.Write("a.go", "package a\n\nvar A = 2\nvar B = 1\n").Commit("c3")
+	c4 := r.Rename("a.go", "pkg/b c.go").Commit("c4")
+	c5 := r.Write("pkg/b c.go", "package a\n\nvar A = 2\n").Commit("c5")
+
+	res, err := New(Opts{RepoDir: r.Dir()}).FileHistory(context.Background(), "pkg/b c.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		SHA          string
+		Path         string
+		PreviousPath string
+		Additions    int
+		Deletions    int
+	}
+	var got []entry
+	for _, e := range res {
+		got = append(got, entry{e.Commit.SHA, e.Path, e.PreviousPath, e.Additions, e.Deletions})
+		if e.Blame == nil || e.Blame.Filename != e.Path || e.Commit.Message == "" {
+			t.Fatalf("missing blame or commit for %v: %+v", e.Commit.SHA, e)
+		}
+	}
+	want := []entry{
+		{c1, "a.go", "", 3, 0},
+		{c3, "a.go", "", 2, 1},
+		{c4, "pkg/b c.go", "a.go", 0, 0},
+		{c5, "pkg/b c.go", "", 0, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%+v\nwant\n%+v", got, want)
+	}
+	var lineSHAs []string
+	for _, l := range res[1].Blame.Lines {
+		lineSHAs = append(lineSHAs, l.SHA)
+	}
+	if !reflect.DeepEqual(lineSHAs, []string{c1, c1, c3, c3}) {
+		t.Errorf("unexpected blame after c3 %v", lineSHAs)
+	}
+	if len(res[3].Blame.Lines) != 3 || res[3].Blame.Lines[2].SHA != c3 {
+		t.Errorf("unexpected blame after c5 %+v", res[3].Blame.Lines)
+	}
+}
+
+func TestFileHistoryNotFound(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Commit("c1")
+	res, err := New(Opts{RepoDir: r.Dir()}).FileHistory(context.Background(), "missing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expected no history, got %+v", res)
+	}
+}
@@ -8,15 +8,43 @@ import (
 )
 
 type Process struct {
-	checkFilePathCache map[string]string
+	opts               Opts
+	vendorRules        []vendorRule
+	checkFilePathCache map[string]pathSkip
+}
+
+// pathSkip is the result of checking file path, empty if file should be processed
+type pathSkip struct {
+	reason string
+	rule   string
 }
 
 func New() *Process {
-	s := &Process{}
-	s.checkFilePathCache = map[string]string{}
+	s, err := NewWithOpts(Opts{})
+	if err != nil {
+		panic(err)
+	}
 	return s
 }
 
+// NewWithOpts creates Process with additional vendor detection. Returns an error for invalid VendorRules.
+func NewWithOpts(opts Opts) (*Process, error) {
+	s := &Process{}
+	s.opts = opts
+	var rules []VendorRule
+	if !opts.NoDefaultVendorRules {
+		rules = append(rules, DefaultVendorRules...)
+	}
+	rules = append(rules, opts.VendorRules...)
+	var err error
+	s.vendorRules, err = compileVendorRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	s.checkFilePathCache = map[string]pathSkip{}
+	return s, nil
+}
+
 const (
 	skipLanguageUnknown      = "Language was unknown"
 	skipFileSize             = "File size was %dK which exceeds limit of %dK"
@@ -39,6 +67,12 @@ type Info struct {
 	Language   string
 	License    *License
 	SkipReason string
+	// SkipRule is the rule that caused the file to be skipped, for example the matched exclusion pattern or the exceeded limit. Useful to debug why files were skipped.
+	SkipRule string
+	// IsTest is true for test files, see IsTest. Set also for skipped files.
+	IsTest bool
+	// Generated is true for files created by code generators, see IsGenerated. Generated files are not skipped by GetInfo, callers decide how to count them.
+	Generated bool
 }
 
 // maxFileSize controls the size of the overall file we will process before
@@ -57,10 +91,17 @@ const maxLinePerFile = 40000
 const maxBytesPerLine = 1096
 
 func (s *Process) GetInfo(args InfoArgs) (res Info, skipReason string) {
+	res.IsTest = IsTest(args.FilePath, args.Content)
+	res.Generated = IsGenerated(args.FilePath, args.Content)
+	res.SkipReason, res.SkipRule = s.skip(args, &res)
+	return res, res.SkipReason
+}
+
+func (s *Process) skip(args InfoArgs, res *Info) (skipReason string, rule string) {
 	fileSize := len(args.Content)
 
 	if fileSize > maxFileSize {
-		return res, fmt.Sprintf(skipFileSize, fileSize/1000, maxFileSize/1000)
+		return fmt.Sprintf(skipFileSize, fileSize/1000, maxFileSize/1000), fmt.Sprintf("maxFileSize=%d", maxFileSize)
 	}
 
 	if possibleLicense(args.FilePath) {
@@ -70,33 +111,37 @@ func (s *Process) GetInfo(args InfoArgs) (res Info, skipReason string) {
 		}
 		if l != nil {
 			res.License = l
-			return res, skipLicense
+			return skipLicense, "license detected: " + l.Name
 		}
 	}
 
-	if skip := s.checkFilePath(args.FilePath); skip != "" {
-		return res, skip
+	if skip := s.checkFilePath(args.FilePath); skip.reason != "" {
+		return skip.reason, skip.rule
 	}
 
 	if len(args.Lines) > maxLinePerFile {
-		return res, fmt.Sprintf(skipMaxLinesExceeded, maxLinePerFile)
+		return fmt.Sprintf(skipMaxLinesExceeded, maxLinePerFile), fmt.Sprintf("maxLinePerFile=%d", maxLinePerFile)
 	}
 
 	for _, line := range args.Lines {
 		if len(line) > maxBytesPerLine {
-			return res, fmt.Sprintf(skipMaxLineBytesExceeded, len(line), maxBytesPerLine)
+			return fmt.Sprintf(skipMaxLineBytesExceeded, len(line), maxBytesPerLine), fmt.Sprintf("maxBytesPerLine=%d", maxBytesPerLine)
 		}
 	}
 
+	if holder := s.foreignCopyright(args.FilePath, args.Lines); holder != "" {
+		return skipVendoredFile, fmt.Sprintf("foreign copyright header %q", holder)
+	}
+
 	res.Language = enry.GetLanguage(args.FilePath, args.Content)
 	if res.Language == "" {
-		return res, skipLanguageUnknown
+		return skipLanguageUnknown, "no language detected by enry"
 	}
 
-	return res, ""
+	return "", ""
 }
 
-func (s *Process) checkFilePath(filePath string) (skipReason string) {
+func (s *Process) checkFilePath(filePath string) pathSkip {
 	if res, ok := s.checkFilePathCache[filePath]; ok {
 		return res
 	}
@@ -105,22 +150,24 @@ func (s *Process) checkFilePath(filePath string) (skipReason string) {
 	return res
 }
 
-func (s *Process) checkFilePathUncached(filePath string) (skipReason string) {
+func (s *Process) checkFilePathUncached(filePath string) pathSkip {
 	if enry.IsConfiguration(filePath) {
-		return skipConfigFile
+		return pathSkip{skipConfigFile, "enry configuration file"}
 	}
 	if enry.IsDotFile(filePath) {
-		return skipDotFile
+		return pathSkip{skipDotFile, "enry dot file"}
+	}
+	if m := ignorePatterns.FindString(filePath); m != "" {
+		return pathSkip{skipBlacklisted, fmt.Sprintf("exclusion pattern matched %q", m)}
 	}
-	if ignorePatterns.MatchString(filePath) {
-		return skipBlacklisted
+	if rule := s.vendorRule(filePath); rule != "" {
+		return pathSkip{skipVendoredFile, rule}
 	}
 	if s.isVendored(filePath) {
-		return skipVendoredFile
+		return pathSkip{skipVendoredFile, "enry vendored path"}
 	}
-	return ""
+	return pathSkip{}
 }
-
 func (p *Process) isVendored(filePath string) bool {
 	if enry.IsVendor(filePath) {
 		// enry will incorrectly match something like:
@@ -123,3 +123,18 @@ func TestLanguageUnknown(t *testing.T) {
 	))
 	assert.Equal(t, skipLanguageUnknown, skipReason)
 }
+
+func TestSkipRule(t *testing.T) {
+	p := New()
+	info, skipReason := p.GetInfo(makeArgs("web/node_modules/a.js", testOKContent))
+	assert.Equal(t, skipBlacklisted, skipReason)
+	assert.Equal(t, `exclusion pattern matched "node_modules"`, info.SkipRule)
+
+	info, skipReason = p.GetInfo(makeArgs("main.go", strings.Repeat("a", maxBytesPerLine+1)))
+	assert.NotEqual(t, "", skipReason)
+	assert.Equal(t, "maxBytesPerLine=1096", info.SkipRule)
+
+	info, skipReason = p.GetInfo(makeArgs(testOKFilePath, testOKContent))
+	assert.Equal(t, "", skipReason)
+	assert.Equal(t, "", info.SkipRule)
+}
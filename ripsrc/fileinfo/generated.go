@@ -0,0 +1,38 @@
+package fileinfo
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+)
+
+// generatedFileNames matches naming conventions of code generators.
+var generatedFileNames = regexp.MustCompile(`(\.pb\.go|\.pb\.gw\.go|_gen\.go|_generated\.go|^zz_generated\..*\.go|\.g\.dart|\.freezed\.dart|\.designer\.cs|\.g\.cs|\.generated\.(cs|ts|js|swift)|_pb2(_grpc)?\.py|\.pb\.(h|cc)|_grpc_pb\.js|_pb\.js|\.pb\.swift)$`)
+
+// generatedMarkers matches header comments added by code generators, for example "Code generated by X. DO NOT EDIT." used by go tools or "@generated" used by facebook tools.
+var generatedMarkers = regexp.MustCompile(`(GENERATED|DO NOT EDIT|DO NOT MODIFY|machine generated|@generated|(?i:code generated by|auto-generated|autogenerated|this file (was|is) generated))`)
+
+// generatedHeaderLines is the number of lines at the start of the file checked for generated markers.
+const generatedHeaderLines = 40
+
+// IsGenerated returns true if file was created by a code generator, based on file name conventions or markers in header comments.
+func IsGenerated(filePath string, content []byte) bool {
+	name := path.Base(filePath)
+	if generatedFileNames.MatchString(name) {
+		return true
+	}
+	return generatedMarkers.Match(header(content, generatedHeaderLines))
+}
+
+// header returns the first n lines of content.
+func header(content []byte, n int) []byte {
+	pos := 0
+	for i := 0; i < n; i++ {
+		j := bytes.IndexByte(content[pos:], '\n')
+		if j == -1 {
+			return content
+		}
+		pos += j + 1
+	}
+	return content[:pos]
+}
@@ -0,0 +1,35 @@
+package fileinfo
+
+import "testing"
+
+func TestIsGenerated(t *testing.T) {
+	cases := []struct {
+		Path    string
+		Content string
+		Want    bool
+	}{
+		{"a.go", "package a\n", false},
+		{"api/a.pb.go", "package api\n", true},
+		{"a_string.go", "// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n\npackage a\n", true},
+		{"a_string.go", "package a\n", false},
+		{"lib/a.js", "/**\n * @generated\n */\n", true},
+		{"lib/a.py", "# This file was automatically generated\n# this file was generated by tool\n", true},
+		{"Form1.designer.cs", "", true},
+		{"a.go", "package a\n\n// docs mention that generated code is skipped\n", false},
+	}
+	for _, c := range cases {
+		got := IsGenerated(c.Path, []byte(c.Content))
+		if got != c.Want {
+			t.Errorf("path %v content %q: wanted %v got %v", c.Path, c.Content, c.Want, got)
+		}
+	}
+
+	// markers after the header are ignored
+	content := ""
+	for i := 0; i < generatedHeaderLines; i++ {
+		content += "x\n"
+	}
+	if IsGenerated("a.go", []byte(content+"// DO NOT EDIT\n")) {
+		t.Error("marker after header should be ignored")
+	}
+}
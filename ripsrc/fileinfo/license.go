@@ -1,12 +1,13 @@
 package fileinfo
 
-import "regexp"
+import (
+	"regexp"
+
+	"github.com/pinpt/ripsrc/ripsrc/types"
+)
 
 // License holds details about detected license
-type License struct {
-	Name       string
-	Confidence float32
-}
+type License = types.License
 
 var licenses = regexp.MustCompile("\\/?(LICENSE|LICENCE|README|COPYING|LICENSE-.*|UNLICENSE|UNLICENCE)(\\.(md|txt))?$")
 
@@ -0,0 +1,78 @@
+package fileinfo
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// testDirs are directory names that contain tests in common project layouts.
+var testDirs = map[string]bool{
+	"test":      true,
+	"tests":     true,
+	"__tests__": true,
+	"spec":      true,
+	"specs":     true,
+	"e2e":       true,
+}
+
+// testFileNames matches test file naming conventions per language.
+var testFileNames = regexp.MustCompile(strings.Join([]string{
+	// go, python, ruby, elixir, c/c++
+	`_test\.(go|py|rb|exs|c|cc|cpp)$`,
+	// python
+	`^test_.*\.py$`,
+	// javascript, typescript
+	`\.(test|spec)\.(js|jsx|ts|tsx|mjs|cjs)$`,
+	// ruby
+	`_spec\.rb$`,
+	// java, kotlin, scala, c#, php, swift
+	`(Test|Tests|IT|Spec)\.(java|kt|scala|cs|php|swift)$`,
+}, "|"))
+
+// testImports matches imports of test frameworks, by file extension.
+var testImports = map[string]*regexp.Regexp{
+	".py":    regexp.MustCompile(`(?m)^\s*(import|from)\s+(pytest|unittest)\b`),
+	".js":    jsTestImports,
+	".jsx":   jsTestImports,
+	".ts":    jsTestImports,
+	".tsx":   jsTestImports,
+	".java":  jvmTestImports,
+	".kt":    jvmTestImports,
+	".scala": regexp.MustCompile(`(?m)^\s*import\s+(org\.scalatest|org\.junit|munit)\b`),
+	".cs":    regexp.MustCompile(`(?m)^\s*using\s+(NUnit\.Framework|Xunit|Microsoft\.VisualStudio\.TestTools)\b`),
+	".rb":    regexp.MustCompile(`(?m)^\s*require\s+['"](rspec|minitest|test/unit)`),
+	".php":   regexp.MustCompile(`PHPUnit\\Framework\\TestCase`),
+}
+
+var jsTestImports = regexp.MustCompile(`(from|require\()\s*['"](vitest|mocha|chai|jest|@jest/globals|@testing-library/[^'"]+|ava|tape|supertest)['"]`)
+
+var jvmTestImports = regexp.MustCompile(`(?m)^\s*import\s+(static\s+)?(org\.junit|org\.testng|io\.kotest|kotlin\.test)\b`)
+
+// testImportsMaxBytes limits the part of the file checked for test framework imports, imports are at the start of the file.
+const testImportsMaxBytes = 8 * 1024
+
+// IsTest returns true if the file looks like a test based on path conventions, or if content is passed, on test framework imports.
+func IsTest(filePath string, content []byte) bool {
+	name := path.Base(filePath)
+	if testFileNames.MatchString(name) {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		if testDirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	re := testImports[strings.ToLower(path.Ext(name))]
+	if re == nil || len(content) == 0 {
+		return false
+	}
+	if len(content) > testImportsMaxBytes {
+		content = content[:testImportsMaxBytes]
+		if i := bytes.LastIndexByte(content, '\n'); i != -1 {
+			content = content[:i]
+		}
+	}
+	return re.Match(content)
+}
@@ -0,0 +1,34 @@
+package fileinfo
+
+import "testing"
+
+func TestIsTest(t *testing.T) {
+	cases := []struct {
+		Path    string
+		Content string
+		Want    bool
+	}{
+		{"pkg/a.go", "package a", false},
+		{"pkg/a_test.go", "package a", true},
+		{"app/test_models.py", "", true},
+		{"app/models.py", "import os", false},
+		{"app/check.py", "import os\nimport pytest\n", true},
+		{"web/src/button.test.tsx", "", true},
+		{"web/src/button.tsx", "import React from 'react'", false},
+		{"web/src/helpers.js", "const { expect } = require('chai')", true},
+		{"src/main/java/App.java", "package app;", false},
+		{"src/test/java/App.java", "package app;", true},
+		{"src/main/java/AppTest.java", "package app;", true},
+		{"src/main/java/Check.java", "package app;\nimport org.junit.Test;\n", true},
+		{"lib/user_spec.rb", "", true},
+		{"Tests/UserTests.cs", "", true},
+		{"lib/Util.cs", "using Xunit;", true},
+		{"latest/a.go", "", false},
+	}
+	for _, c := range cases {
+		got := IsTest(c.Path, []byte(c.Content))
+		if got != c.Want {
+			t.Errorf("path %v: wanted %v got %v", c.Path, c.Want, got)
+		}
+	}
+}
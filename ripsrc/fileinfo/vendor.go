@@ -0,0 +1,109 @@
+package fileinfo
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// VendorRule marks files with matching paths as vendored.
+type VendorRule struct {
+	// Name identifies the rule in Info.SkipRule, for example the ecosystem.
+	Name string
+	// Pattern is a regular expression matched against the file path.
+	Pattern string
+}
+
+// DefaultVendorRules detect dependency trees of common ecosystems, in addition to vendor/ and node_modules matched by the exclusion list and paths detected by enry.
+var DefaultVendorRules = []VendorRule{
+	{"python virtualenv", `(^|/)(\.?venv|virtualenv|\.tox)/`},
+	{"python site-packages", `(^|/)(site-packages|dist-packages)/`},
+	{"third party", `(^|/)(third[-_]?party|3rd[-_]?party|thirdparty)/`},
+	{"bower", `(^|/)bower_components/`},
+	{"jspm", `(^|/)jspm_packages/`},
+	{"cocoapods", `(^|/)Pods/`},
+	{"carthage", `(^|/)Carthage/(Checkouts|Build)/`},
+	{"nuget", `(^|/)packages/[^/]+\.[0-9]+\.[0-9]+(\.[0-9]+)*/`},
+	{"dart pub", `(^|/)\.pub-cache/`},
+}
+
+// Opts are options for NewWithOpts.
+type Opts struct {
+	// VendorRules are checked in addition to DefaultVendorRules.
+	VendorRules []VendorRule
+	// NoDefaultVendorRules set to true to only use VendorRules.
+	NoDefaultVendorRules bool
+	// CopyrightHolders are names of copyright holders of the repo code, for example the company name. Matched case insensitively as substrings.
+	// When set, files in subdirectories with copyright headers of other holders are detected as vendored, which finds checked-in SDKs and copied libraries. Files without copyright headers are not affected.
+	CopyrightHolders []string
+}
+
+type vendorRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// ValidateVendorRules returns an error if any pattern is not a valid regular expression.
+func ValidateVendorRules(rules []VendorRule) error {
+	_, err := compileVendorRules(rules)
+	return err
+}
+
+func compileVendorRules(rules []VendorRule) (res []vendorRule, _ error) {
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vendor rule %q pattern %q err: %v", r.Name, r.Pattern, err)
+		}
+		res = append(res, vendorRule{name: r.Name, re: re})
+	}
+	return
+}
+
+// vendorRule returns the rule and the matched part of the path, empty if no rule matches.
+func (s *Process) vendorRule(filePath string) string {
+	for _, r := range s.vendorRules {
+		if m := r.re.FindString(filePath); m != "" {
+			return fmt.Sprintf("vendor rule %q matched %q", r.name, m)
+		}
+	}
+	return ""
+}
+
+// copyrightRe matches copyright notices with (c) or years, capturing the holder.
+var copyrightRe = regexp.MustCompile(`(?i)copyright\s+(?:(?:\(c\)|©)\s*(?:[0-9]{4}(?:\s*[-,]\s*[0-9]{4})*\s*,?\s*)?|[0-9]{4}(?:\s*[-,]\s*[0-9]{4})*\s*,?\s*)(?:by\s+)?([^\r\n]*)`)
+
+// copyrightHeaderLines is the number of lines at the start of the file checked for copyright notices.
+const copyrightHeaderLines = 30
+
+// foreignCopyright returns the holder of the first copyright notice in the file header if it does not match Opts.CopyrightHolders, empty otherwise.
+func (s *Process) foreignCopyright(filePath string, lines [][]byte) string {
+	if len(s.opts.CopyrightHolders) == 0 || !strings.Contains(filePath, "/") {
+		return ""
+	}
+	if len(lines) > copyrightHeaderLines {
+		lines = lines[:copyrightHeaderLines]
+	}
+	for _, line := range lines {
+		if !bytes.Contains(bytes.ToLower(line), []byte("copyright")) {
+			continue
+		}
+		m := copyrightRe.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		holder := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(string(m[1])), "*/#-"))
+		if holder == "" {
+			continue
+		}
+		lower := strings.ToLower(holder)
+		for _, own := range s.opts.CopyrightHolders {
+			if strings.Contains(lower, strings.ToLower(own)) {
+				return ""
+			}
+		}
+		return holder
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package fileinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVendorRules(t *testing.T) {
+	cases := []struct {
+		Path string
+		Rule string
+	}{
+		{"venv/lib/python3.8/a.py", "python virtualenv"},
+		{"app/.venv/lib/a.py", "python virtualenv"},
+		{"lib/python3.8/site-packages/requests/api.py", "python site-packages"},
+		{"third_party/zlib/deflate.c", "third party"},
+		{"src/3rdparty/json.hpp", "third party"},
+		{"web/bower_components/jquery/jquery.js", "bower"},
+		{"ios/Pods/Alamofire/Source/Request.swift", "cocoapods"},
+		{"packages/Newtonsoft.Json.12.0.1/lib/a.cs", "nuget"},
+		{"src/packages/api/a.go", ""},
+		{"dir1/a.go", ""},
+	}
+	p := New()
+	for _, c := range cases {
+		info, skipReason := p.GetInfo(makeArgs(c.Path, testOKContent))
+		if c.Rule == "" {
+			if skipReason != "" {
+				t.Errorf("%v: unexpected skip %v %v", c.Path, skipReason, info.SkipRule)
+			}
+			continue
+		}
+		if skipReason != skipVendoredFile || !strings.Contains(info.SkipRule, c.Rule) {
+			t.Errorf("%v: wanted vendored by %q, got %v %v", c.Path, c.Rule, skipReason, info.SkipRule)
+		}
+	}
+}
+
+func TestVendorRulesOpts(t *testing.T) {
+	p, err := NewWithOpts(Opts{VendorRules: []VendorRule{{Name: "sdk", Pattern: `(^|/)sdk/`}}, NoDefaultVendorRules: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, skipReason := p.GetInfo(makeArgs("lib/sdk/a.go", testOKContent))
+	if skipReason != skipVendoredFile || info.SkipRule != `vendor rule "sdk" matched "/sdk/"` {
+		t.Errorf("unexpected result %v %v", skipReason, info.SkipRule)
+	}
+	if _, skipReason := p.GetInfo(makeArgs("third_party/a.go", testOKContent)); skipReason != "" {
+		t.Errorf("default rules should not be used, got %v", skipReason)
+	}
+
+	_, err = NewWithOpts(Opts{VendorRules: []VendorRule{{Name: "invalid", Pattern: "("}}})
+	if err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestForeignCopyright(t *testing.T) {
+	p, err := NewWithOpts(Opts{CopyrightHolders: []string{"Pinpoint"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		Path    string
+		Content string
+		Holder  string
+	}{
+		{"sdk/a.go", "// Copyright 2015 Google Inc. All rights reserved.\n" + testOKContent, "Google Inc. All rights reserved."},
+		{"sdk/b.go", "/*\n * Copyright (c) 2010-2012, Other Corp\n */\n" + testOKContent, "Other Corp"},
+		{"pkg/c.go", "// Copyright 2019 Pinpoint Software, Inc.\n" + testOKContent, ""},
+		{"pkg/d.go", "package main\n\nvar copyright = \"x\"\n", ""},
+		// files in the root are not treated as vendored
+		{"e.go", "// Copyright 2015 Google Inc.\n" + testOKContent, ""},
+	}
+	for _, c := range cases {
+		info, skipReason := p.GetInfo(makeArgs(c.Path, c.Content))
+		if c.Holder == "" {
+			if skipReason != "" {
+				t.Errorf("%v: unexpected skip %v %v", c.Path, skipReason, info.SkipRule)
+			}
+			continue
+		}
+		if skipReason != skipVendoredFile || !strings.Contains(info.SkipRule, c.Holder) {
+			t.Errorf("%v: wanted foreign copyright %q, got %v %v", c.Path, c.Holder, skipReason, info.SkipRule)
+		}
+	}
+
+	// without CopyrightHolders headers are not checked
+	if _, skipReason := New().GetInfo(makeArgs("sdk/a.go", cases[0].Content)); skipReason != "" {
+		t.Errorf("unexpected skip %v", skipReason)
+	}
+}
@@ -0,0 +1,143 @@
+package ripsrc
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
+)
+
+// fileInfoCacheFile is written next to the checkpoint with fileinfo results of recently processed blobs, so that incremental runs do not analyze blobs seen by previous runs again, for example files of merged branches.
+const fileInfoCacheFile = "fileinfo-cache.json.gz"
+
+// fileInfoCacheFormat is incremented when cached data changes
+const fileInfoCacheFormat = 1
+
+type fileInfoCache struct {
+	// Key is fileInfoCacheKey of the run that wrote the cache. Cache with different key is ignored.
+	Key string
+	// Entries are ordered from least to most recently used.
+	Entries []fileInfoCacheEntry
+}
+
+type fileInfoCacheEntry struct {
+	SHA  string
+	Path string
+	Info fileinfo.Info
+}
+
+// fileInfoCacheKey identifies ripsrc version and options that affect fileinfo results.
+func (s *Ripsrc) fileInfoCacheKey() string {
+	b, err := json.Marshal([]interface{}{fileInfoCacheFormat, version(), s.opts.VendorRules, s.opts.NoDefaultVendorRules, s.opts.CopyrightHolders})
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func (s *Ripsrc) fileInfoCachePath(ctx context.Context) (string, error) {
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(loc), fileInfoCacheFile), nil
+}
+
+// loadFileInfoCache adds fileinfo results saved by the previous run to blobCache. Only loaded once per instance, errors are logged, since the cache only affects performance.
+func (s *Ripsrc) loadFileInfoCache(ctx context.Context) {
+	if s.blobCache == nil || s.fileInfoCacheLoaded || !s.opts.Stages.Has(StageFileInfo) {
+		return
+	}
+	s.fileInfoCacheLoaded = true
+	loc, err := s.fileInfoCachePath(ctx)
+	if err != nil {
+		s.opts.Logger.Warn("could not load fileinfo cache", "err", err)
+		return
+	}
+	cache, err := readFileInfoCache(loc)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		s.opts.Logger.Warn("could not load fileinfo cache", "err", err)
+		return
+	}
+	if cache.Key != s.fileInfoCacheKey() {
+		s.opts.Logger.Debug("fileinfo cache was written by different version or options, ignoring")
+		return
+	}
+	for _, e := range cache.Entries {
+		s.blobCache.addInfo(e.SHA, e.Path, blobInfo{info: e.Info, skipReason: e.Info.SkipReason})
+	}
+	s.opts.Logger.Debug("loaded fileinfo cache", "entries", len(cache.Entries))
+}
+
+// saveFileInfoCache writes fileinfo results kept in blobCache next to the checkpoint. Errors are logged.
+func (s *Ripsrc) saveFileInfoCache(ctx context.Context) {
+	if s.blobCache == nil || !s.opts.Stages.Has(StageFileInfo) {
+		return
+	}
+	loc, err := s.fileInfoCachePath(ctx)
+	if err != nil {
+		s.opts.Logger.Warn("could not save fileinfo cache", "err", err)
+		return
+	}
+	cache := fileInfoCache{Key: s.fileInfoCacheKey()}
+	s.blobCache.eachInfo(func(sha, path string, info blobInfo) {
+		cache.Entries = append(cache.Entries, fileInfoCacheEntry{SHA: sha, Path: path, Info: info.info})
+	})
+	err = writeFileInfoCache(loc, cache)
+	if err != nil {
+		s.opts.Logger.Warn("could not save fileinfo cache", "err", err)
+	}
+}
+
+func readFileInfoCache(loc string) (res fileInfoCache, _ error) {
+	f, err := os.Open(loc)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return res, err
+	}
+	defer gz.Close()
+	err = json.NewDecoder(gz).Decode(&res)
+	return res, err
+}
+
+// writeFileInfoCache writes cache atomically
+func writeFileInfoCache(loc string, cache fileInfoCache) error {
+	err := os.MkdirAll(filepath.Dir(loc), 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(loc + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(loc + ".tmp")
+	gz := gzip.NewWriter(f)
+	err = json.NewEncoder(gz).Encode(cache)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(loc+".tmp", loc)
+}
@@ -0,0 +1,35 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestGeneratedFiles(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").
+		Write("b.go", "// Code generated by tool. DO NOT EDIT.\n\npackage a\n").
+		Write("c.pb.go", "package a\n").
+		Commit("c1")
+
+	res, err := New(Opts{RepoDir: r.Dir()}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]BlameResult{}
+	for _, b := range res {
+		got[b.Filename] = b
+	}
+	if got["a.go"].Generated || got["a.go"].Skipped != "" {
+		t.Errorf("a.go should not be generated %+v", got["a.go"])
+	}
+	for _, f := range []string{"b.go", "c.pb.go"} {
+		b := got[f]
+		if !b.Generated || b.Skipped != generatedFile || len(b.Lines) == 0 {
+			t.Errorf("%v should be generated with lines %+v", f, b)
+		}
+	}
+}
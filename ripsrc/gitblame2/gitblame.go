@@ -1,10 +1,13 @@
 package gitblame2
 
 import (
-	"os"
-	"os/exec"
+	"bytes"
+	"context"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 )
 
 type Line struct {
@@ -18,6 +21,15 @@ func (l Line) String() string {
 
 type Result struct {
 	Lines []Line
+	// Commits has author information for commits referenced in Lines, map[commit_hash]Commit.
+	Commits map[string]Commit
+}
+
+// Commit is author information for a commit referenced in blame output.
+type Commit struct {
+	AuthorName  string
+	AuthorEmail string
+	AuthorTime  time.Time
 }
 
 func (r Result) String() string {
@@ -29,6 +41,11 @@ func (r Result) String() string {
 }
 
 func Run(repoDir, commitHash, file string) (res Result, _ error) {
+	return RunContext(context.Background(), repoDir, commitHash, file)
+}
+
+// RunContext runs git blame for file at commitHash. Same as Run, but cancelled with ctx.
+func RunContext(ctx context.Context, repoDir, commitHash, file string) (res Result, _ error) {
 	args := []string{
 		"blame",
 		commitHash,
@@ -36,17 +53,29 @@ func Run(repoDir, commitHash, file string) (res Result, _ error) {
 		"--",
 		file,
 	}
-	cmd := exec.Command("git", args...)
-	cmd.Dir = repoDir
-	cmd.Stderr = os.Stderr
-	b, err := cmd.Output()
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", repoDir, args)
 	if err != nil {
-		panic(err)
+		return res, err
 	}
-	res0 := parseOutput(string(b))
+	res0 := parseOutput(out.String())
+	res.Commits = map[string]Commit{}
 	for _, l0 := range res0 {
 		l := Line{Content: l0.Content, CommitHash: l0.CommitHash}
 		res.Lines = append(res.Lines, l)
+		if _, ok := res.Commits[l.CommitHash]; !ok {
+			res.Commits[l.CommitHash] = commitFromMeta(l0.Meta)
+		}
 	}
 	return res, nil
 }
+
+func commitFromMeta(meta map[string]string) (res Commit) {
+	res.AuthorName = meta["author"]
+	res.AuthorEmail = strings.TrimSuffix(strings.TrimPrefix(meta["author-mail"], "<"), ">")
+	sec, err := strconv.ParseInt(meta["author-time"], 10, 64)
+	if err == nil {
+		res.AuthorTime = time.Unix(sec, 0).UTC()
+	}
+	return
+}
@@ -0,0 +1,307 @@
+package gitexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrObjectNotFound is returned from CatFile.Get and DiffTree.Diff when object does not exist in repo.
+var ErrObjectNotFound = errors.New("git object not found")
+
+// batchProcess is a long-lived git process that receives requests on stdin and writes responses to stdout.
+type batchProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *limitedBuffer
+	args   []string
+	dir    string
+	// replayed is the recorded output when replaying, cmd is nil in that case
+	replayed io.Closer
+	// rec records the output when recording
+	rec    *recording
+	recCtx context.Context
+}
+
+func startBatch(ctx context.Context, gitCommand string, repoDir string, args []string) (*batchProcess, error) {
+	if rep := replayerFromContext(ctx); rep != nil {
+		return rep.startBatch(repoDir, args)
+	}
+	s := &batchProcess{}
+	s.args = args
+	s.dir = repoDir
+	s.stderr = &limitedBuffer{max: maxStderr}
+	env := envFromContext(ctx)
+	s.cmd = exec.CommandContext(ctx, gitCommand, safeArgs(env, args)...)
+	s.cmd.Dir = repoDir
+	// flush output after each request, otherwise responses are buffered until process exits
+	s.cmd.Env = append(env.environ(), "GIT_FLUSH=1")
+	s.cmd.Stderr = s.stderr
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	s.stdin = stdin
+	if rec := recorderFromContext(ctx); rec != nil {
+		s.rec, err = rec.begin(batchKey(args), args)
+		if err != nil {
+			return nil, fmt.Errorf("could not record git command, dir: %v err: %v", rec.dir, err)
+		}
+		s.recCtx = ctx
+		s.stdout = bufio.NewReaderSize(io.TeeReader(stdout, s.rec), 64*1024)
+	} else {
+		s.stdout = bufio.NewReaderSize(stdout, 64*1024)
+	}
+	err = s.cmd.Start()
+	if err != nil {
+		if s.rec != nil {
+			s.rec.discard()
+		}
+		return nil, NewError(args, repoDir, "", err)
+	}
+	return s, nil
+}
+
+func (s *batchProcess) request(line string) error {
+	_, err := io.WriteString(s.stdin, line+"\n")
+	if err != nil {
+		return s.error(err)
+	}
+	return nil
+}
+
+func (s *batchProcess) readLine() (string, error) {
+	line, err := s.stdout.ReadString('\n')
+	if err != nil {
+		return "", s.error(err)
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (s *batchProcess) error(err error) error {
+	return NewError(s.args, s.dir, s.stderr.String(), err)
+}
+
+func (s *batchProcess) Close() error {
+	if s.replayed != nil {
+		return s.replayed.Close()
+	}
+	err := s.stdin.Close()
+	if err != nil {
+		return err
+	}
+	err = s.cmd.Wait()
+	if err != nil {
+		err = s.error(err)
+	}
+	if s.rec != nil {
+		if s.recCtx.Err() != nil {
+			s.rec.discard()
+		} else if rerr := s.rec.finish(err); rerr != nil && err == nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+// CatFile reads objects using a single long-lived git cat-file --batch process, instead of starting git for each object.
+// Not safe for concurrent use. Call Close when done.
+type CatFile struct {
+	p *batchProcess
+}
+
+// Object is a git object returned from CatFile.
+type Object struct {
+	SHA  string
+	Type string
+	Size int64
+	Data []byte
+}
+
+// NewCatFile starts git cat-file --batch in repoDir.
+func NewCatFile(ctx context.Context, gitCommand string, repoDir string) (*CatFile, error) {
+	p, err := startBatch(ctx, gitCommand, repoDir, []string{"cat-file", "--batch"})
+	if err != nil {
+		return nil, err
+	}
+	s := &CatFile{}
+	s.p = p
+	return s, nil
+}
+
+// Get returns the object by sha or any revision accepted by git cat-file, for example HEAD:path/file.go.
+func (s *CatFile) Get(object string) (res Object, _ error) {
+	if strings.ContainsAny(object, "\n") {
+		return res, fmt.Errorf("invalid object name: %q", object)
+	}
+	err := s.p.request(object)
+	if err != nil {
+		return res, err
+	}
+	header, err := s.p.readLine()
+	if err != nil {
+		return res, err
+	}
+	res, err = parseObjectHeader(object, header)
+	if err != nil {
+		return res, err
+	}
+	// content is followed by newline
+	res.Data = make([]byte, res.Size+1)
+	_, err = io.ReadFull(s.p.stdout, res.Data)
+	if err != nil {
+		return res, s.p.error(err)
+	}
+	res.Data = res.Data[:res.Size]
+	return res, nil
+}
+
+// Close stops the git process.
+func (s *CatFile) Close() error {
+	return s.p.Close()
+}
+
+// CatFileCheck reads object type and size using a single long-lived git cat-file --batch-check process, without reading object content.
+// Not safe for concurrent use. Call Close when done.
+type CatFileCheck struct {
+	p *batchProcess
+}
+
+// NewCatFileCheck starts git cat-file --batch-check in repoDir.
+func NewCatFileCheck(ctx context.Context, gitCommand string, repoDir string) (*CatFileCheck, error) {
+	p, err := startBatch(ctx, gitCommand, repoDir, []string{"cat-file", "--batch-check"})
+	if err != nil {
+		return nil, err
+	}
+	s := &CatFileCheck{}
+	s.p = p
+	return s, nil
+}
+
+// Info returns the object sha, type and size. Data is not set.
+func (s *CatFileCheck) Info(object string) (res Object, _ error) {
+	if strings.ContainsAny(object, "\n") {
+		return res, fmt.Errorf("invalid object name: %q", object)
+	}
+	err := s.p.request(object)
+	if err != nil {
+		return res, err
+	}
+	header, err := s.p.readLine()
+	if err != nil {
+		return res, err
+	}
+	return parseObjectHeader(object, header)
+}
+
+// Close stops the git process.
+func (s *CatFileCheck) Close() error {
+	return s.p.Close()
+}
+
+func parseObjectHeader(object string, header string) (res Object, _ error) {
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return res, fmt.Errorf("%w: %v", ErrObjectNotFound, object)
+	}
+	parts := strings.Split(header, " ")
+	if len(parts) != 3 {
+		return res, fmt.Errorf("unexpected git cat-file header: %v", header)
+	}
+	res.SHA = parts[0]
+	res.Type = parts[1]
+	size, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return res, fmt.Errorf("unexpected git cat-file header: %v", header)
+	}
+	res.Size = size
+	return res, nil
+}
+
+// DiffTree returns diffs using a single long-lived git diff-tree --stdin process, instead of starting git for each diff.
+// Not safe for concurrent use. Call Close when done.
+type DiffTree struct {
+	p *batchProcess
+	// marker is the diff of existing commit to itself, which has no changes and is used as end of response marker
+	marker string
+}
+
+// NewDiffTree starts git diff-tree --stdin in repoDir. Pass additional args to control output format, for example -p -M.
+// Repo must have at least one commit.
+func NewDiffTree(ctx context.Context, gitCommand string, repoDir string, args ...string) (*DiffTree, error) {
+	head := headCommit(ctx, gitCommand, repoDir)
+	if head == "" {
+		return nil, fmt.Errorf("git diff-tree requires a repo with HEAD commit, repo: %v", repoDir)
+	}
+	// print commit and passed parent on each response, so that response to the marker differs from the actual request
+	args = append([]string{"diff-tree", "--stdin", "--always", "-r", "--pretty=tformat:%H %P"}, args...)
+	p, err := startBatch(ctx, gitCommand, repoDir, args)
+	if err != nil {
+		return nil, err
+	}
+	s := &DiffTree{}
+	s.p = p
+	s.marker = head + " " + head
+	return s, nil
+}
+
+// Diff returns the diff from commit from to commit to, same as git diff-tree -r from to. Both must be full shas.
+// Returns ErrObjectNotFound if commit to does not exist. If from does not exist git exits and all following calls return an error.
+func (s *DiffTree) Diff(from, to string) ([]byte, error) {
+	if strings.ContainsAny(from+to, " \n") {
+		return nil, fmt.Errorf("invalid commit: %q %q", from, to)
+	}
+	req := to + " " + from
+	if req == s.marker {
+		return nil, nil
+	}
+	// diff-tree --stdin expects commit followed by parents, from does not need to be an actual parent
+	err := s.p.request(req)
+	if err != nil {
+		return nil, err
+	}
+	// diff-tree skips unknown commits without output, always write the marker to know where response ends
+	err = s.p.request(s.marker)
+	if err != nil {
+		return nil, err
+	}
+	line, err := s.p.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == s.marker {
+		return nil, fmt.Errorf("%w: %v", ErrObjectNotFound, to)
+	}
+	if line != req {
+		return nil, s.p.error(fmt.Errorf("unexpected git diff-tree output: %v", line))
+	}
+	var res []byte
+	for {
+		line, err := s.p.stdout.ReadString('\n')
+		if err != nil {
+			return nil, s.p.error(err)
+		}
+		if strings.TrimSuffix(line, "\n") == s.marker {
+			break
+		}
+		res = append(res, line...)
+	}
+	// diff is separated from commit line by empty line
+	res = bytes.TrimPrefix(res, []byte("\n"))
+	return res, nil
+}
+
+// Close stops the git process.
+func (s *DiffTree) Close() error {
+	return s.p.Close()
+}
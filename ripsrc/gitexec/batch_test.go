@@ -0,0 +1,116 @@
+package gitexec_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCatFile(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\nb\n").Commit("c1")
+	r.Write("b.txt", "").Commit("c2")
+
+	cf, err := gitexec.NewCatFile(context.Background(), "git", r.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+
+	for i := 0; i < 2; i++ {
+		obj, err := cf.Get("HEAD:a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.Type != "blob" || string(obj.Data) != "a\nb\n" || obj.Size != 4 {
+			t.Fatalf("unexpected object %+v", obj)
+		}
+	}
+	obj, err := cf.Get("HEAD:b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != 0 || len(obj.Data) != 0 {
+		t.Fatalf("expected empty blob, got %+v", obj)
+	}
+	_, err = cf.Get("HEAD:missing.txt")
+	if !errors.Is(err, gitexec.ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound, got %v", err)
+	}
+	// process is still usable after missing object
+	_, err = cf.Get("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatFileCheck(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\nb").Commit("c1")
+
+	cf, err := gitexec.NewCatFileCheck(context.Background(), "git", r.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+
+	obj, err := cf.Info("HEAD:a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Type != "blob" || obj.Size != 3 || len(obj.SHA) != 40 || obj.Data != nil {
+		t.Fatalf("unexpected object %+v", obj)
+	}
+	_, err = cf.Info("HEAD:missing.txt")
+	if !errors.Is(err, gitexec.ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound, got %v", err)
+	}
+	obj, err = cf.Info("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Type != "commit" {
+		t.Fatalf("unexpected object %+v", obj)
+	}
+}
+
+func TestDiffTree(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+	c3 := r.Write("c.txt", "c\n").Commit("c3")
+
+	dt, err := gitexec.NewDiffTree(context.Background(), "git", r.Dir(), "-p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dt.Close()
+
+	got, err := dt.Diff(c1, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := r.Git("diff", c1, c2)
+	if strings.TrimSpace(string(got)) != want {
+		t.Fatalf("invalid diff, wanted\n%v\ngot\n%s", want, got)
+	}
+	got, err = dt.Diff(c1, c3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = r.Git("diff", c1, c3)
+	if strings.TrimSpace(string(got)) != want {
+		t.Fatalf("invalid diff, wanted\n%v\ngot\n%s", want, got)
+	}
+	_, err = dt.Diff(c1, strings.Repeat("1", 40))
+	if !errors.Is(err, gitexec.ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package gitexec
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Env controls the environment of git commands. Set in ctx using WithEnv.
+type Env struct {
+	// UserConfig disables isolation from system and global git config, HOME and hooks.
+	// By default git commands ignore system and global config, run with empty HOME and hooks disabled, so that filters, pagers, hooks and other user settings could not alter output or hang the command.
+	// Repo config is always used. Set UserConfig if user config is needed, for example for custom filters or credentials.
+	UserConfig bool
+
+	// Vars are additional environment variables in KEY=VALUE format, for example GIT_SSH_COMMAND or GIT_ASKPASS. Applied after isolation, so could also override HOME.
+	Vars []string
+
+	// CredentialHelper replaces credential helpers from config, for example "store --file=/path/to/credentials". Used for commands accessing remotes.
+	CredentialHelper string
+
+	// ReadOnly disables writes git could do in the repo as a side effect of read commands, such as index refresh, automatic gc and maintenance.
+	ReadOnly bool
+}
+
+type envKey struct{}
+
+// WithEnv returns context that makes all git commands executed with it use passed env.
+func WithEnv(ctx context.Context, env Env) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+func envFromContext(ctx context.Context) Env {
+	if e, ok := ctx.Value(envKey{}).(Env); ok {
+		return e
+	}
+	return Env{}
+}
+
+// isolatedVars are removed from environment of isolated git commands. These are usually set when running from git hooks or aliases and would make git use a different repo or config.
+var isolatedVars = []string{
+	"GIT_DIR",
+	"GIT_WORK_TREE",
+	"GIT_INDEX_FILE",
+	"GIT_OBJECT_DIRECTORY",
+	"GIT_ALTERNATE_OBJECT_DIRECTORIES",
+	"GIT_COMMON_DIR",
+	"GIT_NAMESPACE",
+	"GIT_CEILING_DIRECTORIES",
+	"GIT_CONFIG",
+	"GIT_CONFIG_PARAMETERS",
+	"GIT_CONFIG_COUNT",
+	"GIT_EXTERNAL_DIFF",
+	"GIT_DIFF_OPTS",
+	"GIT_PAGER",
+	"PAGER",
+	"HOME",
+	"XDG_CONFIG_HOME",
+}
+
+// environ returns environment variables for git command.
+func (s Env) environ() []string {
+	if s.UserConfig {
+		res := os.Environ()
+		if s.ReadOnly {
+			res = append(res, "GIT_OPTIONAL_LOCKS=0")
+		}
+		return append(res, s.Vars...)
+	}
+	var res []string
+	for _, kv := range os.Environ() {
+		if !isolatedVar(kv) {
+			res = append(res, kv)
+		}
+	}
+	res = append(res,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_ATTR_NOSYSTEM=1",
+		"HOME="+os.DevNull,
+		"XDG_CONFIG_HOME="+os.DevNull,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_PAGER=cat",
+	)
+	if s.ReadOnly {
+		// status and diff skip refreshing the index
+		res = append(res, "GIT_OPTIONAL_LOCKS=0")
+	}
+	// later values take precedence
+	return append(res, s.Vars...)
+}
+
+func isolatedVar(kv string) bool {
+	for _, k := range isolatedVars {
+		if strings.HasPrefix(kv, k+"=") {
+			return true
+		}
+	}
+	// GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n> set with GIT_CONFIG_COUNT
+	return strings.HasPrefix(kv, "GIT_CONFIG_KEY_") || strings.HasPrefix(kv, "GIT_CONFIG_VALUE_")
+}
+
+// config returns -c overrides for git command.
+func (s Env) config() (res []string) {
+	if !s.UserConfig {
+		// hooks are not expected to run for commands used, but could be triggered by worktree or checkout commands
+		res = append(res, "core.hooksPath="+os.DevNull)
+	}
+	if s.ReadOnly {
+		res = append(res, "gc.auto=0", "maintenance.auto=false")
+	}
+	if s.CredentialHelper != "" {
+		// empty value resets the list of helpers from config
+		res = append(res, "credential.helper=", "credential.helper="+s.CredentialHelper)
+	}
+	return
+}
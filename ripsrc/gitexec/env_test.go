@@ -0,0 +1,115 @@
+package gitexec_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestEnvIsolation(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+
+	home, err := ioutil.TempDir("", "ripsrc-home-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	err = ioutil.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[ripsrc]\n\ttest = global\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	// set when running from git hooks, would point git to a different repo
+	t.Setenv("GIT_DIR", filepath.Join(home, "missing"))
+
+	run := func(ctx context.Context, args ...string) (string, error) {
+		out := bytes.NewBuffer(nil)
+		err := gitexec.ExecIntoWriter(ctx, out, "git", r.Dir(), args)
+		return strings.TrimSpace(out.String()), err
+	}
+
+	ctx := context.Background()
+	head, err := run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != c1 {
+		t.Errorf("GIT_DIR should be ignored, got head %v", head)
+	}
+	v, err := run(ctx, "config", "--get", "ripsrc.test")
+	if err == nil || v != "" {
+		t.Errorf("global config should be ignored, got %v", v)
+	}
+	v, _ = run(ctx, "config", "--get", "core.hooksPath")
+	if v != os.DevNull {
+		t.Errorf("hooks should be disabled, got hooksPath %v", v)
+	}
+
+	userCtx := gitexec.WithEnv(ctx, gitexec.Env{UserConfig: true})
+	os.Unsetenv("GIT_DIR")
+	v, err = run(userCtx, "config", "--get", "ripsrc.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "global" {
+		t.Errorf("expected global config with UserConfig, got %v", v)
+	}
+}
+
+func TestEnvVarsAndCredentialHelper(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Git("config", "credential.helper", "!echo password=from-repo-config; true")
+
+	ctx := gitexec.WithEnv(context.Background(), gitexec.Env{
+		Vars:             []string{"GIT_AUTHOR_NAME=ripsrc-test", "GIT_AUTHOR_EMAIL=test@example.com"},
+		CredentialHelper: "!f() { echo username=u; echo password=p; }; f",
+	})
+
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", r.Dir(), []string{"var", "GIT_AUTHOR_IDENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "ripsrc-test ") {
+		t.Errorf("expected author from Vars, got %v", out.String())
+	}
+
+	out.Reset()
+	in := strings.NewReader("protocol=https\nhost=example.com\n\n")
+	err = gitexec.ExecIntoWriterWithStdin(ctx, out, in, "git", r.Dir(), []string{"credential", "fill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "username=u\npassword=p\n") {
+		t.Errorf("expected credentials from CredentialHelper, got %v", out.String())
+	}
+}
+
+func TestEnvReadOnly(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Git("config", "gc.auto", "1")
+
+	ctx := gitexec.WithEnv(context.Background(), gitexec.Env{ReadOnly: true})
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", r.Dir(), []string{"config", "--get", "gc.auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "0" {
+		t.Errorf("expected automatic gc to be disabled, got gc.auto %v", out.String())
+	}
+}
@@ -0,0 +1,103 @@
+package gitexec
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Common git failures. Use errors.Is to check for them on errors returned from this package.
+var (
+	// ErrNotARepo is returned when the directory is not a git repository.
+	ErrNotARepo = errors.New("not a git repository")
+	// ErrAmbiguousRef is returned when a ref or revision could not be resolved or is ambiguous.
+	ErrAmbiguousRef = errors.New("ambiguous or unknown git ref")
+	// ErrDetachedHead is returned when the operation requires a branch, but HEAD is detached.
+	ErrDetachedHead = errors.New("detached HEAD")
+	// ErrEmptyRepo is returned from Prepare when the repo has no commits, for example freshly initialized repo with unborn HEAD.
+	ErrEmptyRepo = errors.New("repo has no commits")
+	// ErrUnbornHead is returned from Prepare when HEAD points to a branch without commits, but the repo has other refs. Happens in mirrors where the default branch was renamed.
+	ErrUnbornHead = errors.New("HEAD does not point to a commit")
+)
+
+// ExitError is the error of a replayed git command that exited with non-zero code when recorded, see Replayer. Commands that are run return *exec.ExitError instead. Use ExitCode to check both.
+type ExitError struct {
+	Code int
+	// Msg is the message of the original error.
+	Msg string
+}
+
+func (s *ExitError) Error() string {
+	return s.Msg
+}
+
+// ExitCode returns the exit code of a failed git command from *exec.ExitError or *ExitError in err chain. Returns -1 if err does not contain exit code, for example when the command could not be started.
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var replayErr *ExitError
+	if errors.As(err, &replayErr) {
+		return replayErr.Code
+	}
+	return -1
+}
+
+// Error is returned when git command fails. Contains the command, stderr output and the classified Kind, if known.
+type Error struct {
+	Args   []string
+	Dir    string
+	Stderr string
+	// Kind is one of ErrNotARepo, ErrAmbiguousRef, ErrDetachedHead or nil.
+	Kind error
+	// Err is the original error returned from running the command.
+	Err error
+}
+
+// NewError creates Error and classifies stderr output.
+func NewError(args []string, dir string, stderr string, err error) *Error {
+	s := &Error{}
+	s.Args = args
+	s.Dir = dir
+	s.Stderr = strings.TrimSpace(stderr)
+	s.Err = err
+	s.Kind = classify(stderr)
+	return s
+}
+
+func (s *Error) Error() string {
+	return fmt.Sprintf("failed executing git command: git %v dir: %v err: %v stderr: %v", strings.Join(s.Args, " "), s.Dir, s.Err, s.Stderr)
+}
+
+// Is allows checking Kind with errors.Is.
+func (s *Error) Is(target error) bool {
+	return s.Kind != nil && target == s.Kind
+}
+
+func (s *Error) Unwrap() error {
+	return s.Err
+}
+
+var errorPatterns = []struct {
+	Pattern string
+	Kind    error
+}{
+	{"not a git repository", ErrNotARepo},
+	{"ambiguous argument", ErrAmbiguousRef},
+	{"is ambiguous", ErrAmbiguousRef},
+	{"unknown revision", ErrAmbiguousRef},
+	{"bad revision", ErrAmbiguousRef},
+	{"Needed a single revision", ErrAmbiguousRef},
+	{"is not a symbolic ref", ErrDetachedHead},
+}
+
+func classify(stderr string) error {
+	for _, p := range errorPatterns {
+		if strings.Contains(stderr, p.Pattern) {
+			return p.Kind
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package gitexec
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindGitDir returns the git dir of the repo without running git. Supports regular checkouts, bare repos and linked worktrees or submodules, where .git is a file with "gitdir: <path>" pointing to the actual git dir.
+func FindGitDir(repoDir string) (string, error) {
+	dotGit := filepath.Join(repoDir, ".git")
+	stat, err := os.Stat(dotGit)
+	switch {
+	case os.IsNotExist(err):
+		if isGitDir(repoDir) {
+			// bare repo
+			return repoDir, nil
+		}
+		return "", fmt.Errorf("%w: %v", ErrNotARepo, repoDir)
+	case err != nil:
+		return "", err
+	case stat.IsDir():
+		return dotGit, nil
+	}
+	b, err := ioutil.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	line := string(bytes.TrimSpace(b))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("unexpected .git file content in %v: %q", repoDir, line)
+	}
+	return resolvePath(repoDir, strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))), nil
+}
+
+// CommonGitDir returns the git dir shared by all worktrees of the repo, which contains objects and refs. Same as FindGitDir for repos without linked worktrees.
+func CommonGitDir(repoDir string) (string, error) {
+	gitDir, err := FindGitDir(repoDir)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if os.IsNotExist(err) {
+		return gitDir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolvePath(gitDir, strings.TrimSpace(string(b))), nil
+}
+
+// MainRepoDir returns the dir of the main worktree for linked worktrees, or the repo dir itself otherwise. For bare repos returns the bare repo dir.
+// Use it for data that should be shared by all worktrees of the repo, such as checkpoints.
+func MainRepoDir(repoDir string) (string, error) {
+	commonDir, err := CommonGitDir(repoDir)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Dir(commonDir), nil
+	}
+	return commonDir, nil
+}
+
+func isGitDir(dir string) bool {
+	for _, sub := range []string{"objects", "refs", "HEAD"} {
+		if _, err := os.Stat(filepath.Join(dir, sub)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func resolvePath(base, loc string) string {
+	if filepath.IsAbs(loc) {
+		return filepath.Clean(loc)
+	}
+	return filepath.Join(base, loc)
+}
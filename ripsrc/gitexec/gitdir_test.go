@@ -0,0 +1,73 @@
+package gitexec_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestFindGitDirWorktree(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+
+	tmp, err := ioutil.TempDir("", "ripsrc-worktree-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	wt := filepath.Join(tmp, "wt")
+	r.Git("worktree", "add", "-q", "-b", "wt", wt)
+	bare := filepath.Join(tmp, "bare.git")
+	r.Git("clone", "-q", "--bare", r.Dir(), bare)
+
+	cases := []struct {
+		repoDir string
+		gitDir  string
+		common  string
+		main    string
+	}{
+		{r.Dir(), filepath.Join(r.Dir(), ".git"), filepath.Join(r.Dir(), ".git"), r.Dir()},
+		{wt, filepath.Join(r.Dir(), ".git", "worktrees", "wt"), filepath.Join(r.Dir(), ".git"), r.Dir()},
+		{bare, bare, bare, bare},
+	}
+	for _, c := range cases {
+		gitDir, err := gitexec.FindGitDir(c.repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		common, err := gitexec.CommonGitDir(c.repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		main, err := gitexec.MainRepoDir(c.repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !samePath(gitDir, c.gitDir) || !samePath(common, c.common) || !samePath(main, c.main) {
+			t.Errorf("%v: wanted %v %v %v got %v %v %v", c.repoDir, c.gitDir, c.common, c.main, gitDir, common, main)
+		}
+	}
+
+	_, err = gitexec.FindGitDir(tmp)
+	if !errors.Is(err, gitexec.ErrNotARepo) {
+		t.Errorf("expected ErrNotARepo, got %v", err)
+	}
+}
+
+func samePath(a, b string) bool {
+	a, err := filepath.EvalSymlinks(a)
+	if err != nil {
+		return false
+	}
+	b, err = filepath.EvalSymlinks(b)
+	if err != nil {
+		return false
+	}
+	return a == b
+}
@@ -12,6 +12,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 const cacheDir = "pp-git-cache"
@@ -110,29 +112,47 @@ func hashString(str string) string {
 }
 
 func headCommit(ctx context.Context, gitCommand string, repoDir string) string {
-	out := bytes.NewBuffer(nil)
-	c := exec.Command(gitCommand, "rev-parse", "HEAD")
-	c.Dir = repoDir
-	c.Stdout = out
-	c.Run()
-	res := strings.TrimSpace(out.String())
+	res := strings.TrimSpace(quietOutput(ctx, gitCommand, repoDir, []string{"rev-parse", "HEAD"}))
 	if len(res) != 40 {
 		return ""
 	}
 	return res
 }
 
+// hasRefs returns true if the repo has at least one branch, tag or other ref. Errors are treated as no refs, callers check the repo before.
+func hasRefs(ctx context.Context, gitCommand string, repoDir string) bool {
+	return strings.TrimSpace(quietOutput(ctx, gitCommand, repoDir, []string{"for-each-ref", "--count=1", "--format=%(objectname)"})) != ""
+}
+
+// quietOutput returns stdout of the command ignoring errors and without printing stderr. Used for checks where failure is an expected result.
+func quietOutput(ctx context.Context, gitCommand string, repoDir string, args []string) string {
+	out := bytes.NewBuffer(nil)
+	run := func(wr io.Writer, _ io.Reader) error {
+		env := envFromContext(ctx)
+		c := exec.CommandContext(ctx, gitCommand, safeArgs(env, args)...)
+		c.Dir = repoDir
+		c.Env = env.environ()
+		c.Stdout = wr
+		return c.Run()
+	}
+	if rep := replayerFromContext(ctx); rep != nil {
+		rep.exec(out, nil, repoDir, args)
+	} else if rec := recorderFromContext(ctx); rec != nil {
+		rec.exec(ctx, out, nil, args, run)
+	} else {
+		run(out, nil)
+	}
+	return out.String()
+}
+
+// ExecPiped runs git command in background and returns its output. Git errors, including cancelation of ctx, are returned from Read after the output.
+// Closing the reader before reading all output stops the command.
 func ExecPiped(ctx context.Context, gitCommand string, repoDir string, args []string) (io.ReadCloser, error) {
 	r, wr := io.Pipe()
 	go func() {
 		err := ExecIntoWriter(ctx, wr, gitCommand, repoDir, args)
-		if err != nil {
-			panic(err)
-		}
-		err = wr.Close()
-		if err != nil {
-			panic(err)
-		}
+		// nil err closes normally with io.EOF
+		wr.CloseWithError(err)
 	}()
 	return r, nil
 }
@@ -147,14 +167,107 @@ func Exec(ctx context.Context, gitCommand string, repoDir string, args []string)
 }
 
 func ExecIntoWriter(ctx context.Context, wr io.Writer, gitCommand string, repoDir string, args []string) error {
-	c := exec.CommandContext(ctx, gitCommand, args...)
+	return ExecIntoWriterWithStdin(ctx, wr, nil, gitCommand, repoDir, args)
+}
+
+// ExecIntoWriterWithStdin is the same as ExecIntoWriter, but also passes stdin to the command. Used for commands such as git patch-id.
+// Timeouts and retries are controlled by Policy set in ctx using WithPolicy, DefaultPolicy otherwise. Returned errors are *Error and include git stderr output.
+// Commands are replayed or recorded when ctx has Replayer or Recorder, see WithReplayer and WithRecorder.
+func ExecIntoWriterWithStdin(ctx context.Context, wr io.Writer, stdin io.Reader, gitCommand string, repoDir string, args []string) error {
+	if rep := replayerFromContext(ctx); rep != nil {
+		return rep.exec(wr, stdin, repoDir, args)
+	}
+	if rec := recorderFromContext(ctx); rec != nil {
+		return rec.exec(ctx, wr, stdin, args, func(wr io.Writer, stdin io.Reader) error {
+			return execWithPolicy(ctx, wr, stdin, gitCommand, repoDir, args)
+		})
+	}
+	return execWithPolicy(ctx, wr, stdin, gitCommand, repoDir, args)
+}
+
+func execWithPolicy(ctx context.Context, wr io.Writer, stdin io.Reader, gitCommand string, repoDir string, args []string) error {
+	policy := policyFromContext(ctx)
+	out := &countingWriter{wr: wr}
+	delay := policy.RetryDelay
+	for attempt := 0; ; attempt++ {
+		stderr, err := execOnce(ctx, policy.timeout(args), out, stdin, gitCommand, repoDir, args)
+		if err == nil {
+			return nil
+		}
+		retry := attempt < policy.Retries && stdin == nil && out.n == 0 && ctx.Err() == nil && isTransient(stderr)
+		if !retry {
+			return NewError(args, repoDir, stderr, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// maxStderr limits the amount of stderr output kept for errors
+const maxStderr = 10 * 1024
+
+// execOnce runs the command once. timeout is the per-command timeout from Policy, 0 for none. Errors caused by the caller canceling ctx are returned as is.
+func execOnce(ctx context.Context, timeout time.Duration, wr io.Writer, stdin io.Reader, gitCommand string, repoDir string, args []string) (stderr string, _ error) {
+	cmdCtx := ctx
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	errBuf := &limitedBuffer{max: maxStderr}
+	env := envFromContext(ctx)
+	c := exec.CommandContext(cmdCtx, gitCommand, safeArgs(env, args)...)
 	c.Dir = repoDir
-	c.Stderr = os.Stderr
+	c.Env = env.environ()
+	c.Stdin = stdin
+	c.Stderr = io.MultiWriter(os.Stderr, errBuf)
 	c.Stdout = wr
-	if err := c.Run(); err != nil {
-		return fmt.Errorf("failed executing git command %v", err)
+	err := c.Run()
+	if err != nil && timeout != 0 && cmdCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return errBuf.String(), fmt.Errorf("timed out after %v: %w", timeout, err)
 	}
-	return nil
+	return errBuf.String(), err
+}
+
+type countingWriter struct {
+	wr io.Writer
+	n  int64
+}
+
+func (s *countingWriter) Write(p []byte) (int, error) {
+	n, err := s.wr.Write(p)
+	s.n += int64(n)
+	return n, err
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest. Safe to read while long-lived process writes to it.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (s *limitedBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rem := s.max - s.buf.Len(); rem > 0 {
+		if len(p) > rem {
+			s.buf.Write(p[:rem])
+		} else {
+			s.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (s *limitedBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
 }
 
 type noopReadCloser struct {
@@ -0,0 +1,137 @@
+package gitexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubcommand(t *testing.T) {
+	cases := []struct {
+		Args []string
+		Want string
+	}{
+		{[]string{"log", "--all"}, "log"},
+		{[]string{"-c", "diff.renameLimit=10000", "log"}, "log"},
+		{[]string{"--no-pager", "show"}, "show"},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		got := subcommand(c.Args)
+		if got != c.Want {
+			t.Errorf("args %v, want %v got %v", c.Args, c.Want, got)
+		}
+	}
+}
+
+func TestErrorIncludesStderr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ripsrc-gitexec-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ExecIntoWriter(context.Background(), ioutil.Discard, "git", dir, []string{"rev-parse", "HEAD"})
+	if err == nil {
+		t.Fatal("expected error outside of git repo")
+	}
+	if !strings.Contains(err.Error(), "not a git repository") {
+		t.Errorf("expected stderr in error, got %v", err)
+	}
+	if !errors.Is(err, ErrNotARepo) {
+		t.Errorf("expected ErrNotARepo, got %v", err)
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ripsrc-gitexec-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// fake git command that fails with index.lock error on first call
+	script := filepath.Join(dir, "git")
+	marker := filepath.Join(dir, "marker")
+	err = ioutil.WriteFile(script, []byte(`#!/bin/sh
+if [ ! -f `+marker+` ]; then
+	touch `+marker+`
+	echo "fatal: Unable to create '.git/index.lock': File exists." >&2
+	exit 128
+fi
+echo ok
+`), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithPolicy(context.Background(), Policy{Retries: 1, RetryDelay: time.Millisecond})
+	out := bytes.NewBuffer(nil)
+	err = ExecIntoWriter(ctx, out, script, dir, []string{"status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "ok\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ripsrc-gitexec-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "git")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nexec sleep 10\n"), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithPolicy(context.Background(), Policy{CommandTimeouts: map[string]time.Duration{"log": 50 * time.Millisecond}})
+	start := time.Now()
+	err = ExecIntoWriter(ctx, ioutil.Discard, script, dir, []string{"log"})
+	var exitErr *exec.ExitError
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") || !errors.As(err, &exitErr) {
+		t.Fatalf("expected timeout error wrapping exit error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("command was not killed on timeout")
+	}
+
+	// deadline of the caller is not reported as command timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = ExecIntoWriter(ctx, ioutil.Discard, script, dir, []string{"log"})
+	if err == nil || strings.Contains(err.Error(), "timed out") || !errors.As(err, &exitErr) {
+		t.Fatalf("expected exit error without timeout, got %v", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		Stderr string
+		Want   error
+	}{
+		{"fatal: not a git repository (or any of the parent directories): .git", ErrNotARepo},
+		{"fatal: ambiguous argument 'x': unknown revision or path not in the working tree.", ErrAmbiguousRef},
+		{"warning: refname 'a' is ambiguous.", ErrAmbiguousRef},
+		{"fatal: ref HEAD is not a symbolic ref", ErrDetachedHead},
+		{"fatal: something else", nil},
+	}
+	for _, c := range cases {
+		err := NewError([]string{"log"}, "", c.Stderr, errors.New("exit status 128"))
+		if c.Want == nil {
+			if err.Kind != nil {
+				t.Errorf("stderr %q, expected no kind, got %v", c.Stderr, err.Kind)
+			}
+			continue
+		}
+		if !errors.Is(err, c.Want) {
+			t.Errorf("stderr %q, want %v got %v", c.Stderr, c.Want, err.Kind)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package gitexec
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Policy controls timeouts and retries for git commands.
+type Policy struct {
+	// Timeout is the maximum duration of a single git command attempt. 0 means no timeout.
+	Timeout time.Duration
+	// CommandTimeouts overrides Timeout for specific git subcommands, for example {"log": time.Hour}.
+	CommandTimeouts map[string]time.Duration
+	// Retries is the number of additional attempts for transient failures, such as index.lock contention.
+	// Commands are only retried if no output was written yet and no stdin was passed.
+	Retries int
+	// RetryDelay is the wait before the first retry. Doubled after each attempt.
+	RetryDelay time.Duration
+}
+
+// DefaultPolicy is used when context does not have a policy set using WithPolicy.
+var DefaultPolicy = Policy{
+	Retries:    3,
+	RetryDelay: 200 * time.Millisecond,
+}
+
+type policyKey struct{}
+
+// WithPolicy returns context that makes all git commands executed with it use passed policy.
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+func policyFromContext(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+// timeout returns the timeout for git command with args.
+func (s Policy) timeout(args []string) time.Duration {
+	if d, ok := s.CommandTimeouts[subcommand(args)]; ok {
+		return d
+	}
+	return s.Timeout
+}
+
+// subcommand returns git subcommand skipping global options such as -c key=value.
+func subcommand(args []string) string {
+	i := subcommandIndex(args)
+	if i == -1 {
+		return ""
+	}
+	return args[i]
+}
+
+// subcommandIndex returns the position of git subcommand in args, or -1 if there is none.
+func subcommandIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "-c" || a == "-C" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+var transientErrors = []string{
+	"index.lock",
+	"cannot lock ref",
+	"Unable to create",
+	"Resource temporarily unavailable",
+	"Stale file handle",
+}
+
+// isTransient returns true if git stderr output indicates failure that could succeed on retry.
+func isTransient(stderr string) bool {
+	for _, s := range transientErrors {
+		if strings.Contains(stderr, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -13,10 +13,14 @@ const gitattributesConfig = `
 ** diff
 `
 */
+// Prepare checks that HEAD points to a commit. Returns ErrEmptyRepo if the repo has no refs and ErrUnbornHead if HEAD could not be resolved but there are other refs.
 func Prepare(ctx context.Context, gitCommand, repoDir string) error {
 	headCommit := headCommit(ctx, gitCommand, repoDir)
 	if headCommit == "" {
-		return fmt.Errorf("can't get head commit for repo: %v", repoDir)
+		if !hasRefs(ctx, gitCommand, repoDir) {
+			return fmt.Errorf("%w: %v", ErrEmptyRepo, repoDir)
+		}
+		return fmt.Errorf("can't get head commit for repo: %w: %v", ErrUnbornHead, repoDir)
 	}
 
 	/*
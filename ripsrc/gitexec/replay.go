@@ -0,0 +1,393 @@
+package gitexec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by git commands replayed using Replayer when the command is not in the bundle.
+var ErrNotRecorded = errors.New("git command was not recorded")
+
+// bundleIndex is the file in the bundle dir listing recorded commands, one json encoded recordedCommand per line.
+const bundleIndex = "commands.jsonl"
+
+// recordedCommand is an entry of the bundle index.
+type recordedCommand struct {
+	// Key identifies the command for replay, see commandKey.
+	Key  string   `json:"key"`
+	Args []string `json:"args"`
+	// Output is the name of gzipped stdout file in the bundle dir.
+	Output string `json:"output"`
+	Stderr string `json:"stderr,omitempty"`
+	// Err is the error returned from running the command, empty on success.
+	Err string `json:"err,omitempty"`
+	// ExitCode is the exit code of the failed command, 0 if it succeeded or did not exit, for example could not be started.
+	ExitCode int `json:"exit_code,omitempty"`
+	// Seq is the order in which commands were started. Commands are added to index when finished, so the order in index could differ.
+	Seq int `json:"seq"`
+}
+
+// error returns the recorded error, *ExitError if the command exited with non-zero code. Nil on success.
+func (s recordedCommand) error() error {
+	if s.Err == "" {
+		return nil
+	}
+	if s.ExitCode != 0 {
+		return &ExitError{Code: s.ExitCode, Msg: s.Err}
+	}
+	return errors.New(s.Err)
+}
+
+// commandKey returns the key used to match replayed commands. Values of -c options pointing to temp files are replaced, since these are created for each run.
+func commandKey(args []string, stdin []byte) string {
+	tmp := os.TempDir()
+	var parts []string
+	for i, a := range args {
+		if i > 0 && args[i-1] == "-c" {
+			if j := strings.Index(a, "="+tmp); j != -1 {
+				a = a[:j] + "=<tmp>"
+			}
+		}
+		parts = append(parts, a)
+	}
+	if stdin != nil {
+		h := sha256.Sum256(stdin)
+		parts = append(parts, "<stdin:"+hex.EncodeToString(h[:])+">")
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// batchKey returns the key of a long-lived batch process, which is recorded as a single command with all responses as output.
+func batchKey(args []string) string {
+	return commandKey(append([]string{"<batch>"}, args...), nil)
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns context that makes all git commands executed with it record their output using rec.
+func WithRecorder(ctx context.Context, rec *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+func recorderFromContext(ctx context.Context) *Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return rec
+}
+
+type replayerKey struct{}
+
+// WithReplayer returns context that makes all git commands executed with it return output recorded in rep bundle instead of running git.
+func WithReplayer(ctx context.Context, rep *Replayer) context.Context {
+	return context.WithValue(ctx, replayerKey{}, rep)
+}
+
+func replayerFromContext(ctx context.Context) *Replayer {
+	rep, _ := ctx.Value(replayerKey{}).(*Replayer)
+	return rep
+}
+
+// Recorder captures output of git commands into a bundle dir, so that a run could be replayed later using Replayer without git and the repo, for example to reproduce a bug reported for a repo that could not be shared.
+// The bundle contains file contents and history returned by git, so it has to be handled the same way as the repo.
+// Commands are recorded when they finish, commands canceled using ctx are not recorded. Long-lived batch processes, such as cat-file --batch, are recorded with all responses when closed.
+// Safe for concurrent use.
+type Recorder struct {
+	dir string
+	mu  sync.Mutex
+	n   int
+}
+
+// NewRecorder creates a recorder writing to dir. Dir is created on first command and should be empty.
+func NewRecorder(dir string) *Recorder {
+	s := &Recorder{}
+	s.dir = dir
+	return s
+}
+
+// recording is the output of a single command being written to the bundle.
+type recording struct {
+	rec  *Recorder
+	cmd  recordedCommand
+	f    *os.File
+	gz   *gzip.Writer
+	werr error
+}
+
+func (s *Recorder) begin(key string, args []string) (*recording, error) {
+	s.mu.Lock()
+	s.n++
+	n := s.n
+	s.mu.Unlock()
+	err := os.MkdirAll(s.dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%06d.out.gz", n)
+	f, err := os.Create(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	r := &recording{}
+	r.rec = s
+	r.cmd = recordedCommand{Key: key, Args: args, Output: name, Seq: n}
+	r.f = f
+	r.gz = gzip.NewWriter(f)
+	return r, nil
+}
+
+// Write keeps the first write error to return from finish, so that the command output is not affected by recording failures.
+func (s *recording) Write(p []byte) (int, error) {
+	if s.werr == nil {
+		_, s.werr = s.gz.Write(p)
+	}
+	return len(p), nil
+}
+
+// discard removes the output of a command that should not be recorded.
+func (s *recording) discard() {
+	s.gz.Close()
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
+
+// finish writes the output and adds the command to the index. err is the error returned from the command.
+func (s *recording) finish(err error) error {
+	if err != nil {
+		var gerr *Error
+		if errors.As(err, &gerr) {
+			s.cmd.Stderr = gerr.Stderr
+			if gerr.Err != nil {
+				err = gerr.Err
+			}
+		}
+		s.cmd.Err = err.Error()
+		if code := ExitCode(err); code > 0 {
+			s.cmd.ExitCode = code
+		}
+	}
+	werr := s.werr
+	if err := s.gz.Close(); werr == nil {
+		werr = err
+	}
+	if err := s.f.Close(); werr == nil {
+		werr = err
+	}
+	if werr != nil {
+		return fmt.Errorf("could not record git command output, dir: %v err: %v", s.rec.dir, werr)
+	}
+	return s.rec.addToIndex(s.cmd)
+}
+
+func (s *Recorder) addToIndex(cmd recordedCommand) error {
+	b, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(s.dir, bundleIndex), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("could not record git command, dir: %v err: %v", s.dir, err)
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not record git command, dir: %v err: %v", s.dir, err)
+	}
+	return f.Close()
+}
+
+// exec runs the command using run and records its output and error.
+func (s *Recorder) exec(ctx context.Context, wr io.Writer, stdin io.Reader, args []string, run func(wr io.Writer, stdin io.Reader) error) error {
+	var stdinBytes []byte
+	if stdin != nil {
+		var err error
+		stdinBytes, err = ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		stdin = bytes.NewReader(stdinBytes)
+	}
+	rec, err := s.begin(commandKey(args, stdinBytes), args)
+	if err != nil {
+		return fmt.Errorf("could not record git command, dir: %v err: %v", s.dir, err)
+	}
+	err = run(io.MultiWriter(rec, wr), stdin)
+	if err != nil && ctx.Err() != nil {
+		rec.discard()
+		return err
+	}
+	if rerr := rec.finish(err); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// Replayer returns output recorded by Recorder instead of running git. Commands are matched by arguments and stdin. Repeated commands return recordings in the order they were started when recording, the last one is returned when all were used.
+// Options that affect git commands have to be the same as when recording. Safe for concurrent use.
+type Replayer struct {
+	dir string
+
+	once     sync.Once
+	loadErr  error
+	mu       sync.Mutex
+	commands map[string][]recordedCommand
+	last     map[string]recordedCommand
+}
+
+// NewReplayer creates a replayer reading bundle from dir. Bundle is read on first command.
+func NewReplayer(dir string) *Replayer {
+	s := &Replayer{}
+	s.dir = dir
+	return s
+}
+
+func (s *Replayer) load() error {
+	s.once.Do(func() {
+		s.commands = map[string][]recordedCommand{}
+		s.last = map[string]recordedCommand{}
+		f, err := os.Open(filepath.Join(s.dir, bundleIndex))
+		if err != nil {
+			s.loadErr = fmt.Errorf("could not read git replay bundle, dir: %v err: %v", s.dir, err)
+			return
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 100*1024*1024)
+		for sc.Scan() {
+			var cmd recordedCommand
+			err := json.Unmarshal(sc.Bytes(), &cmd)
+			if err != nil {
+				s.loadErr = fmt.Errorf("could not parse git replay bundle, dir: %v err: %v", s.dir, err)
+				return
+			}
+			s.commands[cmd.Key] = append(s.commands[cmd.Key], cmd)
+		}
+		if err := sc.Err(); err != nil {
+			s.loadErr = fmt.Errorf("could not read git replay bundle, dir: %v err: %v", s.dir, err)
+			return
+		}
+		for _, cmds := range s.commands {
+			sort.Slice(cmds, func(i, j int) bool {
+				return cmds[i].Seq < cmds[j].Seq
+			})
+		}
+	})
+	return s.loadErr
+}
+
+func (s *Replayer) next(key string, args []string) (recordedCommand, error) {
+	err := s.load()
+	if err != nil {
+		return recordedCommand{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmds := s.commands[key]
+	if len(cmds) == 0 {
+		if cmd, ok := s.last[key]; ok {
+			return cmd, nil
+		}
+		return recordedCommand{}, fmt.Errorf("%w: git %v", ErrNotRecorded, strings.Join(args, " "))
+	}
+	s.commands[key] = cmds[1:]
+	s.last[key] = cmds[0]
+	return cmds[0], nil
+}
+
+func (s *Replayer) output(cmd recordedCommand) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, cmd.Output))
+	if err != nil {
+		return nil, fmt.Errorf("could not read git replay bundle, dir: %v err: %v", s.dir, err)
+	}
+	return newGzipFileCloser(f)
+}
+
+// exec writes recorded output of the command to wr and returns the recorded error.
+func (s *Replayer) exec(wr io.Writer, stdin io.Reader, repoDir string, args []string) error {
+	var stdinBytes []byte
+	if stdin != nil {
+		var err error
+		stdinBytes, err = ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+	}
+	cmd, err := s.next(commandKey(args, stdinBytes), args)
+	if err != nil {
+		return err
+	}
+	out, err := s.output(cmd)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(wr, out)
+	if err != nil {
+		return NewError(args, repoDir, "", err)
+	}
+	if err := cmd.error(); err != nil {
+		return NewError(args, repoDir, cmd.Stderr, err)
+	}
+	return nil
+}
+
+// startBatch returns batch process which responses are read from the recorded output. Requests are not checked.
+func (s *Replayer) startBatch(repoDir string, args []string) (*batchProcess, error) {
+	cmd, err := s.next(batchKey(args), args)
+	if err != nil {
+		return nil, NewError(args, repoDir, "", err)
+	}
+	out, err := s.output(cmd)
+	if err != nil {
+		return nil, NewError(args, repoDir, "", err)
+	}
+	p := &batchProcess{}
+	p.args = args
+	p.dir = repoDir
+	p.stderr = &limitedBuffer{max: maxStderr}
+	p.stderr.Write([]byte(cmd.Stderr))
+	p.stdin = nopWriteCloser{ioutil.Discard}
+	p.stdout = bufio.NewReaderSize(out, 64*1024)
+	p.replayed = replayedBatch{ReadCloser: out, p: p, err: cmd.error()}
+	return p, nil
+}
+
+// replayedBatch returns the recorded error of the batch process from Close.
+type replayedBatch struct {
+	io.ReadCloser
+	p   *batchProcess
+	err error
+}
+
+func (s replayedBatch) Close() error {
+	err := s.ReadCloser.Close()
+	if err != nil {
+		return err
+	}
+	if s.err != nil {
+		return s.p.error(s.err)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package gitexec_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestRecordReplay(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	attrs := func() string {
+		f, err := ioutil.TempFile("", "ripsrc-attributes-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		return f.Name()
+	}
+
+	type output struct {
+		Head    string
+		Log     string
+		Missing error
+		// NotAncestor is the exit code of merge-base --is-ancestor for commits that are not ancestors, 1
+		NotAncestor int
+		PatchID     string
+		Blob        string
+	}
+	run := func(ctx context.Context, gitCommand, repoDir string) (res output) {
+		t.Helper()
+		err := gitexec.Prepare(ctx, gitCommand, repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := bytes.NewBuffer(nil)
+		err = gitexec.ExecIntoWriter(ctx, out, gitCommand, repoDir, []string{"rev-parse", "HEAD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Head = out.String()
+		// temp files in args differ in each run
+		tmp := attrs()
+		defer os.Remove(tmp)
+		out.Reset()
+		err = gitexec.ExecIntoWriter(ctx, out, gitCommand, repoDir, []string{"-c", "core.attributesFile=" + tmp, "log", "-p", "--format=%H"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Log = out.String()
+		res.Missing = gitexec.ExecIntoWriter(ctx, out, gitCommand, repoDir, []string{"rev-parse", "--verify", "missing"})
+		res.NotAncestor = gitexec.ExitCode(gitexec.ExecIntoWriter(ctx, out, gitCommand, repoDir, []string{"merge-base", "--is-ancestor", c2, c1}))
+		out.Reset()
+		diff := r.Git("show", c2)
+		err = gitexec.ExecIntoWriterWithStdin(ctx, out, strings.NewReader(diff), gitCommand, repoDir, []string{"patch-id", "--stable"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.PatchID = out.String()
+		cf, err := gitexec.NewCatFile(ctx, gitCommand, repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := cf.Get(c2 + ":a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Blob = string(obj.Data)
+		err = cf.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	bundle, err := ioutil.TempDir("", "ripsrc-bundle-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bundle)
+	ctx := gitexec.WithRecorder(context.Background(), gitexec.NewRecorder(bundle))
+	want := run(ctx, "git", r.Dir())
+	if !errors.Is(want.Missing, gitexec.ErrAmbiguousRef) || want.NotAncestor != 1 || want.Blob != "a\nb\n" || want.PatchID == "" {
+		t.Fatalf("unexpected output when recording %+v", want)
+	}
+
+	// replay without the repo and git
+	empty, err := ioutil.TempDir("", "ripsrc-replay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(empty)
+	ctx = gitexec.WithReplayer(context.Background(), gitexec.NewReplayer(bundle))
+	for i := 0; i < 2; i++ {
+		got := run(ctx, "git-does-not-exist", empty)
+		if got.Head != want.Head || got.Log != want.Log || got.NotAncestor != want.NotAncestor || got.PatchID != want.PatchID || got.Blob != want.Blob {
+			t.Errorf("replayed output differs\ngot  %+v\nwant %+v", got, want)
+		}
+		if !errors.Is(got.Missing, gitexec.ErrAmbiguousRef) || !strings.Contains(got.Missing.Error(), "missing") {
+			t.Errorf("expected replayed error with stderr, got %v", got.Missing)
+		}
+	}
+
+	err = gitexec.ExecIntoWriter(ctx, ioutil.Discard, "git", empty, []string{"log"})
+	if !errors.Is(err, gitexec.ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package gitexec
+
+// SafeConfig is passed as -c overrides to all git commands run by this package, so that user, global or repo config could not change the output format expected by parsers.
+// Config passed by callers using -c is applied after these and takes precedence.
+var SafeConfig = []string{
+	// keep a/ and b/ prefixes in diff headers
+	"diff.noprefix=false",
+	"diff.mnemonicPrefix=false",
+	"diff.srcPrefix=a/",
+	"diff.dstPrefix=b/",
+	"diff.relative=false",
+	// keep leading space on empty context lines
+	"diff.suppressBlankEmpty=false",
+	"color.ui=never",
+	"color.diff=never",
+	// commit lines must only contain hashes
+	"log.decorate=false",
+	"log.showSignature=false",
+	"log.abbrevCommit=false",
+	"log.showRoot=true",
+	"log.follow=false",
+	"core.quotePath=true",
+	"i18n.logOutputEncoding=UTF-8",
+}
+
+// safeDiffFlags are added to commands that could output diffs. Disables external diff drivers (diff.external, GIT_EXTERNAL_DIFF and gitattributes), textconv filters and colors.
+var safeDiffFlags = []string{"--no-ext-diff", "--no-textconv", "--no-color"}
+
+var diffCommands = map[string]bool{
+	"log":          true,
+	"show":         true,
+	"diff":         true,
+	"diff-tree":    true,
+	"format-patch": true,
+}
+
+// safeArgs returns args with SafeConfig and env config prepended and safeDiffFlags added after subcommand for commands that could output diffs.
+func safeArgs(env Env, args []string) []string {
+	config := append(SafeConfig[:len(SafeConfig):len(SafeConfig)], env.config()...)
+	res := make([]string, 0, len(config)*2+len(args)+len(safeDiffFlags))
+	for _, c := range config {
+		res = append(res, "-c", c)
+	}
+	i := subcommandIndex(args)
+	if i == -1 || !diffCommands[args[i]] {
+		return append(res, args...)
+	}
+	res = append(res, args[:i+1]...)
+	res = append(res, safeDiffFlags...)
+	return append(res, args[i+1:]...)
+}
@@ -0,0 +1,56 @@
+package gitexec_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestUserConfigDoesNotChangeOutput(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n\nb\n").Commit("c1")
+	r.Write("a.txt", "a\n\nc\n").Commit("c2")
+
+	config := map[string]string{
+		"diff.noprefix":           "true",
+		"diff.mnemonicPrefix":     "true",
+		"diff.suppressBlankEmpty": "true",
+		"diff.external":           "/bin/false",
+		"color.ui":                "always",
+		"color.diff":              "always",
+		"log.decorate":            "full",
+	}
+	for k, v := range config {
+		r.Git("config", k, v)
+	}
+
+	run := func(args ...string) string {
+		out := bytes.NewBuffer(nil)
+		err := gitexec.ExecIntoWriter(context.Background(), out, "git", r.Dir(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	log := run("log", "-p", "--pretty=short", "-1")
+	if !strings.HasPrefix(log, "commit "+r.Head()+"\n") {
+		t.Errorf("commit line should not be decorated or colored, got\n%v", log)
+	}
+	for _, want := range []string{"diff --git a/a.txt b/a.txt\n", "--- a/a.txt\n", " a\n \n-b\n+c\n"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log output does not contain %q, got\n%v", want, log)
+		}
+	}
+
+	// diff.external is used by git diff unless disabled
+	diff := run("diff", "HEAD~1", "HEAD")
+	if !strings.HasPrefix(diff, "diff --git a/a.txt b/a.txt\n") {
+		t.Errorf("unexpected diff output\n%v", diff)
+	}
+}
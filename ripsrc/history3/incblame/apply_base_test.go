@@ -33,3 +33,27 @@ func file(hash string, lines ...*Line) Blame {
 func line(str string, commit string) *Line {
 	return &Line{Line: []byte(str), Commit: commit}
 }
+
+func mustParse(content []byte) Diff {
+	res, err := Parse(content)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func mustApply(file Blame, diff Diff, commit string, fileForDebug string) Blame {
+	res, err := Apply(file, diff, commit, fileForDebug)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func mustApplyMerge(parents []Blame, diffs []Diff, commit string, fileForDebug string) Blame {
+	res, err := ApplyMerge(parents, diffs, commit, fileForDebug)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -20,9 +20,9 @@ index 0000000..43f9419
 +`
 
 func TestApplyBasic1(t *testing.T) {
-	diff := Parse([]byte(basicDiff1))
+	diff := mustParse([]byte(basicDiff1))
 	c1 := "c1"
-	f2 := Apply(Blame{}, diff, c1, "")
+	f2 := mustApply(Blame{}, diff, c1, "")
 
 	want := file("c1",
 		line(`package main`, c1),
@@ -57,10 +57,10 @@ func TestApplyBasic2Regular(t *testing.T) {
 	c1 := "c1"
 	c2 := "c2"
 
-	diff := Parse([]byte(basicDiff1))
-	f := Apply(Blame{}, diff, c1, "")
-	diff = Parse([]byte(basicDiff2))
-	f = Apply(f, diff, c2, "")
+	diff := mustParse([]byte(basicDiff1))
+	f := mustApply(Blame{}, diff, c1, "")
+	diff = mustParse([]byte(basicDiff2))
+	f = mustApply(f, diff, c2, "")
 
 	want := file("c2",
 		line(`package main`, c1),
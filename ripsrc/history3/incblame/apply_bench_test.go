@@ -6,28 +6,14 @@ import (
 )
 
 func makeLongDiffCreate(c int) Diff {
-	diffPrefix := `diff --git a/a.txt b/a.txt
-new file mode 100644
-index 0000000..43f9419
---- /dev/null
-+++ b/a.txt
-@@ -0,0 +1,` + strconv.Itoa(c) + ` @@` + "\n"
-
-	diffBytes := []byte{}
-	diffBytes = append(diffBytes, diffPrefix...)
-	for i := 0; i < c; i++ {
-		diffBytes = append(diffBytes, "+a"...)
-		diffBytes = append(diffBytes, '\n')
-	}
-
-	return Parse(diffBytes)
+	return mustParse(makeLongDiffCreateData(c))
 }
 
 func TestApplyNewGenFile(t *testing.T) {
 	const lines = 3
 	diff := makeLongDiffCreate(lines)
 
-	f := Apply(Blame{}, diff, "c1", "")
+	f := mustApply(Blame{}, diff, "c1", "")
 	want := Blame{}
 	for i := 0; i < lines; i++ {
 		want.Lines = append(want.Lines, line("a", "c1"))
@@ -39,8 +25,56 @@ func BenchmarkApplyNewLargeFile(b *testing.B) {
 	const lines = 10000
 	diff := makeLongDiffCreate(lines)
 
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mustApply(Blame{}, diff, "c1", "")
+	}
+}
+
+func TestApplyDoesNotReferenceDiff(t *testing.T) {
+	data := []byte(`diff --git a/a.txt b/a.txt
+new file mode 100644
+index 0000000..43f9419
+--- /dev/null
++++ b/a.txt
+@@ -0,0 +1,2 @@
++a
++b
+`)
+	f := mustApply(Blame{}, mustParse(data), "c1", "")
+	for i := range data {
+		data[i] = 'x'
+	}
+	want := file("c1",
+		line("a", "c1"),
+		line("b", "c1"),
+	)
+	assertEqualFiles(t, f, want)
+}
+
+func makeLongDiffCreateData(c int) []byte {
+	diffPrefix := `diff --git a/a.txt b/a.txt
+new file mode 100644
+index 0000000..43f9419
+--- /dev/null
++++ b/a.txt
+@@ -0,0 +1,` + strconv.Itoa(c) + ` @@` + "\n"
+
+	diffBytes := []byte{}
+	diffBytes = append(diffBytes, diffPrefix...)
+	for i := 0; i < c; i++ {
+		diffBytes = append(diffBytes, "+a"...)
+		diffBytes = append(diffBytes, '\n')
+	}
+	return diffBytes
+}
+
+func BenchmarkParseLargeFile(b *testing.B) {
+	data := makeLongDiffCreateData(10000)
+
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		Apply(Blame{}, diff, "c1", "")
+		mustParse(data)
 	}
 }
 
@@ -59,15 +93,15 @@ func makeLongDiffRemoval(c int) Diff {
 		diffBytes = append(diffBytes, '\n')
 	}
 
-	return Parse(diffBytes)
+	return mustParse(diffBytes)
 }
 
 func TestApplyRemovalsGen(t *testing.T) {
 	const lines = 3
 	diff1 := makeLongDiffCreate(lines)
-	f1 := Apply(Blame{}, diff1, "c1", "")
+	f1 := mustApply(Blame{}, diff1, "c1", "")
 	diff2 := makeLongDiffRemoval(lines)
-	f2 := Apply(f1, diff2, "c2", "")
+	f2 := mustApply(f1, diff2, "c2", "")
 	want := file("c2",
 		line("a", "c1"),
 	)
@@ -77,11 +111,12 @@ func TestApplyRemovalsGen(t *testing.T) {
 func BenchmarkApplyLargeRemoval(b *testing.B) {
 	const lines = 10000
 	diff1 := makeLongDiffCreate(lines)
-	f1 := Apply(Blame{}, diff1, "c1", "")
+	f1 := mustApply(Blame{}, diff1, "c1", "")
 	diff2 := makeLongDiffRemoval(lines)
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		Apply(f1, diff2, "c1", "")
+		mustApply(f1, diff2, "c1", "")
 	}
 }
 
@@ -101,15 +136,15 @@ func makeLongDiffAdd(c int) Diff {
 
 	res = append(res, " a\n"...)
 
-	return Parse(res)
+	return mustParse(res)
 }
 
 func TestApplyAdditionsStartGen(t *testing.T) {
 	const lines = 3
 	diff1 := makeLongDiffCreate(1)
-	f1 := Apply(Blame{}, diff1, "c1", "")
+	f1 := mustApply(Blame{}, diff1, "c1", "")
 	diff2 := makeLongDiffAdd(lines)
-	f2 := Apply(f1, diff2, "c2", "")
+	f2 := mustApply(f1, diff2, "c2", "")
 	want := file("c2",
 		line("b", "c2"),
 		line("b", "c2"),
@@ -122,10 +157,11 @@ func TestApplyAdditionsStartGen(t *testing.T) {
 func BenchmarkApplyLargeAdditionsStart(b *testing.B) {
 	const lines = 10000
 	diff1 := makeLongDiffCreate(1)
-	f1 := Apply(Blame{}, diff1, "c1", "")
+	f1 := mustApply(Blame{}, diff1, "c1", "")
 	diff2 := makeLongDiffAdd(lines)
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		Apply(f1, diff2, "c2", "")
+		mustApply(f1, diff2, "c2", "")
 	}
 }
@@ -0,0 +1,54 @@
+package incblame
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMalformedHunkHeader(t *testing.T) {
+	data := `diff --git a/a.txt b/a.txt
+index 0000000..7898192
+--- a/a.txt
++++ b/a.txt
+@@ -x,1 +1,1 @@
+-a
++b
+`
+	diff, err := Parse([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), "invalid diff context format") {
+		t.Fatalf("expected error for malformed hunk header, got %v", err)
+	}
+	if diff.Path != "a.txt" || diff.PathPrev != "a.txt" {
+		t.Fatalf("expected paths to be set on error, got %+v", diff)
+	}
+	// parser is reused from pool and works after error
+	mustParse([]byte(basicDiff1))
+}
+
+func TestApplyHunkOutsideOfFile(t *testing.T) {
+	data := `diff --git a/a.txt b/a.txt
+index 0000000..7898192
+--- a/a.txt
++++ b/a.txt
+@@ -5,2 +5,2 @@
+ a
+-b
++c
+`
+	f := file("c1", line("a", "c1"))
+	_, err := Apply(f, mustParse([]byte(data)), "c2", "a.txt")
+	if err == nil || !strings.Contains(err.Error(), "file has 1 lines") {
+		t.Fatalf("expected error for hunk outside of file, got %v", err)
+	}
+	_, err = Apply(*BlameUnknownFile("c1"), mustParse([]byte(basicDiff1)), "c2", "a.txt")
+	if err == nil {
+		t.Fatal("expected error applying to unknown blame")
+	}
+}
+
+func TestApplyMergeMismatchedDiffs(t *testing.T) {
+	_, err := ApplyMerge([]Blame{{}, {}}, []Diff{{}}, "m", "a.txt")
+	if err == nil {
+		t.Fatal("expected error when diffs do not match parents")
+	}
+}
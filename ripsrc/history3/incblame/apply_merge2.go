@@ -5,28 +5,35 @@ import (
 )
 
 // ApplyMerge creates a new blame data for file based on parent blame data and merge diff for each parent (generated using git -m option)
-func ApplyMerge(parents []Blame, diffs []Diff, commit string, fileForDebug string) Blame {
+// Returns an error if any of the diffs could not be applied or results for parents differ.
+func ApplyMerge(parents []Blame, diffs []Diff, commit string, fileForDebug string) (Blame, error) {
 	//fmt.Println("apply")
 	//for i, p := range parents {
 	//	fmt.Println("parent", i)
 	//	fmt.Println(p)
 	//}
+	if len(parents) == 0 || len(parents) != len(diffs) {
+		return Blame{}, fmt.Errorf("commit:%v file:%v expected a diff for each parent, got %v parents and %v diffs", commit, fileForDebug, len(parents), len(diffs))
+	}
 	for _, p := range parents {
 		if p.IsBinary {
-			panic("binary parent")
+			return Blame{}, fmt.Errorf("commit:%v file:%v binary parent", commit, fileForDebug)
 		}
 	}
 
 	for _, d := range diffs {
 		if d.IsBinary {
-			panic("binary diff")
+			return Blame{}, fmt.Errorf("commit:%v file:%v binary diff", commit, fileForDebug)
 		}
 	}
 
 	// different view for each parent
 	var cand []Blame
 	for i, p := range parents {
-		res := Apply(p, diffs[i], commit, fileForDebug)
+		res, err := Apply(p, diffs[i], commit, fileForDebug)
+		if err != nil {
+			return Blame{}, err
+		}
 		cand = append(cand, res)
 	}
 	// check that cand lines are eq
@@ -36,7 +43,7 @@ func ApplyMerge(parents []Blame, diffs []Diff, commit string, fileForDebug strin
 	}
 	for _, c := range cand {
 		if len(c.Lines) != len(cand[0].Lines) {
-			panic(fmt.Errorf("not all resulting blames have the same num of file:%v lines:%v", fileForDebug, lenLines))
+			return Blame{}, fmt.Errorf("commit:%v not all resulting blames have the same num of file:%v lines:%v", commit, fileForDebug, lenLines)
 		}
 	}
 
@@ -56,5 +63,5 @@ func ApplyMerge(parents []Blame, diffs []Diff, commit string, fileForDebug strin
 		}
 		// if the line originated from none of the parents it will be set to commit, because it was created here
 	}
-	return res
+	return res, nil
 }
@@ -1,2 +1,2 @@
-// Package incblame generates blame data incrementally based on history of patches. To use first parse the file diff using Parse and then apply resulting Diff to Blame data. You start by calling blame := Apply(nil, diff, "..") and then using new blame data as parents for next commits. See tests for examples.
+// Package incblame generates blame data incrementally based on history of patches. To use first parse the file diff using Parse and then apply resulting Diff to Blame data. You start by calling blame, err := Apply(Blame{}, diff, "..", path) and then using new blame data as parents for next commits. See tests for examples.
 package incblame
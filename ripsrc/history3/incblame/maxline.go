@@ -1,4 +0,0 @@
-package incblame
-
-const mb = 1000 * 1000
-const maxLine = 100 * mb
@@ -1,9 +1,9 @@
 package incblame
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"sync"
 )
 
 // Diff is change made to one file.
@@ -31,6 +31,57 @@ func (h Hunk) String() string {
 	return string(h.Data)
 }
 
+// HunkStats are ranges and changed line counts of a hunk of a regular (not merge) diff. Ranges include context lines around changes.
+type HunkStats struct {
+	// OldStart is the 1-based first line of the hunk in the file before the change, 0 if the hunk has no lines there.
+	OldStart int
+	OldLines int
+	// NewStart is the 1-based first line of the hunk in the file after the change, 0 if the hunk has no lines there.
+	NewStart int
+	NewLines int
+	Added    int
+	Deleted  int
+}
+
+// Stats returns ranges and changed line counts of the hunk. Only meaningful for regular diffs, merge diffs have a range for each parent.
+func (h Hunk) Stats() (res HunkStats) {
+	for _, loc := range h.Locations {
+		start, lines := loc.Offset, loc.Lines
+		if start == 0 && lines != 0 {
+			// short form without count, such as -1, is parsed as count, but it is the start of a single line range
+			start, lines = lines, 1
+		}
+		switch loc.Op {
+		case OpDel:
+			res.OldStart, res.OldLines = start, lines
+		case OpAdd:
+			res.NewStart, res.NewLines = start, lines
+		}
+	}
+	for pos := 0; pos < len(h.Data); {
+		b, next, _ := nextLine(h.Data, pos)
+		pos = next
+		if len(b) == 0 {
+			continue
+		}
+		switch b[0] {
+		case '+':
+			res.Added++
+		case '-':
+			res.Deleted++
+		}
+	}
+	return
+}
+
+// HunkStats returns stats of all hunks of the diff, see Hunk.Stats.
+func (d Diff) HunkStats() (res []HunkStats) {
+	for _, h := range d.Hunks {
+		res = append(res, h.Stats())
+	}
+	return
+}
+
 // HunkLocation is the operation, offset and line modified.
 type HunkLocation struct {
 	Op     OpType
@@ -50,10 +101,40 @@ const (
 
 // Parse parses patch output for one file extracted from the following command.
 // git log -p -c (sames as git-diff-tree -p -c)
-// Returns parsed diff which could be applied to blame data saved in File.
-func Parse(content []byte) (res Diff) {
-	p := newParser(content)
-	return p.Parse()
+// Returns parsed diff which could be applied to blame data saved in File, or an error if the diff is malformed.
+// On error the returned diff has only Path and PathPrev set, if they could be parsed, so that callers could report the affected file.
+// Hunk data references content without copying, content must not be modified while diff is used.
+func Parse(content []byte) (res Diff, rerr error) {
+	p := parserPool.Get().(*parser)
+	defer parserPool.Put(p)
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			res = Diff{PathPrev: p.diff.PathPrev, Path: p.diff.Path}
+			rerr = perr.err
+		}
+	}()
+	p.reset(content)
+	return p.Parse(), nil
+}
+
+// parseError is used by parser to abort parsing of malformed diff, it is recovered in Parse and returned as error.
+type parseError struct {
+	err error
+}
+
+func parseErr(format string, args ...interface{}) parseError {
+	return parseError{err: fmt.Errorf(format, args...)}
+}
+
+// parserPool reuses parser state between calls to Parse.
+var parserPool = sync.Pool{
+	New: func() interface{} {
+		return &parser{preMeta: map[string]string{}}
+	},
 }
 
 const (
@@ -62,6 +143,7 @@ const (
 	stNextIsCurrName = "stNextIsCurrName"
 	stNextIsContext  = "stNextIsContext"
 	stInPatchLines   = "stInPatchLines"
+	stInBinaryPatch  = "stInBinaryPatch"
 )
 
 type parser struct {
@@ -70,26 +152,43 @@ type parser struct {
 
 	diff Diff
 
+	// declPaths is false when paths could not be parsed from diff declaration, in that case names from ---/+++ lines are used
+	declPaths bool
+	namePrev  string
+	name      string
+
 	preMeta         map[string]string
 	currentContexts []HunkLocation
-	currentLines    []byte
 
-	res []Hunk
+	// hunk data is content[hunkStart:hunkEnd], hunkCopy is set when lines need to be normalized and could not be used as is
+	hunkStart int
+	hunkEnd   int
+	hunkCopy  bool
 
-	wantedMeta []string
+	res []Hunk
 }
 
 const metaRenameFrom = "rename from"
 const metaRenameTo = "rename to"
 const metaNewFile = "new file"
 const metaDeletedFile = "deleted file"
+const metaCopyFrom = "copy from"
+const metaCopyTo = "copy to"
 const metaBinaryFiles = "Binary files"
 
-func newParser(content []byte) *parser {
-	p := &parser{}
+// metaBinaryPatch starts binary patch in diffs created with --binary, base85 encoded data follows
+const metaBinaryPatch = "GIT binary patch"
+
+var wantedMeta = []string{metaRenameFrom, metaRenameTo, metaCopyFrom, metaCopyTo, metaNewFile, metaDeletedFile, metaBinaryFiles}
+
+func (p *parser) reset(content []byte) {
+	preMeta := p.preMeta
+	for k := range preMeta {
+		delete(preMeta, k)
+	}
+	*p = parser{}
 	p.content = content
-	p.wantedMeta = []string{metaRenameFrom, metaRenameTo, metaNewFile, metaDeletedFile, metaBinaryFiles}
-	return p
+	p.preMeta = preMeta
 }
 
 func (p *parser) Parse() (res Diff) {
@@ -98,20 +197,21 @@ func (p *parser) Parse() (res Diff) {
 	}
 
 	p.state = stParseDiff
-	p.preMeta = map[string]string{}
 
-	scanner := bufio.NewScanner(bytes.NewReader(p.content))
-	scanner.Buffer(nil, maxLine)
-	for scanner.Scan() {
-		p.line(scanner.Bytes())
-	}
-	if err := scanner.Err(); err != nil {
-		panic(err)
+	for pos := 0; pos < len(p.content); {
+		line, next, full := nextLine(p.content, pos)
+		p.line(line, next, full)
+		pos = next
 	}
 	p.finishHunk()
 
 	p.diff.Hunks = p.res
 
+	if !p.declPaths {
+		p.diff.PathPrev = p.namePrev
+		p.diff.Path = p.name
+	}
+
 	if p.preMeta[metaNewFile] != "" {
 		p.diff.PathPrev = ""
 	}
@@ -122,11 +222,20 @@ func (p *parser) Parse() (res Diff) {
 	}
 
 	if p.preMeta[metaRenameFrom] != "" {
-		p.diff.PathPrev = p.preMeta[metaRenameFrom]
+		p.diff.PathPrev = p.metaPath(metaRenameFrom)
 		if p.preMeta[metaRenameTo] == "" {
-			panic("has rename from, but not rename to")
+			panic(parseErr("has rename from, but not rename to"))
+		}
+		p.diff.Path = p.metaPath(metaRenameTo)
+	}
+
+	// copy keeps the source file, the diff is applied on top of it to create the new one, same as for rename
+	if p.preMeta[metaCopyFrom] != "" {
+		p.diff.PathPrev = p.metaPath(metaCopyFrom)
+		if p.preMeta[metaCopyTo] == "" {
+			panic(parseErr("has copy from, but not copy to"))
 		}
-		p.diff.Path = p.preMeta[metaRenameTo]
+		p.diff.Path = p.metaPath(metaCopyTo)
 	}
 
 	if p.preMeta[metaBinaryFiles] != "" {
@@ -138,13 +247,24 @@ func (p *parser) Parse() (res Diff) {
 	return
 }
 
-func (p *parser) line(b []byte) {
+// metaPath returns path from rename or copy meta line, unquoting it if needed
+func (p *parser) metaPath(key string) string {
+	res, err := unquoteName([]byte(p.preMeta[key]))
+	if err != nil {
+		panic(parseErr("invalid path in %v line: %v", key, err))
+	}
+	return res
+}
+
+// line processes line b, next is the position of the following line in content
+func (p *parser) line(b []byte, next int, full bool) {
 	switch p.state {
 	case stParseDiff:
 		p.state = stParsingPreMeta
 
 		var err error
 		p.diff.PathPrev, p.diff.Path, err = parseDiffDecl(b)
+		p.declPaths = err == nil
 		if err != nil {
 			if err == errParseDiffDeclMerge {
 				// will get name from diff later
@@ -153,46 +273,64 @@ func (p *parser) line(b []byte) {
 				// will get name from diff later
 				return
 			}
-			panic(err)
+			panic(parseError{err: err})
 		}
 	case stParsingPreMeta:
+		if string(b) == metaBinaryPatch {
+			p.diff.IsBinary = true
+			p.state = stInBinaryPatch
+			return
+		}
 		if !startsWith(b, "---") {
-			for _, s := range p.wantedMeta {
+			for _, s := range wantedMeta {
 				if startsWith(b, s+" ") {
 					p.preMeta[s] = string(b[len(s)+1:])
 				}
 			}
 		} else {
+			p.namePrev = extractName(b)
 			p.state = stNextIsCurrName
 
 		}
 	case stNextIsCurrName:
+		if !startsWith(b, "+++") {
+			panic(parseErr("expected +++ line, got %s", b))
+		}
+		p.name = extractName(b)
 		p.state = stNextIsContext
 	case stNextIsContext:
 		if len(b) <= 2 {
 			return
 		}
 		if string(b[0:2]) == "@@" {
-			p.parseContext(b)
+			p.parseContext(b, next)
 			return
 		}
 	case stInPatchLines:
-		p.lineInPatchLines(b)
+		p.lineInPatchLines(b, next, full)
+	case stInBinaryPatch:
+		// binary data is not used
 	default:
 		panic("invalid state")
 	}
 }
 
+// extractName returns path from ---/+++ line without a/ or b/ prefix. Returns empty string for /dev/null.
 func extractName(b []byte) string {
 	if len(b) <= 4 {
-		panic(fmt.Errorf("expected path name, line %s", string(b)))
+		panic(parseErr("expected path name, line %s", string(b)))
 	}
-	name := string(b[4:])
-	if name == "/dev/null" {
+	// git adds tab after names containing spaces
+	data := bytes.TrimSuffix(b[4:], []byte("\t"))
+	if string(data) == "/dev/null" {
 		return ""
 	}
+	name, err := unquoteName(data)
+	if err != nil {
+		panic(parseErr("invalid path name, line %s err: %v", string(b), err))
+	}
 	if len(name) <= 2 {
-		panic(fmt.Errorf("expected path name, line %s", string(b)))
+		panic(parseErr("expected path name, line %s", string(b)))
 	}
 	return name[2:]
 }
@@ -201,27 +339,50 @@ func (p *parser) finishHunk() {
 	if len(p.currentContexts) != 0 {
 		h := Hunk{}
 		h.Locations = p.currentContexts
-		h.Data = p.currentLines
+		h.Data = p.hunkData()
 
 		p.res = append(p.res, h)
 
 	}
 
 	p.currentContexts = nil
-	p.currentLines = nil
 }
 
-func (p *parser) parseContext(b []byte) {
+// hunkData returns hunk lines each followed by \n. Returns a sub slice of content if possible to avoid copying.
+func (p *parser) hunkData() []byte {
+	data := p.content[p.hunkStart:p.hunkEnd:p.hunkEnd]
+	if len(data) == 0 {
+		return nil
+	}
+	if !p.hunkCopy {
+		return data
+	}
+	res := make([]byte, 0, len(data)+1)
+	for pos := 0; pos < len(data); {
+		line, next, _ := nextLine(data, pos)
+		res = append(res, line...)
+		res = append(res, '\n')
+		pos = next
+	}
+	return res
+}
+
+func (p *parser) parseContext(b []byte, next int) {
 	p.currentContexts = parseContext(b)
 	p.state = stInPatchLines
+	p.hunkStart = next
+	p.hunkEnd = next
+	p.hunkCopy = false
 }
 
-func (p *parser) lineInPatchLines(b []byte) {
-	if string(b[0:2]) == "@@" {
+func (p *parser) lineInPatchLines(b []byte, next int, full bool) {
+	if startsWith(b, "@@") {
 		p.finishHunk()
-		p.parseContext(b)
+		p.parseContext(b, next)
 		return
 	}
-	p.currentLines = append(p.currentLines, b...)
-	p.currentLines = append(p.currentLines, "\n"...)
+	p.hunkEnd = next
+	if !full {
+		p.hunkCopy = true
+	}
 }
@@ -30,12 +30,12 @@ func diffString(diff Diff) string {
 }
 
 func tparse(diff string) Diff {
-	return Parse([]byte(diff))
+	return mustParse([]byte(diff))
 }
 
 func tparseDiffs(diffs ...string) (res []Diff) {
 	for _, d := range diffs {
-		res = append(res, Parse([]byte(d)))
+		res = append(res, mustParse([]byte(d)))
 	}
 	return
 }
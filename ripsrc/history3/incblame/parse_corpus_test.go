@@ -0,0 +1,82 @@
+package incblame
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseCorpus parses unusual diffs produced by git, stored in testdata/corpus. Each file in corpus must have an entry here.
+func TestParseCorpus(t *testing.T) {
+	cases := map[string]struct {
+		PathPrev string
+		Path     string
+		IsBinary bool
+		Hunks    int
+	}{
+		"copy_space_in_name":                 {"src file.txt", "copy file.txt", false, 1},
+		"deleted_binary_file":                {"bin.dat", "", true, 0},
+		"deleted_empty_file":                 {"empty.txt", "", false, 0},
+		"git_binary_patch":                   {"bin.dat", "bin.dat", true, 0},
+		"mode_change_only":                   {"run.sh", "run.sh", false, 0},
+		"mode_change_with_content":           {"run.sh", "run.sh", false, 1},
+		"modified_binary_file":               {"bin.dat", "bin.dat", true, 0},
+		"modified_quoted_name_with_spaces":   {"ü x.txt", "ü x.txt", false, 1},
+		"modified_symlink":                   {"link", "link", false, 1},
+		"new_binary_file":                    {"", "bin.dat", true, 0},
+		"new_empty_file":                     {"", "empty.txt", false, 0},
+		"new_file_space_in_name":             {"", "a a.txt", false, 1},
+		"new_symlink":                        {"", "link", false, 1},
+		"quoted_special_chars":               {"", "tab\t\"q\".txt", false, 1},
+		"quoted_unicode_name":                {"", "ä.txt", false, 1},
+		"rename_quoted_unicode_name":         {"ä.txt", "ö.txt", false, 0},
+		"rename_space_in_name":               {"a a.txt", "b b.txt", false, 0},
+		"rename_to_quoted_name_with_changes": {"copy file.txt", "moved ü file.txt", false, 1},
+		"submodule_added":                    {"", "sub", false, 1},
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(cases) {
+		t.Errorf("corpus has %v files, but %v cases", len(files), len(cases))
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".diff")
+		t.Run(name, func(t *testing.T) {
+			want, ok := cases[name]
+			if !ok {
+				t.Fatalf("no case for corpus file %v", f)
+			}
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PathPrev != want.PathPrev || got.Path != want.Path {
+				t.Errorf("invalid paths, got %q:%q wanted %q:%q", got.PathPrev, got.Path, want.PathPrev, want.Path)
+			}
+			if got.IsBinary != want.IsBinary {
+				t.Errorf("invalid IsBinary, got %v", got.IsBinary)
+			}
+			if len(got.Hunks) != want.Hunks {
+				t.Errorf("invalid number of hunks, got %v wanted %v", len(got.Hunks), want.Hunks)
+			}
+			if got.IsBinary || got.Path == "" {
+				return
+			}
+			// added files apply on empty blame, other changes on the file before the change, which is not in corpus
+			if got.PathPrev == "" {
+				_, err = Apply(Blame{}, got, "c1", got.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -43,7 +43,7 @@ index 0000000..43f9419
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -85,7 +85,7 @@ index 43f9419..1671209 100644
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -155,7 +155,7 @@ index 0eb2edb..4422a7d 100644
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -171,7 +171,7 @@ rename to b.txt`
 		Path:     "b.txt",
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -203,7 +203,7 @@ index 7898192..2e65efe 100644
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -218,7 +218,7 @@ index 0000000..e69de29`
 		Path:     "a.txt",
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -237,7 +237,7 @@ index 6178079..0000000
 		Path:     "",
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -267,7 +267,7 @@ index 0000000..7898192
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -296,7 +296,7 @@ index 0000000..7898192
 		},
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
 
@@ -313,6 +313,64 @@ Binary files /dev/null and b/main.go differ`
 		IsBinary: true,
 	}
 
-	got := Parse([]byte(data))
+	got := mustParse([]byte(data))
 	assertEqualDiffs(t, got, want)
 }
+
+func TestParseCRLF(t *testing.T) {
+	data := "diff --git a/a.txt b/a.txt\r\n" +
+		"index 7898192..2e65efe 100644\r\n" +
+		"--- a/a.txt\r\n" +
+		"+++ b/a.txt\r\n" +
+		"@@ -1 +1 @@\r\n" +
+		"-a\r\n" +
+		"+b\r\n"
+
+	want := Diff{
+		PathPrev: "a.txt",
+		Path:     "a.txt",
+		Hunks: []Hunk{
+			{
+				Locations: []HunkLocation{
+					{OpDel, 0, 1},
+					{OpAdd, 0, 1},
+				},
+				Data: []byte("-a\n+b\n"),
+			},
+		},
+	}
+
+	got := mustParse([]byte(data))
+	assertEqualDiffs(t, got, want)
+}
+
+func TestHunkStats(t *testing.T) {
+	data := `diff --git a/a.txt b/a.txt
+index 0000000..43f9419 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,4 +1,4 @@
+ a
+-b
++b2
++b3
+ c
+-d
+@@ -20 +20,2 @@
+ t
++u
+`
+	got := mustParse([]byte(data)).HunkStats()
+	want := []HunkStats{
+		{OldStart: 1, OldLines: 4, NewStart: 1, NewLines: 4, Added: 2, Deleted: 2},
+		{OldStart: 20, OldLines: 1, NewStart: 20, NewLines: 2, Added: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wanted %v hunks, got %v", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("hunk %v wanted %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
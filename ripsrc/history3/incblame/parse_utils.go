@@ -17,7 +17,7 @@ func startsWith(b []byte, prefix string) bool {
 
 func parseContext(b0 []byte) (res []HunkLocation) {
 	rerr := func(msg string) {
-		panic(fmt.Errorf("invalid diff context format %v %v", string(b0), msg))
+		panic(parseErr("invalid diff context format %v %v", string(b0), msg))
 	}
 	atc := 0
 	for _, b := range b0 {
@@ -102,19 +102,18 @@ func parseDiffDecl(diff []byte) (fromPath string, toPath string, _ error) {
 		return "", "", fmt.Errorf("invalid prefix for diff decl %s", diff)
 	}
 	data := diff[len(diffDeclPrefixNormal):]
+	if len(data) != 0 && (data[0] == '"' || data[len(data)-1] == '"') {
+		return parseDiffDeclQuoted(diff, data)
+	}
 	spaceCount := countByte(data, ' ')
 	if spaceCount == 0 {
 		return "", "", fmt.Errorf("invalid format for diff decl, no space sep %s", diff)
 	}
 	remPrefix := func(data []byte, pr string) (string, error) {
-		d2 := data
-		if len(d2) >= 2 && d2[0] == '"' && d2[len(d2)-1] == '"' {
-			d2 = d2[1 : len(d2)-1]
-		}
-		if len(d2) <= len(pr) || string(d2[0:len(pr)]) != pr {
+		if len(data) <= len(pr) || string(data[0:len(pr)]) != pr {
 			return "", fmt.Errorf("invalid format for diff decl %s, removing prefix %s", diff, data)
 		}
-		return string(d2[len(pr):]), nil
+		return string(data[len(pr):]), nil
 	}
 	// simple case with no spaces in name
 	if spaceCount == 1 {
@@ -149,6 +148,70 @@ func parseDiffDecl(diff []byte) (fromPath string, toPath string, _ error) {
 	return
 }
 
+// parseDiffDeclQuoted parses diff declaration where one or both names are quoted. Git quotes names containing special characters, such as tab, quote or non-ascii.
+// For example: diff --git "a/\303\244.txt" "b/\303\266 b.txt"
+func parseDiffDeclQuoted(diff []byte, data []byte) (fromPath string, toPath string, _ error) {
+	var from, to []byte
+	if data[0] == '"' {
+		end := quotedEnd(data)
+		if end == -1 || end+1 >= len(data) || data[end+1] != ' ' {
+			return "", "", fmt.Errorf("invalid format for diff decl %s, unterminated quoted name", diff)
+		}
+		from = data[:end+1]
+		to = data[end+2:]
+	} else {
+		// unquoted name does not contain quotes, so quoted name starts at the first one
+		i := bytes.IndexByte(data, '"')
+		if i < 2 || data[i-1] != ' ' {
+			return "", "", fmt.Errorf("invalid format for diff decl %s", diff)
+		}
+		from = data[:i-1]
+		to = data[i:]
+	}
+	remPrefix := func(data []byte, pr string) (string, error) {
+		name, err := unquoteName(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid format for diff decl %s, err: %v", diff, err)
+		}
+		if len(name) <= len(pr) || name[0:len(pr)] != pr {
+			return "", fmt.Errorf("invalid format for diff decl %s, removing prefix %s", diff, data)
+		}
+		return name[len(pr):], nil
+	}
+	var err error
+	fromPath, err = remPrefix(from, "a/")
+	if err != nil {
+		return "", "", err
+	}
+	toPath, err = remPrefix(to, "b/")
+	if err != nil {
+		return "", "", err
+	}
+	return
+}
+
+// quotedEnd returns the index of the closing quote for quoted string starting at data[0], or -1 if there is none.
+func quotedEnd(data []byte) int {
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteName returns name as is if it is not quoted, otherwise removes quotes and resolves escapes.
+// Git uses c-style escapes in quoted names, with non-ascii bytes as octal, which are compatible with go string literals.
+func unquoteName(b []byte) (string, error) {
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+		return string(b), nil
+	}
+	return strconv.Unquote(string(b))
+}
+
 func countByte(data []byte, b byte) (res int) {
 	for _, v := range data {
 		if v == b {
@@ -157,3 +220,22 @@ func countByte(data []byte, b byte) (res int) {
 	}
 	return
 }
+
+// nextLine returns the line starting at pos without line ending and the position of the next line. Same as bufio.ScanLines, but returns sub slices of data instead of copying.
+// full is false if line had \r before \n or data did not end with \n, so the line could not be used as is with the following new line.
+func nextLine(data []byte, pos int) (line []byte, next int, full bool) {
+	i := bytes.IndexByte(data[pos:], '\n')
+	if i == -1 {
+		line = dropCR(data[pos:])
+		return line, len(data), false
+	}
+	line = dropCR(data[pos : pos+i])
+	return line, pos + i + 1, len(line) == i
+}
+
+func dropCR(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		return data[0 : len(data)-1]
+	}
+	return data
+}
@@ -85,6 +85,31 @@ func TestParseDiffDecl(t *testing.T) {
 			In:        "diff --git a/a a.txt b/b b.txt",
 			ErrSpaces: true,
 		},
+		{
+			Label:    "escaped non-ascii",
+			In:       `diff --git "a/\303\244.txt" "b/\303\266.txt"`,
+			FromPath: "ä.txt",
+			ToPath:   "ö.txt",
+		},
+		{
+			Label:    "escaped tab and quote with spaces",
+			In:       `diff --git "a/x \t\"q\".txt" "b/x \t\"q\".txt"`,
+			FromPath: "x \t\"q\".txt",
+			ToPath:   "x \t\"q\".txt",
+		},
+		{
+			Label:    "only new name quoted",
+			In:       `diff --git a/copy file.txt "b/moved \303\274 file.txt"`,
+			FromPath: "copy file.txt",
+			ToPath:   "moved ü file.txt",
+		},
+		{
+			Label:    "only old name quoted",
+			In:       `diff --git "a/moved \303\274 file.txt" b/copy file.txt`,
+			FromPath: "moved ü file.txt",
+			ToPath:   "copy file.txt",
+		},
+		{Label: "unterminated quote", In: `diff --git "a/a.txt b/a.txt`, ErrOther: true},
 		// check that invalid format does not panic
 		{Label: "invalid format", In: "", ErrOther: true},
 		{Label: "invalid format", In: "diff --git ", ErrOther: true},
@@ -119,3 +144,20 @@ func TestParseDiffDecl(t *testing.T) {
 	}
 
 }
+
+func TestNextLine(t *testing.T) {
+	data := []byte("a\nb\r\n\nc")
+	var got []string
+	var gotFull []bool
+	for pos := 0; pos < len(data); {
+		line, next, full := nextLine(data, pos)
+		got = append(got, string(line))
+		gotFull = append(gotFull, full)
+		pos = next
+	}
+	want := []string{"a", "b", "", "c"}
+	wantFull := []bool{true, false, true, false}
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(gotFull, wantFull) {
+		t.Errorf("wanted %q %v, got %q %v", want, wantFull, got, gotFull)
+	}
+}
@@ -0,0 +1,52 @@
+package incblame
+
+import (
+	"bytes"
+)
+
+var diffDeclPrefix = []byte("diff --git ")
+
+// SplitDiff splits unified diff in git format containing changes to multiple files (output of git diff or git format-patch) into diffs for each file, which could be passed to Parse.
+// Lines before the first diff declaration (for example format-patch email headers) and format-patch signature at the end are ignored.
+func SplitDiff(content []byte) (res [][]byte) {
+	var lines [][]byte
+	// no limit on line length since content is already in memory
+	for pos := 0; pos < len(content); {
+		var line []byte
+		line, pos, _ = nextLine(content, pos)
+		lines = append(lines, line)
+	}
+	lines = trimPatchSignature(lines)
+
+	var cur []byte
+	for _, line := range lines {
+		if bytes.HasPrefix(line, diffDeclPrefix) {
+			if cur != nil {
+				res = append(res, cur)
+			}
+			cur = []byte{}
+		}
+		if cur == nil {
+			// before first diff
+			continue
+		}
+		cur = append(cur, line...)
+		cur = append(cur, '\n')
+	}
+	if cur != nil {
+		res = append(res, cur)
+	}
+	return
+}
+
+// trimPatchSignature removes the "-- \n<git version>" signature added by git format-patch.
+func trimPatchSignature(lines [][]byte) [][]byte {
+	end := len(lines)
+	for end > 0 && len(bytes.TrimSpace(lines[end-1])) == 0 {
+		end--
+	}
+	if end >= 2 && string(lines[end-2]) == "-- " {
+		return lines[:end-2]
+	}
+	return lines
+}
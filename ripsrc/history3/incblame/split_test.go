@@ -0,0 +1,41 @@
+package incblame
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitDiff(t *testing.T) {
+	data := `From 1234 Mon Sep 17 00:00:00 2001
+From: User1 <user1@example.com>
+Subject: [PATCH] c2
+
+---
+ a.txt | 1 +
+diff --git a/a.txt b/a.txt
+index 7898192..e61ef7b 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1,2 @@
+ a
++b
+diff --git a/b.txt b/b.txt
+new file mode 100644
+index 0000000..e61ef7b
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1 @@
++-- 
+-- 
+2.20.1
+
+`
+	got := SplitDiff([]byte(data))
+	want := [][]byte{
+		[]byte("diff --git a/a.txt b/a.txt\nindex 7898192..e61ef7b 100644\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1,2 @@\n a\n+b\n"),
+		[]byte("diff --git a/b.txt b/b.txt\nnew file mode 100644\nindex 0000000..e61ef7b\n--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+-- \n"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%q\ngot\n%q", want, got)
+	}
+}
@@ -0,0 +1,291 @@
+package process
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/parser"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// minChangesForParallelApply is the number of changed files in commit starting from which diffs are applied in parallel. Small commits are faster sequentially.
+const minChangesForParallelApply = 8
+
+// changeResult is the result of applying a single file diff of a regular commit.
+type changeResult struct {
+	// path in res.Files
+	path  string
+	blame *incblame.Blame
+	// store is true if blame should also be stored in repo for use by next commits. False for removed files.
+	store bool
+	// deleted is the number of lines deleted by origin commit, only with Opts.TrackDeletions
+	deleted map[string]int
+	// prevLines are line numbers before the commit, only with Opts.TrackLineMapping
+	prevLines []int
+	// hunks are stats of diff hunks, only with Opts.TrackHunks
+	hunks []incblame.HunkStats
+	// patch is the diff of the file, only with Opts.TrackPatches
+	patch []byte
+	// quarantined is set when diff could not be parsed or applied and blame is marked as unknown
+	quarantined error
+	// skipped is true if diff was not applied because commit exceeded Opts.CommitDeadline
+	skipped bool
+
+	parseDur time.Duration
+	applyDur time.Duration
+	err      error
+}
+
+// applyRegularChanges parses and applies file diffs of a regular commit. File states are independent, so for wide commits diffs are applied in parallel using up to Opts.ApplyConcurrency goroutines.
+// Only reads r, results are returned in the same order as commit.Changes so that caller can update state deterministically.
+// Changes not started before Opts.CommitDeadline passed are skipped.
+func (s *Process) applyRegularChanges(r repo.Repo, commit parser.Commit) []changeResult {
+	res := make([]changeResult, len(commit.Changes))
+	deadline := s.commitDeadline()
+	apply := func(ch parser.Change) (res changeResult) {
+		defer func() {
+			if res.err != nil && res.path == "" {
+				res.path = changePath(ch)
+			}
+		}()
+		defer recoverChange(&res, commit)
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return s.skipChange(commit, ch)
+		}
+		return s.applyRegularChange(r, commit, ch)
+	}
+	concurrency := s.opts.ApplyConcurrency
+	if concurrency == 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if concurrency == 1 || len(commit.Changes) < minChangesForParallelApply {
+		for i, ch := range commit.Changes {
+			res[i] = apply(ch)
+		}
+		return res
+	}
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res[i] = apply(commit.Changes[i])
+			}
+		}()
+	}
+	for i := range commit.Changes {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return res
+}
+
+func (s *Process) applyRegularChange(r repo.Repo, commit parser.Commit, ch parser.Change) (res changeResult) {
+	//fmt.Printf("%+v\n", string(ch.Diff))
+	parseStart := time.Now()
+	diff, err := incblame.Parse(ch.Diff)
+	res.parseDur = time.Since(parseStart)
+	if s.opts.TrackPatches {
+		res.patch = ch.Diff
+	}
+	if err != nil {
+		path := diff.PathOrPrev()
+		if path == "" {
+			res.err = fmt.Errorf("could not parse diff, commit: %v err: %v", commit.Hash, err)
+			return
+		}
+		s.quarantine(&res, commit.Hash, path, err)
+		return
+	}
+
+	if s.opts.TrackHunks && !diff.IsBinary {
+		res.hunks = diff.HunkStats()
+	}
+
+	if diff.IsBinary {
+		// do not keep actual lines, but show in result
+		res.blame = incblame.BlameBinaryFile(commit.Hash)
+		if diff.Path == "" {
+			// removal
+			res.path = diff.PathPrev
+		} else {
+			res.path = diff.Path
+			res.store = true
+		}
+		return
+	}
+
+	//fmt.Printf("diff %+v\n", diff)
+	if diff.Path == "" {
+		// file removed, no longer need to keep blame reference, but showcase the file in res.Files using PathPrev
+		res.path = diff.PathPrev
+		res.blame = &incblame.Blame{Commit: commit.Hash}
+		if len(commit.Parents) == 1 && (s.opts.TrackDeletions || s.opts.TrackHunks) {
+			parentBlame := r.GetFileOptional(commit.Parents[0], diff.PathPrev)
+			if s.opts.TrackDeletions {
+				res.deleted = deletedLines(parentBlame, res.blame)
+			}
+			if s.opts.TrackHunks {
+				res.hunks = removedHunks(parentBlame)
+			}
+		}
+		return
+	}
+
+	// TODO: test renames here as well
+
+	// this is a rename
+	if diff.PathPrev != "" && diff.PathPrev != diff.Path {
+		if len(commit.Parents) != 1 {
+			res.err = fmt.Errorf("rename with more than 1 parent (merge) not supported: %v", commit.Hash)
+			return
+		}
+		// rename with no patch
+		if len(diff.Hunks) == 0 {
+			parent := commit.Parents[0]
+			pb, err := r.GetFileMust(parent, diff.PathPrev)
+			if err != nil {
+				res.err = fmt.Errorf("could not get parent file for rename: %v err: %v", commit.Hash, err)
+				return
+			}
+			if pb.IsBinary || pb.IsUnknown {
+				res.path = diff.Path
+				res.blame = pb
+				res.store = true
+				return
+			}
+		}
+
+	} else {
+		// this is an empty file creation
+		//if len(diff.Hunks) == 0 {
+		//	panic(fmt.Errorf("no changes in commit: %v diff: %v", commit.Hash, string(ch.Diff)))
+		//}
+	}
+
+	var parentBlame *incblame.Blame
+
+	if diff.PathPrev == "" {
+		// file added in this commit, no parent blame for this file
+	} else {
+		switch len(commit.Parents) {
+		case 0: // initial commit, no parent
+		case 1: // regular commit
+			parentHash := commit.Parents[0]
+			pb := r.GetFileOptional(parentHash, diff.PathPrev)
+			// file may not be in parent if this is create
+			if pb != nil {
+				parentBlame = pb
+			}
+		default: // merge
+			res.err = fmt.Errorf("merge passed to regular commit processing: %v", commit.Hash)
+			return
+
+		}
+	}
+
+	if parentBlame != nil && parentBlame.IsUnknown {
+		// quarantined in one of the previous commits, stays unknown until file is added again
+		res.path = diff.Path
+		res.blame = incblame.BlameUnknownFile(commit.Hash)
+		res.store = true
+		return
+	}
+
+	applyStart := time.Now()
+	var blame incblame.Blame
+	if parentBlame == nil {
+		blame, err = incblame.Apply(incblame.Blame{}, diff, commit.Hash, diff.PathOrPrev())
+	} else {
+		if parentBlame.IsBinary {
+			blame, err = s.slowGitBlame(commit.Hash, diff.Path)
+			if err != nil {
+				res.err = err
+				return
+			}
+		} else {
+			blame, err = incblame.Apply(*parentBlame, diff, commit.Hash, diff.PathOrPrev())
+		}
+	}
+	res.applyDur = time.Since(applyStart)
+	if err != nil {
+		s.quarantine(&res, commit.Hash, diff.Path, err)
+		return
+	}
+	res.path = diff.Path
+	res.blame = &blame
+	res.store = true
+	if s.opts.TrackDeletions {
+		res.deleted = deletedLines(parentBlame, res.blame)
+	}
+	if s.opts.TrackLineMapping {
+		res.prevLines = prevLines(parentBlame, res.blame)
+	}
+	return
+}
+
+// quarantine marks blame of the file as unknown from this commit forward, so that a diff which could not be parsed or applied does not stop processing of the whole repo.
+// Next changes to the file are not applied until it is added again.
+func (s *Process) quarantine(res *changeResult, commit string, path string, err error) {
+	s.opts.Logger.Warn("could not apply diff, marking file blame as unknown", "commit", commit, "file", path, "err", err)
+	res.path = path
+	res.blame = incblame.BlameUnknownFile(commit)
+	res.store = true
+	res.quarantined = err
+}
+
+// prevLines returns the 1-based line number in parent blame of each line of the new blame, 0 for added lines. Apply copies unchanged lines from the parent, so they are found by identity. Returns nil if there is no parent.
+func prevLines(parent *incblame.Blame, blame *incblame.Blame) []int {
+	if parent == nil || parent.IsBinary || parent.IsUnknown || len(blame.Lines) == 0 {
+		return nil
+	}
+	pos := make(map[*incblame.Line]int, len(parent.Lines))
+	for i, l := range parent.Lines {
+		pos[l] = i + 1
+	}
+	res := make([]int, len(blame.Lines))
+	for i, l := range blame.Lines {
+		res[i] = pos[l]
+	}
+	return res
+}
+
+// deletedLines returns the number of lines by origin commit that are in parent blame, but not in the new one. Returns nil if nothing was deleted.
+func deletedLines(parent *incblame.Blame, blame *incblame.Blame) map[string]int {
+	if parent == nil || parent.IsBinary || len(parent.Lines) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, l := range parent.Lines {
+		counts[l.Commit]++
+	}
+	for _, l := range blame.Lines {
+		counts[l.Commit]--
+	}
+	var res map[string]int
+	for c, n := range counts {
+		if n <= 0 {
+			continue
+		}
+		if res == nil {
+			res = map[string]int{}
+		}
+		res[c] = n
+	}
+	return res
+}
+
+// removedHunks returns the hunk of a removed file. Parse drops hunks of removed files, so it is created from parent blame instead.
+func removedHunks(parent *incblame.Blame) []incblame.HunkStats {
+	if parent == nil || parent.IsBinary || parent.IsUnknown || len(parent.Lines) == 0 {
+		return nil
+	}
+	n := len(parent.Lines)
+	return []incblame.HunkStats{{OldStart: 1, OldLines: n, Deleted: n}}
+}
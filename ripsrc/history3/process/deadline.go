@@ -0,0 +1,51 @@
+package process
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/parser"
+)
+
+// ErrCommitDeadline is set in Result.Quarantined for files which diffs were skipped because the commit exceeded Opts.CommitDeadline.
+var ErrCommitDeadline = errors.New("commit processing deadline exceeded")
+
+// commitDeadline returns the time after which remaining changes of the commit starting now are skipped. Zero if Opts.CommitDeadline is not set.
+func (s *Process) commitDeadline() time.Time {
+	if s.opts.CommitDeadline == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(s.opts.CommitDeadline)
+}
+
+// skipChange returns the result for file change without applying the diff. Only the diff header is parsed to get the paths.
+// Removed files stay removed, other files are marked as unknown, the same as quarantined files, until they are added again.
+func (s *Process) skipChange(commit parser.Commit, ch parser.Change) (res changeResult) {
+	diff, err := incblame.Parse(diffHeader(ch.Diff))
+	if err != nil && diff.PathOrPrev() == "" {
+		res.err = fmt.Errorf("could not parse diff, commit: %v err: %v", commit.Hash, err)
+		return
+	}
+	res.skipped = true
+	if err == nil && diff.Path == "" {
+		res.path = diff.PathPrev
+		res.blame = &incblame.Blame{Commit: commit.Hash}
+		return
+	}
+	res.path = diff.PathOrPrev()
+	res.blame = incblame.BlameUnknownFile(commit.Hash)
+	res.store = true
+	res.quarantined = ErrCommitDeadline
+	return
+}
+
+// diffHeader returns the part of the diff before the first hunk.
+func diffHeader(diff []byte) []byte {
+	if i := bytes.Index(diff, []byte("\n@@")); i != -1 {
+		return diff[:i+1]
+	}
+	return diff
+}
@@ -0,0 +1,109 @@
+package process
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/parser"
+)
+
+// FailedFile is a file of a commit that could not be processed. Path is empty if the error is not specific to a file.
+type FailedFile struct {
+	Commit string
+	Path   string
+	Err    error
+}
+
+func (s FailedFile) String() string {
+	if s.Path == "" {
+		return fmt.Sprintf("commit: %v err: %v", s.Commit, s.Err)
+	}
+	return fmt.Sprintf("commit: %v file: %v err: %v", s.Commit, s.Path, s.Err)
+}
+
+// ApplyError is returned when commits could not be processed. Lists all failed files, not only the first one.
+// Files of a commit are applied in parallel and commits of independent segments are processed in parallel, so multiple files and commits could fail before processing stops.
+type ApplyError struct {
+	Files []FailedFile
+}
+
+func (s *ApplyError) Error() string {
+	var msgs []string
+	for _, f := range s.Files {
+		msgs = append(msgs, f.String())
+	}
+	return fmt.Sprintf("could not process %v files in %v commits: %v", len(s.Files), len(s.Commits()), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of the first failed file.
+func (s *ApplyError) Unwrap() error {
+	if len(s.Files) == 0 {
+		return nil
+	}
+	return s.Files[0].Err
+}
+
+// Commits returns hashes of failed commits, in the order of Files.
+func (s *ApplyError) Commits() (res []string) {
+	seen := map[string]bool{}
+	for _, f := range s.Files {
+		if seen[f.Commit] {
+			continue
+		}
+		seen[f.Commit] = true
+		res = append(res, f.Commit)
+	}
+	return
+}
+
+// newApplyError returns err of commit as ApplyError.
+func newApplyError(commit string, err error) *ApplyError {
+	res := &ApplyError{}
+	res.add(commit, err)
+	return res
+}
+
+// add appends err of commit. Files of ApplyError are added as is, other errors are added as not specific to a file.
+func (s *ApplyError) add(commit string, err error) {
+	if err2, ok := err.(*ApplyError); ok {
+		s.Files = append(s.Files, err2.Files...)
+		return
+	}
+	s.Files = append(s.Files, FailedFile{Commit: commit, Err: err})
+}
+
+// errOrNil returns nil if there are no failed files, so that typed nil is not returned as error.
+func (s *ApplyError) errOrNil() error {
+	if len(s.Files) == 0 {
+		return nil
+	}
+	return s
+}
+
+// recoverChange converts a panic when applying a single diff to error, so that it does not crash the process from a worker goroutine.
+func recoverChange(res *changeResult, commit parser.Commit) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*res = changeResult{}
+	res.err = fmt.Errorf("panic applying diff, commit: %v err: %v\n%s", commit.Hash, r, debug.Stack())
+}
+
+// recoverSegmentTask converts a panic when processing a commit in segment task to task error, so that it is reported by commitDone instead of crashing the process.
+func recoverSegmentTask(t *segmentTask) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	t.res = Result{}
+	t.err = fmt.Errorf("panic processing commit: %v err: %v\n%s", t.hash, r, debug.Stack())
+}
+
+// changePath returns the path of a file change from the diff header, used to report errors when diff could not be applied. Returns empty string if header could not be parsed.
+func changePath(ch parser.Change) string {
+	diff, _ := incblame.Parse(diffHeader(ch.Diff))
+	return diff.PathOrPrev()
+}
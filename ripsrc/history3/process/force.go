@@ -0,0 +1,63 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+const backupSuffix = ".backup"
+
+// runForceFull processes all commits from scratch, keeping the existing checkpoints as backup until the run succeeds.
+// If the run fails, the backup is restored.
+func (s *Process) runForceFull(ctx context.Context, resChan chan Result) error {
+	err := s.backupCheckpoints()
+	if err != nil {
+		close(resChan)
+		return fmt.Errorf("could not backup checkpoints: %v", err)
+	}
+	err = s.run(ctx, resChan)
+	if err != nil {
+		rerr := s.restoreBackup()
+		if rerr != nil {
+			s.opts.Logger.Error("could not restore checkpoints backup", "dir", s.checkpointsDir+backupSuffix, "err", rerr)
+		}
+		return err
+	}
+	return os.RemoveAll(s.checkpointsDir + backupSuffix)
+}
+
+func (s *Process) backupCheckpoints() error {
+	backup := s.checkpointsDir + backupSuffix
+	_, err := os.Stat(backup)
+	if err == nil {
+		// previous forced run did not finish, backup has the last good state
+		s.opts.Logger.Warn("checkpoints backup exists from unfinished previous run, keeping it", "dir", backup)
+		return os.RemoveAll(s.checkpointsDir)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(s.checkpointsDir, backup)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Process) restoreBackup() error {
+	backup := s.checkpointsDir + backupSuffix
+	_, err := os.Stat(backup)
+	if os.IsNotExist(err) {
+		// there were no checkpoints before the run
+		return os.RemoveAll(s.checkpointsDir)
+	}
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(s.checkpointsDir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(backup, s.checkpointsDir)
+}
@@ -1,12 +1,12 @@
 package gitblamecommit
 
 import (
+	"bytes"
 	"context"
-	"os"
-	"os/exec"
 	"runtime"
 	"strings"
 
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
 )
 
@@ -37,14 +37,12 @@ func listOfFiles(ctx context.Context, repoDir string, commitHash string) (res []
 		"-r",
 		commitHash,
 	}
-	cmd := exec.Command("git", args...)
-	cmd.Dir = repoDir
-	cmd.Stderr = os.Stderr
-	b, err := cmd.Output()
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, "git", repoDir, args)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	for _, l := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
 		res = append(res, l)
 	}
 	return res, nil
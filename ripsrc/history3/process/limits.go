@@ -0,0 +1,76 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// LimitReason is the limit that stopped processing early.
+type LimitReason string
+
+const (
+	// LimitDuration means processing took longer than Opts.MaxDuration.
+	LimitDuration = LimitReason("duration")
+	// LimitMemory means heap allocation exceeded Opts.MaxMemoryBytes.
+	LimitMemory = LimitReason("memory")
+)
+
+// ErrLimitReached is returned, wrapped in *PartialError, when processing stopped because of MaxDuration or MaxMemoryBytes.
+var ErrLimitReached = errors.New("processing limit reached")
+
+// PartialError is returned from Run when processing stopped early at a commit boundary because of MaxDuration or MaxMemoryBytes.
+// All commits up to LastCommit were returned and intermediate checkpoint was written for them. Run again with ResumeInterrupted and the same options to continue.
+type PartialError struct {
+	Reason LimitReason
+	// LastCommit is the last processed commit.
+	LastCommit string
+	// Commits is the number of commits processed in this run before stopping.
+	Commits int
+}
+
+func (s *PartialError) Error() string {
+	return fmt.Sprintf("%v: %v, stopped after %v commits, last commit: %v", ErrLimitReached, s.Reason, s.Commits, s.LastCommit)
+}
+
+func (s *PartialError) Unwrap() error {
+	return ErrLimitReached
+}
+
+// memoryCheckInterval limits how often heap size is checked, since runtime.ReadMemStats stops the world.
+const memoryCheckInterval = 100 * time.Millisecond
+
+// limitReached returns the exceeded limit or empty string. Only called when there are no pending merge parts.
+func (s *Process) limitReached() LimitReason {
+	if s.opts.MaxDuration != 0 && time.Since(s.started) >= s.opts.MaxDuration {
+		return LimitDuration
+	}
+	if s.opts.MaxMemoryBytes != 0 && time.Since(s.memoryChecked) >= memoryCheckInterval {
+		s.memoryChecked = time.Now()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc >= s.opts.MaxMemoryBytes {
+			return LimitMemory
+		}
+	}
+	return ""
+}
+
+// stopAtLimit writes intermediate checkpoint after entries and returns *PartialError if a limit is reached. Returns nil otherwise.
+func (s *Process) stopAtLimit(entries int) error {
+	reason := s.limitReached()
+	if reason == "" {
+		return nil
+	}
+	err := s.writePartial(entries)
+	if err != nil {
+		return err
+	}
+	s.opts.Logger.Warn("stopping processing, limit reached", "reason", reason, "commit", s.lastProcessedCommitHash, "commits", s.commitsProcessed)
+	return &PartialError{
+		Reason:     reason,
+		LastCommit: s.lastProcessedCommitHash,
+		Commits:    s.commitsProcessed,
+	}
+}
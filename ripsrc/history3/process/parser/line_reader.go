@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// lineReader reads lines of any length, unlike bufio.Scanner which fails on lines longer than its buffer. Long lines are read in chunks of the buffer size.
+type lineReader struct {
+	r   *bufio.Reader
+	max int
+	// long holds the current line when it does not fit into the buffer
+	long []byte
+}
+
+func newLineReader(r io.Reader, max int) *lineReader {
+	s := &lineReader{}
+	s.r = bufio.NewReaderSize(r, 64*1024)
+	s.max = max
+	return s
+}
+
+// Line returns the next line without the line ending. The returned slice is only valid until the next call. Returns io.EOF after the last line.
+func (s *lineReader) Line() ([]byte, error) {
+	s.long = s.long[:0]
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			s.long = append(s.long, chunk...)
+			if s.max > 0 && len(s.long) > s.max {
+				return nil, fmt.Errorf("%w: exceeds limit of %d bytes", ErrLineTooLong, s.max)
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line := chunk
+		if len(s.long) != 0 {
+			s.long = append(s.long, chunk...)
+			line = s.long
+		}
+		if err == io.EOF && len(line) == 0 {
+			return nil, io.EOF
+		}
+		line = dropLineEnding(line)
+		if s.max > 0 && len(line) > s.max {
+			return nil, fmt.Errorf("%w: exceeds limit of %d bytes", ErrLineTooLong, s.max)
+		}
+		return line, nil
+	}
+}
+
+// dropLineEnding removes \n or \r\n, same as bufio.ScanLines.
+func dropLineEnding(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		b = b[:len(b)-1]
+	}
+	if len(b) > 0 && b[len(b)-1] == '\r' {
+		b = b[:len(b)-1]
+	}
+	return b
+}
@@ -1,22 +1,26 @@
 package parser
 
 import (
-	"bufio"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 )
 
 type Parser struct {
-	r io.Reader
+	r    io.Reader
+	opts Opts
 
 	res    chan Commit
 	commit Commit
 	change Change
 
 	state state
-	diff  []byte
+
+	// buf holds diffs of the current commit, changes reference parts of it to avoid allocation for each diff
+	buf       []byte
+	diffStart int
 
 	msgEmptyLines int
 }
@@ -56,30 +60,50 @@ func (c Change) String() string {
 	return string(c.Diff)
 }
 
+type Opts struct {
+	// MaxLine is the max length of a line in bytes, longer lines return ErrLineTooLong. Default is DefaultMaxLine, negative for no limit.
+	MaxLine int
+}
+
+const mb = 1000 * 1000
+
+// DefaultMaxLine is the default Opts.MaxLine.
+const DefaultMaxLine = 100 * mb
+
+// ErrLineTooLong is returned from Run when input has a line longer than Opts.MaxLine.
+var ErrLineTooLong = errors.New("line too long")
+
 func New(r io.Reader) *Parser {
+	return NewWithOpts(r, Opts{})
+}
+
+func NewWithOpts(r io.Reader, opts Opts) *Parser {
+	if opts.MaxLine == 0 {
+		opts.MaxLine = DefaultMaxLine
+	}
 	p := &Parser{}
 	p.r = r
+	p.opts = opts
 	return p
 }
 
-const mb = 1000 * 1000
-const maxLine = 100 * mb
-
 func (s *Parser) Run(res chan Commit) error {
 	defer close(res)
 
 	s.res = res
 	s.state = stNotStarted
 
-	scanner := bufio.NewScanner(s.r)
-	scanner.Buffer(nil, maxLine)
-	for scanner.Scan() {
-		line := scanner.Bytes()
+	rd := newLineReader(s.r, s.opts.MaxLine)
+	for {
+		line, err := rd.Line()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 		s.line(line)
 	}
-	if err := scanner.Err(); err != nil {
-		return err
-	}
 
 	// handle empty log output
 	if s.state != stNotStarted {
@@ -125,8 +149,8 @@ func (s *Parser) line(b []byte) {
 			s.endDiff()
 			s.startDiff(b)
 		} else {
-			s.diff = append(s.diff, b...)
-			s.diff = append(s.diff, '\n')
+			s.buf = append(s.buf, b...)
+			s.buf = append(s.buf, '\n')
 		}
 	case stCommitNext:
 		s.parseCommitLine(b)
@@ -137,14 +161,14 @@ func (s *Parser) line(b []byte) {
 
 func (s *Parser) startDiff(b []byte) {
 	s.state = stInDiff
-	s.diff = []byte{}
-	s.diff = append(s.diff, b...)
-	s.diff = append(s.diff, '\n')
+	s.diffStart = len(s.buf)
+	s.buf = append(s.buf, b...)
+	s.buf = append(s.buf, '\n')
 }
 
 func (s *Parser) endDiff() {
 	c := Change{}
-	c.Diff = s.diff
+	c.Diff = s.buf[s.diffStart:len(s.buf):len(s.buf)]
 	s.commit.Changes = append(s.commit.Changes, c)
 }
 
@@ -180,6 +204,7 @@ func (s *Parser) parseCommitLine(b []byte) {
 	}
 	s.commit = c
 	s.state = stParentsNext
+	s.buf = nil
 }
 
 const mergePrefix = "Merge: "
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"errors"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -277,3 +279,51 @@ index 7898192..e61ef7b 100644
 func tb(s string) []byte {
 	return []byte(s)
 }
+
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	data := `commit e99cb00954f08c1d33c5935742809868335483bf
+Author: User1 <user1@example.com>
+
+    c1
+
+diff --git a/a.txt b/a.txt
+new file mode 100644
+index 0000000..7898192
+--- /dev/null
++++ b/a.txt
+@@ -0,0 +1 @@
++` + long + `
+`
+	res, err := NewWithOpts(strings.NewReader(data), Opts{MaxLine: -1}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Changes) != 1 || !strings.Contains(string(res[0].Changes[0].Diff), "+"+long+"\n") {
+		t.Fatalf("long line was not parsed, got %v", res)
+	}
+
+	_, err = NewWithOpts(strings.NewReader(data), Opts{MaxLine: 100 * 1024}).RunGetAll()
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("wanted ErrLineTooLong, got %v", err)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	rd := newLineReader(strings.NewReader("a\r\n\nb"), 0)
+	var got []string
+	for {
+		line, err := rd.Line()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(line))
+	}
+	want := []string{"a", "", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted %q got %q", want, got)
+	}
+}
@@ -0,0 +1,121 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+)
+
+// Intermediate checkpoints are written to a separate dir, so the checkpoint of the previous complete run stays valid if process crashes.
+// They store the state after the first Commits log entries of the run, which allows resuming the same run by skipping these entries.
+
+const partialDirName = "partial"
+
+const partialProgressFile = "progress.json"
+
+type partialProgress struct {
+	// CommitFromIncl and Refs of the interrupted run. Resume is only possible with the same log range.
+	CommitFromIncl string
+	Refs           []string
+	AllBranches    bool
+	// Entries is the number of git log entries processed. Merges have one entry per parent.
+	Entries int
+	// LastCommit is the last processed commit, used to check that log returns the same commits on resume.
+	LastCommit string
+}
+
+func (s *Process) partialDir() string {
+	return filepath.Join(s.checkpointsDir, partialDirName)
+}
+
+func (s *Process) newPartialProgress(entries int) partialProgress {
+	return partialProgress{
+		CommitFromIncl: s.opts.CommitFromIncl,
+		Refs:           s.opts.Refs,
+		AllBranches:    s.opts.AllBranches,
+		Entries:        entries,
+		LastCommit:     s.lastProcessedCommitHash,
+	}
+}
+
+func (s partialProgress) sameRun(b partialProgress) bool {
+	return s.CommitFromIncl == b.CommitFromIncl && fmt.Sprint(s.Refs) == fmt.Sprint(b.Refs) && s.AllBranches == b.AllBranches
+}
+
+// shouldWritePartial returns true if intermediate checkpoint is due. Only called when there are no pending merge parts.
+func (s *Process) shouldWritePartial() bool {
+	if s.opts.CheckpointEvery != 0 && s.partialCommits >= s.opts.CheckpointEvery {
+		return true
+	}
+	if s.opts.CheckpointInterval != 0 && time.Since(s.partialWritten) >= s.opts.CheckpointInterval {
+		return true
+	}
+	return false
+}
+
+func (s *Process) writePartial(entries int) error {
+	start := time.Now()
+	writer := s.newCheckpointWriter()
+	dir := s.partialDir()
+	err := writer.Write(s.repo, dir, s.lastProcessedCommitHash)
+	if err != nil {
+		return fmt.Errorf("could not write intermediate checkpoint: %v", err)
+	}
+	data, err := json.Marshal(s.newPartialProgress(entries))
+	if err != nil {
+		return err
+	}
+	loc := filepath.Join(dir, partialProgressFile)
+	err = ioutil.WriteFile(loc+".tmp", data, 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(loc+".tmp", loc)
+	if err != nil {
+		return err
+	}
+	s.partialCommits = 0
+	s.partialWritten = time.Now()
+	s.opts.Metrics.Duration(metrics.StageDuration, time.Since(start), "stage", metrics.StageCheckpointWrite)
+	s.opts.Logger.Info("wrote intermediate checkpoint", "commit", s.lastProcessedCommitHash, "entries", entries)
+	return nil
+}
+
+// readPartial loads intermediate checkpoint of interrupted run with the same options. Returns the number of log entries to skip, 0 if there is nothing to resume.
+func (s *Process) readPartial() (skip int, lastCommit string, _ error) {
+	dir := s.partialDir()
+	data, err := ioutil.ReadFile(filepath.Join(dir, partialProgressFile))
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	var progress partialProgress
+	err = json.Unmarshal(data, &progress)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not parse intermediate checkpoint progress: %v", err)
+	}
+	if !progress.sameRun(s.newPartialProgress(0)) {
+		s.opts.Logger.Warn("ignoring intermediate checkpoint created with different options", "commit_from", progress.CommitFromIncl)
+		return 0, "", nil
+	}
+	reader := repo.NewCheckpointReader(s.opts.Logger)
+	r, err := reader.Read(dir, progress.LastCommit)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not read intermediate checkpoint: %v", err)
+	}
+	s.repo = r
+	s.opts.Logger.Info("resuming from intermediate checkpoint", "commit", progress.LastCommit, "entries", progress.Entries)
+	return progress.Entries, progress.LastCommit, nil
+}
+
+func (s *Process) removePartial() error {
+	return os.RemoveAll(s.partialDir())
+}
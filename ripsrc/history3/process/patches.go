@@ -0,0 +1,61 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/parser"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// Patch is a change that does not exist in the local repo, for example pull request commit retrieved from api.
+type Patch struct {
+	// ID is used instead of commit hash in results and as the commit of lines added by this patch. Must be unique.
+	ID string
+
+	// Diff is unified diff in git format (output of git diff or git format-patch). Could contain changes to multiple files.
+	Diff []byte
+}
+
+// RunPatches applies patches on top of checkpointed blame state and returns blame results for each patch.
+// First patch is applied on baseCommit, each next patch on the result of the previous one.
+// Checkpoint has to be created by previous Run and contain baseCommit in recently processed commits. Checkpoint is not modified.
+func (s *Process) RunPatches(baseCommit string, patches []Patch) (res []Result, _ error) {
+	if baseCommit == "" {
+		return nil, errors.New("baseCommit is required when applying patches")
+	}
+
+	reader := repo.NewCheckpointReader(s.opts.Logger)
+	r, err := reader.Read(s.checkpointsDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("Could not read checkpoint: %v", err)
+	}
+	if _, ok := r[baseCommit]; !ok {
+		return nil, fmt.Errorf("base commit for patches not found in checkpoint: %v", baseCommit)
+	}
+	s.repo = r
+
+	parent := baseCommit
+	for _, p := range patches {
+		if p.ID == "" {
+			return nil, errors.New("patch ID is required")
+		}
+		if _, ok := s.repo[p.ID]; ok {
+			return nil, fmt.Errorf("patch ID is not unique or same as existing commit: %v", p.ID)
+		}
+		commit := parser.Commit{}
+		commit.Hash = p.ID
+		commit.Parents = []string{parent}
+		for _, diff := range incblame.SplitDiff(p.Diff) {
+			commit.Changes = append(commit.Changes, parser.Change{Diff: diff})
+		}
+		r, err := s.processRegularCommit(s.repo, commit)
+		if err != nil {
+			return nil, newApplyError(p.ID, err)
+		}
+		res = append(res, r)
+		parent = p.ID
+	}
+	return
+}
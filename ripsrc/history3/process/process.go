@@ -2,18 +2,22 @@ package process
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
 
 	"github.com/pinpt/ripsrc/ripsrc/gitblame2"
 	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
 
 	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
 
@@ -43,6 +47,25 @@ type Process struct {
 	checkpointsDir string
 
 	lastProcessedCommitHash string
+
+	// prevTips are tips saved in the checkpoint of CommitFromIncl, tips are updated with commits of this run and saved in the new checkpoint, see repo.CheckpointWriter.Tips
+	prevTips []string
+	tips     map[string]bool
+
+	ctx   context.Context
+	batch traceBatch
+
+	// mu protects timing updated from commits processed in parallel
+	mu sync.Mutex
+
+	// commits processed and time of the last intermediate checkpoint
+	partialCommits int
+	partialWritten time.Time
+
+	// start of the run, commits processed in it and time of the last memory check, used for MaxDuration and MaxMemoryBytes
+	started          time.Time
+	commitsProcessed int
+	memoryChecked    time.Time
 }
 
 type Opts struct {
@@ -71,13 +94,94 @@ type Opts struct {
 	// WantedBranchRefs filter branches.  When CommitFromIncl and AllBranches is set this is required.
 	WantedBranchRefs []string
 
+	// Refs is a list of branches, tags or shas. If set, processes commits reachable from these refs only. Takes precedence over AllBranches.
+	Refs []string
+
 	// ParentsGraph is optional graph of commits. Pass to reuse, if not passed will be created.
 	ParentsGraph *parentsgraph.Graph
+
+	// Metrics receives pipeline metrics. Optional.
+	Metrics metrics.Sink
+
+	// Tracer creates spans for commit graph, checkpoint io and batches of processed commits. Optional.
+	Tracer tracing.Tracer
+
+	// CheckpointEvery writes intermediate checkpoint every N processed commits. 0 disables.
+	CheckpointEvery int
+
+	// CheckpointInterval writes intermediate checkpoint if at least this time passed since the previous one. 0 disables.
+	CheckpointInterval time.Duration
+
+	// SharedCheckpointsDir is the directory for checkpoints shared between repos, for example forks of the same upstream. Checkpoints are stored there by commit hash after each run.
+	// When CommitFromIncl is set and there is no checkpoint for it in CheckpointsDir, a shared checkpoint for the same commit is used instead.
+	SharedCheckpointsDir string
+
+	// CheckpointCompression is the compression used when writing checkpoints. Default is repo.CompressionGzip. Checkpoints are read using any registered compression.
+	CheckpointCompression repo.Compression
+
+	// ApplyConcurrency is the max number of goroutines applying file diffs of a single commit. Default is the number of CPUs. Set to 1 to disable parallel apply.
+	ApplyConcurrency int
+
+	// SegmentConcurrency is the max number of commits processed in parallel. Commits on independent branches are processed concurrently, commits depending on each other are still processed in order.
+	// Results are returned in the same order as with sequential processing. Default is 0, which processes all commits sequentially.
+	SegmentConcurrency int
+
+	// ForceFullReprocess ignores CommitFromIncl and existing checkpoints and processes all commits from scratch. Existing checkpoints are kept as backup and restored if processing fails.
+	ForceFullReprocess bool
+
+	// ResumeInterrupted continues from intermediate checkpoint left by interrupted run with the same CommitFromIncl, AllBranches and Refs.
+	// Commits processed before the intermediate checkpoint are not returned again.
+	ResumeInterrupted bool
+
+	// TrackDeletions fills Result.Deleted with lines deleted or rewritten by each regular commit.
+	TrackDeletions bool
+
+	// TrackLineMapping fills Result.PrevLines with line numbers that lines had before each regular commit.
+	TrackLineMapping bool
+
+	// TrackHunks fills Result.Hunks with hunks of each file changed by regular commits.
+	TrackHunks bool
+
+	// TrackPatches fills Result.Patches with diffs of each file changed by regular commits.
+	TrackPatches bool
+
+	// MaxLine is the max length in bytes of a line in git log output, for example a changed line of minified file. Longer lines fail processing.
+	// Default is parser.DefaultMaxLine, negative for no limit.
+	MaxLine int
+
+	// MaxDuration stops processing at the next commit boundary after this time passed since the start of the run. 0 disables.
+	// Intermediate checkpoint is written and Run returns *PartialError, continue using ResumeInterrupted.
+	MaxDuration time.Duration
+
+	// MaxMemoryBytes stops processing at the next commit boundary when heap allocated by the process is over this size. 0 disables.
+	// Heap is shared by everything running in the process, so this is only a per-repo limit when repos are processed one at a time.
+	MaxMemoryBytes uint64
+
+	// CommitDeadline is the time budget for applying file diffs of a single regular commit. Files not started within the budget are skipped and their blame is unknown until they are added again, the same as quarantined files. Result.DeadlineExceeded is set for such commits.
+	// Protects the run from pathological commits, for example vendoring of a huge number of files. A single file diff is not interrupted. 0 disables.
+	CommitDeadline time.Duration
+
+	// AuthorDateOrder set to true to process and return commits in author date order. By default commits are in committer date order. In both cases parents are returned before children.
+	AuthorDateOrder bool
 }
 
 type Result struct {
 	Commit string
 	Files  map[string]*incblame.Blame
+	// Deleted is the number of lines removed from each file by this commit, by the commit that added them, map[file]map[origin_commit]lines.
+	// Modified lines count as deleted and added. Only set with Opts.TrackDeletions, not set for merge commits.
+	Deleted map[string]map[string]int
+	// PrevLines maps lines of each file after this commit to line numbers in the parent version, map[file][]prev_line. Index is 0-based line after the commit, value is 1-based line number in the parent, 0 for lines added or modified by the commit.
+	// For renamed files line numbers are in the file before the rename. Only set with Opts.TrackLineMapping, not set for merge commits, added, removed, binary and unknown files.
+	PrevLines map[string][]int
+	// Hunks are ranges and changed line counts of each hunk of the file diff, map[file][]hunk. Only set with Opts.TrackHunks, not set for merge commits and binary files.
+	Hunks map[string][]incblame.HunkStats
+	// Patches are unified diffs of each file in git format, as in git log -p, map[file]diff. Only set with Opts.TrackPatches, not set for merge commits.
+	Patches map[string][]byte
+	// Quarantined has the error for each file which diff could not be parsed or applied in this commit. Blame of these files is unknown (incblame.Blame.IsUnknown) from this commit until the file is added again.
+	Quarantined map[string]error
+	// DeadlineExceeded is true if some file diffs of the commit were skipped because of Opts.CommitDeadline. These files are in Quarantined with ErrCommitDeadline.
+	DeadlineExceeded bool
 }
 
 func New(opts Opts) *Process {
@@ -86,6 +190,17 @@ func New(opts Opts) *Process {
 	if opts.Logger == nil {
 		opts.Logger = logger.NewDefaultLogger(os.Stdout)
 	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.Noop{}
+	}
+	if opts.Tracer == nil {
+		opts.Tracer = tracing.Noop{}
+	}
+	if opts.ForceFullReprocess {
+		opts.CommitFromIncl = ""
+		opts.CommitFromMakeNonIncl = false
+		opts.ResumeInterrupted = false
+	}
 	s.opts = opts
 	s.gitCommand = "git"
 
@@ -94,7 +209,12 @@ func New(opts Opts) *Process {
 	if opts.CheckpointsDir != "" {
 		s.checkpointsDir = filepath.Join(opts.CheckpointsDir, "pp-git-cache")
 	} else {
-		s.checkpointsDir = filepath.Join(opts.RepoDir, "pp-git-cache")
+		// linked worktrees share checkpoints with the main worktree, since they share commits
+		repoDir, err := gitexec.MainRepoDir(opts.RepoDir)
+		if err != nil {
+			repoDir = opts.RepoDir
+		}
+		s.checkpointsDir = filepath.Join(repoDir, "pp-git-cache")
 	}
 
 	return s
@@ -104,6 +224,65 @@ func (s *Process) Timing() Timing {
 	return *s.timing
 }
 
+func (s *Process) newCheckpointWriter() *repo.CheckpointWriter {
+	res := repo.NewCheckpointWriter(s.opts.Logger)
+	if s.opts.CheckpointCompression != nil {
+		res.Compression = s.opts.CheckpointCompression
+	}
+	return res
+}
+
+// RecompressCheckpoints rewrites existing checkpoints using CheckpointCompression.
+func (s *Process) RecompressCheckpoints() error {
+	c := s.opts.CheckpointCompression
+	if c == nil {
+		c = repo.CompressionGzip
+	}
+	for _, dir := range []string{s.checkpointsDir, s.partialDir()} {
+		commit, err := repo.CheckpointCommit(dir)
+		if err != nil {
+			return err
+		}
+		if commit == "" {
+			continue
+		}
+		err = repo.Recompress(dir, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateCheckpoints upgrades existing checkpoints to the current format version.
+func (s *Process) MigrateCheckpoints() error {
+	for _, dir := range []string{s.checkpointsDir, s.partialDir()} {
+		from, err := repo.Migrate(dir)
+		if err != nil {
+			return err
+		}
+		if from != 0 && from != repo.FormatVersion {
+			s.opts.Logger.Info("migrated checkpoint", "dir", dir, "from", from, "to", repo.FormatVersion)
+		}
+	}
+	return nil
+}
+
+// CheckpointInfo returns information about saved checkpoint. Returns nil if there is no checkpoint.
+func (s *Process) CheckpointInfo() (*repo.Info, error) {
+	return repo.CheckpointInfo(s.checkpointsDir)
+}
+
+// Dir returns the directory where checkpoints for the repo are stored.
+func (s *Process) Dir() string {
+	return s.checkpointsDir
+}
+
+// CheckpointCommit returns the last commit included in saved checkpoint. Returns empty string if there is no checkpoint.
+func (s *Process) CheckpointCommit() (string, error) {
+	return repo.CheckpointCommit(s.checkpointsDir)
+}
+
 func (s *Process) initCheckpoints() error {
 
 	if s.opts.CommitFromIncl == "" {
@@ -115,12 +294,23 @@ func (s *Process) initCheckpoints() error {
 		} else {
 			expectedCommit = s.opts.CommitFromIncl
 		}
+		start := time.Now()
+		_, span := s.opts.Tracer.Start(s.ctx, tracing.SpanCheckpointRead)
+		defer span.End()
+		dir, err := s.checkpointReadDir()
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("Could not read checkpoint: %v", err)
+		}
 		reader := repo.NewCheckpointReader(s.opts.Logger)
-		r, err := reader.Read(s.checkpointsDir, expectedCommit)
+		r, err := reader.Read(dir, expectedCommit)
 		if err != nil {
+			span.RecordError(err)
 			return fmt.Errorf("Could not read checkpoint: %v", err)
 		}
 		s.repo = r
+		s.opts.Metrics.Duration(metrics.StageDuration, time.Since(start), "stage", metrics.StageCheckpointRead)
+		s.reportCheckpointSize(metrics.CheckpointReadBytes, dir)
 	}
 
 	s.unloader = repo.NewUnloader(s.repo)
@@ -128,26 +318,49 @@ func (s *Process) initCheckpoints() error {
 }
 
 func (s *Process) Run(resChan chan Result) error {
+	return s.RunContext(context.Background(), resChan)
+}
+
+// RunContext is the same as Run, but uses ctx as parent for tracing spans.
+func (s *Process) RunContext(ctx context.Context, resChan chan Result) error {
+	if s.opts.ForceFullReprocess {
+		return s.runForceFull(ctx, resChan)
+	}
+	return s.run(ctx, resChan)
+}
+
+func (s *Process) run(ctx context.Context, resChan chan Result) error {
 	defer func() {
 		close(resChan)
 	}()
+	s.ctx = ctx
 
 	if s.opts.ParentsGraph != nil {
 		s.graph = s.opts.ParentsGraph
 	} else {
+		_, span := s.opts.Tracer.Start(ctx, tracing.SpanCommitGraph)
 		s.graph = parentsgraph.New(parentsgraph.Opts{
 			RepoDir:     s.opts.RepoDir,
 			AllBranches: s.opts.AllBranches,
+			Refs:        s.opts.Refs,
 			Logger:      s.opts.Logger,
 		})
-		err := s.graph.Read()
+		err := s.graph.ReadContext(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.End()
 			return err
 		}
+		span.End()
 	}
 
 	s.childrenProcessed = map[string]int{}
 
+	err := s.initTips()
+	if err != nil {
+		return err
+	}
+
 	r, err := s.gitLogPatches()
 	if err != nil {
 		return err
@@ -156,18 +369,15 @@ func (s *Process) Run(resChan chan Result) error {
 	defer r.Close()
 
 	commits := make(chan parser.Commit)
-	p := parser.New(r)
+	p := parser.NewWithOpts(r, parser.Opts{MaxLine: s.opts.MaxLine})
 
+	// done is closed when parser exits, parseErr is safe to read after that
 	done := make(chan bool)
+	var parseErr error
 
 	go func() {
-		defer func() {
-			done <- true
-		}()
-		err := p.Run(commits)
-		if err != nil {
-			panic(err)
-		}
+		defer close(done)
+		parseErr = p.Run(commits)
 	}()
 
 	drainAndExit := func() {
@@ -176,45 +386,155 @@ func (s *Process) Run(resChan chan Result) error {
 		<-done
 	}
 
+	var segments *segmentScheduler
+	if s.opts.SegmentConcurrency > 1 {
+		segments = newSegmentScheduler(s, resChan)
+	}
+
+	s.partialWritten = time.Now()
+	s.started = time.Now()
+	skip := 0
+	skipLastCommit := ""
 	i := 0
 	for commit := range commits {
 		if i == 0 {
-			err := s.initCheckpoints()
+			if s.opts.ResumeInterrupted {
+				var err error
+				skip, skipLastCommit, err = s.readPartial()
+				if err != nil {
+					drainAndExit()
+					return err
+				}
+			}
+			if skip == 0 {
+				err := s.initCheckpoints()
+				if err != nil {
+					drainAndExit()
+					return err
+				}
+			} else {
+				s.unloader = repo.NewUnloader(s.repo)
+			}
+		}
+		i++
+		s.addTip(commit.Hash)
+		if i <= skip {
+			// already processed before interruption, only restore bookkeeping for unloading
+			if commit.Hash != s.lastProcessedCommitHash {
+				s.lastProcessedCommitHash = commit.Hash
+				s.trimGraphAfterCommitProcessed(commit.Hash)
+			}
+			if i == skip && commit.Hash != skipLastCommit {
+				drainAndExit()
+				return fmt.Errorf("can't resume from intermediate checkpoint, git log returned different commits, wanted %v at position %v got %v", skipLastCommit, skip, commit.Hash)
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			s.batch.End(err)
+			drainAndExit()
+			return err
+		}
+		s.batch.Commit(s, commit.Hash)
+		commit.Parents = s.graph.Parents[commit.Hash]
+		if segments != nil {
+			// commits are returned and intermediate checkpoints written by scheduler
+			err := segments.Add(commit, i)
 			if err != nil {
+				s.batch.End(err)
 				drainAndExit()
 				return err
 			}
+			continue
 		}
-		i++
-		commit.Parents = s.graph.Parents[commit.Hash]
 		err := s.processCommit(resChan, commit)
 		if err != nil {
+			s.batch.End(err)
 			drainAndExit()
 			return err
 		}
+		s.partialCommits++
+		if len(s.mergeParts) == 0 && s.shouldWritePartial() {
+			err := s.writePartial(i)
+			if err != nil {
+				s.batch.End(err)
+				drainAndExit()
+				return err
+			}
+		}
+		if len(s.mergeParts) == 0 {
+			err := s.stopAtLimit(i)
+			if err != nil {
+				s.batch.End(err)
+				drainAndExit()
+				return err
+			}
+		}
+	}
+
+	<-done
+	if parseErr != nil {
+		s.batch.End(parseErr)
+		return fmt.Errorf("could not parse git log output: %v", parseErr)
+	}
+
+	if i < skip {
+		return fmt.Errorf("can't resume from intermediate checkpoint, git log returned %v commits, but checkpoint was after %v", i, skip)
 	}
 
+	if segments != nil {
+		err := segments.Flush()
+		if err != nil {
+			s.batch.End(err)
+			return err
+		}
+	}
 	if len(s.mergeParts) > 0 {
-		s.processGotMergeParts(resChan)
+		err := s.processGotMergeParts(resChan)
+		if err != nil {
+			s.batch.End(err)
+			return err
+		}
 	}
+	s.batch.End(nil)
 
 	if i == 0 {
 		// there were no items in log, happens when last processed commit was in a branch that is no longer recent and is skipped in incremental
 		// no need to write checkpoints
-		<-done
 		return nil
 	}
 
-	writer := repo.NewCheckpointWriter(s.opts.Logger)
+	if err := ctx.Err(); err != nil {
+		// results of the last commits may not have been used, do not checkpoint them
+		return err
+	}
+	writeStart := time.Now()
+	_, writeSpan := s.opts.Tracer.Start(ctx, tracing.SpanCheckpointWrite)
+	writer := s.newCheckpointWriter()
+	writer.Tips = s.sortedTips()
 	err = writer.Write(s.repo, s.checkpointsDir, s.lastProcessedCommitHash)
 	if err != nil {
-		<-done
+		writeSpan.RecordError(err)
+		writeSpan.End()
+		return err
+	}
+	writeSpan.End()
+	s.opts.Metrics.Duration(metrics.StageDuration, time.Since(writeStart), "stage", metrics.StageCheckpointWrite)
+	s.reportCheckpointSize(metrics.CheckpointWriteBytes, s.checkpointsDir)
+
+	err = s.shareCheckpoint()
+	if err != nil {
+		return fmt.Errorf("could not write shared checkpoint: %v", err)
+	}
+
+	// complete checkpoint written, intermediate is no longer needed
+	err = s.removePartial()
+	if err != nil {
 		return err
 	}
 
 	//fmt.Println("max len of stored tree", s.maxLenOfStoredTree)
 	//fmt.Println("repo len", len(s.repo))
-	<-done
 	return nil
 }
 
@@ -233,6 +553,17 @@ func (s *Process) trimGraphAfterCommitProcessed(commit string) {
 	if commitsInMemory > s.maxLenOfStoredTree {
 		s.maxLenOfStoredTree = commitsInMemory
 	}
+	s.opts.Metrics.Gauge(metrics.BlameCommitsInMemory, float64(commitsInMemory))
+	s.opts.Metrics.Gauge(metrics.BlameMergePartsPending, float64(len(s.mergeParts)))
+}
+
+func (s *Process) reportCheckpointSize(metric string, dir string) {
+	size, err := repo.CheckpointSize(dir)
+	if err != nil {
+		s.opts.Logger.Warn("could not get checkpoint size for metrics", "err", err)
+		return
+	}
+	s.opts.Metrics.Counter(metric, float64(size))
 }
 
 func (s *Process) processCommit(resChan chan Result, commit parser.Commit) error {
@@ -244,7 +575,10 @@ func (s *Process) processCommit(resChan chan Result, commit parser.Commit) error
 			return nil
 		} else {
 			// finished
-			s.processGotMergeParts(resChan)
+			err := s.processGotMergeParts(resChan)
+			if err != nil {
+				return err
+			}
 			// new commit
 			// continue below
 		}
@@ -257,23 +591,44 @@ func (s *Process) processCommit(resChan chan Result, commit parser.Commit) error
 		return nil
 	}
 
-	res, err := s.processRegularCommit(commit)
+	s.lastProcessedCommitHash = commit.Hash
+	res, err := s.processRegularCommit(s.repo, commit)
 	if err != nil {
-		return err
+		return newApplyError(commit.Hash, err)
 	}
 	s.trimGraphAfterCommitProcessed(commit.Hash)
-	resChan <- res
-	return nil
+	s.commitsProcessed++
+	return s.send(resChan, res)
 }
 
-func (s *Process) processGotMergeParts(resChan chan Result) {
-	res, err := s.processMergeCommit(s.mergePartsCommit, s.mergeParts)
+func (s *Process) processGotMergeParts(resChan chan Result) error {
+	s.lastProcessedCommitHash = s.mergePartsCommit
+	res, err := s.processMergeCommit(s.repo, s.mergePartsCommit, s.mergeParts)
 	if err != nil {
-		panic(err)
+		return newApplyError(s.mergePartsCommit, err)
 	}
 	s.trimGraphAfterCommitProcessed(s.mergePartsCommit)
 	s.mergeParts = nil
-	resChan <- res
+	s.commitsProcessed++
+	return s.send(resChan, res)
+}
+
+// send returns result to the caller. Returns error if the run is canceled before the caller reads it, so that processing does not block on a caller that stopped reading.
+func (s *Process) send(resChan chan Result, res Result) error {
+	select {
+	case resChan <- res:
+		return nil
+	case <-s.context().Done():
+		return s.context().Err()
+	}
+}
+
+// context returns ctx of the run, or background context when commits are processed outside of Run, for example when applying patches.
+func (s *Process) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
 }
 
 type Timing struct {
@@ -282,6 +637,8 @@ type Timing struct {
 	MergesCount         int
 	MergesTime          time.Duration
 	SlowestCommits      []CommitWithDuration
+	// OctopusMergesCount is the number of merges with more than 2 parents, included in MergesCount.
+	OctopusMergesCount int
 }
 
 type CommitWithDuration struct {
@@ -326,6 +683,7 @@ func (s *Timing) OutputStats(wr io.Writer) {
 	fmt.Fprintln(wr, "time in regular commits", s.RegularCommitsTime)
 	fmt.Fprintln(wr, "merges", s.MergesCount)
 	fmt.Fprintln(wr, "time in merges commits", s.MergesTime)
+	fmt.Fprintln(wr, "octopus merges", s.OctopusMergesCount)
 	fmt.Fprintf(wr, "time in %v slowest commits %v\n", len(s.SlowestCommits), s.SlowestCommitsDur())
 	fmt.Fprintln(wr, "slowest commits")
 	for _, c := range s.SlowestCommits {
@@ -334,124 +692,86 @@ func (s *Timing) OutputStats(wr io.Writer) {
 
 }
 
-func (s *Process) processRegularCommit(commit parser.Commit) (res Result, rerr error) {
-	s.lastProcessedCommitHash = commit.Hash
+// processRegularCommit applies commit changes on top of parent state in r and stores the resulting file state for commit in r.
+func (s *Process) processRegularCommit(r repo.Repo, commit parser.Commit) (res Result, rerr error) {
 
 	start := time.Now()
 	defer func() {
 		dur := time.Since(start)
+		s.mu.Lock()
 		s.timing.UpdateSlowestCommitsWith(commit.Hash, dur)
 		s.timing.RegularCommitsTime += dur
 		s.timing.RegularCommitsCount++
+		s.mu.Unlock()
+		s.opts.Metrics.Duration(metrics.StageDuration, dur, "stage", metrics.StageRegularCommit)
 	}()
 
 	if len(commit.Parents) > 1 {
-		panic("not a regular commit")
+		rerr = fmt.Errorf("not a regular commit: %v", commit.Hash)
+		return
 	}
 	// note that commit exists (important for empty commits)
-	s.repo.AddCommit(commit.Hash)
+	r.AddCommit(commit.Hash)
 
 	//fmt.Println("processing regular commit", commit.Hash)
 	res.Commit = commit.Hash
 	res.Files = map[string]*incblame.Blame{}
 
-	for _, ch := range commit.Changes {
-
-		//fmt.Printf("%+v\n", string(ch.Diff))
-		diff := incblame.Parse(ch.Diff)
-
-		if diff.IsBinary {
-			// do not keep actual lines, but show in result
-			bl := incblame.BlameBinaryFile(commit.Hash)
-
-			if diff.Path == "" {
-				p := diff.PathPrev
-				res.Files[p] = bl
-				// removal
-			} else {
-				p := diff.Path
-				res.Files[p] = bl
-				s.repo[commit.Hash][p] = bl
-			}
-			continue
+	changes := s.applyRegularChanges(r, commit)
+	failed := &ApplyError{}
+	for _, ch := range changes {
+		if ch.err != nil {
+			failed.Files = append(failed.Files, FailedFile{Commit: commit.Hash, Path: ch.path, Err: ch.err})
 		}
-
-		//fmt.Printf("diff %+v\n", diff)
-		if diff.Path == "" {
-			// file removed, no longer need to keep blame reference, but showcase the file in res.Files using PathPrev
-			res.Files[diff.PathPrev] = &incblame.Blame{Commit: commit.Hash}
-			continue
+	}
+	if rerr = failed.errOrNil(); rerr != nil {
+		return
+	}
+	skipped := 0
+	for _, ch := range changes {
+		s.batch.AddDurations(ch.parseDur, ch.applyDur)
+		if ch.skipped {
+			skipped++
 		}
-
-		// TODO: test renames here as well
-
-		if diff.Path == "" {
-			panic(fmt.Errorf("commit diff does not specify Path: %v diff: %v", commit.Hash, string(ch.Diff)))
+		res.Files[ch.path] = ch.blame
+		if ch.quarantined != nil {
+			if res.Quarantined == nil {
+				res.Quarantined = map[string]error{}
+			}
+			res.Quarantined[ch.path] = ch.quarantined
 		}
-
-		// this is a rename
-		if diff.PathPrev != "" && diff.PathPrev != diff.Path {
-			if len(commit.Parents) != 1 {
-				panic(fmt.Errorf("rename with more than 1 parent (merge) not supported: %v diff: %v", commit.Hash, string(ch.Diff)))
+		if len(ch.deleted) != 0 {
+			if res.Deleted == nil {
+				res.Deleted = map[string]map[string]int{}
 			}
-			// rename with no patch
-			if len(diff.Hunks) == 0 {
-				parent := commit.Parents[0]
-				pb, err := s.repo.GetFileMust(parent, diff.PathPrev)
-				if err != nil {
-					rerr = fmt.Errorf("could not get parent file for rename: %v err: %v", commit.Hash, err)
-					return
-				}
-				if pb.IsBinary {
-					s.repo[commit.Hash][diff.Path] = pb
-					res.Files[diff.Path] = pb
-					continue
-				}
+			res.Deleted[ch.path] = ch.deleted
+		}
+		if ch.hunks != nil {
+			if res.Hunks == nil {
+				res.Hunks = map[string][]incblame.HunkStats{}
 			}
-
-		} else {
-			// this is an empty file creation
-			//if len(diff.Hunks) == 0 {
-			//	panic(fmt.Errorf("no changes in commit: %v diff: %v", commit.Hash, string(ch.Diff)))
-			//}
+			res.Hunks[ch.path] = ch.hunks
 		}
-
-		var parentBlame *incblame.Blame
-
-		if diff.PathPrev == "" {
-			// file added in this commit, no parent blame for this file
-		} else {
-			switch len(commit.Parents) {
-			case 0: // initial commit, no parent
-			case 1: // regular commit
-				parentHash := commit.Parents[0]
-				pb := s.repo.GetFileOptional(parentHash, diff.PathPrev)
-				// file may not be in parent if this is create
-				if pb != nil {
-					parentBlame = pb
-				}
-			case 2: // merge
-				panic("merge passed to regular commit processing")
-
+		if ch.patch != nil {
+			if res.Patches == nil {
+				res.Patches = map[string][]byte{}
 			}
+			res.Patches[ch.path] = ch.patch
 		}
-
-		var blame incblame.Blame
-		if parentBlame == nil {
-			blame = incblame.Apply(incblame.Blame{}, diff, commit.Hash, diff.PathOrPrev())
-		} else {
-			if parentBlame.IsBinary {
-				bl, err := s.slowGitBlame(commit.Hash, diff.Path)
-				if err != nil {
-					return res, err
-				}
-				blame = bl
-			} else {
-				blame = incblame.Apply(*parentBlame, diff, commit.Hash, diff.PathOrPrev())
+		if ch.prevLines != nil {
+			if res.PrevLines == nil {
+				res.PrevLines = map[string][]int{}
 			}
+			res.PrevLines[ch.path] = ch.prevLines
 		}
-		s.repo[commit.Hash][diff.Path] = &blame
-		res.Files[diff.Path] = &blame
+		if ch.store {
+			r[commit.Hash][ch.path] = ch.blame
+		}
+	}
+
+	if skipped != 0 {
+		res.DeadlineExceeded = true
+		s.opts.Logger.Warn("commit exceeded deadline, skipped remaining file diffs", "commit", commit.Hash, "skipped", skipped, "files", len(changes), "deadline", s.opts.CommitDeadline)
 	}
 
 	if len(commit.Parents) == 0 {
@@ -461,19 +781,19 @@ func (s *Process) processRegularCommit(commit parser.Commit) (res Result, rerr e
 
 	// copy unchanged from prev
 	p := commit.Parents[0]
-	files := s.repo.GetCommitMust(p)
+	files := r.GetCommitMust(p)
 	for fp := range files {
 		// was in the diff changes, nothing to do
 		if _, ok := res.Files[fp]; ok {
 			continue
 		}
-		blame, err := s.repo.GetFileMust(p, fp)
+		blame, err := r.GetFileMust(p, fp)
 		if err != nil {
 			rerr = fmt.Errorf("could not get parent file for unchanged: %v err: %v", commit.Hash, err)
 			return
 		}
 		// copy reference
-		s.repo[commit.Hash][fp] = blame
+		r[commit.Hash][fp] = blame
 	}
 
 	return
@@ -481,25 +801,31 @@ func (s *Process) processRegularCommit(commit parser.Commit) (res Result, rerr e
 
 const deletedPrefix = "@@@del@@@"
 
-func (s *Process) processMergeCommit(commitHash string, parts map[string]parser.Commit) (res Result, rerr error) {
-	s.lastProcessedCommitHash = commitHash
+// processMergeCommit applies merge diffs against each parent (git log -m) on top of parent states in r. Octopus merges with more than 2 parents are handled the same way, each line is attributed to the first parent it came from, same as git blame.
+func (s *Process) processMergeCommit(r repo.Repo, commitHash string, parts map[string]parser.Commit) (res Result, rerr error) {
+
+	parentHashes := s.graph.Parents[commitHash]
+	parentCount := len(parentHashes)
 
 	start := time.Now()
 	defer func() {
 		dur := time.Since(start)
+		s.mu.Lock()
 		s.timing.UpdateSlowestCommitsWith(commitHash, dur)
 		s.timing.MergesTime += dur
 		s.timing.MergesCount++
+		if parentCount > 2 {
+			s.timing.OctopusMergesCount++
+		}
+		s.mu.Unlock()
+		s.opts.Metrics.Duration(metrics.StageDuration, dur, "stage", metrics.StageMergeCommit)
 	}()
 
 	// note that commit exists (important for empty commits)
-	s.repo.AddCommit(commitHash)
+	r.AddCommit(commitHash)
 
 	//fmt.Println("processing merge commit", commitHash)
 
-	parentHashes := s.graph.Parents[commitHash]
-	parentCount := len(parentHashes)
-
 	res.Commit = commitHash
 	res.Files = map[string]*incblame.Blame{}
 
@@ -511,9 +837,36 @@ func (s *Process) processMergeCommit(commitHash string, parts map[string]parser.
 		hashToParOrd[h] = i
 	}
 
+	quarantine := func(path string, err error) {
+		s.opts.Logger.Warn("could not apply merge diff, marking file blame as unknown", "commit", commitHash, "file", path, "err", err)
+		if res.Quarantined == nil {
+			res.Quarantined = map[string]error{}
+		}
+		res.Quarantined[path] = err
+		bl := incblame.BlameUnknownFile(commitHash)
+		r[commitHash][path] = bl
+		res.Files[path] = bl
+	}
+
 	for parHash, part := range parts {
+		// merge with no changes is shown without the parent
+		parInd, ok := hashToParOrd[parHash]
+		if !ok && len(part.Changes) != 0 {
+			rerr = fmt.Errorf("merge diff is from a commit that is not a parent. merge: %v from: %v parents: %v", commitHash, parHash, parentHashes)
+			return
+		}
 		for _, ch := range part.Changes {
-			diff := incblame.Parse(ch.Diff)
+			parseStart := time.Now()
+			diff, err := incblame.Parse(ch.Diff)
+			s.batch.AddDurations(time.Since(parseStart), 0)
+			if err != nil {
+				if diff.Path == "" {
+					rerr = fmt.Errorf("could not parse merge diff. merge: %v err: %v", commitHash, err)
+					return
+				}
+				quarantine(diff.Path, err)
+				continue
+			}
 			key := ""
 			if diff.Path != "" {
 				key = diff.Path
@@ -525,7 +878,6 @@ func (s *Process) processMergeCommit(commitHash string, parts map[string]parser.
 				par = make([]*incblame.Diff, parentCount, parentCount)
 				diffs[key] = par
 			}
-			parInd := hashToParOrd[parHash]
 			par[parInd] = &diff
 		}
 	}
@@ -533,6 +885,9 @@ func (s *Process) processMergeCommit(commitHash string, parts map[string]parser.
 	// get a list of all files
 	files := map[string]bool{}
 	for k := range diffs {
+		if res.Quarantined[k] != nil {
+			continue
+		}
 		files[k] = true
 	}
 
@@ -583,7 +938,7 @@ EACHFILE:
 				continue
 			}
 			parent := parentHashes[i]
-			pb, err := s.repo.GetFileMust(parent, diff.PathPrev)
+			pb, err := r.GetFileMust(parent, diff.PathPrev)
 			if err != nil {
 				rerr = fmt.Errorf("could not get file for merge bin parent. merge: %v %v", commitHash, err)
 				return
@@ -596,7 +951,7 @@ EACHFILE:
 		// do not try to resolve the diffs for binary files in merge commits
 		if binaryDiffs != 0 || binParentsWithDiffs != 0 {
 			bl := incblame.BlameBinaryFile(commitHash)
-			s.repo[commitHash][k] = bl
+			r[commitHash][k] = bl
 			res.Files[k] = bl
 			continue
 		}
@@ -624,10 +979,10 @@ EACHFILE:
 			if diff == nil {
 				// same as parent
 				parent := parentHashes[i]
-				pb := s.repo.GetFileOptional(parent, k)
+				pb := r.GetFileOptional(parent, k)
 				if pb != nil {
 					// exacly the same as parent, no changes
-					s.repo[commitHash][k] = pb
+					r[commitHash][k] = pb
 					continue EACHFILE
 				}
 			}
@@ -638,9 +993,10 @@ EACHFILE:
 			if diff == nil {
 				// no change use prev
 				parentHash := parentHashes[i]
-				parentBlame := s.repo.GetFileOptional(parentHash, k)
+				parentBlame := r.GetFileOptional(parentHash, k)
 				if parentBlame == nil {
-					panic(fmt.Errorf("merge: no change for file recorded, but parent does not contain file:%v merge commit:%v parent:%v", k, commitHash, parentHash))
+					rerr = &ApplyError{Files: []FailedFile{{Commit: commitHash, Path: k, Err: fmt.Errorf("merge: no change for file recorded, but parent does not contain file, parent: %v", parentHash)}}}
+					return
 				}
 				parents = append(parents, *parentBlame)
 				continue
@@ -654,7 +1010,7 @@ EACHFILE:
 			}
 
 			parentHash := parentHashes[i]
-			parentBlame, err := s.repo.GetFileMust(parentHash, pathPrev)
+			parentBlame, err := r.GetFileMust(parentHash, pathPrev)
 			if err != nil {
 				rerr = fmt.Errorf("could not get file for unchanged case1 merge file. merge: %v %v", commitHash, err)
 				return
@@ -669,8 +1025,27 @@ EACHFILE:
 			}
 			diffs2 = append(diffs2, *ob)
 		}
-		blame := incblame.ApplyMerge(parents, diffs2, commitHash, k)
-		s.repo[commitHash][k] = &blame
+		unknownParent := false
+		for _, p := range parents {
+			if p.IsUnknown {
+				unknownParent = true
+			}
+		}
+		if unknownParent {
+			// quarantined in one of the parents, stays unknown
+			bl := incblame.BlameUnknownFile(commitHash)
+			r[commitHash][k] = bl
+			res.Files[k] = bl
+			continue
+		}
+		applyStart := time.Now()
+		blame, err := incblame.ApplyMerge(parents, diffs2, commitHash, k)
+		s.batch.AddDurations(0, time.Since(applyStart))
+		if err != nil {
+			quarantine(k, err)
+			continue
+		}
+		r[commitHash][k] = &blame
 
 		// only showing deletes and files changed in merge comparent to at least one parent
 		res.Files[k] = &blame
@@ -681,7 +1056,7 @@ EACHFILE:
 	// get a list of all files in all parents
 	files = map[string]bool{}
 	for _, p := range parentHashes {
-		filesInCommit := s.repo.GetCommitMust(p)
+		filesInCommit := r.GetCommitMust(p)
 		for f := range filesInCommit {
 			files[f] = true
 		}
@@ -692,7 +1067,7 @@ EACHFILE:
 	for f := range files {
 		alreadyAddedAbove := false
 		{
-			bl := s.repo.GetFileOptional(commitHash, f)
+			bl := r.GetFileOptional(commitHash, f)
 			if bl != nil {
 				alreadyAddedAbove = true
 			}
@@ -705,7 +1080,7 @@ EACHFILE:
 
 		var candidates []*incblame.Blame
 		for _, p := range parentHashes {
-			bl := s.repo.GetFileOptional(p, f)
+			bl := r.GetFileOptional(p, f)
 			if bl != nil {
 				candidates = append(candidates, bl)
 			}
@@ -714,12 +1089,13 @@ EACHFILE:
 		// only one branch has the file
 		if len(candidates) == 1 {
 			// copy reference
-			s.repo[commitHash][f] = candidates[0]
+			r[commitHash][f] = candidates[0]
 			continue
 		}
 
 		if len(candidates) == 0 {
-			panic("no file candidates")
+			rerr = &ApplyError{Files: []FailedFile{{Commit: commitHash, Path: f, Err: errors.New("merge: no file candidates")}}}
+			return
 		}
 
 		// TODO: if more than one candidate we pick at random right now
@@ -749,13 +1125,13 @@ EACHFILE:
 		if res2 == nil {
 			var err error
 			// all are unchanged
-			res2, err = s.repo.GetFileMust(root, f)
+			res2, err = r.GetFileMust(root, f)
 			if err != nil {
 				rerr = fmt.Errorf("could not get file for unchanged case2 merge file. merge: %v %v", commitHash, err)
 				return
 			}
 		}
-		s.repo[commitHash][f] = res2
+		r[commitHash][f] = res2
 
 	}
 
@@ -763,7 +1139,7 @@ EACHFILE:
 }
 
 func (s *Process) slowGitBlame(commitHash string, filePath string) (res incblame.Blame, _ error) {
-	bl, err := gitblame2.Run(s.opts.RepoDir, commitHash, filePath)
+	bl, err := gitblame2.RunContext(s.context(), s.opts.RepoDir, commitHash, filePath)
 	//fmt.Println("running regular blame for file switching from bin mode to regular")
 	if err != nil {
 		return res, err
@@ -793,6 +1169,13 @@ func (s *Process) RunGetAll() (_ []Result, err error) {
 	return res2, err
 }
 
+func (s *Process) dateOrderFlag() string {
+	if s.opts.AuthorDateOrder {
+		return "--author-date-order"
+	}
+	return "--date-order"
+}
+
 func (s *Process) gitLogPatches() (io.ReadCloser, error) {
 	// empty file at temp location to set an empty attributesFile
 	f, err := ioutil.TempFile("", "ripsrc")
@@ -810,13 +1193,24 @@ func (s *Process) gitLogPatches() (io.ReadCloser, error) {
 		"log",
 		"-p",
 		"-m",
-		"--date-order",
+		s.dateOrderFlag(),
 		"--reverse",
 		"--no-abbrev-commit",
 		"--pretty=short",
 	}
 
-	if s.opts.CommitFromIncl != "" {
+	if len(s.opts.Refs) != 0 {
+		args = append(args, s.opts.Refs...)
+		if s.opts.CommitFromIncl != "" {
+			if s.opts.CommitFromMakeNonIncl {
+				args = append(args, "^"+s.opts.CommitFromIncl)
+			} else {
+				args = append(args, "^"+s.opts.CommitFromIncl+"^")
+			}
+			args = append(args, s.excludePrevTips()...)
+		}
+		args = append(args, "--")
+	} else if s.opts.CommitFromIncl != "" {
 		if s.opts.AllBranches {
 			for _, c := range s.opts.WantedBranchRefs {
 				args = append(args, c)
@@ -829,16 +1223,16 @@ func (s *Process) gitLogPatches() (io.ReadCloser, error) {
 			pf = "^..HEAD"
 		}
 		args = append(args, s.opts.CommitFromIncl+pf)
+		args = append(args, s.excludePrevTips()...)
 	} else {
 		if s.opts.AllBranches {
 			args = append(args, "--all")
 		}
 	}
 
-	ctx := context.Background()
 	//if s.opts.DisableCache {
 
-	return gitexec.ExecPiped(ctx, s.gitCommand, s.opts.RepoDir, args)
+	return gitexec.ExecPiped(s.ctx, s.gitCommand, s.opts.RepoDir, args)
 	//}
 	//return gitexec.ExecWithCache(ctx, s.gitCommand, s.opts.RepoDir, args)
 }
@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Compression is a codec for checkpoint files. Built in are CompressionGzip, which is the default, and CompressionNone.
+// Other codecs, for example zstd, could be added using RegisterCompression.
+type Compression interface {
+	// Name is used to select compression in options.
+	Name() string
+	// Magic is the prefix written by this codec at the start of the stream. Used to detect compression when reading. Empty for CompressionNone.
+	Magic() []byte
+	NewWriter(wr io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+type gzipCompression struct{}
+
+func (gzipCompression) Name() string {
+	return "gzip"
+}
+
+func (gzipCompression) Magic() []byte {
+	return []byte{0x1f, 0x8b}
+}
+
+func (gzipCompression) NewWriter(wr io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(wr), nil
+}
+
+func (gzipCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type noCompression struct{}
+
+func (noCompression) Name() string {
+	return "none"
+}
+
+func (noCompression) Magic() []byte {
+	return nil
+}
+
+func (noCompression) NewWriter(wr io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{wr}, nil
+}
+
+func (noCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(r), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+var (
+	// CompressionGzip compresses checkpoint files using gzip. This is the default.
+	CompressionGzip Compression = gzipCompression{}
+	// CompressionNone writes checkpoint files uncompressed. Faster, but uses more disk space.
+	CompressionNone Compression = noCompression{}
+)
+
+var compressionsMu sync.RWMutex
+var compressions = map[string]Compression{
+	CompressionGzip.Name(): CompressionGzip,
+	CompressionNone.Name(): CompressionNone,
+}
+
+// RegisterCompression makes compression available for writing and detected when reading checkpoints. Codec must write a non-empty Magic prefix.
+func RegisterCompression(c Compression) {
+	if len(c.Magic()) == 0 {
+		panic(fmt.Errorf("compression %v has no magic prefix", c.Name()))
+	}
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[c.Name()] = c
+}
+
+// CompressionByName returns registered compression. Empty name returns the default CompressionGzip.
+func CompressionByName(name string) (Compression, error) {
+	if name == "" {
+		return CompressionGzip, nil
+	}
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	c, ok := compressions[name]
+	if !ok {
+		var names []string
+		for k := range compressions {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown checkpoint compression %q, available: %v", name, names)
+	}
+	return c, nil
+}
+
+// detectCompression returns the compression of the stream based on its prefix. Streams without a known prefix are not compressed, which was the case for old checkpoints.
+func detectCompression(r *bufio.Reader) (Compression, error) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for _, c := range compressions {
+		magic := c.Magic()
+		if len(magic) == 0 {
+			continue
+		}
+		b, err := r.Peek(len(magic))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if bytes.Equal(b, magic) {
+			return c, nil
+		}
+	}
+	return CompressionNone, nil
+}
+
+// Recompress rewrites checkpoint stored in dir using passed compression. Could be used to migrate existing checkpoints to a different compression, reading works with any registered compression.
+func Recompress(dir string, c Compression) error {
+	dir = filepath.Join(dir, checkpointDirName)
+	err := restoreOld(dir)
+	if err != nil {
+		return err
+	}
+	for _, kind := range checkpointKinds {
+		err := recompressFile(filepath.Join(dir, kind), c, false)
+		if err != nil {
+			return fmt.Errorf("could not recompress checkpoint file %v err: %v", kind, err)
+		}
+	}
+	return nil
+}
+
+// recompressFile rewrites file using compression c. If c is nil, uses the current compression of the file. Skips files already using c unless force is set.
+func recompressFile(loc string, c Compression, force bool) error {
+	f, err := os.Open(loc)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	current, err := detectCompression(br)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = current
+	}
+	if current.Name() == c.Name() && !force {
+		return nil
+	}
+	r, err := current.NewReader(br)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(loc + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	wr, err := c.NewWriter(out)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(wr, r)
+	// checkpoints written before Close was called on gzip writer have no gzip footer
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	err = wr.Close()
+	if err != nil {
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(loc+".tmp", loc)
+}
@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// prefixCompression is a test codec that writes data as is after a magic prefix.
+type prefixCompression struct{}
+
+func (prefixCompression) Name() string {
+	return "test-prefix"
+}
+
+func (prefixCompression) Magic() []byte {
+	return []byte("RTST")
+}
+
+func (c prefixCompression) NewWriter(wr io.Writer) (io.WriteCloser, error) {
+	_, err := wr.Write(c.Magic())
+	if err != nil {
+		return nil, err
+	}
+	return nopWriteCloser{wr}, nil
+}
+
+func (c prefixCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	_, err := io.ReadFull(r, make([]byte, len(c.Magic())))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+func testRepo() Repo {
+	repo := New()
+	for i := 0; i < 3; i++ {
+		ch := randomString(32)
+		repo.AddCommit(ch)
+		for i := 0; i < 3; i++ {
+			repo[ch][randomString(10)] = randomBlameLineLen(3, 10)
+		}
+	}
+	return repo
+}
+
+func TestCompression(t *testing.T) {
+	RegisterCompression(prefixCompression{})
+	for _, name := range []string{"gzip", "none", "test-prefix"} {
+		t.Run(name, func(t *testing.T) {
+			dir := tempDir()
+			defer os.RemoveAll(dir)
+			repo := testRepo()
+
+			c, err := CompressionByName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wr := testWriter(t)
+			wr.Compression = c
+			err = wr.Write(repo, dir, "c1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo2, err := testReader(t).Read(dir, "c1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(repo, repo2) {
+				t.Fatalf("wanted repo %v\ngot repo %v", repo.Debug(), repo2.Debug())
+			}
+		})
+	}
+	_, err := CompressionByName("invalid")
+	if err == nil {
+		t.Fatal("expected error for unknown compression")
+	}
+}
+
+// writeLegacy rewrites checkpoint files as older versions did, gzip flushed but not closed, so there is no gzip footer
+func writeLegacy(t *testing.T, dir string) {
+	for _, kind := range checkpointKinds {
+		loc := filepath.Join(dir, checkpointDirName, kind)
+		f, err := os.Open(loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		out, err := os.Create(loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gw := gzip.NewWriter(out)
+		wr := msgp.NewWriter(gw)
+		_, err = wr.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wr.Flush()
+		gw.Flush()
+		out.Close()
+	}
+}
+
+func TestCompressionLegacyAndRecompress(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	repo := testRepo()
+
+	err := testWriter(t).Write(repo, dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLegacy(t, dir)
+
+	check := func() {
+		t.Helper()
+		repo2, err := testReader(t).Read(dir, "c1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(repo, repo2) {
+			t.Fatalf("wanted repo %v\ngot repo %v", repo.Debug(), repo2.Debug())
+		}
+	}
+	check()
+
+	err = Recompress(dir, CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	err = Recompress(dir, CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
@@ -20,6 +20,7 @@ type Blame struct {
 	Commit       string   `msg:"c"`
 	LinePointers []uint64 `msg:"lp"`
 	IsBinary     bool     `msg:"ib"`
+	IsUnknown    bool     `msg:"iu"`
 }
 
 type Line struct {
@@ -61,6 +61,12 @@ func (z *Blame) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "IsBinary")
 				return
 			}
+		case "iu":
+			z.IsUnknown, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "IsUnknown")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -74,9 +80,9 @@ func (z *Blame) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Blame) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 4
+	// map header, size 5
 	// write "p"
-	err = en.Append(0x84, 0xa1, 0x70)
+	err = en.Append(0x85, 0xa1, 0x70)
 	if err != nil {
 		return
 	}
@@ -122,15 +128,25 @@ func (z *Blame) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "IsBinary")
 		return
 	}
+	// write "iu"
+	err = en.Append(0xa2, 0x69, 0x75)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.IsUnknown)
+	if err != nil {
+		err = msgp.WrapError(err, "IsUnknown")
+		return
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Blame) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 4
+	// map header, size 5
 	// string "p"
-	o = append(o, 0x84, 0xa1, 0x70)
+	o = append(o, 0x85, 0xa1, 0x70)
 	o = msgp.AppendUint64(o, z.Pointer)
 	// string "c"
 	o = append(o, 0xa1, 0x63)
@@ -144,6 +160,9 @@ func (z *Blame) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "ib"
 	o = append(o, 0xa2, 0x69, 0x62)
 	o = msgp.AppendBool(o, z.IsBinary)
+	// string "iu"
+	o = append(o, 0xa2, 0x69, 0x75)
+	o = msgp.AppendBool(o, z.IsUnknown)
 	return
 }
 
@@ -202,6 +221,12 @@ func (z *Blame) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "IsBinary")
 				return
 			}
+		case "iu":
+			z.IsUnknown, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "IsUnknown")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -216,7 +241,7 @@ func (z *Blame) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Blame) Msgsize() (s int) {
-	s = 1 + 2 + msgp.Uint64Size + 2 + msgp.StringPrefixSize + len(z.Commit) + 3 + msgp.ArrayHeaderSize + (len(z.LinePointers) * (msgp.Uint64Size)) + 3 + msgp.BoolSize
+	s = 1 + 2 + msgp.Uint64Size + 2 + msgp.StringPrefixSize + len(z.Commit) + 3 + msgp.ArrayHeaderSize + (len(z.LinePointers) * (msgp.Uint64Size)) + 3 + msgp.BoolSize + 3 + msgp.BoolSize
 	return
 }
 
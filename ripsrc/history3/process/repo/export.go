@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ExportPrefix is the directory of checkpoint files in archives written by ExportCheckpoint.
+const ExportPrefix = checkpointDirName + "/"
+
+// checkpointFiles are all files of a complete checkpoint
+func checkpointFiles() []string {
+	return append(append([]string{}, checkpointKinds...), checkpointFormatFile, checkpointVersionFile)
+}
+
+// ExportCheckpoint writes files of checkpoint stored in dir to tw under ExportPrefix, so that it could be imported on another machine using CheckpointImport. Files are written as is, since they are already compressed.
+// Returns ErrNoCheckpoint if dir has no checkpoint.
+func ExportCheckpoint(dir string, tw *tar.Writer) error {
+	commit, err := CheckpointCommit(dir)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		return ErrNoCheckpoint
+	}
+	dir = filepath.Join(dir, checkpointDirName)
+	err = checkFormat(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range checkpointFiles() {
+		err := exportFile(tw, filepath.Join(dir, name), ExportPrefix+name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrNoCheckpoint is returned when exporting a dir without checkpoint.
+var ErrNoCheckpoint = errors.New("ripsrc: no checkpoint to export")
+
+func exportFile(tw *tar.Writer, loc string, name string) error {
+	f, err := os.Open(loc)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0666,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// CheckpointImport writes checkpoint files exported on another machine into a temporary dir and replaces checkpoint in dir once all files are written, so that an incomplete import does not affect the existing checkpoint.
+type CheckpointImport struct {
+	dir    string
+	tmpDir string
+	added  map[string]bool
+}
+
+// NewCheckpointImport prepares import into dir. Call Close when done, even after Finish.
+func NewCheckpointImport(dir string) (*CheckpointImport, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	s := &CheckpointImport{}
+	s.dir = dir
+	s.tmpDir, err = ioutil.TempDir(dir, "tmp-import-")
+	if err != nil {
+		return nil, err
+	}
+	s.added = map[string]bool{}
+	return s, nil
+}
+
+// Add writes checkpoint file. Name is the name of the file in archive, files not written by ExportCheckpoint are rejected.
+func (s *CheckpointImport) Add(name string, r io.Reader) error {
+	base := path.Base(name)
+	valid := path.Clean(name) == ExportPrefix+base
+	if valid {
+		valid = false
+		for _, f := range checkpointFiles() {
+			if f == base {
+				valid = true
+			}
+		}
+	}
+	if !valid {
+		return fmt.Errorf("ripsrc: unexpected file in checkpoint archive: %v", name)
+	}
+	f, err := os.Create(filepath.Join(s.tmpDir, base))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	s.added[base] = true
+	return nil
+}
+
+// Finish checks that checkpoint is complete and in supported format and replaces checkpoint in dir. Returns the last commit included in imported checkpoint.
+func (s *CheckpointImport) Finish() (commit string, _ error) {
+	for _, f := range checkpointFiles() {
+		if !s.added[f] {
+			return "", fmt.Errorf("ripsrc: checkpoint archive is incomplete, missing file: %v", f)
+		}
+	}
+	v, err := checkpointFormat(s.tmpDir)
+	if err != nil {
+		return "", err
+	}
+	if v > FormatVersion {
+		return "", ErrCheckpointFormat{CheckpointDir: s.tmpDir, Have: v, Want: FormatVersion}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(s.tmpDir, checkpointVersionFile))
+	if err != nil {
+		return "", err
+	}
+	commit = string(b)
+
+	// same swap as in CheckpointWriter, restoreOld recovers from crash in between
+	dir := filepath.Join(s.dir, checkpointDirName)
+	err = restoreOld(dir)
+	if err != nil {
+		return "", err
+	}
+	oldDir := dir + oldDirSuffix
+	err = os.RemoveAll(oldDir)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(dir, oldDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	err = os.Rename(s.tmpDir, dir)
+	if err != nil {
+		return "", err
+	}
+	err = os.RemoveAll(oldDir)
+	if err != nil {
+		return "", err
+	}
+	_, err = Migrate(s.dir)
+	if err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// Close removes temporary files left by unfinished import.
+func (s *CheckpointImport) Close() error {
+	return os.RemoveAll(s.tmpDir)
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FormatVersion is the version of checkpoint format written by this version of ripsrc.
+// Increment it when serialization changes and add migration from the previous version to migrations.
+//
+// Versions:
+// 1 - checkpoints without format file, gzip streams were not closed and had no footer
+// 2 - format file added, compressed streams are closed
+const FormatVersion = 2
+
+const checkpointFormatFile = "checkpoint-format"
+
+// migrations[v] upgrades checkpoint in dir from format v to v+1
+var migrations = map[int]func(dir string) error{
+	1: migrate1to2,
+}
+
+// ErrCheckpointFormat is returned when reading checkpoint written in a different format version.
+type ErrCheckpointFormat struct {
+	CheckpointDir string
+	Have          int
+	Want          int
+}
+
+func (s ErrCheckpointFormat) Error() string {
+	if s.Have > s.Want {
+		return fmt.Sprintf("ripsrc: checkpoint format version %v was written by a newer version of ripsrc, supported version is %v, dir: %v", s.Have, s.Want, s.CheckpointDir)
+	}
+	return fmt.Sprintf("ripsrc: checkpoint format version %v is outdated, supported version is %v, use Migrate to upgrade it, dir: %v", s.Have, s.Want, s.CheckpointDir)
+}
+
+// checkpointFormat returns format version of checkpoint in checkpoint dir. Checkpoints without format file are version 1.
+func checkpointFormat(dir string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, checkpointFormatFile))
+	if os.IsNotExist(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed reading checkpoint format file, err: %v", err)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint format file, err: %v", err)
+	}
+	return v, nil
+}
+
+func checkFormat(dir string) error {
+	v, err := checkpointFormat(dir)
+	if err != nil {
+		return err
+	}
+	if v != FormatVersion {
+		return ErrCheckpointFormat{CheckpointDir: dir, Have: v, Want: FormatVersion}
+	}
+	return nil
+}
+
+func writeFormat(dir string) error {
+	return writeFileAtomic(filepath.Join(dir, checkpointFormatFile), []byte(strconv.Itoa(FormatVersion)))
+}
+
+// Migrate upgrades checkpoint stored in dir to the current FormatVersion. Returns the version checkpoint had before migration.
+// Does nothing if there is no checkpoint or it is already in the current format.
+func Migrate(dir string) (from int, _ error) {
+	dir = filepath.Join(dir, checkpointDirName)
+	err := restoreOld(dir)
+	if err != nil {
+		return 0, err
+	}
+	_, err = os.Stat(filepath.Join(dir, checkpointVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	from, err = checkpointFormat(dir)
+	if err != nil {
+		return 0, err
+	}
+	if from > FormatVersion {
+		return from, ErrCheckpointFormat{CheckpointDir: dir, Have: from, Want: FormatVersion}
+	}
+	for v := from; v < FormatVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return from, fmt.Errorf("ripsrc: no migration from checkpoint format version %v, reprocess the repo from scratch, dir: %v", v, dir)
+		}
+		err := m(dir)
+		if err != nil {
+			return from, fmt.Errorf("ripsrc: could not migrate checkpoint from format version %v, dir: %v err: %v", v, dir, err)
+		}
+	}
+	return from, nil
+}
+
+// migrate1to2 closes compressed streams and adds format file
+func migrate1to2(dir string) error {
+	for _, kind := range checkpointKinds {
+		err := recompressFile(filepath.Join(dir, kind), nil, true)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(dir, checkpointFormatFile), []byte("2"))
+}
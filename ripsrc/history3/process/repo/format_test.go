@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFormatMigrate(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	repo := testRepo()
+
+	err := testWriter(t).Write(repo, dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make it look like checkpoint written by format version 1
+	writeLegacy(t, dir)
+	err = os.Remove(filepath.Join(dir, checkpointDirName, checkpointFormatFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testReader(t).Read(dir, "c1")
+	ferr, ok := err.(ErrCheckpointFormat)
+	if !ok {
+		t.Fatalf("expected ErrCheckpointFormat, got %v", err)
+	}
+	if ferr.Have != 1 || ferr.Want != FormatVersion {
+		t.Fatalf("unexpected error %+v", ferr)
+	}
+
+	from, err := Migrate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Fatalf("wanted migration from version 1, got %v", from)
+	}
+	repo2, err := testReader(t).Read(dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repo, repo2) {
+		t.Fatalf("wanted repo %v\ngot repo %v", repo.Debug(), repo2.Debug())
+	}
+
+	from, err = Migrate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != FormatVersion {
+		t.Fatalf("wanted no migration, got from %v", from)
+	}
+}
+
+func TestFormatNewer(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+
+	err := testWriter(t).Write(testRepo(), dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, checkpointDirName, checkpointFormatFile), []byte("1000"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testReader(t).Read(dir, "c1")
+	if _, ok := err.(ErrCheckpointFormat); !ok {
+		t.Fatalf("expected ErrCheckpointFormat, got %v", err)
+	}
+	_, err = Migrate(dir)
+	if _, ok := err.(ErrCheckpointFormat); !ok {
+		t.Fatalf("expected ErrCheckpointFormat, got %v", err)
+	}
+}
+
+func TestFormatMigrateNoCheckpoint(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	from, err := Migrate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 {
+		t.Fatalf("wanted 0 for missing checkpoint, got %v", from)
+	}
+}
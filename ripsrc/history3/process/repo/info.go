@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo/disk"
+)
+
+// Info describes checkpoint stored on disk.
+type Info struct {
+	// Commit is the last commit included in checkpoint.
+	Commit string
+	// Time when checkpoint was written.
+	Time time.Time
+	// Commits is the number of commits which blame state is stored in checkpoint.
+	Commits int
+	// Files is the number of file blames stored in checkpoint, including the same blame referenced from multiple commits.
+	Files int
+	// SizeBytes is the size of checkpoint files.
+	SizeBytes int64
+	// FormatVersion is the version of checkpoint format.
+	FormatVersion int
+}
+
+// CheckpointInfo returns information about checkpoint stored in dir. Only reads the index of commits and files, not the blame data. Returns nil if there is no checkpoint.
+func CheckpointInfo(dir string) (*Info, error) {
+	commit, err := CheckpointCommit(dir)
+	if err != nil {
+		return nil, err
+	}
+	if commit == "" {
+		return nil, nil
+	}
+	cdir := filepath.Join(dir, checkpointDirName)
+	res := &Info{}
+	res.Commit = commit
+	stat, err := os.Stat(filepath.Join(cdir, checkpointVersionFile))
+	if err != nil {
+		return nil, err
+	}
+	res.Time = stat.ModTime()
+	res.FormatVersion, err = checkpointFormat(cdir)
+	if err != nil {
+		return nil, err
+	}
+	res.SizeBytes, err = CheckpointSize(dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMsgReader(cdir, "repo")
+	if err != nil {
+		return nil, err
+	}
+	commits := map[string]bool{}
+	for {
+		obj := &disk.DataRow{}
+		err := r.Read(obj)
+		if err != nil {
+			if msgIsEOF(err) {
+				break
+			}
+			r.Finish()
+			return nil, err
+		}
+		commits[obj.Commit] = true
+		res.Files++
+	}
+	res.Commits = len(commits)
+	err = r.Finish()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
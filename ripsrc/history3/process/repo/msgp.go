@@ -1,21 +1,25 @@
 package repo
 
 import (
-	"compress/gzip"
+	"bufio"
+	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/tinylib/msgp/msgp"
 )
 
+// checkpointKinds are the names of files in checkpoint dir
+var checkpointKinds = []string{"repo", "blames", "lines", "line-data"}
+
 type msgWriter struct {
 	loc string
 	f   *os.File
-	gw  *gzip.Writer
+	cw  io.WriteCloser
 	wr  *msgp.Writer
 }
 
-func newMsgWriter(dir string, kind string) (*msgWriter, error) {
+func newMsgWriter(dir string, kind string, compression Compression) (*msgWriter, error) {
 	s := &msgWriter{}
 	err := os.MkdirAll(dir, 0777)
 	if err != nil {
@@ -27,8 +31,11 @@ func newMsgWriter(dir string, kind string) (*msgWriter, error) {
 		return nil, err
 	}
 	s.f = f
-	s.gw = gzip.NewWriter(f)
-	s.wr = msgp.NewWriter(s.gw)
+	s.cw, err = compression.NewWriter(f)
+	if err != nil {
+		return nil, err
+	}
+	s.wr = msgp.NewWriter(s.cw)
 	return s, nil
 }
 
@@ -41,7 +48,7 @@ func (s *msgWriter) Finish() error {
 	if err != nil {
 		return err
 	}
-	err = s.gw.Flush()
+	err = s.cw.Close()
 	if err != nil {
 		return err
 	}
@@ -55,7 +62,7 @@ func (s *msgWriter) Finish() error {
 type msgReader struct {
 	loc string
 	f   *os.File
-	gr  *gzip.Reader
+	cr  io.ReadCloser
 	r   *msgp.Reader
 }
 
@@ -67,11 +74,16 @@ func newMsgReader(dir string, kind string) (*msgReader, error) {
 		return nil, err
 	}
 	s.f = f
-	s.gr, err = gzip.NewReader(f)
+	br := bufio.NewReader(f)
+	compression, err := detectCompression(br)
+	if err != nil {
+		return nil, err
+	}
+	s.cr, err = compression.NewReader(br)
 	if err != nil {
 		return nil, err
 	}
-	s.r = msgp.NewReader(s.gr)
+	s.r = msgp.NewReader(s.cr)
 	return s, nil
 }
 
@@ -80,7 +92,11 @@ func (s *msgReader) Read(obj msgp.Decodable) error {
 }
 
 func (s *msgReader) Finish() error {
-	err := s.f.Close()
+	err := s.cr.Close()
+	if err != nil && !msgIsEOF(err) {
+		return err
+	}
+	err = s.f.Close()
 	if err != nil {
 		return err
 	}
@@ -3,7 +3,9 @@ package repo
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
@@ -34,6 +36,16 @@ func NewCheckpointReader(logger logger.Logger) *CheckpointReader {
 func (s *CheckpointReader) Read(dir string, expectedCommit string) (Repo, error) {
 	dir = filepath.Join(dir, checkpointDirName)
 
+	err := restoreOld(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkFormat(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	if expectedCommit != "" {
 		// no expected commit validation requested
 		b, err := ioutil.ReadFile(filepath.Join(dir, checkpointVersionFile))
@@ -121,6 +133,7 @@ func (s *CheckpointReader) Read(dir string, expectedCommit string) (Repo, error)
 			bl := &incblame.Blame{}
 			bl.Commit = obj.Commit
 			bl.IsBinary = obj.IsBinary
+			bl.IsUnknown = obj.IsUnknown
 			for _, lp := range obj.LinePointers {
 				line, ok := lines[lp]
 				if !ok {
@@ -177,8 +190,56 @@ func (s *CheckpointReader) Read(dir string, expectedCommit string) (Repo, error)
 }
 
 func msgIsEOF(err error) bool {
-	if err.Error() == "unexpected EOF" {
+	// checkpoints written by older versions have no gzip footer and end with unexpected EOF
+	if err.Error() == "EOF" || err.Error() == "unexpected EOF" {
 		return true
 	}
 	return false
 }
+
+// CheckpointSize returns the size in bytes of checkpoint files stored in dir.
+func CheckpointSize(dir string) (res int64, _ error) {
+	dir = filepath.Join(dir, checkpointDirName)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			res += f.Size()
+		}
+	}
+	return res, nil
+}
+
+// CheckpointCommit returns the last commit included in checkpoint stored in dir. Returns empty string if there is no checkpoint.
+func CheckpointCommit(dir string) (string, error) {
+	err := restoreOld(filepath.Join(dir, checkpointDirName))
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, checkpointDirName, checkpointVersionFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed reading checkpoint version file, err: %v", err)
+	}
+	return string(b), nil
+}
+
+// CheckpointTips returns CheckpointWriter.Tips of checkpoint stored in dir. Returns nil if there is no checkpoint or it was written without tips.
+func CheckpointTips(dir string) ([]string, error) {
+	err := restoreOld(filepath.Join(dir, checkpointDirName))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, checkpointDirName, checkpointTipsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading checkpoint tips file, err: %v", err)
+	}
+	return strings.Fields(string(b)), nil
+}
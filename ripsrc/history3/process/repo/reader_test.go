@@ -68,3 +68,69 @@ func TestReaderValidateCommit(t *testing.T) {
 	assert.Equal(t, "c1", err2.HaveCommit)
 	t.Log("error msg: " + err.Error())
 }
+
+func TestCheckpointInfo(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+
+	info, err := CheckpointInfo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != nil {
+		t.Fatalf("expected no checkpoint, got %+v", info)
+	}
+
+	repo := New()
+	repo.AddCommit("c1")
+	repo["c1"]["p1"] = randomBlameLineLen(1, 1)
+	repo["c1"]["p2"] = randomBlameLineLen(1, 1)
+	repo.AddCommit("c2")
+	repo["c2"]["p1"] = repo["c1"]["p1"]
+	err = testWriter(t).Write(repo, dir, "c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = CheckpointInfo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "c2", info.Commit)
+	assert.Equal(t, 2, info.Commits)
+	assert.Equal(t, 3, info.Files)
+	assert.Equal(t, FormatVersion, info.FormatVersion)
+	assert.True(t, info.SizeBytes > 0)
+	assert.False(t, info.Time.IsZero())
+}
+
+func TestCheckpointTips(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	repo := New()
+	repo.AddCommit("c1")
+	repo["c1"]["p1"] = randomBlameLineLen(1, 1)
+
+	err := testWriter(t).Write(repo, dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tips, err := CheckpointTips(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tips != nil {
+		t.Fatalf("expected no tips, got %v", tips)
+	}
+
+	w := testWriter(t)
+	w.Tips = []string{"c1", "c2"}
+	err = w.Write(repo, dir, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tips, err = CheckpointTips(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"c1", "c2"}, tips)
+}
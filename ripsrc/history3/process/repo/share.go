@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CopyCheckpoint copies checkpoint stored in dir to dst. Files are hard linked when possible, which is safe since checkpoint files are never modified in place.
+// Does nothing if dst already has a checkpoint.
+func CopyCheckpoint(dir, dst string) error {
+	src := filepath.Join(dir, checkpointDirName)
+	target := filepath.Join(dst, checkpointDirName)
+	_, err := os.Stat(target)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.MkdirAll(dst, 0777)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(dst, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		err := linkOrCopy(filepath.Join(src, f.Name()), filepath.Join(tmp, f.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Rename(tmp, target)
+	if err != nil {
+		// other process could have written the same checkpoint in the meantime
+		if _, serr := os.Stat(target); serr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func linkOrCopy(from, to string) error {
+	err := os.Link(from, to)
+	if err == nil {
+		return nil
+	}
+	r, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	wr, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(wr, r)
+	if err != nil {
+		wr.Close()
+		return err
+	}
+	return wr.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/cespare/xxhash"
@@ -17,13 +18,23 @@ const checkpointDirName = "checkpoint"
 
 const checkpointVersionFile = "checkpoint-version"
 
+// checkpointTipsFile lists CheckpointWriter.Tips, one commit per line.
+const checkpointTipsFile = "checkpoint-tips"
+
 type CheckpointWriter struct {
 	logger logger.Logger
+
+	// Compression used for checkpoint files. Default is CompressionGzip.
+	Compression Compression
+
+	// Tips are commits processed up to this checkpoint that are not parents of other processed commits. Incremental runs exclude their ancestors, so that commits on branches processed before are not returned again. Optional.
+	Tips []string
 }
 
 func NewCheckpointWriter(logger logger.Logger) *CheckpointWriter {
 	s := &CheckpointWriter{}
 	s.logger = logger
+	s.Compression = CompressionGzip
 	return s
 }
 
@@ -46,19 +57,19 @@ func (s *CheckpointWriter) Write(repo Repo, dir string, lastCommit string) error
 
 	dir = filepath.Join(dir, checkpointDirName)
 
-	repoWr, err := newMsgWriter(tmpDir, "repo")
+	repoWr, err := newMsgWriter(tmpDir, "repo", s.Compression)
 	if err != nil {
 		return err
 	}
-	blamesWr, err := newMsgWriter(tmpDir, "blames")
+	blamesWr, err := newMsgWriter(tmpDir, "blames", s.Compression)
 	if err != nil {
 		return err
 	}
-	linesWr, err := newMsgWriter(tmpDir, "lines")
+	linesWr, err := newMsgWriter(tmpDir, "lines", s.Compression)
 	if err != nil {
 		return err
 	}
-	lineDataWr, err := newMsgWriter(tmpDir, "line-data")
+	lineDataWr, err := newMsgWriter(tmpDir, "line-data", s.Compression)
 	if err != nil {
 		return err
 	}
@@ -97,6 +108,7 @@ func (s *CheckpointWriter) Write(repo Repo, dir string, lastCommit string) error
 			bl.Pointer = blamePointerC
 			bl.Commit = file.Commit
 			bl.IsBinary = file.IsBinary
+			bl.IsUnknown = file.IsUnknown
 			bl.LinePointers = make([]uint64, 0, len(file.Lines))
 			for _, l := range file.Lines {
 
@@ -160,17 +172,51 @@ func (s *CheckpointWriter) Write(repo Repo, dir string, lastCommit string) error
 		return err
 	}
 
+	err = writeFormat(tmpDir)
+	if err != nil {
+		return err
+	}
 	err = writeFileAtomic(filepath.Join(tmpDir, checkpointVersionFile), []byte(lastCommit))
 	if err != nil {
 		return err
 	}
+	if len(s.Tips) != 0 {
+		err = writeFileAtomic(filepath.Join(tmpDir, checkpointTipsFile), []byte(strings.Join(s.Tips, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+	}
 
-	err = os.RemoveAll(dir)
+	// swap directories so that a complete checkpoint exists on disk at all times, restoreOld recovers from crash in between
+	oldDir := dir + oldDirSuffix
+	err = os.RemoveAll(oldDir)
 	if err != nil {
 		return err
 	}
+	err = os.Rename(dir, oldDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(tmpDir, dir)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(oldDir)
+}
 
-	return os.Rename(tmpDir, dir)
+const oldDirSuffix = ".old"
+
+// restoreOld moves back previous checkpoint if writer crashed after moving it away, but before moving the new one in place.
+func restoreOld(dir string) error {
+	_, err := os.Stat(dir)
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(dir+oldDirSuffix, dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
 }
 
 func writeFileAtomic(loc string, data []byte) error {
@@ -0,0 +1,217 @@
+package process
+
+import (
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/parser"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// segmentScheduler processes commits of independent history segments in parallel.
+//
+// Commits come from git log in topological order. Each commit becomes a task that starts as soon as its parents are processed,
+// so commits on unrelated branches run concurrently, while each chain of commits is still applied in order. Merge commits wait for all their parents, joining the segments.
+//
+// Tasks do not modify s.repo. They read parent file state captured when the task is created, or from the parent task, and produce file state for their own commit.
+// Results are committed to s.repo and returned in the original log order from the calling goroutine, so output and checkpoints are the same as with sequential processing.
+type segmentScheduler struct {
+	p       *Process
+	resChan chan Result
+
+	sem    chan bool
+	window int
+
+	inflight map[string]*segmentTask
+	queue    []*segmentTask
+
+	pendingMerge *segmentTask
+}
+
+type segmentTask struct {
+	hash string
+	// commit is set for regular commits
+	commit parser.Commit
+	// parts is set for merge commits, map[parent_diffed]parser.Commit
+	parts map[string]parser.Commit
+	// lastEntry is the position of the last git log entry of this commit
+	lastEntry int
+
+	// parentFiles is state of parents already committed to s.repo at the time task was created
+	parentFiles map[string]map[string]*incblame.Blame
+	// deps are parent tasks not committed at the time task was created
+	deps []*segmentTask
+
+	done  chan bool
+	res   Result
+	files map[string]*incblame.Blame
+	err   error
+	// depFailed is true if err is the error of a parent task
+	depFailed bool
+}
+
+// segmentWindowFactor times SegmentConcurrency is the max number of dispatched tasks that are not yet committed. Documented in ripsrc.Opts.SegmentConcurrency.
+const segmentWindowFactor = 4
+
+func newSegmentScheduler(p *Process, resChan chan Result) *segmentScheduler {
+	s := &segmentScheduler{}
+	s.p = p
+	s.resChan = resChan
+	s.sem = make(chan bool, p.opts.SegmentConcurrency)
+	// limit the number of commits kept in memory ahead of the oldest unfinished one, results are sent to resChan only after that, so a slow reader pauses dispatching
+	s.window = p.opts.SegmentConcurrency * segmentWindowFactor
+	s.inflight = map[string]*segmentTask{}
+	return s
+}
+
+// Add schedules git log entry at position entry. Merges have multiple entries, one for each parent.
+func (s *segmentScheduler) Add(commit parser.Commit, entry int) error {
+	if s.pendingMerge != nil {
+		if s.pendingMerge.hash == commit.Hash {
+			s.pendingMerge.parts[commit.MergeDiffFrom] = commit
+			s.pendingMerge.lastEntry = entry
+			return nil
+		}
+		// all parts of merge received
+		err := s.dispatch(s.pendingMerge)
+		s.pendingMerge = nil
+		if err != nil {
+			return err
+		}
+	}
+	t := &segmentTask{}
+	t.hash = commit.Hash
+	t.lastEntry = entry
+	if len(commit.Parents) > 1 {
+		t.parts = map[string]parser.Commit{}
+		t.parts[commit.MergeDiffFrom] = commit
+		s.pendingMerge = t
+		return nil
+	}
+	t.commit = commit
+	return s.dispatch(t)
+}
+
+// Flush waits for all scheduled commits and returns their results.
+func (s *segmentScheduler) Flush() error {
+	if s.pendingMerge != nil {
+		err := s.dispatch(s.pendingMerge)
+		s.pendingMerge = nil
+		if err != nil {
+			return err
+		}
+	}
+	return s.commitDone(0)
+}
+
+func (s *segmentScheduler) dispatch(t *segmentTask) error {
+	t.done = make(chan bool)
+	t.parentFiles = map[string]map[string]*incblame.Blame{}
+	for _, p := range s.p.graph.Parents[t.hash] {
+		if dep, ok := s.inflight[p]; ok {
+			t.deps = append(t.deps, dep)
+			continue
+		}
+		if files, ok := s.p.repo[p]; ok {
+			t.parentFiles[p] = files
+		}
+	}
+	s.inflight[t.hash] = t
+	s.queue = append(s.queue, t)
+	go s.run(t)
+	return s.commitDone(s.window)
+}
+
+func (s *segmentScheduler) run(t *segmentTask) {
+	defer close(t.done)
+	for _, dep := range t.deps {
+		<-dep.done
+		if dep.err != nil {
+			t.err = dep.err
+			t.depFailed = true
+			return
+		}
+	}
+	s.sem <- true
+	defer func() {
+		<-s.sem
+	}()
+	defer recoverSegmentTask(t)
+	r := repo.New()
+	for p, files := range t.parentFiles {
+		r[p] = files
+	}
+	for _, dep := range t.deps {
+		r[dep.hash] = dep.files
+	}
+	if t.parts != nil {
+		t.res, t.err = s.p.processMergeCommit(r, t.hash, t.parts)
+	} else {
+		t.res, t.err = s.p.processRegularCommit(r, t.commit)
+	}
+	t.files = r[t.hash]
+}
+
+// commitDone commits finished tasks in log order, until no more than maxQueued tasks remain unfinished. Pass 0 to wait for all.
+func (s *segmentScheduler) commitDone(maxQueued int) error {
+	for len(s.queue) != 0 {
+		t := s.queue[0]
+		if len(s.queue) > maxQueued {
+			<-t.done
+		} else {
+			select {
+			case <-t.done:
+			default:
+				return nil
+			}
+		}
+		s.queue = s.queue[1:]
+		if t.err != nil {
+			return s.failed(t)
+		}
+		p := s.p
+		p.repo[t.hash] = t.files
+		delete(s.inflight, t.hash)
+		p.lastProcessedCommitHash = t.hash
+		p.trimGraphAfterCommitProcessed(t.hash)
+		err := p.send(s.resChan, t.res)
+		if err != nil {
+			s.wait()
+			return err
+		}
+		p.partialCommits++
+		p.commitsProcessed++
+		if p.shouldWritePartial() {
+			err := p.writePartial(t.lastEntry)
+			if err != nil {
+				s.wait()
+				return err
+			}
+		}
+		err = p.stopAtLimit(t.lastEntry)
+		if err != nil {
+			s.wait()
+			return err
+		}
+	}
+	return nil
+}
+
+// failed waits for remaining tasks after task t failed and returns errors of all failed tasks. Tasks that failed only because their parent failed are not included.
+func (s *segmentScheduler) failed(t *segmentTask) error {
+	res := newApplyError(t.hash, t.err)
+	for _, t2 := range s.queue {
+		<-t2.done
+		if t2.err != nil && !t2.depFailed {
+			res.add(t2.hash, t2.err)
+		}
+	}
+	s.queue = nil
+	return res
+}
+
+// wait waits for remaining tasks to finish after error
+func (s *segmentScheduler) wait() {
+	for _, t := range s.queue {
+		<-t.done
+	}
+	s.queue = nil
+}
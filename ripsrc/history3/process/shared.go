@@ -0,0 +1,49 @@
+package process
+
+import (
+	"path/filepath"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// sharedCheckpointDir returns location of checkpoint for commit in SharedCheckpointsDir.
+// Shared checkpoints are keyed by commit hash and not by repo, so that forks sharing history with upstream could reuse them.
+func (s *Process) sharedCheckpointDir(commit string) string {
+	prefix := commit
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(s.opts.SharedCheckpointsDir, prefix, commit)
+}
+
+// checkpointReadDir returns the dir to read checkpoint for CommitFromIncl from. Uses repo checkpoint if it exists for the commit, or if there is no shared checkpoint for it.
+func (s *Process) checkpointReadDir() (string, error) {
+	if s.opts.SharedCheckpointsDir == "" {
+		return s.checkpointsDir, nil
+	}
+	have, err := repo.CheckpointCommit(s.checkpointsDir)
+	if err != nil {
+		return "", err
+	}
+	if have == s.opts.CommitFromIncl || (have != "" && s.opts.NoStrictResume) {
+		return s.checkpointsDir, nil
+	}
+	shared := s.sharedCheckpointDir(s.opts.CommitFromIncl)
+	sharedHave, err := repo.CheckpointCommit(shared)
+	if err != nil {
+		return "", err
+	}
+	if sharedHave == "" {
+		return s.checkpointsDir, nil
+	}
+	s.opts.Logger.Info("using shared checkpoint", "commit", s.opts.CommitFromIncl, "dir", shared)
+	return shared, nil
+}
+
+// shareCheckpoint copies the checkpoint written for the last processed commit to SharedCheckpointsDir.
+func (s *Process) shareCheckpoint() error {
+	if s.opts.SharedCheckpointsDir == "" {
+		return nil
+	}
+	return repo.CopyCheckpoint(s.checkpointsDir, s.sharedCheckpointDir(s.lastProcessedCommitHash))
+}
@@ -0,0 +1,62 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// initTips reads tips of the checkpoint for CommitFromIncl. Commits reachable from them were processed by previous runs and are excluded from git log.
+// Checkpoints written by previous versions have no tips, in that case only ancestors of CommitFromIncl are excluded.
+func (s *Process) initTips() error {
+	s.tips = map[string]bool{}
+	s.prevTips = nil
+	if s.opts.CommitFromIncl == "" {
+		return nil
+	}
+	dir, err := s.checkpointReadDir()
+	if err != nil {
+		return fmt.Errorf("Could not read checkpoint: %v", err)
+	}
+	s.prevTips, err = repo.CheckpointTips(dir)
+	if err != nil {
+		return fmt.Errorf("Could not read checkpoint: %v", err)
+	}
+	for _, c := range s.prevTips {
+		s.tips[c] = true
+	}
+	return nil
+}
+
+// excludePrevTips returns git log args excluding commits processed by previous runs.
+// CommitFromIncl is not excluded when it should be processed again. Tips that are not in the graph are skipped, they are not reachable from processed refs and could no longer exist after force-push and gc.
+func (s *Process) excludePrevTips() (res []string) {
+	for _, c := range s.prevTips {
+		if c == s.opts.CommitFromIncl && !s.opts.CommitFromMakeNonIncl {
+			continue
+		}
+		if _, ok := s.graph.Parents[c]; !ok {
+			continue
+		}
+		res = append(res, "^"+c)
+	}
+	return
+}
+
+// addTip updates tips with commit returned from git log. Parents of commit are no longer tips.
+func (s *Process) addTip(commit string) {
+	for _, p := range s.graph.Parents[commit] {
+		delete(s.tips, p)
+	}
+	s.tips[commit] = true
+}
+
+// sortedTips returns tips to save in checkpoint, sorted so that git log args are stable across runs.
+func (s *Process) sortedTips() (res []string) {
+	for c := range s.tips {
+		res = append(res, c)
+	}
+	sort.Strings(res)
+	return
+}
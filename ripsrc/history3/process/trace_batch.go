@@ -0,0 +1,69 @@
+package process
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
+)
+
+// traceBatch groups processed commits into spans of tracing.BatchSize commits.
+type traceBatch struct {
+	span       tracing.Span
+	index      int
+	commits    int
+	lastCommit string
+
+	// mu protects durations, which could be added from commits processed in parallel
+	mu       sync.Mutex
+	parseDur time.Duration
+	applyDur time.Duration
+}
+
+// AddDurations adds time spent in incblame parse and apply to the current batch.
+func (s *traceBatch) AddDurations(parse, apply time.Duration) {
+	s.mu.Lock()
+	s.parseDur += parse
+	s.applyDur += apply
+	s.mu.Unlock()
+}
+
+// Commit is called for every commit read from git log. Merges are returned multiple times, once for each parent, but counted once.
+func (s *traceBatch) Commit(p *Process, commit string) {
+	if commit == s.lastCommit {
+		return
+	}
+	if s.span != nil && s.commits >= tracing.BatchSize {
+		s.End(nil)
+	}
+	if s.span == nil {
+		_, s.span = p.opts.Tracer.Start(p.ctx, tracing.SpanBlameBatch, "batch", strconv.Itoa(s.index), "first_commit", commit)
+		s.index++
+	}
+	s.commits++
+	s.lastCommit = commit
+}
+
+// End ends the current batch span if any.
+func (s *traceBatch) End(err error) {
+	if s.span == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.SetAttributes(
+		"last_commit", s.lastCommit,
+		"commits", strconv.Itoa(s.commits),
+		"parse_ms", strconv.FormatInt(int64(s.parseDur/time.Millisecond), 10),
+		"apply_ms", strconv.FormatInt(int64(s.applyDur/time.Millisecond), 10),
+	)
+	s.span.End()
+	s.span = nil
+	s.commits = 0
+	s.parseDur = 0
+	s.applyDur = 0
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestApplyErrorListsAllFailedFiles(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-apply-error-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	opts := process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}
+	_, err = process.New(opts).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patches := []process.Patch{
+		{
+			ID: "p1",
+			// renames of files that do not exist in parent
+			Diff: []byte(`diff --git a/x.txt b/y.txt
+similarity index 100%
+rename from x.txt
+rename to y.txt
+diff --git a/a.txt b/a.txt
+index 7898192..6178079 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1,2 @@
+ a
++b
+diff --git a/z.txt b/w.txt
+similarity index 100%
+rename from z.txt
+rename to w.txt
+`),
+		},
+	}
+	_, err = process.New(opts).RunPatches(c1, patches)
+	var applyErr *process.ApplyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("expected ApplyError, got %v", err)
+	}
+	var paths []string
+	for _, f := range applyErr.Files {
+		if f.Commit != "p1" || f.Err == nil {
+			t.Errorf("invalid failed file %+v", f)
+		}
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"w.txt", "y.txt"}) {
+		t.Errorf("expected both renames to fail, got %v", paths)
+	}
+	if !reflect.DeepEqual(applyErr.Commits(), []string{"p1"}) {
+		t.Errorf("invalid failed commits %v", applyErr.Commits())
+	}
+}
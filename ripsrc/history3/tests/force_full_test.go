@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestForceFullReprocess(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-force-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	full, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CommitFromIncl is ignored, all commits are returned
+	got, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, CommitFromIncl: c2, ForceFullReprocess: true}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertResult(t, full, got)
+	cache := filepath.Join(checkpointsDir, "pp-git-cache")
+	if _, err := os.Stat(cache + ".backup"); !os.IsNotExist(err) {
+		t.Fatal("backup should be removed after successful run")
+	}
+
+	// failed run restores previous checkpoint
+	r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	// shared checkpoints dir is a file, so writing shared checkpoint at the end fails
+	invalidShared := filepath.Join(checkpointsDir, "file")
+	err = ioutil.WriteFile(invalidShared, nil, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, SharedCheckpointsDir: invalidShared, ForceFullReprocess: true}).RunGetAll()
+	if err == nil {
+		t.Fatal("expected error writing shared checkpoint")
+	}
+	commit, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}).CheckpointCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != c2 {
+		t.Fatalf("expected checkpoint to be restored for %v, got %v", c2, commit)
+	}
+}
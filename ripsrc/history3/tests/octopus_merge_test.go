@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+// lines9 returns a file with 9 lines, replacing the lines at the given 1-based positions.
+func lines9(repl map[int]string) string {
+	var res []string
+	for i := 1; i <= 9; i++ {
+		l := fmt.Sprint(i)
+		if r, ok := repl[i]; ok {
+			l = r
+		}
+		res = append(res, l)
+	}
+	return strings.Join(res, "\n") + "\n"
+}
+
+// gitBlame returns the origin commit of each line of the file at commit according to git blame.
+func gitBlame(r *testkit.Repo, commit, path string) (res []string) {
+	out := r.Git("blame", "--line-porcelain", commit, "--", path)
+	for _, l := range strings.Split(out, "\n") {
+		if len(l) > 40 && l[40] == ' ' && !strings.HasPrefix(l, "\t") {
+			res = append(res, l[:40])
+		}
+	}
+	return
+}
+
+func assertSameAsGitBlame(t *testing.T, r *testkit.Repo, got []process.Result, commit string, paths ...string) {
+	t.Helper()
+	var res *process.Result
+	for i := range got {
+		if got[i].Commit == commit {
+			res = &got[i]
+		}
+	}
+	if res == nil {
+		t.Fatalf("no result for commit %v", commit)
+	}
+	for _, p := range paths {
+		f := res.Files[p]
+		if f == nil {
+			t.Fatalf("no file %v in commit %v", p, commit)
+		}
+		var lines []string
+		for _, l := range f.Lines {
+			lines = append(lines, l.Commit)
+		}
+		want := gitBlame(r, commit, p)
+		if strings.Join(lines, ",") != strings.Join(want, ",") {
+			t.Errorf("file %v commit %v blame differs from git blame\ngot  %v\nwant %v", p, commit, lines, want)
+		}
+	}
+}
+
+func TestOctopusMerge(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", lines9(nil)).Write("d.txt", "d\n").Commit("base")
+	r.Branch("b1").Write("a.txt", lines9(map[int]string{1: "b1"})).Commit("b1")
+	r.Checkout("master").Branch("b2").Write("a.txt", lines9(map[int]string{5: "b2"})).Write("b2.txt", "b2\n").Commit("b2")
+	r.Checkout("master").Branch("b3").Write("a.txt", lines9(map[int]string{9: "b3"})).Delete("d.txt").Commit("b3")
+	r.Checkout("master")
+	m := r.Merge("octopus", "b1", "b2", "b3")
+	if p := strings.Fields(r.Git("log", "-1", "--format=%P", m)); len(p) != 4 {
+		t.Fatalf("expected octopus merge with 4 parents, got %v", p)
+	}
+	c2 := r.Write("a.txt", lines9(map[int]string{1: "b1", 3: "c2", 5: "b2", 9: "b3"})).Write("b2.txt", "b2\nc2\n").Commit("c2")
+
+	p := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir()})
+	got, err := p.RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 {
+		t.Fatalf("wanted 6 results, got %v", len(got))
+	}
+	if timing := p.Timing(); timing.MergesCount != 1 || timing.OctopusMergesCount != 1 {
+		t.Errorf("wanted 1 octopus merge, got %+v", timing)
+	}
+	// only files that differ from all parents are returned for merge
+	res := got[4]
+	if res.Commit != m || len(res.Files) != 2 || res.Files["a.txt"] == nil || res.Files["d.txt"] == nil || len(res.Files["d.txt"].Lines) != 0 {
+		t.Errorf("unexpected merge result %v", res)
+	}
+	assertSameAsGitBlame(t, r, got, m, "a.txt")
+	assertSameAsGitBlame(t, r, got, c2, "a.txt", "b2.txt")
+}
+
+func TestOctopusMergeWithChangesInMerge(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", lines9(nil)).Write("r.txt", "r1\nr2\n").Commit("base")
+	r.Branch("b1").Write("a.txt", lines9(map[int]string{1: "b1"})).Commit("b1")
+	r.Write("new.txt", "n\n").Commit("b1-2")
+	r.Checkout("master").Branch("b2").Rename("r.txt", "r2.txt").Commit("b2")
+	// same file created in 2 branches
+	r.Checkout("master").Branch("b3").Write("a.txt", lines9(map[int]string{9: "b3"})).Write("new.txt", "n\n").Commit("b3")
+	r.Checkout("master").Write("a.txt", lines9(map[int]string{5: "m"})).Commit("m1")
+	r.Git("merge", "-q", "--no-ff", "--no-commit", "b1", "b2", "b3")
+	r.Write("a.txt", lines9(map[int]string{1: "b1", 3: "in-merge", 5: "m", 9: "b3"}))
+	r.Git("add", "-A")
+	r.Git("commit", "-q", "-m", "octopus")
+	m := r.Head()
+	c2 := r.Write("a.txt", lines9(map[int]string{1: "b1", 2: "c2", 3: "in-merge", 5: "m", 9: "b3"})).Write("r2.txt", "r1\nr2\nc2\n").Write("new.txt", "n\nc2\n").Commit("c2")
+
+	for _, opts := range []process.Opts{{}, {SegmentConcurrency: 4}, {AllBranches: true}} {
+		opts.RepoDir = r.Dir()
+		opts.CheckpointsDir = t.TempDir()
+		got, err := process.New(opts).RunGetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameAsGitBlame(t, r, got, m, "a.txt")
+		assertSameAsGitBlame(t, r, got, c2, "a.txt", "r2.txt", "new.txt")
+	}
+}
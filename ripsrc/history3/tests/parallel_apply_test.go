@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestParallelApplySameAsSequential(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	for i := 0; i < 20; i++ {
+		r.Write(fmt.Sprintf("f%v.txt", i), "a\nb\n")
+	}
+	r.Commit("c1")
+	for i := 0; i < 20; i++ {
+		r.Write(fmt.Sprintf("f%v.txt", i), "a\n"+strings.Repeat("x\n", i)+"b\n")
+	}
+	r.Delete("f0.txt").Rename("f1.txt", "g1.txt")
+	r.Commit("c2")
+
+	run := func(concurrency int) []process.Result {
+		res, err := process.New(process.Opts{
+			RepoDir:          r.Dir(),
+			CheckpointsDir:   t.TempDir(),
+			ApplyConcurrency: concurrency,
+		}).RunGetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	want := run(1)
+	got := run(4)
+	assertResult(t, want, got)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testutil"
+)
+
+func TestPatchesBasic1(t *testing.T) {
+	dirs := testutil.UnzipTestRepo("basic")
+	defer dirs.Remove()
+
+	ctx := context.Background()
+	err := gitexec.Prepare(ctx, gitCommand, dirs.RepoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := process.Opts{}
+	opts.RepoDir = dirs.RepoDir
+	_, err = process.New(opts).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := "b4dadc54e312e976694161c2ac59ab76feb0c40d"
+	c2 := "69ba50fff990c169f80de96674919033a0a9b66d"
+	p1 := "pr1-1"
+	p2 := "pr1-2"
+
+	patches := []process.Patch{
+		{
+			ID: p1,
+			Diff: []byte(`diff --git a/main.go b/main.go
+index 1671209..e8b1f1a 100644
+--- a/main.go
++++ b/main.go
+@@ -2,5 +2,5 @@ package main
+ 
+ func main() {
+-  // do nothing
++  // do something
+ }
+ 
+diff --git a/b.txt b/b.txt
+new file mode 100644
+index 0000000..6178079
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1 @@
++b
+`),
+		},
+		{
+			ID: p2,
+			Diff: []byte(`diff --git a/b.txt b/b.txt
+index 6178079..a3f0b5c 100644
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1,2 @@
+ b
++c
+`),
+		},
+	}
+
+	got, err := process.New(opts).RunPatches(c2, patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []process.Result{
+		{
+			Commit: p1,
+			Files: map[string]*incblame.Blame{
+				"main.go": file(p1,
+					line(`package main`, c1),
+					line(``, c1),
+					line(`func main() {`, c1),
+					line(`  // do something`, p1),
+					line(`}`, c1),
+					line(``, c1),
+				),
+				"b.txt": file(p1,
+					line(`b`, p1),
+				),
+			},
+		},
+		{
+			Commit: p2,
+			Files: map[string]*incblame.Blame{
+				"b.txt": file(p2,
+					line(`b`, p1),
+					line(`c`, p2),
+				),
+			},
+		},
+	}
+	assertResult(t, want, got)
+}
+
+func TestPatchesBaseNotInCheckpoint(t *testing.T) {
+	dirs := testutil.UnzipTestRepo("basic")
+	defer dirs.Remove()
+
+	ctx := context.Background()
+	err := gitexec.Prepare(ctx, gitCommand, dirs.RepoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := process.Opts{}
+	opts.RepoDir = dirs.RepoDir
+	_, err = process.New(opts).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = process.New(opts).RunPatches("xxx", nil)
+	if err == nil {
+		t.Fatal("expected error for missing base commit")
+	}
+}
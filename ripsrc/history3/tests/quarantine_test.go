@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestQuarantineMalformedDiff(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Write("b.txt", "b\n").Commit("c1")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-quarantine-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	opts := process.Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}
+	_, err = process.New(opts).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patches := []process.Patch{
+		{
+			ID: "p1",
+			// hunk refers to lines that do not exist in a.txt
+			Diff: []byte(`diff --git a/a.txt b/a.txt
+index 7898192..6178079 100644
+--- a/a.txt
++++ b/a.txt
+@@ -5,2 +5,2 @@
+ x
+-y
++z
+diff --git a/b.txt b/b.txt
+index 6178079..a3f0b5c 100644
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1,2 @@
+ b
++c
+`),
+		},
+		{
+			ID: "p2",
+			Diff: []byte(`diff --git a/a.txt b/a.txt
+index 7898192..6178079 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1,2 @@
+ a
++b
+`),
+		},
+	}
+	got, err := process.New(opts).RunPatches(c1, patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 results, got %v", len(got))
+	}
+
+	p1 := got[0]
+	if err := p1.Quarantined["a.txt"]; err == nil || !strings.Contains(err.Error(), "file has 1 lines") {
+		t.Errorf("expected a.txt to be quarantined, got %v", p1.Quarantined)
+	}
+	if !p1.Files["a.txt"].IsUnknown {
+		t.Errorf("expected unknown blame for a.txt, got %v", p1.Files["a.txt"])
+	}
+	if !file("p1", line("b", c1), line("c", "p1")).Eq(p1.Files["b.txt"]) {
+		t.Errorf("invalid blame for b.txt, got %v", p1.Files["b.txt"])
+	}
+
+	// stays unknown in the next commits without reporting the error again
+	p2 := got[1]
+	if !p2.Files["a.txt"].IsUnknown || len(p2.Quarantined) != 0 {
+		t.Errorf("expected a.txt to stay unknown, got %v %v", p2.Files["a.txt"], p2.Quarantined)
+	}
+}
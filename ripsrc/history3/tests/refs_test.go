@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+func TestRefs1(t *testing.T) {
+	test := NewTest(t, "multiple_branches")
+	got := test.Run(&process.Opts{Refs: []string{"b", "c"}})
+
+	c1 := "bdf8c8cfa9c027e58f1aea5c532ba0e9ef74bc4c"
+	c2 := "d3a93f475772c90918ebc34e144e1c3554163a9f"
+	c4 := "3f18a2ea07832a18d0645df2aa666b339cee1a06"
+
+	want := []process.Result{
+		{
+			Commit: c1,
+			Files: map[string]*incblame.Blame{
+				"a.txt": file(c1,
+					line(`a`, c1),
+				),
+			},
+		},
+		{
+			Commit: c2,
+			Files: map[string]*incblame.Blame{
+				"a.txt": file(c2,
+					line(`a`, c1),
+					line(`b`, c2),
+				),
+			},
+		},
+		{
+			Commit: c4,
+			Files: map[string]*incblame.Blame{
+				"a.txt": file(c4,
+					line(`a`, c1),
+					line(`c`, c4),
+				),
+			},
+		},
+	}
+	assertResult(t, want, got)
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestResumeInterrupted(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("b").Write("b.txt", "b\n").Commit("c2")
+	r.Checkout("master").Write("a.txt", "a\na2\n").Commit("c3")
+	r.Merge("m1", "b")
+	r.Write("b.txt", "b\nb2\n").Commit("c4")
+	r.Write("a.txt", "a\na2\na3\n").Commit("c5")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-resume-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+	partial := filepath.Join(checkpointsDir, "pp-git-cache", "partial")
+	backup := filepath.Join(checkpointsDir, "backup")
+
+	opts := process.Opts{
+		RepoDir:         r.Dir(),
+		CheckpointsDir:  checkpointsDir,
+		CheckpointEvery: 1,
+	}
+
+	// full run, saving intermediate checkpoint seen when 4th commit is returned to simulate crash
+	var full []process.Result
+	resChan := make(chan process.Result)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			full = append(full, r)
+			if len(full) == 4 {
+				copyDir(t, partial, backup)
+			}
+		}
+		done <- true
+	}()
+	err = process.New(opts).Run(resChan)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) != 6 {
+		t.Fatalf("expected 6 commits, got %v", len(full))
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Fatal("intermediate checkpoint should be removed after complete run")
+	}
+
+	// the last intermediate checkpoint before 4th result was after 3 commits
+	err = os.RemoveAll(filepath.Join(checkpointsDir, "pp-git-cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyDir(t, backup, partial)
+
+	opts.ResumeInterrupted = true
+	got, err := process.New(opts).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertResult(t, full[3:], got)
+}
+
+func copyDir(t *testing.T, from, to string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(to), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("cp", "-r", from, to).CombinedOutput()
+	if err != nil {
+		t.Fatalf("copy failed: %v %s", err, out)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSegmentsSameAsSequentialFixtures(t *testing.T) {
+	repos := []string{
+		"basic",
+		"basic_rename",
+		"bin_merge1",
+		"merge_basic",
+		"merge_basic2",
+		"merge_basic_crud_branch",
+		"merge_created_and_deleted_in_branch",
+		"merge_new_with_change",
+		"merge_same_changes",
+		"merge_unchanged",
+		"multiple_branches",
+	}
+	for _, name := range repos {
+		t.Run(name, func(t *testing.T) {
+			want := NewTest(t, name).Run(&process.Opts{AllBranches: true})
+			got := NewTest(t, name).Run(&process.Opts{AllBranches: true, SegmentConcurrency: 4})
+			assertResult(t, want, got)
+		})
+	}
+}
+
+func TestSegmentsSameAsSequentialBranchy(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	for b := 0; b < 4; b++ {
+		name := fmt.Sprintf("b%v", b)
+		r.Checkout("master").Branch(name)
+		for i := 0; i < 5; i++ {
+			r.Write(fmt.Sprintf("%v.txt", name), fmt.Sprintf("%v\n", i)).Commit(fmt.Sprintf("%v-%v", name, i))
+		}
+	}
+	r.Checkout("master")
+	for b := 0; b < 4; b++ {
+		r.Merge(fmt.Sprintf("m%v", b), fmt.Sprintf("b%v", b))
+	}
+	r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	run := func(concurrency int) []process.Result {
+		res, err := process.New(process.Opts{
+			RepoDir:            r.Dir(),
+			CheckpointsDir:     t.TempDir(),
+			AllBranches:        true,
+			SegmentConcurrency: concurrency,
+		}).RunGetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	want := run(0)
+	got := run(4)
+	if len(want) != 26 {
+		t.Fatalf("expected 26 commits, got %v", len(want))
+	}
+	assertResult(t, want, got)
+}
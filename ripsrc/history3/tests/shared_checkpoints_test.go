@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSharedCheckpoints(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	tempDir := func() string {
+		dir, err := ioutil.TempDir("", "ripsrc-shared-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	upstreamDir := tempDir()
+	defer os.RemoveAll(upstreamDir)
+	forkDir := tempDir()
+	defer os.RemoveAll(forkDir)
+	sharedDir := tempDir()
+	defer os.RemoveAll(sharedDir)
+
+	// upstream run stores checkpoint for c2 in shared dir
+	_, err := process.New(process.Opts{
+		RepoDir:              r.Dir(),
+		CheckpointsDir:       upstreamDir,
+		SharedCheckpointsDir: sharedDir,
+	}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fork has the same history with additional commit
+	r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	fullDir := tempDir()
+	defer os.RemoveAll(fullDir)
+	full, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: fullDir}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = process.New(process.Opts{
+		RepoDir:        r.Dir(),
+		CheckpointsDir: forkDir,
+		CommitFromIncl: c2,
+	}).RunGetAll()
+	if err == nil {
+		t.Fatal("expected error without checkpoint")
+	}
+
+	got, err := process.New(process.Opts{
+		RepoDir:              r.Dir(),
+		CheckpointsDir:       forkDir,
+		SharedCheckpointsDir: sharedDir,
+		CommitFromIncl:       c2,
+	}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertResult(t, full[1:], got)
+}
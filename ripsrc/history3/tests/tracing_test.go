@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
+)
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (s *recordingTracer) Start(ctx context.Context, name string, attrs ...string) (context.Context, tracing.Span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := &recordingSpan{Name: name, Attrs: map[string]string{}}
+	sp.SetAttributes(attrs...)
+	s.spans = append(s.spans, sp)
+	return ctx, sp
+}
+
+type recordingSpan struct {
+	Name  string
+	Attrs map[string]string
+	Ended bool
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...string) {
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.Attrs[attrs[i]] = attrs[i+1]
+	}
+}
+
+func (s *recordingSpan) RecordError(err error) {}
+
+func (s *recordingSpan) End() {
+	s.Ended = true
+}
+
+func TestTracingBasic1(t *testing.T) {
+	tracer := &recordingTracer{}
+	test := NewTest(t, "basic")
+	test.Run(&process.Opts{Tracer: tracer})
+
+	var names []string
+	for _, sp := range tracer.spans {
+		if !sp.Ended {
+			t.Fatalf("span not ended %v", sp.Name)
+		}
+		names = append(names, sp.Name)
+	}
+	want := []string{tracing.SpanCommitGraph, tracing.SpanBlameBatch, tracing.SpanCheckpointWrite}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wanted spans %v, got %v", want, names)
+	}
+	batch := tracer.spans[1].Attrs
+	if batch["commits"] != "2" || batch["first_commit"] != "b4dadc54e312e976694161c2ac59ab76feb0c40d" || batch["last_commit"] != "69ba50fff990c169f80de96674919033a0a9b66d" {
+		t.Fatalf("unexpected batch attributes %v", batch)
+	}
+}
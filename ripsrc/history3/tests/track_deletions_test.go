@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestTrackDeletions(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "1\n2\n3\n").Write("b.txt", "b\n").Commit("c1")
+	c2 := r.Write("a.txt", "1\nx\n3\n4\n").Commit("c2")
+	r.Write("a.txt", "1\n").Delete("b.txt").Commit("c3")
+
+	got, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: r.Dir(), TrackDeletions: true}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]map[string]int{
+		nil,
+		{"a.txt": {c1: 1}},
+		{"a.txt": {c1: 1, c2: 2}, "b.txt": {c1: 1}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wanted %v results, got %v", len(want), len(got))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i].Deleted, want[i]) {
+			t.Errorf("commit %v wanted deleted %v got %v", i, want[i], got[i].Deleted)
+		}
+	}
+
+	got, err = process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: r.Dir(), ForceFullReprocess: true}).RunGetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range got {
+		if res.Deleted != nil {
+			t.Fatal("deleted should only be set with TrackDeletions")
+		}
+	}
+}
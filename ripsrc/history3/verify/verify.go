@@ -0,0 +1,149 @@
+// Package verify compares incremental blame results with git blame, so that divergences of incblame from git are caught.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitblame2"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// Reason is the kind of divergence between incremental blame and git blame.
+type Reason string
+
+const (
+	// ReasonLineCount is returned when the number of lines differs. Got and Want are the numbers of lines.
+	ReasonLineCount = Reason("line_count")
+	// ReasonCommit is returned when the line is attributed to a different commit. Got and Want are commit hashes.
+	ReasonCommit = Reason("commit")
+	// ReasonContent is returned when the content of the line differs. Got and Want are line contents.
+	ReasonContent = Reason("content")
+)
+
+// Divergence is a difference between incremental blame and git blame of a file.
+type Divergence struct {
+	Commit string
+	File   string
+	Reason Reason
+	// Line is 1-based line number. 0 for ReasonLineCount.
+	Line int
+	// Got is the value from incremental blame.
+	Got string
+	// Want is the value from git blame.
+	Want string
+}
+
+func (s Divergence) String() string {
+	return fmt.Sprintf("commit: %v file: %v line: %v %v got: %q want: %q", s.Commit, s.File, s.Line, s.Reason, s.Got, s.Want)
+}
+
+type Opts struct {
+	// RepoDir is location of git repo.
+	RepoDir string
+	// SampleRate is the fraction of (commit, file) results checked, from 0 to 1. Default is 1, checking all files.
+	SampleRate float64
+	// Seed changes which files are sampled. Sampling is deterministic for the same seed, so that rerun checks the same files.
+	Seed int64
+	// Strict reports lines attributed to different commits even if they have short content, such as empty lines or braces.
+	// Without it these are ignored, since git blame and incremental blame could attribute repeating lines to different, but equally valid commits.
+	Strict bool
+}
+
+// Verifier checks sampled results of history3 process with git blame. Safe for concurrent use.
+type Verifier struct {
+	opts Opts
+}
+
+// New creates a verifier.
+func New(opts Opts) *Verifier {
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	s := &Verifier{}
+	s.opts = opts
+	return s
+}
+
+// Sample returns true if file at commit should be checked.
+func (s *Verifier) Sample(commit, file string) bool {
+	if s.opts.SampleRate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(s.opts.Seed, 10)))
+	h.Write([]byte(commit))
+	h.Write([]byte(file))
+	return float64(h.Sum64()%1e6)/1e6 < s.opts.SampleRate
+}
+
+// CheckResult checks sampled files of a commit result. Removed, binary and unknown files are not checked.
+func (s *Verifier) CheckResult(ctx context.Context, res process.Result) (all []Divergence, _ error) {
+	var files []string
+	for p := range res.Files {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	for _, p := range files {
+		if !s.Sample(res.Commit, p) {
+			continue
+		}
+		div, err := s.CheckFile(ctx, res.Commit, p, res.Files[p])
+		if err != nil {
+			return all, err
+		}
+		all = append(all, div...)
+	}
+	return all, nil
+}
+
+// CheckFile runs git blame for file at commit and compares it with bl. Removed, binary and unknown files are not checked.
+func (s *Verifier) CheckFile(ctx context.Context, commit, file string, bl *incblame.Blame) ([]Divergence, error) {
+	if bl == nil || bl.IsBinary || bl.IsUnknown || len(bl.Lines) == 0 {
+		// removed files have no lines and no git blame
+		return nil, nil
+	}
+	want, err := gitblame2.RunContext(ctx, s.opts.RepoDir, commit, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not run git blame, commit: %v file: %v err: %v", commit, file, err)
+	}
+	return Compare(commit, file, bl, want, s.opts.Strict), nil
+}
+
+// Compare returns differences between incremental blame and git blame of the same file. See Opts.Strict.
+func Compare(commit, file string, got *incblame.Blame, want gitblame2.Result, strict bool) (res []Divergence) {
+	if len(got.Lines) != len(want.Lines) {
+		return []Divergence{{
+			Commit: commit,
+			File:   file,
+			Reason: ReasonLineCount,
+			Got:    strconv.Itoa(len(got.Lines)),
+			Want:   strconv.Itoa(len(want.Lines)),
+		}}
+	}
+	for i := range got.Lines {
+		l1 := got.Lines[i]
+		l2 := want.Lines[i]
+		// TODO: looks like currently we convert newlines to linux style, even if it was different in source
+		c1 := strings.TrimSpace(string(l1.Line))
+		c2 := strings.TrimSpace(l2.Content)
+		if c1 != c2 {
+			res = append(res, Divergence{Commit: commit, File: file, Reason: ReasonContent, Line: i + 1, Got: c1, Want: c2})
+			continue
+		}
+		if l1.Commit != l2.CommitHash && (strict || !ambiguousLine(c1)) {
+			res = append(res, Divergence{Commit: commit, File: file, Reason: ReasonCommit, Line: i + 1, Got: l1.Commit, Want: l2.CommitHash})
+		}
+	}
+	return
+}
+
+// ambiguousLine returns true for short lines, which are often repeated in a file. Both blames are valid when these are attributed to different commits.
+func ambiguousLine(content string) bool {
+	return len(content) <= 10
+}
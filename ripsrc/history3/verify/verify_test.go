@@ -0,0 +1,213 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitblame2"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestCompare(t *testing.T) {
+	got := &incblame.Blame{Lines: incblame.Lines{
+		{Line: []byte("package main"), Commit: "c1"},
+		{Line: []byte("}"), Commit: "c1"},
+		{Line: []byte("func main() {"), Commit: "c2"},
+		{Line: []byte("a"), Commit: "c2"},
+	}}
+	want := gitblame2.Result{Lines: []gitblame2.Line{
+		{Content: "package main", CommitHash: "c1"},
+		{Content: "}", CommitHash: "c2"},
+		{Content: "func main() {", CommitHash: "c1"},
+		{Content: "b", CommitHash: "c2"},
+	}}
+	res := Compare("c3", "main.go", got, want, false)
+	if len(res) != 2 || res[0].Reason != ReasonCommit || res[0].Line != 3 || res[0].Got != "c2" || res[0].Want != "c1" || res[1].Reason != ReasonContent || res[1].Line != 4 {
+		t.Errorf("unexpected divergences %v", res)
+	}
+	res = Compare("c3", "main.go", got, want, true)
+	if len(res) != 3 || res[0].Line != 2 {
+		t.Errorf("strict should report short lines %v", res)
+	}
+	want.Lines = want.Lines[:3]
+	res = Compare("c3", "main.go", got, want, false)
+	if len(res) != 1 || res[0].Reason != ReasonLineCount || res[0].Got != "4" || res[0].Want != "3" {
+		t.Errorf("unexpected divergences %v", res)
+	}
+}
+
+func TestSample(t *testing.T) {
+	v := New(Opts{SampleRate: 0.2, Seed: 1})
+	n := 0
+	for i := 0; i < 1000; i++ {
+		if v.Sample("c1", fmt.Sprint(i)) {
+			n++
+		}
+		if v.Sample("c1", fmt.Sprint(i)) != New(Opts{SampleRate: 0.2, Seed: 1}).Sample("c1", fmt.Sprint(i)) {
+			t.Fatal("sampling should be deterministic")
+		}
+	}
+	if n < 150 || n > 250 {
+		t.Errorf("wanted around 200 sampled files, got %v", n)
+	}
+	if !New(Opts{}).Sample("c1", "a") {
+		t.Error("all files should be sampled by default")
+	}
+}
+
+// randomRepo builds a repo with random edits, renames, branches and merges. Lines are unique, so that attribution is not ambiguous.
+type randomRepo struct {
+	r     *testkit.Repo
+	rand  *rand.Rand
+	files map[string][]string
+	next  int
+}
+
+func (s *randomRepo) line() string {
+	s.next++
+	return fmt.Sprintf("line %v", s.next)
+}
+
+func (s *randomRepo) paths() (res []string) {
+	for p := range s.files {
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return
+}
+
+func (s *randomRepo) edit(p string) {
+	lines := append([]string{}, s.files[p]...)
+	for n := 1 + s.rand.Intn(3); n > 0; n-- {
+		i := s.rand.Intn(len(lines) + 1)
+		switch s.rand.Intn(3) {
+		case 0:
+			lines = append(lines[:i], append([]string{s.line()}, lines[i:]...)...)
+		case 1:
+			if i < len(lines) && len(lines) > 1 {
+				lines = append(lines[:i], lines[i+1:]...)
+			}
+		case 2:
+			if i < len(lines) {
+				lines[i] = s.line()
+			}
+		}
+	}
+	s.files[p] = lines
+	s.r.Write(p, strings.Join(lines, "\n")+"\n")
+}
+
+// readFiles syncs the state after checkout or merge.
+func (s *randomRepo) readFiles() {
+	s.files = map[string][]string{}
+	for _, p := range strings.Split(s.r.Git("ls-files"), "\n") {
+		s.files[p] = strings.Split(strings.TrimSuffix(s.r.Git("show", "HEAD:"+p), "\n"), "\n")
+	}
+}
+
+// commit makes a random change. Renames are only done before branching, since renamed file could be detected as removed when merging a branch with many changes to it.
+func (s *randomRepo) commit(i int, rename bool) {
+	paths := s.paths()
+	switch {
+	case s.rand.Intn(10) == 0:
+		p := fmt.Sprintf("f%v.txt", s.next)
+		s.files[p] = []string{s.line(), s.line()}
+		s.r.Write(p, strings.Join(s.files[p], "\n")+"\n")
+	case rename && s.rand.Intn(5) == 0:
+		from := paths[s.rand.Intn(len(paths))]
+		to := "renamed-" + from
+		s.r.Rename(from, to)
+		s.files[to] = s.files[from]
+		delete(s.files, from)
+	default:
+		for _, p := range paths {
+			if s.rand.Intn(2) == 0 {
+				s.edit(p)
+			}
+		}
+	}
+	s.r.Commit(fmt.Sprintf("c%v", i))
+}
+
+func buildRandomRepo(t *testing.T, seed int64, commits int) *testkit.Repo {
+	s := &randomRepo{}
+	s.r = testkit.New(t)
+	s.rand = rand.New(rand.NewSource(seed))
+	s.files = map[string][]string{}
+	for i := 0; i < 3; i++ {
+		p := fmt.Sprintf("f%v.txt", i)
+		for j := 0; j < 10; j++ {
+			s.files[p] = append(s.files[p], s.line())
+		}
+		s.r.Write(p, strings.Join(s.files[p], "\n")+"\n")
+	}
+	s.r.Commit("initial")
+	branches := 0
+	for i := 0; i < commits; i++ {
+		switch s.rand.Intn(8) {
+		case 0:
+			branches++
+			s.r.Branch(fmt.Sprintf("b%v", branches))
+			s.readFiles()
+		case 1:
+			if branches == 0 {
+				break
+			}
+			target := fmt.Sprintf("b%v", 1+s.rand.Intn(branches))
+			s.r.Checkout("master")
+			// conflicting changes are resolved using master, so merge has changes compared to the branch
+			s.r.Git("merge", "-q", "--no-ff", "--no-edit", "-X", "ours", target)
+			s.readFiles()
+		case 2:
+			if branches == 0 {
+				break
+			}
+			s.r.Checkout(fmt.Sprintf("b%v", 1+s.rand.Intn(branches)))
+			s.readFiles()
+		}
+		s.commit(i, branches == 0)
+	}
+	s.r.Checkout("master")
+	return s.r
+}
+
+// TestMatchesGitBlame checks incremental blame of all files in all commits of random repos against git blame.
+func TestMatchesGitBlame(t *testing.T) {
+	seeds := 8
+	commits := 40
+	if testing.Short() {
+		seeds = 2
+	}
+	ctx := context.Background()
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			r := buildRandomRepo(t, seed, commits)
+			defer r.Remove()
+			got, err := process.New(process.Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir(), AllBranches: true}).RunGetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := New(Opts{RepoDir: r.Dir(), Strict: true})
+			checked := 0
+			for _, res := range got {
+				div, err := v.CheckResult(ctx, res)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, d := range div {
+					t.Error(d)
+				}
+				checked += len(res.Files)
+			}
+			if checked == 0 {
+				t.Fatal("no files checked")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// HistoryRewrite selects what CodeByCommit does when history was rewritten since the checkpoint. See Opts.OnHistoryRewrite.
+type HistoryRewrite string
+
+const (
+	// HistoryRewriteFail returns *HistoryRewrittenError. This is the default.
+	HistoryRewriteFail = HistoryRewrite("")
+	// HistoryRewriteReprocess processes all commits from scratch, same as ForceFullReprocess.
+	HistoryRewriteReprocess = HistoryRewrite("reprocess")
+)
+
+func (s HistoryRewrite) valid() bool {
+	switch s {
+	case HistoryRewriteFail, HistoryRewriteReprocess:
+		return true
+	}
+	return false
+}
+
+// HistoryRewrittenError is returned from CodeByCommit when CommitFromIncl is not reachable from processed refs, so that incremental processing from the checkpoint would fail or return wrong blame.
+// Happens when branches were force-pushed or rewritten, for example using filter-branch, or when replace refs or grafts, which git commands honor, changed since the checkpoint was written.
+// Use errors.As to get it. Set Opts.OnHistoryRewrite to HistoryRewriteReprocess to process the repo from scratch instead.
+type HistoryRewrittenError struct {
+	// Commit is CommitFromIncl.
+	Commit string
+	// Missing is true if Commit no longer exists in the repo, false if it exists but is not reachable from processed refs.
+	Missing bool
+}
+
+func (s *HistoryRewrittenError) Error() string {
+	if s.Missing {
+		return fmt.Sprintf("ripsrc: history was rewritten, CommitFromIncl %v does not exist in repo", s.Commit)
+	}
+	return fmt.Sprintf("ripsrc: history was rewritten, CommitFromIncl %v is not reachable from processed refs", s.Commit)
+}
+
+// checkHistoryRewrite checks that CommitFromIncl is an ancestor of at least one processed ref. Applies Opts.OnHistoryRewrite if not.
+// Called before prepareGitExec, since validation fails when CommitFromIncl no longer exists.
+func (s *Ripsrc) checkHistoryRewrite(ctx context.Context) error {
+	commit := s.opts.CommitFromIncl
+	if commit == "" {
+		return nil
+	}
+	if _, err := gitexec.FindGitDir(s.opts.RepoDir); err != nil {
+		// reported by validation
+		return nil
+	}
+	rewritten := &HistoryRewrittenError{Commit: commit}
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"cat-file", "-e", commit + "^{commit}"})
+	if err != nil {
+		rewritten.Missing = true
+	} else {
+		ok, err := s.reachableFromRefs(ctx, commit)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	if s.opts.OnHistoryRewrite != HistoryRewriteReprocess {
+		return rewritten
+	}
+	s.opts.Logger.Warn("history was rewritten since the checkpoint, reprocessing all commits", "commit", commit, "missing", rewritten.Missing)
+	s.opts.ForceFullReprocess = true
+	s.opts.CommitFromIncl = ""
+	s.opts.CommitFromMakeNonIncl = false
+	s.opts.ResumeInterrupted = false
+	return nil
+}
+
+// reachableFromRefs returns true if commit is an ancestor of Opts.Refs, any ref with AllBranches, or HEAD otherwise.
+func (s *Ripsrc) reachableFromRefs(ctx context.Context, commit string) (bool, error) {
+	if len(s.opts.Refs) == 0 && s.opts.AllBranches {
+		out := bytes.NewBuffer(nil)
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"for-each-ref", "--count=1", "--contains", commit, "--format=%(refname)"})
+		if err != nil {
+			return false, err
+		}
+		return len(bytes.TrimSpace(out.Bytes())) != 0, nil
+	}
+	refs := s.opts.Refs
+	if len(refs) == 0 {
+		refs = []string{"HEAD"}
+	}
+	for _, ref := range refs {
+		out := bytes.NewBuffer(nil)
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"merge-base", "--is-ancestor", commit, ref})
+		if err == nil {
+			return true, nil
+		}
+		// exit code 1 means not an ancestor
+		if gitexec.ExitCode(err) != 1 {
+			return false, err
+		}
+	}
+	return false, nil
+}
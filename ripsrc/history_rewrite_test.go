@@ -0,0 +1,64 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestHistoryRewrite(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a2\n").Commit("c2")
+	checkpoints := r.Dir() + "-checkpoints"
+	defer os.RemoveAll(checkpoints)
+
+	run := func(opts Opts) ([]BlameResult, error) {
+		opts.RepoDir = r.Dir()
+		opts.CheckpointsDir = checkpoints
+		return New(opts).CodeSlice(context.Background())
+	}
+	if _, err := run(Opts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// force-push equivalent, c2 is replaced
+	r.Git("reset", "-q", "--hard", c1)
+	c3 := r.Write("a.txt", "a3\n").Commit("c3")
+
+	_, err := run(Opts{CommitFromIncl: c2, CommitFromMakeNonIncl: true})
+	var rewritten *HistoryRewrittenError
+	if !errors.As(err, &rewritten) || rewritten.Commit != c2 || rewritten.Missing {
+		t.Fatalf("expected HistoryRewrittenError, got %v", err)
+	}
+
+	_, err = run(Opts{CommitFromIncl: c2, CommitFromMakeNonIncl: true, AllBranches: true})
+	if !errors.As(err, &rewritten) {
+		t.Fatalf("expected HistoryRewrittenError with AllBranches, got %v", err)
+	}
+
+	_, err = run(Opts{CommitFromIncl: strings.Repeat("1", 40), CommitFromMakeNonIncl: true})
+	if !errors.As(err, &rewritten) || !rewritten.Missing {
+		t.Fatalf("expected HistoryRewrittenError for missing commit, got %v", err)
+	}
+
+	res, err := run(Opts{CommitFromIncl: c2, CommitFromMakeNonIncl: true, OnHistoryRewrite: HistoryRewriteReprocess})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Commit.SHA != c1 || res[1].Commit.SHA != c3 {
+		t.Fatalf("expected all commits to be reprocessed, got %v", len(res))
+	}
+
+	// incremental run from the new checkpoint is not affected
+	c4 := r.Write("a.txt", "a4\n").Commit("c4")
+	res, err = run(Opts{CommitFromIncl: c3, CommitFromMakeNonIncl: true})
+	if err != nil || len(res) != 1 || res[0].Commit.SHA != c4 {
+		t.Fatalf("unexpected incremental result %v %v", len(res), err)
+	}
+}
@@ -0,0 +1,43 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestTrackHunks(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c1")
+	r.Write("a.go", "package a\n\nfunc a() { a() }\n\nfunc b() {}\n").Commit("c2")
+	r.Delete("a.go").Commit("c3")
+
+	res, err := New(Opts{RepoDir: r.Dir(), TrackHunks: true}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results, got %v", len(res))
+	}
+	want := [][]Hunk{
+		{{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 3, Added: 3}},
+		{{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 5, Added: 3, Deleted: 1}},
+		{{OldStart: 1, OldLines: 5, NewStart: 0, NewLines: 0, Deleted: 5}},
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(res[i].Hunks, w) {
+			t.Errorf("commit %v wanted hunks %+v, got %+v", i+1, w, res[i].Hunks)
+		}
+	}
+
+	res, err = New(Opts{RepoDir: r.Dir()}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[1].Hunks != nil {
+		t.Errorf("hunks should only be set with TrackHunks, got %+v", res[1].Hunks)
+	}
+}
@@ -0,0 +1,54 @@
+package ripsrc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestIncludePatches(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c1")
+	r.Write("a.go", "package a\n\nfunc a() { a() }\n").Commit("c2")
+	r.Delete("a.go").Commit("c3")
+
+	res, err := New(Opts{RepoDir: r.Dir(), IncludePatches: true}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results, got %v", len(res))
+	}
+	want := []string{
+		"+func a() {}\n",
+		"-func a() {}\n+func a() { a() }\n",
+		"-func a() { a() }\n",
+	}
+	for i, w := range want {
+		p := res[i].Patch
+		if !strings.HasPrefix(p, "diff --git a/a.go b/a.go\n") || !strings.HasSuffix(p, w) || res[i].PatchTruncated {
+			t.Errorf("commit %v unexpected patch %q", i+1, p)
+		}
+	}
+
+	full := res[1].Patch
+	res, err = New(Opts{RepoDir: r.Dir(), IncludePatches: true, MaxPatchSize: len(full) - 1}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPatch := strings.TrimSuffix(full, "+func a() { a() }\n")
+	if res[1].Patch != wantPatch || !res[1].PatchTruncated {
+		t.Errorf("expected patch cut at the last full line, got %q truncated %v", res[1].Patch, res[1].PatchTruncated)
+	}
+
+	res, err = New(Opts{RepoDir: r.Dir()}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[1].Patch != "" {
+		t.Errorf("patch should only be set with IncludePatches, got %q", res[1].Patch)
+	}
+}
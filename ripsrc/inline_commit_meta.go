@@ -0,0 +1,29 @@
+package ripsrc
+
+// InlineCommitMeta selects which commit metadata is set in BlameResult.Commit. See Opts.InlineCommitMeta.
+type InlineCommitMeta string
+
+const (
+	// InlineCommitMetaDefault sets the same commit as CommitCode.Commit, with trailers and signature only if enabled using Opts.CommitTrailers and Opts.CommitSignatures. This is the default.
+	InlineCommitMetaDefault = InlineCommitMeta("")
+	// InlineCommitMetaFull sets the full commit record, including message, parents, file stats and trailers, such as Signed-off-by, so that each result could be used without joining against commits. Trailers are parsed even if Opts.CommitTrailers is not set.
+	InlineCommitMetaFull = InlineCommitMeta("full")
+	// InlineCommitMetaRef sets only Commit.SHA as a reference to CommitCode.Commit, so that the commit is not repeated in each result, for example when writing results with NewJSONLSink.
+	InlineCommitMetaRef = InlineCommitMeta("ref")
+)
+
+func (s InlineCommitMeta) valid() bool {
+	switch s {
+	case InlineCommitMetaDefault, InlineCommitMetaFull, InlineCommitMetaRef:
+		return true
+	}
+	return false
+}
+
+// inlineCommit returns the commit set in BlameResult.Commit according to Opts.InlineCommitMeta.
+func (s *Ripsrc) inlineCommit(c Commit) Commit {
+	if s.opts.InlineCommitMeta == InlineCommitMetaRef {
+		return Commit{SHA: c.SHA}
+	}
+	return c
+}
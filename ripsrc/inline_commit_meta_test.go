@@ -0,0 +1,49 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestInlineCommitMeta(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.go", "package a\n").Commit("c1")
+	c2 := r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c2\n\nSigned-off-by: Jane Doe <jane@example.com>")
+
+	run := func(inline InlineCommitMeta) []BlameResult {
+		t.Helper()
+		res, err := New(Opts{RepoDir: r.Dir(), InlineCommitMeta: inline}).CodeSlice(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 || res[1].Commit.SHA != c2 {
+			t.Fatalf("unexpected results %+v", res)
+		}
+		return res
+	}
+
+	res := run(InlineCommitMetaDefault)
+	if c := res[1].Commit; c.Message == "" || len(c.Parents) != 1 || len(c.Trailers) != 0 {
+		t.Errorf("default should set commit without trailers, got %+v", c)
+	}
+
+	res = run(InlineCommitMetaFull)
+	c := res[1].Commit
+	if c.Message == "" || len(c.Parents) != 1 || c.Parents[0] != c1 || c.Files["a.go"] == nil || c.AuthorEmail == "" {
+		t.Errorf("expected full commit, got %+v", c)
+	}
+	if so := c.Trailer("Signed-off-by"); len(so) != 1 || so[0] != "Jane Doe <jane@example.com>" {
+		t.Errorf("expected signed-off-by trailer, got %v", c.Trailers)
+	}
+
+	res = run(InlineCommitMetaRef)
+	if c := res[1].Commit; c.Message != "" || c.Parents != nil || c.Files != nil || !c.Date.IsZero() {
+		t.Errorf("expected only sha, got %+v", c)
+	}
+	if res[1].Status != GitFileCommitStatusModified || len(res[1].Lines) != 3 {
+		t.Errorf("file results should not change, got %+v", res[1])
+	}
+}
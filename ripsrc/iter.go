@@ -0,0 +1,187 @@
+package ripsrc
+
+import "context"
+
+// BlameIter is a pull-based iterator over results of Code. Use as an alternative to channels when early termination or error handling matters:
+//
+//	it := r.BlameIter(ctx)
+//	defer it.Close()
+//	for it.Next() {
+//		use(it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// With Go 1.23 or later, All can be used with range: for res := range it.All() { ... }.
+type BlameIter struct {
+	iterRun
+	res   chan BlameResult
+	value BlameResult
+}
+
+// BlameIter starts Code and returns iterator over its results. Close must be called if iteration is stopped before Next returns false.
+func (s *Ripsrc) BlameIter(ctx context.Context) *BlameIter {
+	it := &BlameIter{}
+	it.res = make(chan BlameResult)
+	it.start(ctx, func(ctx context.Context) error {
+		return s.Code(ctx, it.res)
+	})
+	return it
+}
+
+// Next advances to the next result. Returns false when there are no more results or processing failed, check Err after that.
+func (s *BlameIter) Next() bool {
+	v, ok := <-s.res
+	if !ok {
+		s.wait()
+		return false
+	}
+	s.value = v
+	return true
+}
+
+// Value returns the current result.
+func (s *BlameIter) Value() BlameResult {
+	return s.value
+}
+
+// Close stops processing and releases resources. Safe to call multiple times and after iteration is finished.
+func (s *BlameIter) Close() error {
+	s.stop()
+	for range s.res {
+	}
+	s.wait()
+	return s.Err()
+}
+
+// All returns range-over-func iterator, compatible with iter.Seq in Go 1.23. Processing is stopped if the loop exits early. Check Err after the loop.
+func (s *BlameIter) All() func(yield func(BlameResult) bool) {
+	return func(yield func(BlameResult) bool) {
+		defer s.Close()
+		for s.Next() {
+			if !yield(s.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// CommitIter is a pull-based iterator over results of CodeByCommit. See BlameIter for usage.
+//
+// Blames of the current commit could be read from Value().Blames before calling Next, blames that were not read are skipped.
+type CommitIter struct {
+	iterRun
+	res     chan CommitCode
+	value   CommitCode
+	hasPrev bool
+}
+
+// CommitIter starts CodeByCommit and returns iterator over its results. Close must be called if iteration is stopped before Next returns false.
+func (s *Ripsrc) CommitIter(ctx context.Context) *CommitIter {
+	it := &CommitIter{}
+	it.res = make(chan CommitCode)
+	it.start(ctx, func(ctx context.Context) error {
+		return s.CodeByCommit(ctx, it.res)
+	})
+	return it
+}
+
+// Next advances to the next commit. Returns false when there are no more commits or processing failed, check Err after that.
+func (s *CommitIter) Next() bool {
+	s.skipBlames()
+	v, ok := <-s.res
+	if !ok {
+		s.wait()
+		return false
+	}
+	s.value = v
+	s.hasPrev = true
+	return true
+}
+
+// skipBlames reads remaining blames of the current commit, CodeByCommit does not continue until they are read.
+func (s *CommitIter) skipBlames() {
+	if !s.hasPrev {
+		return
+	}
+	for range s.value.Blames {
+	}
+	s.hasPrev = false
+}
+
+// Value returns the current commit.
+func (s *CommitIter) Value() CommitCode {
+	return s.value
+}
+
+// Close stops processing and releases resources. Safe to call multiple times and after iteration is finished.
+func (s *CommitIter) Close() error {
+	s.stop()
+	s.skipBlames()
+	for c := range s.res {
+		for range c.Blames {
+		}
+	}
+	s.wait()
+	return s.Err()
+}
+
+// All returns range-over-func iterator, compatible with iter.Seq in Go 1.23. Processing is stopped if the loop exits early. Check Err after the loop.
+func (s *CommitIter) All() func(yield func(CommitCode) bool) {
+	return func(yield func(CommitCode) bool) {
+		defer s.Close()
+		for s.Next() {
+			if !yield(s.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// iterRun runs the producer in background and keeps its error.
+type iterRun struct {
+	cancel   context.CancelFunc
+	done     chan bool
+	err      error
+	canceled bool
+}
+
+func (s *iterRun) start(ctx context.Context, run func(ctx context.Context) error) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan bool)
+	go func() {
+		defer close(s.done)
+		defer s.cancel()
+		s.err = run(ctx)
+	}()
+}
+
+// stop cancels processing, unless it already finished. Errors of stopped processing are not returned from Err, since they are caused by cancelation.
+func (s *iterRun) stop() {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	s.canceled = true
+	s.cancel()
+}
+
+// wait waits for producer to exit. Only call after the result channel is closed.
+func (s *iterRun) wait() {
+	<-s.done
+}
+
+// Err returns the error that stopped processing. Returns nil if processing is still running or was stopped by Close.
+func (s *iterRun) Err() error {
+	select {
+	case <-s.done:
+	default:
+		return nil
+	}
+	if s.canceled {
+		return nil
+	}
+	return s.err
+}
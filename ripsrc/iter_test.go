@@ -0,0 +1,67 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestBlameIter(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Write("b.txt", "b\n").Commit("c2")
+
+	it := New(Opts{RepoDir: r.Dir()}).BlameIter(context.Background())
+	defer it.Close()
+	var got []string
+	for it.Next() {
+		got = append(got, it.Value().Commit.SHA+" "+it.Value().Filename)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got[1:])
+	want := []string{c1 + " a.txt", c2 + " a.txt", c2 + " b.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCommitIterEarlyStop(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("a.txt", "a\nb\n").Commit("c2")
+	r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+
+	it := New(Opts{RepoDir: r.Dir()}).CommitIter(context.Background())
+	var got []string
+	it.All()(func(c CommitCode) bool {
+		got = append(got, c.SHA)
+		return false
+	})
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{c1}) {
+		t.Fatalf("expected to stop after first commit, got %v", got)
+	}
+	if it.Next() {
+		t.Fatal("expected no more commits after Close")
+	}
+}
+
+func TestCommitIterErr(t *testing.T) {
+	it := New(Opts{RepoDir: t.TempDir()}).CommitIter(context.Background())
+	defer it.Close()
+	if it.Next() {
+		t.Fatal("expected no commits")
+	}
+	if it.Err() == nil {
+		t.Fatal("expected error for dir that is not a repo")
+	}
+}
@@ -0,0 +1,46 @@
+package ripsrc
+
+import (
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// PartialError is returned from CodeByCommit when processing stopped early because of Opts.MaxDuration or Opts.MaxMemoryBytes.
+// Commits up to PartialError.LastCommit were returned and checkpointed. Run again with ResumeInterrupted and the same options to continue.
+type PartialError = process.PartialError
+
+// ErrLimitReached is wrapped by PartialError, use errors.Is(err, ErrLimitReached) to check for partial completion.
+var ErrLimitReached = process.ErrLimitReached
+
+// LimitReason is the limit that stopped processing.
+type LimitReason = process.LimitReason
+
+const (
+	// LimitDuration means processing took longer than Opts.MaxDuration.
+	LimitDuration = process.LimitDuration
+	// LimitMemory means heap allocation exceeded Opts.MaxMemoryBytes.
+	LimitMemory = process.LimitMemory
+)
+
+// maxDurationLeft returns MaxDuration minus time already spent since started, for example building the commit graph. Returns the smallest positive duration if the time is already used up, so that processing stops after the first commit.
+func (s *Ripsrc) maxDurationLeft(started time.Time) time.Duration {
+	if s.opts.MaxDuration == 0 {
+		return 0
+	}
+	res := s.opts.MaxDuration - time.Since(started)
+	if res <= 0 {
+		return 1
+	}
+	return res
+}
+
+// countDeadlineSkipped returns the number of files skipped because of Opts.CommitDeadline.
+func countDeadlineSkipped(r process.Result) (res int) {
+	for _, err := range r.Quarantined {
+		if err == process.ErrCommitDeadline {
+			res++
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestMaxMemoryBytesStopsAndResumes(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+	c3 := r.Write("b.txt", "b\n").Commit("c3")
+
+	checkpoints, err := ioutil.TempDir("", "ripsrc-limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpoints)
+
+	opts := Opts{RepoDir: r.Dir(), CheckpointsDir: checkpoints, MaxMemoryBytes: 1}
+	commits, _, err := codeByCommit(opts)
+	if !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached, got %v", err)
+	}
+	var perr *PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *PartialError, got %T", err)
+	}
+	if perr.Reason != LimitMemory || perr.LastCommit != c1 || perr.Commits != 1 {
+		t.Fatalf("unexpected partial result %+v", perr)
+	}
+	if got := shas(commits); !reflect.DeepEqual(got, []string{c1}) {
+		t.Fatalf("expected only the first commit, got %v", got)
+	}
+
+	opts.MaxMemoryBytes = 0
+	opts.ResumeInterrupted = true
+	got := codeByCommitSHAs(t, opts)
+	if !reflect.DeepEqual(got, []string{c2, c3}) {
+		t.Fatalf("expected remaining commits after resume, got %v", got)
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	checkpoints, err := ioutil.TempDir("", "ripsrc-limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpoints)
+
+	_, err = New(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpoints, MaxDuration: 1}).CodeSlice(context.Background())
+	var perr *PartialError
+	if !errors.As(err, &perr) || perr.Reason != LimitDuration {
+		t.Fatalf("expected PartialError with LimitDuration, got %v", err)
+	}
+
+	err = Opts{RepoDir: r.Dir(), MaxDuration: -1}.Validate()
+	if err == nil {
+		t.Fatal("expected error for negative MaxDuration")
+	}
+}
+
+func TestCommitDeadlineSkipsFiles(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Write("b.txt", "b\n").Commit("c1")
+	r.Git("rm", "-q", "b.txt")
+	c2 := r.Commit("c2")
+
+	rs := New(Opts{RepoDir: r.Dir(), CommitDeadline: 1, SkipReport: true})
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	got := map[string]map[string]BlameResult{}
+	var exceeded []string
+	go func() {
+		for c := range commits {
+			if c.DeadlineExceeded {
+				exceeded = append(exceeded, c.SHA)
+			}
+			got[c.SHA] = map[string]BlameResult{}
+			for b := range c.Blames {
+				got[c.SHA][b.Filename] = b
+			}
+		}
+		done <- true
+	}()
+	err := rs.CodeByCommit(context.Background(), commits)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exceeded, []string{c1, c2}) {
+		t.Fatalf("expected all commits over deadline, got %v", exceeded)
+	}
+	a := got[c1]["a.txt"]
+	if a.Skipped != unknownBlame || a.BlameError != "commit processing deadline exceeded" {
+		t.Fatalf("expected a.txt skipped with deadline error, got %+v", a)
+	}
+	if b := got[c2]["b.txt"]; b.Status != GitFileCommitStatusRemoved {
+		t.Fatalf("expected b.txt removed in c2, got %+v", b)
+	}
+	want := []SkippedCommit{{SHA: c1, Files: 2}, {SHA: c2, Files: 0}}
+	if !reflect.DeepEqual(rs.SkipReport().Commits(), want) {
+		t.Fatalf("unexpected skip report commits %+v", rs.SkipReport().Commits())
+	}
+}
@@ -0,0 +1,300 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// LineHistoryEntry is a commit that added, changed or removed lines of the tracked range, returned from LineHistory.
+type LineHistoryEntry struct {
+	Commit Commit
+	// Path is the name of the file in this commit.
+	Path string
+	// Start is the first line of the tracked range after this commit, 1-based. 0 if the commit is only known from blame, see LineHistory.
+	Start int
+	// End is the last line of the tracked range after this commit, inclusive. 0 if the commit is only known from blame.
+	End int
+	// Added is the number of lines of the range added by this commit. Modified lines count as deleted and added.
+	Added int
+	// Deleted is the number of lines inside the range removed by this commit.
+	Deleted int
+}
+
+// lineHistoryNode is a processed commit with blames of the file under any of its names.
+type lineHistoryNode struct {
+	commit commitmeta.Commit
+	order  int
+	files  map[string]*incblame.Blame
+}
+
+// LineHistory is an equivalent of git log -L start,end:path. Returns commits that introduced and changed lines start to end (1-based, inclusive) of the file at the first of Opts.Refs or HEAD, oldest first.
+// The range is tracked back through the incremental blame of each commit that changed the file, following renames, so git blame is not run. History is processed using CodeByCommit, starting from the existing checkpoint.
+// The range is followed through the first parent of merges. Lines that came from other parents of a merge, or from commits before CommitFromIncl, are not tracked further, commits that added them are returned with Start and End set to 0.
+func (s *Ripsrc) LineHistory(ctx context.Context, path string, start, end int) (res []LineHistoryEntry, _ error) {
+	if path == "" {
+		return nil, errors.New("LineHistory: path is required")
+	}
+	if start < 1 || end < start {
+		return nil, fmt.Errorf("LineHistory: invalid line range %v-%v", start, end)
+	}
+	gctx := s.gitContext(ctx)
+	err := s.prepareGitExec(gctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.fileLog(gctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("LineHistory: file not found in history of %v: %v", s.treeRef(), path)
+	}
+	names := map[string]bool{path: true}
+	for _, e := range entries {
+		names[e.path] = true
+		if e.previousPath != "" {
+			names[e.previousPath] = true
+		}
+	}
+	out := bytes.NewBuffer(nil)
+	err = gitexec.ExecIntoWriter(gctx, out, gitCommand, s.opts.RepoDir, []string{"rev-parse", "--verify", s.treeRef() + "^{commit}"})
+	if err != nil {
+		return nil, err
+	}
+	tip := strings.TrimSpace(out.String())
+
+	nodes := map[string]*lineHistoryNode{}
+	s.onProcessResult = func(r process.Result, commit commitmeta.Commit) {
+		node := &lineHistoryNode{commit: commit, order: len(nodes)}
+		for fn, bl := range r.Files {
+			if !names[fn] {
+				continue
+			}
+			if node.files == nil {
+				node.files = map[string]*incblame.Blame{}
+			}
+			node.files[fn] = bl
+		}
+		nodes[r.Commit] = node
+	}
+	defer func() {
+		s.onProcessResult = nil
+	}()
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			for range c.Blames {
+			}
+		}
+		done <- true
+	}()
+	err = s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	return lineHistory(nodes, tip, path, start-1, end-1)
+}
+
+// findFileBlame walks first parents starting from sha and returns the first commit that changed the file.
+func findFileBlame(nodes map[string]*lineHistoryNode, sha string, name string) (*lineHistoryNode, *incblame.Blame) {
+	for sha != "" {
+		node, ok := nodes[sha]
+		if !ok {
+			return nil, nil
+		}
+		if bl, ok := node.files[name]; ok {
+			return node, bl
+		}
+		sha = ""
+		if len(node.commit.Parents) != 0 {
+			sha = node.commit.Parents[0]
+		}
+	}
+	return nil, nil
+}
+
+// fileStatus returns the status of the file in commit, empty if the commit did not change it.
+func fileStatus(commit commitmeta.Commit, name string) CommitStatus {
+	if f := commit.Files[name]; f != nil {
+		return f.Status
+	}
+	return ""
+}
+
+// lineHistory tracks range a-b (0-based, inclusive) of the file back from tip.
+func lineHistory(nodes map[string]*lineHistoryNode, tip string, name string, a, b int) (res []LineHistoryEntry, _ error) {
+	node, bl := findFileBlame(nodes, tip, name)
+	if node == nil || fileStatus(node.commit, name) == GitFileCommitStatusRemoved {
+		return nil, fmt.Errorf("LineHistory: file not found at %v: %v", tip, name)
+	}
+	if bl.IsBinary || bl.IsUnknown {
+		return nil, fmt.Errorf("LineHistory: blame not available for file: %v", name)
+	}
+	if b >= len(bl.Lines) {
+		return nil, fmt.Errorf("LineHistory: line range %v-%v is outside of the file with %v lines: %v", a+1, b+1, len(bl.Lines), name)
+	}
+
+	bySHA := map[string]*LineHistoryEntry{}
+	entry := func(commit commitmeta.Commit) *LineHistoryEntry {
+		e, ok := bySHA[commit.SHA]
+		if !ok {
+			e = &LineHistoryEntry{Commit: commit}
+			bySHA[commit.SHA] = e
+		}
+		return e
+	}
+	// blamed adds commits of lines that could not be tracked further
+	blamed := func(lines incblame.Lines, path string) {
+		for _, l := range lines {
+			commit := commitmeta.Commit{SHA: l.Commit}
+			if n, ok := nodes[l.Commit]; ok {
+				commit = n.commit
+			}
+			e := entry(commit)
+			if e.Path == "" {
+				e.Path = path
+			}
+			e.Added++
+		}
+	}
+
+	for {
+		commit := node.commit
+		prevName := name
+		isNew := fileStatus(commit, name) == GitFileCommitStatusAdded
+		if f := commit.Files[name]; f != nil && f.RenamedFrom != "" {
+			prevName = f.RenamedFrom
+			isNew = false
+		}
+		var prevNode *lineHistoryNode
+		var prev *incblame.Blame
+		if !isNew && len(commit.Parents) != 0 {
+			prevNode, prev = findFileBlame(nodes, commit.Parents[0], prevName)
+		}
+		if prev == nil || prev.IsBinary || prev.IsUnknown {
+			var other incblame.Lines
+			added := 0
+			for _, l := range bl.Lines[a : b+1] {
+				if l.Commit == commit.SHA {
+					added++
+				} else {
+					other = append(other, l)
+				}
+			}
+			if added != 0 {
+				e := entry(commit)
+				e.Path, e.Start, e.End = name, a+1, b+1
+				e.Added += added
+			}
+			blamed(other, name)
+			break
+		}
+
+		mapped := alignLines(prev.Lines, bl.Lines, commit.SHA)
+		prevStart, prevEnd := -1, -1
+		include := func(i int) {
+			if prevStart == -1 || i < prevStart {
+				prevStart = i
+			}
+			if i > prevEnd {
+				prevEnd = i
+			}
+		}
+		added := 0
+		var other incblame.Lines
+		for i := a; i <= b; i++ {
+			switch {
+			case mapped[i] != -1:
+				include(mapped[i])
+			case bl.Lines[i].Commit == commit.SHA:
+				added++
+			default:
+				other = append(other, bl.Lines[i])
+			}
+		}
+		blamed(other, name)
+
+		// removed lines are inside the range if the next kept line is inside, or if they were replaced with the last line of the range
+		kept := make([]int, len(prev.Lines))
+		for i := range kept {
+			kept[i] = -1
+		}
+		for i, p := range mapped {
+			if p != -1 {
+				kept[p] = i
+			}
+		}
+		deleted := 0
+		next := len(bl.Lines)
+		for p := len(prev.Lines) - 1; p >= 0; p-- {
+			if kept[p] != -1 {
+				next = kept[p]
+				continue
+			}
+			if (a < next && next <= b) || (next == b+1 && mapped[b] == -1) {
+				deleted++
+				include(p)
+			}
+		}
+
+		if added != 0 || deleted != 0 {
+			e := entry(commit)
+			e.Path, e.Start, e.End = name, a+1, b+1
+			e.Added += added
+			e.Deleted += deleted
+		}
+		if prevStart == -1 {
+			break
+		}
+		node, bl, name, a, b = prevNode, prev, prevName, prevStart, prevEnd
+	}
+
+	for _, e := range bySHA {
+		res = append(res, *e)
+	}
+	order := func(sha string) int {
+		if n, ok := nodes[sha]; ok {
+			return n.order
+		}
+		return -1
+	}
+	sort.Slice(res, func(i, j int) bool {
+		oi, oj := order(res[i].Commit.SHA), order(res[j].Commit.SHA)
+		if oi != oj {
+			return oi < oj
+		}
+		return res[i].Commit.SHA < res[j].Commit.SHA
+	})
+	return res, nil
+}
+
+// alignLines maps lines of cur to lines of prev with the same content and commit, keeping the order. Lines added by commit, or not found in prev because they came from another parent of a merge, are mapped to -1.
+func alignLines(prev, cur incblame.Lines, commit string) []int {
+	res := make([]int, len(cur))
+	j := 0
+	for i, l := range cur {
+		res[i] = -1
+		if l.Commit == commit {
+			continue
+		}
+		for k := j; k < len(prev); k++ {
+			if prev[k].Eq(*l) {
+				res[i] = k
+				j = k + 1
+				break
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestLineHistory(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.go", "package a\n\nfunc A() {\n\treturn\n}\n\nfunc B() {\n}\n").Commit("c1")
+	// changes outside of the range
+	r.Write("a.go", "package a\n\nfunc A() {\n\treturn\n}\n\nfunc B() {\n\tprintln()\n}\n").Commit("c2")
+	c3 := r.Write("a.go", "package a\n\nfunc A() {\n\tprintln()\n\treturn\n}\n\nfunc B() {\n\tprintln()\n}\n").Commit("c3")
+	r.Rename("a.go", "b.go").Commit("c4")
+	c5 := r.Write("b.go", "package a\n\n// A does nothing\nfunc A() {\n\tprintln()\n}\n\nfunc B() {\n\tprintln()\n}\n").Commit("c5")
+
+	// func A in b.go is lines 4-6
+	res, err := New(Opts{RepoDir: r.Dir()}).LineHistory(context.Background(), "b.go", 4, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		SHA     string
+		Path    string
+		Start   int
+		End     int
+		Added   int
+		Deleted int
+	}
+	var got []entry
+	for _, e := range res {
+		got = append(got, entry{e.Commit.SHA, e.Path, e.Start, e.End, e.Added, e.Deleted})
+	}
+	want := []entry{
+		{c1, "a.go", 3, 5, 3, 0},
+		{c3, "a.go", 3, 6, 1, 0},
+		{c5, "b.go", 4, 6, 0, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%+v\nwant\n%+v", got, want)
+	}
+	if res[0].Commit.Message != "c1" {
+		t.Errorf("expected commit metadata, got %+v", res[0].Commit)
+	}
+}
+
+func TestLineHistoryInvalidRange(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Commit("c1")
+	_, err := New(Opts{RepoDir: r.Dir()}).LineHistory(context.Background(), "a.go", 1, 5)
+	if err == nil {
+		t.Fatal("expected error for range outside of the file")
+	}
+}
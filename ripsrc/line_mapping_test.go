@@ -0,0 +1,73 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestTrackLineMapping(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\nfunc a() {}\n\nfunc b() {}\n").Commit("c1")
+	// insert line at top, modify line 3, remove line 4
+	r.Write("a.go", "// doc\npackage a\n\nfunc a() { a() }\nfunc b() {}\n").Commit("c2")
+	r.Rename("a.go", "b.go").Commit("c3")
+	r.Branch("other").Write("b.go", "// doc\npackage a\n\nfunc a() { a() }\nfunc b() {}\nfunc c() {}\n").Commit("c4")
+	r.Checkout("master").Write("c.go", "package a\n").Commit("c5")
+	r.Merge("m", "other")
+
+	res, err := New(Opts{RepoDir: r.Dir(), TrackLineMapping: true}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]int
+	for _, b := range res {
+		var prev []int
+		for i, l := range b.Lines {
+			if l.Line != i+1 {
+				t.Errorf("file %v line %v has Line %v", b.Filename, i+1, l.Line)
+			}
+			prev = append(prev, l.PrevLine)
+		}
+		got = append(got, prev)
+	}
+	want := [][]int{
+		// added file
+		{0, 0, 0, 0, 0},
+		{0, 1, 2, 0, 5},
+		// renamed without changes
+		{1, 2, 3, 4, 5},
+		{1, 2, 3, 4, 5, 0},
+		{0},
+	}
+	if len(res) < len(want) {
+		t.Fatalf("expected at least %v results, got %v", len(want), len(res))
+	}
+	for i, w := range want {
+		if !intsEqual(got[i], w) {
+			t.Errorf("commit %v file %v wanted prev lines %v, got %v", i+1, res[i].Filename, w, got[i])
+		}
+	}
+	// mapping is not set for merges
+	for _, b := range res[len(want):] {
+		for _, l := range b.Lines {
+			if l.PrevLine != 0 {
+				t.Errorf("merge should not have prev lines, file %v", b.Filename)
+			}
+		}
+	}
+}
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrAlreadyRunning is returned when another ripsrc run holds the lock of the same checkpoints dir. Use errors.Is to check for it.
+var ErrAlreadyRunning = errors.New("ripsrc is already running for this repo")
+
+// LockStaleAfter is the age after which a lock that was not refreshed is considered left by a crashed process and is removed.
+// Running processes refresh the lock every LockStaleAfter/4.
+var LockStaleAfter = 10 * time.Minute
+
+// lockSuffix is added to checkpoints dir to get lock file location. Lock is kept outside of the dir, since checkpoints dir is renamed when forcing full reprocess.
+const lockSuffix = ".lock"
+
+// lockInfo is written to the lock file to identify the holder.
+type lockInfo struct {
+	PID      int
+	Hostname string
+	Started  time.Time
+}
+
+// dirLock is an advisory lock on checkpoints dir, held by a single process at a time.
+type dirLock struct {
+	loc  string
+	stop chan bool
+	wg   sync.WaitGroup
+}
+
+// acquireLock creates lock file for checkpoints dir. Returns error wrapping ErrAlreadyRunning if the lock is held by another process.
+// Stale locks, left by processes that are no longer running on this host or not refreshed for LockStaleAfter, are removed.
+func acquireLock(dir string) (*dirLock, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(filepath.Dir(dir), 0777)
+	if err != nil {
+		return nil, err
+	}
+	loc := dir + lockSuffix
+	hostname, _ := os.Hostname()
+	info := lockInfo{PID: os.Getpid(), Hostname: hostname, Started: time.Now()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(loc, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			_, err = f.Write(data)
+			if err2 := f.Close(); err == nil {
+				err = err2
+			}
+			if err != nil {
+				os.Remove(loc)
+				return nil, err
+			}
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		holder, stale, err := checkLock(loc, hostname)
+		if err != nil {
+			return nil, err
+		}
+		if !stale || attempt != 0 {
+			return nil, fmt.Errorf("%w: lock %v held by pid %v on %v since %v", ErrAlreadyRunning, loc, holder.PID, holder.Hostname, holder.Started.Format(time.RFC3339))
+		}
+		err = os.Remove(loc)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	s := &dirLock{}
+	s.loc = loc
+	s.stop = make(chan bool)
+	s.wg.Add(1)
+	go s.refresh()
+	return s, nil
+}
+
+// checkLock returns lock holder and whether the lock is stale.
+func checkLock(loc string, hostname string) (holder lockInfo, stale bool, _ error) {
+	stat, err := os.Stat(loc)
+	if os.IsNotExist(err) {
+		// released in the meantime
+		return holder, true, nil
+	}
+	if err != nil {
+		return holder, false, err
+	}
+	data, err := ioutil.ReadFile(loc)
+	if err != nil && !os.IsNotExist(err) {
+		return holder, false, err
+	}
+	if json.Unmarshal(data, &holder) != nil {
+		// partially written by a process that is starting now, or corrupted, rely on age only
+		return holder, time.Since(stat.ModTime()) > LockStaleAfter, nil
+	}
+	if holder.Hostname == hostname && holder.PID != 0 && !processAlive(holder.PID) {
+		return holder, true, nil
+	}
+	return holder, time.Since(stat.ModTime()) > LockStaleAfter, nil
+}
+
+// refresh updates lock modification time, so that it is not considered stale by other hosts.
+func (s *dirLock) refresh() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(LockStaleAfter / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(s.loc, now, now)
+		}
+	}
+}
+
+// Release removes the lock file.
+func (s *dirLock) Release() error {
+	close(s.stop)
+	s.wg.Wait()
+	err := os.Remove(s.loc)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// lock acquires lock on checkpoints dir of the repo. Worktrees of the same repo share it, as they share checkpoints.
+func (s *Ripsrc) lock(ctx context.Context) (*dirLock, error) {
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return acquireLock(filepath.Dir(loc))
+}
@@ -0,0 +1,8 @@
+// +build windows
+
+package ripsrc
+
+// processAlive is not supported on windows, locks are only considered stale based on age.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,105 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestLock(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ripsrc-lock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "checkpoints")
+
+	l1, err := acquireLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = acquireLock(dir)
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+	err = l1.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := acquireLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2.Release()
+
+	writeLock := func(info lockInfo, modTime time.Time) {
+		t.Helper()
+		b, err := json.Marshal(info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(dir+lockSuffix, b, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(dir+lockSuffix, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hostname, _ := os.Hostname()
+
+	// process that exited on this host
+	cmd := exec.Command("true")
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLock(lockInfo{PID: cmd.Process.Pid, Hostname: hostname}, time.Now())
+	l3, err := acquireLock(dir)
+	if err != nil {
+		t.Fatalf("expected lock of exited process to be stale, got %v", err)
+	}
+	l3.Release()
+
+	// other host, recently refreshed
+	writeLock(lockInfo{PID: 1, Hostname: "other-host"}, time.Now())
+	_, err = acquireLock(dir)
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+
+	// other host, not refreshed
+	writeLock(lockInfo{PID: 1, Hostname: "other-host"}, time.Now().Add(-2*LockStaleAfter))
+	l4, err := acquireLock(dir)
+	if err != nil {
+		t.Fatalf("expected old lock to be stale, got %v", err)
+	}
+	l4.Release()
+}
+
+func TestCodeByCommitAlreadyRunning(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+
+	s := New(Opts{RepoDir: r.Dir()})
+	l, err := s.lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	_, _, err = codeByCommit(Opts{RepoDir: r.Dir()})
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+}
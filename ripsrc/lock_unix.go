@@ -0,0 +1,12 @@
+// +build !windows
+
+package ripsrc
+
+import "syscall"
+
+// processAlive returns true if process with pid exists on this host.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists, but belongs to another user
+	return err == nil || err == syscall.EPERM
+}
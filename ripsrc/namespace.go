@@ -0,0 +1,241 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// namespaceDirName is the subdirectory of CheckpointsDir holding per repo checkpoints when NamespaceCheckpoints is set.
+const namespaceDirName = "repos"
+
+// namespaceMetaFile is written next to namespaced checkpoints to identify the repo in ListCheckpoints.
+const namespaceMetaFile = "repo.json"
+
+// deletingSuffix is added to namespace dir while it is being deleted.
+const deletingSuffix = ".deleting"
+
+// RepoCheckpoints describes checkpoints stored for one repo in a namespaced CheckpointsDir. Returned from ListCheckpoints.
+type RepoCheckpoints struct {
+	// RepoID is the namespace key, Opts.RepoID or derived from remote url.
+	RepoID string
+	// RemoteURL is the origin url of the repo at the time of the last run. Empty if repo has no origin.
+	RemoteURL string
+	// RepoDir is the location of the repo at the time of the last run.
+	RepoDir string
+	// Updated is the time of the last successful run.
+	Updated time.Time
+	// Dir is the directory with checkpoints for the repo.
+	Dir string
+	// SizeBytes is the size of checkpoint files on disk.
+	SizeBytes int64
+}
+
+// validRepoID checks that id could be used as a single path segment.
+func validRepoID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("RepoID %q is not a valid directory name", id)
+	}
+	return nil
+}
+
+// repoID returns the namespace key for the repo. Uses Opts.RepoID if set, otherwise hash of origin url, or hash of absolute RepoDir if there is no origin.
+func (s *Ripsrc) repoID(ctx context.Context) (id string, remoteURL string, _ error) {
+	remoteURL, err := s.remoteURL(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if s.opts.RepoID != "" {
+		return s.opts.RepoID, remoteURL, nil
+	}
+	key := remoteURL
+	if key == "" {
+		// same id for all worktrees of the repo
+		repoDir, err := gitexec.MainRepoDir(s.opts.RepoDir)
+		if err != nil {
+			return "", "", err
+		}
+		abs, err := filepath.Abs(repoDir)
+		if err != nil {
+			return "", "", err
+		}
+		key = "dir:" + abs
+	}
+	h := sha1.Sum([]byte(key))
+	return hex.EncodeToString(h[:]), remoteURL, nil
+}
+
+// remoteURL returns url of origin remote or empty string if not set.
+func (s *Ripsrc) remoteURL(ctx context.Context) (string, error) {
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"config", "--get", "remote.origin.url"})
+	if err != nil {
+		// git config exits with 1 and no output if the key is not set
+		var gerr *gitexec.Error
+		if errors.As(err, &gerr) && gerr.Stderr == "" {
+			return "", nil
+		}
+		return "", err
+	}
+	return normalizeRemoteURL(strings.TrimSpace(out.String())), nil
+}
+
+// normalizeRemoteURL removes differences that do not change the repo, so that clones using https and ssh urls of the same repo share the namespace when possible.
+func normalizeRemoteURL(u string) string {
+	u = strings.TrimSuffix(u, "/")
+	u = strings.TrimSuffix(u, ".git")
+	if i := strings.Index(u, "://"); i != -1 {
+		u = u[i+3:]
+		if at := strings.Index(u, "@"); at != -1 && at < strings.Index(u+"/", "/") {
+			u = u[at+1:]
+		}
+	} else if at := strings.Index(u, "@"); at != -1 {
+		// scp-like syntax user@host:path
+		u = strings.Replace(u[at+1:], ":", "/", 1)
+	}
+	return strings.ToLower(u)
+}
+
+// checkpointsDir returns the directory to pass to process as CheckpointsDir. With NamespaceCheckpoints it is a per repo subdirectory of Opts.CheckpointsDir.
+func (s *Ripsrc) checkpointsDir(ctx context.Context) (string, error) {
+	if !s.opts.NamespaceCheckpoints {
+		return s.opts.CheckpointsDir, nil
+	}
+	if s.namespacedCheckpointsDir != "" {
+		return s.namespacedCheckpointsDir, nil
+	}
+	id, _, err := s.repoID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get repo id for checkpoints namespace: %v", err)
+	}
+	s.namespacedCheckpointsDir = filepath.Join(s.opts.CheckpointsDir, namespaceDirName, id)
+	return s.namespacedCheckpointsDir, nil
+}
+
+// writeNamespaceMeta records repo identity in namespaced checkpoints dir, so that ListCheckpoints could show which repo it belongs to.
+func (s *Ripsrc) writeNamespaceMeta(ctx context.Context) error {
+	if !s.opts.NamespaceCheckpoints {
+		return nil
+	}
+	dir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return err
+	}
+	id, remoteURL, err := s.repoID(ctx)
+	if err != nil {
+		return err
+	}
+	repoDir, err := filepath.Abs(s.opts.RepoDir)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(RepoCheckpoints{RepoID: id, RemoteURL: remoteURL, RepoDir: repoDir, Updated: time.Now()})
+	if err != nil {
+		return err
+	}
+	loc := filepath.Join(dir, namespaceMetaFile)
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(loc+".tmp", b, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(loc+".tmp", loc)
+}
+
+// ListCheckpoints returns repos that have checkpoints in checkpointsDir used with Opts.NamespaceCheckpoints, sorted by RepoID.
+func ListCheckpoints(checkpointsDir string) (res []RepoCheckpoints, _ error) {
+	root := filepath.Join(checkpointsDir, namespaceDirName)
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || validRepoID(e.Name()) != nil || strings.HasSuffix(e.Name(), deletingSuffix) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		rc := RepoCheckpoints{}
+		b, err := ioutil.ReadFile(filepath.Join(dir, namespaceMetaFile))
+		switch {
+		case os.IsNotExist(err):
+			// run was interrupted before writing metadata
+		case err != nil:
+			return nil, err
+		default:
+			err = json.Unmarshal(b, &rc)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %v in %v: %v", namespaceMetaFile, dir, err)
+			}
+		}
+		rc.RepoID = e.Name()
+		rc.Dir = dir
+		rc.SizeBytes, err = dirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rc)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].RepoID < res[j].RepoID
+	})
+	return res, nil
+}
+
+// DeleteCheckpoints removes checkpoints of the repo with repoID from checkpointsDir used with Opts.NamespaceCheckpoints. Returns nil if there are no checkpoints for the repo.
+// Returns error wrapping ErrAlreadyRunning if the repo is being processed.
+func DeleteCheckpoints(checkpointsDir string, repoID string) error {
+	err := validRepoID(repoID)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(checkpointsDir, namespaceDirName, repoID)
+	if _, err := os.Stat(dir); err == nil {
+		lock, err := acquireLock(process.New(process.Opts{CheckpointsDir: dir}).Dir())
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+	// rename first so that partially deleted checkpoints are never read
+	tmp := dir + deletingSuffix
+	err = os.Rename(dir, tmp)
+	if os.IsNotExist(err) {
+		return os.RemoveAll(tmp)
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(tmp)
+}
+
+func dirSize(dir string) (res int64, _ error) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			res += info.Size()
+		}
+		return nil
+	})
+	return res, err
+}
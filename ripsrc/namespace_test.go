@@ -0,0 +1,98 @@
+package ripsrc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestNamespaceCheckpoints(t *testing.T) {
+	r1 := testkit.New(t)
+	defer r1.Remove()
+	r1.Write("a.txt", "a\n").Commit("c1")
+	r1.Git("remote", "add", "origin", "https://user@github.com/pinpt/Repo1.git")
+
+	r2 := testkit.New(t)
+	defer r2.Remove()
+	c2 := r2.Write("b.txt", "b\n").Commit("c1")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-namespace-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+	ctx := context.Background()
+
+	opts1 := Opts{RepoDir: r1.Dir(), CheckpointsDir: checkpointsDir, NamespaceCheckpoints: true}
+	opts2 := Opts{RepoDir: r2.Dir(), CheckpointsDir: checkpointsDir, NamespaceCheckpoints: true, RepoID: "repo2"}
+	for _, opts := range []Opts{opts1, opts2} {
+		_, err = New(opts).CodeSlice(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := New(opts2).Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.Commit != c2 {
+		t.Fatalf("wanted checkpoint for %v, got %+v", c2, info)
+	}
+
+	list, err := ListCheckpoints(checkpointsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("wanted 2 repos, got %+v", list)
+	}
+	byURL := map[string]RepoCheckpoints{}
+	for _, rc := range list {
+		byURL[rc.RemoteURL] = rc
+		if rc.SizeBytes == 0 || rc.Updated.IsZero() {
+			t.Fatalf("missing size or time %+v", rc)
+		}
+	}
+	if byURL["github.com/pinpt/repo1"].RepoID == "" {
+		t.Fatalf("wanted normalized remote url, got %+v", list)
+	}
+	if byURL[""].RepoID != "repo2" {
+		t.Fatalf("wanted explicit RepoID, got %+v", list)
+	}
+
+	// clone using ssh url of the same repo shares the namespace
+	if id, _, _ := New(Opts{RepoDir: r1.Dir()}).repoID(ctx); id != byURL["github.com/pinpt/repo1"].RepoID {
+		t.Fatalf("unexpected repo id %v", id)
+	}
+	if normalizeRemoteURL("git@github.com:pinpt/repo1.git") != "github.com/pinpt/repo1" {
+		t.Fatal("ssh url not normalized")
+	}
+
+	err = DeleteCheckpoints(checkpointsDir, "repo2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = New(opts2).Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Exists {
+		t.Fatal("checkpoint not deleted")
+	}
+	list, err = ListCheckpoints(checkpointsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("wanted 1 repo after delete, got %+v", list)
+	}
+
+	err = DeleteCheckpoints(checkpointsDir, "../x")
+	if err == nil {
+		t.Fatal("wanted error for invalid repo id")
+	}
+}
@@ -0,0 +1,208 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// ProcessingStatus is returned from NeedsProcessing.
+type ProcessingStatus struct {
+	// Needed is true if there are commits that were not processed by the previous successful run.
+	Needed bool
+	// NoPreviousRun is true if there is no record of a previous successful run. Needed is always true in this case.
+	NoPreviousRun bool
+	// ChangedRefs are refs that were added or moved since the previous run. Removed refs are not included, since they do not need processing.
+	ChangedRefs []string
+	// NewCommits is the approximate number of commits that were not processed yet.
+	NewCommits int
+	// LastRun is the time of the previous successful run.
+	LastRun time.Time
+	// EmptyRepo is true if the repo has no commits. Needed is false in this case.
+	EmptyRepo bool
+}
+
+// refsManifest records ref tips at the start of a successful run.
+type refsManifest struct {
+	// Refs is map[ref]commit
+	Refs             map[string]string
+	CheckpointCommit string
+	Time             time.Time
+	// Commits is the number of commits processed in the run
+	Commits int
+}
+
+const refsManifestFile = "refs-manifest.json"
+
+// NeedsProcessing compares current refs with refs recorded by the last successful CodeByCommit run and returns whether there is anything new to process.
+// Only runs a few cheap git commands, so it could be used to skip unchanged repos.
+//
+// Refs considered are the same as used for processing, Opts.Refs if set, all refs with AllBranches, or HEAD otherwise.
+func (s *Ripsrc) NeedsProcessing(ctx context.Context) (res ProcessingStatus, _ error) {
+	ctx = s.gitContext(ctx)
+
+	err := s.prepareGitExec(ctx)
+	if errors.Is(err, ErrEmptyRepo) {
+		res.EmptyRepo = true
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	current, err := s.currentRefs(ctx)
+	if err != nil {
+		return res, err
+	}
+	prev, err := s.readRefsManifest(ctx)
+	if err != nil {
+		return res, err
+	}
+	var oldTips []string
+	if prev == nil {
+		res.Needed = true
+		res.NoPreviousRun = true
+		for ref := range current {
+			res.ChangedRefs = append(res.ChangedRefs, ref)
+		}
+	} else {
+		res.LastRun = prev.Time
+		for ref, commit := range current {
+			if prev.Refs[ref] != commit {
+				res.ChangedRefs = append(res.ChangedRefs, ref)
+			}
+		}
+		for _, commit := range prev.Refs {
+			oldTips = append(oldTips, commit)
+		}
+	}
+	sort.Strings(res.ChangedRefs)
+	if len(res.ChangedRefs) == 0 {
+		return res, nil
+	}
+	args := []string{"rev-list", "--count", "--ignore-missing"}
+	for _, ref := range res.ChangedRefs {
+		args = append(args, current[ref])
+	}
+	if len(oldTips) != 0 {
+		args = append(args, "--not")
+		args = append(args, oldTips...)
+	}
+	out := bytes.NewBuffer(nil)
+	err = gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, args)
+	if err != nil {
+		return res, err
+	}
+	res.NewCommits, err = strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil {
+		return res, fmt.Errorf("unexpected git rev-list output: %v", out.String())
+	}
+	// moved refs without new commits, for example reset to an older commit, do not need processing
+	res.Needed = res.NoPreviousRun || res.NewCommits != 0
+	return res, nil
+}
+
+// currentRefs returns map[ref]commit for refs that are processed with current options.
+func (s *Ripsrc) currentRefs(ctx context.Context) (map[string]string, error) {
+	res := map[string]string{}
+	out := bytes.NewBuffer(nil)
+	switch {
+	case len(s.opts.Refs) != 0:
+		args := []string{"rev-parse"}
+		for _, ref := range s.opts.Refs {
+			args = append(args, ref+"^{commit}")
+		}
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, args)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Fields(out.String())
+		if len(lines) != len(s.opts.Refs) {
+			return nil, fmt.Errorf("unexpected git rev-parse output: %v", out.String())
+		}
+		for i, ref := range s.opts.Refs {
+			res[ref] = lines[i]
+		}
+	case s.opts.AllBranches:
+		// same as git log --all used for processing
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"for-each-ref", "--format=%(refname) %(objectname) %(*objectname)"})
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(out.String(), "\n") {
+			parts := strings.Fields(line)
+			if len(parts) < 2 {
+				continue
+			}
+			commit := parts[1]
+			if len(parts) == 3 {
+				// annotated tag, use tagged object
+				commit = parts[2]
+			}
+			res[parts[0]] = commit
+		}
+		out.Reset()
+		fallthrough
+	default:
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"rev-parse", "HEAD"})
+		if err != nil {
+			return nil, err
+		}
+		res["HEAD"] = strings.TrimSpace(out.String())
+	}
+	return res, nil
+}
+
+func (s *Ripsrc) refsManifestPath(ctx context.Context) (string, error) {
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	p := process.New(process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	})
+	return filepath.Join(p.Dir(), refsManifestFile), nil
+}
+
+// readRefsManifest returns nil if there is no manifest
+func (s *Ripsrc) readRefsManifest(ctx context.Context) (*refsManifest, error) {
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(loc)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res refsManifest
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse refs manifest: %v", err)
+	}
+	return &res, nil
+}
+
+func (s *Ripsrc) writeRefsManifest(ctx context.Context, refs map[string]string, checkpointCommit string, commits int) error {
+	m := refsManifest{Refs: refs, CheckpointCommit: checkpointCommit, Time: time.Now(), Commits: commits}
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(loc, m)
+}
@@ -0,0 +1,87 @@
+package ripsrc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestNeedsProcessing(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-needs-processing-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+	opts := Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}
+	ctx := context.Background()
+
+	check := func(want ProcessingStatus) {
+		t.Helper()
+		got, err := New(opts).NeedsProcessing(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.LastRun = want.LastRun
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("want\n%+v\ngot\n%+v", want, got)
+		}
+	}
+
+	check(ProcessingStatus{Needed: true, NoPreviousRun: true, ChangedRefs: []string{"HEAD"}, NewCommits: 2})
+
+	_, err = New(opts).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(ProcessingStatus{})
+
+	r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	check(ProcessingStatus{Needed: true, ChangedRefs: []string{"HEAD"}, NewCommits: 1})
+}
+
+func TestCheckpoint(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Write("b.txt", "b\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+	opts := Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}
+	ctx := context.Background()
+
+	got, err := New(opts).Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Exists {
+		t.Fatalf("expected no checkpoint, got %+v", got)
+	}
+
+	_, err = New(opts).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = New(opts).Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Exists || got.Commit != c2 || got.ProcessedCommits != 2 || !reflect.DeepEqual(got.Refs, map[string]string{"HEAD": c2}) {
+		t.Fatalf("unexpected checkpoint info %+v", got)
+	}
+	if got.StoredCommits == 0 || got.SizeBytes == 0 || got.Time.IsZero() {
+		t.Fatalf("unexpected checkpoint info %+v", got)
+	}
+}
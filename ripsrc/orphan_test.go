@@ -0,0 +1,78 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/branchdiff"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestOrphanBranch(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("a.txt", "a2\n").Commit("c2")
+	r.Git("checkout", "-q", "--orphan", "gh-pages")
+	r.Git("rm", "-q", "-rf", ".")
+	p1 := r.Write("index.js", "var a = 1\n").Commit("p1")
+	p2 := r.Write("index.js", "var a = 2\n").Commit("p2")
+	r.Checkout("master")
+	ctx := context.Background()
+
+	branches := func() map[string]Branch {
+		t.Helper()
+		res, err := New(Opts{RepoDir: r.Dir(), AllBranches: true}).BranchesSlice(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := map[string]Branch{}
+		for _, b := range res {
+			m[b.Name] = b
+		}
+		return m
+	}
+
+	res, err := New(Opts{RepoDir: r.Dir(), AllBranches: true}).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("wanted results for 4 commits, got %v", len(res))
+	}
+	got := branches()
+	pages := got["gh-pages"]
+	if !pages.Orphan || pages.IsMerged || len(pages.Commits) != 2 || pages.Commits[0] != p1 || pages.Commits[1] != p2 {
+		t.Errorf("unexpected orphan branch %+v", pages)
+	}
+	if got["master"].Orphan {
+		t.Errorf("default branch should not be orphan")
+	}
+	diff, err := New(Opts{RepoDir: r.Dir(), AllBranches: true}).BranchDiffSlice(ctx, "gh-pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 || !diff[0].Orphan || diff[0].MergeStatus != branchdiff.MergeStatusOrphan || diff[0].AheadCount != 2 || diff[0].BehindCount != 2 {
+		t.Errorf("unexpected branch diff %+v", diff)
+	}
+
+	// merging unrelated histories joins the roots, blame continues from the orphan branch
+	r.Git("merge", "-q", "--no-edit", "--allow-unrelated-histories", "gh-pages")
+	c3 := r.Write("index.js", "var a = 2\nvar b = 3\n").Commit("c3")
+	res, err = New(Opts{RepoDir: r.Dir(), AllBranches: true}).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []*BlameLine
+	for _, b := range res {
+		if b.Commit.SHA == c3 && b.Filename == "index.js" {
+			lines = b.Lines
+		}
+	}
+	if len(lines) != 2 || lines[0].SHA != p2 || lines[1].SHA != c3 {
+		t.Errorf("unexpected blame after merging orphan branch %+v", lines)
+	}
+	if pages := branches()["gh-pages"]; pages.Orphan || !pages.IsMerged {
+		t.Errorf("expected merged branch not to be orphan %+v", pages)
+	}
+}
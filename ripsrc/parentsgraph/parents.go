@@ -20,7 +20,9 @@ type Graph struct {
 type Opts struct {
 	RepoDir     string
 	AllBranches bool
-	Logger      logger.Logger
+	// Refs limits the graph to commits reachable from these branches, tags or shas. Takes precedence over AllBranches.
+	Refs   []string
+	Logger logger.Logger
 }
 
 func New(opts Opts) *Graph {
@@ -37,12 +39,17 @@ func NewFromMap(parents map[string][]string) *Graph {
 }
 
 func (s *Graph) Read() error {
+	return s.ReadContext(context.Background())
+}
+
+// ReadContext is the same as Read, ctx is passed to git commands.
+func (s *Graph) ReadContext(ctx context.Context) error {
 	start := time.Now()
 	s.opts.Logger.Info("parentsgraph: starting reading")
 	defer func() {
 		s.opts.Logger.Info("parentsgraph: completed reading", "d", time.Since(start))
 	}()
-	err := s.retrieveParents()
+	err := s.retrieveParents(ctx)
 	if err != nil {
 		return err
 	}
@@ -66,8 +73,8 @@ func (s *Graph) childrenFromParents() {
 	}
 }
 
-func (s *Graph) retrieveParents() error {
-	r, err := s.gitLogParents()
+func (s *Graph) retrieveParents(ctx context.Context) error {
+	r, err := s.gitLogParents(ctx)
 	if err != nil {
 		return err
 	}
@@ -83,7 +90,7 @@ func (s *Graph) retrieveParents() error {
 	return nil
 }
 
-func (s *Graph) gitLogParents() (io.ReadCloser, error) {
+func (s *Graph) gitLogParents(ctx context.Context) (io.ReadCloser, error) {
 	args := []string{
 		"log",
 		"-m",
@@ -92,10 +99,12 @@ func (s *Graph) gitLogParents() (io.ReadCloser, error) {
 		"--pretty=format:%H@%P",
 	}
 
-	if s.opts.AllBranches {
+	if len(s.opts.Refs) != 0 {
+		args = append(args, s.opts.Refs...)
+		args = append(args, "--")
+	} else if s.opts.AllBranches {
 		args = append(args, "--all")
 	}
 
-	ctx := context.Background()
 	return gitexec.ExecPiped(ctx, "git", s.opts.RepoDir, args)
 }
@@ -0,0 +1,154 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// Patch is a change that does not exist in the local repo, for example pull request commit retrieved from api.
+type Patch struct {
+	// ID is used instead of commit hash in results and as the SHA of lines added by this patch. Must be unique.
+	ID string
+
+	// AuthorName, AuthorEmail, Date and Message are used as commit metadata in results.
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+	Message     string
+
+	// Diff is unified diff in git format (output of git diff or git format-patch). Could contain changes to multiple files.
+	Diff []byte
+}
+
+// CodeFromPatches applies patches on top of checkpointed state of baseCommit and returns code information for files changed in patches.
+// First patch is applied on baseCommit, each next patch on the result of the previous one. Patched commits do not need to exist in the local repo.
+// Requires checkpoint created by previous Code or CodeByCommit call that processed baseCommit. Checkpoint is not modified.
+func (s *Ripsrc) CodeFromPatches(ctx context.Context, baseCommit string, patches []Patch, res chan BlameResult) error {
+	defer close(res)
+	ctx = s.gitContext(ctx)
+
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.getCommitInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	var processPatches []process.Patch
+	parent := baseCommit
+	for i, p := range patches {
+		meta, err := patchCommitMeta(p, parent, int64(len(s.commitMeta)+i))
+		if err != nil {
+			return err
+		}
+		s.commitMeta[p.ID] = s.scrubCommit(meta)
+		processPatches = append(processPatches, process.Patch{ID: p.ID, Diff: p.Diff})
+		parent = p.ID
+	}
+
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	processOpts := process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	}
+	results, err := process.New(processOpts).RunPatches(baseCommit, processPatches)
+	if err != nil {
+		return err
+	}
+	for _, r1 := range results {
+		rs, err := s.codeInfoFiles(r1)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			select {
+			case res <- r:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) CodeFromPatchesSlice(ctx context.Context, baseCommit string, patches []Patch) (res []BlameResult, _ error) {
+	resChan := make(chan BlameResult)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.CodeFromPatches(ctx, baseCommit, patches, resChan)
+	<-done
+	return res, err
+}
+
+// patchCommitMeta creates commit metadata for patch, with file stats calculated from the diff.
+func patchCommitMeta(p Patch, parent string, ordinal int64) (res Commit, _ error) {
+	res.SHA = p.ID
+	res.AuthorName = p.AuthorName
+	res.AuthorEmail = p.AuthorEmail
+	res.CommitterName = p.AuthorName
+	res.CommitterEmail = p.AuthorEmail
+	res.Date = p.Date
+	res.CommitterDate = p.Date
+	res.Ordinal = ordinal
+	res.Message = p.Message
+	res.Parents = []string{parent}
+	res.Files = map[string]*CommitFile{}
+	for _, data := range incblame.SplitDiff(p.Diff) {
+		diff, err := incblame.Parse(data)
+		if err != nil {
+			return res, fmt.Errorf("could not parse patch %v err: %v", p.ID, err)
+		}
+		f := &CommitFile{}
+		f.Binary = diff.IsBinary
+		switch {
+		case diff.PathPrev == "":
+			f.Filename = diff.Path
+			f.Status = commitmeta.GitFileCommitStatusAdded
+		case diff.Path == "":
+			f.Filename = diff.PathPrev
+			f.Status = commitmeta.GitFileCommitStatusRemoved
+		default:
+			f.Filename = diff.Path
+			f.Status = commitmeta.GitFileCommitStatusModified
+			if diff.PathPrev != diff.Path {
+				f.Renamed = true
+				f.RenamedFrom = diff.PathPrev
+				f.RenamedTo = diff.Path
+			}
+		}
+		for _, h := range diff.Hunks {
+			for _, line := range bytes.Split(h.Data, []byte("\n")) {
+				if len(line) == 0 {
+					continue
+				}
+				switch line[0] {
+				case '+':
+					f.Additions++
+				case '-':
+					f.Deletions++
+				}
+			}
+		}
+		res.Files[f.Filename] = f
+	}
+	return res, nil
+}
@@ -16,13 +16,13 @@ func IterDir(dir string, maxRecursion int, cb func(repo string) error) error {
 		return fmt.Errorf("passed dir is a file, expecting a dir")
 	}
 
-	// check if contains .git
-	containsDotGit, err := dirContainsDir(dir, ".git")
-	if err != nil {
-		return err
+	// check if contains .git, it is a file pointing to the git dir in linked worktrees and submodules
+	_, err = os.Stat(filepath.Join(dir, ".git"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't check if dir contains .git, dir: %v err: %v", dir, err)
 	}
 
-	if containsDotGit {
+	if err == nil {
 		err := cb(dir)
 		if err != nil {
 			return err
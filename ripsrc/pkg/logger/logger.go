@@ -7,31 +7,80 @@ import (
 	"sync"
 )
 
+// Logger is a leveled structured logger. Args are key-value pairs, keys have to be strings.
+//
+//	logger.Info("processing branch", "name", name, "commit", commit)
 type Logger interface {
-	Info(msg string, args ...interface{})
 	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Level is the minimum level of messages written by DefaultLogger.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
 }
 
 type DefaultLogger struct {
-	wr io.Writer
-	mu sync.Mutex
+	wr    io.Writer
+	mu    sync.Mutex
+	level Level
 }
 
+// NewDefaultLogger creates logger that writes all messages including debug to wr.
 func NewDefaultLogger(wr io.Writer) Logger {
+	return NewDefaultLoggerWithLevel(wr, LevelDebug)
+}
+
+// NewDefaultLoggerWithLevel creates logger that writes messages with passed level and above to wr.
+func NewDefaultLoggerWithLevel(wr io.Writer, level Level) Logger {
 	s := &DefaultLogger{}
 	s.wr = wr
+	s.level = level
 	return s
 }
 
+func (s *DefaultLogger) Debug(msg string, args ...interface{}) {
+	s.log(LevelDebug, msg, args...)
+}
+
 func (s *DefaultLogger) Info(msg string, args ...interface{}) {
-	s.log("INFO", msg, args...)
+	s.log(LevelInfo, msg, args...)
 }
 
-func (s *DefaultLogger) Debug(msg string, args ...interface{}) {
-	s.log("DEBUG", msg, args...)
+func (s *DefaultLogger) Warn(msg string, args ...interface{}) {
+	s.log(LevelWarn, msg, args...)
+}
+
+func (s *DefaultLogger) Error(msg string, args ...interface{}) {
+	s.log(LevelError, msg, args...)
 }
 
-func (s *DefaultLogger) log(kind string, msg string, args ...interface{}) {
+func (s *DefaultLogger) log(level Level, msg string, args ...interface{}) {
+	if level < s.level {
+		return
+	}
+	kind := level.String()
 	write := func(format string, args ...interface{}) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
@@ -71,3 +120,38 @@ func formatArgs(args []interface{}) (res []kv, _ error) {
 	}
 	return
 }
+
+type withLogger struct {
+	logger Logger
+	args   []interface{}
+}
+
+// With returns logger that adds passed key-value fields to all messages.
+func With(logger Logger, args ...interface{}) Logger {
+	s := &withLogger{}
+	s.logger = logger
+	s.args = args
+	return s
+}
+
+func (s *withLogger) Debug(msg string, args ...interface{}) {
+	s.logger.Debug(msg, s.merge(args)...)
+}
+
+func (s *withLogger) Info(msg string, args ...interface{}) {
+	s.logger.Info(msg, s.merge(args)...)
+}
+
+func (s *withLogger) Warn(msg string, args ...interface{}) {
+	s.logger.Warn(msg, s.merge(args)...)
+}
+
+func (s *withLogger) Error(msg string, args ...interface{}) {
+	s.logger.Error(msg, s.merge(args)...)
+}
+
+func (s *withLogger) merge(args []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(s.args)+len(args))
+	res = append(res, s.args...)
+	return append(res, args...)
+}
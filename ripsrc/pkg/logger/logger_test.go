@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDefaultLoggerLevel(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewDefaultLoggerWithLevel(buf, LevelWarn)
+	l.Debug("d")
+	l.Info("i")
+	l.Warn("w", "k", 1)
+	l.Error("e")
+	want := "WARN w [{k 1}]\nERROR e []\n"
+	if buf.String() != want {
+		t.Fatalf("wanted\n%q\ngot\n%q", want, buf.String())
+	}
+}
+
+func TestWith(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := With(NewDefaultLogger(buf), "repo", "r1")
+	l.Info("msg", "k", "v")
+	want := "INFO msg [{repo r1} {k v}]\n"
+	if buf.String() != want {
+		t.Fatalf("wanted\n%q\ngot\n%q", want, buf.String())
+	}
+}
@@ -0,0 +1,33 @@
+// +build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlog returns Logger writing to slog.
+func NewSlog(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+func (s *slogLogger) Debug(msg string, args ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelDebug, msg, args...)
+}
+
+func (s *slogLogger) Info(msg string, args ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelInfo, msg, args...)
+}
+
+func (s *slogLogger) Warn(msg string, args ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelWarn, msg, args...)
+}
+
+func (s *slogLogger) Error(msg string, args ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelError, msg, args...)
+}
@@ -0,0 +1,34 @@
+package logger
+
+// ZapSugaredLogger is the subset of *zap.SugaredLogger methods used by NewZap. Defined here to avoid depending on zap.
+type ZapSugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+type zapLogger struct {
+	l ZapSugaredLogger
+}
+
+// NewZap returns Logger writing to zap. Pass zapLogger.Sugar().
+func NewZap(l ZapSugaredLogger) Logger {
+	return &zapLogger{l: l}
+}
+
+func (s *zapLogger) Debug(msg string, args ...interface{}) {
+	s.l.Debugw(msg, args...)
+}
+
+func (s *zapLogger) Info(msg string, args ...interface{}) {
+	s.l.Infow(msg, args...)
+}
+
+func (s *zapLogger) Warn(msg string, args ...interface{}) {
+	s.l.Warnw(msg, args...)
+}
+
+func (s *zapLogger) Error(msg string, args ...interface{}) {
+	s.l.Errorw(msg, args...)
+}
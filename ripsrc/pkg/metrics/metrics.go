@@ -0,0 +1,82 @@
+// Package metrics defines the sink ripsrc uses to export pipeline metrics.
+package metrics
+
+import "time"
+
+// Sink receives pipeline metrics. Labels are key-value pairs, same as logger args.
+// Implementations have to be safe for concurrent use.
+type Sink interface {
+	// Counter adds value to a monotonically increasing counter.
+	Counter(name string, value float64, labels ...string)
+	// Gauge sets the current value of a gauge.
+	Gauge(name string, value float64, labels ...string)
+	// Duration records duration of an operation.
+	Duration(name string, d time.Duration, labels ...string)
+}
+
+// Metric names exported by ripsrc.
+const (
+	// CommitsProcessed is a counter of commits with blame results. Use rate to get commits/sec.
+	CommitsProcessed = "ripsrc_commits_processed_total"
+	// FilesProcessed is a counter of file results returned.
+	FilesProcessed = "ripsrc_files_processed_total"
+	// BlameCommitsInMemory is a gauge of commits which blame state is kept in memory waiting for their children to be processed.
+	BlameCommitsInMemory = "ripsrc_blame_commits_in_memory"
+	// BlameMergePartsPending is a gauge of merge diff parts waiting for the full merge to be read.
+	BlameMergePartsPending = "ripsrc_blame_merge_parts_pending"
+	// CheckpointReadBytes is a counter of checkpoint bytes read from disk.
+	CheckpointReadBytes = "ripsrc_checkpoint_read_bytes_total"
+	// CheckpointWriteBytes is a counter of checkpoint bytes written to disk.
+	CheckpointWriteBytes = "ripsrc_checkpoint_write_bytes_total"
+	// BlobCacheHits is a counter of files which code info was reused from an identical blob processed before.
+	BlobCacheHits = "ripsrc_blob_cache_hits_total"
+	// VerifiedFiles is a counter of file results cross-checked with git blame, see ripsrc Opts.VerificationSampleRate.
+	VerifiedFiles = "ripsrc_verified_files_total"
+	// VerificationDivergences is a counter of verified files which blame differs from git blame, with reason label of the first difference.
+	VerificationDivergences = "ripsrc_verification_divergences_total"
+	// StageDuration is the duration of a pipeline stage, with stage label.
+	StageDuration = "ripsrc_stage_duration_seconds"
+)
+
+// Stage label values used with StageDuration.
+const (
+	StageCommitGraph     = "commit_graph"
+	StageCommitMeta      = "commit_meta"
+	StageRegularCommit   = "regular_commit"
+	StageMergeCommit     = "merge_commit"
+	StageCodeInfo        = "code_info"
+	StageCheckpointRead  = "checkpoint_read"
+	StageCheckpointWrite = "checkpoint_write"
+)
+
+// Noop discards all metrics.
+type Noop struct{}
+
+func (Noop) Counter(name string, value float64, labels ...string)    {}
+func (Noop) Gauge(name string, value float64, labels ...string)      {}
+func (Noop) Duration(name string, d time.Duration, labels ...string) {}
+
+type multi []Sink
+
+// Multi returns sink that sends metrics to all passed sinks.
+func Multi(sinks ...Sink) Sink {
+	return multi(sinks)
+}
+
+func (s multi) Counter(name string, value float64, labels ...string) {
+	for _, sink := range s {
+		sink.Counter(name, value, labels...)
+	}
+}
+
+func (s multi) Gauge(name string, value float64, labels ...string) {
+	for _, sink := range s {
+		sink.Gauge(name, value, labels...)
+	}
+}
+
+func (s multi) Duration(name string, d time.Duration, labels ...string) {
+	for _, sink := range s {
+		sink.Duration(name, d, labels...)
+	}
+}
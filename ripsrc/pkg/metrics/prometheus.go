@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prometheus is a Sink that keeps metrics in memory and exposes them in Prometheus text format. Use it as http.Handler for /metrics endpoint.
+// Durations are exported as summaries with _sum and _count in seconds.
+type Prometheus struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	sums     map[string]float64
+	counts   map[string]uint64
+	// types keeps metric type by name for TYPE lines
+	types map[string]string
+}
+
+func NewPrometheus() *Prometheus {
+	s := &Prometheus{}
+	s.counters = map[string]float64{}
+	s.gauges = map[string]float64{}
+	s.sums = map[string]float64{}
+	s.counts = map[string]uint64{}
+	s.types = map[string]string{}
+	return s
+}
+
+func (s *Prometheus) Counter(name string, value float64, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.types[name] = "counter"
+	s.counters[series(name, labels)] += value
+}
+
+func (s *Prometheus) Gauge(name string, value float64, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.types[name] = "gauge"
+	s.gauges[series(name, labels)] = value
+}
+
+func (s *Prometheus) Duration(name string, d time.Duration, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.types[name] = "summary"
+	s.sums[series(name+"_sum", labels)] += d.Seconds()
+	s.counts[series(name+"_count", labels)]++
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format.
+func (s *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.WriteTo(w)
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+func (s *Prometheus) WriteTo(wr io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := map[string][]string{}
+	add := func(series string, v string) {
+		name := series
+		if i := strings.Index(series, "{"); i != -1 {
+			name = series[:i]
+		}
+		name = strings.TrimSuffix(strings.TrimSuffix(name, "_sum"), "_count")
+		lines[name] = append(lines[name], series+" "+v)
+	}
+	for k, v := range s.counters {
+		add(k, formatFloat(v))
+	}
+	for k, v := range s.gauges {
+		add(k, formatFloat(v))
+	}
+	for k, v := range s.sums {
+		add(k, formatFloat(v))
+	}
+	for k, v := range s.counts {
+		add(k, strconv.FormatUint(v, 10))
+	}
+
+	var names []string
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	total := int64(0)
+	for _, name := range names {
+		sort.Strings(lines[name])
+		n, err := fmt.Fprintf(wr, "# TYPE %v %v\n%v\n", name, s.types[name], strings.Join(lines[name], "\n"))
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// series returns name with labels in Prometheus format, labels are sorted by key.
+func series(name string, labels []string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	type kv struct{ K, V string }
+	var kvs []kv
+	for i := 0; i+1 < len(labels); i += 2 {
+		kvs = append(kvs, kv{labels[i], labels[i+1]})
+	}
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].K < kvs[j].K
+	})
+	var parts []string
+	for _, l := range kvs {
+		parts = append(parts, l.K+"="+strconv.Quote(l.V))
+	}
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
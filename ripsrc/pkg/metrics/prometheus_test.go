@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	s := NewPrometheus()
+	s.Counter(CommitsProcessed, 1, "repo", "r1")
+	s.Counter(CommitsProcessed, 2, "repo", "r1")
+	s.Gauge(BlameCommitsInMemory, 5)
+	s.Duration(StageDuration, 2*time.Second, "stage", StageCodeInfo, "repo", "r1")
+	s.Duration(StageDuration, time.Second, "stage", StageCodeInfo, "repo", "r1")
+
+	buf := bytes.NewBuffer(nil)
+	_, err := s.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE ripsrc_blame_commits_in_memory gauge
+ripsrc_blame_commits_in_memory 5
+# TYPE ripsrc_commits_processed_total counter
+ripsrc_commits_processed_total{repo="r1"} 3
+# TYPE ripsrc_stage_duration_seconds summary
+ripsrc_stage_duration_seconds_count{repo="r1",stage="code_info"} 2
+ripsrc_stage_duration_seconds_sum{repo="r1",stage="code_info"} 3
+`
+	if buf.String() != want {
+		t.Fatalf("wanted\n%v\ngot\n%v", want, buf.String())
+	}
+}
@@ -0,0 +1,233 @@
+// Package testkit builds synthetic git repositories programmatically for deterministic tests against ripsrc.
+//
+// Repositories are created in a temp dir using git command line, same as ripsrc itself, so no zipped fixtures are needed.
+// Author, committer and dates are fixed, each commit is one minute after the previous one, which makes commit hashes stable across runs.
+//
+//	r := testkit.New(t)
+//	defer r.Remove()
+//	c1 := r.Write("a.txt", "a\n").Commit("c1")
+//	r.Branch("feature").Write("a.txt", "a\nb\n").Commit("c2")
+//	r.Checkout("master").Merge("m1", "feature")
+package testkit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Opts is configuration for New.
+type Opts struct {
+	// DefaultBranch is the name of the initial branch. Default is master.
+	DefaultBranch string
+	// AuthorName used for commits. Default is User1.
+	AuthorName string
+	// AuthorEmail used for commits. Default is user1@example.com.
+	AuthorEmail string
+	// StartTime is the date of the first commit. Default is 2019-01-01 00:00:00 UTC.
+	StartTime time.Time
+}
+
+// Repo is a git repository in a temp dir. All methods call t.Fatal on error.
+type Repo struct {
+	t    testing.TB
+	opts Opts
+
+	tempDir string
+	dir     string
+
+	authorName  string
+	authorEmail string
+	now         time.Time
+}
+
+// New creates a new empty repo with default options.
+func New(t testing.TB) *Repo {
+	return NewWithOpts(t, Opts{})
+}
+
+// NewWithOpts creates a new empty repo.
+func NewWithOpts(t testing.TB, opts Opts) *Repo {
+	t.Helper()
+	if opts.DefaultBranch == "" {
+		opts.DefaultBranch = "master"
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = "User1"
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = "user1@example.com"
+	}
+	if opts.StartTime.IsZero() {
+		opts.StartTime = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	s := &Repo{}
+	s.t = t
+	s.opts = opts
+	s.authorName = opts.AuthorName
+	s.authorEmail = opts.AuthorEmail
+	s.now = opts.StartTime
+
+	tempDir, err := ioutil.TempDir("", "ripsrc-testkit-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tempDir = tempDir
+	s.dir = filepath.Join(tempDir, "repo")
+	err = os.MkdirAll(s.dir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.git("init", "-q")
+	s.git("symbolic-ref", "HEAD", "refs/heads/"+opts.DefaultBranch)
+	return s
+}
+
+// Dir returns the location of the repo. Pass it as ripsrc.Opts.RepoDir.
+func (s *Repo) Dir() string {
+	return s.dir
+}
+
+// Remove deletes the repo from disk.
+func (s *Repo) Remove() {
+	err := os.RemoveAll(s.tempDir)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// Author sets the author and committer for next commits.
+func (s *Repo) Author(name, email string) *Repo {
+	s.authorName = name
+	s.authorEmail = email
+	return s
+}
+
+// Write creates or overwrites a file in the working tree and stages it.
+func (s *Repo) Write(path string, content string) *Repo {
+	s.t.Helper()
+	loc := filepath.Join(s.dir, path)
+	err := os.MkdirAll(filepath.Dir(loc), 0777)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	err = ioutil.WriteFile(loc, []byte(content), 0666)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.git("add", "--", path)
+	return s
+}
+
+// Delete removes a file and stages the removal.
+func (s *Repo) Delete(path string) *Repo {
+	s.t.Helper()
+	s.git("rm", "-q", "--", path)
+	return s
+}
+
+// Rename moves a file and stages the rename.
+func (s *Repo) Rename(from, to string) *Repo {
+	s.t.Helper()
+	err := os.MkdirAll(filepath.Dir(filepath.Join(s.dir, to)), 0777)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.git("mv", "--", from, to)
+	return s
+}
+
+// Commit commits staged changes and returns the commit hash. Empty commits are allowed.
+func (s *Repo) Commit(msg string) string {
+	s.t.Helper()
+	s.tick()
+	s.git("commit", "-q", "--allow-empty", "--no-verify", "-m", msg)
+	return s.Head()
+}
+
+// Branch creates a new branch at current head and checks it out.
+func (s *Repo) Branch(name string) *Repo {
+	s.t.Helper()
+	s.git("checkout", "-q", "-b", name)
+	return s
+}
+
+// Checkout switches to existing branch or commit.
+func (s *Repo) Checkout(ref string) *Repo {
+	s.t.Helper()
+	s.git("checkout", "-q", ref)
+	return s
+}
+
+// Merge merges branches into current branch, always creating a merge commit, and returns merge commit hash.
+// Pass multiple branches for an octopus merge. Fails on conflicts.
+func (s *Repo) Merge(msg string, branches ...string) string {
+	s.t.Helper()
+	s.tick()
+	args := []string{"merge", "-q", "--no-ff", "--no-edit", "-m", msg}
+	args = append(args, branches...)
+	s.git(args...)
+	return s.Head()
+}
+
+// Tag creates a lightweight tag at current head. Pass message to create annotated tag.
+func (s *Repo) Tag(name string, message string) *Repo {
+	s.t.Helper()
+	if message == "" {
+		s.git("tag", name)
+		return s
+	}
+	s.tick()
+	s.git("tag", "-a", name, "-m", message)
+	return s
+}
+
+// Head returns the hash of current head commit.
+func (s *Repo) Head() string {
+	s.t.Helper()
+	return s.Git("rev-parse", "HEAD")
+}
+
+// Git runs arbitrary git command in the repo and returns trimmed output. Useful for cases not covered by other methods.
+func (s *Repo) Git(args ...string) string {
+	s.t.Helper()
+	return strings.TrimSpace(string(s.git(args...)))
+}
+
+// tick moves the clock for next commit or tag.
+func (s *Repo) tick() {
+	s.now = s.now.Add(time.Minute)
+}
+
+func (s *Repo) git(args ...string) []byte {
+	s.t.Helper()
+	date := s.now.Format(time.RFC3339)
+	c := exec.Command("git", args...)
+	c.Dir = s.dir
+	// isolate from user and system config, so results do not depend on the machine
+	c.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_AUTHOR_NAME="+s.authorName,
+		"GIT_AUTHOR_EMAIL="+s.authorEmail,
+		"GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME="+s.authorName,
+		"GIT_COMMITTER_EMAIL="+s.authorEmail,
+		"GIT_COMMITTER_DATE="+date,
+	)
+	out := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	c.Stdout = out
+	c.Stderr = stderr
+	err := c.Run()
+	if err != nil {
+		s.t.Fatal(fmt.Errorf("testkit: git %v failed, err: %v stderr: %s", strings.Join(args, " "), err, stderr.Bytes()))
+	}
+	return out.Bytes()
+}
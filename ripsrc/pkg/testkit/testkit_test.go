@@ -0,0 +1,79 @@
+package testkit
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+func TestDeterministicHashes(t *testing.T) {
+	build := func() string {
+		r := New(t)
+		defer r.Remove()
+		r.Write("a.txt", "a\n").Commit("c1")
+		return r.Head()
+	}
+	h1 := build()
+	h2 := build()
+	if h1 != h2 {
+		t.Fatalf("hashes are not stable, got %v and %v", h1, h2)
+	}
+}
+
+func TestRipsrcOnTestkitRepo(t *testing.T) {
+	r := New(t)
+	defer r.Remove()
+
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Branch("feature").Write("a.txt", "a\nb\n").Commit("c2")
+	r.Checkout("master")
+	c3 := r.Rename("a.txt", "b.txt").Commit("c3")
+	m := r.Merge("m1", "feature")
+
+	opts := ripsrc.Opts{}
+	opts.RepoDir = r.Dir()
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-testkit-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+	opts.CheckpointsDir = checkpointsDir
+	opts.Logger = logger.NewDefaultLogger(ioutil.Discard)
+	res, err := ripsrc.New(opts).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	for _, r := range res {
+		var shas []string
+		for _, l := range r.Lines {
+			shas = append(shas, l.SHA)
+		}
+		got[r.Commit.SHA+":"+r.Filename] = shas
+	}
+	want := map[string][]string{
+		c1 + ":a.txt": {c1},
+		c2 + ":a.txt": {c1, c2},
+		c3 + ":b.txt": {c1},
+		m + ":b.txt":  {c1, c2},
+	}
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			t.Fatalf("missing result for %v, got %v", k, got)
+		}
+		if len(g) != len(w) {
+			t.Fatalf("invalid lines for %v, wanted %v got %v", k, w, g)
+		}
+		for i := range w {
+			if w[i] != g[i] {
+				t.Fatalf("invalid lines for %v, wanted %v got %v", k, w, g)
+			}
+		}
+	}
+}
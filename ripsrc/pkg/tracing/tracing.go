@@ -0,0 +1,66 @@
+// Package tracing defines the tracer ripsrc uses to create spans for pipeline stages.
+//
+// The interfaces mirror OpenTelemetry, so adapting go.opentelemetry.io/otel/trace.Tracer is a few lines:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (s otelTracer) Start(ctx context.Context, name string, attrs ...string) (context.Context, tracing.Span) {
+//		ctx, span := s.t.Start(ctx, name)
+//		sp := otelSpan{span}
+//		sp.SetAttributes(attrs...)
+//		return ctx, sp
+//	}
+//
+//	type otelSpan struct{ s trace.Span }
+//
+//	func (s otelSpan) SetAttributes(attrs ...string) {
+//		for i := 0; i+1 < len(attrs); i += 2 {
+//			s.s.SetAttributes(attribute.String(attrs[i], attrs[i+1]))
+//		}
+//	}
+//	func (s otelSpan) RecordError(err error) { s.s.RecordError(err) }
+//	func (s otelSpan) End()                  { s.s.End() }
+package tracing
+
+import "context"
+
+// Tracer creates spans. Attributes are key-value pairs, same as logger args.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttributes(attrs ...string)
+	RecordError(err error)
+	End()
+}
+
+// Span names used by ripsrc.
+const (
+	SpanCode            = "ripsrc.code"
+	SpanCommitGraph     = "ripsrc.commit_graph"
+	SpanCommitMeta      = "ripsrc.commit_meta"
+	SpanCheckpointRead  = "ripsrc.checkpoint_read"
+	SpanCheckpointWrite = "ripsrc.checkpoint_write"
+	// SpanBlameBatch covers diff parse and incblame apply for a batch of commits. Time spent in each is set in parse_ms and apply_ms attributes.
+	SpanBlameBatch = "ripsrc.blame_batch"
+	// SpanCodeInfoBatch covers fileinfo and code stats for a batch of commits.
+	SpanCodeInfoBatch = "ripsrc.code_info_batch"
+)
+
+// BatchSize is the number of commits in SpanBlameBatch and SpanCodeInfoBatch.
+const BatchSize = 100
+
+// Noop creates spans that do nothing.
+type Noop struct{}
+
+func (Noop) Start(ctx context.Context, name string, attrs ...string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...string) {}
+func (noopSpan) RecordError(err error)         {}
+func (noopSpan) End()                          {}
@@ -0,0 +1,102 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// Plan describes what Code or CodeByCommit would process with the current options.
+type Plan struct {
+	RepoDir string
+
+	// Branches that would be processed. Only the default branch, unless AllBranches or Refs are set. When Refs are set, contains Refs.
+	Branches []string
+
+	// CheckpointCommit is the last commit stored in checkpoint. Empty if there is no checkpoint yet.
+	CheckpointCommit string
+
+	// Commits is the number of commits that would be processed, including merges.
+	Commits int
+
+	// Merges is the number of merge commits that would be processed. Merges are usually slower than regular commits.
+	Merges int
+
+	// FileOperations is the estimated number of incremental blame file operations, the sum of changed files over all commits.
+	FileOperations int
+}
+
+// Plan returns what would be processed by Code or CodeByCommit without running incremental blame or code info.
+// Only commit graph and commit metadata are retrieved, so it is much cheaper than a full run and could be used to predict cost.
+func (s *Ripsrc) Plan(ctx context.Context) (res Plan, _ error) {
+	ctx = s.gitContext(ctx)
+
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	res.RepoDir = s.opts.RepoDir
+
+	switch {
+	case len(s.opts.Refs) != 0:
+		res.Branches = s.opts.Refs
+	case s.opts.AllBranches:
+		branches, err := s.getBranches(ctx)
+		if err != nil {
+			return res, err
+		}
+		for _, b := range branches {
+			res.Branches = append(res.Branches, b.Name)
+		}
+	default:
+		b, err := s.getDefaultBranch(ctx)
+		if err != nil {
+			return res, err
+		}
+		res.Branches = []string{b.Name}
+	}
+
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return res, err
+	}
+	gitProcessor := process.New(process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	})
+	res.CheckpointCommit, err = gitProcessor.CheckpointCommit()
+	if err != nil {
+		return res, err
+	}
+
+	err = s.getCommitInfo(ctx)
+	if err != nil {
+		return res, err
+	}
+	for _, c := range s.commitMeta {
+		res.Commits++
+		if len(c.Parents) > 1 {
+			res.Merges++
+		}
+		res.FileOperations += len(c.Files)
+	}
+	return res, nil
+}
+
+// OutputStats writes human-readable plan.
+func (s Plan) OutputStats(wr io.Writer) {
+	fmt.Fprintln(wr, "repo:", s.RepoDir)
+	fmt.Fprintln(wr, "branches:", s.Branches)
+	checkpoint := s.CheckpointCommit
+	if checkpoint == "" {
+		checkpoint = "none"
+	}
+	fmt.Fprintln(wr, "last checkpoint:", checkpoint)
+	fmt.Fprintln(wr, "commits:", s.Commits)
+	fmt.Fprintln(wr, "merges:", s.Merges)
+	fmt.Fprintln(wr, "estimated file operations:", s.FileOperations)
+}
@@ -0,0 +1,35 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestPlan(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Write("b.txt", "b\n").Commit("c1")
+	r.Branch("feature").Write("a.txt", "a\na2\n").Commit("c2")
+	r.Checkout("master").Write("b.txt", "b\nb2\n").Commit("c3")
+	r.Merge("m1", "feature")
+
+	rs := New(Opts{RepoDir: r.Dir(), AllBranches: true})
+	got, err := rs.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Plan{
+		RepoDir:  r.Dir(),
+		Branches: []string{"feature", "master"},
+		Commits:  4,
+		Merges:   1,
+		// 2 in c1, 1 in c2, 1 in c3 and 1 in merge
+		FileOperations: 5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid plan, want\n%+v\ngot\n%+v", want, got)
+	}
+}
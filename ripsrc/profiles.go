@@ -0,0 +1,123 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+)
+
+// Profile is a kind of runtime profile captured during CodeByCommit, see Opts.Profiles.
+type Profile string
+
+const (
+	// ProfileCPU captures CPU profile of the whole run, written as cpu.pprof.
+	ProfileCPU = Profile("cpu")
+	// ProfileHeap captures heap profile at the end of the run, written as heap.pprof.
+	ProfileHeap = Profile("heap")
+	// ProfileTrace captures execution trace of the whole run, written as trace.out. View it using go tool trace.
+	ProfileTrace = Profile("trace")
+)
+
+func (s Profile) valid() bool {
+	switch s {
+	case ProfileCPU, ProfileHeap, ProfileTrace:
+		return true
+	}
+	return false
+}
+
+func (s Profile) filename() string {
+	if s == ProfileTrace {
+		return "trace.out"
+	}
+	return string(s) + ".pprof"
+}
+
+const profilesDirName = "profiles"
+
+// startProfiles starts profiles selected in Opts.Profiles, written to a new dir for the run next to the checkpoint. Returns written files and stop, which must be called at the end of the run.
+// CPU profile and execution trace are global to the process, if another one is already running, for example when processing repos in parallel, the profile is skipped with a warning.
+func (s *Ripsrc) startProfiles(ctx context.Context, started time.Time) (files []string, stop func(), _ error) {
+	stop = func() {}
+	if len(s.opts.Profiles) == 0 {
+		return nil, stop, nil
+	}
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return nil, stop, err
+	}
+	dir := filepath.Join(filepath.Dir(loc), profilesDirName, started.UTC().Format("20060102T150405.000Z"))
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, stop, err
+	}
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	for _, kind := range s.opts.Profiles {
+		fn := filepath.Join(dir, kind.filename())
+		if kind == ProfileHeap {
+			stops = append(stops, func() {
+				err := writeHeapProfile(fn)
+				if err != nil {
+					s.opts.Logger.Warn("could not write heap profile", "file", fn, "err", err)
+				}
+			})
+			files = append(files, fn)
+			continue
+		}
+		f, err := os.Create(fn)
+		if err != nil {
+			stop()
+			return nil, func() {}, err
+		}
+		switch kind {
+		case ProfileCPU:
+			err = pprof.StartCPUProfile(f)
+		case ProfileTrace:
+			err = trace.Start(f)
+		}
+		if err != nil {
+			s.opts.Logger.Warn("could not start profile, skipping", "profile", kind, "err", err)
+			f.Close()
+			os.Remove(fn)
+			continue
+		}
+		kind := kind
+		stops = append(stops, func() {
+			switch kind {
+			case ProfileCPU:
+				pprof.StopCPUProfile()
+			case ProfileTrace:
+				trace.Stop()
+			}
+			f.Close()
+		})
+		files = append(files, fn)
+	}
+	s.opts.Logger.Info("writing profiles", "dir", dir)
+	return files, stop, nil
+}
+
+func writeHeapProfile(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	// up to date statistics as of the end of the run
+	runtime.GC()
+	err = pprof.Lookup("heap").WriteTo(f, 0)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not write heap profile, err: %v", err)
+	}
+	return f.Close()
+}
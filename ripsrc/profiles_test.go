@@ -0,0 +1,45 @@
+package ripsrc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestProfiles(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-profiles-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	rip := New(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, Profiles: []Profile{ProfileCPU, ProfileHeap, ProfileTrace}})
+	runAll(t, rip)
+	m := rip.RunManifest()
+	if m == nil || len(m.Profiles) != 3 {
+		t.Fatalf("expected 3 profiles in manifest, got %+v", m)
+	}
+	want := []string{"cpu.pprof", "heap.pprof", "trace.out"}
+	for i, fn := range m.Profiles {
+		if filepath.Base(fn) != want[i] {
+			t.Errorf("wanted %v, got %v", want[i], fn)
+		}
+		info, err := os.Stat(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("profile is empty %v", fn)
+		}
+		rel, err := filepath.Rel(checkpointsDir, fn)
+		if err != nil || rel == fn || rel[0] == '.' {
+			t.Errorf("profile not written next to checkpoints %v", fn)
+		}
+	}
+}
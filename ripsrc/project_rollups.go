@@ -0,0 +1,180 @@
+package ripsrc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/projects"
+)
+
+// Project is a logical project inside the repo, detected by build manifests such as go.mod, package.json, pom.xml or BUILD.
+type Project = projects.Project
+
+// ProjectRollup is the aggregate of all files of a project at the last processed commit. Files of nested projects are not included in the parent project.
+type ProjectRollup struct {
+	// ProjectID is the root directory of the project, same as BlameResult.ProjectID. Empty for files outside of any project.
+	ProjectID string
+	RollupTotals
+	// Commits is the number of processed commits that added, changed or removed files of the project.
+	Commits int
+	// LastCommit is the sha of the last processed commit that added, changed or removed a file of the project.
+	LastCommit string
+	// LastModified is the date of LastCommit.
+	LastModified time.Time
+}
+
+type projectFile struct {
+	dirFile
+	project string
+}
+
+type projectState struct {
+	commits int
+	last    Commit
+}
+
+// ProjectRollups aggregates blames returned from CodeByCommit with Opts.Projects into per project totals.
+// Add every commit with AddCommit and its blames with AddBlame in the order returned, then call Result.
+// With checkpoints only files changed in new commits are included, process without checkpoints to get rollups of the whole tree.
+type ProjectRollups struct {
+	// CommitDate selects the commit date used for LastModified.
+	CommitDate CommitDate
+
+	files    map[string]projectFile
+	projects map[string]*projectState
+	current  Commit
+}
+
+// NewProjectRollups creates empty aggregation.
+func NewProjectRollups() *ProjectRollups {
+	s := &ProjectRollups{}
+	s.files = map[string]projectFile{}
+	s.projects = map[string]*projectState{}
+	return s
+}
+
+// AddCommit must be called before AddBlame for blames of the commit. Removes files that were removed or renamed by the commit, since blames are not returned for them.
+func (s *ProjectRollups) AddCommit(c Commit) {
+	s.current = Commit{SHA: c.SHA, Date: s.CommitDate.Of(c)}
+	for fn, f := range c.Files {
+		if f.Status != GitFileCommitStatusRemoved {
+			continue
+		}
+		if pf, ok := s.files[fn]; ok {
+			s.touch(pf.project)
+			delete(s.files, fn)
+		}
+	}
+}
+
+// touch records that the current commit changed the project.
+func (s *ProjectRollups) touch(project string) {
+	p, ok := s.projects[project]
+	if !ok {
+		p = &projectState{}
+		s.projects[project] = p
+	}
+	if p.last.SHA == s.current.SHA {
+		return
+	}
+	p.commits++
+	p.last = s.current
+}
+
+// AddBlame replaces the summary of the file with the state after the current commit.
+func (s *ProjectRollups) AddBlame(r BlameResult) {
+	s.touch(r.ProjectID)
+	if r.Status == GitFileCommitStatusRemoved {
+		delete(s.files, r.Filename)
+		return
+	}
+	s.files[r.Filename] = projectFile{dirFile: newDirFile(r), project: r.ProjectID}
+}
+
+// Result returns rollups of projects that contain files, sorted by ProjectID.
+func (s *ProjectRollups) Result() (res []ProjectRollup) {
+	rollups := map[string]*ProjectRollup{}
+	for _, f := range s.files {
+		p, ok := rollups[f.project]
+		if !ok {
+			p = &ProjectRollup{}
+			p.ProjectID = f.project
+			p.RollupTotals = newRollupTotals()
+			st := s.projects[f.project]
+			p.Commits = st.commits
+			p.LastCommit = st.last.SHA
+			p.LastModified = st.last.Date
+			rollups[f.project] = p
+		}
+		p.add(f.dirFile)
+	}
+	for _, p := range rollups {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ProjectID < res[j].ProjectID
+	})
+	return
+}
+
+// Projects returns projects detected at the first of Opts.Refs or HEAD, sorted by ID.
+func (s *Ripsrc) Projects(ctx context.Context) ([]Project, error) {
+	ctx = s.gitContext(ctx)
+	err := s.prepareGitExec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.listTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return projects.Detect(files).Projects(), nil
+}
+
+// ProjectRollups processes the repo using CodeByCommit with Projects and returns per project totals at the last processed commit. Uses and updates checkpoints the same way as CodeByCommit.
+func (s *Ripsrc) ProjectRollups(ctx context.Context, res chan ProjectRollup) error {
+	defer close(res)
+	s.opts.Projects = true
+	agg := NewProjectRollups()
+	agg.CommitDate = s.opts.CommitDate
+
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			agg.AddCommit(c.Commit)
+			for b := range c.Blames {
+				agg.AddBlame(b)
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return err
+	}
+	for _, r := range agg.Result() {
+		select {
+		case res <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) ProjectRollupsSlice(ctx context.Context) (res []ProjectRollup, _ error) {
+	resChan := make(chan ProjectRollup)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.ProjectRollups(ctx, resChan)
+	<-done
+	return res, err
+}
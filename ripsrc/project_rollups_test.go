@@ -0,0 +1,45 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestProjectRollups(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("go.mod", "module x\n").Write("main.go", "package main\n").Commit("c1")
+	r.Write("web/package.json", "{}\n").Write("web/app.js", "a()\nb()\n").Commit("c2")
+	c3 := r.Write("web/app.js", "a()\n").Commit("c3")
+
+	rs := New(Opts{RepoDir: r.Dir()})
+	projects, err := rs.Projects(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantProjects := []Project{{ID: ".", Kinds: []string{"go"}}, {ID: "web", Kinds: []string{"npm"}}}
+	if !reflect.DeepEqual(projects, wantProjects) {
+		t.Fatalf("got projects %v, want %v", projects, wantProjects)
+	}
+
+	res, err := rs.ProjectRollupsSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 projects, got %+v", res)
+	}
+	root, web := res[0], res[1]
+	if root.ProjectID != "." || root.Files != 2 || root.Commits != 1 {
+		t.Errorf("unexpected root project %+v", root)
+	}
+	if web.ProjectID != "web" || web.Files != 2 || web.Commits != 2 || web.LastCommit != c3 {
+		t.Errorf("unexpected web project %+v", web)
+	}
+	if web.LocByLanguage["JavaScript"] != 1 {
+		t.Errorf("unexpected web languages %v", web.LocByLanguage)
+	}
+}
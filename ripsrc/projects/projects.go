@@ -0,0 +1,111 @@
+// Package projects detects project roots inside a monorepo based on build manifests, such as go.mod or package.json.
+package projects
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Manifests maps file names that mark a project root to the project kind.
+var Manifests = map[string]string{
+	"go.mod":           "go",
+	"package.json":     "npm",
+	"pom.xml":          "maven",
+	"build.gradle":     "gradle",
+	"build.gradle.kts": "gradle",
+	"BUILD":            "bazel",
+	"BUILD.bazel":      "bazel",
+	"Cargo.toml":       "cargo",
+	"pyproject.toml":   "python",
+	"setup.py":         "python",
+	"composer.json":    "composer",
+	"Gemfile":          "ruby",
+}
+
+// ignoredDirs contain dependencies rather than projects of the repo.
+var ignoredDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"third_party":  true,
+	"testdata":     true,
+}
+
+// Project is a logical project inside the repo.
+type Project struct {
+	// ID is the root directory of the project, "." for repo root.
+	ID string
+	// Kinds are the kinds of manifests found in the root directory, sorted. For example a directory with both go.mod and package.json has kinds go and npm.
+	Kinds []string
+}
+
+// Index finds the project of a file.
+type Index struct {
+	projects map[string]*Project
+}
+
+// Detect returns index of projects found in the list of file paths relative to repo root.
+func Detect(paths []string) *Index {
+	s := &Index{}
+	s.projects = map[string]*Project{}
+	for _, p := range paths {
+		kind, ok := Manifests[path.Base(p)]
+		if !ok || ignored(p) {
+			continue
+		}
+		dir := path.Dir(p)
+		pr, ok := s.projects[dir]
+		if !ok {
+			pr = &Project{ID: dir}
+			s.projects[dir] = pr
+		}
+		if !contains(pr.Kinds, kind) {
+			pr.Kinds = append(pr.Kinds, kind)
+			sort.Strings(pr.Kinds)
+		}
+	}
+	return s
+}
+
+func ignored(p string) bool {
+	for _, part := range strings.Split(path.Dir(p), "/") {
+		if ignoredDirs[part] {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the project with the closest root directory containing the file. Returns false if file is not in any project.
+func (s *Index) Find(filePath string) (res Project, ok bool) {
+	dir := path.Dir(filePath)
+	for {
+		if pr, ok := s.projects[dir]; ok {
+			return *pr, true
+		}
+		if dir == "." {
+			return res, false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// Projects returns all projects sorted by ID.
+func (s *Index) Projects() (res []Project) {
+	for _, pr := range s.projects {
+		res = append(res, *pr)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return
+}
@@ -0,0 +1,52 @@
+package projects
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	idx := Detect([]string{
+		"README.md",
+		"go.mod",
+		"services/api/go.mod",
+		"services/api/main.go",
+		"web/package.json",
+		"web/BUILD",
+		"web/src/app.ts",
+		"web/node_modules/left-pad/package.json",
+		"java/pom.xml",
+	})
+	want := []Project{
+		{ID: ".", Kinds: []string{"go"}},
+		{ID: "java", Kinds: []string{"maven"}},
+		{ID: "services/api", Kinds: []string{"go"}},
+		{ID: "web", Kinds: []string{"bazel", "npm"}},
+	}
+	if got := idx.Projects(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	cases := map[string]string{
+		"README.md":                              ".",
+		"services/api/main.go":                   "services/api",
+		"services/other/x.go":                    ".",
+		"web/src/app.ts":                         "web",
+		"web/node_modules/left-pad/package.json": "web",
+	}
+	for p, want := range cases {
+		got, ok := idx.Find(p)
+		if !ok || got.ID != want {
+			t.Errorf("path %v: got %v %v, want %v", p, got.ID, ok, want)
+		}
+	}
+}
+
+func TestFindNoRootProject(t *testing.T) {
+	idx := Detect([]string{"a/go.mod", "b/x.txt"})
+	if _, ok := idx.Find("b/x.txt"); ok {
+		t.Error("expected file outside of projects to not be found")
+	}
+	if got, ok := idx.Find("a/b/c.go"); !ok || got.ID != "a" {
+		t.Errorf("unexpected project %v %v", got, ok)
+	}
+}
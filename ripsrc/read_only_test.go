@@ -0,0 +1,98 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestReadOnly(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Branch("b1").Write("b.txt", "b\n").Commit("c2")
+	r.Checkout("master").Write("a.txt", "a\nb\n").Commit("c3")
+	// unstaged change, status and diff would refresh the index without ReadOnly
+	r.Write("a.txt", "changed\n")
+
+	checkpointsDir, err := ioutil.TempDir("", "ripsrc-checkpoints-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkpointsDir)
+
+	snapshot := func() map[string]string {
+		t.Helper()
+		res := map[string]string{}
+		err := filepath.Walk(r.Dir(), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			res[path] = fmt.Sprint(info.Size(), info.ModTime().UnixNano(), info.Mode())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	before := snapshot()
+
+	opts := Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, ReadOnly: true, AllBranches: true}
+	got := codeByCommitSHAs(t, opts)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 commits, got %v", got)
+	}
+	s := New(opts)
+	_, err = s.BranchEventsSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.NeedsProcessing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.BranchesSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := snapshot()
+	if !reflect.DeepEqual(before, after) {
+		for k, v := range after {
+			if before[k] != v {
+				t.Errorf("changed in repo: %v", k)
+			}
+		}
+		for k := range before {
+			if _, ok := after[k]; !ok {
+				t.Errorf("removed from repo: %v", k)
+			}
+		}
+	}
+}
+
+func TestReadOnlyValidate(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+
+	err := Opts{RepoDir: r.Dir(), ReadOnly: true}.Validate()
+	if err == nil {
+		t.Error("expected error without CheckpointsDir")
+	}
+	err = Opts{RepoDir: r.Dir(), ReadOnly: true, CheckpointsDir: filepath.Join(r.Dir(), "checkpoints")}.Validate()
+	if err == nil {
+		t.Error("expected error for CheckpointsDir inside RepoDir")
+	}
+	err = Opts{RepoDir: r.Dir(), ReadOnly: true, CheckpointsDir: r.Dir() + "-checkpoints"}.Validate()
+	if err != nil {
+		t.Errorf("expected CheckpointsDir outside of RepoDir to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package ripsrc
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Redaction is a kind of data removed from commit messages, see Opts.RedactMessages.
+type Redaction string
+
+const (
+	// RedactEmails replaces email addresses with [email].
+	RedactEmails = Redaction("emails")
+	// RedactTokens replaces access tokens, keys and passwords with [token]. Detects tokens of common services, private keys, JWTs and values of key=value pairs with names such as password, secret or token.
+	RedactTokens = Redaction("tokens")
+	// RedactURLs replaces URLs with [url].
+	RedactURLs = Redaction("urls")
+)
+
+func (s Redaction) valid() bool {
+	switch s {
+	case RedactEmails, RedactTokens, RedactURLs:
+		return true
+	}
+	return false
+}
+
+// redactionRule replaces matches of re with repl, which could refer to submatches.
+type redactionRule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// redactionRules are rules of each kind. Tokens are redacted before urls and emails, since tokens could be part of urls and urls could contain user@host.
+var redactionRules = map[Redaction][]redactionRule{
+	RedactTokens: {
+		{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[token]"},
+		{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[abposr]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|sk_live_[0-9A-Za-z]{24,})\b`), "[token]"},
+		{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`), "[token]"},
+		{regexp.MustCompile(`(?i)\b((?:api[_-]?key|access[_-]?key|secret|token|password|passwd|pwd)["']?\s*[:=]\s*)["']?[^\s"']+["']?`), "${1}[token]"},
+	},
+	RedactURLs: {
+		{regexp.MustCompile(`(?i)\b(?:https?|ftp|ssh|git)://[^\s<>"'()\[\]]+`), "[url]"},
+	},
+	RedactEmails: {
+		{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`), "[email]"},
+	},
+}
+
+// redactionOrder is the order in which kinds are applied, see redactionRules.
+var redactionOrder = []Redaction{RedactTokens, RedactURLs, RedactEmails}
+
+// redactor removes data selected in Opts.RedactMessages and Opts.RedactPatterns from commit and tag messages. Nil redactor returns values unchanged.
+type redactor struct {
+	rules []redactionRule
+}
+
+// newRedactor returns nil if nothing is redacted. Patterns are checked in Validate.
+func newRedactor(kinds []Redaction, patterns []string) (*redactor, error) {
+	selected := map[Redaction]bool{}
+	for _, k := range kinds {
+		selected[k] = true
+	}
+	s := &redactor{}
+	for _, k := range redactionOrder {
+		if selected[k] {
+			s.rules = append(s.rules, redactionRules[k]...)
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %v", p, err)
+		}
+		s.rules = append(s.rules, redactionRule{re: re, repl: "[redacted]"})
+	}
+	if len(s.rules) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *redactor) text(v string) string {
+	for _, r := range s.rules {
+		v = r.re.ReplaceAllString(v, r.repl)
+	}
+	return v
+}
+
+// commit returns commit with redacted message and trailer values.
+func (s *redactor) commit(c Commit) Commit {
+	if s == nil {
+		return c
+	}
+	c.Message = s.text(c.Message)
+	if len(c.Trailers) != 0 {
+		trailers := make([]Trailer, len(c.Trailers))
+		for i, t := range c.Trailers {
+			t.Value = s.text(t.Value)
+			trailers[i] = t
+		}
+		c.Trailers = trailers
+	}
+	return c
+}
+
+// tag returns tag with redacted message.
+func (s *redactor) tag(t Tag) Tag {
+	if s == nil {
+		return t
+	}
+	t.Message = s.text(t.Message)
+	return t
+}
+
+// scrubCommit applies Opts.RedactMessages and Opts.Anonymize to a commit before it is returned.
+func (s *Ripsrc) scrubCommit(c Commit) Commit {
+	return s.anonymizer.commit(s.redactor.commit(c))
+}
+
+// scrubTag applies Opts.RedactMessages and Opts.Anonymize to a tag before it is returned.
+func (s *Ripsrc) scrubTag(t Tag) Tag {
+	return s.anonymizer.tag(s.redactor.tag(t))
+}
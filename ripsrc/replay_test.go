@@ -0,0 +1,84 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestGitRecordReplay(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c1")
+	r.Branch("b").Write("a.go", "package a\n\nfunc a() {}\n\nfunc b() {}\n").Rename("a.go", "b.go").Commit("c2")
+	r.Checkout("master").Write("c.go", "package c\n").Commit("c3")
+	r.Merge("c4", "b")
+
+	bundle := t.TempDir()
+	want, err := New(Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir(), GitRecordDir: bundle}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 3 {
+		t.Fatalf("expected 3 commits, got %v", len(want))
+	}
+	r.Remove()
+
+	got, err := New(Opts{RepoDir: t.TempDir(), CheckpointsDir: t.TempDir(), GitReplayDir: bundle}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed results differ\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestGitReplayIncremental(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Commit("c1")
+	r.Branch("b").Checkout("master")
+	c2 := r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c2")
+
+	bundle := t.TempDir()
+	checkpoints := t.TempDir()
+	_, err := New(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpoints, GitRecordDir: bundle}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c3 := r.Write("a.go", "package a\n\nfunc a() { a() }\n").Commit("c3")
+	// c2 is not an ancestor of b, so checking history rewrite gets merge-base exit code 1 before trying master
+	incremental := Opts{CommitFromIncl: c2, CommitFromMakeNonIncl: true, Refs: []string{"b", "master"}}
+	opts := incremental
+	opts.RepoDir = r.Dir()
+	opts.CheckpointsDir = checkpoints
+	incrementalBundle := t.TempDir()
+	opts.GitRecordDir = incrementalBundle
+	want, err := New(opts).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 1 || want[0].Commit.SHA != c3 {
+		t.Fatalf("expected only c3 to be processed, got %v", len(want))
+	}
+	r.Remove()
+
+	checkpoints = t.TempDir()
+	_, err = New(Opts{RepoDir: t.TempDir(), CheckpointsDir: checkpoints, GitReplayDir: bundle}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = incremental
+	opts.RepoDir = t.TempDir()
+	opts.CheckpointsDir = checkpoints
+	opts.GitReplayDir = incrementalBundle
+	got, err := New(opts).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed results differ\ngot  %+v\nwant %+v", got, want)
+	}
+}
@@ -7,12 +7,18 @@ import (
 
 	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
 
+	"github.com/pinpt/ripsrc/ripsrc/branchmeta"
+	"github.com/pinpt/ripsrc/ripsrc/codeowners"
 	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
 	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
 	"github.com/pinpt/ripsrc/ripsrc/gitexec"
 	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/tracing"
+	"github.com/pinpt/ripsrc/ripsrc/projects"
 
 	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/history3/verify"
 )
 
 // Opts is configuration for running ripsrc on a single repo.
@@ -23,33 +29,267 @@ type Opts struct {
 	// Logger object for info and debug.
 	Logger logger.Logger
 
+	// Metrics receives pipeline metrics such as processed commits, blame state size, checkpoint bytes and per-stage durations.
+	// Optional, use metrics.NewPrometheus() to expose them to Prometheus.
+	Metrics metrics.Sink
+
+	// Tracer creates spans for pipeline stages, keyed by repo and commit batch. Optional, see tracing package for OpenTelemetry adapter.
+	Tracer tracing.Tracer
+
+	// ReadOnly guarantees that nothing is written inside the repo, for example for snapshots or NFS-mounted repos. Requires CheckpointsDir outside of the repo, all state and locks are kept there or in TMPDIR.
+	// Git commands run with optional locks, automatic gc and maintenance disabled, so that they do not refresh the index or write other files as a side effect.
+	ReadOnly bool
+
 	// CheckpointsDir is the directory to store incremental data cache for this repo.
-	// If empty, directory is created inside repoDir.
+	// If empty, directory is created inside repoDir, or inside the main worktree when RepoDir is a linked worktree.
 	CheckpointsDir string
 
+	// SharedCheckpointsDir is the directory for checkpoints shared between repos, for example forks of the same upstream. Could be used by multiple repos at the same time.
+	// Checkpoints are stored there by commit hash after each run. When CommitFromIncl is set and CheckpointsDir has no checkpoint for it, a shared checkpoint for the same commit is used instead,
+	// so processing a fork could continue from upstream commit it is based on.
+	SharedCheckpointsDir string
+
+	// NamespaceCheckpoints stores checkpoints in a per repo subdirectory of CheckpointsDir, so that one CheckpointsDir could be used for many repos.
+	// The subdirectory is named by RepoID. Use ListCheckpoints and DeleteCheckpoints to manage stored checkpoints. Requires CheckpointsDir.
+	NamespaceCheckpoints bool
+
+	// RepoID is the stable identity of the repo used with NamespaceCheckpoints. Must be a valid directory name.
+	// If empty, hash of the origin remote url is used, or hash of absolute RepoDir if repo has no origin. For linked worktrees the main worktree dir is used, so all worktrees share checkpoints.
+	RepoID string
+
 	// NoStrictResume forces incremental processing to avoid checking that it continues from the same commit in previously finished on. Since incrementals save a large number of previous commits, it works even starting on another commit.
 	NoStrictResume bool
 
 	// CommitFromIncl process starting from this commit (including this commit).
 	CommitFromIncl string
 
+	// SegmentConcurrency is the max number of commits blamed in parallel. Commits on independent branches are processed concurrently, results are returned in the same order as with sequential processing.
+	// Default is 0, which processes commits sequentially. Up to 4*SegmentConcurrency commits are blamed ahead of the oldest unfinished one, which bounds results kept in memory while waiting for the caller, see CodeByCommit.
+	SegmentConcurrency int
+
+	// CheckpointEvery writes intermediate checkpoint every N processed commits, so that interrupted run could be resumed using ResumeInterrupted. 0 disables.
+	CheckpointEvery int
+
+	// CheckpointCompression is the name of compression used when writing checkpoints, "gzip" (default), "none" or compression added using RegisterCheckpointCompression.
+	// Existing checkpoints are read regardless of compression used to write them.
+	CheckpointCompression string
+
+	// CheckpointInterval writes intermediate checkpoint if at least this time passed since the previous one. 0 disables.
+	CheckpointInterval time.Duration
+
+	// ResumeInterrupted continues from intermediate checkpoint left by interrupted run with the same CommitFromIncl, AllBranches and Refs.
+	// Commits processed before the intermediate checkpoint are not returned again.
+	ResumeInterrupted bool
+
+	// MaxDuration stops CodeByCommit at the next commit boundary after this time passed since the call started. 0 disables.
+	// Intermediate checkpoint is written for the returned commits and the call returns *PartialError. Run again with ResumeInterrupted and the same options to continue.
+	// With ForceFullReprocess the previous checkpoints are restored instead, since forced runs can't be resumed.
+	MaxDuration time.Duration
+
+	// MaxMemoryBytes stops CodeByCommit at the next commit boundary when heap allocated by the process is over this size. 0 disables. Behaves the same as MaxDuration when exceeded.
+	// Heap is shared by everything running in the process, so this only bounds a single repo when repos are processed one at a time.
+	MaxMemoryBytes uint64
+
+	// CommitDeadline is the time budget for blaming a single commit. When exceeded, remaining files of the commit are skipped with unknown blame, the same as files which diff could not be applied, and processing continues.
+	// Such commits have CommitCode.DeadlineExceeded set and are listed in SkipReport.Commits. Protects the run from pathological commits, for example vendoring a huge number of files. Merge commits are not limited. 0 disables.
+	CommitDeadline time.Duration
+
+	// SkippedFiles controls whether files skipped by code analysis are returned and with which data. Default is SkippedFilesReason.
+	SkippedFiles SkippedFiles
+
+	// VendorRules are path patterns of vendored files, checked in addition to fileinfo.DefaultVendorRules, which detect python virtualenvs, third_party, bower_components and other dependency trees.
+	// Vendored files are skipped with the name of the matched rule in SkipReport.
+	VendorRules []VendorRule
+
+	// NoDefaultVendorRules set to true to only use VendorRules, for repos that keep own code in directories such as third_party.
+	NoDefaultVendorRules bool
+
+	// CopyrightHolders are names of copyright holders of the repo code, such as the company name. When set, files in subdirectories with copyright headers of other holders are skipped as vendored, which detects checked-in SDKs and copied libraries.
+	CopyrightHolders []string
+
+	// SkipReport records every path excluded from code analysis with the matching rule, retrievable using Ripsrc.SkipReport after the run.
+	SkipReport bool
+
+	// TrackDeletions fills BlameResult.DeletedLines with lines deleted or rewritten by each commit. Used by CodeSurvival.
+	TrackDeletions bool
+
+	// TrackLineMapping fills BlameLine.PrevLine with the line number each line had before the commit, derived from the diff, so that editors could map annotations across versions.
+	TrackLineMapping bool
+
+	// TrackHunks fills BlameResult.Hunks with ranges and changed line counts of each hunk of the file diff, so that diff analytics do not need to parse patches again.
+	TrackHunks bool
+
+	// IncludePatches fills BlameResult.Patch with the unified diff of the file in each commit, so that consumers that need the patch alongside blame, such as code review model training, do not need to run git again.
+	IncludePatches bool
+
+	// MaxPatchSize is the max size in bytes of BlameResult.Patch. Longer patches are cut at the last full line and have PatchTruncated set.
+	// Default is DefaultMaxPatchSize, 1MB, negative for no limit.
+	MaxPatchSize int
+
+	// ClassifyChurn fills CommitCode.Churn with lines deleted or rewritten by each commit classified as self-churn, rework or legacy refactor. Enables TrackDeletions.
+	ClassifyChurn bool
+
+	// ChurnWindow is the age of lines after which rewriting them is counted as legacy refactor instead of self-churn or rework. Default is DefaultChurnWindow, 21 days.
+	ChurnWindow time.Duration
+
+	// ForceFullReprocess ignores CommitFromIncl and existing checkpoints and processes all commits from scratch, for example to rebuild corrupted checkpoints.
+	// Existing checkpoints are kept as backup until processing succeeds and restored if it fails.
+	ForceFullReprocess bool
+
+	// OnHistoryRewrite selects what CodeByCommit does when CommitFromIncl is no longer reachable from processed refs, because history was force-pushed or rewritten. Default returns *HistoryRewrittenError, HistoryRewriteReprocess processes all commits as with ForceFullReprocess.
+	OnHistoryRewrite HistoryRewrite
+
 	// CommitFromMakeNonIncl by default we start from passed commit and include it. Set CommitFromMakeNonIncl to true to avoid returning it, and skipping reading/writing checkpoint.
 	CommitFromMakeNonIncl bool
 
-	// IncrementalIgnoreBranchesOlderThan provides a way to ignore old branches in incremental processing.
+	// IncrementalIgnoreBranchesOlderThan provides a way to ignore old branches in incremental processing with AllBranches. Branches with last commit older than this are skipped, default branch is always processed.
 	// Default is time.Now() - 90 * day
-	// BUG: this field is ignored, only processing HEAD branch in incrementals right now
 	IncrementalIgnoreBranchesOlderThan time.Time
 
 	// AllBranches set to true to process all branches. If false, processes HEAD only.
-	// BUG: in incrementals only processing HEAD branch
+	// In incrementals, only branches with commits after IncrementalIgnoreBranchesOlderThan are processed.
 	AllBranches bool
 
+	// MaxBranches limits the number of branches processed with AllBranches in addition to the default branch. Most recently committed branches are selected. 0 means no limit.
+	// Bounds processing of repos with thousands of branches. Branches skipped are not returned from Branches either.
+	MaxBranches int
+
 	// BranchesUseOrigin by default ripsrc lists only local branches when using Branches method. Set this to true to use origin/ branches instead.
 	BranchesUseOrigin bool
 
+	// BranchesInclude limits AllBranches processing, Branches and BranchEvents to branches matching any of these patterns, for example "release/*".
+	// Patterns are globs as in path.Match, where * does not match /, or regular expressions when prefixed with "re:", for example "re:^hotfix-[0-9]+$".
+	// Default branch is always included, since other branches are compared with it.
+	// With AllBranches, matching branches are resolved at the start and processed as Refs. Not used when Refs are set.
+	BranchesInclude []string
+
+	// BranchesExclude removes branches matching any of these patterns, applied after BranchesInclude. Same pattern format as BranchesInclude.
+	BranchesExclude []string
+
+	// ExcludeAuthors skips commits with author name or email matching any of these patterns, for example dependency update bots, so that they do not distort churn and contributor metrics.
+	// Patterns are globs, such as dependabot* or *@users.noreply.github.com, or regular expressions prefixed with "re:", such as re:(?i)\[bot\]$. Brackets in globs are character classes.
+	// Skipped commits are still processed, so that blame of later commits is correct, but are not returned. Lines changed by them are still attributed to them in blame.
+	ExcludeAuthors []string
+
+	// FlagExcludedAuthors set to true to return commits matching ExcludeAuthors with Commit.ExcludedAuthor set, instead of skipping them.
+	FlagExcludedAuthors bool
+
+	// Refs is a list of branches, tags or shas. If set, ripsrc processes the union of commits reachable from these refs (minus commits processed by previous runs when CommitFromIncl is set).
+	// Takes precedence over AllBranches for commit processing. Branches and BranchDiff still require AllBranches=true.
+	Refs []string
+
 	// PullRequestSHAs is a list of custom sha references to process similar to branches returned from the repo.
 	PullRequestSHAs []string
+
+	// PullRequests is a list of named pull request refs (id, head and optional base sha) to process similar to branches returned from the repo.
+	// Results are labeled with the pull request id and if base is set, only the delta against the base is returned.
+	PullRequests []PullRequest
+
+	// GitPolicy sets timeouts and retries for git commands. Default is gitexec.DefaultPolicy.
+	GitPolicy *gitexec.Policy
+
+	// GitUserConfig set to true to run git commands with system and global git config, HOME and hooks of the current user.
+	// By default git commands are isolated from them, so that filters, pagers and hooks could not alter output or hang processing. Repo config is always used.
+	GitUserConfig bool
+
+	// GitEnv are additional environment variables in KEY=VALUE format passed to all git commands, for example GIT_SSH_COMMAND or GIT_ASKPASS.
+	// Note that HOME is empty unless GitUserConfig is set, so ssh keys need to be passed explicitly, for example GIT_SSH_COMMAND=ssh -i /path/to/key.
+	GitEnv []string
+
+	// GitCredentialHelper replaces credential helpers from git config for all git commands, for example "store --file=/path/to/credentials".
+	GitCredentialHelper string
+
+	// GitRecordDir records output of all git commands into a bundle in this dir, so that the run could be reproduced using GitReplayDir without the repo, for example for bugs reported on repos that could not be shared.
+	// The bundle contains file contents and history of the repo, so handle it the same way as the repo. Dir should be empty.
+	GitRecordDir string
+
+	// GitReplayDir replays git command output from a bundle recorded using GitRecordDir instead of running git. RepoDir could be any existing dir. Other options have to be the same as when recording.
+	// Checkpoints are not part of the bundle, use a new CheckpointsDir or the checkpoints of the recorded run copied as they were before it.
+	GitReplayDir string
+
+	// BlobCacheSize is the number of recently analyzed blobs for which language, skip classification and line stats are kept, so that identical content is not analyzed again. Default is DefaultBlobCacheSize, negative disables.
+	// Language and skip classification of cached blobs is saved next to the checkpoint after each CodeByCommit run and reused by the next run.
+	BlobCacheSize int
+
+	// CacheBranches keeps results of Branches, DefaultBranch and branch lists used to select refs until ref tips change, so that repeated queries on a long-lived instance, as made by an API server, only run two cheap git commands.
+	// Changes of init.defaultBranch config are not detected while refs stay the same.
+	CacheBranches bool
+
+	// Profiles captures runtime profiles of each CodeByCommit run, written to a new dir for the run in profiles next to the checkpoint. Paths are recorded in RunManifest.Profiles.
+	// Use it to investigate slow repos without rebuilding. CPU profile and trace are global to the process, so only one run at a time could capture them.
+	Profiles []Profile
+
+	// Analyzers are names of analyzers added using RegisterAnalyzer to run for each analyzed file. Results are set in BlameResult.Analyses.
+	Analyzers []string
+
+	// Sinks receive results of CodeByCommit while they are returned, for example NewJSONLSink or NewSQLSink. Multiple sinks run in order for each result.
+	Sinks []OutputSink
+
+	// Enricher attaches hosting metadata, such as pull request, approvals and CI status, to CommitCode.Hosting before commits are returned. Optional.
+	Enricher CommitEnricher
+
+	// MaxLine is the max length in bytes of a line in git log output, for example a changed line of a minified file. Longer lines fail processing.
+	// Default is 100MB, negative for no limit.
+	MaxLine int
+
+	// CommitFileSizes set to true to fill Size and SizePrev of Commit.Files, so that size change in bytes is available also for binary files. Requires an additional git process.
+	CommitFileSizes bool
+
+	// CommitDate selects whether author or committer date is used for time bucketing, ages (churn window, code survival, BlameLine.Date) and ordering of commits.
+	// Default uses author date for bucketing and ages, and orders commits by committer date.
+	CommitDate CommitDate
+
+	// CommitSignatures set to true to fill Commit.Signature with GPG or SSH signature verification status. Verification is slow for repos with many signed commits.
+	// Keys are taken from repo config or GitEnv, for example gpg.ssh.allowedSignersFile or GNUPGHOME, since user config is not used by default.
+	CommitSignatures bool
+
+	// CommitTrailers set to true to fill Commit.Trailers with trailers of the commit message, such as Signed-off-by or Co-authored-by.
+	CommitTrailers bool
+
+	// InlineCommitMeta selects commit metadata set in BlameResult.Commit. Use InlineCommitMetaFull for consumers that need message, parents and trailers on each result without joining against commits,
+	// or InlineCommitMetaRef to only set the sha and keep results small. Default is InlineCommitMetaDefault.
+	InlineCommitMeta InlineCommitMeta
+
+	// CommitsReleasedInTag set to true to fill CommitCode.ReleasedInTag with the first tag that includes the commit.
+	CommitsReleasedInTag bool
+
+	// CodeOwners set to true to fill BlameResult.Owners from CODEOWNERS in GitHub or GitLab syntax.
+	// CODEOWNERS is read once from the first of Refs or HEAD and applied to results of all commits, so owners reflect the current ownership.
+	CodeOwners bool
+
+	// Projects set to true to fill BlameResult.ProjectID with the root of the closest project containing the file, so that a monorepo could be reported as multiple projects.
+	// Project roots are directories with build manifests, see projects.Manifests. They are detected once from the first of Refs or HEAD.
+	Projects bool
+
+	// Attribution selects the commit BlameLine.SHA, Name, Email and Date are taken from. Default is AttributionCommit.
+	// With AttributionMerge lines are attributed to the merge commit that landed them on the default branch, which is how ownership is often reported, and BlameLine.OriginalSHA is set to the commit that changed the line.
+	// The default branch is read once per CodeByCommit call, so commits of branches merged later are attributed to themselves until the next run.
+	Attribution Attribution
+
+	// LandedIn maps commit sha to the commit that landed it on the default branch, for example from pull requests of the hosting service. Used with AttributionMerge for commits that can not be detected from the commit graph, such as rebase merges. Takes precedence over the commit graph.
+	LandedIn map[string]string
+
+	// VerificationSampleRate is the fraction of file results of CodeByCommit, from 0 to 1, cross-checked with git blame, so that drift of incremental blame is caught in production. 0 disables.
+	// Files with different line attribution are logged as warnings and counted in metrics.VerificationDivergences. Each checked file runs git blame, so keep it low for big repos, for example 0.001.
+	VerificationSampleRate float64
+
+	// Anonymize replaces author, committer, tagger and code owner names and emails in all results with keyed hashes, and optionally drops messages and line content, so that results could leave security restricted environments for analysis.
+	// Commits are filtered using ExcludeAuthors before anonymization. Zero value disables.
+	Anonymize AnonymizeOpts
+
+	// RedactMessages removes selected kinds of data, such as emails, tokens and urls, from commit messages, trailers and tag messages before they are returned, for orgs that want messages but not personal data or secrets embedded in them.
+	// Separate from Anonymize, which hashes authors. Matches are replaced with a placeholder, for example [email].
+	RedactMessages []Redaction
+
+	// RedactPatterns are additional regular expressions removed from messages the same way as RedactMessages, replaced with [redacted]. For example internal hostnames or ticket ids of a customer.
+	RedactPatterns []string
+
+	// Stages selects analysis steps to run, so that consumers that do not need all data do not pay for it. For example StageCommits|StageBlame|StageLines skips language detection and line stats,
+	// and StageCommits only returns commits without processing the history. Default is StagesAll.
+	Stages Stages
+
+	// Duplicates configures duplicate code detection used by Ripsrc.Duplicates. Zero value uses defaults, see duplicates.Opts.
+	Duplicates DuplicatesOpts
 }
 
 // Ripsrc runs on a single repo.
@@ -60,11 +300,73 @@ type Ripsrc struct {
 	opts            Opts
 	gitExecPrepared bool
 
+	namespacedCheckpointsDir string
+
 	commitMeta map[string]commitmeta.Commit
+	// commitOrder are commits of commitMeta in git log order
+	commitOrder []string
 
 	fileInfo *fileinfo.Process
 
 	commitGraph *parentsgraph.Graph
+
+	timings *stageTimings
+
+	skipReport *SkipReport
+
+	// blobCache is nil if disabled with negative BlobCacheSize
+	blobCache *blobCache
+	// fileInfoCacheLoaded is set after fileinfo results saved by the previous run are added to blobCache
+	fileInfoCacheLoaded bool
+
+	// branchCache is nil unless Opts.CacheBranches is set
+	branchCache *branchmeta.Cache
+
+	// branchesResult is the last result of Branches with the ref tips it was computed for, only kept with Opts.CacheBranches
+	branchesResult struct {
+		tips string
+		res  []Branch
+	}
+
+	// selectedBranches are branches processed when AllBranches is limited, nil otherwise. See selectBranchRefs.
+	selectedBranches map[string]bool
+
+	// blobs returns blob sizes, only set while CodeByCommit is running
+	blobs *gitexec.CatFileCheck
+
+	// codeOwners is set while CodeByCommit is running with Opts.CodeOwners, nil if repo does not have CODEOWNERS
+	codeOwners *codeowners.File
+
+	// projects is set while CodeByCommit is running with Opts.Projects
+	projects *projects.Index
+
+	// landedIn is set while CodeByCommit is running with AttributionMerge, see getLandedIn
+	landedIn map[string]string
+
+	// runCounts are results returned by the current CodeByCommit call, used in RunManifest
+	runCounts RunCounts
+
+	// runProfiles are profile files written by the current CodeByCommit call, see Opts.Profiles
+	runProfiles []string
+
+	// runManifest is the manifest of the last successful CodeByCommit call
+	runManifest *RunManifest
+
+	// onProcessResult is called with incremental blame of each commit while CodeByCommit is running, before code info. Used by LineHistory, SnippetProvenance and Duplicates, which need line content.
+	onProcessResult func(r process.Result, commit commitmeta.Commit)
+
+	// verifier cross-checks results with git blame, nil unless Opts.VerificationSampleRate is set
+	verifier *verify.Verifier
+
+	// gitRecorder and gitReplayer are set from Opts.GitRecordDir and Opts.GitReplayDir, shared by all calls so that repeated commands are replayed in order
+	gitRecorder *gitexec.Recorder
+	gitReplayer *gitexec.Replayer
+
+	// anonymizer applies Opts.Anonymize to results, nil if disabled
+	anonymizer *anonymizer
+
+	// redactor applies Opts.RedactMessages and Opts.RedactPatterns to results, nil if disabled
+	redactor *redactor
 }
 
 func New(opts Opts) *Ripsrc {
@@ -72,33 +374,121 @@ func New(opts Opts) *Ripsrc {
 	if opts.Logger == nil {
 		opts.Logger = logger.NewDefaultLogger(os.Stdout)
 	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.Noop{}
+	}
+	if opts.Tracer == nil {
+		opts.Tracer = tracing.Noop{}
+	}
+
+	if opts.ForceFullReprocess {
+		opts.CommitFromIncl = ""
+		opts.CommitFromMakeNonIncl = false
+		opts.ResumeInterrupted = false
+	}
 
 	s := &Ripsrc{}
+	s.timings = newStageTimings()
+	opts.Metrics = metrics.Multi(opts.Metrics, s.timings)
 	s.opts = opts
 	s.CodeInfoTimings = &CodeInfoTimings{}
-	s.fileInfo = fileinfo.New()
+	fileInfo, err := fileinfo.NewWithOpts(fileinfo.Opts{
+		VendorRules:          opts.VendorRules,
+		NoDefaultVendorRules: opts.NoDefaultVendorRules,
+		CopyrightHolders:     opts.CopyrightHolders,
+	})
+	if err != nil {
+		// invalid vendor rules are returned from Validate, which runs before processing
+		fileInfo = fileinfo.New()
+	}
+	s.fileInfo = fileInfo
+	switch {
+	case opts.BlobCacheSize == 0:
+		s.blobCache = newBlobCache(DefaultBlobCacheSize)
+	case opts.BlobCacheSize > 0:
+		s.blobCache = newBlobCache(opts.BlobCacheSize)
+	}
+	if opts.SkipReport {
+		s.skipReport = newSkipReport()
+	}
+	if opts.CacheBranches {
+		s.branchCache = branchmeta.NewCache()
+	}
+	s.verifier = newVerifier(opts)
+	s.anonymizer = newAnonymizer(opts.Anonymize)
+	// invalid patterns are returned from Validate, which runs before processing
+	s.redactor, _ = newRedactor(opts.RedactMessages, opts.RedactPatterns)
+	if opts.GitRecordDir != "" {
+		s.gitRecorder = gitexec.NewRecorder(opts.GitRecordDir)
+	}
+	if opts.GitReplayDir != "" {
+		s.gitReplayer = gitexec.NewReplayer(opts.GitReplayDir)
+	}
 	return s
 }
 
 var gitCommand = "git"
 
+// gitContext returns ctx with GitPolicy, git environment, record and replay options applied to git commands.
+func (s *Ripsrc) gitContext(ctx context.Context) context.Context {
+	ctx = gitexec.WithEnv(ctx, gitexec.Env{
+		UserConfig:       s.opts.GitUserConfig,
+		Vars:             s.opts.GitEnv,
+		CredentialHelper: s.opts.GitCredentialHelper,
+		ReadOnly:         s.opts.ReadOnly,
+	})
+	if s.gitRecorder != nil {
+		ctx = gitexec.WithRecorder(ctx, s.gitRecorder)
+	}
+	if s.gitReplayer != nil {
+		ctx = gitexec.WithReplayer(ctx, s.gitReplayer)
+	}
+	if s.opts.GitPolicy == nil {
+		return ctx
+	}
+	return gitexec.WithPolicy(ctx, *s.opts.GitPolicy)
+}
+
 func (s *Ripsrc) prepareGitExec(ctx context.Context) error {
 	if s.gitExecPrepared {
 		return nil
 	}
-	return gitexec.Prepare(ctx, gitCommand, s.opts.RepoDir)
+	err := s.opts.validate(ctx)
+	if err != nil {
+		return err
+	}
+	err = gitexec.Prepare(ctx, gitCommand, s.opts.RepoDir)
+	if err != nil {
+		return err
+	}
+	if s.opts.AllBranches && len(s.opts.Refs) == 0 && (s.opts.CommitFromIncl != "" || s.opts.MaxBranches > 0 || !s.branchFilter().empty()) {
+		s.opts.Refs, err = s.selectBranchRefs(ctx)
+		if err != nil {
+			return err
+		}
+		s.opts.Logger.Debug("processing selected branches", "refs", s.opts.Refs)
+	}
+	s.gitExecPrepared = true
+	return nil
 }
 
 func (s *Ripsrc) buildCommitGraph(ctx context.Context) error {
 	if s.commitGraph != nil {
 		return nil
 	}
+	start := time.Now()
+	_, span := s.opts.Tracer.Start(ctx, tracing.SpanCommitGraph, "repo", s.opts.RepoDir)
+	defer func() {
+		span.End()
+		s.opts.Metrics.Duration(metrics.StageDuration, time.Since(start), "stage", metrics.StageCommitGraph)
+	}()
 
 	s.commitGraph = parentsgraph.New(parentsgraph.Opts{
 		RepoDir:     s.opts.RepoDir,
 		AllBranches: s.opts.AllBranches,
+		Refs:        s.opts.Refs,
 		Logger:      s.opts.Logger,
 	})
 
-	return s.commitGraph.Read()
+	return s.commitGraph.ReadContext(ctx)
 }
@@ -0,0 +1,305 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// Version is the version of ripsrc recorded in RunManifest. Set at build time using -ldflags "-X github.com/pinpt/ripsrc/ripsrc.Version=v1.2.3".
+// If empty, the module version from build info is used.
+var Version = ""
+
+func version() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == "github.com/pinpt/ripsrc" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/pinpt/ripsrc" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+// RunCounts are the numbers of results returned by a CodeByCommit run.
+type RunCounts struct {
+	// Commits is the number of returned commits, including merges.
+	Commits int
+	// Merges is the number of returned merge commits.
+	Merges int
+	// Files is the number of returned BlameResults.
+	Files int
+	// SkippedFiles is the number of returned BlameResults with Skipped set, by reason.
+	SkippedFiles map[string]int
+	// DeadlineExceeded is the number of commits with CommitCode.DeadlineExceeded.
+	DeadlineExceeded int
+}
+
+func (s *RunCounts) add(rc CommitCode, blames []BlameResult) {
+	s.Commits++
+	if len(rc.Parents) > 1 {
+		s.Merges++
+	}
+	if rc.DeadlineExceeded {
+		s.DeadlineExceeded++
+	}
+	s.Files += len(blames)
+	for _, b := range blames {
+		if b.Skipped == "" {
+			continue
+		}
+		if s.SkippedFiles == nil {
+			s.SkippedFiles = map[string]int{}
+		}
+		s.SkippedFiles[b.Skipped]++
+	}
+}
+
+// RunManifest describes a successful CodeByCommit run, so that results could be audited and reproduced. It is written next to the checkpoint after each run and returned from Ripsrc.RunManifest.
+type RunManifest struct {
+	// Version is the ripsrc version, see Version.
+	Version string
+	// CheckpointFormat is the checkpoint format version written by this ripsrc version.
+	CheckpointFormat int
+	// RepoDir is Opts.RepoDir.
+	RepoDir string
+	// Options are Opts fields that are set, by name. Logger, Metrics and Tracer are not included, Sinks are replaced with their types, LandedIn with its length, only names of GitEnv variables are included and GitCredentialHelper is replaced with "set", since they could contain secrets.
+	Options map[string]interface{}
+	// Refs are the ref tips at the start of the run, map[ref]commit.
+	Refs map[string]string
+	// CheckpointCommit is the commit the checkpoint was written for. Empty if blame was not run.
+	CheckpointCommit string
+	// Counts are the numbers of returned results.
+	Counts RunCounts
+	// Started is the start time of the run.
+	Started time.Time
+	// Duration is the wall time of the run.
+	Duration time.Duration
+	// Stages are timings of pipeline stages of all calls made on the Ripsrc instance, see TimingReport.
+	Stages []StageTiming
+	// Profiles are files written for Opts.Profiles. Heap profile is written when the run returns, after the manifest.
+	Profiles []string
+}
+
+const runManifestFile = "run-manifest.json"
+
+// manifestOptions returns non-zero opts fields that could be serialized.
+func manifestOptions(opts Opts) map[string]interface{} {
+	res := map[string]interface{}{}
+	v := reflect.ValueOf(opts)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		if f.Type.Kind() == reflect.Interface || fv.IsZero() {
+			continue
+		}
+		switch f.Name {
+		case "RepoDir":
+			continue
+		case "GitEnv":
+			var names []string
+			for _, kv := range opts.GitEnv {
+				names = append(names, envName(kv))
+			}
+			res[f.Name] = names
+		case "GitCredentialHelper":
+			res[f.Name] = "set"
+		case "LandedIn":
+			res[f.Name] = len(opts.LandedIn)
+		case "Sinks":
+			var names []string
+			for _, sink := range opts.Sinks {
+				names = append(names, fmt.Sprintf("%T", sink))
+			}
+			res[f.Name] = names
+		default:
+			res[f.Name] = fv.Interface()
+		}
+	}
+	return res
+}
+
+func envName(kv string) string {
+	for i := 0; i < len(kv); i++ {
+		if kv[i] == '=' {
+			return kv[:i]
+		}
+	}
+	return kv
+}
+
+// finishRunManifest records the manifest of a successful run. Written next to the checkpoint if write is true.
+func (s *Ripsrc) finishRunManifest(ctx context.Context, started time.Time, refs map[string]string, checkpointCommit string, write bool) error {
+	m := RunManifest{}
+	m.Version = version()
+	m.CheckpointFormat = repo.FormatVersion
+	m.RepoDir = s.opts.RepoDir
+	m.Options = manifestOptions(s.opts)
+	m.Refs = refs
+	m.CheckpointCommit = checkpointCommit
+	m.Counts = s.runCounts
+	m.Started = started
+	m.Duration = time.Since(started)
+	m.Stages = s.timings.Report().Stages
+	m.Profiles = s.runProfiles
+	s.runManifest = &m
+	if !write {
+		return nil
+	}
+	loc, err := s.runManifestPath(ctx)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(loc, m)
+}
+
+func (s *Ripsrc) runManifestPath(ctx context.Context) (string, error) {
+	loc, err := s.refsManifestPath(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(loc), runManifestFile), nil
+}
+
+// writeJSONFile writes v as json atomically, creating the directory if needed.
+func writeJSONFile(loc string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(loc), 0777)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(loc+".tmp", b, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(loc+".tmp", loc)
+}
+
+// RunManifest returns the manifest of the last successful CodeByCommit call on this instance, nil if there was none.
+func (s *Ripsrc) RunManifest() *RunManifest {
+	return s.runManifest
+}
+
+// ReadRunManifest returns the manifest written by the last successful run that processed history, for example in another process. Returns nil if there is none.
+func (s *Ripsrc) ReadRunManifest(ctx context.Context) (*RunManifest, error) {
+	loc, err := s.runManifestPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(loc)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res RunManifest
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse run manifest: %v", err)
+	}
+	return &res, nil
+}
+
+// ManifestDiff is a difference between two run manifests.
+type ManifestDiff struct {
+	// Field is the name of the field, with the key for maps, for example Options.AllBranches or Refs.refs/heads/master.
+	Field string
+	// A and B are the values in the compared manifests, json encoded for options. Empty if the key is missing.
+	A string
+	B string
+}
+
+// CompareRunManifests returns differences that could affect results, sorted by Field. Started, Duration and Stages are not compared, since they differ between every run, and RepoDir is not compared, since it differs between machines.
+// Manifests read from json could be compared with manifests returned from RunManifest, options are compared by their json encoding.
+func CompareRunManifests(a, b RunManifest) (res []ManifestDiff) {
+	add := func(field, va, vb string) {
+		if va != vb {
+			res = append(res, ManifestDiff{Field: field, A: va, B: vb})
+		}
+	}
+	add("Version", a.Version, b.Version)
+	add("CheckpointFormat", strconv.Itoa(a.CheckpointFormat), strconv.Itoa(b.CheckpointFormat))
+	add("CheckpointCommit", a.CheckpointCommit, b.CheckpointCommit)
+
+	jsonValue := func(m map[string]interface{}, k string) string {
+		v, ok := m[k]
+		if !ok {
+			return ""
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	keys := map[string]bool{}
+	for k := range a.Options {
+		keys[k] = true
+	}
+	for k := range b.Options {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		add("Options."+k, jsonValue(a.Options, k), jsonValue(b.Options, k))
+	}
+	keys = map[string]bool{}
+	for k := range a.Refs {
+		keys[k] = true
+	}
+	for k := range b.Refs {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		add("Refs."+k, a.Refs[k], b.Refs[k])
+	}
+
+	ca, cb := a.Counts, b.Counts
+	add("Counts.Commits", strconv.Itoa(ca.Commits), strconv.Itoa(cb.Commits))
+	add("Counts.Merges", strconv.Itoa(ca.Merges), strconv.Itoa(cb.Merges))
+	add("Counts.Files", strconv.Itoa(ca.Files), strconv.Itoa(cb.Files))
+	add("Counts.DeadlineExceeded", strconv.Itoa(ca.DeadlineExceeded), strconv.Itoa(cb.DeadlineExceeded))
+	keys = map[string]bool{}
+	for k := range ca.SkippedFiles {
+		keys[k] = true
+	}
+	for k := range cb.SkippedFiles {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		add("Counts.SkippedFiles."+k, strconv.Itoa(ca.SkippedFiles[k]), strconv.Itoa(cb.SkippedFiles[k]))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Field < res[j].Field
+	})
+	return
+}
+
+func sortedKeys(m map[string]bool) (res []string) {
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return
+}
@@ -0,0 +1,68 @@
+package ripsrc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestRunManifest(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").Write("package-lock.json", "{}\n").Commit("c1")
+	c2 := r.Write("a.go", "package a\n\nvar A = 1\n").Commit("c2")
+
+	opts := Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir(), GitEnv: []string{"GIT_ASKPASS=secret"}}
+	rip := New(opts)
+	runAll(t, rip)
+	m := rip.RunManifest()
+	if m == nil {
+		t.Fatal("expected run manifest")
+	}
+	if m.CheckpointCommit != c2 || m.Refs["HEAD"] != c2 || m.Version == "" || m.CheckpointFormat == 0 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	if m.Counts.Commits != 2 || m.Counts.Files != 3 || len(m.Counts.SkippedFiles) != 1 {
+		t.Errorf("unexpected counts %+v", m.Counts)
+	}
+	if !reflect.DeepEqual(m.Options["GitEnv"], []string{"GIT_ASKPASS"}) || m.Options["CheckpointsDir"] != opts.CheckpointsDir {
+		t.Errorf("unexpected options %+v", m.Options)
+	}
+
+	stored, err := New(opts).ReadRunManifest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil {
+		t.Fatal("expected stored run manifest")
+	}
+	if diff := CompareRunManifests(*m, *stored); len(diff) != 0 {
+		t.Errorf("expected stored manifest to be the same, got %+v", diff)
+	}
+
+	r.Write("a.go", "package a\n").Commit("c3")
+	opts.AllBranches = true
+	rip = New(opts)
+	runAll(t, rip)
+	var fields []string
+	for _, d := range CompareRunManifests(*stored, *rip.RunManifest()) {
+		fields = append(fields, d.Field)
+	}
+	if got := strings.Join(fields, ","); !strings.Contains(got, "CheckpointCommit") || !strings.Contains(got, "Options.AllBranches") || !strings.Contains(got, "Counts.Commits") {
+		t.Errorf("unexpected diff fields %v", got)
+	}
+}
+
+func runAll(t *testing.T, rip *Ripsrc) {
+	t.Helper()
+	it := rip.CommitIter(context.Background())
+	defer it.Close()
+	for it.Next() {
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package server runs incremental processing of repos when git hosting webhooks report a push, so that results stay current without an external scheduler.
+//
+// Server is an http.Handler accepting push events from GitHub, GitLab, Gitea or any sender posting json with ref and repository.full_name. For each push, the affected repo is fetched and processed incrementally from its checkpoint.
+// Pushes to a repo that is being processed are coalesced into a single next run.
+package server
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+// Opts are options for Server.
+type Opts struct {
+	// Logger for info and errors. Default writes to stdout.
+	Logger logger.Logger
+	// Repo returns processing options for the repo full name from the webhook, for example pinpt/ripsrc. Return false to ignore pushes to the repo.
+	// Called for every run, so that new Sinks could be created for each run. RepoDir should be a mirror clone, see ripsrc.Ripsrc.Fetch. CommitFromIncl is set from the checkpoint.
+	Repo func(name string) (ripsrc.Opts, bool)
+	// Secret verifies webhooks. GitHub and Gitea signatures in X-Hub-Signature-256 and GitLab X-Gitlab-Token are checked. Empty accepts all requests.
+	Secret string
+	// NoFetch skips git fetch before processing, for repos updated by other means.
+	NoFetch bool
+	// OnRun is called after each run, for example to record metrics. Optional.
+	OnRun func(RunResult)
+}
+
+// RunResult describes a processing run triggered by webhooks.
+type RunResult struct {
+	// Repo is the repo full name.
+	Repo string
+	// Refs are refs of pushes that triggered the run, in order received.
+	Refs []string
+	// Skipped is true if there was nothing new to process after fetch.
+	Skipped bool
+	// Commits is the number of returned commits.
+	Commits int
+	// Started is the start time of the run.
+	Started time.Time
+	// Duration is the wall time of the run.
+	Duration time.Duration
+	// Err is the error of fetch or processing.
+	Err error
+}
+
+// Server processes repos on push webhooks. Create it using New and close it using Close.
+type Server struct {
+	opts   Opts
+	ctx    context.Context
+	cancel func()
+
+	mu    sync.Mutex
+	repos map[string]*repoState
+	wg    sync.WaitGroup
+}
+
+type repoState struct {
+	running bool
+	// pending are refs pushed while the repo was running, processed in the next run
+	pending []string
+}
+
+// New creates a server. Register it as http handler for the webhook url.
+func New(opts Opts) *Server {
+	if opts.Logger == nil {
+		opts.Logger = logger.NewDefaultLogger(os.Stdout)
+	}
+	s := &Server{}
+	s.opts = opts
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.repos = map[string]*repoState{}
+	return s
+}
+
+// Close cancels running processing and waits for it to stop.
+func (s *Server) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// Wait blocks until there are no running or pending runs. Used in tests and for graceful shutdown after the http server stops accepting requests.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// schedule starts a run for the repo, or adds ref to the next run if the repo is being processed.
+func (s *Server) schedule(name string, ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.repos[name]
+	if !ok {
+		st = &repoState{}
+		s.repos[name] = st
+	}
+	st.pending = append(st.pending, ref)
+	if st.running {
+		return
+	}
+	st.running = true
+	s.wg.Add(1)
+	go s.loop(name, st)
+}
+
+func (s *Server) loop(name string, st *repoState) {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		if len(st.pending) == 0 || s.ctx.Err() != nil {
+			st.running = false
+			st.pending = nil
+			s.mu.Unlock()
+			return
+		}
+		refs := st.pending
+		st.pending = nil
+		s.mu.Unlock()
+
+		res := s.run(name, refs)
+		if res.Err != nil {
+			s.opts.Logger.Error("webhook run failed", "repo", name, "err", res.Err)
+		} else {
+			s.opts.Logger.Info("webhook run finished", "repo", name, "commits", res.Commits, "skipped", res.Skipped, "duration", res.Duration)
+		}
+		if s.opts.OnRun != nil {
+			s.opts.OnRun(res)
+		}
+	}
+}
+
+func (s *Server) run(name string, refs []string) (res RunResult) {
+	res.Repo = name
+	res.Refs = refs
+	res.Started = time.Now()
+	defer func() {
+		res.Duration = time.Since(res.Started)
+	}()
+	ctx := s.ctx
+	opts, ok := s.opts.Repo(name)
+	if !ok {
+		res.Skipped = true
+		return
+	}
+	rip := ripsrc.New(opts)
+	if !s.opts.NoFetch {
+		res.Err = rip.Fetch(ctx, "")
+		if res.Err != nil {
+			return
+		}
+	}
+	status, err := rip.NeedsProcessing(ctx)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	if !status.Needed {
+		res.Skipped = true
+		return
+	}
+	info, err := rip.Checkpoint(ctx)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	if info.Exists {
+		opts.CommitFromIncl = info.Commit
+		opts.CommitFromMakeNonIncl = true
+		rip = ripsrc.New(opts)
+	}
+	commits := make(chan ripsrc.CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			for range c.Blames {
+			}
+			res.Commits++
+		}
+		done <- true
+	}()
+	res.Err = rip.CodeByCommit(ctx, commits)
+	<-done
+	return
+}
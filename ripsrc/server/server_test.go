@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestWebhookIncremental(t *testing.T) {
+	origin := testkit.New(t)
+	defer origin.Remove()
+	origin.Write("a.txt", "a\n").Commit("c1")
+
+	tmp, err := ioutil.TempDir("", "ripsrc-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	mirror := filepath.Join(tmp, "repo.git")
+	out, err := exec.Command("git", "clone", "--quiet", "--mirror", origin.Dir(), mirror).CombinedOutput()
+	if err != nil {
+		t.Fatalf("clone failed: %v %s", err, out)
+	}
+
+	var mu sync.Mutex
+	var runs []RunResult
+	s := New(Opts{
+		Logger: logger.NewDefaultLogger(ioutil.Discard),
+		Repo: func(name string) (ripsrc.Opts, bool) {
+			if name != "pinpt/repo" {
+				return ripsrc.Opts{}, false
+			}
+			return ripsrc.Opts{RepoDir: mirror, CheckpointsDir: filepath.Join(tmp, "checkpoints"), Logger: logger.NewDefaultLogger(ioutil.Discard)}, true
+		},
+		Secret: "secret",
+		OnRun: func(r RunResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs = append(runs, r)
+		},
+	})
+	defer s.Close()
+
+	push := func(repo string, secret string) int {
+		body := []byte(`{"ref":"refs/heads/master","repository":{"full_name":"` + repo + `"}}`)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		s.Wait()
+		return w.Code
+	}
+	lastRun := func() RunResult {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(runs) == 0 {
+			t.Fatal("no runs")
+		}
+		return runs[len(runs)-1]
+	}
+
+	if code := push("pinpt/repo", "invalid"); code != http.StatusUnauthorized {
+		t.Fatalf("wanted 401 for invalid signature, got %v", code)
+	}
+	if code := push("pinpt/other", "secret"); code != http.StatusOK {
+		t.Fatalf("wanted 200 for unknown repo, got %v", code)
+	}
+	if len(runs) != 0 {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+
+	if code := push("pinpt/repo", "secret"); code != http.StatusAccepted {
+		t.Fatalf("wanted 202, got %v", code)
+	}
+	if r := lastRun(); r.Err != nil || r.Commits != 1 || r.Refs[0] != "refs/heads/master" {
+		t.Fatalf("unexpected first run %+v", r)
+	}
+
+	// new commit in origin is fetched and processed incrementally
+	origin.Write("a.txt", "a2\n").Commit("c2")
+	push("pinpt/repo", "secret")
+	if r := lastRun(); r.Err != nil || r.Commits != 1 || r.Skipped {
+		t.Fatalf("unexpected incremental run %+v", r)
+	}
+
+	push("pinpt/repo", "secret")
+	if r := lastRun(); r.Err != nil || !r.Skipped {
+		t.Fatalf("expected skipped run without changes %+v", r)
+	}
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+	s := New(Opts{Repo: func(name string) (ripsrc.Opts, bool) {
+		t.Fatal("repo should not be resolved for ping")
+		return ripsrc.Opts{}, false
+	}})
+	defer s.Close()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{"zen":"hi"}`)))
+	req.Header.Set("X-GitHub-Event", "ping")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wanted 200, got %v", w.Code)
+	}
+}
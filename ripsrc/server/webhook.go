@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxPayload is the max size of webhook body, GitHub limits payloads to 25MB
+const maxPayload = 25 << 20
+
+// pushEvent has fields of push payloads of GitHub, Gitea and GitLab used to find the repo.
+type pushEvent struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	// Project is set by GitLab
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+func (s pushEvent) repo() string {
+	if s.Repository.FullName != "" {
+		return s.Repository.FullName
+	}
+	return s.Project.PathWithNamespace
+}
+
+// ServeHTTP accepts push webhooks. Responds with 202 when a run is scheduled, 200 for ignored events such as pings or pushes to unknown repos, and 401 for invalid signatures.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	if !s.verify(r, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if !isPush(r) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var ev pushEvent
+	err = json.Unmarshal(body, &ev)
+	if err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	name := ev.repo()
+	if name == "" {
+		http.Error(w, "repository not set", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.opts.Repo(name); !ok {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	s.schedule(name, ev.Ref)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// isPush returns false for events other than pushes, based on event headers. Requests without event headers are treated as pushes.
+func isPush(r *http.Request) bool {
+	for _, h := range []string{"X-GitHub-Event", "X-Gitea-Event"} {
+		if ev := r.Header.Get(h); ev != "" {
+			return ev == "push"
+		}
+	}
+	if ev := r.Header.Get("X-Gitlab-Event"); ev != "" {
+		return ev == "Push Hook" || ev == "Tag Push Hook"
+	}
+	return true
+}
+
+func (s *Server) verify(r *http.Request, body []byte) bool {
+	if s.opts.Secret == "" {
+		return true
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Secret)) == 1
+	}
+	sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.opts.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
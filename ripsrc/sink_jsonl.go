@@ -0,0 +1,74 @@
+package ripsrc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// JSONLRecord is a line written by JSONLSink. Kind is "commit" or "blame", with the matching field set.
+type JSONLRecord struct {
+	Kind   string       `json:"kind"`
+	Commit *JSONLCommit `json:"commit,omitempty"`
+	Blame  *BlameResult `json:"blame,omitempty"`
+}
+
+// JSONLCommit is a commit with fields of CommitCode other than Blames.
+type JSONLCommit struct {
+	Commit
+	ReleasedInTag    string         `json:"released_in_tag,omitempty"`
+	Tests            TestStats      `json:"tests"`
+	Churn            *ChurnStats    `json:"churn,omitempty"`
+	DeadlineExceeded bool           `json:"deadline_exceeded,omitempty"`
+	Hosting          *CommitHosting `json:"hosting,omitempty"`
+}
+
+func newJSONLCommit(commit CommitCode) JSONLCommit {
+	c := JSONLCommit{}
+	c.Commit = commit.Commit
+	c.ReleasedInTag = commit.ReleasedInTag
+	c.Tests = commit.Tests
+	c.Churn = commit.Churn
+	c.DeadlineExceeded = commit.DeadlineExceeded
+	c.Hosting = commit.Hosting
+	return c
+}
+
+// JSONLSink writes each commit and blame as a JSONLRecord on a separate line.
+type JSONLSink struct {
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewJSONLSink creates a sink writing to wr. Output is buffered and flushed in OnFinish. If wr is an io.Closer, it is closed in OnFinish.
+func NewJSONLSink(wr io.Writer) *JSONLSink {
+	s := &JSONLSink{}
+	s.buf = bufio.NewWriter(wr)
+	s.enc = json.NewEncoder(s.buf)
+	if c, ok := wr.(io.Closer); ok {
+		s.closer = c
+	}
+	return s
+}
+
+func (s *JSONLSink) OnCommit(ctx context.Context, commit CommitCode) error {
+	c := newJSONLCommit(commit)
+	return s.enc.Encode(JSONLRecord{Kind: "commit", Commit: &c})
+}
+
+func (s *JSONLSink) OnBlame(ctx context.Context, blame BlameResult) error {
+	return s.enc.Encode(JSONLRecord{Kind: "blame", Blame: &blame})
+}
+
+func (s *JSONLSink) OnFinish(ctx context.Context, err error) error {
+	err = s.buf.Flush()
+	if s.closer != nil {
+		err2 := s.closer.Close()
+		if err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QueueMessage is a record published by QueueSink. Value is a json encoded JSONLRecord.
+type QueueMessage struct {
+	// Key is the commit sha for commits and sha:filename for blames. Use it as the partition key, so that records of a commit stay in order.
+	Key string
+	// Kind is "commit" or "blame", same as JSONLRecord.Kind.
+	Kind  string
+	Value []byte
+}
+
+// Publisher sends messages to a message queue, for example a Kafka producer writing to a topic. Adapt the client used by your pipeline to this interface.
+type Publisher interface {
+	// Publish sends a batch of messages in order. Returned error retries the whole batch, so publishing should be idempotent or tolerate duplicates.
+	Publish(ctx context.Context, msgs []QueueMessage) error
+}
+
+// QueueSinkOpts are options for NewQueueSink.
+type QueueSinkOpts struct {
+	// BatchSize is the max number of messages published in one call. Default is 500.
+	BatchSize int
+	// MaxRetries is the number of retries of a failed batch before processing fails. Default is 5, negative disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for each next one. Default is 1s.
+	RetryBackoff time.Duration
+	// OmitLines does not include BlameResult.Lines in blame records, which are large for big files.
+	OmitLines bool
+}
+
+// QueueSink publishes commits and blames to a message queue in batches, retrying failed batches. Messages are published in the same order as results are returned.
+type QueueSink struct {
+	pub     Publisher
+	opts    QueueSinkOpts
+	pending []QueueMessage
+	// sleep is replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewQueueSink creates a sink publishing to pub.
+func NewQueueSink(pub Publisher, opts QueueSinkOpts) *QueueSink {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	s := &QueueSink{}
+	s.pub = pub
+	s.opts = opts
+	s.sleep = sleepContext
+	return s
+}
+
+func (s *QueueSink) OnCommit(ctx context.Context, commit CommitCode) error {
+	c := newJSONLCommit(commit)
+	return s.add(ctx, commit.SHA, JSONLRecord{Kind: "commit", Commit: &c})
+}
+
+func (s *QueueSink) OnBlame(ctx context.Context, blame BlameResult) error {
+	if s.opts.OmitLines {
+		blame.Lines = nil
+	}
+	return s.add(ctx, blame.Commit.SHA+":"+blame.Filename, JSONLRecord{Kind: "blame", Blame: &blame})
+}
+
+// OnFinish publishes remaining messages. Messages are published even if processing failed, since returned results are included in the checkpoint.
+func (s *QueueSink) OnFinish(ctx context.Context, err error) error {
+	return s.flush(ctx)
+}
+
+func (s *QueueSink) add(ctx context.Context, key string, rec JSONLRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, QueueMessage{Key: key, Kind: rec.Kind, Value: b})
+	if len(s.pending) >= s.opts.BatchSize {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+func (s *QueueSink) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	backoff := s.opts.RetryBackoff
+	for i := 0; ; i++ {
+		err := s.pub.Publish(ctx, s.pending)
+		if err == nil {
+			break
+		}
+		if i >= s.opts.MaxRetries || ctx.Err() != nil {
+			return fmt.Errorf("could not publish %v messages after %v attempts, err: %v", len(s.pending), i+1, err)
+		}
+		err = s.sleep(ctx, backoff)
+		if err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+	s.pending = nil
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
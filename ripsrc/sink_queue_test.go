@@ -0,0 +1,82 @@
+package ripsrc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+type flakyPublisher struct {
+	// fail is the number of calls to fail
+	fail    int
+	calls   int
+	batches [][]QueueMessage
+}
+
+func (s *flakyPublisher) Publish(ctx context.Context, msgs []QueueMessage) error {
+	s.calls++
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("broker not available")
+	}
+	s.batches = append(s.batches, msgs)
+	return nil
+}
+
+func TestQueueSink(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("b.txt", "b\n").Commit("c2")
+
+	pub := &flakyPublisher{fail: 2}
+	sink := NewQueueSink(pub, QueueSinkOpts{BatchSize: 3, OmitLines: true})
+	var waits []time.Duration
+	sink.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	runAll(t, New(Opts{RepoDir: r.Dir(), Sinks: []OutputSink{sink}}))
+
+	if len(pub.batches) != 2 || len(pub.batches[0]) != 3 || len(pub.batches[1]) != 1 {
+		t.Fatalf("unexpected batches %+v", pub.batches)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Fatalf("unexpected retry backoff %v", waits)
+	}
+	m := pub.batches[0][1]
+	if m.Kind != "blame" || m.Key != c1+":a.txt" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	var rec JSONLRecord
+	err := json.Unmarshal(m.Value, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Blame == nil || rec.Blame.Filename != "a.txt" || len(rec.Blame.Lines) != 0 {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
+
+func TestQueueSinkRetriesExhausted(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+
+	pub := &flakyPublisher{fail: 100}
+	sink := NewQueueSink(pub, QueueSinkOpts{MaxRetries: 2})
+	sink.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+	_, err := New(Opts{RepoDir: r.Dir(), Sinks: []OutputSink{sink}}).CodeSlice(context.Background())
+	if err == nil {
+		t.Fatal("expected publish error")
+	}
+	if pub.calls != 3 {
+		t.Fatalf("wanted 3 attempts, got %v", pub.calls)
+	}
+}
@@ -0,0 +1,108 @@
+package ripsrc
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SQLSinkSchema creates tables written by SQLSink, using SQLite syntax. Rows are keyed by commit sha and file name, so repeated runs replace existing rows.
+const SQLSinkSchema = `CREATE TABLE IF NOT EXISTS ripsrc_commits (
+	sha TEXT PRIMARY KEY,
+	author_name TEXT,
+	author_email TEXT,
+	committer_name TEXT,
+	committer_email TEXT,
+	date TEXT,
+	committer_date TEXT,
+	message TEXT,
+	parents TEXT,
+	released_in_tag TEXT
+);
+CREATE TABLE IF NOT EXISTS ripsrc_blames (
+	sha TEXT,
+	filename TEXT,
+	language TEXT,
+	status TEXT,
+	size INTEGER,
+	loc INTEGER,
+	sloc INTEGER,
+	comments INTEGER,
+	blanks INTEGER,
+	complexity INTEGER,
+	skipped TEXT,
+	blob_sha TEXT,
+	PRIMARY KEY (sha, filename)
+);`
+
+// DefaultSQLSinkBatch is the default number of commits written in one transaction by SQLSink.
+const DefaultSQLSinkBatch = 100
+
+// SQLSink writes commits and file stats into SQLite tables described in SQLSinkSchema. Lines are not written.
+// The database is opened by the caller with a SQLite driver of their choice, for example github.com/mattn/go-sqlite3, so ripsrc does not depend on cgo. The database is not closed by the sink.
+type SQLSink struct {
+	db      *sql.DB
+	batch   int
+	tx      *sql.Tx
+	commits int
+}
+
+// NewSQLSink creates tables if needed and returns a sink writing batch commits per transaction, DefaultSQLSinkBatch if batch is 0.
+func NewSQLSink(ctx context.Context, db *sql.DB, batch int) (*SQLSink, error) {
+	for _, stmt := range strings.Split(SQLSinkSchema, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		_, err := db.ExecContext(ctx, stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if batch <= 0 {
+		batch = DefaultSQLSinkBatch
+	}
+	s := &SQLSink{}
+	s.db = db
+	s.batch = batch
+	return s, nil
+}
+
+func (s *SQLSink) OnCommit(ctx context.Context, commit CommitCode) error {
+	if s.tx != nil && s.commits >= s.batch {
+		err := s.tx.Commit()
+		s.tx = nil
+		if err != nil {
+			return err
+		}
+	}
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		s.tx = tx
+		s.commits = 0
+	}
+	s.commits++
+	c := commit.Commit
+	_, err := s.tx.ExecContext(ctx, `INSERT OR REPLACE INTO ripsrc_commits (sha, author_name, author_email, committer_name, committer_email, date, committer_date, message, parents, released_in_tag) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.SHA, c.AuthorName, c.AuthorEmail, c.CommitterName, c.CommitterEmail, c.Date.Format(time.RFC3339), c.CommitterDate.Format(time.RFC3339), c.Message, strings.Join(c.Parents, " "), commit.ReleasedInTag)
+	return err
+}
+
+func (s *SQLSink) OnBlame(ctx context.Context, b BlameResult) error {
+	_, err := s.tx.ExecContext(ctx, `INSERT OR REPLACE INTO ripsrc_blames (sha, filename, language, status, size, loc, sloc, comments, blanks, complexity, skipped, blob_sha) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.Commit.SHA, b.Filename, b.Language, string(b.Status), b.Size, b.Loc, b.Sloc, b.Comments, b.Blanks, b.Complexity, b.Skipped, b.BlobSHA)
+	return err
+}
+
+// OnFinish commits the last batch. Rows written before an error are kept, rerunning replaces them.
+func (s *SQLSink) OnFinish(ctx context.Context, err error) error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx = nil
+	return tx.Commit()
+}
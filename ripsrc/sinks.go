@@ -0,0 +1,65 @@
+package ripsrc
+
+import (
+	"context"
+	"fmt"
+)
+
+// OutputSink receives results of CodeByCommit while they are returned, so that exports run during the pass without teeing the channel. Set sinks in Opts.Sinks.
+// Methods are called from a single goroutine, in the same order as results are returned. Returned errors stop processing.
+type OutputSink interface {
+	// OnCommit is called for each commit before its blames. Blames channel is nil.
+	OnCommit(ctx context.Context, commit CommitCode) error
+	// OnBlame is called for each file of the last commit passed to OnCommit.
+	OnBlame(ctx context.Context, blame BlameResult) error
+	// OnFinish is called once when CodeByCommit returns, with the error it returns, nil on success. Use it to flush and close outputs.
+	OnFinish(ctx context.Context, err error) error
+}
+
+// NoopSink is a sink that discards all results. Useful to run the pass only for its side effects, such as writing checkpoints.
+type NoopSink struct{}
+
+func (NoopSink) OnCommit(ctx context.Context, commit CommitCode) error {
+	return nil
+}
+
+func (NoopSink) OnBlame(ctx context.Context, blame BlameResult) error {
+	return nil
+}
+
+func (NoopSink) OnFinish(ctx context.Context, err error) error {
+	return nil
+}
+
+// toSinks passes commit and its blames to Opts.Sinks
+func (s *Ripsrc) toSinks(ctx context.Context, rc CommitCode, blames []BlameResult) error {
+	if len(s.opts.Sinks) == 0 {
+		return nil
+	}
+	rc.Blames = nil
+	for _, sink := range s.opts.Sinks {
+		err := sink.OnCommit(ctx, rc)
+		if err != nil {
+			return fmt.Errorf("output sink %T failed, commit: %v err: %v", sink, rc.SHA, err)
+		}
+		for _, b := range blames {
+			err := sink.OnBlame(ctx, b)
+			if err != nil {
+				return fmt.Errorf("output sink %T failed, commit: %v file: %v err: %v", sink, rc.SHA, b.Filename, err)
+			}
+		}
+	}
+	return nil
+}
+
+// finishSinks calls OnFinish of all sinks, even if one of them fails. Returns err if set, otherwise the first sink error.
+func (s *Ripsrc) finishSinks(ctx context.Context, err error) error {
+	res := err
+	for _, sink := range s.opts.Sinks {
+		err2 := sink.OnFinish(ctx, err)
+		if err2 != nil && res == nil {
+			res = fmt.Errorf("output sink %T failed to finish, err: %v", sink, err2)
+		}
+	}
+	return res
+}
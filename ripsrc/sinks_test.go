@@ -0,0 +1,219 @@
+package ripsrc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+type recordingSink struct {
+	events []string
+	err    error
+}
+
+func (s *recordingSink) OnCommit(ctx context.Context, commit CommitCode) error {
+	s.events = append(s.events, "commit:"+commit.Message)
+	return nil
+}
+
+func (s *recordingSink) OnBlame(ctx context.Context, blame BlameResult) error {
+	s.events = append(s.events, "blame:"+blame.Filename)
+	return nil
+}
+
+func (s *recordingSink) OnFinish(ctx context.Context, err error) error {
+	s.events = append(s.events, "finish")
+	s.err = err
+	return nil
+}
+
+func TestSinks(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("b.txt", "b\n").Write("a.txt", "a2\n").Commit("c2")
+
+	var buf bytes.Buffer
+	rec := &recordingSink{}
+	runAll(t, New(Opts{RepoDir: r.Dir(), Sinks: []OutputSink{NewJSONLSink(&buf), NoopSink{}, rec}}))
+
+	want := []string{"commit:c1", "blame:a.txt", "commit:c2", "blame:a.txt", "blame:b.txt", "finish"}
+	got := append([]string{}, rec.events...)
+	// files of a commit are not ordered
+	if len(got) == len(want) && got[3] > got[4] {
+		got[3], got[4] = got[4], got[3]
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") || rec.err != nil {
+		t.Fatalf("wanted events %v got %v err %v", want, rec.events, rec.err)
+	}
+
+	var kinds []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec JSONLRecord
+		err := json.Unmarshal(sc.Bytes(), &rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch rec.Kind {
+		case "commit":
+			if rec.Commit == nil || rec.Commit.SHA == "" {
+				t.Fatalf("commit not set %s", sc.Bytes())
+			}
+		case "blame":
+			if rec.Blame == nil || rec.Blame.Filename == "" || len(rec.Blame.Lines) == 0 {
+				t.Fatalf("blame not set %s", sc.Bytes())
+			}
+		}
+		kinds = append(kinds, rec.Kind)
+	}
+	if strings.Join(kinds, ",") != "commit,blame,commit,blame,blame" {
+		t.Fatalf("unexpected jsonl records %v", kinds)
+	}
+}
+
+type failingSink struct {
+	NoopSink
+}
+
+func (failingSink) OnBlame(ctx context.Context, blame BlameResult) error {
+	return errors.New("disk full")
+}
+
+func TestSinkError(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	rec := &recordingSink{}
+	_, err := New(Opts{RepoDir: r.Dir(), Sinks: []OutputSink{failingSink{}, rec}}).CodeSlice(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+	if rec.err == nil {
+		t.Fatal("expected error passed to OnFinish")
+	}
+}
+
+// fakeSQLDriver records executed statements, enough to test SQLSink without a database
+type fakeSQLDriver struct {
+	mu    sync.Mutex
+	execs []string
+	// commits is the number of committed transactions
+	commits int
+}
+
+func (d *fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	return fakeSQLConn{d}, nil
+}
+
+type fakeSQLConn struct {
+	d *fakeSQLDriver
+}
+
+func (c fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeSQLStmt{c.d, query}, nil
+}
+
+func (c fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c fakeSQLConn) Begin() (driver.Tx, error) {
+	return fakeSQLTx{c.d}, nil
+}
+
+type fakeSQLTx struct {
+	d *fakeSQLDriver
+}
+
+func (t fakeSQLTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.commits++
+	return nil
+}
+
+func (t fakeSQLTx) Rollback() error {
+	return nil
+}
+
+type fakeSQLStmt struct {
+	d     *fakeSQLDriver
+	query string
+}
+
+func (s fakeSQLStmt) Close() error {
+	return nil
+}
+
+func (s fakeSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	q := strings.Fields(s.query)
+	s.d.execs = append(s.d.execs, strings.Join(q[:5], " "))
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var fakeSQL = &fakeSQLDriver{}
+
+func init() {
+	sql.Register("ripsrc-fake-sql", fakeSQL)
+}
+
+func TestSQLSink(t *testing.T) {
+	d := fakeSQL
+	d.execs = nil
+	d.commits = 0
+	db, err := sql.Open("ripsrc-fake-sql", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	r.Write("b.txt", "b\n").Commit("c2")
+	r.Write("c.txt", "c\n").Commit("c3")
+
+	ctx := context.Background()
+	sink, err := NewSQLSink(ctx, db, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runAll(t, New(Opts{RepoDir: r.Dir(), Sinks: []OutputSink{sink}}))
+
+	var commits, blames int
+	for _, e := range d.execs {
+		switch e {
+		case "INSERT OR REPLACE INTO ripsrc_commits":
+			commits++
+		case "INSERT OR REPLACE INTO ripsrc_blames":
+			blames++
+		}
+	}
+	if commits != 3 || blames != 3 {
+		t.Fatalf("wanted 3 commits and 3 blames, got %v %v, statements %v", commits, blames, d.execs)
+	}
+	// batch of 2 commits and the last one
+	if d.commits != 2 {
+		t.Fatalf("wanted 2 transactions, got %v", d.commits)
+	}
+}
@@ -0,0 +1,123 @@
+package ripsrc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// SkippedPath is a file excluded from code analysis. Returned in SkipReport.
+type SkippedPath struct {
+	Path string
+	// Reason is the same as BlameResult.Skipped.
+	Reason string
+	// Rule is the matching rule, for example the exclusion pattern or the exceeded limit.
+	Rule string
+	// Commits is the number of processed commits that changed the file while it was skipped.
+	Commits int
+	// LastCommit is the last processed commit that skipped the file.
+	LastCommit string
+}
+
+// SkippedCommit is a commit which file diffs were partially or fully skipped because it exceeded Opts.CommitDeadline. Returned in SkipReport.
+type SkippedCommit struct {
+	SHA string
+	// Files is the number of files changed by the commit that got unknown blame.
+	Files int
+}
+
+// SkipReport lists all paths excluded from code analysis and commits skipped because of Opts.CommitDeadline during the run. Enable using Opts.SkipReport and get using Ripsrc.SkipReport after the run.
+type SkipReport struct {
+	mu      sync.Mutex
+	paths   map[string]*SkippedPath
+	commits []SkippedCommit
+}
+
+func newSkipReport() *SkipReport {
+	s := &SkipReport{}
+	s.paths = map[string]*SkippedPath{}
+	return s
+}
+
+func (s *SkipReport) add(path, reason, rule, commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.paths[path]
+	if !ok {
+		p = &SkippedPath{Path: path}
+		s.paths[path] = p
+	}
+	p.Reason = reason
+	p.Rule = rule
+	p.Commits++
+	p.LastCommit = commit
+}
+
+func (s *SkipReport) addCommit(sha string, files int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commits = append(s.commits, SkippedCommit{SHA: sha, Files: files})
+}
+
+// Commits returns commits skipped because of Opts.CommitDeadline in processing order.
+func (s *SkipReport) Commits() []SkippedCommit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SkippedCommit(nil), s.commits...)
+}
+
+// Paths returns skipped paths sorted by path.
+func (s *SkipReport) Paths() (res []SkippedPath) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.paths {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return
+}
+
+// ByReason returns the number of skipped paths by reason.
+func (s *SkipReport) ByReason() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := map[string]int{}
+	for _, p := range s.paths {
+		res[p.Reason]++
+	}
+	return res
+}
+
+// OutputStats writes human-readable report.
+func (s *SkipReport) OutputStats(wr io.Writer) {
+	byReason := s.ByReason()
+	var reasons []string
+	for r := range byReason {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	fmt.Fprintln(wr, "skipped paths by reason")
+	for _, r := range reasons {
+		fmt.Fprintf(wr, "%v: %v\n", r, byReason[r])
+	}
+	fmt.Fprintln(wr, "skipped paths")
+	for _, p := range s.Paths() {
+		fmt.Fprintf(wr, "%v\t%v\t%v\n", p.Path, p.Reason, p.Rule)
+	}
+	commits := s.Commits()
+	if len(commits) == 0 {
+		return
+	}
+	fmt.Fprintln(wr, "commits over deadline")
+	for _, c := range commits {
+		fmt.Fprintf(wr, "%v\t%v files\n", c.SHA, c.Files)
+	}
+}
+
+// SkipReport returns paths excluded from code analysis by runs of this Ripsrc. Returns nil unless Opts.SkipReport is set.
+func (s *Ripsrc) SkipReport() *SkipReport {
+	return s.skipReport
+}
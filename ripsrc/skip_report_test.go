@@ -0,0 +1,47 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSkipReport(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").
+		Write("node_modules/b.js", "var b\n").
+		Write(".env", "A=1\n").
+		Commit("c1")
+	c2 := r.Write("node_modules/b.js", "var b = 1\n").Commit("c2")
+
+	rs := New(Opts{RepoDir: r.Dir()})
+	if rs.SkipReport() != nil {
+		t.Fatal("report should be nil unless enabled")
+	}
+
+	rs = New(Opts{RepoDir: r.Dir(), SkipReport: true})
+	_, err := rs.CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rs.SkipReport().Paths()
+	if len(got) != 2 {
+		t.Fatalf("wanted 2 skipped paths, got %+v", got)
+	}
+	if got[0].Path != ".env" || got[0].Reason != "File was a dot file" {
+		t.Errorf("unexpected %+v", got[0])
+	}
+	b := got[1]
+	if b.Path != "node_modules/b.js" || b.Reason != "File was on an exclusion list" || !strings.Contains(b.Rule, "node_modules") || b.Commits != 2 || b.LastCommit != c2 {
+		t.Errorf("unexpected %+v", b)
+	}
+	out := bytes.NewBuffer(nil)
+	rs.SkipReport().OutputStats(out)
+	if !strings.Contains(out.String(), "File was on an exclusion list: 1") {
+		t.Errorf("unexpected output\n%v", out.String())
+	}
+}
@@ -0,0 +1,49 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSkippedFiles(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n").
+		Write("vendor/b.go", "package b\n\nvar x = 1\n").
+		Commit("c1")
+
+	run := func(mode SkippedFiles) map[string]BlameResult {
+		t.Helper()
+		res, err := New(Opts{RepoDir: r.Dir(), SkippedFiles: mode}).CodeSlice(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]BlameResult{}
+		for _, b := range res {
+			got[b.Filename] = b
+		}
+		return got
+	}
+
+	got := run(SkippedFilesReason)
+	if b := got["vendor/b.go"]; !b.IsSkipped() || b.Size != 0 || b.Loc != 0 {
+		t.Errorf("wanted skipped file with reason only, got %+v", b)
+	}
+
+	got = run(SkippedFilesFlag)
+	if b := got["vendor/b.go"]; !b.IsSkipped() || b.Size != 21 || b.Loc != 3 || len(b.Lines) != 0 {
+		t.Errorf("wanted skipped file with size and loc, got %+v", b)
+	}
+
+	got = run(SkippedFilesDrop)
+	if _, ok := got["vendor/b.go"]; ok || len(got) != 1 {
+		t.Errorf("wanted skipped file dropped, got %+v", got)
+	}
+
+	err := Opts{RepoDir: r.Dir(), SkippedFiles: "invalid"}.Validate()
+	if err == nil {
+		t.Error("wanted error for invalid SkippedFiles")
+	}
+}
@@ -0,0 +1,179 @@
+package ripsrc
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// snapshotFormat is the version of the snapshot archive layout, changes when files other than checkpoint files change
+const snapshotFormat = 1
+
+const snapshotHeaderFile = "snapshot.json"
+
+// SnapshotHeader describes a snapshot written by ExportSnapshot. It is the first file of the archive.
+type SnapshotHeader struct {
+	// Format is the version of the snapshot archive layout.
+	Format int
+	// Version is the ripsrc version that wrote the snapshot, see Version.
+	Version string
+	// Commit is the last processed commit included in the snapshot. Pass it as CommitFromIncl to continue processing after import.
+	Commit string
+	// Refs are tips of refs processed by the last successful run, map[ref]commit. Empty if the last run was made by an older version of ripsrc.
+	Refs map[string]string
+	// Commits is the number of commits processed by the last successful run.
+	Commits int
+	// Time when checkpoint was written.
+	Time time.Time
+}
+
+// ExportSnapshot writes the complete blame state saved by the last run to wr as a tar archive, so that incremental processing could be continued on another machine using ImportSnapshot.
+// Unlike checkpoints dir, the archive does not depend on CheckpointsDir, Namespace or RepoDir. Checkpoint files are written as is, so a custom CheckpointCompression must be registered when importing.
+func (s *Ripsrc) ExportSnapshot(ctx context.Context, wr io.Writer) error {
+	err := s.opts.validate(ctx)
+	if err != nil {
+		return err
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	dir, err := s.checkpointDir(ctx)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CheckpointCommit(dir)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		return errors.New("ExportSnapshot: no checkpoint for the repo, run CodeByCommit first")
+	}
+	h := SnapshotHeader{}
+	h.Format = snapshotFormat
+	h.Version = version()
+	h.Commit = commit
+	m, err := s.readRefsManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if m != nil && m.CheckpointCommit == commit {
+		h.Refs = m.Refs
+		h.Commits = m.Commits
+		h.Time = m.Time
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(wr)
+	err = tw.WriteHeader(&tar.Header{
+		Name:    snapshotHeaderFile,
+		Mode:    0666,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	if err != nil {
+		return err
+	}
+	err = repo.ExportCheckpoint(dir, tw)
+	if err != nil {
+		return fmt.Errorf("could not export checkpoint, err: %v", err)
+	}
+	return tw.Close()
+}
+
+// ImportSnapshot reads archive written by ExportSnapshot and replaces checkpoint for the repo, so that the next CodeByCommit with CommitFromIncl set to SnapshotHeader.Commit continues processing from the exported state.
+// Existing checkpoint is kept if the archive is invalid or incomplete. Snapshots in older checkpoint format are migrated.
+func (s *Ripsrc) ImportSnapshot(ctx context.Context, r io.Reader) (res SnapshotHeader, _ error) {
+	err := s.opts.validate(ctx)
+	if err != nil {
+		return res, err
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer lock.Release()
+	dir, err := s.checkpointDir(ctx)
+	if err != nil {
+		return res, err
+	}
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return res, fmt.Errorf("ImportSnapshot: could not read archive, err: %v", err)
+	}
+	if hdr.Name != snapshotHeaderFile {
+		return res, fmt.Errorf("ImportSnapshot: not a snapshot, expected %v as the first file, got %v", snapshotHeaderFile, hdr.Name)
+	}
+	b, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return res, fmt.Errorf("ImportSnapshot: could not parse snapshot header, err: %v", err)
+	}
+	if res.Format != snapshotFormat {
+		return res, fmt.Errorf("ImportSnapshot: unsupported snapshot format %v, supported format is %v", res.Format, snapshotFormat)
+	}
+
+	imp, err := repo.NewCheckpointImport(dir)
+	if err != nil {
+		return res, err
+	}
+	defer imp.Close()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("ImportSnapshot: could not read archive, err: %v", err)
+		}
+		err = imp.Add(hdr.Name, tr)
+		if err != nil {
+			return res, err
+		}
+	}
+	commit, err := imp.Finish()
+	if err != nil {
+		return res, err
+	}
+	if commit != res.Commit {
+		// checkpoint is already replaced, but refs manifest is not, so NeedsProcessing will process the repo again
+		return res, fmt.Errorf("ImportSnapshot: checkpoint commit %v does not match snapshot header commit %v", commit, res.Commit)
+	}
+	if len(res.Refs) == 0 {
+		return res, nil
+	}
+	return res, s.writeRefsManifest(ctx, res.Refs, res.Commit, res.Commits)
+}
+
+// checkpointDir returns dir containing checkpoint for the repo
+func (s *Ripsrc) checkpointDir(ctx context.Context) (string, error) {
+	checkpointsDir, err := s.checkpointsDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	p := process.New(process.Opts{
+		Logger:         s.opts.Logger,
+		RepoDir:        s.opts.RepoDir,
+		CheckpointsDir: checkpointsDir,
+	})
+	return p.Dir(), nil
+}
@@ -0,0 +1,108 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSnapshotExportImport(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\nb\n").Commit("c1")
+	c2 := r.Write("b.txt", "b\n").Commit("c2")
+
+	dirA, err := ioutil.TempDir("", "ripsrc-snapshot-a-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirA)
+	dirB, err := ioutil.TempDir("", "ripsrc-snapshot-b-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirB)
+	ctx := context.Background()
+
+	_, err = New(Opts{RepoDir: r.Dir(), CheckpointsDir: dirA}).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = New(Opts{RepoDir: r.Dir(), CheckpointsDir: dirA}).ExportSnapshot(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ripB := New(Opts{RepoDir: r.Dir(), CheckpointsDir: dirB})
+	h, err := ripB.ImportSnapshot(ctx, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Commit != c2 || h.Refs["HEAD"] != c2 || h.Commits != 2 {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	info, err := ripB.Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.Commit != c2 || info.ProcessedCommits != 2 {
+		t.Fatalf("checkpoint not imported %+v", info)
+	}
+
+	// continue from imported state, unchanged lines are attributed to commits processed before export
+	c3 := r.Write("a.txt", "a\nb\nc\n").Commit("c3")
+	res, err := New(Opts{RepoDir: r.Dir(), CheckpointsDir: dirB, CommitFromIncl: h.Commit, CommitFromMakeNonIncl: true}).CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Filename != "a.txt" || res[0].Commit.SHA != c3 {
+		t.Fatalf("unexpected results %+v", res)
+	}
+	var got []string
+	for _, l := range res[0].Lines {
+		got = append(got, l.SHA)
+	}
+	want := []string{c1, c1, c3}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("wanted blame %v, got %v", want, got)
+	}
+}
+
+func TestSnapshotImportInvalid(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+	dir, err := ioutil.TempDir("", "ripsrc-snapshot-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	rip := New(Opts{RepoDir: r.Dir(), CheckpointsDir: dir})
+	_, err = rip.CodeSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = rip.ExportSnapshot(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// truncated archive does not replace existing checkpoint
+	_, err = rip.ImportSnapshot(ctx, bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	if err == nil {
+		t.Fatal("expected error for truncated snapshot")
+	}
+	info, err := rip.Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.Commit != c1 {
+		t.Fatalf("existing checkpoint changed %+v", info)
+	}
+}
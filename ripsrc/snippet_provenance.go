@@ -0,0 +1,165 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+)
+
+// SnippetMatch is a location of the snippet in the repo history, returned from SnippetProvenance.
+type SnippetMatch struct {
+	// Filename is the file containing the snippet.
+	Filename string
+	// FoundIn is the first processed commit in which the file contained these lines.
+	FoundIn Commit
+	// Start is the first matched line in FoundIn, 1-based.
+	Start int
+	// End is the last matched line in FoundIn, inclusive. Blank lines inside the match are included.
+	End int
+	// Lines is the blame of the matched lines from Start to End.
+	Lines []*BlameLine
+	// Origin is the commit that added most of the matched lines, the earliest one if there are multiple.
+	Origin Commit
+}
+
+// snippetLines returns trimmed non-blank lines of the snippet.
+func snippetLines(snippet string) (res [][]byte) {
+	for _, l := range strings.Split(snippet, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		res = append(res, []byte(l))
+	}
+	return
+}
+
+// findSnippet returns ranges of lines (0-based, inclusive) that match the snippet, ignoring indentation and blank lines.
+func findSnippet(lines incblame.Lines, snippet [][]byte) (res [][2]int) {
+	var nonBlank []int
+	for i, l := range lines {
+		if len(bytes.TrimSpace(l.Line)) != 0 {
+			nonBlank = append(nonBlank, i)
+		}
+	}
+	for i := 0; i+len(snippet) <= len(nonBlank); i++ {
+		match := true
+		for j, sl := range snippet {
+			if !bytes.Equal(bytes.TrimSpace(lines[nonBlank[i+j]].Line), sl) {
+				match = false
+				break
+			}
+		}
+		if match {
+			res = append(res, [2]int{nonBlank[i], nonBlank[i+len(snippet)-1]})
+		}
+	}
+	return
+}
+
+// SnippetProvenance searches the blame of every file changed in processed commits for the snippet and returns where its lines originate, for example to check where copied code came from.
+// Lines are compared ignoring leading and trailing whitespace, blank lines are ignored. A location is returned once for each file and set of commits that added the matched lines, so the same code is not repeated for every later change of the file.
+// Matches are sorted by Origin date, the oldest first. History is processed using CodeByCommit, run without CommitFromIncl to search the whole history.
+func (s *Ripsrc) SnippetProvenance(ctx context.Context, snippet string) (res []SnippetMatch, _ error) {
+	lines := snippetLines(snippet)
+	if len(lines) == 0 {
+		return nil, errors.New("SnippetProvenance: snippet is empty")
+	}
+	seen := map[string]bool{}
+	s.onProcessResult = func(r process.Result, commit commitmeta.Commit) {
+		for fn, bl := range r.Files {
+			if bl.IsBinary || bl.IsUnknown {
+				continue
+			}
+			for _, m := range findSnippet(bl.Lines, lines) {
+				key := []string{fn}
+				for _, l := range bl.Lines[m[0] : m[1]+1] {
+					key = append(key, l.Commit)
+				}
+				k := strings.Join(key, "\x00")
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				res = append(res, s.snippetMatch(fn, commit, bl.Lines, m[0], m[1]))
+			}
+		}
+	}
+	defer func() {
+		s.onProcessResult = nil
+	}()
+	commits := make(chan CommitCode)
+	done := make(chan bool)
+	go func() {
+		for c := range commits {
+			for range c.Blames {
+			}
+		}
+		done <- true
+	}()
+	err := s.CodeByCommit(ctx, commits)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		da, db := s.opts.CommitDate.Of(a.Origin), s.opts.CommitDate.Of(b.Origin)
+		if !da.Equal(db) {
+			return da.Before(db)
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start < b.Start
+	})
+	return res, nil
+}
+
+func (s *Ripsrc) snippetMatch(fn string, commit commitmeta.Commit, lines incblame.Lines, start, end int) (res SnippetMatch) {
+	res.Filename = fn
+	res.FoundIn = commit
+	res.Start = start + 1
+	res.End = end + 1
+	counts := map[string]int{}
+	for i, l := range lines[start : end+1] {
+		meta := s.commitMeta[l.Commit]
+		res.Lines = append(res.Lines, &BlameLine{
+			Line:  start + i + 1,
+			Name:  meta.AuthorName,
+			Email: meta.AuthorEmail,
+			Date:  s.opts.CommitDate.Of(meta),
+			SHA:   l.Commit,
+		})
+		if len(bytes.TrimSpace(l.Line)) != 0 {
+			counts[l.Commit]++
+		}
+	}
+	var shas []string
+	for sha := range counts {
+		shas = append(shas, sha)
+	}
+	sort.Slice(shas, func(i, j int) bool {
+		a, b := shas[i], shas[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		da, db := s.opts.CommitDate.Of(s.commitMeta[a]), s.opts.CommitDate.Of(s.commitMeta[b])
+		if !da.Equal(db) {
+			return da.Before(db)
+		}
+		return a < b
+	})
+	origin := shas[0]
+	res.Origin = s.commitMeta[origin]
+	if res.Origin.SHA == "" {
+		res.Origin.SHA = origin
+	}
+	return
+}
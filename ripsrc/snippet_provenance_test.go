@@ -0,0 +1,43 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestSnippetProvenance(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("util.go", "package a\n\nfunc Max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n").Commit("c1")
+	r.Write("util.go", "package a\n\n// Max returns the larger value\nfunc Max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n").Commit("c2")
+	c3 := r.Write("other/copy.go", "package other\n\nfunc max(a, b int) int {\n    if a > b {\n\n        return a\n    }\n    return b\n}\n").Commit("c3")
+
+	snippet := "if a > b {\n  return a\n}\nreturn b"
+	res, err := New(Opts{RepoDir: r.Dir()}).SnippetProvenance(context.Background(), snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 matches, got %+v", res)
+	}
+	m := res[0]
+	if m.Filename != "util.go" || m.FoundIn.SHA != c1 || m.Origin.SHA != c1 || m.Start != 4 || m.End != 7 || len(m.Lines) != 4 {
+		t.Errorf("unexpected first match %+v", m)
+	}
+	if m.Lines[0].Email != "user1@example.com" {
+		t.Errorf("expected author on matched lines, got %+v", m.Lines[0])
+	}
+	m = res[1]
+	if m.Filename != "other/copy.go" || m.Origin.SHA != c3 || m.Start != 4 || m.End != 8 {
+		t.Errorf("unexpected second match %+v", m)
+	}
+}
+
+func TestSnippetProvenanceEmpty(t *testing.T) {
+	_, err := New(Opts{RepoDir: "."}).SnippetProvenance(context.Background(), " \n\n")
+	if err == nil {
+		t.Fatal("expected error for empty snippet")
+	}
+}
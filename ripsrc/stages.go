@@ -0,0 +1,72 @@
+package ripsrc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Stages are analysis steps run by Code and CodeByCommit, combined with |. See Opts.Stages.
+type Stages int
+
+const (
+	// StageCommits returns commit metadata. Always required, use StageCommits alone to only get commits.
+	StageCommits Stages = 1 << iota
+	// StageBlame runs incremental blame over the history. Without it history is not processed and checkpoints are not read or written,
+	// CodeByCommit only returns commit metadata and CommitCode.Blames is closed without results.
+	StageBlame
+	// StageFileInfo detects language, license, tests and generated files, and applies skip rules. Without it all files are returned without Language and Skipped, and CommitCode.Tests counts all changes as production code.
+	StageFileInfo
+	// StageStats counts sloc, comments, blanks and complexity and classifies lines. Without it only Size and Loc are set. Requires StageFileInfo, since stats depend on language.
+	StageStats
+	// StageLines fills BlameResult.Lines. Without it only file level data is returned, LinesByAuthor of rollups is empty.
+	StageLines
+
+	// StagesAll runs all stages. This is the default.
+	StagesAll = StageCommits | StageBlame | StageFileInfo | StageStats | StageLines
+)
+
+// Has returns true if stage is enabled. Zero value means all stages.
+func (s Stages) Has(stage Stages) bool {
+	if s == 0 {
+		s = StagesAll
+	}
+	return s&stage == stage
+}
+
+func (s Stages) String() string {
+	if s == 0 {
+		s = StagesAll
+	}
+	var res []string
+	for _, st := range []struct {
+		stage Stages
+		name  string
+	}{
+		{StageCommits, "commits"},
+		{StageBlame, "blame"},
+		{StageFileInfo, "fileinfo"},
+		{StageStats, "stats"},
+		{StageLines, "lines"},
+	} {
+		if s.Has(st.stage) {
+			res = append(res, st.name)
+		}
+	}
+	return strings.Join(res, "|")
+}
+
+func (s Stages) validate() error {
+	if s&^StagesAll != 0 {
+		return errors.New("Stages: unknown stage")
+	}
+	if !s.Has(StageCommits) {
+		return errors.New("Stages: StageCommits is required")
+	}
+	if !s.Has(StageBlame) && (s.Has(StageFileInfo) || s.Has(StageStats) || s.Has(StageLines)) {
+		return errors.New("Stages: StageFileInfo, StageStats and StageLines require StageBlame")
+	}
+	if s.Has(StageStats) && !s.Has(StageFileInfo) {
+		return errors.New("Stages: StageStats requires StageFileInfo")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package ripsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestStagesCommitsOnly(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.go", "package a\n").Commit("c1")
+	c2 := r.Write("a.go", "package a\n\nvar A = 1\n").Commit("c2")
+
+	checkpointsDir := t.TempDir()
+	commits, blames, err := codeByCommit(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir, Stages: StageCommits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blames != 0 {
+		t.Errorf("expected no blames, got %v", blames)
+	}
+	got := shas(commits)
+	if len(got) != 2 || got[0] != c1 || got[1] != c2 {
+		t.Fatalf("unexpected commits %v", got)
+	}
+
+	// checkpoints were not written, so full processing starts from the first commit
+	res, err := New(Opts{RepoDir: r.Dir(), CheckpointsDir: checkpointsDir}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected blames of both commits, got %v", len(res))
+	}
+}
+
+func TestStagesSkipFileInfoAndLines(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\n// A\nvar A = 1\n").Commit("c1")
+
+	res, err := New(Opts{RepoDir: r.Dir(), Stages: StageCommits | StageBlame}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %v", len(res))
+	}
+	b := res[0]
+	if b.Language != "" || b.Lines != nil || b.Loc != 4 || b.Sloc != 0 || b.Size == 0 {
+		t.Errorf("unexpected result %+v", b)
+	}
+
+	res, err = New(Opts{RepoDir: r.Dir(), Stages: StagesAll &^ StageLines}).CodeSlice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = res[0]
+	if b.Language != "Go" || b.Lines != nil || b.Sloc != 2 || b.Comments != 1 {
+		t.Errorf("unexpected result %+v", b)
+	}
+}
+
+func TestStagesValidate(t *testing.T) {
+	cases := []struct {
+		stages Stages
+		valid  bool
+	}{
+		{0, true},
+		{StagesAll, true},
+		{StageCommits, true},
+		{StageCommits | StageBlame | StageLines, true},
+		{StageBlame, false},
+		{StageCommits | StageLines, false},
+		{StageCommits | StageBlame | StageStats, false},
+		{StagesAll << 1, false},
+	}
+	for _, c := range cases {
+		err := c.stages.validate()
+		if (err == nil) != c.valid {
+			t.Errorf("stages %v: expected valid %v, got %v", c.stages, c.valid, err)
+		}
+	}
+}
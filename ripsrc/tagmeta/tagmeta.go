@@ -0,0 +1,179 @@
+package tagmeta
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+)
+
+type Opts struct {
+	// Logger outputs logs.
+	Logger logger.Logger
+	// RepoDir is location of git repo.
+	RepoDir string
+}
+
+// Tag contains information about annotated or lightweight tag.
+type Tag struct {
+	// Name of the tag
+	Name string
+
+	// Commit is the commit that tag points to. For annotated tags this is the peeled commit, not the tag object.
+	Commit string
+
+	// Annotated is true for tags created with git tag -a, false for lightweight tags.
+	Annotated bool
+
+	// TagSHA is the sha of the tag object. Empty for lightweight tags.
+	TagSHA string
+
+	// TaggerName is the name of the tagger. Empty for lightweight tags.
+	TaggerName string
+
+	// TaggerEmail is the email of the tagger. Empty for lightweight tags.
+	TaggerEmail string
+
+	// Date is the tagger date for annotated tags and the committer date of the commit for lightweight tags.
+	Date time.Time
+
+	// Message is the tag message. Empty for lightweight tags.
+	Message string
+}
+
+const fieldSep = "@@@"
+
+// Get returns all tags in repo sorted by name. Tags that do not point to commits (for example tags on trees or blobs) are skipped.
+func Get(ctx context.Context, opts Opts) (res []Tag, _ error) {
+	format := strings.Join([]string{
+		"%(objectname)",
+		"%(objecttype)",
+		"%(refname:short)",
+		"%(*objectname)",
+		"%(*objecttype)",
+		"%(taggername)",
+		"%(taggeremail)",
+		"%(taggerdate:iso-strict)",
+		"%(committerdate:iso-strict)",
+		"%(*committerdate:iso-strict)",
+		"%(contents)",
+	}, fieldSep) + "%00"
+	data, err := execCommand(ctx, opts.RepoDir, []string{"for-each-ref", "--format", format, "refs/tags"})
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range bytes.Split(data, []byte{0}) {
+		rec := strings.TrimLeft(string(rec), "\n")
+		if strings.TrimSpace(rec) == "" {
+			continue
+		}
+		parts := strings.SplitN(rec, fieldSep, 11)
+		if len(parts) != 11 {
+			return nil, fmt.Errorf("unexpected git for-each-ref output: %v", rec)
+		}
+		t := Tag{}
+		t.Name = parts[2]
+		date := ""
+		switch parts[1] {
+		case "commit":
+			t.Commit = parts[0]
+			date = parts[8]
+		case "tag":
+			t.Annotated = true
+			t.TagSHA = parts[0]
+			t.TaggerName = parts[5]
+			t.TaggerEmail = strings.TrimSuffix(strings.TrimPrefix(parts[6], "<"), ">")
+			t.Message = strings.TrimSpace(parts[10])
+			date = parts[7]
+			switch parts[4] {
+			case "commit":
+				t.Commit = parts[3]
+			case "tag":
+				// tag of a tag, peel it fully
+				commit, err := peelCommit(ctx, opts.RepoDir, t.Name)
+				if err != nil {
+					return nil, err
+				}
+				t.Commit = commit
+			default:
+				opts.Logger.Debug("tagmeta: skipping tag not pointing to commit", "name", t.Name, "type", parts[4])
+				continue
+			}
+			if date == "" {
+				// old tags could be created without tagger
+				date = parts[9]
+			}
+		default:
+			opts.Logger.Debug("tagmeta: skipping tag not pointing to commit", "name", t.Name, "type", parts[1])
+			continue
+		}
+		if date != "" {
+			t.Date, err = time.Parse(time.RFC3339, date)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse tag date: %v err: %v", date, err)
+			}
+		}
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return
+}
+
+// ReleasedIn returns map[commit]tagName with the first tag (by Date) that includes the commit. Commits not included in any tag are not in the map.
+func ReleasedIn(gr *parentsgraph.Graph, tags []Tag) map[string]string {
+	sorted := make([]Tag, len(tags))
+	copy(sorted, tags)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a := sorted[i]
+		b := sorted[j]
+		if a.Date.Equal(b.Date) {
+			return a.Name < b.Name
+		}
+		return a.Date.Before(b.Date)
+	})
+	res := map[string]string{}
+	for _, t := range sorted {
+		stack := []string{t.Commit}
+		for len(stack) != 0 {
+			h := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if _, ok := res[h]; ok {
+				// already released in earlier tag, same applies to all parents
+				continue
+			}
+			res[h] = t.Name
+			stack = append(stack, gr.Parents[h]...)
+		}
+	}
+	return res
+}
+
+func peelCommit(ctx context.Context, repoDir string, name string) (string, error) {
+	data, err := execCommand(ctx, repoDir, []string{"rev-parse", "refs/tags/" + name + "^{commit}"})
+	if err != nil {
+		return "", err
+	}
+	res := strings.TrimSpace(string(data))
+	if len(res) != 40 {
+		return "", fmt.Errorf("unexpected output from git rev-parse for tag: %v", name)
+	}
+	return res, nil
+}
+
+func execCommand(ctx context.Context, repoDir string, args []string) ([]byte, error) {
+	r, err := gitexec.Exec(ctx, "git", repoDir, args)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,38 @@
+package tagmeta
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/parentsgraph"
+)
+
+func TestReleasedIn(t *testing.T) {
+	gr := parentsgraph.NewFromMap(map[string][]string{
+		"c1": nil,
+		"c2": {"c1"},
+		"c3": {"c2"},
+		"b1": {"c1"},
+		"c4": {"c3", "b1"},
+		"c5": {"c4"},
+	})
+	day := func(d int) time.Time {
+		return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	tags := []Tag{
+		{Name: "v2", Commit: "c4", Date: day(2)},
+		{Name: "v1", Commit: "c2", Date: day(1)},
+	}
+	got := ReleasedIn(gr, tags)
+	want := map[string]string{
+		"c1": "v1",
+		"c2": "v1",
+		"c3": "v2",
+		"b1": "v2",
+		"c4": "v2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted\n%v\ngot\n%v", want, got)
+	}
+}
@@ -0,0 +1,41 @@
+package e2etests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/tagmeta"
+)
+
+func TestTags1(t *testing.T) {
+	test := NewTest(t, "tags1", nil)
+	got := test.Run()
+
+	want := []tagmeta.Tag{
+		{
+			Name:   "v1",
+			Commit: "106f14214ff7c29d167035c6ea0dad4a04d3f7df",
+			Date:   parseTime("2019-01-01T01:02:00+01:00"),
+		},
+		{
+			Name:        "v2",
+			Commit:      "2d429b2af0f040bc4e9906d5548c90099f18b35e",
+			Annotated:   true,
+			TagSHA:      "2d4b5520253e436a936ff33f3262f23bd7c0bbd9",
+			TaggerName:  "User1",
+			TaggerEmail: "user1@example.com",
+			Date:        parseTime("2019-01-01T01:04:00+01:00"),
+			Message:     "Release 2",
+		},
+	}
+
+	assertResult(t, want, got)
+}
+
+func parseTime(s string) time.Time {
+	res, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+package e2etests
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testutil"
+	"github.com/pinpt/ripsrc/ripsrc/tagmeta"
+)
+
+type Test struct {
+	t        *testing.T
+	repoName string
+	opts     *tagmeta.Opts
+}
+
+func NewTest(t *testing.T, repoName string, opts *tagmeta.Opts) *Test {
+	s := &Test{}
+	s.t = t
+	s.repoName = repoName
+	s.opts = opts
+	return s
+}
+
+func (s *Test) Run() []tagmeta.Tag {
+	t := s.t
+	dirs := testutil.UnzipTestRepo(s.repoName)
+	defer dirs.Remove()
+
+	ctx := context.Background()
+	repoDir := dirs.RepoDir
+	gitexec.Prepare(ctx, "git", repoDir)
+
+	opts := tagmeta.Opts{}
+	if s.opts != nil {
+		opts = *s.opts
+	}
+	opts.Logger = logger.NewDefaultLogger(os.Stdout)
+	opts.RepoDir = repoDir
+	res, err := tagmeta.Get(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func assertResult(t *testing.T, want, got []tagmeta.Tag) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("invalid result count, wanted %v, got %v", len(want), len(got))
+	}
+	gotCopy := make([]tagmeta.Tag, len(got))
+	copy(gotCopy, got)
+
+	for i := range want {
+		g := gotCopy[i]
+		if !reflect.DeepEqual(want[i], g) {
+			t.Fatalf("invalid tag, wanted\n%+v\ngot\n%+v", want[i], got[i])
+		}
+	}
+}
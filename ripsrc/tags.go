@@ -0,0 +1,70 @@
+package ripsrc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pinpt/ripsrc/ripsrc/tagmeta"
+)
+
+// Tag contains information about annotated or lightweight tag.
+type Tag = tagmeta.Tag
+
+// Tags returns all tags in the repo with target commit, tagger, date and message.
+func (s *Ripsrc) Tags(ctx context.Context, res chan Tag) (rerr error) {
+	defer close(res)
+	defer func() {
+		rerr = ctxErr(ctx, rerr)
+	}()
+
+	err := s.prepareGitExec(ctx)
+	if errors.Is(err, ErrEmptyRepo) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tags, err := s.getTags(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		select {
+		case res <- s.scrubTag(t):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *Ripsrc) TagsSlice(ctx context.Context) (res []Tag, _ error) {
+	resChan := make(chan Tag)
+	done := make(chan bool)
+	go func() {
+		for r := range resChan {
+			res = append(res, r)
+		}
+		done <- true
+	}()
+	err := s.Tags(ctx, resChan)
+	<-done
+	return res, err
+}
+
+func (s *Ripsrc) getTags(ctx context.Context) ([]Tag, error) {
+	opts := tagmeta.Opts{}
+	opts.Logger = s.opts.Logger
+	opts.RepoDir = s.opts.RepoDir
+	return tagmeta.Get(s.gitContext(ctx), opts)
+}
+
+// getReleasedInTag returns map[commit]tagName with the first tag that includes the commit.
+func (s *Ripsrc) getReleasedInTag(ctx context.Context) (map[string]string, error) {
+	tags, err := s.getTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tagmeta.ReleasedIn(s.commitGraph, tags), nil
+}
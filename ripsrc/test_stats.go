@@ -0,0 +1,42 @@
+package ripsrc
+
+// TestStats splits lines changed by a commit between test and production files, see BlameResult.IsTest. Returned in CommitCode.Tests.
+type TestStats struct {
+	TestAdditions       int
+	TestDeletions       int
+	ProductionAdditions int
+	ProductionDeletions int
+}
+
+// TestRatio returns the number of test lines added per production line added. Returns 0 if no production lines were added.
+func (s TestStats) TestRatio() float64 {
+	if s.ProductionAdditions == 0 {
+		return 0
+	}
+	return float64(s.TestAdditions) / float64(s.ProductionAdditions)
+}
+
+// Add sums stats, for example to get totals for a range of commits.
+func (s *TestStats) Add(s2 TestStats) {
+	s.TestAdditions += s2.TestAdditions
+	s.TestDeletions += s2.TestDeletions
+	s.ProductionAdditions += s2.ProductionAdditions
+	s.ProductionDeletions += s2.ProductionDeletions
+}
+
+func testStats(commit Commit, blames []BlameResult) (res TestStats) {
+	for _, b := range blames {
+		f, ok := commit.Files[b.Filename]
+		if !ok {
+			continue
+		}
+		if b.IsTest {
+			res.TestAdditions += f.Additions
+			res.TestDeletions += f.Deletions
+		} else {
+			res.ProductionAdditions += f.Additions
+			res.ProductionDeletions += f.Deletions
+		}
+	}
+	return
+}
@@ -0,0 +1,21 @@
+package ripsrc
+
+import (
+	"testing"
+)
+
+func TestTestStats(t *testing.T) {
+	commit := Commit{Files: map[string]*CommitFile{
+		"a.go":      {Additions: 10, Deletions: 2},
+		"a_test.go": {Additions: 5, Deletions: 1},
+	}}
+	blames := []BlameResult{{Filename: "a.go"}, {Filename: "a_test.go", IsTest: true}}
+	got := testStats(commit, blames)
+	want := TestStats{TestAdditions: 5, TestDeletions: 1, ProductionAdditions: 10, ProductionDeletions: 2}
+	if got != want {
+		t.Fatalf("wanted %+v got %+v", want, got)
+	}
+	if got.TestRatio() != 0.5 {
+		t.Fatalf("unexpected ratio %v", got.TestRatio())
+	}
+}
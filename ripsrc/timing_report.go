@@ -0,0 +1,122 @@
+package ripsrc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+)
+
+// TimingReport contains per-stage timings for all Code, CodeByCommit, Branches and BranchDiff calls made on Ripsrc instance.
+type TimingReport struct {
+	// Stages sorted by name. See metrics.Stage* for possible values.
+	Stages []StageTiming
+	// Wall is the total wall time spent in ripsrc calls.
+	Wall time.Duration
+	// CPU is the user and system CPU time used by the process during ripsrc calls.
+	// It is process wide, since stages run concurrently and can't be separated.
+	CPU time.Duration
+	// SlowestCommits are the commits that took the longest in incremental blame.
+	SlowestCommits []process.CommitWithDuration
+}
+
+// StageTiming is the wall time and number of executions of a pipeline stage.
+// For stages executed per commit, such as regular_commit or code_info, Count is the number of commits.
+type StageTiming struct {
+	Stage string
+	Count int
+	Wall  time.Duration
+}
+
+// String returns human-readable report for logs.
+func (s TimingReport) String() string {
+	var res []string
+	res = append(res, fmt.Sprintf("total wall %v cpu %v", s.Wall, s.CPU))
+	for _, st := range s.Stages {
+		avg := time.Duration(0)
+		if st.Count != 0 {
+			avg = st.Wall / time.Duration(st.Count)
+		}
+		res = append(res, fmt.Sprintf("stage %v count %v wall %v avg %v", st.Stage, st.Count, st.Wall, avg))
+	}
+	for _, c := range s.SlowestCommits {
+		res = append(res, fmt.Sprintf("slow commit %v %v", c.Commit, c.Duration))
+	}
+	return strings.Join(res, "\n")
+}
+
+// TimingReport returns per-stage timings for calls made so far.
+func (s *Ripsrc) TimingReport() TimingReport {
+	res := s.timings.Report()
+	res.SlowestCommits = s.GitProcessTimings.SlowestCommits
+	return res
+}
+
+// stageTimings is a metrics sink that aggregates stage durations for TimingReport.
+type stageTimings struct {
+	mu     sync.Mutex
+	stages map[string]*StageTiming
+	wall   time.Duration
+	cpu    time.Duration
+}
+
+func newStageTimings() *stageTimings {
+	s := &stageTimings{}
+	s.stages = map[string]*StageTiming{}
+	return s
+}
+
+func (s *stageTimings) Counter(name string, value float64, labels ...string) {}
+
+func (s *stageTimings) Gauge(name string, value float64, labels ...string) {}
+
+func (s *stageTimings) Duration(name string, d time.Duration, labels ...string) {
+	if name != metrics.StageDuration {
+		return
+	}
+	stage := ""
+	for i := 0; i+1 < len(labels); i += 2 {
+		if labels[i] == "stage" {
+			stage = labels[i+1]
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stages[stage]
+	if !ok {
+		st = &StageTiming{Stage: stage}
+		s.stages[stage] = st
+	}
+	st.Count++
+	st.Wall += d
+}
+
+// track measures wall and cpu time of a ripsrc call. Use as defer s.timings.track()()
+func (s *stageTimings) track() func() {
+	start := time.Now()
+	startCPU := processCPUTime()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.wall += time.Since(start)
+		s.cpu += processCPUTime() - startCPU
+	}
+}
+
+func (s *stageTimings) Report() (res TimingReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res.Wall = s.wall
+	res.CPU = s.cpu
+	for _, st := range s.stages {
+		res.Stages = append(res.Stages, *st)
+	}
+	sort.Slice(res.Stages, func(i, j int) bool {
+		return res.Stages[i].Stage < res.Stages[j].Stage
+	})
+	return
+}
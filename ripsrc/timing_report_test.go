@@ -0,0 +1,36 @@
+package ripsrc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+)
+
+func TestStageTimingsReport(t *testing.T) {
+	s := newStageTimings()
+	s.Duration(metrics.StageDuration, 2*time.Second, "stage", "b")
+	s.Duration(metrics.StageDuration, time.Second, "stage", "a")
+	s.Duration(metrics.StageDuration, 3*time.Second, "stage", "b")
+	s.Duration("other", time.Hour, "stage", "a")
+	s.track()()
+
+	got := s.Report()
+	want := []StageTiming{
+		{Stage: "a", Count: 1, Wall: time.Second},
+		{Stage: "b", Count: 2, Wall: 5 * time.Second},
+	}
+	if len(got.Stages) != len(want) {
+		t.Fatalf("invalid stages, got %+v", got.Stages)
+	}
+	for i := range want {
+		if got.Stages[i] != want[i] {
+			t.Errorf("invalid stage %v, want %+v got %+v", i, want[i], got.Stages[i])
+		}
+	}
+	str := got.String()
+	if !strings.Contains(str, "stage b count 2 wall 5s avg 2.5s") {
+		t.Errorf("invalid string output: %v", str)
+	}
+}
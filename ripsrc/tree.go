@@ -0,0 +1,33 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+)
+
+// treeRef is the revision used for data that describes the current state of the repo, such as CODEOWNERS and project roots. The first of Opts.Refs or HEAD.
+func (s *Ripsrc) treeRef() string {
+	if len(s.opts.Refs) != 0 {
+		return s.opts.Refs[0]
+	}
+	return "HEAD"
+}
+
+// listTree returns paths of all files at treeRef.
+func (s *Ripsrc) listTree(ctx context.Context) (res []string, _ error) {
+	out := bytes.NewBuffer(nil)
+	err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.opts.RepoDir, []string{"ls-tree", "-r", "-z", "--name-only", s.treeRef()})
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range strings.Split(out.String(), "\x00") {
+		if fn == "" {
+			continue
+		}
+		res = append(res, fn)
+	}
+	return res, nil
+}
@@ -0,0 +1,98 @@
+package types
+
+import "time"
+
+// BlameResult holds details about the blame result
+type BlameResult struct {
+	Commit             Commit       `json:"commit"`
+	Language           string       `json:"language"`
+	Filename           string       `json:"filename"`
+	Lines              []*BlameLine `json:"lines"`
+	Size               int64        `json:"size"`
+	Loc                int64        `json:"loc"`
+	Sloc               int64        `json:"sloc"`
+	Comments           int64        `json:"comments"`
+	Blanks             int64        `json:"blanks"`
+	Complexity         int64        `json:"complexity"`
+	WeightedComplexity float64      `json:"weighted_complexity"`
+	Skipped            string       `json:"skipped,omitempty"`
+	License            *License     `json:"license,omitempty"`
+	Status             CommitStatus `json:"status"`
+	// Generated is true if file was created by a code generator, based on file name or header comments. Generated files have Skipped set, but still include Lines and stats.
+	Generated bool `json:"generated"`
+	// IsTest is true if file looks like a test based on path conventions or test framework imports.
+	IsTest bool `json:"is_test"`
+	// DeletedLines is the number of lines deleted or rewritten in this file by the commit, by sha of the commit that added them.
+	// Only set with Opts.TrackDeletions, not set for merge commits.
+	DeletedLines map[string]int `json:"deleted_lines,omitempty"`
+	// Hunks are changed ranges of this file in the commit, as in git show. Only set with Opts.TrackHunks, not set for merge commits and binary files.
+	Hunks []Hunk `json:"hunks,omitempty"`
+	// Patch is the unified diff of this file in the commit in git format, as in git log -p. Only set with Opts.IncludePatches, not set for merge commits.
+	Patch string `json:"patch,omitempty"`
+	// PatchTruncated is true if Patch was cut to Opts.MaxPatchSize.
+	PatchTruncated bool `json:"patch_truncated,omitempty"`
+	// BlameError is the error parsing or applying the diff of this file in this commit. The file is skipped in this and following commits, until it is added again.
+	BlameError string `json:"blame_error,omitempty"`
+	// BlobSHA is the sha of the file content after the commit, same content has the same sha across commits and files. Empty for removed files.
+	BlobSHA string `json:"blob_sha,omitempty"`
+	// BlobSize is the size of the file content in bytes after the commit. Zero for removed files.
+	BlobSize int64 `json:"blob_size,omitempty"`
+	// Mode is the git file mode after the commit, 100644 for regular and 100755 for executable files. Empty for removed files.
+	Mode string `json:"mode,omitempty"`
+	// Owners are the owners of the file from CODEOWNERS, such as @user, @org/team or email. Only set with Opts.CodeOwners.
+	Owners []string `json:"owners,omitempty"`
+	// ProjectID is the root directory of the project containing the file in a monorepo, "." for repo root. Empty if file is not in any project. Only set with Opts.Projects.
+	ProjectID string `json:"project_id,omitempty"`
+	// Analyses are values returned by analyzers selected in Opts.Analyzers, by analyzer name. Analyzers returning nil are not included.
+	Analyses map[string]interface{} `json:"analyses,omitempty"`
+}
+
+// Executable returns true if file has executable bit set.
+func (r BlameResult) Executable() bool {
+	return r.Mode == ModeExecutable
+}
+
+// IsSkipped returns true if file was not analyzed, Skipped contains the reason.
+func (r BlameResult) IsSkipped() bool {
+	return r.Skipped != ""
+}
+
+// Hunk is a changed range of a file in a commit, the same as a hunk of the unified diff. Ranges include up to 3 unchanged context lines around changes.
+type Hunk struct {
+	// OldStart is the 1-based first line of the hunk before the commit. 0 for added files.
+	OldStart int `json:"old_start"`
+	// OldLines is the number of lines of the hunk before the commit, including context.
+	OldLines int `json:"old_lines"`
+	// NewStart is the 1-based first line of the hunk after the commit. 0 for removed files.
+	NewStart int `json:"new_start"`
+	// NewLines is the number of lines of the hunk after the commit, including context.
+	NewLines int `json:"new_lines"`
+	// Added is the number of lines added by the hunk. Modified lines count as deleted and added.
+	Added int `json:"added"`
+	// Deleted is the number of lines removed by the hunk.
+	Deleted int `json:"deleted"`
+}
+
+// BlameLine is a single line entry in blame
+type BlameLine struct {
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Comment bool      `json:"comment"`
+	Code    bool      `json:"code"`
+	Blank   bool      `json:"blank"`
+	SHA     string    `json:"sha"`
+	// OriginalSHA is the commit that changed the line when SHA is the merge commit that landed it, see ripsrc.AttributionMerge. Empty otherwise.
+	OriginalSHA string `json:"original_sha,omitempty"`
+	// Line is the 1-based line number in the file after the commit.
+	Line int `json:"line,omitempty"`
+	// PrevLine is the 1-based line number the line had before the commit, so that annotations could be mapped across versions. 0 if the line was added or modified by the commit or mapping is not known.
+	// Only set with ripsrc Opts.TrackLineMapping for files changed by regular commits, not for merges. For renamed files it is the line in the file before the rename.
+	PrevLine int `json:"prev_line,omitempty"`
+}
+
+// License holds details about detected license
+type License struct {
+	Name       string  `json:"name"`
+	Confidence float32 `json:"confidence"`
+}
@@ -0,0 +1,160 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+// Commit is a specific detail around a commit
+type Commit struct {
+	SHA            string `json:"sha"`
+	AuthorName     string `json:"author_name"`
+	AuthorEmail    string `json:"author_email"`
+	CommitterName  string `json:"committer_name"`
+	CommitterEmail string `json:"committer_email"`
+
+	// Date is the author date, when the change was originally made. Keeps the timezone offset of the author, use Date.Zone() to get it.
+	Date time.Time `json:"date"`
+	// CommitterDate is the date when the commit was created. Differs from Date for rebased, amended or cherry-picked commits. Keeps the timezone offset of the committer.
+	CommitterDate time.Time `json:"committer_date"`
+	Ordinal       int64     `json:"ordinal"`
+	Message       string    `json:"message"`
+
+	Parents []string `json:"parents"`
+
+	Files map[string]*CommitFile `json:"files"`
+
+	// Signature is the signature verification result. Only set with Opts.Signatures.
+	Signature Signature `json:"signature"`
+
+	// Trailers are trailers at the end of the commit message, such as Signed-off-by or Co-authored-by, in order. Only set with Opts.CommitTrailers or when returned from Commits.
+	Trailers []Trailer `json:"trailers,omitempty"`
+
+	// ExcludedAuthor is true when the author matches ripsrc Opts.ExcludeAuthors, for example a dependency update bot. Such commits are only returned with Opts.FlagExcludedAuthors.
+	ExcludedAuthor bool `json:"excluded_author,omitempty"`
+}
+
+// Trailer is a key value line at the end of the commit message, such as Signed-off-by: Name <email>.
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Trailer returns values of trailers with key, compared case insensitively, in order.
+func (c Commit) Trailer(key string) (res []string) {
+	for _, t := range c.Trailers {
+		if strings.EqualFold(t.Key, key) {
+			res = append(res, t.Value)
+		}
+	}
+	return
+}
+
+// Author returns either the author name (preference) or the email if not found
+func (c Commit) Author() string {
+	if c.AuthorName != "" {
+		return c.AuthorName
+	}
+	return c.AuthorEmail
+}
+
+// ModeExecutable is the git file mode of executable files.
+const ModeExecutable = "100755"
+
+// CommitFile is a specific detail around a file in a commit
+type CommitFile struct {
+	Filename    string       `json:"filename"`
+	Status      CommitStatus `json:"status"`
+	Renamed     bool         `json:"renamed"`
+	Copied      bool         `json:"copied"`
+	RenamedFrom string       `json:"renamed_from,omitempty"`
+	RenamedTo   string       `json:"renamed_to,omitempty"`
+	CopiedFrom  string       `json:"copied_from,omitempty"`
+	Additions   int          `json:"additions"`
+	Deletions   int          `json:"deletions"`
+	Binary      bool         `json:"binary"`
+	// BlobSHA is the full sha of the file blob after the commit. Empty for removed files.
+	BlobSHA string `json:"blob_sha,omitempty"`
+	// Mode is the git file mode after the commit, for example 100644 or 100755 for executable files. Empty for removed files.
+	Mode string `json:"mode,omitempty"`
+	// BlobSHAPrev is the full sha of the file blob before the commit, in the first parent for merges. Empty for added files.
+	BlobSHAPrev string `json:"blob_sha_prev,omitempty"`
+	// Size is the size of the file in bytes after the commit. Only set with Opts.BlobSizes.
+	Size int64 `json:"size,omitempty"`
+	// SizePrev is the size of the file in bytes before the commit. Only set with Opts.BlobSizes.
+	SizePrev int64 `json:"size_prev,omitempty"`
+}
+
+// Executable returns true if the file has executable bit set after the commit.
+func (s CommitFile) Executable() bool {
+	return s.Mode == ModeExecutable
+}
+
+// Changes returns the number of changed lines, same as changes in GitHub api.
+func (s CommitFile) Changes() int {
+	return s.Additions + s.Deletions
+}
+
+// SizeChange returns the change of file size in bytes, negative if file became smaller. Only set with Opts.BlobSizes. Also set for binary files, that do not have line stats.
+func (s CommitFile) SizeChange() int64 {
+	return s.Size - s.SizePrev
+}
+
+// CommitStatus is a commit status type
+type CommitStatus string
+
+const (
+	// GitFileCommitStatusAdded is the added status
+	GitFileCommitStatusAdded = CommitStatus("added")
+	// GitFileCommitStatusModified is the modified status
+	GitFileCommitStatusModified = CommitStatus("modified")
+	// GitFileCommitStatusRemoved is the removed status
+	GitFileCommitStatusRemoved = CommitStatus("removed")
+)
+
+func (s CommitStatus) String() string {
+	return string(s)
+}
+
+// SignatureStatus is the result of commit signature verification, same as %G? placeholder of git log.
+type SignatureStatus string
+
+const (
+	// SignatureNone is returned for commits without signature.
+	SignatureNone = SignatureStatus("N")
+	// SignatureGood is returned for good and valid signature.
+	SignatureGood = SignatureStatus("G")
+	// SignatureBad is returned for bad signature.
+	SignatureBad = SignatureStatus("B")
+	// SignatureUnknownValidity is returned for good signature with unknown validity, for example from key that is not trusted.
+	SignatureUnknownValidity = SignatureStatus("U")
+	// SignatureExpired is returned for good signature that has expired.
+	SignatureExpired = SignatureStatus("X")
+	// SignatureExpiredKey is returned for good signature made by an expired key.
+	SignatureExpiredKey = SignatureStatus("Y")
+	// SignatureRevokedKey is returned for good signature made by a revoked key.
+	SignatureRevokedKey = SignatureStatus("R")
+	// SignatureCannotCheck is returned when signature could not be checked, for example because of missing key.
+	SignatureCannotCheck = SignatureStatus("E")
+)
+
+// Signature holds GPG or SSH signature details of a commit. Only set with Opts.Signatures.
+type Signature struct {
+	Status SignatureStatus `json:"status,omitempty"`
+	// KeyID is the key used to sign the commit, %GK in git log. Could be set even if signature could not be checked.
+	KeyID string `json:"key_id,omitempty"`
+	// Signer is the name of the signer, %GS in git log. Empty if signature could not be checked.
+	Signer string `json:"signer,omitempty"`
+	// Fingerprint is the fingerprint of the key used to sign the commit, %GF in git log.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Signed returns true if commit has a signature, whether or not it could be verified.
+func (s Signature) Signed() bool {
+	return s.Status != "" && s.Status != SignatureNone
+}
+
+// Verified returns true if commit has a good and valid signature.
+func (s Signature) Verified() bool {
+	return s.Status == SignatureGood
+}
@@ -0,0 +1,12 @@
+// Package types contains the exported result types returned by ripsrc, in one place and without dependencies on processing packages.
+//
+// The types are aliased from ripsrc, commitmeta and fileinfo, so code using those names keeps working. Prefer this package in code that serializes or stores results.
+//
+// # Stability
+//
+// Within a major version:
+//   - exported fields and their JSON names are not renamed or removed, new fields are only added
+//   - JSON names are set explicitly with tags and do not depend on Go field names
+//   - fields that are only filled with specific options use omitempty, consumers should treat missing values as zero values
+//   - string constants, such as CommitStatus and SignatureStatus values, keep their values
+package types
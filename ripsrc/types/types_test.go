@@ -0,0 +1,46 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestJSONNames fails when JSON names change, which breaks stored results of downstream consumers.
+func TestJSONNames(t *testing.T) {
+	date := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := BlameResult{
+		Commit: Commit{
+			SHA:         "c1",
+			AuthorName:  "a",
+			AuthorEmail: "a@example.com",
+			Date:        date,
+			Files: map[string]*CommitFile{
+				"a.go": {Filename: "a.go", Status: GitFileCommitStatusAdded, Additions: 1},
+			},
+			Signature: Signature{Status: SignatureGood},
+		},
+		Language: "Go",
+		Filename: "a.go",
+		Lines:    []*BlameLine{{Name: "a", Email: "a@example.com", Date: date, Code: true, SHA: "c1"}},
+		Loc:      1,
+		License:  &License{Name: "MIT", Confidence: 1},
+		Status:   GitFileCommitStatusAdded,
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"commit":{"sha":"c1","author_name":"a","author_email":"a@example.com","committer_name":"","committer_email":"","date":"2019-01-01T00:00:00Z","committer_date":"0001-01-01T00:00:00Z","ordinal":0,"message":"","parents":null,"files":{"a.go":{"filename":"a.go","status":"added","renamed":false,"copied":false,"additions":1,"deletions":0,"binary":false}},"signature":{"status":"G"}},"language":"Go","filename":"a.go","lines":[{"name":"a","email":"a@example.com","date":"2019-01-01T00:00:00Z","comment":false,"code":true,"blank":false,"sha":"c1"}],"size":0,"loc":1,"sloc":0,"comments":0,"blanks":0,"complexity":0,"weighted_complexity":0,"license":{"name":"MIT","confidence":1},"status":"added","generated":false,"is_test":false}`
+	if string(b) != want {
+		t.Fatalf("JSON changed\ngot:  %s\nwant: %s", b, want)
+	}
+	var got BlameResult
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Commit.Files["a.go"].Additions != 1 || got.Lines[0].SHA != "c1" || !got.Commit.Signature.Verified() {
+		t.Fatalf("round trip lost data %+v", got)
+	}
+}
@@ -0,0 +1,271 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pinpt/ripsrc/ripsrc/fileinfo"
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process/repo"
+)
+
+// Validate checks that options are usable before starting processing. It is called automatically by Code, CodeByCommit, Branches, BranchDiff and Tags, but could be called earlier to fail fast.
+//
+// Checks that RepoDir is a git repo, CheckpointsDir is writable, CommitFromIncl exists in repo and that flags that require each other are set.
+func (s Opts) Validate() error {
+	return s.validate(context.Background())
+}
+
+func (s Opts) validate(ctx context.Context) error {
+	err := s.validateFlags()
+	if err != nil {
+		return fmt.Errorf("ripsrc: invalid opts: %w", err)
+	}
+	err = s.validateDirs(ctx)
+	if err != nil {
+		return fmt.Errorf("ripsrc: invalid opts: %w", err)
+	}
+	if s.CommitFromIncl != "" {
+		out := bytes.NewBuffer(nil)
+		err := gitexec.ExecIntoWriter(ctx, out, gitCommand, s.RepoDir, []string{"rev-parse", "--verify", "--quiet", s.CommitFromIncl + "^{commit}"})
+		if err != nil {
+			return fmt.Errorf("ripsrc: invalid opts: CommitFromIncl %v does not exist in repo %v: %w", s.CommitFromIncl, s.RepoDir, err)
+		}
+	}
+	return nil
+}
+
+func (s Opts) validateFlags() error {
+	if s.RepoDir == "" {
+		return errors.New("RepoDir is required")
+	}
+	if s.CommitFromMakeNonIncl && s.CommitFromIncl == "" {
+		return errors.New("CommitFromMakeNonIncl requires CommitFromIncl")
+	}
+	if s.NamespaceCheckpoints && s.CheckpointsDir == "" {
+		return errors.New("NamespaceCheckpoints requires CheckpointsDir")
+	}
+	switch s.SkippedFiles {
+	case SkippedFilesReason, SkippedFilesFlag, SkippedFilesDrop:
+	default:
+		return fmt.Errorf("invalid SkippedFiles: %q", s.SkippedFiles)
+	}
+	if s.ChurnWindow < 0 {
+		return errors.New("ChurnWindow must not be negative")
+	}
+	if s.RepoID != "" {
+		err := validRepoID(s.RepoID)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := newBranchFilter(s.BranchesInclude, s.BranchesExclude); err != nil {
+		return err
+	}
+	if err := fileinfo.ValidateVendorRules(s.VendorRules); err != nil {
+		return err
+	}
+	if _, err := newAuthorFilter(s.ExcludeAuthors); err != nil {
+		return err
+	}
+	if s.FlagExcludedAuthors && len(s.ExcludeAuthors) == 0 {
+		return errors.New("FlagExcludedAuthors requires ExcludeAuthors")
+	}
+	if s.ReadOnly && s.CheckpointsDir == "" {
+		return errors.New("ReadOnly requires CheckpointsDir outside of RepoDir")
+	}
+	if s.MaxDuration < 0 {
+		return errors.New("MaxDuration must not be negative")
+	}
+	if s.CommitDeadline < 0 {
+		return errors.New("CommitDeadline must not be negative")
+	}
+	if s.MaxBranches < 0 {
+		return fmt.Errorf("MaxBranches must not be negative, got %v", s.MaxBranches)
+	}
+	if !s.InlineCommitMeta.valid() {
+		return fmt.Errorf("invalid InlineCommitMeta: %q", s.InlineCommitMeta)
+	}
+	if !s.CommitDate.valid() {
+		return fmt.Errorf("invalid CommitDate: %q", s.CommitDate)
+	}
+	if !s.OnHistoryRewrite.valid() {
+		return fmt.Errorf("invalid OnHistoryRewrite: %q", s.OnHistoryRewrite)
+	}
+	if !s.Attribution.valid() {
+		return fmt.Errorf("invalid Attribution: %q", s.Attribution)
+	}
+	if len(s.LandedIn) != 0 && s.Attribution != AttributionMerge {
+		return errors.New("LandedIn requires AttributionMerge")
+	}
+	if _, err := analyzersByName(s.Analyzers); err != nil {
+		return err
+	}
+	for _, p := range s.Profiles {
+		if !p.valid() {
+			return fmt.Errorf("invalid Profiles: %q", p)
+		}
+	}
+	for _, kv := range s.GitEnv {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("GitEnv: expected KEY=VALUE, got %q", kv)
+		}
+	}
+	for _, r := range s.RedactMessages {
+		if !r.valid() {
+			return fmt.Errorf("invalid RedactMessages: %q", r)
+		}
+	}
+	if _, err := newRedactor(s.RedactMessages, s.RedactPatterns); err != nil {
+		return err
+	}
+	if (s.Anonymize.DropMessages || s.Anonymize.DropContent) && len(s.Anonymize.Key) == 0 {
+		return errors.New("Anonymize.DropMessages and Anonymize.DropContent require Anonymize.Key")
+	}
+	if s.GitRecordDir != "" && s.GitReplayDir != "" {
+		return errors.New("GitRecordDir and GitReplayDir are mutually exclusive")
+	}
+	_, err := repo.CompressionByName(s.CheckpointCompression)
+	if err != nil {
+		return err
+	}
+	if err := s.Stages.validate(); err != nil {
+		return err
+	}
+	if s.ClassifyChurn && !s.Stages.Has(StageBlame) {
+		return errors.New("ClassifyChurn requires StageBlame")
+	}
+	if s.VerificationSampleRate < 0 || s.VerificationSampleRate > 1 {
+		return fmt.Errorf("VerificationSampleRate must be from 0 to 1, got %v", s.VerificationSampleRate)
+	}
+	if s.VerificationSampleRate != 0 && !s.Stages.Has(StageBlame) {
+		return errors.New("VerificationSampleRate requires StageBlame")
+	}
+	for _, pr := range s.PullRequests {
+		if pr.HeadSHA == "" {
+			return fmt.Errorf("PullRequests: HeadSHA is required, pull request id: %v", pr.ID)
+		}
+	}
+	return nil
+}
+
+func (s Opts) validateDirs(ctx context.Context) error {
+	stat, err := os.Stat(s.RepoDir)
+	if err != nil {
+		return fmt.Errorf("RepoDir %v is not accessible: %w", s.RepoDir, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("RepoDir %v is not a directory", s.RepoDir)
+	}
+	err = gitexec.ExecIntoWriter(ctx, ioutil.Discard, gitCommand, s.RepoDir, []string{"rev-parse", "--git-dir"})
+	if err != nil {
+		return fmt.Errorf("RepoDir %v is not a git repo: %w", s.RepoDir, err)
+	}
+	if s.ReadOnly {
+		err := s.checkOutsideRepo(s.CheckpointsDir, s.SharedCheckpointsDir)
+		if err != nil {
+			return err
+		}
+	}
+	if s.CheckpointsDir != "" {
+		err := checkWritable(s.CheckpointsDir)
+		if err != nil {
+			return fmt.Errorf("CheckpointsDir %v is not writable: %w", s.CheckpointsDir, err)
+		}
+	}
+	if s.SharedCheckpointsDir != "" {
+		err := checkWritable(s.SharedCheckpointsDir)
+		if err != nil {
+			return fmt.Errorf("SharedCheckpointsDir %v is not writable: %w", s.SharedCheckpointsDir, err)
+		}
+	}
+	return nil
+}
+
+// checkOutsideRepo returns error if any of dirs is inside RepoDir or the git dir of the repo, which could be outside of RepoDir for linked worktrees.
+func (s Opts) checkOutsideRepo(dirs ...string) error {
+	repoDirs := []string{s.RepoDir}
+	if gitDir, err := gitexec.CommonGitDir(s.RepoDir); err == nil {
+		repoDirs = append(repoDirs, gitDir)
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, repoDir := range repoDirs {
+			if pathInside(dir, repoDir) {
+				return fmt.Errorf("ReadOnly requires checkpoints outside of the repo, %v is inside %v", dir, repoDir)
+			}
+		}
+	}
+	return nil
+}
+
+// pathInside returns true if loc is dir or inside of it. Symlinks are resolved for existing paths.
+func pathInside(loc, dir string) bool {
+	loc = absPath(loc)
+	dir = absPath(dir)
+	rel, err := filepath.Rel(dir, loc)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// absPath returns absolute path with symlinks resolved for the longest existing prefix.
+func absPath(loc string) string {
+	loc, err := filepath.Abs(loc)
+	if err != nil {
+		return loc
+	}
+	var rest []string
+	for {
+		res, err := filepath.EvalSymlinks(loc)
+		if err == nil {
+			return filepath.Join(append([]string{res}, rest...)...)
+		}
+		parent := filepath.Dir(loc)
+		if parent == loc {
+			return filepath.Join(append([]string{loc}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(loc)}, rest...)
+		loc = parent
+	}
+}
+
+// checkWritable checks that dir or the closest existing parent, if dir does not exist yet, is writable
+func checkWritable(dir string) error {
+	for {
+		stat, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return err
+			}
+			dir = parent
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return fmt.Errorf("%v is not a directory", dir)
+		}
+		break
+	}
+	f, err := ioutil.TempFile(dir, ".ripsrc-write-check-")
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,65 @@
+package ripsrc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/gitexec"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestValidate(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	c1 := r.Write("a.txt", "a\n").Commit("c1")
+
+	notRepo, err := ioutil.TempDir("", "ripsrc-validate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(notRepo)
+
+	cases := []struct {
+		Label   string
+		Opts    Opts
+		WantErr string
+	}{
+		{"valid", Opts{RepoDir: r.Dir(), CommitFromIncl: c1, CheckpointsDir: filepath.Join(notRepo, "a", "b")}, ""},
+		{"no repo dir", Opts{}, "RepoDir is required"},
+		{"missing repo dir", Opts{RepoDir: filepath.Join(notRepo, "missing")}, "is not accessible"},
+		{"not a repo", Opts{RepoDir: notRepo}, "is not a git repo"},
+		{"missing commit", Opts{RepoDir: r.Dir(), CommitFromIncl: strings.Repeat("1", 40)}, "does not exist in repo"},
+		{"non incl without commit", Opts{RepoDir: r.Dir(), CommitFromMakeNonIncl: true}, "requires CommitFromIncl"},
+		{"checkpoints dir is file", Opts{RepoDir: r.Dir(), CheckpointsDir: filepath.Join(r.Dir(), "a.txt")}, "is not a directory"},
+		{"invalid profile", Opts{RepoDir: r.Dir(), Profiles: []Profile{"block"}}, "invalid Profiles"},
+		{"invalid author pattern", Opts{RepoDir: r.Dir(), ExcludeAuthors: []string{"re:("}}, "invalid author pattern"},
+		{"invalid verification sample rate", Opts{RepoDir: r.Dir(), VerificationSampleRate: 2}, "VerificationSampleRate must be from 0 to 1"},
+		{"anonymize without key", Opts{RepoDir: r.Dir(), Anonymize: AnonymizeOpts{DropMessages: true}}, "require Anonymize.Key"},
+		{"invalid redaction", Opts{RepoDir: r.Dir(), RedactMessages: []Redaction{"phones"}}, "invalid RedactMessages"},
+		{"invalid redact pattern", Opts{RepoDir: r.Dir(), RedactPatterns: []string{"("}}, "invalid redact pattern"},
+		{"invalid inline commit meta", Opts{RepoDir: r.Dir(), InlineCommitMeta: "sha"}, "invalid InlineCommitMeta"},
+		{"record and replay", Opts{RepoDir: r.Dir(), GitRecordDir: notRepo, GitReplayDir: notRepo}, "mutually exclusive"},
+		{"invalid vendor rule", Opts{RepoDir: r.Dir(), VendorRules: []VendorRule{{Name: "sdk", Pattern: "("}}}, "invalid vendor rule"},
+	}
+	for _, c := range cases {
+		err := c.Opts.Validate()
+		if c.WantErr == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", c.Label, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.WantErr) {
+			t.Errorf("%v: wanted error containing %q, got %v", c.Label, c.WantErr, err)
+		}
+	}
+
+	err = Opts{RepoDir: notRepo}.Validate()
+	if !errors.Is(err, gitexec.ErrNotARepo) {
+		t.Errorf("expected ErrNotARepo, got %v", err)
+	}
+}
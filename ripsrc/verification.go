@@ -0,0 +1,48 @@
+package ripsrc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/history3/verify"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+)
+
+func newVerifier(opts Opts) *verify.Verifier {
+	if opts.VerificationSampleRate <= 0 {
+		return nil
+	}
+	return verify.New(verify.Opts{RepoDir: opts.RepoDir, SampleRate: opts.VerificationSampleRate})
+}
+
+// verifyResult cross-checks sampled files of the commit with git blame, see Opts.VerificationSampleRate. Divergences and git errors are only logged, so that verification never fails processing.
+func (s *Ripsrc) verifyResult(ctx context.Context, r process.Result) {
+	if s.verifier == nil {
+		return
+	}
+	var files []string
+	for p := range r.Files {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	for _, p := range files {
+		if !s.verifier.Sample(r.Commit, p) {
+			continue
+		}
+		div, err := s.verifier.CheckFile(ctx, r.Commit, p, r.Files[p])
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.opts.Logger.Warn("could not verify blame", "commit", r.Commit, "file", p, "err", err)
+			continue
+		}
+		s.opts.Metrics.Counter(metrics.VerifiedFiles, 1)
+		if len(div) == 0 {
+			continue
+		}
+		s.opts.Metrics.Counter(metrics.VerificationDivergences, 1, "reason", string(div[0].Reason))
+		s.opts.Logger.Warn("blame differs from git blame", "commit", r.Commit, "file", p, "lines", len(div), "first", div[0].String())
+	}
+}
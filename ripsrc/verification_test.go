@@ -0,0 +1,63 @@
+package ripsrc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/commitmeta"
+	"github.com/pinpt/ripsrc/ripsrc/history3/incblame"
+	"github.com/pinpt/ripsrc/ripsrc/history3/process"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/logger"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/metrics"
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestVerificationSampleRate(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.go", "package a\n\nfunc a() {}\n").Commit("c1")
+	c2 := r.Branch("b").Write("a.go", "package a\n\nfunc a() {}\n\nfunc b() {}\n").Commit("c2")
+	r.Checkout("master").Write("b.go", "package b\n").Commit("c3")
+	r.Merge("c4", "b")
+
+	run := func(onProcessResult func(process.Result, commitmeta.Commit)) (string, string) {
+		t.Helper()
+		sink := metrics.NewPrometheus()
+		logs := bytes.NewBuffer(nil)
+		rip := New(Opts{RepoDir: r.Dir(), CheckpointsDir: t.TempDir(), VerificationSampleRate: 1, Metrics: sink, Logger: logger.NewDefaultLogger(logs)})
+		rip.onProcessResult = onProcessResult
+		if _, err := rip.CodeSlice(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		sink.WriteTo(buf)
+		return buf.String(), logs.String()
+	}
+
+	out, logs := run(nil)
+	if !strings.Contains(out, metrics.VerifiedFiles+" 3\n") || strings.Contains(out, metrics.VerificationDivergences) {
+		t.Errorf("expected 3 verified files without divergences, got\n%v", out)
+	}
+	if strings.Contains(logs, "differs from git blame") {
+		t.Errorf("unexpected divergence\n%v", logs)
+	}
+
+	// attribute a line to the wrong commit
+	out, logs = run(func(res process.Result, commit commitmeta.Commit) {
+		if res.Commit != c2 {
+			return
+		}
+		bl := *res.Files["a.go"]
+		bl.Lines = append(incblame.Lines{}, bl.Lines...)
+		bl.Lines[4] = &incblame.Line{Line: bl.Lines[4].Line, Commit: "1111111111111111111111111111111111111111"}
+		res.Files["a.go"] = &bl
+	})
+	if !strings.Contains(out, metrics.VerificationDivergences+`{reason="commit"} 1`) {
+		t.Errorf("expected divergence metric, got\n%v", out)
+	}
+	if !strings.Contains(logs, "differs from git blame") || !strings.Contains(logs, c2) {
+		t.Errorf("expected divergence warning, got\n%v", logs)
+	}
+}
@@ -0,0 +1,53 @@
+package ripsrc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/ripsrc/ripsrc/pkg/testkit"
+)
+
+func TestLinkedWorktreeSharesCheckpoints(t *testing.T) {
+	r := testkit.New(t)
+	defer r.Remove()
+	r.Write("a.txt", "a\n").Commit("c1")
+	c2 := r.Write("a.txt", "a\nb\n").Commit("c2")
+
+	tmp, err := ioutil.TempDir("", "ripsrc-worktree-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	wt := filepath.Join(tmp, "wt")
+	r.Git("worktree", "add", "-q", "--detach", wt, c2)
+
+	got := codeByCommitSHAs(t, Opts{RepoDir: wt})
+	if len(got) != 2 || got[1] != c2 {
+		t.Fatalf("unexpected commits %v", got)
+	}
+	info, err := New(Opts{RepoDir: r.Dir()}).Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.Commit != c2 {
+		t.Fatalf("expected checkpoint written from worktree to be used by main worktree, got %+v", info)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "pp-git-cache")); !os.IsNotExist(err) {
+		t.Errorf("checkpoints should not be written inside linked worktree")
+	}
+
+	a, _, err := New(Opts{RepoDir: r.Dir()}).repoID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := New(Opts{RepoDir: wt}).repoID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("expected the same repo id for worktrees, got %v %v", a, b)
+	}
+}